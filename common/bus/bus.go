@@ -0,0 +1,271 @@
+// Package bus fans messages out to subscribers of topics, every subscriber
+// reading its own buffered channel, so a slow one doesn't hold up the others.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what a delivery does when the buffer of a subscriber is full
+type Policy int
+
+const (
+	DropNewest Policy = iota // The subscriber misses the message
+	DropOldest               // The oldest buffered message makes room
+	Block                    // The publisher waits for room, or until its context ends
+	Disconnect               // The subscriber is unsubscribed, its channel closed
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropNewest:
+		return "DropNewest"
+	case DropOldest:
+		return "DropOldest"
+	case Block:
+		return "Block"
+	case Disconnect:
+		return "Disconnect"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// Options of a subscription
+type Options[T any] struct {
+	Buffer int
+	Policy Policy
+	Filter func(message T) bool // Messages it rejects are skipped, nil accepts all
+}
+
+// Bus keeps the subscribers of every topic. Publishing reads a snapshot of
+// the subscribers, which is replaced when they change, so publishers neither
+// wait for each other nor copy thousands of subscribers for every message.
+// The zero value is not usable, create a bus with New.
+type Bus[T any] struct {
+	mutex  sync.RWMutex
+	topics map[string][]*Subscription[T]
+	closed bool
+}
+
+func New[T any]() *Bus[T] {
+	return &Bus[T]{topics: make(map[string][]*Subscription[T])}
+}
+
+// Subscription receives the messages published to its topics, in the order
+// each publisher published them. Messages of different publishers may reach
+// different subscribers in different orders.
+type Subscription[T any] struct {
+	bus      *Bus[T]
+	options  Options[T]
+	messages chan T
+	topics   []string      // Guarded by the mutex of the bus
+	senders  sync.RWMutex  // Held for reading while delivering, so the channel is closed after the last send
+	done     chan struct{} // Closed first when unsubscribing, it wakes up blocked senders
+	closed   bool          // Guarded by senders
+	once     sync.Once
+	dropped  atomic.Uint64
+}
+
+// Subscribe creates a subscription to the topics, more can be joined later.
+// Subscribing to a closed bus returns a subscription already unsubscribed.
+func (b *Bus[T]) Subscribe(options Options[T], topics ...string) *Subscription[T] {
+	s := &Subscription[T]{
+		bus:      b,
+		options:  options,
+		messages: make(chan T, max(options.Buffer, 0)),
+		done:     make(chan struct{}),
+	}
+	b.mutex.Lock()
+	closed := b.closed
+	if !closed {
+		s.join(topics)
+	}
+	b.mutex.Unlock()
+	if closed {
+		s.Unsubscribe()
+	}
+	return s
+}
+
+// Publish delivers a message to the subscribers of a topic, stopping early
+// with the error of ctx when it ends
+func (b *Bus[T]) Publish(ctx context.Context, topic string, message T) error {
+	return b.PublishExcept(ctx, topic, message, nil)
+}
+
+// PublishExcept delivers a message to the subscribers of a topic but one,
+// usually the subscription of the sender
+func (b *Bus[T]) PublishExcept(ctx context.Context, topic string, message T, except *Subscription[T]) error {
+	b.mutex.RLock()
+	subscribers := b.topics[topic]
+	b.mutex.RUnlock()
+	for _, subscriber := range subscribers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if subscriber != except {
+			subscriber.Deliver(ctx, message)
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the number of subscribers of a topic
+func (b *Bus[T]) Subscribers(topic string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.topics[topic])
+}
+
+// Close unsubscribes everyone, the subscribers read what was buffered and
+// then find their channels closed
+func (b *Bus[T]) Close() {
+	b.mutex.Lock()
+	b.closed = true
+	subscribers := make(map[*Subscription[T]]bool)
+	for _, topic := range b.topics {
+		for _, subscriber := range topic {
+			subscribers[subscriber] = true
+		}
+	}
+	b.mutex.Unlock()
+	for subscriber := range subscribers {
+		subscriber.Unsubscribe()
+	}
+}
+
+// C returns the channel of the messages, closed once unsubscribed
+func (s *Subscription[T]) C() <-chan T {
+	return s.messages
+}
+
+// Dropped returns the number of messages the subscriber missed because it was too slow
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Join subscribes to more topics
+func (s *Subscription[T]) Join(topics ...string) {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+	if !s.bus.closed && !s.isClosed() {
+		s.join(topics)
+	}
+}
+
+// Leave unsubscribes from some topics, the messages are still delivered by
+// the subscription's other topics and Deliver
+func (s *Subscription[T]) Leave(topics ...string) {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+	s.leave(topics)
+}
+
+// join and leave copy the subscribers of a topic instead of changing them,
+// publishers may be reading them. The mutex of the bus must be held.
+func (s *Subscription[T]) join(topics []string) {
+	for _, topic := range topics {
+		if !slices.Contains(s.topics, topic) {
+			s.topics = append(s.topics, topic)
+			s.bus.topics[topic] = append(slices.Clip(s.bus.topics[topic]), s)
+		}
+	}
+}
+
+func (s *Subscription[T]) leave(topics []string) {
+	for _, topic := range topics {
+		if !slices.Contains(s.topics, topic) {
+			continue
+		}
+		s.topics = slices.DeleteFunc(s.topics, func(joined string) bool { return joined == topic })
+		subscribers := slices.DeleteFunc(slices.Clone(s.bus.topics[topic]), func(other *Subscription[T]) bool { return other == s })
+		if len(subscribers) == 0 {
+			delete(s.bus.topics, topic)
+		} else {
+			s.bus.topics[topic] = subscribers
+		}
+	}
+}
+
+// Unsubscribe leaves all the topics and closes the channel, after the
+// buffered messages. It can be called more than once.
+func (s *Subscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		// Closed while holding the mutex of the bus, so Join can't undo leaving
+		s.bus.mutex.Lock()
+		close(s.done)
+		s.leave(slices.Clone(s.topics))
+		s.bus.mutex.Unlock()
+
+		s.senders.Lock()
+		s.closed = true
+		close(s.messages)
+		s.senders.Unlock()
+	})
+}
+
+func (s *Subscription[T]) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Deliver sends a message to this subscriber only, following its policy
+// when the buffer is full. It reports whether the message was buffered.
+func (s *Subscription[T]) Deliver(ctx context.Context, message T) bool {
+	if s.options.Filter != nil && !s.options.Filter(message) {
+		return false
+	}
+	delivered, full := s.deliver(ctx, message)
+	if full && s.options.Policy == Disconnect {
+		s.Unsubscribe()
+	}
+	return delivered
+}
+
+func (s *Subscription[T]) deliver(ctx context.Context, message T) (delivered, full bool) {
+	s.senders.RLock()
+	defer s.senders.RUnlock()
+	if s.closed {
+		return false, false
+	}
+	select {
+	case s.messages <- message:
+		return true, false
+	default:
+	}
+
+	switch s.options.Policy {
+	case DropOldest:
+		// Without a buffer there is nothing older to drop
+		for cap(s.messages) > 0 {
+			select {
+			case <-s.messages:
+				s.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.messages <- message:
+				return true, false
+			default:
+				// Another publisher took the room
+			}
+		}
+	case Block:
+		select {
+		case s.messages <- message:
+			return true, false
+		case <-s.done:
+		case <-ctx.Done():
+		}
+	}
+	s.dropped.Add(1)
+	return false, true
+}
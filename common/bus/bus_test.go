@@ -0,0 +1,206 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// received reads what is buffered without waiting
+func received[T any](s *Subscription[T]) []T {
+	var messages []T
+	for {
+		select {
+		case message, ok := <-s.C():
+			if !ok {
+				return messages
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestTopics(t *testing.T) {
+	bus := New[string]()
+	ctx := context.Background()
+	anna := bus.Subscribe(Options[string]{Buffer: 10}, "all", "room")
+	jan := bus.Subscribe(Options[string]{Buffer: 10}, "all")
+
+	bus.Publish(ctx, "all", "hello")
+	bus.Publish(ctx, "room", "in the room")
+	bus.PublishExcept(ctx, "all", "from jan", jan)
+	jan.Deliver(ctx, "private")
+	if got := received(anna); !slices.Equal(got, []string{"hello", "in the room", "from jan"}) {
+		t.Errorf("Anna received %q", got)
+	}
+	if got := received(jan); !slices.Equal(got, []string{"hello", "private"}) {
+		t.Errorf("Jan received %q", got)
+	}
+
+	anna.Leave("room")
+	jan.Join("room")
+	bus.Publish(ctx, "room", "moved")
+	if got, other := received(jan), received(anna); !slices.Equal(got, []string{"moved"}) || len(other) != 0 {
+		t.Errorf("After changing rooms jan received %q and anna %q", got, other)
+	}
+
+	anna.Unsubscribe()
+	anna.Unsubscribe()
+	if _, ok := <-anna.C(); ok {
+		t.Error("Expected the channel to be closed")
+	}
+	if anna.Deliver(ctx, "late") {
+		t.Error("Expected no delivery after unsubscribing")
+	}
+	if bus.Subscribers("all") != 1 || bus.Subscribers("room") != 1 {
+		t.Errorf("Subscribers: %d of all and %d of room, want 1 and 1", bus.Subscribers("all"), bus.Subscribers("room"))
+	}
+}
+
+func TestSlowSubscriberPolicies(t *testing.T) {
+	tests := []struct {
+		policy    Policy
+		expected  []int
+		delivered bool // The last message
+		dropped   uint64
+		closed    bool
+	}{
+		{DropNewest, []int{1, 2}, false, 2, false},
+		{DropOldest, []int{3, 4}, true, 2, false},
+		{Disconnect, []int{1, 2}, false, 1, true}, // The last one came after unsubscribing
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			subscription := New[int]().Subscribe(Options[int]{Buffer: 2, Policy: test.policy})
+			var delivered bool
+			for message := range 4 {
+				delivered = subscription.Deliver(context.Background(), message+1)
+			}
+			got := received(subscription)
+			closed := subscription.isClosed()
+			if !slices.Equal(got, test.expected) || delivered != test.delivered || subscription.Dropped() != test.dropped || closed != test.closed {
+				t.Errorf("Received %v, last delivered %v, %d dropped, closed %v", got, delivered, subscription.Dropped(), closed)
+			}
+		})
+	}
+}
+
+func TestBlockingPolicy(t *testing.T) {
+	bus := New[int]()
+	subscription := bus.Subscribe(Options[int]{Buffer: 1, Policy: Block}, "numbers")
+	bus.Publish(context.Background(), "numbers", 1)
+
+	published := make(chan error)
+	go func() { published <- bus.Publish(context.Background(), "numbers", 2) }()
+	select {
+	case <-published:
+		t.Fatal("Expected publishing to wait for room")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if message := <-subscription.C(); message != 1 {
+		t.Fatalf("Received %d", message)
+	}
+	<-published
+	if message := <-subscription.C(); message != 2 {
+		t.Fatalf("Received %d", message)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	subscription.Deliver(ctx, 3)
+	if subscription.Deliver(ctx, 4) || subscription.Dropped() != 1 {
+		t.Errorf("Expected the delivery to give up when ctx ends, %d dropped", subscription.Dropped())
+	}
+}
+
+func TestFilterAndClose(t *testing.T) {
+	bus := New[int]()
+	even := bus.Subscribe(Options[int]{Buffer: 10, Filter: func(n int) bool { return n%2 == 0 }}, "numbers")
+	for n := range 5 {
+		bus.Publish(context.Background(), "numbers", n)
+	}
+	bus.Close()
+
+	var got []int
+	for n := range even.C() {
+		got = append(got, n)
+	}
+	if !slices.Equal(got, []int{0, 2, 4}) {
+		t.Errorf("Received %v, want the buffered even numbers", got)
+	}
+	if late := bus.Subscribe(Options[int]{Buffer: 1}, "numbers"); bus.Subscribers("numbers") != 0 {
+		t.Error("Expected no subscriptions to a closed bus")
+	} else if _, ok := <-late.C(); ok {
+		t.Error("Expected a subscription to a closed bus to be closed")
+	}
+}
+
+// Publishers racing with subscribers leaving and joining, for the race detector
+func TestConcurrentPublishAndUnsubscribe(t *testing.T) {
+	bus := New[int]()
+	var running sync.WaitGroup
+	for range 8 {
+		running.Add(2)
+		go func() {
+			defer running.Done()
+			for n := range 1000 {
+				bus.Publish(context.Background(), "numbers", n)
+			}
+		}()
+		go func() {
+			defer running.Done()
+			for range 100 {
+				subscription := bus.Subscribe(Options[int]{Buffer: 4, Policy: DropOldest}, "numbers")
+				subscription.Join("other")
+				received(subscription)
+				subscription.Unsubscribe()
+				for range subscription.C() {
+				}
+			}
+		}()
+	}
+	running.Wait()
+	if bus.Subscribers("numbers") != 0 || bus.Subscribers("other") != 0 {
+		t.Error("Expected all the subscriptions to be gone")
+	}
+}
+
+// The subscribers read in goroutines of their own, like connections writing
+// to clients, and the benchmark waits until every one got every message
+func benchmarkFanOut(b *testing.B, subscribers int, policy Policy) {
+	bus := New[int]()
+	var reading sync.WaitGroup
+	for range subscribers {
+		subscription := bus.Subscribe(Options[int]{Buffer: 64, Policy: policy}, "all")
+		reading.Add(1)
+		go func() {
+			defer reading.Done()
+			for range subscription.C() {
+			}
+		}()
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := range b.N {
+		bus.Publish(ctx, "all", n)
+	}
+	bus.Close()
+	reading.Wait()
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*subscribers), "ns/delivery")
+}
+
+func BenchmarkFanOut(b *testing.B) {
+	for _, subscribers := range []int{10, 1000, 10000} {
+		for _, policy := range []Policy{Block, DropOldest} {
+			b.Run(fmt.Sprintf("%d/%v", subscribers, policy), func(b *testing.B) {
+				benchmarkFanOut(b, subscribers, policy)
+			})
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// EvictionReason tells an eviction callback why an entry left the cache
+type EvictionReason int
+
+const (
+	Evicted EvictionReason = iota // Least recently used entry dropped to respect the limits
+	Expired                       // Time to live elapsed
+	Removed                       // Deleted, replaced or cleared
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	default:
+		return "removed"
+	}
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	size      int64
+	expiresAt time.Time // Zero for entries that don't expire
+}
+
+// Cache is a least recently used cache safe for concurrent use, limited by the
+// number of entries and optionally by the total size of the values. Entries may
+// expire, they are dropped when found expired or by Prune. Configure the cache
+// with the With and OnEvict methods before sharing it.
+type Cache[K comparable, V any] struct {
+	mutex    sync.Mutex
+	entries  map[K]*list.Element
+	order    *list.List // Most recently used first
+	capacity int
+	maxSize  int64
+	size     int64
+	sizeOf   func(V) int64
+	ttl      time.Duration
+	onEvict  func(key K, value V, reason EvictionReason)
+	now      func() time.Time
+}
+
+// NewCache creates a cache holding up to capacity entries, 0 means no limit
+func NewCache[K comparable, V any](capacity int) *Cache[K, V] {
+	return &Cache[K, V]{
+		entries:  make(map[K]*list.Element),
+		order:    list.New(),
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+// WithTTL sets the time to live of entries added with Set
+func (c *Cache[K, V]) WithTTL(ttl time.Duration) *Cache[K, V] {
+	c.ttl = ttl
+	return c
+}
+
+// WithMaxSize limits the total size of the values as measured by sizeOf, e.g. in bytes
+func (c *Cache[K, V]) WithMaxSize(maxSize int64, sizeOf func(V) int64) *Cache[K, V] {
+	c.maxSize = maxSize
+	c.sizeOf = sizeOf
+	return c
+}
+
+// OnEvict registers a callback called for every entry leaving the cache, outside of its lock
+func (c *Cache[K, V]) OnEvict(callback func(key K, value V, reason EvictionReason)) *Cache[K, V] {
+	c.onEvict = callback
+	return c
+}
+
+// Get returns the value of an entry that hasn't expired and marks it as recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.mutex.Unlock()
+		var empty V
+		return empty, false
+	}
+	entry := element.Value.(*cacheEntry[K, V])
+	if c.expired(entry, c.now()) {
+		c.remove(element)
+		c.mutex.Unlock()
+		c.evicted(entry, Expired)
+		var empty V
+		return empty, false
+	}
+	c.order.MoveToFront(element)
+	c.mutex.Unlock()
+	return entry.value, true
+}
+
+// Set adds or replaces an entry with the time to live of the cache
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL adds or replaces an entry expiring after ttl, 0 means it doesn't expire.
+// A value larger than the size limit isn't cached at all.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	entry := &cacheEntry[K, V]{key: key, value: value}
+	if c.sizeOf != nil {
+		entry.size = c.sizeOf(value)
+	}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+	var dropped []*cacheEntry[K, V]
+	c.mutex.Lock()
+	if element, ok := c.entries[key]; ok {
+		dropped = append(dropped, c.remove(element))
+	}
+	removed := len(dropped)
+	if c.maxSize > 0 && entry.size > c.maxSize {
+		c.mutex.Unlock()
+		c.notify(dropped, removed)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.size += entry.size
+	for c.overLimit() {
+		dropped = append(dropped, c.remove(c.order.Back()))
+	}
+	c.mutex.Unlock()
+	c.notify(dropped, removed)
+}
+
+// Delete removes an entry and reports whether it was present
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mutex.Lock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.mutex.Unlock()
+		return false
+	}
+	entry := c.remove(element)
+	c.mutex.Unlock()
+	c.evicted(entry, Removed)
+	return true
+}
+
+// Prune drops the expired entries and returns their number
+func (c *Cache[K, V]) Prune() int {
+	var expired []*cacheEntry[K, V]
+	c.mutex.Lock()
+	now := c.now()
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*cacheEntry[K, V]); c.expired(entry, now) {
+			expired = append(expired, c.remove(element))
+		}
+		element = next
+	}
+	c.mutex.Unlock()
+	for _, entry := range expired {
+		c.evicted(entry, Expired)
+	}
+	return len(expired)
+}
+
+func (c *Cache[K, V]) Clear() {
+	var removed []*cacheEntry[K, V]
+	c.mutex.Lock()
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		removed = append(removed, element.Value.(*cacheEntry[K, V]))
+	}
+	clear(c.entries)
+	c.order.Init()
+	c.size = 0
+	c.mutex.Unlock()
+	c.notify(removed, len(removed))
+}
+
+// Len returns the number of entries, including expired ones not dropped yet
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// Size returns the total size of the values when the cache has a size limit
+func (c *Cache[K, V]) Size() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size
+}
+
+func (c *Cache[K, V]) overLimit() bool {
+	return (c.capacity > 0 && c.order.Len() > c.capacity) || (c.maxSize > 0 && c.size > c.maxSize)
+}
+
+func (c *Cache[K, V]) expired(entry *cacheEntry[K, V], now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+func (c *Cache[K, V]) remove(element *list.Element) *cacheEntry[K, V] {
+	entry := c.order.Remove(element).(*cacheEntry[K, V])
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	return entry
+}
+
+// notify reports the first removed entries as removed and the rest as evicted,
+// an evicted entry that was past its time to live counts as expired
+func (c *Cache[K, V]) notify(entries []*cacheEntry[K, V], removed int) {
+	if c.onEvict == nil {
+		return
+	}
+	now := c.now()
+	for i, entry := range entries {
+		switch {
+		case i < removed:
+			c.onEvict(entry.key, entry.value, Removed)
+		case c.expired(entry, now):
+			c.onEvict(entry.key, entry.value, Expired)
+		default:
+			c.onEvict(entry.key, entry.value, Evicted)
+		}
+	}
+}
+
+func (c *Cache[K, V]) evicted(entry *cacheEntry[K, V], reason EvictionReason) {
+	if c.onEvict != nil {
+		c.onEvict(entry.key, entry.value, reason)
+	}
+}
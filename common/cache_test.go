@@ -0,0 +1,137 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eviction struct {
+	key    string
+	reason EvictionReason
+}
+
+func newTestCache(capacity int) (*Cache[string, int], *[]eviction, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var evictions []eviction
+	cache := NewCache[string, int](capacity).OnEvict(func(key string, _ int, reason EvictionReason) {
+		evictions = append(evictions, eviction{key, reason})
+	})
+	cache.now = func() time.Time { return now }
+	return cache, &evictions, &now
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, evictions, _ := newTestCache(2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a") // b is now the least recently used
+	cache.Set("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for key, expected := range map[string]int{"a": 1, "c": 3} {
+		if value, ok := cache.Get(key); !ok || value != expected {
+			t.Errorf("Get(%q) = %d, %v", key, value, ok)
+		}
+	}
+	if !slices.Equal(*evictions, []eviction{{"b", Evicted}}) || cache.Len() != 2 {
+		t.Errorf("Unexpected evictions %v, %d entries", *evictions, cache.Len())
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	cache, evictions, now := newTestCache(0)
+	cache.WithTTL(time.Minute)
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, time.Hour)
+	cache.SetWithTTL("c", 3, 0)
+	cache.Set("d", 4)
+	*now = now.Add(time.Minute)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a to expire")
+	}
+	if value, ok := cache.Get("b"); !ok || value != 2 {
+		t.Errorf("Expected b to live for an hour, got %d, %v", value, ok)
+	}
+	if pruned := cache.Prune(); pruned != 1 || cache.Len() != 2 {
+		t.Errorf("Expected Prune to drop d, dropped %d, %d entries left", pruned, cache.Len())
+	}
+	*now = now.Add(24 * time.Hour)
+	if value, ok := cache.Get("c"); !ok || value != 3 {
+		t.Errorf("Expected c never to expire, got %d, %v", value, ok)
+	}
+	if expected := []eviction{{"a", Expired}, {"d", Expired}}; !slices.Equal(*evictions, expected) {
+		t.Errorf("Expected evictions %v, got %v", expected, *evictions)
+	}
+}
+
+func TestCacheMaxSize(t *testing.T) {
+	cache, evictions, _ := newTestCache(0)
+	cache.WithMaxSize(10, func(value int) int64 { return int64(value) })
+	cache.Set("a", 4)
+	cache.Set("b", 4)
+	cache.Set("c", 4) // Over 10, a goes
+	if cache.Size() != 8 || cache.Len() != 2 {
+		t.Errorf("Expected size 8 in 2 entries, got %d in %d", cache.Size(), cache.Len())
+	}
+	cache.Set("huge", 11)
+	if _, ok := cache.Get("huge"); ok {
+		t.Error("Expected a value over the limit not to be cached")
+	}
+	cache.Set("b", 1) // Replacing an entry updates the size
+	if cache.Size() != 5 {
+		t.Errorf("Expected size 5 after replacing b, got %d", cache.Size())
+	}
+	if expected := []eviction{{"a", Evicted}, {"b", Removed}}; !slices.Equal(*evictions, expected) {
+		t.Errorf("Expected evictions %v, got %v", expected, *evictions)
+	}
+}
+
+func TestCacheDeleteAndClear(t *testing.T) {
+	cache, evictions, now := newTestCache(2)
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, time.Second)
+	if !cache.Delete("a") || cache.Delete("a") {
+		t.Error("Expected only the first Delete to find a")
+	}
+	*now = now.Add(time.Second)
+	cache.Set("c", 3)
+	cache.Set("d", 4) // Evicts b, which expired meanwhile
+	cache.Clear()
+	if cache.Len() != 0 || cache.Size() != 0 {
+		t.Errorf("Expected an empty cache, got %d entries", cache.Len())
+	}
+	expected := []eviction{{"a", Removed}, {"b", Expired}, {"d", Removed}, {"c", Removed}}
+	if !slices.Equal(*evictions, expected) {
+		t.Errorf("Expected evictions %v, got %v", expected, *evictions)
+	}
+	if Evicted.String() != "evicted" || Expired.String() != "expired" || Removed.String() != "removed" {
+		t.Error("Unexpected reason names")
+	}
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	cache := NewCache[int, string](100).WithTTL(time.Minute)
+	var wg sync.WaitGroup
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				key := (worker*1000 + i) % 150
+				if value, ok := cache.Get(key); ok && value != fmt.Sprint(key) {
+					t.Errorf("Get(%d) = %q", key, value)
+				}
+				cache.Set(key, fmt.Sprint(key))
+			}
+		}()
+	}
+	wg.Wait()
+	if cache.Len() > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", cache.Len())
+	}
+}
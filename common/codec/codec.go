@@ -0,0 +1,218 @@
+// Package codec serializes values to bytes and streams in a selectable format,
+// optionally limiting the size of an encoded value.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+var ErrTooLarge = errors.New("encoded value is too large")
+
+// Format creates the encoders and decoders of a serialization format
+type Format interface {
+	Name() string
+	NewEncoder(writer io.Writer) Encoder
+	NewDecoder(reader io.Reader) Decoder
+}
+
+// Encoder and Decoder are satisfied by the encoders and decoders of the standard library
+type Encoder interface {
+	Encode(value any) error
+}
+
+type Decoder interface {
+	Decode(value any) error
+}
+
+var (
+	Gob  Format = gobFormat{}
+	JSON Format = jsonFormat{}
+	XML  Format = xmlFormat{}
+)
+
+type gobFormat struct{}
+
+func (gobFormat) Name() string                        { return "gob" }
+func (gobFormat) NewEncoder(writer io.Writer) Encoder { return gob.NewEncoder(writer) }
+func (gobFormat) NewDecoder(reader io.Reader) Decoder { return gob.NewDecoder(reader) }
+
+type jsonFormat struct{}
+
+func (jsonFormat) Name() string                        { return "json" }
+func (jsonFormat) NewEncoder(writer io.Writer) Encoder { return json.NewEncoder(writer) }
+func (jsonFormat) NewDecoder(reader io.Reader) Decoder { return json.NewDecoder(reader) }
+
+type xmlFormat struct{}
+
+func (xmlFormat) Name() string                        { return "xml" }
+func (xmlFormat) NewEncoder(writer io.Writer) Encoder { return xml.NewEncoder(writer) }
+func (xmlFormat) NewDecoder(reader io.Reader) Decoder { return xml.NewDecoder(reader) }
+
+type options struct {
+	format  Format
+	maxSize int
+}
+
+type Option func(*options)
+
+// WithFormat selects the format, gob by default
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithMaxSize limits the encoded size of a single value in bytes, 0 means no limit
+func WithMaxSize(maxSize int) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{format: Gob}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Encode serializes a single value
+func Encode[T any](value T, opts ...Option) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := NewEncoder[T](&buffer, opts...).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decode deserializes a single value encoded by Encode with the same format
+func Decode[T any](data []byte, opts ...Option) (T, error) {
+	var value T
+	err := DecodeInto(data, &value, opts...)
+	return value, err
+}
+
+// DecodeInto deserializes into the value target points to, for callers that don't know its type
+func DecodeInto(data []byte, target any, opts ...Option) error {
+	o := newOptions(opts)
+	if o.maxSize > 0 && len(data) > o.maxSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, len(data), o.maxSize)
+	}
+	return o.format.NewDecoder(bytes.NewReader(data)).Decode(target)
+}
+
+// StreamEncoder writes a stream of values. Formats like gob describe a type
+// once per stream, so its values can't be read back with Decode one by one.
+type StreamEncoder[T any] struct {
+	writer  limitedWriter // Without a limit, it lets Encode redirect the encoder
+	format  Format
+	maxSize int
+	encoder Encoder
+}
+
+func NewEncoder[T any](writer io.Writer, opts ...Option) *StreamEncoder[T] {
+	o := newOptions(opts)
+	e := &StreamEncoder[T]{writer: limitedWriter{writer: writer}, format: o.format, maxSize: o.maxSize}
+	e.encoder = o.format.NewEncoder(&e.writer)
+	return e
+}
+
+// Encode writes the value, a value over the size limit fails before anything is written
+func (e *StreamEncoder[T]) Encode(value T) error {
+	if e.maxSize == 0 {
+		return e.encoder.Encode(value)
+	}
+	// The size is measured by an encoder of its own, the one of the stream
+	// would remember sending the type of a rejected value, like gob does, and
+	// leave the next ones undecodable. On its own a value is at least as large.
+	measured := limitedWriter{writer: io.Discard, limit: e.maxSize}
+	if err := e.format.NewEncoder(&measured).Encode(value); err != nil {
+		return err
+	}
+	// Encoders may write a value in several parts, buffer it to write it at once
+	var buffer bytes.Buffer
+	destination := e.writer.writer
+	e.writer.writer = &buffer
+	err := e.encoder.Encode(value)
+	e.writer.writer = destination
+	if err != nil {
+		return err
+	}
+	_, err = destination.Write(buffer.Bytes())
+	return err
+}
+
+// StreamDecoder reads a stream of values written by a StreamEncoder with the same format
+type StreamDecoder[T any] struct {
+	reader  limitedReader
+	decoder Decoder
+}
+
+func NewDecoder[T any](reader io.Reader, opts ...Option) *StreamDecoder[T] {
+	o := newOptions(opts)
+	d := &StreamDecoder[T]{reader: limitedReader{reader: reader, limit: o.maxSize}}
+	d.decoder = o.format.NewDecoder(&d.reader)
+	return d
+}
+
+// Decode reads the next value, io.EOF marks the end of the stream. Formats that
+// buffer their input may let a value over the size limit through by the bytes
+// they read ahead while decoding the previous one.
+func (d *StreamDecoder[T]) Decode() (T, error) {
+	var value T
+	d.reader.read = 0
+	err := d.decoder.Decode(&value)
+	return value, err
+}
+
+// All iterates over the values until the end of the stream or the first error
+func (d *StreamDecoder[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			value, err := d.Decode()
+			if err == io.EOF || !yield(value, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+type limitedWriter struct {
+	writer  io.Writer
+	limit   int
+	written int
+}
+
+func (w *limitedWriter) Write(data []byte) (int, error) {
+	w.written += len(data)
+	if w.limit > 0 && w.written > w.limit {
+		return 0, fmt.Errorf("%w: over %d bytes", ErrTooLarge, w.limit)
+	}
+	return w.writer.Write(data)
+}
+
+type limitedReader struct {
+	reader io.Reader
+	limit  int
+	read   int
+}
+
+func (r *limitedReader) Read(data []byte) (int, error) {
+	if r.limit > 0 {
+		if r.read >= r.limit {
+			return 0, fmt.Errorf("%w: over %d bytes", ErrTooLarge, r.limit)
+		}
+		data = data[:min(len(data), r.limit-r.read)]
+	}
+	n, err := r.reader.Read(data)
+	r.read += n
+	return n, err
+}
@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	FirstName string
+	LastName  string
+	Age       int
+}
+
+var formats = []Format{Gob, JSON, XML}
+
+func TestEncodeAndDecode(t *testing.T) {
+	for _, format := range formats {
+		t.Run(format.Name(), func(t *testing.T) {
+			data, err := Encode(user{"Jan", "Kowalski", 25}, WithFormat(format))
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, err := Decode[user](data, WithFormat(format))
+			if err != nil || decoded != (user{"Jan", "Kowalski", 25}) {
+				t.Errorf("Decode = %v, %v", decoded, err)
+			}
+			var into user
+			if err := DecodeInto(data, &into, WithFormat(format)); err != nil || into != decoded {
+				t.Errorf("DecodeInto = %v, %v", into, err)
+			}
+		})
+	}
+}
+
+func TestDefaultFormatIsGob(t *testing.T) {
+	data, _ := Encode("text")
+	if _, err := Decode[string](data, WithFormat(Gob)); err != nil {
+		t.Errorf("Expected gob by default, got %v", err)
+	}
+	if _, err := Decode[string](data, WithFormat(JSON)); err == nil {
+		t.Error("Expected decoding gob as json to fail")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	if _, err := Encode(long, WithMaxSize(50)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected Encode over the limit to fail, got %v", err)
+	}
+	data, err := Encode(long, WithMaxSize(200))
+	if err != nil {
+		t.Fatalf("Encode under the limit failed: %v", err)
+	}
+	if _, err := Decode[string](data, WithMaxSize(50)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected Decode over the limit to fail, got %v", err)
+	}
+	if value, err := Decode[string](data, WithMaxSize(len(data))); err != nil || value != long {
+		t.Errorf("Decode at the limit = %q, %v", value, err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	users := []user{{"Jan", "Kowalski", 25}, {"Anna", "Nowak", 30}, {"Piotr", "Zieliński", 40}}
+	for _, format := range formats {
+		t.Run(format.Name(), func(t *testing.T) {
+			var stream bytes.Buffer
+			encoder := NewEncoder[user](&stream, WithFormat(format), WithMaxSize(1024))
+			for _, u := range users {
+				if err := encoder.Encode(u); err != nil {
+					t.Fatalf("Encode failed: %v", err)
+				}
+			}
+			var decoded []user
+			for u, err := range NewDecoder[user](&stream, WithFormat(format)).All() {
+				if err != nil {
+					t.Fatalf("Decode failed: %v", err)
+				}
+				decoded = append(decoded, u)
+			}
+			if len(decoded) != len(users) {
+				t.Fatalf("Expected %d users, got %v", len(users), decoded)
+			}
+			for i := range users {
+				if decoded[i] != users[i] {
+					t.Errorf("Expected %v, got %v", users[i], decoded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamMaxSize(t *testing.T) {
+	var stream bytes.Buffer
+	encoder := NewEncoder[string](&stream, WithMaxSize(50))
+	if err := encoder.Encode("short"); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	written := stream.Len()
+	if err := encoder.Encode(strings.Repeat("a", 100)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected a value over the limit to fail, got %v", err)
+	}
+	if stream.Len() != written {
+		t.Errorf("Expected nothing written for a rejected value, the stream grew from %d to %d", written, stream.Len())
+	}
+	if err := encoder.Encode("again"); err != nil {
+		t.Errorf("Expected the stream to stay usable, got %v", err)
+	}
+
+	decoder := NewDecoder[string](bytes.NewReader(stream.Bytes()), WithMaxSize(50))
+	for _, expected := range []string{"short", "again"} {
+		if value, err := decoder.Decode(); err != nil || value != expected {
+			t.Errorf("Expected %q, got %q, %v", expected, value, err)
+		}
+	}
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Expected the end of the stream, got %v", err)
+	}
+
+	stream.Reset()
+	NewEncoder[string](&stream).Encode(strings.Repeat("a", 100))
+	if _, err := NewDecoder[string](&stream, WithMaxSize(50)).Decode(); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected reading a value over the limit to fail, got %v", err)
+	}
+}
+
+// gob describes a type with the first value of it, a rejected first value must
+// not leave the stream thinking the type was described
+func TestStreamMaxSizeRejectsFirstValue(t *testing.T) {
+	for _, format := range formats {
+		t.Run(format.Name(), func(t *testing.T) {
+			var stream bytes.Buffer
+			encoder := NewEncoder[user](&stream, WithFormat(format), WithMaxSize(200))
+			if err := encoder.Encode(user{FirstName: strings.Repeat("a", 300)}); !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("Expected the first value to be too large, got %v", err)
+			}
+			if stream.Len() != 0 {
+				t.Fatalf("Expected nothing written, got %d bytes", stream.Len())
+			}
+			valid := user{"Jan", "Kowalski", 25}
+			if err := encoder.Encode(valid); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if value, err := NewDecoder[user](&stream, WithFormat(format)).Decode(); err != nil || value != valid {
+				t.Errorf("Decoded %v, %v", value, err)
+			}
+		})
+	}
+}
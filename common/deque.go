@@ -0,0 +1,109 @@
+package common
+
+import "iter"
+
+const minDequeCapacity = 8
+
+// Deque is a double-ended queue kept in a circular buffer, growing as needed
+type Deque[T any] struct {
+	data  []T
+	head  int
+	count int
+}
+
+func (d *Deque[T]) PushBack(element T) {
+	d.grow()
+	d.data[d.index(d.count)] = element
+	d.count++
+}
+
+func (d *Deque[T]) PushFront(element T) {
+	d.grow()
+	d.head = d.index(len(d.data) - 1)
+	d.data[d.head] = element
+	d.count++
+}
+
+func (d *Deque[T]) PopFront() (T, bool) {
+	if d.isEmpty() {
+		var empty T
+		return empty, false
+	}
+	element := d.data[d.head]
+	var empty T
+	d.data[d.head] = empty // Don't keep the popped element reachable
+	d.head = d.index(1)
+	d.count--
+	return element, true
+}
+
+func (d *Deque[T]) PopBack() (T, bool) {
+	if d.isEmpty() {
+		var empty T
+		return empty, false
+	}
+	last := d.index(d.count - 1)
+	element := d.data[last]
+	var empty T
+	d.data[last] = empty
+	d.count--
+	return element, true
+}
+
+func (d *Deque[T]) PeekFront() (T, bool) {
+	if d.isEmpty() {
+		var empty T
+		return empty, false
+	}
+	return d.data[d.head], true
+}
+
+func (d *Deque[T]) PeekBack() (T, bool) {
+	if d.isEmpty() {
+		var empty T
+		return empty, false
+	}
+	return d.data[d.index(d.count-1)], true
+}
+
+func (d *Deque[T]) Clear() {
+	clear(d.data)
+	d.head = 0
+	d.count = 0
+}
+
+// All iterates from the front to the back of the deque
+func (d *Deque[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := 0; i < d.count; i++ {
+			if !yield(d.data[d.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+func (d *Deque[T]) Size() int {
+	return d.count
+}
+
+func (d *Deque[T]) isEmpty() bool {
+	return d.count == 0
+}
+
+// index maps a position counted from the head to a slot in the buffer
+func (d *Deque[T]) index(position int) int {
+	return (d.head + position) % len(d.data)
+}
+
+func (d *Deque[T]) grow() {
+	if d.count < len(d.data) {
+		return
+	}
+	data := make([]T, max(minDequeCapacity, 2*len(d.data)))
+	for i := 0; i < d.count; i++ {
+		data[i] = d.data[d.index(i)]
+	}
+	d.data = data
+	d.head = 0
+}
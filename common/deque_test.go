@@ -0,0 +1,95 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDequeBothEnds(t *testing.T) {
+	deque := &Deque[int]{}
+	deque.PushBack(2)
+	deque.PushFront(1)
+	deque.PushBack(3)
+	if front, ok := deque.PeekFront(); !ok || front != 1 {
+		t.Errorf("Expected 1 at the front, got %d, %v", front, ok)
+	}
+	if back, ok := deque.PeekBack(); !ok || back != 3 {
+		t.Errorf("Expected 3 at the back, got %d, %v", back, ok)
+	}
+	if elements := slices.Collect(deque.All()); !slices.Equal(elements, []int{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3], got %v", elements)
+	}
+	if element, ok := deque.PopBack(); !ok || element != 3 {
+		t.Errorf("Expected to pop 3 from the back, got %d, %v", element, ok)
+	}
+	if element, ok := deque.PopFront(); !ok || element != 1 {
+		t.Errorf("Expected to pop 1 from the front, got %d, %v", element, ok)
+	}
+	if deque.Size() != 1 {
+		t.Errorf("Expected size 1, got %d", deque.Size())
+	}
+}
+
+func TestDequeEmpty(t *testing.T) {
+	deque := &Deque[string]{}
+	if _, ok := deque.PopFront(); ok {
+		t.Error("Expected nothing to pop from the front")
+	}
+	if _, ok := deque.PopBack(); ok {
+		t.Error("Expected nothing to pop from the back")
+	}
+	if _, ok := deque.PeekFront(); ok {
+		t.Error("Expected nothing to peek at the front")
+	}
+	if _, ok := deque.PeekBack(); ok {
+		t.Error("Expected nothing to peek at the back")
+	}
+}
+
+func TestDequeGrowsAcrossTheWrap(t *testing.T) {
+	deque := &Deque[int]{}
+	var expected []int
+	// Pushing to the front wraps the head around before the buffer grows
+	for i := 0; i < 3*minDequeCapacity; i++ {
+		if i%2 == 0 {
+			deque.PushFront(i)
+			expected = slices.Insert(expected, 0, i)
+		} else {
+			deque.PushBack(i)
+			expected = append(expected, i)
+		}
+	}
+	if elements := slices.Collect(deque.All()); !slices.Equal(elements, expected) {
+		t.Errorf("Expected %v, got %v", expected, elements)
+	}
+	for _, element := range slices.Backward(expected) {
+		if popped, ok := deque.PopBack(); !ok || popped != element {
+			t.Fatalf("Expected to pop %d, got %d, %v", element, popped, ok)
+		}
+	}
+}
+
+func TestDequeClearAndAll(t *testing.T) {
+	deque := &Deque[int]{}
+	for i := range 5 {
+		deque.PushBack(i)
+	}
+	var visited []int
+	for element := range deque.All() {
+		if element == 2 {
+			break
+		}
+		visited = append(visited, element)
+	}
+	if !slices.Equal(visited, []int{0, 1}) {
+		t.Errorf("Expected iteration to stop at 2, visited %v", visited)
+	}
+	deque.Clear()
+	if deque.Size() != 0 || len(slices.Collect(deque.All())) != 0 {
+		t.Errorf("Expected an empty deque after Clear, got size %d", deque.Size())
+	}
+	deque.PushFront(7)
+	if element, ok := deque.PopBack(); !ok || element != 7 {
+		t.Errorf("Expected the deque to be usable after Clear, got %d, %v", element, ok)
+	}
+}
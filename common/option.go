@@ -0,0 +1,60 @@
+package common
+
+// Option holds a value or nothing, a typed alternative to nil pointers and comma-ok pairs
+type Option[T any] struct {
+	value   T
+	present bool
+}
+
+func Some[T any](value T) Option[T] {
+	return Option[T]{value: value, present: true}
+}
+
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// OptionOf turns a comma-ok pair into an Option, e.g. OptionOf(stack.Pop())
+func OptionOf[T any](value T, ok bool) Option[T] {
+	if !ok {
+		return None[T]()
+	}
+	return Some(value)
+}
+
+func (o Option[T]) IsSome() bool {
+	return o.present
+}
+
+func (o Option[T]) IsNone() bool {
+	return !o.present
+}
+
+// Get returns the value and whether it is present
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.present
+}
+
+// Unwrap returns the value and panics if there is none
+func (o Option[T]) Unwrap() T {
+	if !o.present {
+		panic("unwrap of an empty option")
+	}
+	return o.value
+}
+
+// OrElse returns the value, or fallback if there is none
+func (o Option[T]) OrElse(fallback T) T {
+	if !o.present {
+		return fallback
+	}
+	return o.value
+}
+
+// MapOption applies mapper to a present value
+func MapOption[T, U any](o Option[T], mapper func(T) U) Option[U] {
+	if !o.present {
+		return None[U]()
+	}
+	return Some(mapper(o.value))
+}
@@ -0,0 +1,44 @@
+package common
+
+import "testing"
+
+func TestOption(t *testing.T) {
+	some := Some("text")
+	if !some.IsSome() || some.IsNone() || some.Unwrap() != "text" || some.OrElse("other") != "text" {
+		t.Errorf("Unexpected option %v", some)
+	}
+	none := None[string]()
+	if none.IsSome() || !none.IsNone() || none.OrElse("other") != "other" {
+		t.Errorf("Unexpected empty option %v", none)
+	}
+	if value, present := none.Get(); present || value != "" {
+		t.Errorf("Get = %q, %v", value, present)
+	}
+	length := MapOption(some, func(text string) int { return len(text) })
+	if value, present := length.Get(); !present || value != 4 {
+		t.Errorf("Expected Some(4), got %d, %v", value, present)
+	}
+	if MapOption(none, func(text string) int { return len(text) }).IsSome() {
+		t.Error("Expected mapping None to give None")
+	}
+}
+
+func TestOptionOf(t *testing.T) {
+	stack := &Stack[int]{}
+	stack.Push(1)
+	if value := OptionOf(stack.Pop()); value.Unwrap() != 1 {
+		t.Errorf("Expected Some(1), got %v", value)
+	}
+	if value := OptionOf(stack.Pop()); value.IsSome() {
+		t.Errorf("Expected None for an empty stack, got %v", value)
+	}
+}
+
+func TestOptionUnwrapPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Unwrap of None to panic")
+		}
+	}()
+	None[int]().Unwrap()
+}
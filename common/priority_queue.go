@@ -0,0 +1,107 @@
+package common
+
+import "iter"
+
+type prioritized[T any] struct {
+	element T
+	seq     uint64 // Insertion order, breaks ties between equal priorities
+}
+
+// PriorityQueue is a binary heap taking out the element first by less. Elements
+// of equal priority leave in the order they were pushed.
+type PriorityQueue[T any] struct {
+	data []prioritized[T]
+	less func(a, b T) bool
+	seq  uint64
+}
+
+// NewPriorityQueue creates a queue where a is taken out before b if less(a, b)
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{less: less}
+}
+
+func (q *PriorityQueue[T]) Push(element T) {
+	q.data = append(q.data, prioritized[T]{element, q.seq})
+	q.seq++
+	q.up(len(q.data) - 1)
+}
+
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	if len(q.data) == 0 {
+		var empty T
+		return empty, false
+	}
+	top := q.data[0].element
+	last := len(q.data) - 1
+	q.data[0] = q.data[last]
+	q.data[last] = prioritized[T]{} // Don't keep the popped element reachable
+	q.data = q.data[:last]
+	q.down(0)
+	return top, true
+}
+
+// Peek returns the element Pop would take out without removing it
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	if len(q.data) == 0 {
+		var empty T
+		return empty, false
+	}
+	return q.data[0].element, true
+}
+
+func (q *PriorityQueue[T]) Clear() {
+	clear(q.data)
+	q.data = q.data[:0]
+}
+
+// All iterates in no particular order, use Pop to get the elements by priority
+func (q *PriorityQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, entry := range q.data {
+			if !yield(entry.element) {
+				return
+			}
+		}
+	}
+}
+
+func (q *PriorityQueue[T]) Size() int {
+	return len(q.data)
+}
+
+func (q *PriorityQueue[T]) before(i, j int) bool {
+	if q.less(q.data[i].element, q.data[j].element) {
+		return true
+	}
+	if q.less(q.data[j].element, q.data[i].element) {
+		return false
+	}
+	return q.data[i].seq < q.data[j].seq
+}
+
+func (q *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.before(i, parent) {
+			return
+		}
+		q.data[i], q.data[parent] = q.data[parent], q.data[i]
+		i = parent
+	}
+}
+
+func (q *PriorityQueue[T]) down(i int) {
+	for {
+		first := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(q.data) && q.before(child, first) {
+				first = child
+			}
+		}
+		if first == i {
+			return
+		}
+		q.data[i], q.data[first] = q.data[first], q.data[i]
+		i = first
+	}
+}
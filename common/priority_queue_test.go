@@ -0,0 +1,56 @@
+package common
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	queue := NewPriorityQueue(func(a, b int) bool { return a < b })
+	if _, ok := queue.Peek(); ok {
+		t.Error("Expected nothing to peek on an empty queue")
+	}
+	numbers := rand.Perm(100)
+	for _, number := range numbers {
+		queue.Push(number)
+	}
+	if element, ok := queue.Peek(); !ok || element != 0 || queue.Size() != 100 {
+		t.Errorf("Expected to peek 0 without removing it, got %d, %v, size %d", element, ok, queue.Size())
+	}
+	if elements := slices.Sorted(queue.All()); !slices.Equal(elements, slices.Sorted(slices.Values(numbers))) {
+		t.Errorf("Expected All to visit every element, got %v", elements)
+	}
+	for expected := range 100 {
+		if element, ok := queue.Pop(); !ok || element != expected {
+			t.Fatalf("Expected %d, got %d, %v", expected, element, ok)
+		}
+	}
+	if _, ok := queue.Pop(); ok {
+		t.Error("Expected an empty queue")
+	}
+	queue.Push(1)
+	queue.Clear()
+	if queue.Size() != 0 {
+		t.Errorf("Expected an empty queue after Clear, got size %d", queue.Size())
+	}
+}
+
+func TestPriorityQueueKeepsInsertionOrderOfEqualPriorities(t *testing.T) {
+	type task struct {
+		priority int
+		name     string
+	}
+	queue := NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	for _, task := range []task{{1, "a"}, {2, "b"}, {1, "c"}, {2, "d"}, {1, "e"}, {3, "f"}, {1, "g"}} {
+		queue.Push(task)
+	}
+	var names []string
+	for queue.Size() > 0 {
+		task, _ := queue.Pop()
+		names = append(names, task.name)
+	}
+	if !slices.Equal(names, []string{"f", "b", "d", "a", "c", "e", "g"}) {
+		t.Errorf("Expected priorities first and insertion order among equal ones, got %v", names)
+	}
+}
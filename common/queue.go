@@ -0,0 +1,34 @@
+package common
+
+import "iter"
+
+// Queue is a first in, first out collection
+type Queue[T any] struct {
+	data Deque[T]
+}
+
+func (q *Queue[T]) Enqueue(element T) {
+	q.data.PushBack(element)
+}
+
+func (q *Queue[T]) Dequeue() (T, bool) {
+	return q.data.PopFront()
+}
+
+// Peek returns the oldest element without removing it
+func (q *Queue[T]) Peek() (T, bool) {
+	return q.data.PeekFront()
+}
+
+func (q *Queue[T]) Clear() {
+	q.data.Clear()
+}
+
+// All iterates from the oldest to the newest element, the order of Dequeue
+func (q *Queue[T]) All() iter.Seq[T] {
+	return q.data.All()
+}
+
+func (q *Queue[T]) Size() int {
+	return q.data.Size()
+}
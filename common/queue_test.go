@@ -0,0 +1,35 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	queue := &Queue[string]{}
+	if _, ok := queue.Peek(); ok {
+		t.Error("Expected nothing to peek on an empty queue")
+	}
+	queue.Enqueue("a")
+	queue.Enqueue("b")
+	queue.Enqueue("c")
+	if element, ok := queue.Peek(); !ok || element != "a" || queue.Size() != 3 {
+		t.Errorf("Expected to peek a without removing it, got %q, %v, size %d", element, ok, queue.Size())
+	}
+	if elements := slices.Collect(queue.All()); !slices.Equal(elements, []string{"a", "b", "c"}) {
+		t.Errorf("Expected [a b c], got %v", elements)
+	}
+	for _, expected := range []string{"a", "b", "c"} {
+		if element, ok := queue.Dequeue(); !ok || element != expected {
+			t.Errorf("Expected %q, got %q, %v", expected, element, ok)
+		}
+	}
+	if _, ok := queue.Dequeue(); ok {
+		t.Error("Expected an empty queue")
+	}
+	queue.Enqueue("d")
+	queue.Clear()
+	if queue.Size() != 0 {
+		t.Errorf("Expected an empty queue after Clear, got size %d", queue.Size())
+	}
+}
@@ -0,0 +1,67 @@
+package common
+
+import "fmt"
+
+// Result holds either the value of an operation or the error it failed with
+type Result[T any] struct {
+	Value T
+	Error error
+}
+
+func Ok[T any](value T) Result[T] {
+	return Result[T]{Value: value}
+}
+
+func Failed[T any](err error) Result[T] {
+	return Result[T]{Error: err}
+}
+
+func (r Result[T]) IsOk() bool {
+	return r.Error == nil
+}
+
+// Get returns the value and the error, the way Go functions usually do
+func (r Result[T]) Get() (T, error) {
+	return r.Value, r.Error
+}
+
+// Unwrap returns the value and panics if the operation failed
+func (r Result[T]) Unwrap() T {
+	if r.Error != nil {
+		panic(fmt.Sprintf("unwrap of a failed result: %v", r.Error))
+	}
+	return r.Value
+}
+
+// OrElse returns the value, or fallback if the operation failed
+func (r Result[T]) OrElse(fallback T) T {
+	if r.Error != nil {
+		return fallback
+	}
+	return r.Value
+}
+
+// Option converts the result to an Option, dropping the error
+func (r Result[T]) Option() Option[T] {
+	if r.Error != nil {
+		return None[T]()
+	}
+	return Some(r.Value)
+}
+
+// MapResult applies mapper to the value of a successful result and passes an error on.
+// It is a function, methods can't have type parameters of their own.
+func MapResult[T, U any](r Result[T], mapper func(T) U) Result[U] {
+	if r.Error != nil {
+		return Failed[U](r.Error)
+	}
+	return Ok(mapper(r.Value))
+}
+
+// AndThen chains an operation that may fail itself
+func AndThen[T, U any](r Result[T], next func(T) Result[U]) Result[U] {
+	if r.Error != nil {
+		return Failed[U](r.Error)
+	}
+	return next(r.Value)
+}
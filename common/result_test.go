@@ -0,0 +1,70 @@
+package common
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+var errTest = errors.New("test error")
+
+func parse(text string) Result[int] {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return Failed[int](err)
+	}
+	return Ok(value)
+}
+
+func TestResult(t *testing.T) {
+	ok := Ok(42)
+	if !ok.IsOk() || ok.Unwrap() != 42 || ok.OrElse(0) != 42 {
+		t.Errorf("Unexpected successful result %v", ok)
+	}
+	if value, err := ok.Get(); value != 42 || err != nil {
+		t.Errorf("Get = %d, %v", value, err)
+	}
+	failed := Failed[int](errTest)
+	if failed.IsOk() || failed.OrElse(-1) != -1 {
+		t.Errorf("Unexpected failed result %v", failed)
+	}
+	if _, err := failed.Get(); !errors.Is(err, errTest) {
+		t.Errorf("Expected the error, got %v", err)
+	}
+	if value, present := ok.Option().Get(); !present || value != 42 {
+		t.Errorf("Expected Some(42), got %d, %v", value, present)
+	}
+	if failed.Option().IsSome() {
+		t.Error("Expected a failed result to become None")
+	}
+}
+
+func TestResultUnwrapPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Unwrap of a failed result to panic")
+		}
+	}()
+	Failed[string](errTest).Unwrap()
+}
+
+func TestMapResultAndAndThen(t *testing.T) {
+	double := func(value int) int { return 2 * value }
+	tests := []struct {
+		name     string
+		result   Result[int]
+		expected Result[string]
+	}{
+		{"Success", AndThen(Ok("21"), parse), Ok("42")},
+		{"Failed mapping", AndThen(Ok("x"), parse), Failed[string](strconv.ErrSyntax)},
+		{"Failed before", AndThen(Failed[string](errTest), parse), Failed[string](errTest)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapped := MapResult(MapResult(tt.result, double), strconv.Itoa)
+			if mapped.Value != tt.expected.Value || !errors.Is(mapped.Error, tt.expected.Error) {
+				t.Errorf("Expected %v, got %v", tt.expected, mapped)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"errors"
+	"sync"
+)
+
+// RingBufferMode decides what Put does when the buffer is full
+type RingBufferMode int
+
+const (
+	Overwrite RingBufferMode = iota // Drop the oldest element to make room
+	Block                           // Wait until a consumer takes an element
+)
+
+var ErrRingBufferClosed = errors.New("ring buffer is closed")
+
+// RingBuffer is a fixed-capacity FIFO buffer safe for concurrent producers and consumers.
+// Take waits while the buffer is empty, Put behaves according to the mode.
+type RingBuffer[T any] struct {
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	data     []T
+	head     int
+	count    int
+	mode     RingBufferMode
+	closed   bool
+}
+
+func NewRingBuffer[T any](capacity int, mode RingBufferMode) *RingBuffer[T] {
+	if capacity <= 0 {
+		panic("ring buffer capacity must be positive")
+	}
+	buffer := &RingBuffer[T]{data: make([]T, capacity), mode: mode}
+	buffer.notEmpty = sync.NewCond(&buffer.mutex)
+	buffer.notFull = sync.NewCond(&buffer.mutex)
+	return buffer
+}
+
+// Put adds an element, it reports whether an older element was overwritten to make room
+func (b *RingBuffer[T]) Put(element T) (overwritten bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for b.mode == Block && b.isFull() && !b.closed {
+		b.notFull.Wait()
+	}
+	if b.closed {
+		return false, ErrRingBufferClosed
+	}
+	if b.isFull() {
+		b.removeOldest()
+		overwritten = true
+	}
+	b.data[(b.head+b.count)%len(b.data)] = element
+	b.count++
+	b.notEmpty.Signal()
+	return overwritten, nil
+}
+
+// Take removes the oldest element, waiting for one while the buffer is empty.
+// Once the buffer is closed the remaining elements are still taken, then Take returns false.
+func (b *RingBuffer[T]) Take() (T, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for b.count == 0 && !b.closed {
+		b.notEmpty.Wait()
+	}
+	return b.take()
+}
+
+// TryTake removes the oldest element without waiting
+func (b *RingBuffer[T]) TryTake() (T, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.take()
+}
+
+// Close wakes up all waiting producers and consumers, further puts fail
+func (b *RingBuffer[T]) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	b.notEmpty.Broadcast()
+	b.notFull.Broadcast()
+}
+
+func (b *RingBuffer[T]) Size() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.count
+}
+
+func (b *RingBuffer[T]) Capacity() int {
+	return len(b.data)
+}
+
+func (b *RingBuffer[T]) take() (T, bool) {
+	if b.count == 0 {
+		var empty T
+		return empty, false
+	}
+	element := b.removeOldest()
+	b.notFull.Signal()
+	return element, true
+}
+
+func (b *RingBuffer[T]) removeOldest() T {
+	element := b.data[b.head]
+	var empty T
+	b.data[b.head] = empty // Don't keep the taken element reachable
+	b.head = (b.head + 1) % len(b.data)
+	b.count--
+	return element
+}
+
+func (b *RingBuffer[T]) isFull() bool {
+	return b.count == len(b.data)
+}
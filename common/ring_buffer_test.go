@@ -0,0 +1,114 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRingBufferOverwrite(t *testing.T) {
+	buffer := NewRingBuffer[int](3, Overwrite)
+	for i := 1; i <= 5; i++ {
+		overwritten, err := buffer.Put(i)
+		if err != nil || overwritten != (i > 3) {
+			t.Errorf("Put(%d) = %v, %v", i, overwritten, err)
+		}
+	}
+	if buffer.Size() != 3 || buffer.Capacity() != 3 {
+		t.Errorf("Expected a full buffer of 3, got size %d, capacity %d", buffer.Size(), buffer.Capacity())
+	}
+	for _, expected := range []int{3, 4, 5} {
+		if element, ok := buffer.TryTake(); !ok || element != expected {
+			t.Errorf("Expected %d, got %d, %v", expected, element, ok)
+		}
+	}
+	if _, ok := buffer.TryTake(); ok {
+		t.Error("Expected an empty buffer")
+	}
+}
+
+func TestRingBufferBlock(t *testing.T) {
+	buffer := NewRingBuffer[int](2, Block)
+	buffer.Put(1)
+	buffer.Put(2)
+	put := make(chan struct{})
+	go func() {
+		buffer.Put(3)
+		close(put)
+	}()
+	select {
+	case <-put:
+		t.Fatal("Expected Put to wait on a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if element, ok := buffer.Take(); !ok || element != 1 {
+		t.Errorf("Expected 1, got %d, %v", element, ok)
+	}
+	<-put
+	for _, expected := range []int{2, 3} {
+		if element, ok := buffer.Take(); !ok || element != expected {
+			t.Errorf("Expected %d, got %d, %v", expected, element, ok)
+		}
+	}
+}
+
+func TestRingBufferClose(t *testing.T) {
+	buffer := NewRingBuffer[string](1, Block)
+	buffer.Put("left")
+	blocked := make(chan error)
+	go func() {
+		_, err := buffer.Put("blocked")
+		blocked <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	buffer.Close()
+	if err := <-blocked; !errors.Is(err, ErrRingBufferClosed) {
+		t.Errorf("Expected a waiting Put to fail on Close, got %v", err)
+	}
+	if element, ok := buffer.Take(); !ok || element != "left" {
+		t.Errorf("Expected the remaining element after Close, got %q, %v", element, ok)
+	}
+	if _, ok := buffer.Take(); ok {
+		t.Error("Expected Take on a closed, drained buffer to return false")
+	}
+}
+
+func TestRingBufferProducersAndConsumers(t *testing.T) {
+	buffer := NewRingBuffer[int](4, Block)
+	const producers, elements = 3, 100
+	var wg sync.WaitGroup
+	for range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= elements; i++ {
+				buffer.Put(i)
+			}
+		}()
+	}
+	sums := make(chan int)
+	for range 2 {
+		go func() {
+			sum := 0
+			for element, ok := buffer.Take(); ok; element, ok = buffer.Take() {
+				sum += element
+			}
+			sums <- sum
+		}()
+	}
+	wg.Wait()
+	buffer.Close()
+	if total := <-sums + <-sums; total != producers*elements*(elements+1)/2 {
+		t.Errorf("Expected every element to be taken once, got a total of %d", total)
+	}
+}
+
+func TestNewRingBufferRejectsZeroCapacity(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a zero capacity")
+		}
+	}()
+	NewRingBuffer[int](0, Overwrite)
+}
@@ -1,5 +1,7 @@
 package common
 
+import "iter"
+
 type Stack[T any] struct {
 	data []T
 }
@@ -15,10 +17,37 @@ func (s *Stack[T]) Pop() (T, bool) {
 	}
 	lastIndex := s.Size() - 1
 	element := s.data[lastIndex]
+	var empty T
+	s.data[lastIndex] = empty   // Don't keep the popped element reachable
 	s.data = s.data[:lastIndex] // [0:lastIndex)
 	return element, true
 }
 
+// Peek returns the top element without removing it
+func (s *Stack[T]) Peek() (T, bool) {
+	if s.isEmpty() {
+		var empty T
+		return empty, false
+	}
+	return s.data[s.Size()-1], true
+}
+
+func (s *Stack[T]) Clear() {
+	clear(s.data)
+	s.data = s.data[:0]
+}
+
+// All iterates from the top to the bottom of the stack, the order of Pop
+func (s *Stack[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := s.Size() - 1; i >= 0; i-- {
+			if !yield(s.data[i]) {
+				return
+			}
+		}
+	}
+}
+
 func (s *Stack[T]) isEmpty() bool {
 	return s.Size() == 0
 }
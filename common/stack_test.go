@@ -0,0 +1,91 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStackPushAndPop(t *testing.T) {
+	stack := &Stack[int]{}
+	stack.Push(1)
+	stack.Push(2)
+	stack.Push(3)
+	if stack.Size() != 3 {
+		t.Errorf("Expected size 3, got %d", stack.Size())
+	}
+	for _, expected := range []int{3, 2, 1} {
+		element, ok := stack.Pop()
+		if !ok || element != expected {
+			t.Errorf("Expected %d, got %d, %v", expected, element, ok)
+		}
+	}
+	if element, ok := stack.Pop(); ok || element != 0 {
+		t.Errorf("Expected an empty stack, got %d, %v", element, ok)
+	}
+}
+
+func TestStackPeek(t *testing.T) {
+	stack := &Stack[string]{}
+	if element, ok := stack.Peek(); ok || element != "" {
+		t.Errorf("Expected nothing to peek on an empty stack, got %q, %v", element, ok)
+	}
+	stack.Push("a")
+	stack.Push("b")
+	if element, ok := stack.Peek(); !ok || element != "b" || stack.Size() != 2 {
+		t.Errorf("Expected to peek b without removing it, got %q, %v, size %d", element, ok, stack.Size())
+	}
+}
+
+func TestStackClear(t *testing.T) {
+	stack := &Stack[*int]{}
+	value := 1
+	stack.Push(&value)
+	stack.Push(&value)
+	stack.Clear()
+	if stack.Size() != 0 {
+		t.Errorf("Expected an empty stack, got size %d", stack.Size())
+	}
+	if _, ok := stack.Pop(); ok {
+		t.Error("Expected nothing to pop after Clear")
+	}
+	stack.Push(nil)
+	if stack.Size() != 1 {
+		t.Errorf("Expected the stack to be usable after Clear, got size %d", stack.Size())
+	}
+}
+
+func TestStackAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []int
+		expected []int
+	}{
+		{"Empty", nil, nil},
+		{"Single element", []int{1}, []int{1}},
+		{"From top to bottom", []int{1, 2, 3}, []int{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := &Stack[int]{}
+			for _, element := range tt.elements {
+				stack.Push(element)
+			}
+			if result := slices.Collect(stack.All()); !slices.Equal(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+			if stack.Size() != len(tt.elements) {
+				t.Errorf("Expected iterating to keep the elements, got size %d", stack.Size())
+			}
+		})
+	}
+
+	stack := &Stack[int]{}
+	stack.Push(1)
+	stack.Push(2)
+	for element := range stack.All() {
+		if element != 2 {
+			t.Errorf("Expected to stop after the top element, got %d", element)
+		}
+		break
+	}
+}
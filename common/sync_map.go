@@ -0,0 +1,75 @@
+package common
+
+import (
+	"iter"
+	"sync"
+)
+
+// SyncMap is a typed wrapper over sync.Map, it suits keys written once and read many times
+// or goroutines working on disjoint sets of keys. The zero value is empty and ready for use.
+type SyncMap[K comparable, V any] struct {
+	data sync.Map
+}
+
+func (m *SyncMap[K, V]) Load(key K) (V, bool) {
+	value, ok := m.data.Load(key)
+	if !ok {
+		var empty V
+		return empty, false
+	}
+	// A nil stored for an interface type V fails the assertion, the zero value is that nil
+	typed, _ := value.(V)
+	return typed, true
+}
+
+func (m *SyncMap[K, V]) Store(key K, value V) {
+	m.data.Store(key, value)
+}
+
+// LoadOrStore returns the existing value for the key if present, otherwise it stores the given value
+func (m *SyncMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	stored, loaded := m.data.LoadOrStore(key, value)
+	actual, _ = stored.(V)
+	return actual, loaded
+}
+
+func (m *SyncMap[K, V]) LoadAndDelete(key K) (V, bool) {
+	value, loaded := m.data.LoadAndDelete(key)
+	if !loaded {
+		var empty V
+		return empty, false
+	}
+	typed, _ := value.(V)
+	return typed, true
+}
+
+func (m *SyncMap[K, V]) Delete(key K) {
+	m.data.Delete(key)
+}
+
+func (m *SyncMap[K, V]) Clear() {
+	m.data.Clear()
+}
+
+// Range calls f for each entry until it returns false, see sync.Map.Range for the consistency it offers
+func (m *SyncMap[K, V]) Range(f func(key K, value V) bool) {
+	m.data.Range(func(key, value any) bool {
+		typedKey, _ := key.(K)
+		typedValue, _ := value.(V)
+		return f(typedKey, typedValue)
+	})
+}
+
+func (m *SyncMap[K, V]) All() iter.Seq2[K, V] {
+	return m.Range
+}
+
+// Size counts the entries, it visits all of them
+func (m *SyncMap[K, V]) Size() int {
+	count := 0
+	m.data.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	return count
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+)
+
+func TestSyncMap(t *testing.T) {
+	m := &SyncMap[string, int]{}
+	if value, ok := m.Load("a"); ok || value != 0 {
+		t.Errorf("Expected a missing key, got %d, %v", value, ok)
+	}
+	m.Store("a", 1)
+	if actual, loaded := m.LoadOrStore("a", 2); !loaded || actual != 1 {
+		t.Errorf("Expected the existing value 1, got %d, %v", actual, loaded)
+	}
+	if actual, loaded := m.LoadOrStore("b", 2); loaded || actual != 2 {
+		t.Errorf("Expected 2 to be stored, got %d, %v", actual, loaded)
+	}
+	m.Store("c", 3)
+	if entries := maps.Collect(m.All()); !maps.Equal(entries, map[string]int{"a": 1, "b": 2, "c": 3}) {
+		t.Errorf("Unexpected entries %v", entries)
+	}
+	visited := 0
+	m.Range(func(string, int) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("Expected Range to stop after the first entry, visited %d", visited)
+	}
+	if value, loaded := m.LoadAndDelete("a"); !loaded || value != 1 {
+		t.Errorf("Expected to delete 1, got %d, %v", value, loaded)
+	}
+	if _, loaded := m.LoadAndDelete("a"); loaded {
+		t.Error("Expected a second LoadAndDelete to find nothing")
+	}
+	m.Delete("b")
+	if m.Size() != 1 {
+		t.Errorf("Expected 1 entry left, got %d", m.Size())
+	}
+	m.Clear()
+	if m.Size() != 0 {
+		t.Errorf("Expected no entries after Clear, got %d", m.Size())
+	}
+}
+
+// mutexMap is the plain alternative the benchmarks compare SyncMap with
+type mutexMap[K comparable, V any] struct {
+	mutex sync.RWMutex
+	data  map[K]V
+}
+
+// nil is a valid value of an interface type, sync.Map stores it as a nil any
+func TestSyncMapNilInterfaceValue(t *testing.T) {
+	m := &SyncMap[string, error]{}
+	m.Store("a", nil)
+	if value, ok := m.Load("a"); !ok || value != nil {
+		t.Errorf("Load = %v, %v", value, ok)
+	}
+	if actual, loaded := m.LoadOrStore("a", fmt.Errorf("other")); !loaded || actual != nil {
+		t.Errorf("LoadOrStore = %v, %v", actual, loaded)
+	}
+	m.Range(func(key string, value error) bool {
+		if key != "a" || value != nil {
+			t.Errorf("Range visited %q, %v", key, value)
+		}
+		return true
+	})
+	if value, loaded := m.LoadAndDelete("a"); !loaded || value != nil {
+		t.Errorf("LoadAndDelete = %v, %v", value, loaded)
+	}
+}
+
+func (m *mutexMap[K, V]) Load(key K) (V, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, ok := m.data[key]
+	return value, ok
+}
+
+func (m *mutexMap[K, V]) Store(key K, value V) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data[key] = value
+}
+
+type concurrentMap interface {
+	Load(key int) (int, bool)
+	Store(key, value int)
+}
+
+const benchmarkKeys = 1024
+
+// Every writesPerHundred of a hundred operations is a store, the rest are loads
+func benchmarkMap(b *testing.B, m concurrentMap, writesPerHundred int) {
+	for key := range benchmarkKeys {
+		m.Store(key, key)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if i%100 < writesPerHundred {
+				m.Store(i%benchmarkKeys, i)
+			} else {
+				m.Load(i % benchmarkKeys)
+			}
+		}
+	})
+}
+
+func BenchmarkMaps(b *testing.B) {
+	for _, writes := range []int{1, 50} {
+		b.Run(fmt.Sprintf("SyncMap/%d%%writes", writes), func(b *testing.B) {
+			benchmarkMap(b, &SyncMap[int, int]{}, writes)
+		})
+		b.Run(fmt.Sprintf("mutexMap/%d%%writes", writes), func(b *testing.B) {
+			benchmarkMap(b, &mutexMap[int, int]{data: map[int]int{}}, writes)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package common
+
+import "iter"
+
+// SyncSet is a set safe for concurrent use, backed by a SyncMap
+type SyncSet[T comparable] struct {
+	data SyncMap[T, struct{}]
+}
+
+// Add reports whether the element was added, false if it was already present
+func (s *SyncSet[T]) Add(element T) bool {
+	_, loaded := s.data.LoadOrStore(element, struct{}{})
+	return !loaded
+}
+
+// Remove reports whether the element was present
+func (s *SyncSet[T]) Remove(element T) bool {
+	_, loaded := s.data.LoadAndDelete(element)
+	return loaded
+}
+
+func (s *SyncSet[T]) Contains(element T) bool {
+	_, ok := s.data.Load(element)
+	return ok
+}
+
+func (s *SyncSet[T]) Clear() {
+	s.data.Clear()
+}
+
+func (s *SyncSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.data.Range(func(element T, _ struct{}) bool {
+			return yield(element)
+		})
+	}
+}
+
+// Size counts the elements, it visits all of them
+func (s *SyncSet[T]) Size() int {
+	return s.data.Size()
+}
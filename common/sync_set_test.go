@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSyncSet(t *testing.T) {
+	set := &SyncSet[string]{}
+	if !set.Add("a") || !set.Add("b") {
+		t.Error("Expected new elements to be added")
+	}
+	if set.Add("a") {
+		t.Error("Expected a duplicate not to be added")
+	}
+	if !set.Contains("a") || set.Contains("c") {
+		t.Error("Unexpected Contains result")
+	}
+	if elements := slices.Sorted(set.All()); !slices.Equal(elements, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", elements)
+	}
+	for range set.All() {
+		break
+	}
+	if !set.Remove("a") || set.Remove("a") {
+		t.Error("Expected only the first Remove to find the element")
+	}
+	if set.Size() != 1 {
+		t.Errorf("Expected 1 element, got %d", set.Size())
+	}
+	set.Clear()
+	if set.Size() != 0 {
+		t.Errorf("Expected no elements after Clear, got %d", set.Size())
+	}
+}
+
+func TestSyncSetAddsOnce(t *testing.T) {
+	set := &SyncSet[int]{}
+	var added sync.WaitGroup
+	results := make(chan bool, 8)
+	for range 8 {
+		added.Add(1)
+		go func() {
+			defer added.Done()
+			results <- set.Add(1)
+		}()
+	}
+	added.Wait()
+	close(results)
+	count := 0
+	for result := range results {
+		if result {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected exactly one goroutine to add the element, got %d", count)
+	}
+}
+
+// mutexSet is the plain alternative the benchmarks compare SyncSet with
+type mutexSet[T comparable] struct {
+	mutex sync.RWMutex
+	data  map[T]struct{}
+}
+
+func (s *mutexSet[T]) Add(element T) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.data[element]; ok {
+		return false
+	}
+	s.data[element] = struct{}{}
+	return true
+}
+
+func (s *mutexSet[T]) Contains(element T) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.data[element]
+	return ok
+}
+
+type concurrentSet interface {
+	Add(element int) bool
+	Contains(element int) bool
+}
+
+func benchmarkSet(b *testing.B, set concurrentSet, writesPerHundred int) {
+	for element := range benchmarkKeys {
+		set.Add(element)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if i%100 < writesPerHundred {
+				set.Add(benchmarkKeys + i)
+			} else {
+				set.Contains(i % benchmarkKeys)
+			}
+		}
+	})
+}
+
+func BenchmarkSets(b *testing.B) {
+	for _, writes := range []int{1, 50} {
+		b.Run(fmt.Sprintf("SyncSet/%d%%writes", writes), func(b *testing.B) {
+			benchmarkSet(b, &SyncSet[int]{}, writes)
+		})
+		b.Run(fmt.Sprintf("mutexSet/%d%%writes", writes), func(b *testing.B) {
+			benchmarkSet(b, &mutexSet[int]{data: map[int]struct{}{}}, writes)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package common
+
+import "sync"
+
+// SyncStack is a Stack safe for concurrent use
+type SyncStack[T any] struct {
+	mutex sync.Mutex
+	stack Stack[T]
+}
+
+func (s *SyncStack[T]) Push(element T) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stack.Push(element)
+}
+
+func (s *SyncStack[T]) Pop() (T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stack.Pop()
+}
+
+func (s *SyncStack[T]) Peek() (T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stack.Peek()
+}
+
+func (s *SyncStack[T]) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stack.Clear()
+}
+
+// Elements returns a copy of the elements from the top to the bottom of the stack
+func (s *SyncStack[T]) Elements() []T {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	elements := make([]T, 0, s.stack.Size())
+	for element := range s.stack.All() {
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+func (s *SyncStack[T]) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stack.Size()
+}
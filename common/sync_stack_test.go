@@ -0,0 +1,57 @@
+package common
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSyncStack(t *testing.T) {
+	stack := &SyncStack[int]{}
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 100 {
+				stack.Push(i*100 + n)
+			}
+		}()
+	}
+	wg.Wait()
+	if stack.Size() != 400 {
+		t.Fatalf("Expected 400 elements, got %d", stack.Size())
+	}
+	top, _ := stack.Peek()
+	if elements := stack.Elements(); len(elements) != 400 || elements[0] != top {
+		t.Errorf("Expected the elements to start at the top %d, got %d elements", top, len(elements))
+	}
+
+	popped := make(chan int, 400)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for element, ok := stack.Pop(); ok; element, ok = stack.Pop() {
+				popped <- element
+			}
+		}()
+	}
+	wg.Wait()
+	close(popped)
+	elements := slices.Sorted(func(yield func(int) bool) {
+		for element := range popped {
+			yield(element)
+		}
+	})
+	for i, element := range elements {
+		if element != i {
+			t.Fatalf("Expected every element to be popped once, got %v", elements)
+		}
+	}
+	stack.Push(1)
+	stack.Clear()
+	if _, ok := stack.Peek(); ok {
+		t.Error("Expected an empty stack after Clear")
+	}
+}
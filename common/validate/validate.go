@@ -0,0 +1,374 @@
+// Package validate checks structs against rules given in their field tags
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidRule is returned for a tag the validator cannot interpret, it is
+// a mistake in the validated type rather than in the validated value
+var ErrInvalidRule = errors.New("invalid validation rule")
+
+// FieldError describes a field that breaks one of its rules. The message is
+// kept as a format and arguments so callers can translate it.
+type FieldError struct {
+	Field  string // Path of the field, e.g. Address.City or Items[2].Name
+	Rule   string
+	Format string
+	Args   []any
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf(e.Format, e.Args...)
+}
+
+// Errors aggregates all the broken rules of a value
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator checks structs against rules given in their field tags, e.g.
+// `validate:"required,len=1..20,regexp=^[a-z]+$"`. The rules are:
+//
+//	required        the value is not zero, strings and collections are not empty
+//	range=min..max  numbers are within the bounds
+//	len=min..max    length of strings (in bytes) and collections is within the bounds
+//	regexp=pattern  strings match the pattern, it takes the rest of the tag so may contain commas
+//	each            the rules after it apply to the elements, and for maps to the keys as well
+//
+// Either bound may be left out, a single number means an exact length and a
+// bound may name a limit set with WithLimit. Nested structs, pointers to
+// them and their slices and maps are validated as well. Limits and names
+// are set up before the first validation, as the parsed tags are cached.
+type Validator struct {
+	tag    string
+	names  string
+	limits map[string]float64
+	types  sync.Map // reflect.Type to *structRules
+}
+
+// New creates a validator reading the rules from the given tag
+func New(tag string) *Validator {
+	return &Validator{tag: tag, limits: make(map[string]float64)}
+}
+
+// WithLimit names a bound, so tags can refer to constants of the program
+func (v *Validator) WithLimit(name string, value float64) *Validator {
+	v.limits[name] = value
+	return v
+}
+
+// NameBy makes errors name fields by another tag, e.g. json, instead of the Go name
+func (v *Validator) NameBy(tag string) *Validator {
+	v.names = tag
+	return v
+}
+
+type rule struct {
+	name       string
+	min, max   *float64
+	pattern    *regexp.Regexp
+	collection string // What the length counts: characters, bytes or entries
+}
+
+type fieldRules struct {
+	index    int
+	name     string
+	rules    []rule
+	elements []rule
+	nested   bool
+}
+
+type structRules struct {
+	fields []fieldRules
+}
+
+// Validate checks value, a struct or a pointer to one, and returns
+// Errors listing every broken rule
+func (v *Validator) Validate(value any) error {
+	var errs Errors
+	if err := v.validate(reflect.ValueOf(value), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) validate(value reflect.Value, path string, errs *Errors) error {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		rules, err := v.rulesOf(value.Type())
+		if err != nil {
+			return err
+		}
+		for _, field := range rules.fields {
+			fieldValue := value.Field(field.index)
+			fieldPath := joinPath(path, field.name)
+			if !check(fieldValue, fieldPath, field.rules, errs) {
+				continue
+			}
+			checkElements(fieldValue, fieldPath, field.elements, errs)
+			if !field.nested {
+				continue
+			}
+			if err := v.validate(fieldValue, fieldPath, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := v.validate(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for iter := value.MapRange(); iter.Next(); {
+			if err := v.validate(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkElements(value reflect.Value, path string, rules []rule, errs *Errors) {
+	if len(rules) == 0 {
+		return
+	}
+	if value.Kind() == reflect.Map {
+		for iter := value.MapRange(); iter.Next(); {
+			elementPath := fmt.Sprintf("%s[%v]", path, iter.Key())
+			check(iter.Key(), elementPath, rules, errs)
+			check(iter.Value(), elementPath, rules, errs)
+		}
+		return
+	}
+	for i := 0; i < value.Len(); i++ {
+		check(value.Index(i), fmt.Sprintf("%s[%d]", path, i), rules, errs)
+	}
+}
+
+// check applies rules to a value and reports whether it kept all of them
+func check(value reflect.Value, path string, rules []rule, errs *Errors) bool {
+	valid := true
+	fail := func(rule, format string, args ...any) {
+		*errs = append(*errs, FieldError{path, rule, format, append([]any{path}, args...)})
+		valid = false
+	}
+	for _, rule := range rules {
+		switch rule.name {
+		case "required":
+			if isEmpty(value) {
+				fail(rule.name, "field %s is required")
+				return false
+			}
+		case "range":
+			number := numberOf(value)
+			if rule.min != nil && number < *rule.min || rule.max != nil && number > *rule.max {
+				fail(rule.name, "field %s must be between %v and %v", bound(rule.min, "-∞"), bound(rule.max, "∞"))
+			}
+		case "len":
+			length := float64(value.Len())
+			if rule.max != nil && length > *rule.max {
+				fail(rule.name, "field %s exceeds %v "+rule.collection, bound(rule.max, ""))
+			} else if rule.min != nil && length < *rule.min {
+				fail(rule.name, "field %s needs at least %v "+rule.collection, bound(rule.min, ""))
+			}
+		case "regexp":
+			if value.Len() > 0 && !rule.pattern.MatchString(value.String()) {
+				fail(rule.name, "field %s does not match %s", rule.pattern)
+			}
+		}
+	}
+	return valid
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func numberOf(value reflect.Value) float64 {
+	switch {
+	case value.CanInt():
+		return float64(value.Int())
+	case value.CanUint():
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
+
+// bound formats a rule bound without an exponent, so large limits stay readable
+func bound(value *float64, infinity string) string {
+	if value == nil {
+		return infinity
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// rulesOf parses the tags of a struct type once and keeps them for later values
+func (v *Validator) rulesOf(structType reflect.Type) (*structRules, error) {
+	if cached, ok := v.types.Load(structType); ok {
+		return cached.(*structRules), nil
+	}
+	parsed := &structRules{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if v.names != "" {
+			if tagName, _, _ := strings.Cut(field.Tag.Get(v.names), ","); tagName != "" && tagName != "-" {
+				name = tagName
+			}
+		}
+		rules, elements, err := v.parse(field.Type, field.Tag.Get(v.tag))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrInvalidRule, structType.Name(), field.Name, err)
+		}
+		nested := mayNest(field.Type)
+		if len(rules) > 0 || len(elements) > 0 || nested {
+			parsed.fields = append(parsed.fields, fieldRules{i, name, rules, elements, nested})
+		}
+	}
+	v.types.Store(structType, parsed)
+	return parsed, nil
+}
+
+// mayNest tells whether values of a type may hold structs worth validating
+func mayNest(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return mayNest(fieldType.Elem())
+	case reflect.Struct, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+func (v *Validator) parse(fieldType reflect.Type, tag string) (rules, elements []rule, err error) {
+	target := &rules
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+		var term string
+		if strings.HasPrefix(tag, "regexp=") {
+			term, tag = tag, ""
+		} else {
+			term, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, _ := strings.Cut(term, "=")
+		name = strings.TrimSpace(name)
+		if name == "each" {
+			switch fieldType.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return nil, nil, fmt.Errorf("each on %s", fieldType)
+			}
+			if target == &elements {
+				return nil, nil, errors.New("each used twice")
+			}
+			fieldType, target = fieldType.Elem(), &elements
+			continue
+		}
+		parsed, err := v.parseRule(fieldType, name, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		*target = append(*target, parsed)
+	}
+	return rules, elements, nil
+}
+
+func (v *Validator) parseRule(fieldType reflect.Type, name, arg string) (rule, error) {
+	parsed := rule{name: name}
+	kind := fieldType.Kind()
+	switch name {
+	case "required":
+		return parsed, nil
+	case "range":
+		if kind < reflect.Int || kind > reflect.Float64 || kind == reflect.Uintptr {
+			return parsed, fmt.Errorf("range on %s", fieldType)
+		}
+	case "len":
+		switch {
+		case kind == reflect.String:
+			parsed.collection = "characters"
+		case kind == reflect.Slice && fieldType.Elem().Kind() == reflect.Uint8:
+			parsed.collection = "bytes"
+		case kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map:
+			parsed.collection = "entries"
+		default:
+			return parsed, fmt.Errorf("len on %s", fieldType)
+		}
+		if !strings.Contains(arg, "..") {
+			arg = arg + ".." + arg
+		}
+	case "regexp":
+		if kind != reflect.String {
+			return parsed, fmt.Errorf("regexp on %s", fieldType)
+		}
+		pattern, err := regexp.Compile(arg)
+		parsed.pattern = pattern
+		return parsed, err
+	default:
+		return parsed, fmt.Errorf("unknown rule %q", name)
+	}
+
+	low, high, ok := strings.Cut(arg, "..")
+	if !ok {
+		return parsed, fmt.Errorf("%s needs min..max, got %q", name, arg)
+	}
+	var err error
+	if parsed.min, err = v.parseBound(low); err != nil {
+		return parsed, err
+	}
+	parsed.max, err = v.parseBound(high)
+	return parsed, err
+}
+
+func (v *Validator) parseBound(text string) (*float64, error) {
+	if text = strings.TrimSpace(text); text == "" {
+		return nil, nil
+	}
+	if limit, ok := v.limits[text]; ok {
+		return &limit, nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown bound %q", text)
+	}
+	return &value, nil
+}
@@ -0,0 +1,129 @@
+package validate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type Person struct {
+	Name    string   `validate:"required,len=..40"`
+	Age     int      `validate:"range=0..150"`
+	Address *Address `validate:"required"`
+	Tags    []string `validate:"len=..3,each,len=1..10"`
+}
+
+type Address struct {
+	City   string `validate:"required"`
+	Postal string `validate:"regexp=^[0-9]{2}-[0-9]{3}$"`
+}
+
+type Order struct {
+	Id       string            `json:"id" validate:"required,len=8"`
+	Quantity uint              `json:"quantity" validate:"range=1..MaxQuantity"`
+	Discount float64           `json:"discount" validate:"range=0..0.5"`
+	Notes    map[string]string `json:"notes" validate:"len=..2,each,len=..5"`
+	Code     string            `json:"code" validate:"regexp=^[A-Z]{2,3}$"`
+	Buyer    Person            `json:"buyer"`
+	Items    []Address         `json:"items"`
+}
+
+func failedFields(t *testing.T, err error) []string {
+	t.Helper()
+	var errs Errors
+	if err == nil {
+		return nil
+	}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected Errors, got %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range errs {
+		fields = append(fields, fieldErr.Field+":"+fieldErr.Rule)
+	}
+	return fields
+}
+
+func TestValidate(t *testing.T) {
+	validator := New("validate").WithLimit("MaxQuantity", 10)
+	valid := func() Order {
+		return Order{
+			Id:       "ord-0001",
+			Quantity: 2,
+			Notes:    map[string]string{"gift": "yes"},
+			Code:     "PLN",
+			Buyer:    Person{Name: "Jan", Age: 30, Address: &Address{City: "Kraków"}},
+			Items:    []Address{{City: "Gdańsk", Postal: "80-001"}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		change   func(order *Order)
+		expected []string
+	}{
+		{"Valid", func(order *Order) {}, nil},
+		{"Required", func(order *Order) { order.Id = "" }, []string{"Id:required"}},
+		{"Exact length", func(order *Order) { order.Id = "ord-1" }, []string{"Id:len"}},
+		{"Named limit", func(order *Order) { order.Quantity = 11 }, []string{"Quantity:range"}},
+		{"Float range", func(order *Order) { order.Discount = 0.75 }, []string{"Discount:range"}},
+		{"Map entries", func(order *Order) { order.Notes["a"], order.Notes["b"] = "1", "2" }, []string{"Notes:len"}},
+		{"Map keys and values", func(order *Order) { order.Notes = map[string]string{"message": "hello!"} }, []string{"Notes[message]:len", "Notes[message]:len"}},
+		{"Pattern with a comma", func(order *Order) { order.Code = "PLNX" }, []string{"Code:regexp"}},
+		{"Nested struct", func(order *Order) { order.Buyer.Age = 151 }, []string{"Buyer.Age:range"}},
+		{"Nested pointer", func(order *Order) { order.Buyer.Address.City = "" }, []string{"Buyer.Address.City:required"}},
+		{"Slice elements", func(order *Order) { order.Items = append(order.Items, Address{Postal: "x"}) }, []string{"Items[1].City:required", "Items[1].Postal:regexp"}},
+		{"Slice element rules", func(order *Order) { order.Buyer.Tags = []string{"go", ""} }, []string{"Buyer.Tags[1]:len"}},
+		{"All errors", func(order *Order) { order.Id, order.Buyer.Name = "", "" }, []string{"Id:required", "Buyer.Name:required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := valid()
+			tt.change(&order)
+			if fields := failedFields(t, validator.Validate(&order)); !slices.Equal(fields, tt.expected) {
+				t.Errorf("Validate() failed %v; want %v", fields, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateNamesFieldsByTag(t *testing.T) {
+	validator := New("validate").NameBy("json").WithLimit("MaxQuantity", 10)
+	err := validator.Validate(Order{Id: "ord-0001", Quantity: 1, Buyer: Person{Age: -1}})
+	if fields := failedFields(t, err); !slices.Equal(fields, []string{"buyer.Name:required", "buyer.Age:range", "buyer.Address:required"}) {
+		t.Errorf("Validate() failed %v", fields)
+	}
+	if message := err.Error(); message != "field buyer.Name is required; field buyer.Age must be between 0 and 150; field buyer.Address is required" {
+		t.Errorf("Error() = %q", message)
+	}
+}
+
+func TestValidateRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"Unknown rule", struct {
+			Name string `validate:"unique"`
+		}{}},
+		{"Range on a string", struct {
+			Name string `validate:"range=1..2"`
+		}{}},
+		{"Unknown limit", struct {
+			Age int `validate:"range=0..MaxAge"`
+		}{}},
+		{"Each on a string", struct {
+			Name string `validate:"each,len=1"`
+		}{}},
+		{"Invalid pattern", struct {
+			Name string `validate:"regexp=[a-"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := New("validate").Validate(tt.value); !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Validate() = %v; want ErrInvalidRule", err)
+			}
+		})
+	}
+}
@@ -1,171 +1,319 @@
 package concurrency
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
 	"time"
+
+	"training.pl/go/common"
 )
 
-type Barber struct {
-	id int
+// Clock tells and waits for time, so a simulation can run on a faster or fake one
+type Clock interface {
+	Now() time.Time
+	After(duration time.Duration) <-chan time.Time
+	Sleep(duration time.Duration)
 }
 
-type Client struct {
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) After(duration time.Duration) <-chan time.Time { return time.After(duration) }
+
+func (SystemClock) Sleep(duration time.Duration) { time.Sleep(duration) }
+
+// Observer is told what happens in the barber shop
+type Observer interface {
+	ShopOpened(barbers, seats int)
+	ClientArrived(client int, appointment bool)
+	ClientSeated(client, occupied, seats int)
+	ClientTurnedAway(client int)
+	HaircutStarted(barber, client, occupied, seats int)
+	HaircutFinished(barber, client int)
+	ShopClosing()
+	ShopClosed(stats BarberShopStats)
+}
+
+// NopObserver ignores all events, embed it to observe only some of them
+type NopObserver struct{}
+
+func (NopObserver) ShopOpened(barbers, seats int)                      {}
+func (NopObserver) ClientArrived(client int, appointment bool)         {}
+func (NopObserver) ClientSeated(client, occupied, seats int)           {}
+func (NopObserver) ClientTurnedAway(client int)                        {}
+func (NopObserver) HaircutStarted(barber, client, occupied, seats int) {}
+func (NopObserver) HaircutFinished(barber, client int)                 {}
+func (NopObserver) ShopClosing()                                       {}
+func (NopObserver) ShopClosed(stats BarberShopStats)                   {}
+
+// PrintObserver prints the events to standard output
+type PrintObserver struct{}
+
+func (PrintObserver) ShopOpened(barbers, seats int) {
+	fmt.Println("Barber shop is opening!")
+	fmt.Printf("Shop configuration: %d barbers, %d waiting room seats\n", barbers, seats)
+}
+
+func (PrintObserver) ClientArrived(client int, appointment bool) {
+	if appointment {
+		fmt.Printf("Client %d: Arriving at shop with an appointment\n", client)
+	} else {
+		fmt.Printf("Client %d: Arriving at shop\n", client)
+	}
+}
+
+func (PrintObserver) ClientSeated(client, occupied, seats int) {
+	fmt.Printf("Client %d: Entered waiting room (seats occupied: %d/%d)\n", client, occupied, seats)
+}
+
+func (PrintObserver) ClientTurnedAway(client int) {
+	fmt.Printf("Client %d: Waiting room full, leaving\n", client)
+}
+
+func (PrintObserver) HaircutStarted(barber, client, occupied, seats int) {
+	fmt.Printf("Barber %d: Cutting hair for client %d (waiting room: %d/%d)\n", barber, client, occupied, seats)
+}
+
+func (PrintObserver) HaircutFinished(barber, client int) {
+	fmt.Printf("Barber %d: Finished cutting hair for client %d\n", barber, client)
+}
+
+func (PrintObserver) ShopClosing() {
+	fmt.Println("\nBarber shop is closing! No new clients accepted.")
+}
+
+func (PrintObserver) ShopClosed(stats BarberShopStats) {
+	fmt.Println("\nAll barbers have gone home. Shop is closed!")
+	fmt.Printf("Served: %d, turned away: %d, average wait: %v\n", stats.Served, stats.TurnedAway, stats.AverageWait.Round(time.Millisecond))
+	fmt.Printf("With an appointment - %v\n", stats.Appointments)
+	fmt.Printf("Walk-in             - %v\n", stats.WalkIns)
+	for barber, served := range stats.ServedByBarber {
+		fmt.Printf("Barber %d served %d clients\n", barber+1, served)
+	}
+}
+
+// ClientStats summarize how a group of clients was treated
+type ClientStats struct {
+	Served      int
+	TurnedAway  int
+	AverageWait time.Duration
+	MaxWait     time.Duration
+}
+
+func (s ClientStats) String() string {
+	return fmt.Sprintf("served: %d, turned away: %d, average wait: %v, longest wait: %v", s.Served, s.TurnedAway, s.AverageWait.Round(time.Millisecond), s.MaxWait.Round(time.Millisecond))
+}
+
+// clientTally collects the ClientStats while the shop is open
+type clientTally struct {
+	served     int
+	turnedAway int
+	totalWait  time.Duration
+	maxWait    time.Duration
+}
+
+func (t *clientTally) serve(wait time.Duration) {
+	t.served++
+	t.totalWait += wait
+	t.maxWait = max(t.maxWait, wait)
+}
+
+func (t clientTally) merge(other clientTally) clientTally {
+	return clientTally{t.served + other.served, t.turnedAway + other.turnedAway, t.totalWait + other.totalWait, max(t.maxWait, other.maxWait)}
+}
+
+func (t clientTally) stats() ClientStats {
+	stats := ClientStats{Served: t.served, TurnedAway: t.turnedAway, MaxWait: t.maxWait}
+	if t.served > 0 {
+		stats.AverageWait = t.totalWait / time.Duration(t.served)
+	}
+	return stats
+}
+
+type BarberShopStats struct {
+	Served         int
+	TurnedAway     int
+	AverageWait    time.Duration // Time in the waiting room of the served clients
+	Appointments   ClientStats
+	WalkIns        ClientStats
+	ServedByBarber []int // Indexed by barber id - 1
+}
+
+type Barber struct {
 	id int
 }
 
-type WaitingRoomRequest struct {
-	action   string // "enter", "leave", "check"
-	response chan int
+type Client struct {
+	id          int
+	appointment bool // Clients with an appointment are served before walk-ins
+	arrived     time.Time
 }
 
 type BarberShop struct {
 	numBarbers        int
 	waitingRoomSize   int
-	clientsChan       chan *Client
-	waitingRoomChan   chan WaitingRoomRequest
+	barbers           *Pool
+	waitingRoom       *WeightedSemaphore // A unit per seat
 	shopOpenDuration  time.Duration
 	haircutDuration   time.Duration
+	barberDurations   []time.Duration // Haircut duration of each barber, haircutDuration if not set
+	appointmentShare  float64         // Of the arriving clients
 	clientArrivalRate time.Duration
-	wg                sync.WaitGroup
 	rand              *rand.Rand
+	clock             Clock
+	observer          Observer
+	lock              sync.Mutex // Guards the fields below
+	waiting           *common.PriorityQueue[*Client]
+	appointments      clientTally
+	walkIns           clientTally
+	servedByBarber    []int
 }
 
 func NewBarberShop(numBarbers, waitingRoomSize int, shopOpenDuration, haircutDuration, clientArrivalRate time.Duration) *BarberShop {
 	return &BarberShop{
 		numBarbers:        numBarbers,
 		waitingRoomSize:   waitingRoomSize,
-		clientsChan:       make(chan *Client, waitingRoomSize),
-		waitingRoomChan:   make(chan WaitingRoomRequest),
+		waitingRoom:       NewWeightedSemaphore(int64(waitingRoomSize)),
 		shopOpenDuration:  shopOpenDuration,
 		haircutDuration:   haircutDuration,
 		clientArrivalRate: clientArrivalRate,
 		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		clock:             SystemClock{},
+		observer:          PrintObserver{},
 	}
 }
 
-func (bs *BarberShop) waitingRoomManager() {
-	currentSize := 0
-	for req := range bs.waitingRoomChan {
-		switch req.action {
-		case "enter":
-			if currentSize < bs.waitingRoomSize {
-				currentSize++
-				req.response <- currentSize
-			} else {
-				req.response <- -1 // Room full
-			}
-		case "leave":
-			if currentSize > 0 {
-				currentSize--
-			}
-			req.response <- currentSize
-		case "check":
-			req.response <- currentSize
-		}
-	}
+// WithClock replaces the clock measuring all durations of the shop
+func (bs *BarberShop) WithClock(clock Clock) *BarberShop {
+	bs.clock = clock
+	return bs
 }
 
-func (bs *BarberShop) barber(id int) {
-	defer bs.wg.Done()
-	barber := &Barber{id: id}
-	fmt.Printf("Barber %d: Ready to work\n", id)
+// WithObserver replaces the observer, by default the events are printed
+func (bs *BarberShop) WithObserver(observer Observer) *BarberShop {
+	bs.observer = observer
+	return bs
+}
 
-	for client := range bs.clientsChan {
-		// Notify waiting room manager that a client is leaving
-		req := WaitingRoomRequest{
-			action:   "leave",
-			response: make(chan int),
-		}
-		bs.waitingRoomChan <- req
-		currentSize := <-req.response
+// WithBarberDurations gives every barber their own haircut duration, replacing the
+// number of barbers with the number of durations
+func (bs *BarberShop) WithBarberDurations(durations ...time.Duration) *BarberShop {
+	bs.numBarbers = len(durations)
+	bs.barberDurations = durations
+	return bs
+}
 
-		fmt.Printf("Barber %d: Cutting hair for client %d (waiting room: %d/%d)\n", barber.id, client.id, currentSize, bs.waitingRoomSize)
-		time.Sleep(bs.haircutDuration)
-		fmt.Printf("Barber %d: Finished cutting hair for client %d\n", barber.id, client.id)
-	}
+// WithAppointments lets the given share of clients come with an appointment,
+// they jump the queue of walk-ins
+func (bs *BarberShop) WithAppointments(share float64) *BarberShop {
+	bs.appointmentShare = share
+	return bs
+}
 
-	fmt.Printf("Barber %d: Going home\n", barber.id)
+func (bs *BarberShop) haircutDurationOf(barber *Barber) time.Duration {
+	if barber.id <= len(bs.barberDurations) {
+		return bs.barberDurations[barber.id-1]
+	}
+	return bs.haircutDuration
 }
 
-func (bs *BarberShop) addClient(id int) {
-	client := &Client{id: id}
+// haircut is the task a free barber takes from the waiting room. The barber serves
+// whoever is first in the queue, not necessarily the client the task was submitted for.
+func (bs *BarberShop) haircut(ctx context.Context) {
+	barber := &Barber{id: WorkerId(ctx)}
+
+	// The client leaves the waiting room
+	bs.lock.Lock()
+	client, _ := bs.waiting.Pop()
+	bs.tally(client).serve(bs.clock.Now().Sub(client.arrived))
+	bs.servedByBarber[barber.id-1]++
+	bs.lock.Unlock()
+	bs.waitingRoom.Release(1)
+
+	bs.observer.HaircutStarted(barber.id, client.id, int(bs.waitingRoom.Used()), bs.waitingRoomSize)
+	bs.clock.Sleep(bs.haircutDurationOf(barber))
+	bs.observer.HaircutFinished(barber.id, client.id)
+}
 
-	// Request to enter the waiting room
-	req := WaitingRoomRequest{
-		action:   "enter",
-		response: make(chan int),
+func (bs *BarberShop) tally(client *Client) *clientTally {
+	if client.appointment {
+		return &bs.appointments
 	}
-	bs.waitingRoomChan <- req
-	currentSize := <-req.response
+	return &bs.walkIns
+}
 
-	if currentSize == -1 {
-		fmt.Printf("Client %d: Waiting room full, leaving\n", id)
+func (bs *BarberShop) addClient(client *Client) {
+	// Take a seat in the waiting room, if there is none the client doesn't wait
+	if !bs.waitingRoom.TryAcquire(1) {
+		bs.lock.Lock()
+		bs.tally(client).turnedAway++
+		bs.lock.Unlock()
+		bs.observer.ClientTurnedAway(client.id)
 		return
 	}
 
-	select {
-	case bs.clientsChan <- client:
-		fmt.Printf("Client %d: Entered waiting room (seats occupied: %d/%d)\n", id, currentSize, bs.waitingRoomSize)
-	default:
-		// This shouldn't happen since we already checked, but handle it gracefully
-		// Request to leave since we couldn't actually enter
-		leaveReq := WaitingRoomRequest{
-			action:   "leave",
-			response: make(chan int),
-		}
-		bs.waitingRoomChan <- leaveReq
-		<-leaveReq.response
-		fmt.Printf("Client %d: Waiting room full, leaving\n", id)
-	}
+	bs.lock.Lock()
+	bs.waiting.Push(client)
+	bs.lock.Unlock()
+	bs.observer.ClientSeated(client.id, int(bs.waitingRoom.Used()), bs.waitingRoomSize)
+	// One haircut per seated client, a seat is held until a barber takes a client, so the queue has room
+	bs.barbers.Submit(bs.haircut)
 }
 
-func (bs *BarberShop) Start() {
-	fmt.Println("Barber shop is opening!")
-	fmt.Printf("Shop configuration: %d barbers, %d waiting room seats\n", bs.numBarbers, bs.waitingRoomSize)
+// Start lets clients in until the shop closes and returns once the waiting ones are served
+func (bs *BarberShop) Start() BarberShopStats {
+	bs.observer.ShopOpened(bs.numBarbers, bs.waitingRoomSize)
 
-	// Start the waiting room manager
-	go bs.waitingRoomManager()
+	bs.barbers = NewPool(bs.numBarbers, bs.waitingRoomSize)
+	bs.waiting = common.NewPriorityQueue(func(a, b *Client) bool { return a.appointment && !b.appointment })
+	bs.appointments = clientTally{}
+	bs.walkIns = clientTally{}
+	bs.servedByBarber = make([]int, bs.numBarbers)
 
-	for i := 1; i <= bs.numBarbers; i++ {
-		bs.wg.Add(1)
-		go bs.barber(i)
-	}
-
-	closingTimer := time.After(bs.shopOpenDuration)
+	closingTimer := bs.clock.After(bs.shopOpenDuration)
 	clientID := 1
 
-	clientTicker := time.NewTicker(bs.clientArrivalRate)
-	defer clientTicker.Stop()
+	arrival := bs.clock.After(bs.clientArrivalRate)
 
 	shopOpen := true
 
 	for shopOpen {
 		select {
-		case <-clientTicker.C:
-			variation := time.Duration(bs.rand.Intn(int(bs.clientArrivalRate / 2)))
-			if variation > 0 {
-				time.Sleep(variation)
+		case <-arrival:
+			arrival = bs.clock.After(bs.clientArrivalRate)
+			if maxVariation := int64(bs.clientArrivalRate / 2); maxVariation > 0 {
+				bs.clock.Sleep(time.Duration(bs.rand.Int63n(maxVariation)))
 			}
 
-			fmt.Printf("Client %d: Arriving at shop\n", clientID)
-			bs.addClient(clientID)
+			client := &Client{id: clientID, appointment: bs.rand.Float64() < bs.appointmentShare, arrived: bs.clock.Now()}
+			bs.observer.ClientArrived(client.id, client.appointment)
+			bs.addClient(client)
 			clientID++
 
 		case <-closingTimer:
-			fmt.Println("\nBarber shop is closing! No new clients accepted.")
+			bs.observer.ShopClosing()
 			shopOpen = false
 		}
 	}
 
-	close(bs.clientsChan)
-	bs.wg.Wait()
-
-	// Close the waiting room channel
-	close(bs.waitingRoomChan)
+	// Clients already waiting still get their haircut
+	bs.barbers.Shutdown(context.Background())
 
-	fmt.Println("\nAll barbers have gone home. Shop is closed!")
+	all := bs.appointments.merge(bs.walkIns).stats()
+	stats := BarberShopStats{
+		Served:         all.Served,
+		TurnedAway:     all.TurnedAway,
+		AverageWait:    all.AverageWait,
+		Appointments:   bs.appointments.stats(),
+		WalkIns:        bs.walkIns.stats(),
+		ServedByBarber: bs.servedByBarber,
+	}
+	bs.observer.ShopClosed(stats)
+	return stats
 }
 
 func Barbers() {
@@ -175,6 +323,8 @@ func Barbers() {
 	haircutDuration := 10 * time.Second
 	clientArrivalRate := 1 * time.Second
 
-	shop := NewBarberShop(numBarbers, waitingRoomSize, shopOpenDuration, haircutDuration, clientArrivalRate)
+	shop := NewBarberShop(numBarbers, waitingRoomSize, shopOpenDuration, haircutDuration, clientArrivalRate).
+		WithBarberDurations(8*time.Second, 12*time.Second).
+		WithAppointments(0.2)
 	shop.Start()
 }
@@ -0,0 +1,128 @@
+package concurrency
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scaledClock runs factor times faster than the wall clock
+type scaledClock struct {
+	start  time.Time
+	factor time.Duration
+}
+
+func (c scaledClock) Now() time.Time {
+	return c.start.Add(time.Since(c.start) * c.factor)
+}
+
+func (c scaledClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration / c.factor)
+}
+
+func (c scaledClock) Sleep(duration time.Duration) {
+	time.Sleep(duration / c.factor)
+}
+
+type recordingObserver struct {
+	NopObserver
+	lock       sync.Mutex
+	arrived    int
+	turnedAway int
+	finished   int
+	closed     *BarberShopStats
+}
+
+func (o *recordingObserver) ClientArrived(client int, appointment bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.arrived++
+}
+
+func (o *recordingObserver) ClientTurnedAway(client int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.turnedAway++
+}
+
+func (o *recordingObserver) HaircutFinished(barber, client int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.finished++
+}
+
+func (o *recordingObserver) ShopClosed(stats BarberShopStats) {
+	o.closed = &stats
+}
+
+func TestBarberShopStats(t *testing.T) {
+	observer := &recordingObserver{}
+	clock := scaledClock{start: time.Now(), factor: 1000}
+	stats := NewBarberShop(1, 2, 30*time.Second, 5*time.Second, time.Second).
+		WithClock(clock).
+		WithObserver(observer).
+		Start()
+
+	if stats.Served == 0 || stats.TurnedAway == 0 {
+		t.Errorf("Expected a busy barber to serve some clients and turn others away, got %+v", stats)
+	}
+	if stats.Served+stats.TurnedAway != observer.arrived {
+		t.Errorf("Expected every one of %d clients served or turned away, got %+v", observer.arrived, stats)
+	}
+	if stats.Served != observer.finished || stats.TurnedAway != observer.turnedAway {
+		t.Errorf("Expected the stats to match the events, got %+v, observed %d served and %d turned away", stats, observer.finished, observer.turnedAway)
+	}
+	if stats.AverageWait < time.Second {
+		t.Errorf("Expected clients to wait behind 5 second haircuts, average wait %v", stats.AverageWait)
+	}
+	if observer.closed == nil || !reflect.DeepEqual(*observer.closed, stats) {
+		t.Errorf("Expected the observer to get the returned stats, got %v", observer.closed)
+	}
+}
+
+func TestBarberShopServesEveryoneWithoutQueue(t *testing.T) {
+	clock := scaledClock{start: time.Now(), factor: 1000}
+	stats := NewBarberShop(3, 3, 10*time.Second, time.Second, 2*time.Second).
+		WithClock(clock).
+		WithObserver(NopObserver{}).
+		Start()
+
+	if stats.Served == 0 || stats.TurnedAway != 0 {
+		t.Errorf("Expected free barbers to serve every client, got %+v", stats)
+	}
+}
+
+func TestBarberShopAppointmentsJumpTheQueue(t *testing.T) {
+	clock := scaledClock{start: time.Now(), factor: 1000}
+	// The barbers can't keep up, so the waiting room stays full
+	stats := NewBarberShop(2, 6, 60*time.Second, 0, time.Second).
+		WithBarberDurations(3*time.Second, 6*time.Second).
+		WithAppointments(0.3).
+		WithClock(clock).
+		WithObserver(NopObserver{}).
+		Start()
+
+	if stats.Appointments.Served == 0 || stats.WalkIns.Served == 0 {
+		t.Fatalf("Expected both kinds of clients served, got %+v", stats)
+	}
+	if stats.Appointments.AverageWait >= stats.WalkIns.AverageWait {
+		t.Errorf("Expected appointments to wait less than walk-ins, got %v and %v", stats.Appointments.AverageWait, stats.WalkIns.AverageWait)
+	}
+	if stats.Served != stats.Appointments.Served+stats.WalkIns.Served || stats.ServedByBarber[0]+stats.ServedByBarber[1] != stats.Served {
+		t.Errorf("Expected the totals to add up, got %+v", stats)
+	}
+	if stats.ServedByBarber[0] <= stats.ServedByBarber[1] {
+		t.Errorf("Expected the faster barber to serve more clients, got %v", stats.ServedByBarber)
+	}
+}
+
+func BenchmarkBarberShop(b *testing.B) {
+	clock := scaledClock{start: time.Now(), factor: 10000}
+	for i := 0; i < b.N; i++ {
+		NewBarberShop(2, 5, 30*time.Second, 10*time.Second, time.Second).
+			WithClock(clock).
+			WithObserver(NopObserver{}).
+			Start()
+	}
+}
@@ -1,70 +1,77 @@
 package concurrency
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
+// FindFiles runs every stage in a group, when one fails the others stop as well
 func FindFiles() {
+	group, ctx := NewGroup(context.Background())
 	files := make(chan string, 10)
 	filesWithExtension := make(chan string)
 	filesWithContent := make(chan string)
 
-	go findFiles(".\\common", files)
-	go findFiles(".\\concurrency", files)
-
-	go filterByExtension(files, filesWithExtension, ".go")
-	go filterByContent(filesWithExtension, filesWithContent, "package concurrency")
-
-	done := false
-	for !done {
-		select {
-		case file := <-filesWithContent:
-			fmt.Println(file)
-		case <-time.After(1 * time.Second):
-			done = true
+	group.Go(func() error {
+		defer close(files)
+		var walkers Group
+		for _, root := range []string{"common", "concurrency"} {
+			walkers.Go(func() error { return findFiles(ctx, root, files) })
 		}
-	}
+		return walkers.Wait()
+	})
+	group.Go(func() error {
+		defer close(filesWithExtension)
+		return filterByExtension(ctx, files, filesWithExtension, ".go")
+	})
+	group.Go(func() error {
+		defer close(filesWithContent)
+		return filterByContent(ctx, filesWithExtension, filesWithContent, "package concurrency")
+	})
 
-	close(files)
-	close(filesWithExtension)
-	close(filesWithContent)
+	// Ends when the last stage closes its output, after all files or an error
+	for file := range filesWithContent {
+		fmt.Println(file)
+	}
+	if err := group.Wait(); err != nil {
+		fmt.Println("Finding files failed:", err)
+	}
 }
 
-func findFiles(path string, files chan<- string) {
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+func findFiles(ctx context.Context, path string, files chan<- string) error {
+	return filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			files <- path
+		if !entry.IsDir() && !send(ctx, files, path) {
+			return ctx.Err()
 		}
 		return nil
 	})
-	if err != nil {
-		panic(err)
-	}
 }
 
-func filterByExtension(files <-chan string, filesWithExtension chan<- string, extension string) {
+func filterByExtension(ctx context.Context, files <-chan string, filesWithExtension chan<- string, extension string) error {
 	for file := range files {
-		if strings.HasSuffix(file, extension) {
-			filesWithExtension <- file
+		if strings.HasSuffix(file, extension) && !send(ctx, filesWithExtension, file) {
+			return ctx.Err()
 		}
 	}
+	return nil
 }
 
-func filterByContent(filesWithExtension <-chan string, filesWithContent chan<- string, text string) {
+func filterByContent(ctx context.Context, filesWithExtension <-chan string, filesWithContent chan<- string, text string) error {
 	for file := range filesWithExtension {
 		content, err := os.ReadFile(file)
 		if err != nil {
-			continue
+			return err
 		}
-		if strings.Contains(string(content), text) {
-			filesWithContent <- file
+		if strings.Contains(string(content), text) && !send(ctx, filesWithContent, file) {
+			return ctx.Err()
 		}
 	}
+	return nil
 }
@@ -0,0 +1,131 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNoFutures = errors.New("no futures to wait for")
+
+// Future holds a value or an error that becomes known later. It completes
+// once, later attempts to complete it are ignored.
+type Future[T any] struct {
+	done  chan struct{}
+	once  sync.Once
+	value T
+	err   error
+}
+
+func NewFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Async runs task on a new goroutine and completes the future with its outcome
+func Async[T any](task func() (T, error)) *Future[T] {
+	future := NewFuture[T]()
+	go func() {
+		future.Complete(task())
+	}()
+	return future
+}
+
+// Complete reports false if the future was already completed
+func (f *Future[T]) Complete(value T, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+func (f *Future[T]) Resolve(value T) bool {
+	return f.Complete(value, nil)
+}
+
+func (f *Future[T]) Reject(err error) bool {
+	var zero T
+	return f.Complete(zero, err)
+}
+
+// Done is closed when the future completes
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits until the future completes or ctx is done
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Then maps the value of the future, an error is passed on without calling mapper
+func Then[T, R any](future *Future[T], mapper func(T) (R, error)) *Future[R] {
+	return Async(func() (R, error) {
+		value, err := future.Get(context.Background())
+		if err != nil {
+			var zero R
+			return zero, err
+		}
+		return mapper(value)
+	})
+}
+
+// All completes with the values of all futures in their order, or with the first error
+func All[T any](futures ...*Future[T]) *Future[[]T] {
+	all := NewFuture[[]T]()
+	values := make([]T, len(futures))
+	var wg sync.WaitGroup
+	for i, future := range futures {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := future.Get(context.Background())
+			if err != nil {
+				all.Reject(err)
+				return
+			}
+			values[i] = value
+		}()
+	}
+	go func() {
+		wg.Wait()
+		all.Resolve(values)
+	}()
+	return all
+}
+
+// Any completes with the first value, or with all errors when every future failed
+func Any[T any](futures ...*Future[T]) *Future[T] {
+	first := NewFuture[T]()
+	if len(futures) == 0 {
+		first.Reject(ErrNoFutures)
+		return first
+	}
+	errs := make([]error, len(futures))
+	var wg sync.WaitGroup
+	for i, future := range futures {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := future.Get(context.Background())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			first.Resolve(value)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		first.Reject(errors.Join(errs...))
+	}()
+	return first
+}
@@ -0,0 +1,52 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// download pretends to fetch a file from a mirror, some of them are down
+func download(mirror, file string) (string, error) {
+	time.Sleep(time.Duration(100+rand.Intn(400)) * time.Millisecond)
+	if rand.Intn(4) == 0 {
+		return "", errors.New(mirror + " is down")
+	}
+	return fmt.Sprintf("%s from %s", file, mirror), nil
+}
+
+// FutureMirrors downloads every file from the fastest of three mirrors, all files at once
+func FutureMirrors() {
+	files := []string{"go.tar.gz", "go.sha256", "go.asc"}
+	var downloads []*Future[string]
+	for _, file := range files {
+		var attempts []*Future[string]
+		for _, mirror := range []string{"mirror-eu", "mirror-us", "mirror-asia"} {
+			attempts = append(attempts, Async(func() (string, error) { return download(mirror, file) }))
+		}
+		downloads = append(downloads, Any(attempts...))
+	}
+	totalSize := Then(All(downloads...), func(contents []string) (int, error) {
+		size := 0
+		for _, content := range contents {
+			size += len(content)
+		}
+		return size, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	size, err := totalSize.Get(ctx)
+	if err != nil {
+		fmt.Println("Download failed:", err)
+		return
+	}
+	fmt.Printf("Downloaded %d bytes in %v\n", size, time.Since(start).Round(time.Millisecond))
+	for _, download := range downloads {
+		content, _ := download.Get(ctx)
+		fmt.Println(content)
+	}
+}
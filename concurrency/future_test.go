@@ -0,0 +1,82 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFutureCompletesOnce(t *testing.T) {
+	future := NewFuture[int]()
+	if !future.Resolve(1) || future.Resolve(2) || future.Reject(errors.New("late")) {
+		t.Error("Expected only the first completion to count")
+	}
+	if value, err := future.Get(context.Background()); value != 1 || err != nil {
+		t.Errorf("Get = %v, %v", value, err)
+	}
+}
+
+func TestFutureGetHonorsContext(t *testing.T) {
+	future := NewFuture[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := future.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+}
+
+func TestThen(t *testing.T) {
+	doubled := Then(Async(func() (int, error) { return 21, nil }), func(value int) (int, error) {
+		return value * 2, nil
+	})
+	if value, err := doubled.Get(context.Background()); value != 42 || err != nil {
+		t.Errorf("Then = %v, %v", value, err)
+	}
+	failure := errors.New("failure")
+	called := false
+	failed := Then(Async(func() (int, error) { return 0, failure }), func(value int) (string, error) {
+		called = true
+		return "", nil
+	})
+	if _, err := failed.Get(context.Background()); err != failure || called {
+		t.Errorf("Expected the error passed on without mapping, got %v, called %v", err, called)
+	}
+}
+
+func TestAll(t *testing.T) {
+	futures := []*Future[int]{NewFuture[int](), NewFuture[int](), NewFuture[int]()}
+	all := All(futures...)
+	// Completing out of order keeps the values in the order of futures
+	futures[2].Resolve(3)
+	futures[0].Resolve(1)
+	futures[1].Resolve(2)
+	if values, err := all.Get(context.Background()); !slices.Equal(values, []int{1, 2, 3}) || err != nil {
+		t.Errorf("All = %v, %v", values, err)
+	}
+
+	failure := errors.New("failure")
+	pending := NewFuture[int]()
+	failed := All(pending, Async(func() (int, error) { return 0, failure }))
+	if _, err := failed.Get(context.Background()); err != failure {
+		t.Errorf("Expected the first error without waiting for the pending future, got %v", err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	slow := NewFuture[string]()
+	fastest := Any(Async(func() (string, error) { return "", first }), slow, Async(func() (string, error) { return "fast", nil }))
+	if value, err := fastest.Get(context.Background()); value != "fast" || err != nil {
+		t.Errorf("Any = %v, %v", value, err)
+	}
+
+	failed := Any(Async(func() (string, error) { return "", first }), Async(func() (string, error) { return "", second }))
+	if _, err := failed.Get(context.Background()); !errors.Is(err, first) || !errors.Is(err, second) {
+		t.Errorf("Expected all errors when every future failed, got %v", err)
+	}
+	if _, err := Any[int]().Get(context.Background()); err != ErrNoFutures {
+		t.Errorf("Expected no futures to fail, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs tasks on goroutines and waits for them, keeping the first error.
+// A zero Group has no context and no limit.
+type Group struct {
+	wg      sync.WaitGroup
+	cancel  context.CancelCauseFunc
+	limit   chan struct{} // A token per running task
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup returns a group and a context cancelled when a task fails or Wait returns
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit lets at most n tasks run at once, Go blocks when they do. A negative n
+// removes the limit. The limit can't change while tasks are running.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.limit = nil
+		return
+	}
+	if len(g.limit) != 0 {
+		panic("concurrency: changing the limit of a group with running tasks")
+	}
+	g.limit = make(chan struct{}, n)
+}
+
+// Go runs the task on a new goroutine. The first task returning an error cancels
+// the context of the group.
+func (g *Group) Go(task func() error) {
+	if g.limit != nil {
+		g.limit <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := task(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.limit != nil {
+		<-g.limit
+	}
+	g.wg.Done()
+}
+
+// Wait blocks until all tasks are done and returns the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
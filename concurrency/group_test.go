@@ -0,0 +1,69 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCancelsOnFirstError(t *testing.T) {
+	group, ctx := NewGroup(context.Background())
+	failure := errors.New("failure")
+	group.Go(func() error { return failure })
+	group.Go(func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("not cancelled")
+		}
+	})
+	if err := group.Wait(); err != failure {
+		t.Errorf("Expected the first error, got %v", err)
+	}
+	if cause := context.Cause(ctx); cause != failure {
+		t.Errorf("Expected the error as the cause of cancellation, got %v", cause)
+	}
+}
+
+func TestGroupWaitCancelsContext(t *testing.T) {
+	group, ctx := NewGroup(context.Background())
+	var done atomic.Int32
+	for range 5 {
+		group.Go(func() error {
+			done.Add(1)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil || done.Load() != 5 {
+		t.Errorf("Expected all tasks done without an error, got %d, %v", done.Load(), err)
+	}
+	if ctx.Err() == nil {
+		t.Error("Expected the context cancelled once Wait returns")
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	var group Group
+	group.SetLimit(2)
+	var running, maxRunning atomic.Int32
+	for range 10 {
+		group.Go(func() error {
+			current := running.Add(1)
+			for {
+				previous := maxRunning.Load()
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil || maxRunning.Load() != 2 {
+		t.Errorf("Expected at most 2 tasks at once, got %d, %v", maxRunning.Load(), err)
+	}
+}
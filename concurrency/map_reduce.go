@@ -0,0 +1,62 @@
+package concurrency
+
+import "sync"
+
+type partition[K comparable, R any] struct {
+	index   int
+	results map[K]R
+	err     error
+}
+
+// MapReduce splits inputs into a contiguous partition per worker. Every worker maps
+// its inputs and reduces the results by key, then the partitions are merged in the
+// order of inputs. The result doesn't depend on which worker finished first as long
+// as reduce is associative. Returns the error of the first input that failed.
+func MapReduce[K comparable, V, R any](inputs []V, workers int, mapper func(V) (map[K]R, error), reduce func(R, R) R) (map[K]R, error) {
+	workers = max(min(workers, len(inputs)), 1)
+	partitions := make(chan partition[K, R], workers)
+	var wg sync.WaitGroup
+	for index := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := map[K]R{}
+			for _, input := range inputs[index*len(inputs)/workers : (index+1)*len(inputs)/workers] {
+				mapped, err := mapper(input)
+				if err != nil {
+					partitions <- partition[K, R]{index: index, err: err}
+					return
+				}
+				reduceInto(results, mapped, reduce)
+			}
+			partitions <- partition[K, R]{index: index, results: results}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(partitions)
+	}()
+
+	ordered := make([]partition[K, R], workers)
+	for p := range partitions {
+		ordered[p.index] = p
+	}
+	results := map[K]R{}
+	for _, p := range ordered {
+		if p.err != nil {
+			return nil, p.err
+		}
+		reduceInto(results, p.results, reduce)
+	}
+	return results, nil
+}
+
+// reduceInto combines every value of source with the value under the same key in target
+func reduceInto[K comparable, R any](target, source map[K]R, reduce func(R, R) R) {
+	for key, value := range source {
+		if existing, ok := target[key]; ok {
+			value = reduce(existing, value)
+		}
+		target[key] = value
+	}
+}
@@ -0,0 +1,73 @@
+package concurrency
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMapReduceIsDeterministic(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	mapper := func(number int) (map[int]string, error) {
+		return map[int]string{number % 3: strconv.Itoa(number)}, nil
+	}
+	// Concatenation isn't commutative, so a result in input order proves the merge order
+	concat := func(a, b string) string { return a + "," + b }
+
+	expected, _ := MapReduce(inputs, 1, mapper, concat)
+	for _, workers := range []int{2, 7, 100, 500} {
+		results, err := MapReduce(inputs, workers, mapper, concat)
+		if err != nil || !maps.Equal(results, expected) {
+			t.Errorf("MapReduce with %d workers = %v, %v; want %v", workers, results, err, expected)
+		}
+	}
+	if expected[1] != "1,4,7,10,13,16,19,22,25,28,31,34,37,40,43,46,49,52,55,58,61,64,67,70,73,76,79,82,85,88,91,94,97" {
+		t.Errorf("Expected values in input order, got %q", expected[1])
+	}
+}
+
+func TestMapReduceReturnsFirstError(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	mapper := func(number int) (map[string]int, error) {
+		switch number {
+		case 3:
+			return nil, first
+		case 8:
+			return nil, second
+		}
+		return map[string]int{"n": number}, nil
+	}
+	sum := func(a, b int) int { return a + b }
+	if _, err := MapReduce([]int{1, 2, 3, 4, 5, 6, 7, 8, 9}, 3, mapper, sum); err != first {
+		t.Errorf("Expected the error of the first failed input, got %v", err)
+	}
+	if results, err := MapReduce([]int{}, 4, mapper, sum); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results for no inputs, got %v, %v", results, err)
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, content := range []string{"Go is fun", "go, GO, go!", "fun: 42 times"} {
+		path := filepath.Join(dir, strconv.Itoa(i)+".txt")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	counts, err := WordCount(paths, 2)
+	expected := map[string]int{"go": 4, "is": 1, "fun": 2, "times": 1}
+	if err != nil || !maps.Equal(counts, expected) {
+		t.Errorf("WordCount = %v, %v; want %v", counts, err, expected)
+	}
+	if _, err := WordCount(append(paths, filepath.Join(dir, "missing.txt")), 2); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file to fail, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package concurrency
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+func countWords(path string) (map[string]int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, word := range strings.FieldsFunc(string(content), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		counts[strings.ToLower(word)]++
+	}
+	return counts, nil
+}
+
+// WordCount counts the words of all files, a word is a run of letters compared case-insensitively
+func WordCount(paths []string, workers int) (map[string]int, error) {
+	return MapReduce(paths, workers, countWords, func(a, b int) int { return a + b })
+}
+
+// MapReduceWordCount prints the most frequent words in the sources of this package
+func MapReduceWordCount() {
+	paths, err := filepath.Glob(filepath.Join("concurrency", "*.go"))
+	if err != nil {
+		panic(err)
+	}
+	counts, err := WordCount(paths, 4)
+	if err != nil {
+		fmt.Println("Word count failed:", err)
+		return
+	}
+	words := make([]string, 0, len(counts))
+	for word := range counts {
+		words = append(words, word)
+	}
+	slices.SortFunc(words, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	fmt.Printf("%d files, %d distinct words\n", len(paths), len(words))
+	for _, word := range words[:min(10, len(words))] {
+		fmt.Printf("%-12s %d\n", word, counts[word])
+	}
+}
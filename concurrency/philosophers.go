@@ -0,0 +1,349 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fork is a channel holding a token while the fork lies on the table, unlike a
+// mutex taking it can be given up when the context is done
+type fork chan struct{}
+
+func newFork() fork {
+	f := make(fork, 1)
+	f <- struct{}{}
+	return f
+}
+
+func (f fork) take(ctx context.Context) error {
+	select {
+	case <-f:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f fork) tryTake() bool {
+	select {
+	case <-f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f fork) put() {
+	f <- struct{}{}
+}
+
+type PhilosopherState int32
+
+const (
+	Thinking PhilosopherState = iota
+	Hungry
+	Eating
+)
+
+func (s PhilosopherState) String() string {
+	return [...]string{"thinking", "hungry", "eating"}[s]
+}
+
+type philosopher struct {
+	state   atomic.Int32
+	since   atomic.Int64 // Unix nanoseconds of the last state change
+	holding atomic.Int32 // Forks in hand
+	meals   atomic.Int64
+}
+
+func (p *philosopher) setState(state PhilosopherState) {
+	p.state.Store(int32(state))
+	p.since.Store(time.Now().UnixNano())
+}
+
+// DiningStrategy decides how a philosopher picks up the two forks next to the seat
+type DiningStrategy interface {
+	Name() string
+	PickUp(ctx context.Context, table *DiningTable, seat int) error
+	PutDown(table *DiningTable, seat int)
+}
+
+// DiningTable seats philosophers between forks, philosopher i eats with forks i and i+1
+type DiningTable struct {
+	forks        []fork
+	philosophers []*philosopher
+	strategy     DiningStrategy
+	thinkTime    time.Duration
+	eatTime      time.Duration
+}
+
+func NewDiningTable(seats int, strategy DiningStrategy, thinkTime, eatTime time.Duration) *DiningTable {
+	table := &DiningTable{strategy: strategy, thinkTime: thinkTime, eatTime: eatTime}
+	for range seats {
+		table.forks = append(table.forks, newFork())
+		table.philosophers = append(table.philosophers, &philosopher{})
+	}
+	return table
+}
+
+func (t *DiningTable) left(seat int) fork {
+	return t.forks[seat]
+}
+
+func (t *DiningTable) right(seat int) fork {
+	return t.forks[(seat+1)%len(t.forks)]
+}
+
+// take takes a fork on behalf of the philosopher at the seat, so the detector sees it in hand
+func (t *DiningTable) take(ctx context.Context, seat int, f fork) error {
+	if err := f.take(ctx); err != nil {
+		return err
+	}
+	t.philosophers[seat].holding.Add(1)
+	return nil
+}
+
+func (t *DiningTable) tryTake(seat int, f fork) bool {
+	if !f.tryTake() {
+		return false
+	}
+	t.philosophers[seat].holding.Add(1)
+	return true
+}
+
+func (t *DiningTable) put(seat int, f fork) {
+	t.philosophers[seat].holding.Add(-1)
+	f.put()
+}
+
+// Dine lets the philosophers think and eat until ctx is done and returns the meals each one had
+func (t *DiningTable) Dine(ctx context.Context) []int64 {
+	var wg sync.WaitGroup
+	for seat := range t.philosophers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.live(ctx, seat)
+		}()
+	}
+	wg.Wait()
+	meals := make([]int64, len(t.philosophers))
+	for seat, p := range t.philosophers {
+		meals[seat] = p.meals.Load()
+	}
+	return meals
+}
+
+func (t *DiningTable) live(ctx context.Context, seat int) {
+	p := t.philosophers[seat]
+	for {
+		p.setState(Thinking)
+		if !sleep(ctx, t.thinkTime) {
+			return
+		}
+		p.setState(Hungry)
+		if err := t.strategy.PickUp(ctx, t, seat); err != nil {
+			return
+		}
+		p.setState(Eating)
+		p.meals.Add(1)
+		eaten := sleep(ctx, t.eatTime)
+		t.strategy.PutDown(t, seat)
+		if !eaten {
+			return
+		}
+	}
+}
+
+// sleep reports false if ctx was done first
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// NaiveForks takes the left fork, then the right one. When all philosophers
+// hold their left fork, each waits for a neighbour forever.
+type NaiveForks struct {
+	// Pause between the forks, giving the neighbours time to take their left fork
+	Pause time.Duration
+}
+
+func (NaiveForks) Name() string { return "naive" }
+
+func (s NaiveForks) PickUp(ctx context.Context, table *DiningTable, seat int) error {
+	if err := table.take(ctx, seat, table.left(seat)); err != nil {
+		return err
+	}
+	time.Sleep(s.Pause)
+	if err := table.take(ctx, seat, table.right(seat)); err != nil {
+		table.put(seat, table.left(seat))
+		return err
+	}
+	return nil
+}
+
+func (NaiveForks) PutDown(table *DiningTable, seat int) {
+	table.put(seat, table.right(seat))
+	table.put(seat, table.left(seat))
+}
+
+// OrderedForks takes the lower numbered fork first. The last philosopher
+// reaches for the right fork first, so no cycle of waiting philosophers can form.
+type OrderedForks struct{}
+
+func (OrderedForks) Name() string { return "ordered locks" }
+
+func (OrderedForks) PickUp(ctx context.Context, table *DiningTable, seat int) error {
+	first, second := table.left(seat), table.right(seat)
+	if seat == len(table.forks)-1 {
+		first, second = second, first
+	}
+	if err := table.take(ctx, seat, first); err != nil {
+		return err
+	}
+	if err := table.take(ctx, seat, second); err != nil {
+		table.put(seat, first)
+		return err
+	}
+	return nil
+}
+
+func (OrderedForks) PutDown(table *DiningTable, seat int) {
+	table.put(seat, table.right(seat))
+	table.put(seat, table.left(seat))
+}
+
+// Arbiter lets at most seats-1 philosophers reach for forks at once, so at
+// least one of them always gets both
+type Arbiter struct {
+	seats *WeightedSemaphore
+}
+
+func NewArbiter(seats int) *Arbiter {
+	return &Arbiter{NewWeightedSemaphore(int64(seats - 1))}
+}
+
+func (*Arbiter) Name() string { return "arbiter" }
+
+func (a *Arbiter) PickUp(ctx context.Context, table *DiningTable, seat int) error {
+	if err := a.seats.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	if err := (NaiveForks{}).PickUp(ctx, table, seat); err != nil {
+		a.seats.Release(1)
+		return err
+	}
+	return nil
+}
+
+func (a *Arbiter) PutDown(table *DiningTable, seat int) {
+	NaiveForks{}.PutDown(table, seat)
+	a.seats.Release(1)
+}
+
+// TryLockBackoff puts the left fork back when the right one is taken and
+// tries again after a random pause, so neighbours don't retry in lockstep
+type TryLockBackoff struct {
+	MaxBackoff time.Duration
+}
+
+func (TryLockBackoff) Name() string { return "try-lock with backoff" }
+
+func (s TryLockBackoff) PickUp(ctx context.Context, table *DiningTable, seat int) error {
+	for {
+		if err := table.take(ctx, seat, table.left(seat)); err != nil {
+			return err
+		}
+		if table.tryTake(seat, table.right(seat)) {
+			return nil
+		}
+		table.put(seat, table.left(seat))
+		if !sleep(ctx, time.Duration(rand.Int63n(int64(s.MaxBackoff)+1))) {
+			return ctx.Err()
+		}
+	}
+}
+
+func (TryLockBackoff) PutDown(table *DiningTable, seat int) {
+	NaiveForks{}.PutDown(table, seat)
+}
+
+// DiningReport lists the philosophers hungry for longer than the detector's threshold
+type DiningReport struct {
+	Starving []int
+	// Every philosopher is hungry with a fork in hand, none can ever eat
+	Deadlock bool
+}
+
+// Detect checks the table every interval until ctx is done and passes a report
+// to handle whenever a philosopher was hungry for longer than threshold
+func (t *DiningTable) Detect(ctx context.Context, interval, threshold time.Duration, handle func(DiningReport)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if report := t.inspect(threshold); len(report.Starving) > 0 {
+				handle(report)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *DiningTable) inspect(threshold time.Duration) DiningReport {
+	report := DiningReport{Deadlock: true}
+	now := time.Now().UnixNano()
+	for seat, p := range t.philosophers {
+		hungry := PhilosopherState(p.state.Load()) == Hungry
+		stuck := hungry && time.Duration(now-p.since.Load()) > threshold
+		if stuck {
+			report.Starving = append(report.Starving, seat)
+		}
+		if !stuck || p.holding.Load() != 1 {
+			report.Deadlock = false
+		}
+	}
+	return report
+}
+
+// DiningPhilosophers seats five philosophers with every strategy in turn
+func DiningPhilosophers() {
+	strategies := []DiningStrategy{
+		NaiveForks{Pause: 50 * time.Millisecond},
+		OrderedForks{},
+		NewArbiter(5),
+		TryLockBackoff{MaxBackoff: 20 * time.Millisecond},
+	}
+	for _, strategy := range strategies {
+		fmt.Printf("\nStrategy: %s\n", strategy.Name())
+		table := NewDiningTable(5, strategy, 10*time.Millisecond, 20*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		go table.Detect(ctx, 100*time.Millisecond, 500*time.Millisecond, func(report DiningReport) {
+			if report.Deadlock {
+				fmt.Println("Deadlock: every philosopher holds one fork and waits for the other")
+				cancel()
+				return
+			}
+			var seats []string
+			for _, seat := range report.Starving {
+				seats = append(seats, fmt.Sprint(seat))
+			}
+			fmt.Printf("Starving philosophers: %s\n", strings.Join(seats, ", "))
+		})
+		fmt.Printf("Meals: %v\n", table.Dine(ctx))
+		cancel()
+	}
+}
@@ -0,0 +1,68 @@
+package concurrency
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiningStrategiesFeedEveryone(t *testing.T) {
+	strategies := []DiningStrategy{
+		OrderedForks{},
+		NewArbiter(5),
+		TryLockBackoff{MaxBackoff: 5 * time.Millisecond},
+	}
+	for _, strategy := range strategies {
+		t.Run(strategy.Name(), func(t *testing.T) {
+			table := NewDiningTable(5, strategy, time.Millisecond, 2*time.Millisecond)
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			var deadlock atomic.Bool
+			go table.Detect(ctx, 10*time.Millisecond, 200*time.Millisecond, func(report DiningReport) {
+				if report.Deadlock {
+					deadlock.Store(true)
+				}
+			})
+			meals := table.Dine(ctx)
+			if deadlock.Load() {
+				t.Error("Expected no deadlock")
+			}
+			for seat, count := range meals {
+				if count == 0 {
+					t.Errorf("Expected philosopher %d to eat, meals %v", seat, meals)
+				}
+			}
+			for _, f := range table.forks {
+				if len(f) != 1 {
+					t.Fatal("Expected every fork back on the table")
+				}
+			}
+		})
+	}
+}
+
+func TestDetectorReportsDeadlock(t *testing.T) {
+	table := NewDiningTable(3, NaiveForks{Pause: 20 * time.Millisecond}, 0, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reports := make(chan DiningReport, 1)
+	go table.Detect(ctx, 10*time.Millisecond, 50*time.Millisecond, func(report DiningReport) {
+		if report.Deadlock {
+			select {
+			case reports <- report:
+			default:
+			}
+			cancel()
+		}
+	})
+	table.Dine(ctx)
+	select {
+	case report := <-reports:
+		if len(report.Starving) != 3 {
+			t.Errorf("Expected all philosophers reported, got %v", report.Starving)
+		}
+	default:
+		t.Fatal("Expected the deadlock to be detected")
+	}
+}
@@ -0,0 +1,104 @@
+package concurrency
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// Every stage runs in goroutines of its own and closes its output once its input
+// is closed or ctx is done. Cancelling ctx stops the whole pipeline, so a consumer
+// may stop reading early without leaking the goroutines of the stages.
+
+// Generate sends the values of a sequence, the source of a pipeline
+func Generate[T any](ctx context.Context, values iter.Seq[T]) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for value := range values {
+			if !send(ctx, out, value) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map passes every value through mapper
+func Map[T, R any](ctx context.Context, in <-chan T, mapper func(T) R) <-chan R {
+	out := make(chan R)
+	go func() {
+		defer close(out)
+		for value := range in {
+			if !send(ctx, out, mapper(value)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Filter passes on only the values keep accepts
+func Filter[T any](ctx context.Context, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for value := range in {
+			if keep(value) && !send(ctx, out, value) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FanOut spreads the values among count outputs, each value goes to the first
+// output ready to take it, so a slow consumer doesn't hold up the others
+func FanOut[T any](ctx context.Context, in <-chan T, count int) []<-chan T {
+	outs := make([]<-chan T, count)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for value := range in {
+				if !send(ctx, out, value) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// FanIn merges the values of all inputs into one output, closed when all inputs are
+func FanIn[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for value := range in {
+				if !send(ctx, out, value) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// send reports false if ctx was done before out took the value
+func send[T any](ctx context.Context, out chan<- T, value T) bool {
+	select {
+	case out <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
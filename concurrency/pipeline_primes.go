@@ -0,0 +1,47 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type candidate struct {
+	number int
+	prime  bool
+}
+
+// checkPrime is deliberately slow, it is the stage worth running on several goroutines
+func checkPrime(number int) candidate {
+	time.Sleep(100 * time.Millisecond)
+	for divisor := 2; divisor*divisor <= number; divisor++ {
+		if number%divisor == 0 {
+			return candidate{number, false}
+		}
+	}
+	return candidate{number, number > 1}
+}
+
+// PipelinePrimes finds the first primes with a pipeline:
+// numbers -> odd only -> fan out to 4 checkers -> fan in -> primes only
+func PipelinePrimes() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // Stops the stages, the numbers never run out
+
+	numbers := Generate(ctx, func(yield func(int) bool) {
+		for number := 2; yield(number); number++ {
+		}
+	})
+	odd := Filter(ctx, numbers, func(number int) bool { return number == 2 || number%2 == 1 })
+	var checked []<-chan candidate
+	for _, part := range FanOut(ctx, odd, 4) {
+		checked = append(checked, Map(ctx, part, checkPrime))
+	}
+	primes := Filter(ctx, FanIn(ctx, checked...), func(c candidate) bool { return c.prime })
+
+	start := time.Now()
+	for i := 1; i <= 10; i++ {
+		// Checkers finish out of order, so the primes do as well
+		fmt.Printf("Prime %d: %d (after %v)\n", i, (<-primes).number, time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,77 @@
+package concurrency
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func collect[T any](in <-chan T) []T {
+	var values []T
+	for value := range in {
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestPipelineStages(t *testing.T) {
+	ctx := context.Background()
+	numbers := Generate(ctx, slices.Values([]int{1, 2, 3, 4, 5, 6}))
+	even := Filter(ctx, numbers, func(n int) bool { return n%2 == 0 })
+	texts := Map(ctx, even, strconv.Itoa)
+	if values := collect(texts); !slices.Equal(values, []string{"2", "4", "6"}) {
+		t.Errorf("Expected [2 4 6], got %v", values)
+	}
+}
+
+func TestFanOutAndFanIn(t *testing.T) {
+	ctx := context.Background()
+	numbers := Generate(ctx, func(yield func(int) bool) {
+		for n := range 100 {
+			if !yield(n) {
+				return
+			}
+		}
+	})
+	var squares []<-chan int
+	for _, part := range FanOut(ctx, numbers, 4) {
+		squares = append(squares, Map(ctx, part, func(n int) int { return n * n }))
+	}
+	values := collect(FanIn(ctx, squares...))
+	slices.Sort(values)
+	if len(values) != 100 {
+		t.Fatalf("Expected 100 values, got %d", len(values))
+	}
+	for n, value := range values {
+		if value != n*n {
+			t.Fatalf("Expected every square once, got %v", values)
+		}
+	}
+}
+
+func TestCancellingStopsThePipeline(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	numbers := Generate(ctx, func(yield func(int) bool) {
+		for n := 0; yield(n); n++ {
+		}
+	})
+	merged := FanIn(ctx, FanOut(ctx, Map(ctx, numbers, func(n int) int { return n + 1 }), 3)...)
+	for range 10 {
+		<-merged
+	}
+	cancel()
+	for range merged {
+		// Values sent before the stages noticed the cancellation
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if leaked := runtime.NumGoroutine() - before; leaked > 0 {
+		t.Errorf("Expected the stages to stop, %d goroutines left", leaked)
+	}
+}
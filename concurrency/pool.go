@@ -0,0 +1,154 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"training.pl/go/common"
+)
+
+var ErrPoolClosed = errors.New("pool is shut down")
+
+// PanicError is a panic recovered from a task
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+type workerIdKey struct{}
+
+// WorkerId returns the number of the pool worker running the task, counted from 1
+func WorkerId(ctx context.Context) int {
+	id, _ := ctx.Value(workerIdKey{}).(int)
+	return id
+}
+
+// Pool runs submitted tasks on a fixed number of workers. Tasks wait in a queue
+// of bounded size, Submit blocks while it is full until the pool is shut down.
+type Pool struct {
+	tasks   chan func(context.Context)
+	workers sync.WaitGroup
+	senders sync.WaitGroup // Submit calls past the closed check, tasks is closed after them
+	closing chan struct{}  // Closed by Shutdown to release blocked Submit calls
+	ctx     context.Context
+	cancel  context.CancelFunc
+	onPanic func(*PanicError)
+	lock    sync.RWMutex // Guards closed, so no sender starts after Shutdown
+	closed  bool
+}
+
+func NewPool(workers, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		tasks:   make(chan func(context.Context), queueSize),
+		closing: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		onPanic: func(err *PanicError) {
+			log.Printf("%v\n%s", err, err.Stack)
+		},
+	}
+	for id := 1; id <= workers; id++ {
+		p.workers.Add(1)
+		go p.worker(id)
+	}
+	return p
+}
+
+// OnPanic replaces the handler of panics in tasks submitted with Submit, by default
+// they are logged. Set it before submitting tasks.
+func (p *Pool) OnPanic(handler func(err *PanicError)) *Pool {
+	p.onPanic = handler
+	return p
+}
+
+// Submit queues a task, the context it gets is cancelled when Shutdown gives up waiting.
+// It fails with ErrPoolClosed once Shutdown is called, also while waiting for room in the queue.
+func (p *Pool) Submit(task func(ctx context.Context)) error {
+	p.lock.RLock()
+	if p.closed {
+		p.lock.RUnlock()
+		return ErrPoolClosed
+	}
+	p.senders.Add(1)
+	p.lock.RUnlock()
+	defer p.senders.Done()
+
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-p.closing:
+		return ErrPoolClosed
+	}
+}
+
+// SubmitResult queues a task and sends its outcome to results, a panic becomes a PanicError.
+// It is a function, methods can't have type parameters of their own.
+func SubmitResult[R any](p *Pool, results chan<- common.Result[R], task func(ctx context.Context) (R, error)) error {
+	return p.Submit(func(ctx context.Context) {
+		var result common.Result[R]
+		func() {
+			defer recoverPanic(func(err *PanicError) { result = common.Failed[R](err) })
+			result.Value, result.Error = task(ctx)
+		}()
+		results <- result
+	})
+}
+
+// Shutdown stops accepting tasks and waits until the queued ones are done. When
+// ctx ends first, the context of the tasks is cancelled and Shutdown returns without waiting.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	first := !p.closed
+	if first {
+		p.closed = true
+		close(p.closing)
+	}
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		if first {
+			p.senders.Wait()
+			close(p.tasks)
+		}
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) worker(id int) {
+	defer p.workers.Done()
+	ctx := context.WithValue(p.ctx, workerIdKey{}, id)
+	for task := range p.tasks {
+		p.run(ctx, task)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, task func(context.Context)) {
+	defer recoverPanic(p.onPanic)
+	task(ctx)
+}
+
+// recoverPanic passes a panic to handler, it must be deferred directly
+func recoverPanic(handler func(*PanicError)) {
+	if value := recover(); value != nil {
+		handler(&PanicError{Value: value, Stack: debug.Stack()})
+	}
+}
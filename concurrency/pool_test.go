@@ -0,0 +1,145 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"training.pl/go/common"
+)
+
+func TestPoolRunsTasksOnBoundedWorkers(t *testing.T) {
+	pool := NewPool(3, 10)
+	var running, maxRunning, done atomic.Int32
+	workers := sync.Map{}
+	for range 20 {
+		err := pool.Submit(func(ctx context.Context) {
+			current := running.Add(1)
+			for {
+				previous := maxRunning.Load()
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			workers.Store(WorkerId(ctx), true)
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if done.Load() != 20 || maxRunning.Load() > 3 {
+		t.Errorf("Expected 20 tasks on at most 3 workers, got %d tasks, %d at once", done.Load(), maxRunning.Load())
+	}
+	workers.Range(func(id, _ any) bool {
+		if id.(int) < 1 || id.(int) > 3 {
+			t.Errorf("Unexpected worker id %v", id)
+		}
+		return true
+	})
+	if err := pool.Submit(func(context.Context) {}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected Submit after Shutdown to fail, got %v", err)
+	}
+}
+
+func TestSubmitResult(t *testing.T) {
+	pool := NewPool(2, 0)
+	results := make(chan common.Result[int], 3)
+	errTask := errors.New("task failed")
+	SubmitResult(pool, results, func(context.Context) (int, error) { return 42, nil })
+	SubmitResult(pool, results, func(context.Context) (int, error) { return 0, errTask })
+	SubmitResult(pool, results, func(context.Context) (int, error) { panic("boom") })
+	pool.Shutdown(context.Background())
+	close(results)
+
+	var values []int
+	var errs []error
+	for result := range results {
+		if result.IsOk() {
+			values = append(values, result.Value)
+		} else {
+			errs = append(errs, result.Error)
+		}
+	}
+	if len(values) != 1 || values[0] != 42 || len(errs) != 2 {
+		t.Fatalf("Unexpected results %v, %v", values, errs)
+	}
+	var panicErr *PanicError
+	for _, err := range errs {
+		if !errors.Is(err, errTask) && !(errors.As(err, &panicErr) && panicErr.Value == "boom") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+}
+
+func TestPoolRecoversPanics(t *testing.T) {
+	panics := make(chan *PanicError, 1)
+	pool := NewPool(1, 1).OnPanic(func(err *PanicError) { panics <- err })
+	pool.Submit(func(context.Context) { panic("boom") })
+	ran := false
+	pool.Submit(func(context.Context) { ran = true })
+	pool.Shutdown(context.Background())
+	if err := <-panics; err.Value != "boom" || len(err.Stack) == 0 {
+		t.Errorf("Unexpected panic %v", err)
+	}
+	if !ran {
+		t.Error("Expected the worker to survive the panic")
+	}
+}
+
+func TestShutdownCancelsTasksOnTimeout(t *testing.T) {
+	pool := NewPool(1, 0)
+	cancelled := make(chan struct{})
+	pool.Submit(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Shutdown to give up, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the running task to be cancelled")
+	}
+}
+
+func TestShutdownWithFullQueue(t *testing.T) {
+	pool := NewPool(1, 1)
+	release := make(chan struct{})
+	defer close(release)
+	block := func(context.Context) { <-release }
+	pool.Submit(block) // Keeps the worker busy
+	pool.Submit(block) // Fills the queue
+	submitted := make(chan error)
+	go func() { submitted <- pool.Submit(block) }()
+	time.Sleep(10 * time.Millisecond) // Lets the Submit wait for room in the queue
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Shutdown to give up, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, blocked by the waiting Submit", elapsed)
+	}
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("Expected the waiting Submit to fail with ErrPoolClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected Shutdown to release the waiting Submit")
+	}
+}
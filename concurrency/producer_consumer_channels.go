@@ -1,48 +1,67 @@
 package concurrency
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func channelProducer(index int, channel chan<- string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for i := 0; i < 10; i++ {
-		time.Sleep(1 * time.Second)
-		fmt.Println("Sending message", index, i)
-		channel <- fmt.Sprintf("Data from producer %d - %d", index, i)
+// RunProducerConsumerChannels passes the items of the producers to consume through
+// a buffered channel. When ctx is done producers stop, the items already buffered
+// are still consumed. Returns the number of consumed items.
+func RunProducerConsumerChannels(ctx context.Context, config ProducerConsumerConfig, consume func(consumer, item int)) int {
+	items := make(chan int, config.BufferSize)
+
+	var producers sync.WaitGroup
+	for producer := range config.Producers {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			for i := range config.Items {
+				// select picks at random when both are ready, so check ctx first
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case items <- producer*config.Items + i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 	}
-	fmt.Println("Producer finished", index)
-}
+	// Consumers drain the channel until the last producer is done
+	go func() {
+		producers.Wait()
+		close(items)
+	}()
 
-func channelConsumer(index int, channel <-chan string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	done := false
-	for !done {
-		select {
-		case message := <-channel:
-			fmt.Printf("Consumer %d received: %v\n", index, message)
-		case <-time.After(15 * time.Second):
-			done = true
-		}
+	var consumed atomic.Int64
+	var consumers sync.WaitGroup
+	for consumer := range config.Consumers {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for item := range items {
+				consume(consumer, item)
+				consumed.Add(1)
+			}
+		}()
 	}
+	consumers.Wait()
+	return int(consumed.Load())
 }
 
 func ProducerConsumerChannels() {
-	channel := make(chan string, 5)
-	wg := sync.WaitGroup{}
-
-	for i := 0; i < 5; i++ {
-		wg.Add(1)
-		go channelProducer(i, channel, &wg)
-	}
-
-	for i := 0; i < 2; i++ {
-		wg.Add(1)
-		go channelConsumer(i, channel, &wg)
-	}
-
-	wg.Wait()
-	close(channel)
+	// Consumers are too slow to take all items before the timeout, producers stop sending then
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	config := ProducerConsumerConfig{Producers: 5, Consumers: 2, BufferSize: 5, Items: 10}
+	consumed := RunProducerConsumerChannels(ctx, config, func(consumer, item int) {
+		fmt.Printf("Consumer %d received: %d\n", consumer, item)
+		time.Sleep(200 * time.Millisecond)
+	})
+	fmt.Printf("Consumed %d of %d items\n", consumed, config.Producers*config.Items)
 }
@@ -1,52 +1,134 @@
 package concurrency
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"training.pl/go/common"
 )
 
-var storage = common.Stack[int]{}
-var mutex = sync.Mutex{}
-var producerCond = sync.NewCond(&mutex)
-var consumerCond = sync.NewCond(&mutex)
-
-func producer(wg *sync.WaitGroup) {
-	for range 100 {
-		mutex.Lock()
-		for storage.Size() >= 10 {
-			fmt.Println("Producer waiting - storage is full")
-			producerCond.Wait()
-		}
-		fmt.Println("Producing")
-		storage.Push(0)
-		consumerCond.Broadcast()
-		mutex.Unlock()
+type ProducerConsumerConfig struct {
+	Producers  int
+	Consumers  int
+	BufferSize int
+	Items      int // Produced by each producer
+}
+
+// condBuffer is a bounded buffer guarded by a mutex, producers wait on notFull
+// and consumers on notEmpty
+type condBuffer struct {
+	items     common.Queue[int]
+	size      int
+	producing int // Producers not finished yet, consumers stop waiting when none is left
+	cancelled bool
+	lock      sync.Mutex
+	notFull   *sync.Cond
+	notEmpty  *sync.Cond
+}
+
+func newCondBuffer(size, producers int) *condBuffer {
+	buffer := &condBuffer{size: size, producing: producers}
+	buffer.notFull = sync.NewCond(&buffer.lock)
+	buffer.notEmpty = sync.NewCond(&buffer.lock)
+	return buffer
+}
+
+// put reports false if the buffer was cancelled before there was room for the item
+func (b *condBuffer) put(item int) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.items.Size() >= b.size && !b.cancelled {
+		b.notFull.Wait()
 	}
-	wg.Done()
-}
-
-func consumer(wg *sync.WaitGroup) {
-	for range 200 {
-		mutex.Lock()
-		for storage.Size() == 0 {
-			fmt.Println("Consumer waiting - storage is empty")
-			consumerCond.Wait()
-		}
-		fmt.Println("Consuming")
-		storage.Pop()
-		producerCond.Broadcast()
-		mutex.Unlock()
+	if b.cancelled {
+		return false
 	}
-	wg.Done()
+	b.items.Enqueue(item)
+	b.notEmpty.Signal()
+	return true
 }
 
-func ProducerConsumerClassic() {
-	wg := sync.WaitGroup{}
-	wg.Add(3)
-	go producer(&wg)
-	go producer(&wg)
-	go consumer(&wg)
+// take reports false once the buffer is empty and all producers are done
+func (b *condBuffer) take() (int, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.items.Size() == 0 && b.producing > 0 {
+		b.notEmpty.Wait()
+	}
+	item, ok := b.items.Dequeue()
+	if ok {
+		b.notFull.Signal()
+	}
+	return item, ok
+}
+
+func (b *condBuffer) producerDone() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.producing--
+	if b.producing == 0 {
+		b.notEmpty.Broadcast()
+	}
+}
+
+func (b *condBuffer) cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.cancelled = true
+	b.notFull.Broadcast()
+}
+
+// RunProducerConsumerClassic passes the items of the producers to consume through
+// a buffer built on condition variables. When ctx is done producers stop, the
+// items already buffered are still consumed. Returns the number of consumed items.
+func RunProducerConsumerClassic(ctx context.Context, config ProducerConsumerConfig, consume func(consumer, item int)) int {
+	buffer := newCondBuffer(config.BufferSize, config.Producers)
+	// Wakes up producers waiting for room, a condition variable can't wait for ctx
+	stop := context.AfterFunc(ctx, buffer.cancel)
+	defer stop()
+
+	var consumed atomic.Int64
+	var wg sync.WaitGroup
+	for producer := range config.Producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer buffer.producerDone()
+			for i := range config.Items {
+				// The AfterFunc runs a moment after ctx is done
+				if ctx.Err() != nil || !buffer.put(producer*config.Items+i) {
+					return
+				}
+			}
+		}()
+	}
+	for consumer := range config.Consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, ok := buffer.take()
+				if !ok {
+					return
+				}
+				consume(consumer, item)
+				consumed.Add(1)
+			}
+		}()
+	}
 	wg.Wait()
+	return int(consumed.Load())
+}
+
+func ProducerConsumerClassic() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	config := ProducerConsumerConfig{Producers: 2, Consumers: 1, BufferSize: 10, Items: 100}
+	consumed := RunProducerConsumerClassic(ctx, config, func(consumer, item int) {
+		fmt.Printf("Consumer %d consumed %d\n", consumer, item)
+	})
+	fmt.Printf("Consumed %d of %d items\n", consumed, config.Producers*config.Items)
 }
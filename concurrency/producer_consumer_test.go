@@ -0,0 +1,80 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type producerConsumerRunner func(context.Context, ProducerConsumerConfig, func(consumer, item int)) int
+
+var producerConsumerRunners = []struct {
+	name string
+	run  producerConsumerRunner
+}{
+	{"classic", RunProducerConsumerClassic},
+	{"channels", RunProducerConsumerChannels},
+}
+
+func TestProducerConsumerDeliversEveryItem(t *testing.T) {
+	config := ProducerConsumerConfig{Producers: 3, Consumers: 2, BufferSize: 4, Items: 100}
+	for _, runner := range producerConsumerRunners {
+		t.Run(runner.name, func(t *testing.T) {
+			var lock sync.Mutex
+			seen := map[int]bool{}
+			consumed := runner.run(context.Background(), config, func(consumer, item int) {
+				lock.Lock()
+				defer lock.Unlock()
+				if seen[item] {
+					t.Errorf("Item %d consumed twice", item)
+				}
+				seen[item] = true
+			})
+			if consumed != 300 || len(seen) != 300 {
+				t.Errorf("Expected 300 items consumed, got %d, %d distinct", consumed, len(seen))
+			}
+		})
+	}
+}
+
+func TestProducerConsumerDrainsOnCancel(t *testing.T) {
+	config := ProducerConsumerConfig{Producers: 2, Consumers: 1, BufferSize: 5, Items: 1000}
+	for _, runner := range producerConsumerRunners {
+		t.Run(runner.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			count := 0
+			consumed := runner.run(ctx, config, func(consumer, item int) {
+				count++
+				if count == 10 {
+					cancel()
+				}
+			})
+			// Up to a full buffer and an item of each producer may arrive after cancelling
+			if consumed < 10 || consumed > 10+config.BufferSize+config.Producers {
+				t.Errorf("Expected producers to stop and the buffer to be drained, consumed %d", consumed)
+			}
+		})
+	}
+}
+
+func BenchmarkProducerConsumer(b *testing.B) {
+	configs := []struct {
+		name   string
+		config ProducerConsumerConfig
+	}{
+		{"1x1/buffer1", ProducerConsumerConfig{Producers: 1, Consumers: 1, BufferSize: 1}},
+		{"4x4/buffer16", ProducerConsumerConfig{Producers: 4, Consumers: 4, BufferSize: 16}},
+		{"8x2/buffer256", ProducerConsumerConfig{Producers: 8, Consumers: 2, BufferSize: 256}},
+	}
+	for _, runner := range producerConsumerRunners {
+		for _, c := range configs {
+			b.Run(runner.name+"/"+c.name, func(b *testing.B) {
+				config := c.config
+				// Every iteration is one item passed from a producer to a consumer
+				config.Items = b.N/config.Producers + 1
+				runner.run(context.Background(), config, func(consumer, item int) {})
+			})
+		}
+	}
+}
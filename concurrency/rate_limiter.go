@@ -0,0 +1,90 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket: tokens are added at a steady rate up to burst,
+// every event takes one. Bursts up to its size pass at once, then events are
+// spread out to the rate.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64 // Negative while waiters reserved tokens not added yet
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewRateLimiter allows perSecond events on average, perSecond must be positive
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Allow reports whether an event may happen now, taking a token if so
+func (l *RateLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *RateLimiter) AllowN(count int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill()
+	if l.tokens < float64(count) {
+		return false
+	}
+	l.tokens -= float64(count)
+	return true
+}
+
+// Wait blocks until an event may happen or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN reserves count tokens and waits until they are added. When ctx ends
+// first, the reservation is returned for other callers.
+func (l *RateLimiter) WaitN(ctx context.Context, count int) error {
+	if float64(count) > l.burst {
+		return fmt.Errorf("cannot wait for %d tokens, the burst is %v", count, l.burst)
+	}
+	l.mutex.Lock()
+	l.refill()
+	l.tokens -= float64(count)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mutex.Lock()
+		l.refill()
+		l.tokens = min(l.tokens+float64(count), l.burst)
+		l.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *RateLimiter) refill() {
+	now := l.now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+	l.tokens = min(l.tokens+elapsed*l.rate, l.burst)
+}
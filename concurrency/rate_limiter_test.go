@@ -0,0 +1,66 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(2, 3)
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if !limiter.Allow() {
+			t.Fatalf("Expected the burst to pass, event %d was denied", i)
+		}
+	}
+	if limiter.Allow() {
+		t.Error("Expected the bucket to be empty after the burst")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.Allow() || limiter.Allow() {
+		t.Error("Expected one token after half a second at 2 per second")
+	}
+	now = now.Add(time.Hour)
+	if !limiter.AllowN(3) || limiter.AllowN(1) {
+		t.Error("Expected the bucket to refill only up to the burst")
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+	start := time.Now()
+	for range 6 {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	// The first event passes at once, the next 5 come every 10ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the events spread to the rate, took %v", elapsed)
+	}
+	if err := limiter.WaitN(context.Background(), 2); err == nil {
+		t.Error("Expected waiting for more than the burst to fail")
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	limiter.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Wait to give up, got %v", err)
+	}
+	// The reservation was returned, so it doesn't delay the next caller
+	limiter.mutex.Lock()
+	tokens := limiter.tokens
+	limiter.mutex.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("Expected the cancelled reservation to be returned, %v tokens left", tokens)
+	}
+}
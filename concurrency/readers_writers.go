@@ -0,0 +1,199 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RWLocker is satisfied by sync.RWMutex and the locks below
+type RWLocker interface {
+	RLock()
+	RUnlock()
+	Lock()
+	Unlock()
+}
+
+// ReaderPreferringLock lets readers in whenever another reader is inside. While
+// their reads overlap the room never empties and writers starve.
+type ReaderPreferringLock struct {
+	mutex     sync.Mutex // Guards readers
+	readers   int
+	roomEmpty sync.Mutex // Held by a writer, or by the readers together
+}
+
+func (l *ReaderPreferringLock) RLock() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.readers++
+	if l.readers == 1 {
+		l.roomEmpty.Lock()
+	}
+}
+
+func (l *ReaderPreferringLock) RUnlock() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.readers--
+	if l.readers == 0 {
+		l.roomEmpty.Unlock()
+	}
+}
+
+func (l *ReaderPreferringLock) Lock() {
+	l.roomEmpty.Lock()
+}
+
+func (l *ReaderPreferringLock) Unlock() {
+	l.roomEmpty.Unlock()
+}
+
+// FairRWLock adds a turnstile in front of the room. A waiting writer holds it, so
+// readers arriving later queue behind the writer instead of overtaking it.
+type FairRWLock struct {
+	turnstile sync.Mutex
+	room      ReaderPreferringLock
+}
+
+func (l *FairRWLock) RLock() {
+	l.turnstile.Lock()
+	l.turnstile.Unlock()
+	l.room.RLock()
+}
+
+func (l *FairRWLock) RUnlock() {
+	l.room.RUnlock()
+}
+
+func (l *FairRWLock) Lock() {
+	l.turnstile.Lock()
+	l.room.Lock()
+}
+
+func (l *FairRWLock) Unlock() {
+	l.turnstile.Unlock()
+	l.room.Unlock()
+}
+
+type ReadersWritersConfig struct {
+	Readers   int
+	Writers   int
+	ReadTime  time.Duration // The lock is held for on average
+	WriteTime time.Duration
+	ThinkTime time.Duration // Between two accesses of a reader or writer on average
+	Duration  time.Duration
+}
+
+type ReadersWritersStats struct {
+	Reads            int
+	Writes           int
+	AverageReadWait  time.Duration
+	AverageWriteWait time.Duration
+	MaxReadWait      time.Duration
+	MaxWriteWait     time.Duration
+}
+
+func (s ReadersWritersStats) String() string {
+	return fmt.Sprintf("reads: %6d, wait avg %10v max %10v | writes: %4d, wait avg %10v max %10v",
+		s.Reads, s.AverageReadWait, s.MaxReadWait.Round(time.Microsecond),
+		s.Writes, s.AverageWriteWait, s.MaxWriteWait.Round(time.Microsecond))
+}
+
+// waits collects how long the accesses of one kind waited for the lock
+type waits struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+func (w *waits) add(other waits) {
+	w.count += other.count
+	w.total += other.total
+	w.max = max(w.max, other.max)
+}
+
+func (w *waits) average() time.Duration {
+	if w.count == 0 {
+		return 0
+	}
+	return (w.total / time.Duration(w.count)).Round(time.Microsecond)
+}
+
+// randomAround keeps the accesses from falling into lockstep, which would leave
+// gaps between reads for writers
+func randomAround(average time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(2*average) + 1))
+}
+
+// SimulateReadersWriters lets the readers and writers access a resource guarded
+// by lock until the configured duration passes
+func SimulateReadersWriters(lock RWLocker, config ReadersWritersConfig) ReadersWritersStats {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Duration)
+	defer cancel()
+
+	var statsLock sync.Mutex
+	var reads, writes waits
+	var wg sync.WaitGroup
+	access := func(total *waits, lock, unlock func(), hold time.Duration) {
+		defer wg.Done()
+		var own waits
+		for sleep(ctx, randomAround(config.ThinkTime)) {
+			start := time.Now()
+			lock()
+			wait := time.Since(start)
+			time.Sleep(randomAround(hold))
+			unlock()
+			own.add(waits{1, wait, wait})
+		}
+		statsLock.Lock()
+		defer statsLock.Unlock()
+		total.add(own)
+	}
+	for range config.Readers {
+		wg.Add(1)
+		go access(&reads, lock.RLock, lock.RUnlock, config.ReadTime)
+	}
+	for range config.Writers {
+		wg.Add(1)
+		go access(&writes, lock.Lock, lock.Unlock, config.WriteTime)
+	}
+	wg.Wait()
+
+	return ReadersWritersStats{
+		Reads:            reads.count,
+		Writes:           writes.count,
+		AverageReadWait:  reads.average(),
+		AverageWriteWait: writes.average(),
+		MaxReadWait:      reads.max,
+		MaxWriteWait:     writes.max,
+	}
+}
+
+// ReadersWriters compares the locks for a read heavy and a balanced mix
+func ReadersWriters() {
+	locks := []struct {
+		name string
+		new  func() RWLocker
+	}{
+		{"reader preferring", func() RWLocker { return &ReaderPreferringLock{} }},
+		{"fair", func() RWLocker { return &FairRWLock{} }},
+		{"sync.RWMutex", func() RWLocker { return &sync.RWMutex{} }},
+	}
+	configs := []struct {
+		name   string
+		config ReadersWritersConfig
+	}{
+		{"10 readers, 2 writers", ReadersWritersConfig{Readers: 10, Writers: 2, ReadTime: 5 * time.Millisecond,
+			WriteTime: time.Millisecond, ThinkTime: time.Millisecond, Duration: 2 * time.Second}},
+		{"3 readers, 3 writers", ReadersWritersConfig{Readers: 3, Writers: 3, ReadTime: time.Millisecond,
+			WriteTime: time.Millisecond, ThinkTime: time.Millisecond, Duration: 2 * time.Second}},
+	}
+	for _, c := range configs {
+		fmt.Printf("\n%s\n", c.name)
+		for _, l := range locks {
+			fmt.Printf("%-18s %v\n", l.name, SimulateReadersWriters(l.new(), c.config))
+		}
+	}
+}
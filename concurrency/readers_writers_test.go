@@ -0,0 +1,66 @@
+package concurrency
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRWLocksExcludeWriters(t *testing.T) {
+	locks := map[string]RWLocker{
+		"reader preferring": &ReaderPreferringLock{},
+		"fair":              &FairRWLock{},
+	}
+	for name, lock := range locks {
+		t.Run(name, func(t *testing.T) {
+			var readers, writers, maxReaders atomic.Int32
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 50 {
+						if i%4 == 0 {
+							lock.Lock()
+							if writers.Add(1) != 1 || readers.Load() != 0 {
+								t.Error("Expected a writer alone inside")
+							}
+							writers.Add(-1)
+							lock.Unlock()
+							continue
+						}
+						lock.RLock()
+						current := readers.Add(1)
+						if writers.Load() != 0 {
+							t.Error("Expected no writer inside with a reader")
+						}
+						if current > maxReaders.Load() {
+							maxReaders.Store(current)
+						}
+						time.Sleep(10 * time.Microsecond)
+						readers.Add(-1)
+						lock.RUnlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if maxReaders.Load() < 2 {
+				t.Error("Expected readers to share the lock")
+			}
+		})
+	}
+}
+
+func TestFairRWLockLetsWritersIn(t *testing.T) {
+	// Overlapping reads starve the writers of a reader preferring lock
+	config := ReadersWritersConfig{Readers: 10, Writers: 2, ReadTime: 5 * time.Millisecond,
+		WriteTime: time.Millisecond, ThinkTime: time.Millisecond, Duration: 300 * time.Millisecond}
+	stats := SimulateReadersWriters(&FairRWLock{}, config)
+	if stats.Reads == 0 || stats.Writes < 10 {
+		t.Errorf("Expected readers and writers to take turns, got %v", stats)
+	}
+	if stats.MaxWriteWait > config.Duration/2 {
+		t.Errorf("Expected no writer to wait long, got %v", stats)
+	}
+}
@@ -0,0 +1,105 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WeightedSemaphore hands out up to size units, a caller may take several at once.
+// Waiters are served in order, so a large request isn't starved by small ones.
+type WeightedSemaphore struct {
+	mutex   sync.Mutex
+	size    int64
+	used    int64
+	waiters list.List // *semaphoreWaiter
+}
+
+type semaphoreWaiter struct {
+	units int64
+	ready chan struct{} // Closed once the units were acquired for the waiter
+}
+
+func NewWeightedSemaphore(size int64) *WeightedSemaphore {
+	return &WeightedSemaphore{size: size}
+}
+
+// Acquire waits until units are available or ctx is done
+func (s *WeightedSemaphore) Acquire(ctx context.Context, units int64) error {
+	s.mutex.Lock()
+	if units > s.size {
+		s.mutex.Unlock()
+		return fmt.Errorf("cannot acquire %d units of a semaphore of size %d", units, s.size)
+	}
+	if s.size-s.used >= units && s.waiters.Len() == 0 {
+		s.used += units
+		s.mutex.Unlock()
+		return nil
+	}
+	waiter := &semaphoreWaiter{units: units, ready: make(chan struct{})}
+	element := s.waiters.PushBack(waiter)
+	s.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		select {
+		case <-waiter.ready:
+			// Acquired meanwhile, give the units back
+			s.used -= units
+			s.notifyWaiters()
+		default:
+			isFirst := s.waiters.Front() == element
+			s.waiters.Remove(element)
+			if isFirst {
+				// The waiters behind may fit now
+				s.notifyWaiters()
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes units only if they are available right away
+func (s *WeightedSemaphore) TryAcquire(units int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.size-s.used >= units && s.waiters.Len() == 0 {
+		s.used += units
+		return true
+	}
+	return false
+}
+
+func (s *WeightedSemaphore) Release(units int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.used -= units
+	if s.used < 0 {
+		panic("semaphore released more units than acquired")
+	}
+	s.notifyWaiters()
+}
+
+// Used returns the units acquired at the moment
+func (s *WeightedSemaphore) Used() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.used
+}
+
+func (s *WeightedSemaphore) notifyWaiters() {
+	for element := s.waiters.Front(); element != nil; element = s.waiters.Front() {
+		waiter := element.Value.(*semaphoreWaiter)
+		if s.size-s.used < waiter.units {
+			return
+		}
+		s.used += waiter.units
+		s.waiters.Remove(element)
+		close(waiter.ready)
+	}
+}
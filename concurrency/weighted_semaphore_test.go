@@ -0,0 +1,99 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWeightedSemaphoreLimitsUnits(t *testing.T) {
+	semaphore := NewWeightedSemaphore(5)
+	var used, maxUsed atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 20 {
+		units := int64(i%3 + 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := semaphore.Acquire(context.Background(), units); err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			current := used.Add(units)
+			for {
+				previous := maxUsed.Load()
+				if current <= previous || maxUsed.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			used.Add(-units)
+			semaphore.Release(units)
+		}()
+	}
+	wg.Wait()
+	if maxUsed.Load() > 5 || semaphore.Used() != 0 {
+		t.Errorf("Expected at most 5 units at once and all released, got %d, %d", maxUsed.Load(), semaphore.Used())
+	}
+}
+
+func TestWeightedSemaphoreTryAcquire(t *testing.T) {
+	semaphore := NewWeightedSemaphore(3)
+	if !semaphore.TryAcquire(2) || semaphore.TryAcquire(2) || !semaphore.TryAcquire(1) {
+		t.Error("Expected TryAcquire to take only the available units")
+	}
+	semaphore.Release(3)
+	if err := semaphore.Acquire(context.Background(), 4); err == nil {
+		t.Error("Expected acquiring more than the size to fail")
+	}
+}
+
+func TestWeightedSemaphoreServesWaitersInOrder(t *testing.T) {
+	semaphore := NewWeightedSemaphore(3)
+	semaphore.Acquire(context.Background(), 2)
+	large := make(chan struct{})
+	go func() {
+		semaphore.Acquire(context.Background(), 3)
+		close(large)
+	}()
+	for waiting := 0; waiting == 0; time.Sleep(time.Millisecond) {
+		semaphore.mutex.Lock()
+		waiting = semaphore.waiters.Len()
+		semaphore.mutex.Unlock()
+	}
+	if semaphore.TryAcquire(1) {
+		t.Fatal("Expected a small request not to overtake the waiting large one")
+	}
+	semaphore.Release(2)
+	select {
+	case <-large:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the large request to be served")
+	}
+}
+
+func TestWeightedSemaphoreAcquireCancelled(t *testing.T) {
+	semaphore := NewWeightedSemaphore(2)
+	semaphore.Acquire(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := semaphore.Acquire(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Acquire to give up, got %v", err)
+	}
+	// The cancelled waiter no longer holds up the smaller one behind it
+	if !semaphore.TryAcquire(1) {
+		t.Error("Expected the free unit to be available")
+	}
+}
+
+func TestWeightedSemaphoreReleaseTooMuch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected releasing units not acquired to panic")
+		}
+	}()
+	NewWeightedSemaphore(1).Release(1)
+}
@@ -2,73 +2,138 @@ package examples
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
 )
 
+type CatOptions struct {
+	NumberLines         bool // -n
+	NumberNonEmptyLines bool // -nb, takes precedence over -n
+	ShowEnds            bool // -E, marks line ends with $
+	SqueezeBlank        bool // -s, prints repeated empty lines once
+}
+
 func Cat() {
-	numberLines := flag.Bool("n", false, "Number lines")
-	numberNonEmptyLines := flag.Bool("nb", false, "Number non empty lines")
-	flag.Parse()
-	paths := flag.Args()
+	os.Exit(RunCat(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	if len(paths) == 0 || (*numberLines && *numberNonEmptyLines) {
-		fmt.Println("Usage: cat [-n|-nb] path, path ...")
-		os.Exit(0)
+// RunCat concatenates the files named in args, or stdin when there are none
+// or the name is "-", and returns the exit code: 0 on success, 1 if any file
+// failed and 2 for invalid flags. Line numbers continue across files.
+func RunCat(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cat [-n|-nb] [-E] [-s] [path ...]")
+		flags.PrintDefaults()
+	}
+	options := CatOptions{}
+	flags.BoolVar(&options.NumberLines, "n", false, "Number lines")
+	flags.BoolVar(&options.NumberNonEmptyLines, "nb", false, "Number non empty lines")
+	flags.BoolVar(&options.ShowEnds, "E", false, "Display $ at the end of each line")
+	flags.BoolVar(&options.SqueezeBlank, "s", false, "Suppress repeated empty lines")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
 	}
 
-	printerFn := printerFactory(*numberLines, *numberNonEmptyLines)
-
+	output := bufio.NewWriter(stdout)
+	printer := newCatPrinter(output, options)
+	exitCode := 0
 	for _, path := range paths {
-		fmt.Printf("File: %s\n", path)
-		if err := cat(path, printerFn); err != nil {
-			log.Fatal("Error reading path: ", path)
+		if err := catPath(path, stdin, printer); err != nil {
+			var pathErr *os.PathError
+			if !errors.As(err, &pathErr) {
+				err = fmt.Errorf("%s: %w", path, err)
+			}
+			output.Flush()
+			fmt.Fprintf(stderr, "cat: %v\n", err)
+			exitCode = 1
 		}
 	}
+	return flushCommand(output, stderr, "cat", exitCode)
 }
 
-type printer = func(int, string)
+func catPath(path string, stdin io.Reader, printer *catPrinter) error {
+	if path == "-" {
+		return printer.print(stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return printer.print(file)
+}
 
-func printerFactory(numberLines, numberNonEmptyLines bool) (printerFn printer) {
-	switch {
-	case numberLines:
-		printerFn = func(lineNumber int, line string) {
-			fmt.Printf("%6d: %s\n", lineNumber, line)
-		}
-	case numberNonEmptyLines:
-		printerFn = func(lineNumber int, line string) {
-			if line != "" {
-				fmt.Printf("%6d: %s\n", lineNumber, line)
-			} else {
-				fmt.Println(line)
+// catPrinter keeps the state carried from one file to the next: the line
+// number, whether the last line was empty and whether it was finished
+type catPrinter struct {
+	output     *bufio.Writer
+	options    CatOptions
+	lineNumber int
+	lastEmpty  bool
+	midLine    bool
+}
+
+func newCatPrinter(output *bufio.Writer, options CatOptions) *catPrinter {
+	return &catPrinter{output: output, options: options}
+}
+
+// print copies the input a buffer at a time, so lines longer than the buffer
+// are written in parts and only the first part gets a number
+func (p *catPrinter) print(input io.Reader) error {
+	reader := bufio.NewReader(input)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if writeErr := p.write(chunk); writeErr != nil {
+				return writeErr
 			}
 		}
-	default:
-		printerFn = func(lineNumber int, line string) {
-			fmt.Println(line)
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
 		}
 	}
-	return printerFn
 }
 
-func cat(path string, printerFn printer) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer func(file *os.File) {
-		if file.Close() != nil {
-			log.Fatal("Error closing file: ", file)
+func (p *catPrinter) write(chunk []byte) error {
+	if !p.midLine {
+		empty := chunk[0] == '\n'
+		if empty && p.lastEmpty && p.options.SqueezeBlank {
+			return nil
+		}
+		p.lastEmpty = empty
+		if p.options.NumberNonEmptyLines && !empty || p.options.NumberLines && !p.options.NumberNonEmptyLines {
+			p.lineNumber++
+			fmt.Fprintf(p.output, "%6d: ", p.lineNumber)
 		}
-	}(file)
-	scanner := bufio.NewScanner(file)
-	lineNumber := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNumber++
-		printerFn(lineNumber, line)
 	}
-	return nil
+	line, ended := chunk, chunk[len(chunk)-1] == '\n'
+	if ended {
+		line = chunk[:len(chunk)-1]
+	}
+	p.output.Write(line)
+	p.midLine = !ended
+	if !ended {
+		return nil
+	}
+	if p.options.ShowEnds {
+		p.output.WriteByte('$')
+	}
+	return p.output.WriteByte('\n')
 }
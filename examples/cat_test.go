@@ -0,0 +1,73 @@
+package examples
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCat(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	os.WriteFile(first, []byte("a\n\n\n\nb\n"), 0644)
+	os.WriteFile(second, []byte("c\nno newline"), 0644)
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{"Plain", []string{first, second}, "", "a\n\n\n\nb\nc\nno newline"},
+		{"Stdin", nil, "x\ny\n", "x\ny\n"},
+		{"Stdin between files", []string{second, "-", first}, "x\n", "c\nno newlinex\na\n\n\n\nb\n"},
+		{"Number lines across files", []string{"-n", first, second}, "",
+			"     1: a\n     2: \n     3: \n     4: \n     5: b\n     6: c\n     7: no newline"},
+		{"Number non empty lines", []string{"-nb", first}, "", "     1: a\n\n\n\n     2: b\n"},
+		{"Non empty numbering wins", []string{"-n", "-nb", first}, "", "     1: a\n\n\n\n     2: b\n"},
+		{"Show ends", []string{"-E", second}, "", "c$\nno newline"},
+		{"Squeeze blank", []string{"-s", "-n", "-E", first}, "", "     1: a$\n     2: $\n     3: b$\n"},
+		{"Squeeze across files", []string{"-s", "-", first}, "\n\n", "\na\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunCat(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != 0 || stdout.String() != tt.expected {
+				t.Errorf("RunCat(%v) = %d, %q; want 0, %q (stderr %q)", tt.args, code, stdout.String(), tt.expected, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunCatLongLines(t *testing.T) {
+	line := strings.Repeat("x", 100000)
+	var stdout, stderr bytes.Buffer
+	if code := RunCat([]string{"-n", "-E"}, strings.NewReader(line+"\n"+line), &stdout, &stderr); code != 0 {
+		t.Fatalf("RunCat failed with %d: %s", code, stderr.String())
+	}
+	if expected := "     1: " + line + "$\n     2: " + line; stdout.String() != expected {
+		t.Errorf("Expected long lines numbered once, got %d bytes", stdout.Len())
+	}
+}
+
+func TestRunCatReportsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(path, []byte("content\n"), 0644)
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	var stdout, stderr bytes.Buffer
+	code := RunCat([]string{missing, path}, strings.NewReader(""), &stdout, &stderr)
+	if code != 1 || stdout.String() != "content\n" || !strings.Contains(stderr.String(), "cat: open "+missing) {
+		t.Errorf("Expected the missing file reported and the rest printed, got %d, %q, %q", code, stdout.String(), stderr.String())
+	}
+	if code := RunCat([]string{"-x"}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("Expected an unknown flag to exit with 2, got %d", code)
+	}
+	if code := RunCat([]string{"-h"}, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Errorf("Expected help to exit with 0, got %d", code)
+	}
+}
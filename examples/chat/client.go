@@ -2,55 +2,103 @@ package chat
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"training.pl/go/common"
+	"training.pl/go/common/codec"
 )
 
+// Client connects to the chat server at address, Ctrl-C leaves the chat
 func Client(address string) {
-	connection, err := net.Dial("tcp", address)
-	if err != nil {
-		panic(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := RunClient(ctx, address, os.Stdin, os.Stdout); err != nil {
+		log.Println("Chat client failed: " + err.Error())
 	}
+}
 
-	go listenForMessages(connection)
-
-	scanner := bufio.NewScanner(os.Stdin)
+// RunClient sends the lines of input to the server, the first one being the
+// nickname, and writes the received messages to output. It returns when ctx
+// is done, input ends or the server disconnects. A read of input in progress
+// can't be interrupted and is abandoned, which is fine for stdin of a process
+// about to exit.
+func RunClient(ctx context.Context, address string, input io.Reader, output io.Writer) error {
+	var dialer net.Dialer
+	connection, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
 
-	for scanner.Scan() {
-		text := scanner.Text()
-		textBytes, err := common.ToBytes(text)
-		if err != nil {
-			log.Println("Error converting text to bytes")
-			continue
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
 		}
-		if len(textBytes) > bufferSize {
-			log.Println("Message to long")
-			continue
-		}
-		log.Printf("Sending message %d bytes long", len(textBytes))
-		messageBytes := make([]byte, bufferSize)
-		copy(messageBytes, textBytes)
-		if _, err = connection.Write(messageBytes); err != nil {
-			log.Println("Error sending message")
-			continue
+	}()
+	fmt.Fprint(output, "Nickname: ")
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		listenForMessages(connection, output)
+	}()
+
+	for {
+		select {
+		case text, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			err := sendText(connection, text)
+			if errors.Is(err, codec.ErrTooLarge) {
+				log.Println("Message to long")
+				continue
+			}
+			if err != nil {
+				log.Println("Error sending message")
+				continue
+			}
+		case <-disconnected:
+			// The server refuses a nickname by disconnecting
+			return nil
+		case <-ctx.Done():
+			// Closing the connection is what tells the server the client left
+			connection.Close()
+			<-disconnected
+			fmt.Fprintln(output, "Bye")
+			return nil
 		}
 	}
 }
 
-func listenForMessages(connection net.Conn) {
-	messageBytes := make([]byte, bufferSize)
+func listenForMessages(connection net.Conn, output io.Writer) {
+	reader := bufio.NewReader(connection)
 	for {
-		_, err := connection.Read(messageBytes)
+		text, err := receiveText(reader)
+		if errors.Is(err, ErrMessageTooLarge) || errors.Is(err, errInvalidMessage) {
+			log.Println("Skipping message: " + err.Error())
+			continue
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
-			log.Println("Error reading message")
-			break
+			fmt.Fprintln(output, "Disconnected")
+			return
 		}
-		var text string
-		err = common.FromBytes(messageBytes, &text)
-		fmt.Println(text)
+		fmt.Fprintln(output, text)
 	}
 }
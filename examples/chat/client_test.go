@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestRunClient(t *testing.T) {
+	address := startServer(t)
+	anna := connect(t, address, "anna")
+	defer anna.connection.Close()
+	anna.expect("Welcome anna, 1 online")
+
+	input, typing := io.Pipe()
+	received, output := io.Pipe()
+	lines := bufio.NewReader(received)
+	expect := func(expected string) {
+		t.Helper()
+		if line, err := lines.ReadString('\n'); err != nil || line != expected+"\n" {
+			t.Fatalf("Client printed %q, %v; want %q", line, err, expected)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- RunClient(ctx, address, input, output) }()
+
+	io.WriteString(typing, "ola\n")
+	expect("Nickname: Welcome ola, 2 online")
+	anna.expect("* ola joined")
+	io.WriteString(typing, "hello\n")
+	anna.expect("ola: hello")
+	anna.send("hi ola")
+	expect("anna: hi ola")
+
+	// Like Ctrl-C, while the client waits for the next line
+	cancel()
+	expect("Bye")
+	if err := <-done; err != nil {
+		t.Errorf("RunClient failed: %v", err)
+	}
+	anna.expect("* ola left")
+}
+
+func TestRunClientRefusedNickname(t *testing.T) {
+	address := startServer(t)
+	input, typing := io.Pipe()
+	received, output := io.Pipe()
+	done := make(chan error)
+	go func() { done <- RunClient(context.Background(), address, input, output) }()
+
+	io.WriteString(typing, "two words\n")
+	lines := bufio.NewReader(received)
+	for _, expected := range []string{"Nickname: Refused: nickname may not contain spaces\n", "Disconnected\n"} {
+		if line, err := lines.ReadString('\n'); err != nil || line != expected {
+			t.Fatalf("Client printed %q, %v; want %q", line, err, expected)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Errorf("RunClient failed: %v", err)
+	}
+}
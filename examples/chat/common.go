@@ -1,10 +1,85 @@
 package chat
 
-import "net"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
 
-const bufferSize = 128
+	"training.pl/go/common/codec"
+)
 
-type message struct {
-	sender net.Conn
-	bytes  []byte
+const (
+	maxMessageSize    = 1024 // Encoded message without its length prefix
+	maxConnections    = 100  // Default limit of clients at once, see Serve
+	subscriberBuffer  = 100  // Messages waiting for a slow client, one more disconnects it
+	maxNicknameLength = 20
+	handshakeTimeout  = time.Minute     // For sending the nickname after connecting
+	writeTimeout      = 5 * time.Second // A client that doesn't read for longer is disconnected
+)
+
+var (
+	ErrMessageTooLarge = errors.New("message too large")
+	errInvalidMessage  = errors.New("invalid message")
+)
+
+// sendText writes a framed gob encoded string, all messages are such strings
+// and the first one a client sends is its nickname
+func sendText(writer io.Writer, text string) error {
+	bytes, err := codec.Encode(text, codec.WithMaxSize(maxMessageSize))
+	if err != nil {
+		return err
+	}
+	return writeMessage(writer, bytes)
+}
+
+func receiveText(reader io.Reader) (string, error) {
+	bytes, err := readMessage(reader)
+	if err != nil {
+		return "", err
+	}
+	text, err := codec.Decode[string](bytes)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	return text, nil
+}
+
+// writeMessage writes a message prefixed with its length as 4 bytes in big
+// endian order, with a single Write. TCP delivers a stream of bytes, a single
+// Read may return part of a message or parts of two, the prefix marks where
+// one message ends and the next begins.
+func writeMessage(writer io.Writer, payload []byte) error {
+	if len(payload) > maxMessageSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, len(payload), maxMessageSize)
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := writer.Write(frame)
+	return err
+}
+
+// readMessage reads a framed message, however many reads it takes. An
+// oversized message is skipped, so the stream stays in sync for the next one.
+func readMessage(reader io.Reader) ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[:])
+	if size > maxMessageSize {
+		if _, err := io.CopyN(io.Discard, reader, int64(size)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, size, maxMessageSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return payload, nil
 }
@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMessagesSurvivePartialReads(t *testing.T) {
+	var stream bytes.Buffer
+	sent := []string{"hello", "", "zażółć gęślą jaźń", strings.Repeat("x", maxMessageSize)}
+	for _, text := range sent {
+		if err := writeMessage(&stream, []byte(text)); err != nil {
+			t.Fatalf("writeMessage failed: %v", err)
+		}
+	}
+
+	// Every read returns a single byte, as a slow network might
+	reader := iotest.OneByteReader(&stream)
+	for _, expected := range sent {
+		payload, err := readMessage(reader)
+		if err != nil || string(payload) != expected {
+			t.Fatalf("readMessage() = %q, %v; want %q", payload, err, expected)
+		}
+	}
+	if _, err := readMessage(reader); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last message, got %v", err)
+	}
+}
+
+func TestOversizedMessagesAreSkipped(t *testing.T) {
+	if err := writeMessage(io.Discard, make([]byte, maxMessageSize+1)); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected writing an oversized message to fail, got %v", err)
+	}
+
+	var stream bytes.Buffer
+	binary.Write(&stream, binary.BigEndian, uint32(maxMessageSize+1))
+	stream.Write(make([]byte, maxMessageSize+1))
+	writeMessage(&stream, []byte("next"))
+	if _, err := readMessage(&stream); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+	if payload, err := readMessage(&stream); err != nil || string(payload) != "next" {
+		t.Errorf("Expected the message after the oversized one, got %q, %v", payload, err)
+	}
+}
+
+func TestTruncatedMessage(t *testing.T) {
+	var stream bytes.Buffer
+	writeMessage(&stream, []byte("hello"))
+	if _, err := readMessage(bytes.NewReader(stream.Bytes()[:6])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := readMessage(bytes.NewReader(stream.Bytes()[:4])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a missing payload, got %v", err)
+	}
+}
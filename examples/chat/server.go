@@ -1,77 +1,288 @@
 package chat
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
-)
+	"syscall"
+	"time"
+	"unicode"
 
-var messages = make(chan *message, 1000)
-var connections = make([]net.Conn, 0)
-var mutex = &sync.RWMutex{}
+	"training.pl/go/common/bus"
+	"training.pl/go/common/codec"
+	"training.pl/go/concurrency"
+)
 
+// Server runs a chat server on address until Ctrl-C
 func Server(address string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := ListenAndServe(ctx, address, maxConnections); err != nil {
+		log.Println("Chat server failed: " + err.Error())
+	}
+}
+
+func ListenAndServe(ctx context.Context, address string, limit int) error {
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer func() {
-		err := listener.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
+	log.Println("Listening on: " + address)
+	return Serve(ctx, listener, limit)
+}
 
-	go messageHandler()
+// topicAll is the topic of the bus every joined client subscribes to
+const topicAll = "all"
 
-	log.Println("Listening on: " + address)
+// chatServer sends messages through a bus, every joined client has a
+// subscription and a goroutine writing what it receives. Each client gets the
+// messages of a sender in the order they were sent, but the messages of
+// different senders may reach different clients in different orders.
+type chatServer struct {
+	bus         *bus.Bus[[]byte]
+	connections map[net.Conn]string // Nicknames of the clients, empty until they join
+	mutex       sync.RWMutex
+	closing     bool // Once set, handlers leave closing the connections to Serve
+	slots       *concurrency.WeightedSemaphore
+	writers     sync.WaitGroup
+}
 
+// Serve handles the clients accepted by listener until ctx is done, at most
+// limit at once, further ones are refused. On shutdown it closes the listener,
+// tells the clients, delivers the messages still queued and then disconnects them.
+func Serve(ctx context.Context, listener net.Listener, limit int) error {
+	if limit < 1 {
+		return fmt.Errorf("invalid connections limit %d", limit)
+	}
+	s := &chatServer{
+		bus:         bus.New[[]byte](),
+		connections: make(map[net.Conn]string),
+		slots:       concurrency.NewWeightedSemaphore(int64(limit)),
+	}
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	s.accept(listener, concurrency.NewPool(limit, 0))
+
+	// No handler is left to publish, the writers send what is buffered and end
+	s.bus.Close()
+	s.writers.Wait()
+	s.mutex.Lock()
+	for connection := range s.connections {
+		connection.Close()
+	}
+	clear(s.connections)
+	s.mutex.Unlock()
+	log.Println("Server stopped")
+	return nil
+}
+
+// accept runs a handler for every client until the listener is closed and
+// then waits for the handlers to finish
+func (s *chatServer) accept(listener net.Listener, handlers *concurrency.Pool) {
 	for {
 		connection, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			break
+		}
 		if err != nil {
 			log.Println("Connection accept error: " + err.Error())
 			continue
 		}
-		log.Println("Client connected: ", connection.LocalAddr())
-		mutex.Lock()
-		connections = append(connections, connection)
-		go connectionHandler(connection, messages)
-		mutex.Unlock()
+		log.Println("Client connected: ", connection.RemoteAddr())
+		if !s.slots.TryAcquire(1) {
+			log.Println("Refusing client, the server is full: ", connection.RemoteAddr())
+			go refuse(connection, "Refused: the server is full")
+			continue
+		}
+		// Set before the connection is registered, so shutdown can't be overridden by it
+		connection.SetReadDeadline(time.Now().Add(handshakeTimeout))
+		s.mutex.Lock()
+		s.connections[connection] = ""
+		s.mutex.Unlock()
+		// Doesn't block for long, there are as many handlers as slots
+		handlers.Submit(func(context.Context) {
+			s.connectionHandler(connection)
+		})
 	}
 
-	// close(messages)
+	log.Println("Shutting down")
+	s.mutex.Lock()
+	s.closing = true
+	for connection := range s.connections {
+		// Wakes up the handlers waiting for the next message of a client
+		connection.SetReadDeadline(time.Now())
+	}
+	s.mutex.Unlock()
+	s.broadcast(nil, "* Server is shutting down")
+	handlers.Shutdown(context.Background())
 }
 
-func connectionHandler(connection net.Conn, messages chan<- *message) {
-	defer func() {
-		err := connection.Close()
-		if err != nil {
-			log.Println("Error closing connection: " + err.Error())
+func refuse(connection net.Conn, reason string) {
+	connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+	sendText(connection, reason)
+	connection.Close()
+}
+
+func (s *chatServer) connectionHandler(connection net.Conn) {
+	reader := bufio.NewReader(connection)
+	nickname, subscription, err := s.join(connection, reader)
+	if err != nil {
+		if !s.isClosing() {
+			log.Println("Handshake failed: " + err.Error())
 		}
-	}()
-	messageBytes := make([]byte, bufferSize)
+		s.leave(connection, "", nil)
+		return
+	}
+
 	for {
-		if _, err := connection.Read(messageBytes); err != nil {
-			log.Println("Error reading message")
+		text, err := receiveText(reader)
+		if errors.Is(err, ErrMessageTooLarge) || errors.Is(err, errInvalidMessage) {
+			log.Println("Skipping message: " + err.Error())
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			log.Println("Client disconnected: ", connection.RemoteAddr())
+			break
+		}
+		if err != nil {
+			if !s.isClosing() {
+				log.Println("Error reading message: " + err.Error())
+			}
 			break
 		}
-		messages <- &message{connection, messageBytes}
+		s.broadcast(subscription, fmt.Sprintf("%s: %s", nickname, text))
 	}
-
+	s.leave(connection, nickname, subscription)
 }
 
-func messageHandler() {
-	for message := range messages {
-		mutex.RLock()
-		for _, connection := range connections {
-			if connection == message.sender {
-				continue
+// join reads the nickname the client sends first, a taken or invalid one is
+// refused with a message and the connection is closed. A client that joined
+// gets a subscription and a writer.
+func (s *chatServer) join(connection net.Conn, reader io.Reader) (string, *bus.Subscription[[]byte], error) {
+	nickname, err := receiveText(reader)
+	if err != nil {
+		return "", nil, err
+	}
+	connection.SetReadDeadline(time.Time{})
+
+	nickname = strings.TrimSpace(nickname)
+	online := 1
+	if err = validateNickname(nickname); err == nil {
+		s.mutex.Lock()
+		for _, other := range s.connections {
+			if strings.EqualFold(other, nickname) {
+				err = fmt.Errorf("nickname %s is taken", nickname)
 			}
-			if _, err := connection.Write(message.bytes); err != nil {
-				log.Println("Error sending message")
-				continue
+			if other != "" {
+				online++
 			}
 		}
-		mutex.RUnlock()
+		if s.closing {
+			// The deadline set by the shutdown may have been cleared above
+			err = errors.New("the server is shutting down")
+		}
+		if err == nil {
+			s.connections[connection] = nickname
+		}
+		s.mutex.Unlock()
+	}
+	if err != nil {
+		connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+		sendText(connection, "Refused: "+err.Error())
+		return "", nil, err
+	}
+
+	// A client that can't keep up would hold up nobody but itself, it is disconnected instead
+	subscription := s.bus.Subscribe(bus.Options[[]byte]{Buffer: subscriberBuffer, Policy: bus.Disconnect}, topicAll)
+	s.writers.Add(1)
+	go func() {
+		defer s.writers.Done()
+		writeMessages(connection, subscription)
+	}()
+	subscription.Deliver(context.Background(), encodeText(fmt.Sprintf("Welcome %s, %d online", nickname, online)))
+	s.broadcast(subscription, fmt.Sprintf("* %s joined", nickname))
+	log.Printf("%s joined as %s", connection.RemoteAddr(), nickname)
+	return nickname, subscription, nil
+}
+
+func validateNickname(nickname string) error {
+	switch {
+	case nickname == "":
+		return errors.New("nickname is empty")
+	case len([]rune(nickname)) > maxNicknameLength:
+		return fmt.Errorf("nickname is longer than %d characters", maxNicknameLength)
+	case strings.ContainsFunc(nickname, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }):
+		return errors.New("nickname may not contain spaces")
+	}
+	return nil
+}
+
+// leave frees the slot of a client that left, ends its subscription, closes
+// its connection and tells the others, unless the server is shutting down and
+// disconnects everyone itself
+func (s *chatServer) leave(connection net.Conn, nickname string, subscription *bus.Subscription[[]byte]) {
+	s.mutex.Lock()
+	closing := s.closing
+	if !closing {
+		delete(s.connections, connection)
+	}
+	s.mutex.Unlock()
+	s.slots.Release(1)
+	if closing {
+		return
+	}
+	if subscription != nil {
+		subscription.Unsubscribe()
+	}
+	if err := connection.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Println("Error closing connection: " + err.Error())
+	}
+	if nickname != "" {
+		s.broadcast(subscription, fmt.Sprintf("* %s left", nickname))
+		log.Printf("%s left", nickname)
+	}
+}
+
+func (s *chatServer) isClosing() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.closing
+}
+
+// broadcast sends text to all the joined clients but the sender, nil for the server
+func (s *chatServer) broadcast(sender *bus.Subscription[[]byte], text string) {
+	if bytes := encodeText(text); bytes != nil {
+		s.bus.PublishExcept(context.Background(), topicAll, bytes, sender)
+	}
+}
+
+func encodeText(text string) []byte {
+	bytes, err := codec.Encode(text, codec.WithMaxSize(maxMessageSize))
+	if err != nil {
+		log.Println("Error encoding message: " + err.Error())
+	}
+	return bytes
+}
+
+// writeMessages writes what a client is sent until its subscription ends,
+// then closes the connection. A client that can't be written to in time is
+// disconnected too, its handler then sees the closed connection and cleans up.
+func writeMessages(connection net.Conn, subscription *bus.Subscription[[]byte]) {
+	defer connection.Close()
+	for bytes := range subscription.C() {
+		connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := writeMessage(connection, bytes); err != nil {
+			log.Println("Error sending message: " + err.Error())
+			return
+		}
 	}
 }
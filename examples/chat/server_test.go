@@ -0,0 +1,163 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClient struct {
+	t          *testing.T
+	connection net.Conn
+	reader     *bufio.Reader
+}
+
+func connect(t *testing.T, address, nickname string) *testClient {
+	t.Helper()
+	connection, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &testClient{t, connection, bufio.NewReader(connection)}
+	client.send(nickname)
+	return client
+}
+
+func (c *testClient) send(text string) {
+	c.t.Helper()
+	if err := sendText(c.connection, text); err != nil {
+		c.t.Fatalf("sendText failed: %v", err)
+	}
+}
+
+func (c *testClient) expect(expected string) {
+	c.t.Helper()
+	c.connection.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if text, err := receiveText(c.reader); err != nil || text != expected {
+		c.t.Fatalf("Received %q, %v; want %q", text, err, expected)
+	}
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	address, _ := startLimitedServer(t, maxConnections)
+	return address
+}
+
+// startLimitedServer returns the address of a server and a function stopping
+// it, which returns once Serve does
+func startLimitedServer(t *testing.T, limit int) (string, func() error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, listener, limit) }()
+	stop := sync.OnceValue(func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			return errors.New("server didn't stop in time")
+		}
+	})
+	t.Cleanup(func() { stop() })
+	return listener.Addr().String(), stop
+}
+
+func TestServerNicknamesAndNotifications(t *testing.T) {
+	address := startServer(t)
+
+	anna := connect(t, address, "anna")
+	defer anna.connection.Close()
+	anna.expect("Welcome anna, 1 online")
+
+	refused := connect(t, address, "Anna")
+	refused.expect("Refused: nickname Anna is taken")
+	if _, err := receiveText(refused.reader); err == nil {
+		t.Error("Expected a refused client to be disconnected")
+	}
+	invalid := connect(t, address, "jan kowalski")
+	invalid.expect("Refused: nickname may not contain spaces")
+
+	jan := connect(t, address, " jan ")
+	jan.expect("Welcome jan, 2 online")
+	anna.expect("* jan joined")
+
+	anna.send("hello")
+	jan.expect("anna: hello")
+	jan.send("hi")
+	anna.expect("jan: hi") // Anna's own message was not sent back to her
+
+	jan.connection.Close()
+	anna.expect("* jan left")
+	ola := connect(t, address, "jan")
+	defer ola.connection.Close()
+	ola.expect("Welcome jan, 2 online") // The nickname is free again
+}
+
+func TestServerRefusesClientsOverLimit(t *testing.T) {
+	address, _ := startLimitedServer(t, 2)
+	anna := connect(t, address, "anna")
+	defer anna.connection.Close()
+	anna.expect("Welcome anna, 1 online")
+	jan := connect(t, address, "jan")
+	jan.expect("Welcome jan, 2 online")
+	anna.expect("* jan joined")
+
+	refused := connect(t, address, "ola")
+	refused.expect("Refused: the server is full")
+	if _, err := receiveText(refused.reader); err == nil {
+		t.Error("Expected a refused client to be disconnected")
+	}
+
+	jan.connection.Close()
+	anna.expect("* jan left")
+	ola := connect(t, address, "ola")
+	defer ola.connection.Close()
+	ola.expect("Welcome ola, 2 online")
+}
+
+func TestServerShutdown(t *testing.T) {
+	address, stop := startLimitedServer(t, maxConnections)
+	anna := connect(t, address, "anna")
+	defer anna.connection.Close()
+	anna.expect("Welcome anna, 1 online")
+	jan := connect(t, address, "jan")
+	defer jan.connection.Close()
+	jan.expect("Welcome jan, 2 online")
+	anna.expect("* jan joined")
+	// Connected but not joined yet, it gets no messages and is disconnected
+	silent, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	jan.send("bye")
+	anna.expect("jan: bye")
+	if err := stop(); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	for _, client := range []*testClient{anna, jan} {
+		client.expect("* Server is shutting down")
+		if _, err := receiveText(client.reader); err != io.EOF {
+			t.Errorf("Expected the connection to be closed, got %v", err)
+		}
+	}
+	silent.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := silent.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+	if _, err := net.Dial("tcp", address); err == nil {
+		t.Error("Expected the listener to be closed")
+	}
+}
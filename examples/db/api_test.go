@@ -0,0 +1,64 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestListUsersPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openMemoryDb(t, NewMemoryBackend(), &Sequence{})
+	defer db.Close()
+	if err := CreateFieldIndex[User](db, "LastName"); err != nil {
+		t.Fatal(err)
+	}
+	users := NewCollection[User](db, "users")
+	for i := 0; i < 5; i++ {
+		users.Insert(ctx, User{"Jan", "Kowalski", int16(20 + i), true})
+	}
+	users.Insert(ctx, User{"Anna", "Nowak", 30, true})
+	router := newRouter(db)
+
+	tests := []struct {
+		url    string
+		status int
+		ages   []int16
+		total  int
+		next   string
+	}{
+		{"/users?limit=2", http.StatusOK, []int16{20, 21}, 6, "/users?limit=2&offset=2"},
+		{"/users?offset=4&limit=2", http.StatusOK, []int16{24, 30}, 6, ""},
+		{"/users?lastName=Kowalski&offset=3&limit=1", http.StatusOK, []int16{23}, 5, "/users?lastName=Kowalski&limit=1&offset=4"},
+		{"/users?lastName=Wiśniewski", http.StatusOK, []int16{}, 0, ""},
+		{"/users?offset=10", http.StatusOK, []int16{}, 6, ""},
+		{"/users?limit=0", http.StatusBadRequest, nil, 0, ""},
+		{"/users?offset=x", http.StatusBadRequest, nil, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if response.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, response.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var page UsersPage
+			if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			ages := []int16{}
+			for _, user := range page.Users {
+				ages = append(ages, user.Age)
+			}
+			if !slices.Equal(ages, tt.ages) || page.Total != tt.total || page.Next != tt.next {
+				t.Errorf("Got ages %v, total %d, next %q; want %v, %d, %q", ages, page.Total, page.Next, tt.ages, tt.total, tt.next)
+			}
+		})
+	}
+}
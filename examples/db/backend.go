@@ -0,0 +1,253 @@
+package db
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Backend stores the files of a database: the data, its state and the write-ahead
+// log. Databases use FileBackend unless opened WithBackend.
+type Backend interface {
+	// OpenFile opens the named file for reading and writing, creating it if needed
+	OpenFile(name string) (File, error)
+	// ReadFile fails with an error matching fs.ErrNotExist for a missing file
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	// Rename replaces newName with oldName atomically, open files keep their content
+	Rename(oldName, newName string) error
+	// Remove deletes the named file, a missing file is not an error
+	Remove(name string) error
+	Exists(name string) (bool, error)
+	// Lock keeps other databases from opening the named file until the lock is closed,
+	// it fails with ErrLocked while another one holds it
+	Lock(name string) (io.Closer, error)
+}
+
+// ErrLocked is returned by Open for a database already opened by another process
+var ErrLocked = errors.New("database is locked")
+
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Size() (int64, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// WithBackend stores the database files in backend instead of the file system
+func WithBackend(backend Backend) Option {
+	return func(d *Database) {
+		d.backend = backend
+	}
+}
+
+// FileBackend keeps the files of a database in the file system
+type FileBackend struct{}
+
+type osFile struct {
+	*os.File
+}
+
+func (f osFile) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (FileBackend) OpenFile(name string) (File, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return osFile{file}, nil
+}
+
+func (FileBackend) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (FileBackend) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0644)
+}
+
+func (FileBackend) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (FileBackend) Remove(name string) error {
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (FileBackend) Exists(name string) (bool, error) {
+	_, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (FileBackend) Lock(name string) (io.Closer, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// MemoryBackend keeps the files of a database in memory, e.g. for tests. Sync
+// does nothing, the content is lost with the backend.
+type MemoryBackend struct {
+	lock   sync.Mutex
+	files  map[string]*memoryFile
+	locked map[string]bool
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{files: make(map[string]*memoryFile), locked: make(map[string]bool)}
+}
+
+type memoryLock struct {
+	backend *MemoryBackend
+	name    string
+	once    sync.Once
+}
+
+type memoryFile struct {
+	lock sync.RWMutex
+	data []byte
+}
+
+func (b *MemoryBackend) OpenFile(name string) (File, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	file, exists := b.files[name]
+	if !exists {
+		file = &memoryFile{}
+		b.files[name] = file
+	}
+	return file, nil
+}
+
+func (b *MemoryBackend) ReadFile(name string) ([]byte, error) {
+	b.lock.Lock()
+	file, exists := b.files[name]
+	b.lock.Unlock()
+	if !exists {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	file.lock.RLock()
+	defer file.lock.RUnlock()
+	return append([]byte(nil), file.data...), nil
+}
+
+func (b *MemoryBackend) WriteFile(name string, data []byte) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.files[name] = &memoryFile{data: append([]byte(nil), data...)}
+	return nil
+}
+
+func (b *MemoryBackend) Rename(oldName, newName string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	file, exists := b.files[oldName]
+	if !exists {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	b.files[newName] = file
+	delete(b.files, oldName)
+	return nil
+}
+
+func (b *MemoryBackend) Remove(name string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.files, name)
+	return nil
+}
+
+func (b *MemoryBackend) Exists(name string) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	_, exists := b.files[name]
+	return exists, nil
+}
+
+func (b *MemoryBackend) Lock(name string) (io.Closer, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.locked[name] {
+		return nil, ErrLocked
+	}
+	b.locked[name] = true
+	return &memoryLock{backend: b, name: name}, nil
+}
+
+func (l *memoryLock) Close() error {
+	l.once.Do(func() {
+		l.backend.lock.Lock()
+		delete(l.backend.locked, l.name)
+		l.backend.lock.Unlock()
+	})
+	return nil
+}
+
+func (f *memoryFile) ReadAt(p []byte, offset int64) (int, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memoryFile) WriteAt(p []byte, offset int64) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if end := offset + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	return copy(f.data[offset:], p), nil
+}
+
+func (f *memoryFile) Size() (int64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return int64(len(f.data)), nil
+}
+
+func (f *memoryFile) Truncate(size int64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if size < int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	return nil
+}
+
+func (f *memoryFile) Sync() error {
+	return nil
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openMemoryDb(t *testing.T, backend *MemoryBackend, idGenerator IdGenerator) *Database {
+	t.Helper()
+	db, err := Open("users.db", idGenerator, WithBackend(backend))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go db.run()
+	return db
+}
+
+func TestMemoryBackend(t *testing.T) {
+	dir, _ := os.Getwd()
+	before, _ := os.ReadDir(dir)
+	backend := NewMemoryBackend()
+	db := openMemoryDb(t, backend, &Sequence{})
+	ids := createUsers(t, db, 4)
+	db.Delete(ctx, ids[0])
+	if result := db.Compact(); result.Error != nil {
+		t.Fatalf("Compact failed: %v", result.Error)
+	}
+	db.Update(ctx, ids[1], &User{"Anna", "Nowak", 30, false})
+	crash(db)
+
+	db = openMemoryDb(t, backend, &Sequence{})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, ids[1], &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Read after recovery = %v, %v", user, result.Error)
+	}
+	if result := db.Read(ctx, ids[0], &User{}); result.Error == nil {
+		t.Error("Expected the deleted record to stay deleted")
+	}
+	if exists, _ := backend.Exists("users.db" + walFileSuffix); !exists {
+		t.Error("Expected the write-ahead log in the backend")
+	}
+	if after, _ := os.ReadDir(dir); len(after) != len(before) {
+		t.Error("Expected no files to be created")
+	}
+}
+
+func TestOpenLockedDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		options []Option
+	}{
+		{"File", filepath.Join(t.TempDir(), "users.db"), nil},
+		{"Memory", "users.db", []Option{WithBackend(NewMemoryBackend())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(tt.path, &Sequence{}, tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go db.run()
+			if other, err := Open(tt.path, &Sequence{}, tt.options...); !errors.Is(err, ErrLocked) {
+				if err == nil {
+					other.closeFiles()
+				}
+				t.Errorf("Expected a second open to fail with ErrLocked, got %v", err)
+			}
+			db.Close()
+
+			db, err = Open(tt.path, &Sequence{}, tt.options...)
+			if err != nil {
+				t.Fatalf("Expected Close to release the lock, got %v", err)
+			}
+			db.closeFiles()
+		})
+	}
+}
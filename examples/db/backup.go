@@ -0,0 +1,176 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"training.pl/go/common/codec"
+)
+
+// A backup is a tar archive holding the state file and the data file
+const (
+	backupStateEntry = "state"
+	backupDataEntry  = "data"
+)
+
+// ErrInvalidBackup is returned by Restore for archives that are damaged or don't fit the database
+var ErrInvalidBackup = errors.New("invalid backup")
+
+type backupSource struct {
+	file  File
+	size  int64
+	state []byte
+}
+
+// Backup writes a consistent copy of the database to w. Only capturing the state
+// goes through the run loop, the data is streamed while requests are served.
+func (d *Database) Backup(w io.Writer) error {
+	var source backupSource
+	if result := d.execute(context.Background(), command{action: "backup", output: &source}); result.Error != nil {
+		return result.Error
+	}
+	return writeBackup(w, &source)
+}
+
+func writeBackup(w io.Writer, source *backupSource) error {
+	defer source.file.Close()
+	archive := tar.NewWriter(w)
+	if err := writeBackupEntry(archive, backupStateEntry, bytes.NewReader(source.state), int64(len(source.state))); err != nil {
+		return err
+	}
+	if err := writeBackupEntry(archive, backupDataEntry, io.NewSectionReader(source.file, 0, source.size), source.size); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// backup captures the state together with the length of the data file it
+// describes. The data file is opened again, so the copy stays readable when
+// compaction replaces the file, and later writes only append past size.
+func (d *Database) backup(source *backupSource) *Result {
+	state, err := codec.Encode(d.state)
+	if err != nil {
+		return &Result{Error: err}
+	}
+	size, err := d.endOffset()
+	if err != nil {
+		return &Result{Error: err}
+	}
+	file, err := d.backend.OpenFile(d.path)
+	if err != nil {
+		return &Result{Error: err}
+	}
+	*source = backupSource{file, size, state}
+	return &Result{}
+}
+
+func writeBackupEntry(archive *tar.Writer, name string, content io.Reader, size int64) error {
+	if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err := io.Copy(archive, content)
+	return err
+}
+
+// Restore replaces the whole content of the database with a backup. The backup
+// is unpacked next to the database first, so a broken archive changes nothing.
+func (d *Database) Restore(r io.Reader) error {
+	return d.restoreBackup(r, "restore")
+}
+
+// restoreBackup unpacks a backup and passes it to the run loop with action
+func (d *Database) restoreBackup(r io.Reader, action string) error {
+	stagedPath := fmt.Sprintf("%s.restore-%x", d.path, randomBits(32))
+	staged, err := d.backend.OpenFile(stagedPath)
+	if err != nil {
+		return err
+	}
+	defer d.backend.Remove(stagedPath)
+	state, err := unpackBackup(r, staged)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if state.Codec == "" {
+		state.Codec = GobCodec.Id()
+	}
+	if state.Codec != d.codec.Id() {
+		return fmt.Errorf("%w: written with the %s codec, database uses %s", ErrInvalidBackup, state.Codec, d.codec.Id())
+	}
+	if err := d.verifyKey(state); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	return d.execute(context.Background(), command{action: action, input: restoreSource{stagedPath, state}}).Error
+}
+
+type restoreSource struct {
+	data  string
+	state *DatabaseState
+}
+
+func unpackBackup(r io.Reader, data File) (*DatabaseState, error) {
+	var state *DatabaseState
+	var size int64 = -1
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+		switch header.Name {
+		case backupStateEntry:
+			content, err := io.ReadAll(archive)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+			}
+			state = &DatabaseState{}
+			if err := codec.DecodeInto(content, state); err != nil {
+				return nil, fmt.Errorf("%w: state: %w", ErrInvalidBackup, err)
+			}
+		case backupDataEntry:
+			if size, err = io.Copy(io.NewOffsetWriter(data, 0), archive); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+			}
+		}
+	}
+	if state == nil || size < 0 {
+		return nil, fmt.Errorf("%w: state or data missing", ErrInvalidBackup)
+	}
+	if state.Records == nil {
+		state.Records = make(map[int64]*Record)
+	}
+	for _, record := range state.Records {
+		if record.Offset < 0 || record.Length < 0 || record.Offset+record.Length > size {
+			return nil, fmt.Errorf("%w: record %d is outside the data", ErrInvalidBackup, record.Id)
+		}
+	}
+	return state, data.Sync()
+}
+
+// restore swaps the unpacked backup in the way compaction swaps in its result
+func (d *Database) restore(source restoreSource) *Result {
+	// Replicas can't follow the change, they have to connect again for a copy
+	d.dropReplicas()
+	if err := d.checkpoint(); err != nil {
+		return &Result{Error: err}
+	}
+	if err := d.backend.Rename(source.data, d.path+compactSuffix); err != nil {
+		return &Result{Error: err}
+	}
+	if err := d.swapIn(source.state); err != nil {
+		return &Result{Error: err}
+	}
+	d.idGenerator.resume(d.state.LastId)
+	if err := d.rebuildIndexes(); err != nil {
+		return &Result{Error: err}
+	}
+	return &Result{}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDb(t, filepath.Join(dir, "users.db"), &Sequence{})
+	ids := createUsers(t, db, 3)
+	db.Delete(ctx, ids[0])
+	if err := CreateFieldIndex[User](db, "Age"); err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := db.Backup(&backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	// Changes after the backup are not part of it
+	db.Update(ctx, ids[1], &User{"Anna", "Nowak", 50, false})
+	db.Create(ctx, &User{"Piotr", "Zieliński", 60, true})
+	db.Compact()
+
+	if err := db.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	user := User{}
+	if result := db.Read(ctx, ids[1], &user); result.Error != nil || user.FirstName != "Jan" {
+		t.Errorf("Expected the backed up version, got %v, %v", user, result.Error)
+	}
+	if names := scannedNames(t, db); len(names) != 2 {
+		t.Errorf("Expected the 2 backed up users, got %v", names)
+	}
+	if found, _ := db.FindRange("User.Age", 50, nil); len(found) != 0 {
+		t.Errorf("Expected indexes rebuilt from the backup, found %v", found)
+	}
+	if found, _ := db.Find("User.Age", 21); !slices.Equal(found, ids[1:2]) {
+		t.Errorf("Find after restore = %v", found)
+	}
+	db.Close()
+
+	// A backup restores into another database as well
+	other := openTestDb(t, filepath.Join(dir, "copy.db"), &Sequence{})
+	defer other.Close()
+	if err := other.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatalf("Restore into another database failed: %v", err)
+	}
+	if result := other.Read(ctx, ids[2], &user); result.Error != nil || user.Age != 22 {
+		t.Errorf("Read from the copy = %v, %v", user, result.Error)
+	}
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	ids := createUsers(t, db, 1)
+
+	if err := db.Restore(strings.NewReader("not a backup")); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected an invalid archive to be rejected, got %v", err)
+	}
+	if result := db.Read(ctx, ids[0], &User{}); result.Error != nil {
+		t.Errorf("Expected the database to be untouched: %v", result.Error)
+	}
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// faultyBackend fails writes to the write-ahead log while fail is set
+type faultyBackend struct {
+	*MemoryBackend
+	fail *atomic.Bool
+}
+
+type faultyFile struct {
+	File
+	fail *atomic.Bool
+}
+
+func (b faultyBackend) OpenFile(name string) (File, error) {
+	file, err := b.MemoryBackend.OpenFile(name)
+	if err != nil || filepath.Ext(name) != walFileSuffix {
+		return file, err
+	}
+	return faultyFile{file, b.fail}, nil
+}
+
+func (f faultyFile) WriteAt(p []byte, offset int64) (int, error) {
+	if f.fail.Load() {
+		return 0, errors.New("disk full")
+	}
+	return f.File.WriteAt(p, offset)
+}
+
+func TestCreateBatch(t *testing.T) {
+	db := openMemoryDb(t, NewMemoryBackend(), &Sequence{})
+	defer db.Close()
+	results, err := db.CreateBatch(ctx, []any{&User{"Jan", "Kowalski", 25, true}, &User{"Anna", "Nowak", 30, true}})
+	if err != nil || len(results) != 2 {
+		t.Fatalf("CreateBatch = %v, %v", results, err)
+	}
+	user := User{}
+	if result := db.Read(ctx, results[1].Value.Id, &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Read = %v, %v", user, result.Error)
+	}
+	if _, err := db.CreateBatch(ctx, []any{&User{}, func() {}}); err == nil {
+		t.Error("Expected a batch with a value the codec can't encode to fail")
+	}
+}
+
+// queueCreates starts count creates while the run loop is stopped, so all of
+// them are queued when it starts
+func queueCreates(t *testing.T, db *Database, count int) chan *Result {
+	t.Helper()
+	return queueCommands(t, db, count, func(i int) *Result {
+		return db.Create(ctx, &User{"Jan", "Kowalski", int16(i), true})
+	})
+}
+
+// queueCommands starts count operations while the run loop is stopped
+func queueCommands(t *testing.T, db *Database, count int, operation func(i int) *Result) chan *Result {
+	t.Helper()
+	results := make(chan *Result, count)
+	for i := 0; i < count; i++ {
+		go func() { results <- operation(i) }()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(db.commands) < count {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out queueing creates")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return results
+}
+
+func TestGroupCommit(t *testing.T) {
+	db, err := Open("users.db", &Sequence{}, WithBackend(NewMemoryBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := queueCreates(t, db, 50)
+	go db.run()
+	defer db.Close()
+	for i := 0; i < 50; i++ {
+		if result := <-results; result.Error != nil {
+			t.Fatalf("Create failed: %v", result.Error)
+		}
+	}
+	if result := db.Read(ctx, 50, &User{}); result.Error != nil {
+		t.Errorf("Read failed: %v", result.Error)
+	}
+	// The run loop replies after logging, so the frames can be read here
+	if db.wal.frames != 1 || db.wal.entries != 50 {
+		t.Errorf("Expected the queued creates to be logged in 1 frame, got %d frames of %d entries", db.wal.frames, db.wal.entries)
+	}
+}
+
+// A group commit logs many operations in one frame, the checkpoint still comes
+// after as many operations as the interval, not as many frames
+func TestCheckpointAfterGroupCommit(t *testing.T) {
+	backend := NewMemoryBackend()
+	db := openMemoryDb(t, backend, &Sequence{})
+	id := db.Create(ctx, &User{"Jan", "Kowalski", 25, true}).Value.Id
+	db.Close()
+
+	db, err := Open("users.db", &Sequence{counter: id}, WithBackend(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.wal.entries = checkpointInterval - 50
+	results := queueCommands(t, db, 50, func(i int) *Result {
+		return db.Update(ctx, id, &User{"Jan", "Kowalski", int16(26 + i), true})
+	})
+	go db.run()
+	defer db.Close()
+	for i := 0; i < 50; i++ {
+		if result := <-results; result.Error != nil {
+			t.Fatalf("Update failed: %v", result.Error)
+		}
+	}
+	if db.wal.frames != 0 || db.wal.entries != 0 {
+		t.Errorf("Expected a checkpoint emptying the log, got %d frames of %d entries", db.wal.frames, db.wal.entries)
+	}
+	state, err := readState(backend, "users.db")
+	if err != nil || state.Records[id] == nil || state.Records[id].Version != 51 {
+		t.Errorf("Expected the saved state to hold the updates, got %v, %v", state.Records[id], err)
+	}
+}
+
+func TestFailedGroupCommitIsUndone(t *testing.T) {
+	var fail atomic.Bool
+	db, err := Open("users.db", &Sequence{}, WithBackend(faultyBackend{NewMemoryBackend(), &fail}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := queueCreates(t, db, 3)
+	fail.Store(true)
+	go db.run()
+	defer db.Close()
+	for i := 0; i < 3; i++ {
+		if result := <-results; result.Error == nil {
+			t.Errorf("Expected the creates of a failed group to fail")
+		}
+	}
+	fail.Store(false)
+	if names := scannedNames(t, db); len(names) != 0 {
+		t.Errorf("Expected the failed creates to be undone, got %v", names)
+	}
+	if result := db.Create(ctx, &User{"Anna", "Nowak", 30, true}); result.Error != nil {
+		t.Errorf("Expected writes to work again, got %v", result.Error)
+	}
+}
+
+// Sequential creates wait for the disk one by one, concurrent ones share group
+// commits, a batch is a single write. The per operation baseline disables grouping.
+func BenchmarkWrites(b *testing.B) {
+	open := func(b *testing.B, groupSize int) *Database {
+		db, err := Open(filepath.Join(b.TempDir(), "users.db"), &Sequence{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		db.groupSize = groupSize
+		go db.run()
+		b.Cleanup(db.Close)
+		return db
+	}
+	concurrent := func(b *testing.B, db *Database) {
+		var writers sync.WaitGroup
+		for i := 0; i < b.N; i++ {
+			writers.Add(1)
+			go func() {
+				defer writers.Done()
+				db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+			}()
+		}
+		writers.Wait()
+	}
+	b.Run("sequential", func(b *testing.B) {
+		db := open(b, maxGroupSize)
+		for i := 0; i < b.N; i++ {
+			db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+		}
+	})
+	b.Run("concurrent per operation", func(b *testing.B) {
+		concurrent(b, open(b, 1))
+	})
+	b.Run("concurrent group commit", func(b *testing.B) {
+		concurrent(b, open(b, maxGroupSize))
+	})
+	b.Run("batch", func(b *testing.B) {
+		db := open(b, maxGroupSize)
+		users := make([]any, 100)
+		for i := range users {
+			users[i] = &User{"Jan", "Kowalski", 25, true}
+		}
+		for i := 0; i < b.N; i += len(users) {
+			db.CreateBatch(ctx, users[:min(len(users), b.N-i)])
+		}
+	})
+}
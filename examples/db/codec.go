@@ -0,0 +1,92 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	msgpack "github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"training.pl/go/common/codec"
+)
+
+// Codec serializes records; Id is stored in the state file to detect a database
+// opened with a different codec than it was written with
+type Codec interface {
+	Id() string
+	Encode(value any) ([]byte, error)
+	Decode(data []byte, value any) error
+}
+
+var (
+	GobCodec      Codec = gobCodec{}
+	JSONCodec     Codec = jsonCodec{}
+	MsgpackCodec  Codec = msgpackCodec{}
+	ProtobufCodec Codec = protobufCodec{} // Records must be proto.Message values
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Id() string {
+	return "gob"
+}
+
+func (gobCodec) Encode(value any) ([]byte, error) {
+	return codec.Encode(value)
+}
+
+func (gobCodec) Decode(data []byte, value any) error {
+	return codec.DecodeInto(data, value)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Id() string {
+	return "json"
+}
+
+func (jsonCodec) Encode(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec) Decode(data []byte, value any) error {
+	return json.Unmarshal(data, value)
+}
+
+var msgpackHandle msgpack.MsgpackHandle
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Id() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Encode(value any) ([]byte, error) {
+	var data []byte
+	err := msgpack.NewEncoderBytes(&data, &msgpackHandle).Encode(value)
+	return data, err
+}
+
+func (msgpackCodec) Decode(data []byte, value any) error {
+	return msgpack.NewDecoderBytes(data, &msgpackHandle).Decode(value)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Id() string {
+	return "protobuf"
+}
+
+func (protobufCodec) Encode(value any) ([]byte, error) {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec cannot encode %T, it is not a proto.Message", value)
+	}
+	return proto.Marshal(message)
+}
+
+func (protobufCodec) Decode(data []byte, value any) error {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec cannot decode into %T, it is not a proto.Message", value)
+	}
+	return proto.Unmarshal(data, message)
+}
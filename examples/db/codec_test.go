@@ -0,0 +1,94 @@
+package db
+
+import (
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"path/filepath"
+	"testing"
+	"training.pl/go/examples/money"
+)
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{GobCodec, JSONCodec, MsgpackCodec} {
+		t.Run(codec.Id(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.db")
+			db, err := Open(path, &Sequence{}, WithCodec(codec))
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			go db.run()
+			created := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+			if result := db.Compact(); result.Error != nil {
+				t.Fatalf("Compact failed: %v", result.Error)
+			}
+			db.Close()
+
+			db, err = Open(path, &Sequence{counter: 1}, WithCodec(codec))
+			if err != nil {
+				t.Fatalf("Reopening failed: %v", err)
+			}
+			go db.run()
+			defer db.Close()
+			user := User{}
+			if result := db.Read(ctx, created.Value.Id, &user); result.Error != nil || user != (User{"Jan", "Kowalski", 25, true}) {
+				t.Errorf("Read = %v, %v", user, result.Error)
+			}
+		})
+	}
+}
+
+type Payment struct {
+	Title  string
+	Amount money.Amount
+}
+
+func TestCodecsStoreMoney(t *testing.T) {
+	for _, codec := range []Codec{GobCodec, JSONCodec, MsgpackCodec} {
+		t.Run(codec.Id(), func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "payments.db"), &Sequence{}, WithCodec(codec))
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			go db.run()
+			defer db.Close()
+			payments := NewCollection[Payment](db, "payments")
+
+			original := Payment{"Rent", money.New(-123456, money.PLN)}
+			id, err := payments.Insert(ctx, original)
+			if err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			if payment, err := payments.Get(ctx, id); err != nil || payment != original {
+				t.Errorf("Get = %v, %v; want %v", payment, err, original)
+			}
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "names.db"), &Sequence{}, WithCodec(ProtobufCodec))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go db.run()
+	defer db.Close()
+
+	created := db.Create(ctx, wrapperspb.String("Jan"))
+	name := &wrapperspb.StringValue{}
+	if result := db.Read(ctx, created.Value.Id, name); result.Error != nil || name.GetValue() != "Jan" {
+		t.Errorf("Read = %v, %v", name, result.Error)
+	}
+	if result := db.Create(ctx, &User{}); result.Error == nil {
+		t.Error("Expected a value that is not a proto.Message to be rejected")
+	}
+}
+
+func TestCodecMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	db.Close()
+
+	if db, err := Open(path, &Sequence{}, WithCodec(JSONCodec)); err == nil {
+		db.closeFiles()
+		t.Fatal("Expected opening a gob database with the JSON codec to fail")
+	}
+}
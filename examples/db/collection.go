@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"training.pl/go/common"
+)
+
+// Collection is a typed view of the records of one kind, several collections
+// can share a database file
+type Collection[T any] struct {
+	db   *Database
+	name string
+}
+
+// Entry is a record of a collection with its id and version
+type Entry[T any] struct {
+	Id      int64
+	Version int64
+	Value   T
+}
+
+func NewCollection[T any](d *Database, name string) *Collection[T] {
+	return &Collection[T]{db: d, name: name}
+}
+
+func (c *Collection[T]) Insert(ctx context.Context, value T) (int64, error) {
+	return c.InsertWithTTL(ctx, value, PersistForever)
+}
+
+// InsertWithTTL inserts a value that disappears after ttl, PersistForever keeps it
+func (c *Collection[T]) InsertWithTTL(ctx context.Context, value T, ttl time.Duration) (int64, error) {
+	result := c.db.execute(ctx, command{action: "insert", input: value, collection: c.name, ttl: ttl})
+	return common.MapResult(*result, func(record *Record) int64 { return record.Id }).Get()
+}
+
+func (c *Collection[T]) Get(ctx context.Context, id int64) (T, error) {
+	entry, err := c.GetEntry(ctx, id)
+	return entry.Value, err
+}
+
+// GetEntry is Get returning the version as well, to be passed to UpdateIf
+func (c *Collection[T]) GetEntry(ctx context.Context, id int64) (Entry[T], error) {
+	start := time.Now()
+	entry, err := c.getEntry(ctx, id)
+	c.db.observe("read", id, start, err)
+	return entry, err
+}
+
+func (c *Collection[T]) getEntry(ctx context.Context, id int64) (Entry[T], error) {
+	entry := Entry[T]{Id: id}
+	if err := ctx.Err(); err != nil {
+		return entry, err
+	}
+	record, raw, err := c.db.readSnapshot(id)
+	if err == nil && record.Collection != c.name {
+		err = notFound(id)
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Version = record.Version
+	err = c.db.decode(record, raw, &entry.Value)
+	return entry, err
+}
+
+func (c *Collection[T]) Update(ctx context.Context, id int64, value T) error {
+	return c.db.execute(ctx, command{action: "update", id: id, input: value, collection: c.name}).Error
+}
+
+// UpdateIf updates a record only if it is still at the given version, see Database.UpdateIf
+func (c *Collection[T]) UpdateIf(ctx context.Context, id int64, version int64, value T) error {
+	return c.db.execute(ctx, command{action: "update", id: id, version: version, input: value, collection: c.name}).Error
+}
+
+func (c *Collection[T]) Delete(ctx context.Context, id int64) error {
+	return c.db.execute(ctx, command{action: "delete", id: id, collection: c.name}).Error
+}
+
+// List returns all records of the collection in insertion order
+func (c *Collection[T]) List(ctx context.Context) ([]Entry[T], error) {
+	var records []*Record
+	var raws [][]byte
+	err := c.db.scanRecords(ctx, func(record *Record, raw []byte) error {
+		if record.Collection == c.name {
+			records = append(records, record)
+			raws = append(raws, raw)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Decoded here rather than in the run loop, so writes don't wait for it
+	entries := make([]Entry[T], len(records))
+	for i, record := range records {
+		entries[i].Id = record.Id
+		entries[i].Version = record.Version
+		if err := c.db.decode(record, raws[i], &entries[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shop.db")
+	db := openTestDb(t, path, &Sequence{})
+	users := NewCollection[User](db, "users")
+	products := NewCollection[Product](db, "products")
+
+	jan, err := users.Insert(ctx, User{"Jan", "Kowalski", 25, true})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	book, _ := products.Insert(ctx, Product{"Book", 10})
+	anna, _ := users.Insert(ctx, User{"Anna", "Nowak", 30, true})
+
+	entry, err := users.GetEntry(ctx, jan)
+	if err != nil || entry.Version != 1 {
+		t.Fatalf("GetEntry = %v, %v", entry, err)
+	}
+	if err := users.UpdateIf(ctx, jan, entry.Version, User{"Jan", "Kowalski", 26, false}); err != nil {
+		t.Errorf("UpdateIf failed: %v", err)
+	}
+	if err := users.UpdateIf(ctx, jan, entry.Version, User{"Jan", "Kowalski", 27, false}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict for a stale version, got %v", err)
+	}
+	if _, err := users.Get(ctx, book); err == nil {
+		t.Error("Expected a product not to be found among users")
+	}
+	if err := users.Delete(ctx, book); err == nil {
+		t.Error("Expected deleting a product through users to fail")
+	}
+	crash(db)
+
+	db = openTestDb(t, path, &Sequence{counter: 3})
+	defer db.Close()
+	users = NewCollection[User](db, "users")
+	products = NewCollection[Product](db, "products")
+	user, err := users.Get(ctx, jan)
+	if err != nil || user.Age != 26 {
+		t.Errorf("Get after recovery = %v, %v", user, err)
+	}
+	list, err := users.List(ctx)
+	if err != nil || len(list) != 2 || list[0].Id != jan || list[1].Id != anna || list[1].Value.FirstName != "Anna" {
+		t.Errorf("List = %v, %v", list, err)
+	}
+	if err := products.Delete(ctx, book); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if list, _ := products.List(ctx); len(list) != 0 {
+		t.Errorf("Expected no products left, got %v", list)
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"time"
+	"training.pl/go/common/codec"
+)
+
+// The compacted data and its state are written next to the database and renamed
+// over it. Renaming the data file is the commit point, recoverCompaction finishes
+// or drops an interrupted compaction.
+const compactSuffix = ".compact"
+
+func (d *Database) Compact() *Result {
+	return d.execute(context.Background(), command{action: "compact", input: 0.0})
+}
+
+// AutoCompact compacts the database in the background every interval, when at
+// least minGarbageRatio of the data file is taken by stale records. Close stops it.
+func (d *Database) AutoCompact(interval time.Duration, minGarbageRatio float64) {
+	d.every(interval, func() {
+		result := d.execute(context.Background(), command{action: "compact", input: minGarbageRatio})
+		if result.Error != nil {
+			log.Printf("Background compaction failed: %v", result.Error)
+		}
+	})
+}
+
+func (d *Database) compact(minGarbageRatio float64) *Result {
+	size, err := d.endOffset()
+	if err != nil {
+		return &Result{Error: err}
+	}
+	var live int64
+	for _, record := range d.state.Records {
+		live += record.Length
+	}
+	if size == 0 || float64(size-live)/float64(size) < minGarbageRatio {
+		return &Result{}
+	}
+	if err := d.checkpoint(); err != nil {
+		return &Result{Error: err}
+	}
+
+	path := d.path
+	records, err := d.copyLiveRecords(path + compactSuffix)
+	if err != nil {
+		d.backend.Remove(path + compactSuffix)
+		return &Result{Error: err}
+	}
+	state := &DatabaseState{}
+	*state = *d.state
+	state.Records = records
+	if err := d.swapIn(state); err != nil {
+		return &Result{Error: err}
+	}
+	return &Result{}
+}
+
+// swapIn replaces the data file with the one prepared at the compaction path and
+// the state with the given one. The write-ahead log must be empty.
+func (d *Database) swapIn(state *DatabaseState) error {
+	path := d.path
+	bytes, err := codec.Encode(state)
+	if err != nil {
+		d.backend.Remove(path + compactSuffix)
+		return err
+	}
+	if err := d.backend.WriteFile(path+compactSuffix+stateFileSuffix, bytes); err != nil {
+		d.backend.Remove(path + compactSuffix)
+		return err
+	}
+
+	if err := d.backend.Rename(path+compactSuffix, path); err != nil {
+		return err
+	}
+	if err := d.backend.Rename(path+compactSuffix+stateFileSuffix, path+stateFileSuffix); err != nil {
+		return err
+	}
+	file, err := d.backend.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	d.snapshotLock.Lock()
+	old := d.file
+	d.file = file
+	d.state = state
+	d.records = newRecordTrie(state.Records)
+	d.snapshot = &snapshot{d.records, file}
+	d.snapshotLock.Unlock()
+	old.Close()
+	return nil
+}
+
+// copyLiveRecords writes the current versions of all records to a new file,
+// keeping their order, and returns their new locations
+func (d *Database) copyLiveRecords(target string) (map[int64]*Record, error) {
+	if err := d.backend.Remove(target); err != nil {
+		return nil, err
+	}
+	file, err := d.backend.OpenFile(target)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	writer := io.NewOffsetWriter(file, 0)
+	ordered := slices.SortedFunc(maps.Values(d.state.Records), func(a, b *Record) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	records := make(map[int64]*Record, len(ordered))
+	var offset int64
+	for _, record := range ordered {
+		if _, err := io.Copy(writer, io.NewSectionReader(d.file, record.Offset, record.Length)); err != nil {
+			return nil, err
+		}
+		moved := *record
+		moved.Offset = offset
+		records[record.Id] = &moved
+		offset += record.Length
+	}
+	return records, file.Sync()
+}
+
+// recoverCompaction completes a compaction interrupted after its data file was
+// swapped in, or removes the leftovers of one interrupted before
+func recoverCompaction(backend Backend, path string) error {
+	compactState := path + compactSuffix + stateFileSuffix
+	stateExists, err := backend.Exists(compactState)
+	if err != nil {
+		return err
+	}
+	if !stateExists {
+		return backend.Remove(path + compactSuffix)
+	}
+	dataExists, err := backend.Exists(path + compactSuffix)
+	if err != nil {
+		return err
+	}
+	if dataExists {
+		backend.Remove(path + compactSuffix)
+		return backend.Remove(compactState)
+	}
+	return backend.Rename(compactState, path+stateFileSuffix)
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createUsers(t *testing.T, db *Database, count int) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, count)
+	for i := 0; i < count; i++ {
+		result := db.Create(ctx, &User{"Jan", "Kowalski", int16(20 + i), true})
+		if result.Error != nil {
+			t.Fatalf("Create failed: %v", result.Error)
+		}
+		ids = append(ids, result.Value.Id)
+	}
+	return ids
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestCompactReclaimsSpace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 10)
+	for _, id := range ids[:5] {
+		db.Delete(ctx, id)
+	}
+	db.Update(ctx, ids[9], &User{"Anna", "Nowak", 99, false})
+	before := fileSize(t, path)
+
+	if result := db.Compact(); result.Error != nil {
+		t.Fatalf("Compact failed: %v", result.Error)
+	}
+	if after := fileSize(t, path); after >= before {
+		t.Errorf("Expected the data file to shrink, was %d, is %d", before, after)
+	}
+	user := User{}
+	if result := db.Read(ctx, ids[9], &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Expected the latest version after compaction, got %v, %v", user, result.Error)
+	}
+	if result := db.Read(ctx, ids[0], &User{}); result.Error == nil {
+		t.Errorf("Expected deleted record %d to stay deleted", ids[0])
+	}
+	db.Close()
+
+	db = openTestDb(t, path, &Sequence{counter: 10})
+	defer db.Close()
+	for _, id := range ids[5:] {
+		if result := db.Read(ctx, id, &User{}); result.Error != nil {
+			t.Errorf("Read of %d after reopening failed: %v", id, result.Error)
+		}
+	}
+}
+
+func TestRecoveryFinishesInterruptedCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 4)
+	db.Delete(ctx, ids[0])
+	db.Update(ctx, ids[3], &User{"Anna", "Nowak", 30, false})
+	db.Close()
+	oldState, err := os.ReadFile(path + stateFileSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDb(t, path, &Sequence{counter: 4})
+	if result := db.Compact(); result.Error != nil {
+		t.Fatalf("Compact failed: %v", result.Error)
+	}
+	db.Close()
+
+	// Crash right after the compacted data file was swapped in
+	os.Rename(path+stateFileSuffix, path+compactSuffix+stateFileSuffix)
+	os.WriteFile(path+stateFileSuffix, oldState, 0644)
+
+	db = openTestDb(t, path, &Sequence{counter: 4})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, ids[3], &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Expected the compacted state to be used, got %v, %v", user, result.Error)
+	}
+}
+
+func TestRecoveryDropsUnfinishedCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 2)
+	db.Close()
+
+	// Crash before the compacted data file was swapped in
+	os.WriteFile(path+compactSuffix, []byte("partial"), 0644)
+	os.WriteFile(path+compactSuffix+stateFileSuffix, []byte("partial"), 0644)
+
+	db = openTestDb(t, path, &Sequence{counter: 2})
+	defer db.Close()
+	if result := db.Read(ctx, ids[1], &User{}); result.Error != nil {
+		t.Errorf("Read failed: %v", result.Error)
+	}
+	if _, err := os.Stat(path + compactSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the unfinished compaction to be removed, got %v", err)
+	}
+}
+
+func TestAutoCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 4)
+	for _, id := range ids[:3] {
+		db.Delete(ctx, id)
+	}
+	before := fileSize(t, path)
+	db.AutoCompact(10*time.Millisecond, 0.5)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fileSize(t, path) >= before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	db.Close()
+	if after := fileSize(t, path); after >= before {
+		t.Errorf("Expected background compaction to shrink the file, was %d, is %d", before, after)
+	}
+}
@@ -1,60 +1,284 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 	"training.pl/go/common"
+	"training.pl/go/common/codec"
+	"training.pl/go/concurrency"
 )
 
-const stateFileSuffix = ".state"
+const (
+	stateFileSuffix = ".state"
+	lockFileSuffix  = ".lock"
+)
+
+// Most writes logged together by a group commit
+const maxGroupSize = 100
+
+// Actions making changes only through log, they can be grouped
+var groupActions = map[string]bool{"insert": true, "update": true, "delete": true, "commit": true}
 
 type command struct {
-	action string
-	id     int64
-	input  any
-	output any
-	reply  chan *Result
+	ctx        context.Context
+	action     string
+	id         int64
+	collection string
+	version    int64 // Expected record version, 0 accepts any
+	ttl        time.Duration
+	input      any
+	output     any
+	reply      *concurrency.Future[*Record]
 }
 
-type Result struct {
-	Record *Record
-	Error  error
-}
+// Result is the outcome of a command, Value is the record it created, read or changed
+type Result = common.Result[*Record]
 
 type Record struct {
-	Id     int64
-	Offset int64
-	Length int64
+	Id         int64
+	Offset     int64
+	Length     int64
+	Seq        int64  // Insertion order
+	Collection string // Empty for records created without a Collection
+	Version    int64  // Starts at 1, incremented by every update
+	ExpiresAt  int64  // Unix time in nanoseconds, 0 for records kept forever
+}
+
+func (r *Record) expired(now time.Time) bool {
+	return r.ExpiresAt != 0 && r.ExpiresAt <= now.UnixNano()
+}
+
+var (
+	ErrNotFound    = errors.New("record not found")
+	ErrDuplicateID = errors.New("record id already exists")
+	// ErrCorrupted means stored data cannot be read back, e.g. a truncated data file
+	ErrCorrupted = errors.New("database corrupted")
+	// ErrConflict is returned by UpdateIf when the record was changed meanwhile
+	ErrConflict = errors.New("record was modified concurrently")
+	ErrClosed   = errors.New("database closed")
+)
+
+func notFound(id int64) error {
+	return fmt.Errorf("%w: id %d", ErrNotFound, id)
+}
+
+func duplicateID(id int64) error {
+	return fmt.Errorf("%w: id %d", ErrDuplicateID, id)
 }
 
 type Database struct {
-	file        *os.File
-	commands    chan command
-	state       *DatabaseState
-	idGenerator IdGenerator
+	path          string
+	backend       Backend
+	lock          io.Closer
+	file          File
+	wal           *writeAheadLog
+	commands      chan command
+	state         *DatabaseState
+	records       recordTrie // The records of state, shared with the snapshots
+	idGenerator   IdGenerator
+	codec         Codec
+	encryptionKey []byte
+	migrations    []Migration
+	metrics       map[string]*operationMetrics
+	slowThreshold time.Duration
+	indexes       map[string]*index
+
+	// Reads use the snapshot instead of the run loop, so they never wait for writes
+	snapshot     *snapshot
+	snapshotLock sync.RWMutex
+	readCache    *common.Cache[*Record, []byte] // Raw records, see WithReadCache
+
+	stopBackground chan struct{}
+	background     sync.WaitGroup
+
+	// Close closes closing, the run loop drains the queued commands and closes stopped
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// Writes queued together are logged together, see handle
+	groupSize int
+	grouping  bool
+	grouped   []walEntry
+
+	replicas []chan []replicatedEntry // Queues of the connected replicas
+	readOnly atomic.Bool              // Set for replicas
 }
 
 type DatabaseState struct {
-	Records map[int64]*Record
-	LastId  int64
+	Records  map[int64]*Record
+	LastId   int64
+	LastSeq  int64
+	Codec    string
+	KeyCheck []byte // Set for encrypted databases
+	// Number of migrations applied, see WithMigrations
+	SchemaVersion int
 }
 
-func Db(filepath string, idGenerator IdGenerator) *Database {
-	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, 0644)
+func Db(filepath string, idGenerator IdGenerator, options ...Option) *Database {
+	db, err := Open(filepath, idGenerator, options...)
 	catchFatal(err, "Failed to open database")
-	var state DatabaseState
-	bytes, err := os.ReadFile(filepath + stateFileSuffix)
+	return db
+}
+
+// Option configures a Database when it is opened
+type Option func(*Database)
+
+// WithCodec sets the codec records are serialized with, gob by default. A database
+// keeps the codec it was created with.
+func WithCodec(codec Codec) Option {
+	return func(d *Database) {
+		d.codec = codec
+	}
+}
+
+// Open is Db returning errors instead of exiting
+func Open(filepath string, idGenerator IdGenerator, options ...Option) (*Database, error) {
+	db := &Database{
+		path:        filepath,
+		backend:     FileBackend{},
+		commands:    make(chan command, 100),
+		idGenerator: idGenerator,
+		codec:       GobCodec,
+		indexes:     make(map[string]*index),
+		metrics:     newMetrics(),
+		groupSize:   maxGroupSize,
+
+		stopBackground: make(chan struct{}),
+		closing:        make(chan struct{}),
+		stopped:        make(chan struct{}),
+	}
+	for _, option := range options {
+		option(db)
+	}
+	// The lock is taken on a file of its own, compaction replaces the data file
+	lock, err := db.backend.Lock(filepath + lockFileSuffix)
 	if err != nil {
-		state = DatabaseState{Records: make(map[int64]*Record), LastId: 0}
-	} else {
-		catchFatal(common.FromBytes(bytes, &state), "Failed reading database state")
+		return nil, fmt.Errorf("failed to open %s: %w", filepath, err)
+	}
+	if err := recoverCompaction(db.backend, filepath); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to recover interrupted compaction: %w", err)
+	}
+	file, err := db.backend.OpenFile(filepath)
+	if err != nil {
+		lock.Close()
+		return nil, err
 	}
-	return &Database{file, make(chan command, 100), &state, idGenerator}
+	state, err := readState(db.backend, filepath)
+	if err != nil {
+		file.Close()
+		lock.Close()
+		return nil, err
+	}
+	wal, err := openWal(db.backend, filepath+walFileSuffix)
+	if err != nil {
+		file.Close()
+		lock.Close()
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	db.file, db.wal, db.state, db.lock = file, wal, state, lock
+	db.records = newRecordTrie(state.Records)
+	if db.encryptionKey != nil {
+		codec, err := newEncryptedCodec(db.codec, db.encryptionKey)
+		if err != nil {
+			db.closeFiles()
+			return nil, err
+		}
+		db.codec = codec
+	}
+	if err := db.recover(); err != nil {
+		db.closeFiles()
+		return nil, fmt.Errorf("failed to recover database: %w", err)
+	}
+	db.idGenerator.resume(db.state.LastId)
+	if err := db.checkCodec(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+	if err := db.migrate(); err != nil {
+		db.closeFiles()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	db.publish()
+	return db, nil
+}
+
+// checkCodec makes sure records are read with the codec they were written with.
+// Databases from before codecs were stored use gob.
+func (d *Database) checkCodec() error {
+	switch {
+	case d.state.Codec == "" && len(d.state.Records) > 0:
+		d.state.Codec = GobCodec.Id()
+	case d.state.Codec == "":
+		d.state.Codec = d.codec.Id()
+		if err := d.setKeyCheck(); err != nil {
+			return err
+		}
+		return d.saveState()
+	}
+	if d.state.Codec != d.codec.Id() {
+		return fmt.Errorf("database was written with the %s codec, opened with %s", d.state.Codec, d.codec.Id())
+	}
+	return d.verifyKey(d.state)
+}
+
+func (d *Database) closeFiles() {
+	d.file.Close()
+	d.wal.close()
+	d.lock.Close()
+}
+
+// readState reads the last saved state, a missing state file means an empty database
+func readState(backend Backend, path string) (*DatabaseState, error) {
+	state := &DatabaseState{Records: make(map[int64]*Record), LastId: 0}
+	bytes, err := backend.ReadFile(path + stateFileSuffix)
+	if err == nil {
+		if err = codec.DecodeInto(bytes, state); err != nil {
+			err = fmt.Errorf("%w: %w", ErrCorrupted, err)
+		}
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed reading database state: %w", err)
+	}
+	return state, nil
+}
+
+// reload replaces the state with the one on disk, after changes failed to be logged
+func (d *Database) reload() error {
+	state, err := readState(d.backend, d.path)
+	if err != nil {
+		return err
+	}
+	d.state = state
+	d.records = newRecordTrie(state.Records)
+	if _, err := d.wal.replay(d.apply); err != nil {
+		return err
+	}
+	d.publish()
+	return d.rebuildIndexes()
+}
+
+// recover applies the operations logged after the last saved state
+func (d *Database) recover() error {
+	replayed, err := d.wal.replay(d.apply)
+	if err != nil || replayed == 0 {
+		return err
+	}
+	return d.checkpoint()
 }
 
 //func catchFatal(err error, description func() string) {
@@ -69,160 +293,444 @@ func catchFatal(err error, description string) {
 	}
 }
 
+// Close stops accepting commands, waits for the run loop to finish the queued ones,
+// saves the state and closes the files. Later calls fail with ErrClosed.
 func (d *Database) Close() {
-	close(d.commands)
-	// catchFatal(d.file.Close(), func() string { return "Close database file failed"})
-	catchFatal(d.file.Close(), "Close database file failed")
-	catchFatal(d.saveState(), "Save database state failed")
+	d.closeOnce.Do(func() {
+		close(d.stopBackground)
+		d.background.Wait()
+		close(d.closing)
+		<-d.stopped
+		d.dropReplicas()
+		catchFatal(d.checkpoint(), "Save database state failed")
+		d.snapshotLock.Lock()
+		defer d.snapshotLock.Unlock()
+		d.snapshot = nil
+		// catchFatal(d.file.Close(), func() string { return "Close database file failed"})
+		catchFatal(d.file.Close(), "Close database file failed")
+		catchFatal(d.wal.close(), "Close write-ahead log failed")
+		catchFatal(d.lock.Close(), "Unlock database failed")
+	})
 }
 
+// saveState replaces the state file atomically, a crash leaves either the old or the new state
 func (d *Database) saveState() error {
-	bytes, err := common.ToBytes(d.state)
+	bytes, err := codec.Encode(d.state)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(d.file.Name()+stateFileSuffix, bytes, 0644)
+	stateFile := d.path + stateFileSuffix
+	if err := d.backend.WriteFile(stateFile+".tmp", bytes); err != nil {
+		return err
+	}
+	return d.backend.Rename(stateFile+".tmp", stateFile)
+}
+
+func (d *Database) checkpoint() error {
+	if err := d.file.Sync(); err != nil {
+		return err
+	}
+	if err := d.saveState(); err != nil {
+		return err
+	}
+	return d.wal.reset()
+}
+
+// log makes operations durable before applying them to the state. Record data is
+// synced first, so a logged record always points at data present in the file.
+func (d *Database) log(entries ...walEntry) error {
+	if d.grouping {
+		// Applied at once for the next commands of the group, flushGroup makes them durable and visible
+		for _, entry := range entries {
+			d.apply(entry)
+		}
+		d.grouped = append(d.grouped, entries...)
+		return nil
+	}
+	if err := d.write(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		d.apply(entry)
+	}
+	return d.logged(entries)
+}
+
+func (d *Database) write(entries []walEntry) error {
+	if err := d.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync the data file: %w", err)
+	}
+	if err := d.wal.append(entries); err != nil {
+		return fmt.Errorf("failed to write the write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// logged publishes applied entries to readers and replicas
+func (d *Database) logged(entries []walEntry) error {
+	d.publish()
+	d.ship(entries)
+	if d.checkpointDue() {
+		return d.checkpoint()
+	}
+	return nil
+}
+
+// flushGroup logs the entries of a group commit as one frame
+func (d *Database) flushGroup() error {
+	entries := d.grouped
+	d.grouping, d.grouped = false, nil
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := d.write(entries); err != nil {
+		// The state holds changes that didn't reach the log, start over from the disk
+		if reloadErr := d.reload(); reloadErr != nil {
+			return errors.Join(err, reloadErr)
+		}
+		return err
+	}
+	return d.logged(entries)
+}
+
+// checkpointDue spreads the cost of saving the state, which grows with the number
+// of records, over as many logged operations: the log gets as long as the state
+// first. The write that triggers a checkpoint still waits for the whole save.
+func (d *Database) checkpointDue() bool {
+	return d.wal.entries >= max(checkpointInterval, len(d.state.Records))
+}
+
+func (d *Database) apply(entry walEntry) {
+	record := entry.Record
+	switch entry.Action {
+	case "insert", "update":
+		if existing, exists := d.state.Records[record.Id]; exists {
+			record.Seq = existing.Seq
+			record.Collection = existing.Collection
+			record.Version = existing.Version + 1
+			record.ExpiresAt = existing.ExpiresAt
+		} else {
+			record.Version = 1
+			d.state.LastSeq++
+			record.Seq = d.state.LastSeq
+		}
+		d.state.Records[record.Id] = &record
+		d.records = d.records.set(&record)
+		if record.Id > d.state.LastId {
+			d.state.LastId = record.Id
+		}
+	case "delete":
+		delete(d.state.Records, record.Id)
+		d.records = d.records.delete(record.Id)
+	case "schema":
+		d.state.SchemaVersion = int(record.Version)
+		return
+	}
+	d.updateIndexes(entry)
 }
 
 func (d *Database) run() {
-	for cmd := range d.commands {
-		switch cmd.action {
-		case "insert":
-			cmd.reply <- d.create(cmd.input)
-		case "find":
-			cmd.reply <- d.read(cmd.id, cmd.output)
-		case "update":
-			cmd.reply <- d.update(cmd.id, cmd.input)
-		case "delete":
-			cmd.reply <- d.delete(cmd.id)
+	defer close(d.stopped)
+	for {
+		select {
+		case cmd := <-d.commands:
+			d.handle(cmd)
+		case <-d.closing:
+			// Commands sent before Close are still executed
+			for {
+				select {
+				case cmd := <-d.commands:
+					d.handle(cmd)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
-func (d *Database) create(object any) *Result {
-	bytes, err := common.ToBytes(object)
-	if err != nil {
-		return &Result{Record: nil, Error: err}
+// handle executes a write together with the writes queued behind it (group
+// commit), they share one sync of the data file and one write-ahead log frame
+func (d *Database) handle(cmd command) {
+	if !groupActions[cmd.action] || d.groupSize < 2 {
+		result := d.perform(cmd)
+		cmd.reply.Complete(result.Value, result.Error)
+		return
 	}
-	offset, err := d.endOffset()
+	d.grouping = true
+	commands := []command{cmd}
+	results := []*Result{d.perform(cmd)}
+	var other *command
+	for other == nil && len(commands) < d.groupSize && len(d.commands) > 0 {
+		queued := <-d.commands
+		if groupActions[queued.action] {
+			commands = append(commands, queued)
+			results = append(results, d.perform(queued))
+		} else {
+			other = &queued
+		}
+	}
+	err := d.flushGroup()
+	for i, cmd := range commands {
+		if err != nil && results[i].Error == nil {
+			results[i] = &Result{Error: err}
+		}
+		cmd.reply.Complete(results[i].Value, results[i].Error)
+	}
+	if other != nil {
+		d.handle(*other)
+	}
+}
+
+func (d *Database) perform(cmd command) *Result {
+	if err := cmd.ctx.Err(); err != nil {
+		return &Result{Error: err}
+	}
+	if writeActions[cmd.action] && d.readOnly.Load() {
+		return &Result{Error: ErrReadOnly}
+	}
+	switch cmd.action {
+	case "insert":
+		return d.create(cmd.input, cmd.collection, cmd.ttl)
+	case "update":
+		return d.update(cmd.id, cmd.input, cmd.collection, cmd.version)
+	case "delete":
+		return d.delete(cmd.id, cmd.collection)
+	case "expire":
+		return d.removeExpired()
+	case "backup":
+		return d.backup(cmd.output.(*backupSource))
+	case "restore", "resync":
+		return d.restore(cmd.input.(restoreSource))
+	case "subscribe":
+		return d.subscribe(cmd.input.(chan []replicatedEntry), cmd.output.(*backupSource))
+	case "unsubscribe":
+		return d.unsubscribe(cmd.input.(chan []replicatedEntry))
+	case "replicate":
+		return d.replicate(cmd.input.([]replicatedEntry))
+	case "compact":
+		return d.compact(cmd.input.(float64))
+	case "forEach":
+		return d.forEach(cmd.input.(func(*Record, []byte) error))
+	case "createIndex":
+		return d.createIndex(cmd.input.(indexDefinition))
+	case "findIndex":
+		return d.findIndex(cmd.input.(indexQuery), cmd.output.(*[]int64))
+	case "commit":
+		return d.commit(cmd.input.([]txOperation), cmd.output.(*[]*Result))
+	}
+	return &Result{Error: fmt.Errorf("unknown action %s", cmd.action)}
+}
+
+func (d *Database) create(object any, collection string, ttl time.Duration) *Result {
+	bytes, err := d.codec.Encode(object)
 	if err != nil {
-		return &Result{Record: nil, Error: err}
+		return &Result{Error: err}
 	}
 	id := d.idGenerator.next()
 	_, exit := d.state.Records[id]
 	if exit {
-		return &Result{nil, fmt.Errorf("record with id %d already exists", id)}
+		return &Result{Error: duplicateID(id)}
 	}
-	length, err := d.file.WriteAt(bytes, offset)
+	record, err := d.writeRecord(id, bytes)
 	if err != nil {
-		return &Result{Record: nil, Error: err}
+		return &Result{Error: err}
 	}
-	record := &Record{id, offset, int64(length)}
-	d.state.Records[id] = record
-	if err := d.saveState(); err != nil {
-		return &Result{Record: nil, Error: err}
+	record.Collection = collection
+	if ttl != PersistForever {
+		record.ExpiresAt = time.Now().Add(ttl).UnixNano()
+	}
+	if err := d.log(walEntry{"insert", record}); err != nil {
+		return &Result{Error: err}
 	}
-	return &Result{record, nil}
+	return &Result{Value: d.state.Records[id]}
 }
 
-func (d *Database) read(id int64, object any) *Result {
+// lookup finds a record to change, an empty collection matches records of all collections
+func (d *Database) lookup(id int64, collection string) (*Record, error) {
 	record, exists := d.state.Records[id]
-	if !exists {
-		return &Result{nil, fmt.Errorf("record with id %d not found", id)}
-	}
-	bytes := make([]byte, record.Length)
-	_, err := d.file.ReadAt(bytes, record.Offset)
-	if err != nil {
-		return &Result{Record: nil, Error: err}
-	}
-	err = common.FromBytes(bytes, object)
-	if err != nil {
-		return &Result{Record: nil, Error: err}
+	if !exists || record.expired(time.Now()) || (collection != "" && record.Collection != collection) {
+		return nil, notFound(id)
 	}
-	return &Result{record, err}
+	return record, nil
 }
 
-func (d *Database) delete(id int64) *Result {
-	_, exists := d.state.Records[id]
-	if !exists {
-		return &Result{nil, fmt.Errorf("record with id %d not found", id)}
+func (d *Database) delete(id int64, collection string) *Result {
+	if _, err := d.lookup(id, collection); err != nil {
+		return &Result{Error: err}
 	}
-	delete(d.state.Records, id)
-	if err := d.saveState(); err != nil {
-		return &Result{nil, err}
+	if err := d.log(walEntry{"delete", Record{Id: id}}); err != nil {
+		return &Result{Error: err}
 	}
-	return &Result{nil, nil}
+	return &Result{}
 }
 
-func (d *Database) update(id int64, object any) *Result {
-	bytes, err := common.ToBytes(object)
+func (d *Database) update(id int64, object any, collection string, version int64) *Result {
+	bytes, err := d.codec.Encode(object)
 	if err != nil {
-		return &Result{nil, err}
+		return &Result{Error: err}
 	}
-	record, exists := d.state.Records[id]
-	if !exists {
-		return &Result{nil, fmt.Errorf("record with id %d not found", id)}
+	existing, err := d.lookup(id, collection)
+	if err != nil {
+		return &Result{Error: err}
 	}
+	if version != 0 && existing.Version != version {
+		return &Result{Value: existing, Error: fmt.Errorf("record with id %d is at version %d, not %d: %w", id, existing.Version, version, ErrConflict)}
+	}
+	record, err := d.writeRecord(id, bytes)
+	if err != nil {
+		return &Result{Error: err}
+	}
+	if err := d.log(walEntry{"update", record}); err != nil {
+		return &Result{Error: err}
+	}
+	return &Result{Value: d.state.Records[id]}
+}
+
+func (d *Database) endOffset() (int64, error) {
+	return d.file.Size()
+}
+
+// writeRecord appends a new version of a record to the data file
+func (d *Database) writeRecord(id int64, bytes []byte) (Record, error) {
 	offset, err := d.endOffset()
 	if err != nil {
-		return &Result{nil, err}
+		return Record{}, fmt.Errorf("failed to write record %d: %w", id, err)
 	}
 	length, err := d.file.WriteAt(bytes, offset)
 	if err != nil {
-		return &Result{nil, err}
+		return Record{}, fmt.Errorf("failed to write record %d: %w", id, err)
 	}
-	record.Offset = offset
-	record.Length = int64(length)
-	return &Result{record, nil}
+	return Record{Id: id, Offset: offset, Length: int64(length)}, nil
 }
 
-func (d *Database) endOffset() (int64, error) {
-	return d.file.Seek(0, io.SeekEnd)
+// readRecord reads the serialized record, a record past the end of the file is corrupted
+func readRecord(file File, record *Record) ([]byte, error) {
+	bytes := make([]byte, record.Length)
+	if _, err := file.ReadAt(bytes, record.Offset); errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: record %d is outside the data file", ErrCorrupted, record.Id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read record %d: %w", record.Id, err)
+	}
+	return bytes, nil
 }
 
-func (d *Database) Create(input any) *Result {
-	reply := make(chan *Result)
-	d.commands <- command{action: "insert", input: input, reply: reply}
-	return <-reply
+// decode fails with ErrCorrupted, stored records are expected to decode into the type they were created from
+func (d *Database) decode(record *Record, raw []byte, output any) error {
+	if err := d.codec.Decode(raw, output); err != nil {
+		return fmt.Errorf("%w: record %d: %w", ErrCorrupted, record.Id, err)
+	}
+	return nil
 }
 
-func (d *Database) Read(id int64, output any) *Result {
-	reply := make(chan *Result)
-	d.commands <- command{action: "find", id: id, output: output, reply: reply}
-	return <-reply
+// execute passes a command to the run loop and waits for its result until ctx is
+// done. A command still queued when ctx expires is skipped by the run loop, one
+// already running completes.
+func (d *Database) execute(ctx context.Context, cmd command) *Result {
+	start := time.Now()
+	result := d.send(ctx, cmd)
+	if operation, measured := measuredActions[cmd.action]; measured {
+		id := cmd.id
+		if result.Value != nil {
+			id = result.Value.Id
+		}
+		d.observe(operation, id, start, result.Error)
+	}
+	return result
+}
+
+func (d *Database) send(ctx context.Context, cmd command) *Result {
+	cmd.ctx = ctx
+	cmd.reply = concurrency.NewFuture[*Record]()
+	select {
+	case <-d.closing:
+		return &Result{Error: ErrClosed}
+	default:
+	}
+	select {
+	case d.commands <- cmd:
+	case <-d.closing:
+		return &Result{Error: ErrClosed}
+	case <-ctx.Done():
+		return &Result{Error: ctx.Err()}
+	}
+	select {
+	case <-cmd.reply.Done():
+	case <-d.stopped:
+		// The run loop replies before it stops, no reply means the command came too late
+		cmd.reply.Reject(ErrClosed)
+	case <-ctx.Done():
+	}
+	record, err := cmd.reply.Get(ctx)
+	return &Result{Value: record, Error: err}
+}
+
+func (d *Database) Create(ctx context.Context, input any) *Result {
+	return d.execute(ctx, command{action: "insert", input: input})
+}
+
+// CreateWithTTL creates a record that disappears after ttl, PersistForever keeps it
+func (d *Database) CreateWithTTL(ctx context.Context, input any, ttl time.Duration) *Result {
+	return d.execute(ctx, command{action: "insert", input: input, ttl: ttl})
+}
+
+// Read runs in the calling goroutine against the latest snapshot, concurrently
+// with other reads and with writes
+func (d *Database) Read(ctx context.Context, id int64, output any) *Result {
+	start := time.Now()
+	result := d.read(ctx, id, output)
+	d.observe("read", id, start, result.Error)
+	return result
+}
+
+func (d *Database) read(ctx context.Context, id int64, output any) *Result {
+	if err := ctx.Err(); err != nil {
+		return &Result{Error: err}
+	}
+	record, raw, err := d.readSnapshot(id)
+	if err != nil {
+		return &Result{Error: err}
+	}
+	if err := d.decode(record, raw, output); err != nil {
+		return &Result{Error: err}
+	}
+	return &Result{Value: record}
 }
 
-func (d *Database) Delete(id int64) *Result {
-	reply := make(chan *Result)
-	d.commands <- command{action: "delete", id: id, reply: reply}
-	return <-reply
+func (d *Database) Delete(ctx context.Context, id int64) *Result {
+	return d.execute(ctx, command{action: "delete", id: id})
 }
 
-func (d *Database) Update(id int64, input any) *Result {
-	reply := make(chan *Result)
-	d.commands <- command{action: "update", id: id, input: input, reply: reply}
-	return <-reply
+func (d *Database) Update(ctx context.Context, id int64, input any) *Result {
+	return d.execute(ctx, command{action: "update", id: id, input: input})
+}
+
+// UpdateIf updates a record only if it is still at the given version, otherwise
+// the error wraps ErrConflict and the result holds the current record
+func (d *Database) UpdateIf(ctx context.Context, id int64, version int64, input any) *Result {
+	return d.execute(ctx, command{action: "update", id: id, version: version, input: input})
 }
 
 func DatabaseTest() {
 	db := Db("users.db", &Sequence{})
 	defer db.Close()
 	go db.run()
+	ctx := context.Background()
 
 	user := User{"Jan", "Kowalski", 25, true}
-	result := db.Create(&user)
-	fmt.Println(result.Record, result.Error)
+	result := db.Create(ctx, &user)
+	fmt.Println(result.Value, result.Error)
 
 	user.IsActive = false
-	result = db.Update(result.Record.Id, &user)
-	fmt.Println(result.Record, result.Error)
+	result = db.Update(ctx, result.Value.Id, &user)
+	fmt.Println(result.Value, result.Error)
 
 	loadedUser := &User{}
-	result = db.Read(result.Record.Id, loadedUser)
-	fmt.Println(result.Record, result.Error, loadedUser)
+	result = db.Read(ctx, result.Value.Id, loadedUser)
+	fmt.Println(result.Value, result.Error, loadedUser)
 
-	result = db.Delete(result.Record.Id)
-	fmt.Println(result.Record, result.Error)
+	result = db.Delete(ctx, result.Value.Id)
+	fmt.Println(result.Value, result.Error)
 }
 
 type User struct {
@@ -233,21 +741,59 @@ type User struct {
 }
 
 func DatabaseExercise() {
-	db := Db("users.db", &Sequence{})
+	db := Db("users.db", &Sequence{}, WithSlowLog(100*time.Millisecond))
 	defer db.Close()
 	go db.run()
+	db.AutoCompact(time.Minute, 0.5)
+	catchFatal(CreateFieldIndex[User](db, "LastName"), "Failed to create index")
+	catchFatal(CreateFieldIndex[User](db, "Age"), "Failed to create index")
 
+	expvar.Publish("db", expvar.Func(func() any { return db.Metrics() }))
+	listener, err := net.Listen("tcp", ":8079")
+	catchFatal(err, "Failed to listen for replicas")
+	defer listener.Close()
+	go db.ServeReplicas(listener)
+	newRouter(db).Run(":8080")
+}
+
+// ReplicaExercise serves read requests on :8081 from a copy of the database
+// of DatabaseExercise, writes are rejected with 405 Method Not Allowed
+func ReplicaExercise() {
+	db := Db("replica.db", &Sequence{})
+	defer db.Close()
+	go db.run()
+	catchFatal(CreateFieldIndex[User](db, "LastName"), "Failed to create index")
+	catchFatal(CreateFieldIndex[User](db, "Age"), "Failed to create index")
+	go func() {
+		for {
+			err := db.Follow(context.Background(), "localhost:8079")
+			log.Printf("%v, reconnecting", err)
+			time.Sleep(time.Second)
+		}
+	}()
+	newRouter(db).Run(":8081")
+}
+
+func newRouter(db *Database) *gin.Engine {
+	users := NewCollection[User](db, "users")
 	router := gin.Default()
 	router.Use(func(c *gin.Context) {
 		c.Set("db", db)
+		c.Set("users", users)
 	})
 
 	router.POST("/users", createUser)
+	router.GET("/users", listUsers)
+	router.GET("/users/search", searchUsers)
 	router.GET("/users/:id", getUser)
 	router.PUT("/users/:id", updateUser)
 	router.DELETE("/users/:id", deleteUser)
-
-	router.Run(":8080")
+	// Admin endpoints have no authentication, don't expose the example beyond localhost
+	router.GET("/admin/backup", backupDatabase)
+	router.POST("/admin/restore", restoreDatabase)
+	// Metrics of the database next to the memory statistics of the runtime
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	return router
 }
 
 func getDb(c *gin.Context) *Database {
@@ -255,6 +801,29 @@ func getDb(c *gin.Context) *Database {
 	return db.(*Database)
 }
 
+func getUsers(c *gin.Context) *Collection[User] {
+	users, _ := c.Get("users")
+	return users.(*Collection[User])
+}
+
+// errorStatus maps database errors to HTTP statuses
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrDuplicateID):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidBackup):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrReadOnly):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 type CreateUserResponse struct {
 	Id int64
 }
@@ -266,13 +835,13 @@ func createUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
-	result := getDb(c).Create(user)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+	id, err := getUsers(c).Insert(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{})
 		return
 	}
-	c.Header("Location", fmt.Sprintf("/api/users/%d", result.Record.Id))
-	c.JSON(http.StatusCreated, &CreateUserResponse{result.Record.Id})
+	c.Header("Location", fmt.Sprintf("/api/users/%d", id))
+	c.JSON(http.StatusCreated, &CreateUserResponse{id})
 }
 
 func getUser(c *gin.Context) {
@@ -281,13 +850,141 @@ func getUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
-	user := User{}
-	result := getDb(c).Read(id, &user)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+	entry, err := getUsers(c).GetEntry(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{})
+		return
+	}
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(entry.Version, 10)))
+	c.JSON(http.StatusOK, &entry.Value)
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type UsersPage struct {
+	Users []FoundUser
+	Total int
+	Next  string `json:",omitempty"` // Link to the next page, empty on the last one
+}
+
+// listUsers handles /users?offset=&limit=&lastName=, a lastName filter uses the index
+func listUsers(c *gin.Context) {
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	limit, err := queryInt(c, "limit", defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
-	c.JSON(http.StatusOK, &user)
+	var users []FoundUser
+	if lastName, ok := c.GetQuery("lastName"); ok {
+		users, err = findUsers(c, "User.LastName", lastName)
+	} else {
+		users, err = allUsers(c)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{})
+		return
+	}
+	page := UsersPage{Users: users[min(offset, len(users)):min(offset+limit, len(users))], Total: len(users)}
+	if offset+limit < len(users) {
+		query := c.Request.URL.Query()
+		query.Set("offset", strconv.Itoa(offset+limit))
+		query.Set("limit", strconv.Itoa(limit))
+		page.Next = (&url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}).String()
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, page.Next))
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func queryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	text, ok := c.GetQuery(name)
+	if !ok {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(text)
+}
+
+func allUsers(c *gin.Context) ([]FoundUser, error) {
+	entries, err := getUsers(c).List(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	users := make([]FoundUser, len(entries))
+	for i, entry := range entries {
+		users[i] = FoundUser{entry.Id, entry.Value}
+	}
+	return users, nil
+}
+
+// findUsers loads the users the index finds for value
+func findUsers(c *gin.Context, index string, value any) ([]FoundUser, error) {
+	ids, err := getDb(c).Find(index, value)
+	if err != nil {
+		return nil, err
+	}
+	return loadUsers(c, ids)
+}
+
+func loadUsers(c *gin.Context, ids []int64) ([]FoundUser, error) {
+	users := []FoundUser{}
+	for _, id := range ids {
+		user, err := getUsers(c).Get(c.Request.Context(), id)
+		if errors.Is(err, ErrNotFound) {
+			continue // Deleted since the index was queried
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, FoundUser{id, user})
+	}
+	return users, nil
+}
+
+type FoundUser struct {
+	Id int64
+	User
+}
+
+// searchUsers handles /users/search?lastName=&minAge=&maxAge=, all parameters are optional
+func searchUsers(c *gin.Context) {
+	db := getDb(c)
+	var ids []int64
+	var err error
+	if lastName, ok := c.GetQuery("lastName"); ok {
+		ids, err = db.Find("User.LastName", lastName)
+	} else {
+		var minAge, maxAge any
+		if minAge, err = optionalInt(c, "minAge"); err == nil {
+			if maxAge, err = optionalInt(c, "maxAge"); err == nil {
+				ids, err = db.FindRange("User.Age", minAge, maxAge)
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	users, err := loadUsers(c, ids)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func optionalInt(c *gin.Context, name string) (any, error) {
+	text, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+	return strconv.Atoi(text)
 }
 
 func updateUser(c *gin.Context) {
@@ -302,9 +999,19 @@ func updateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
-	result := getDb(c).Update(id, &user)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+	// With If-Match set to the ETag from GET the update fails when someone else changed the user meanwhile
+	var version int64
+	if match := c.GetHeader("If-Match"); match != "" {
+		if unquoted, err := strconv.Unquote(match); err == nil {
+			match = unquoted
+		}
+		if version, err = strconv.ParseInt(match, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+	}
+	if err = getUsers(c).UpdateIf(c.Request.Context(), id, version, user); err != nil {
+		c.JSON(errorStatus(err), gin.H{})
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -316,9 +1023,26 @@ func deleteUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
-	result := getDb(c).Delete(id)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+	if err := getUsers(c).Delete(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func backupDatabase(c *gin.Context) {
+	c.Header("Content-Type", "application/x-tar")
+	c.Header("Content-Disposition", `attachment; filename="users.db.tar"`)
+	c.Status(http.StatusOK)
+	if err := getDb(c).Backup(c.Writer); err != nil {
+		// The status is already sent, the client sees a truncated archive
+		log.Printf("Backup failed: %v", err)
+	}
+}
+
+func restoreDatabase(c *gin.Context) {
+	if err := getDb(c).Restore(c.Request.Body); err != nil {
+		c.JSON(errorStatus(err), gin.H{})
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -352,7 +1076,7 @@ func Db(filepath string, idGenerator IdGenerator) *Database {
 	if err != nil {
 		state = DatabaseState{Records: make(map[int64]*Record), LastId: 0}
 	} else {
-		catchFatal(common.FromBytes(bytes, &state), "Failed reading database state")
+		catchFatal(codec.DecodeInto(bytes, &state), "Failed reading database state")
 	}
 	return &Database{file, &sync.Mutex{}, &state, idGenerator}
 }
@@ -376,7 +1100,7 @@ func (d *Database) Close() {
 }
 
 func (d *Database) saveState() error {
-	bytes, err := common.ToBytes(d.state)
+	bytes, err := codec.Encode(d.state)
 	if err != nil {
 		return err
 	}
@@ -384,7 +1108,7 @@ func (d *Database) saveState() error {
 }
 
 func (d *Database) Create(object any) (*Record, error) {
-	bytes, err := common.ToBytes(object)
+	bytes, err := codec.Encode(object)
 	if err != nil {
 		return nil, err
 	}
@@ -423,7 +1147,7 @@ func (d *Database) Read(id int64, object any) (*Record, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = common.FromBytes(bytes, object)
+	err = codec.DecodeInto(bytes, object)
 	if err != nil {
 		return nil, err
 	}
@@ -445,7 +1169,7 @@ func (d *Database) Delete(id int64) error {
 }
 
 func (d *Database) Update(id int64, object any) (*Record, error) {
-	bytes, err := common.ToBytes(object)
+	bytes, err := codec.Encode(object)
 	if err != nil {
 		return nil, err
 	}
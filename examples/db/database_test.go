@@ -0,0 +1,384 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"training.pl/go/common/codec"
+)
+
+var ctx = context.Background()
+
+func openTestDb(t *testing.T, path string, idGenerator IdGenerator) *Database {
+	t.Helper()
+	db := Db(path, idGenerator)
+	go db.run()
+	return db
+}
+
+// crash stops the database without saving its state, like a killed process
+func crash(db *Database) {
+	close(db.closing)
+	<-db.stopped
+	db.file.Close()
+	db.wal.close()
+	db.lock.Close() // Released by the system when a process dies
+}
+
+func TestRecoveryAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	first := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	second := db.Create(ctx, &User{"Anna", "Nowak", 30, true})
+	if first.Error != nil || second.Error != nil {
+		t.Fatalf("Create failed: %v, %v", first.Error, second.Error)
+	}
+	if result := db.Update(ctx, first.Value.Id, &User{"Jan", "Kowalski", 26, false}); result.Error != nil {
+		t.Fatalf("Update failed: %v", result.Error)
+	}
+	if result := db.Delete(ctx, second.Value.Id); result.Error != nil {
+		t.Fatalf("Delete failed: %v", result.Error)
+	}
+	crash(db)
+	bytes, _ := os.ReadFile(path + stateFileSuffix)
+	var saved DatabaseState
+	if err := codec.DecodeInto(bytes, &saved); err != nil || len(saved.Records) != 0 {
+		t.Fatalf("Expected no saved records before recovery, got %v, %v", saved.Records, err)
+	}
+
+	db = openTestDb(t, path, &Sequence{counter: 2})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, first.Value.Id, &user); result.Error != nil {
+		t.Fatalf("Read after recovery failed: %v", result.Error)
+	}
+	if user.Age != 26 || user.IsActive {
+		t.Errorf("Expected the updated user, got %v", user)
+	}
+	if result := db.Read(ctx, second.Value.Id, &User{}); result.Error == nil {
+		t.Errorf("Expected deleted record %d to stay deleted", second.Value.Id)
+	}
+	if info, err := os.Stat(path + walFileSuffix); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty log after recovery, got %v, %v", info, err)
+	}
+}
+
+// An update is logged like any other change, it used to reach the disk only with the next create or delete
+func TestUpdateSurvivesCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	created := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	db.Close()
+
+	db = openTestDb(t, path, &Sequence{counter: 1})
+	for age := int16(26); age <= 30; age++ {
+		if result := db.Update(ctx, created.Value.Id, &User{"Jan", "Kowalski", age, true}); result.Error != nil {
+			t.Fatalf("Update failed: %v", result.Error)
+		}
+	}
+	crash(db)
+
+	db = openTestDb(t, path, &Sequence{counter: 1})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, created.Value.Id, &user); result.Error != nil || user.Age != 30 {
+		t.Errorf("Expected the last update after a crash, got %v, %v", user, result.Error)
+	}
+}
+
+// A crash after the new version of a record was written to the data file but
+// before the update was logged leaves the old version, in the records and the indexes
+func TestInterruptedUpdate(t *testing.T) {
+	var fail atomic.Bool
+	backend := faultyBackend{NewMemoryBackend(), &fail}
+	open := func() *Database {
+		db, err := Open("users.db", &Sequence{counter: 1}, WithBackend(backend))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		go db.run()
+		if err := CreateFieldIndex[User](db, "LastName"); err != nil {
+			t.Fatalf("CreateIndex failed: %v", err)
+		}
+		return db
+	}
+	expect := func(db *Database, id int64, lastName string) {
+		t.Helper()
+		user := User{}
+		if result := db.Read(ctx, id, &user); result.Error != nil || user.LastName != lastName {
+			t.Errorf("Read = %v, %v; want %s", user, result.Error, lastName)
+		}
+		for _, name := range []string{"Kowalski", "Nowak"} {
+			ids, _ := db.Find("User.LastName", name)
+			if indexed := slices.Contains(ids, id); indexed != (name == lastName) {
+				t.Errorf("Find(%s) = %v", name, ids)
+			}
+		}
+	}
+
+	db := open()
+	id := db.Create(ctx, &User{"Jan", "Kowalski", 25, true}).Value.Id
+	fail.Store(true)
+	if result := db.Update(ctx, id, &User{"Jan", "Nowak", 26, true}); result.Error == nil {
+		t.Fatal("Expected the update to fail")
+	}
+	expect(db, id, "Kowalski")
+	crash(db)
+	fail.Store(false)
+
+	db = open()
+	defer db.Close()
+	expect(db, id, "Kowalski")
+	if result := db.Update(ctx, id, &User{"Jan", "Nowak", 26, true}); result.Error != nil {
+		t.Fatalf("Update after recovery failed: %v", result.Error)
+	}
+	expect(db, id, "Nowak")
+}
+
+func TestRecoveryIgnoresTornEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	created := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	if created.Error != nil {
+		t.Fatalf("Create failed: %v", created.Error)
+	}
+	crash(db)
+
+	// A crash in the middle of appending leaves a partial entry behind
+	wal, err := os.OpenFile(path+walFileSuffix, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Write([]byte{0, 0, 1, 0, 42, 42})
+	wal.Close()
+
+	db = openTestDb(t, path, &Sequence{counter: 1})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, created.Value.Id, &user); result.Error != nil || user.FirstName != "Jan" {
+		t.Errorf("Expected the record logged before the torn entry, got %v, %v", user, result.Error)
+	}
+}
+
+func TestCloseSavesState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	created := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	db.Close()
+
+	if info, err := os.Stat(path + walFileSuffix); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty log after Close, got %v, %v", info, err)
+	}
+	db = openTestDb(t, path, &Sequence{counter: 1})
+	defer db.Close()
+	if result := db.Read(ctx, created.Value.Id, &User{}); result.Error != nil {
+		t.Errorf("Read after reopening failed: %v", result.Error)
+	}
+}
+
+func TestExpiredCommandsAreSkipped(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+
+	// Keep the run loop busy so the next command waits in the queue
+	release := make(chan struct{})
+	scanning := make(chan struct{})
+	createUsers(t, db, 1)
+	go db.ForEach(func(id int64, raw []byte) error {
+		close(scanning)
+		<-release
+		return nil
+	})
+	<-scanning
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if result := db.Create(timeout, &User{"Jan", "Kowalski", 25, true}); !errors.Is(result.Error, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to expire, got %v", result.Error)
+	}
+	close(release)
+
+	count := 0
+	db.ForEach(func(id int64, raw []byte) error {
+		count++
+		return nil
+	})
+	if count != 1 {
+		t.Errorf("Expected the expired create to be skipped, found %d records", count)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if result := db.Read(cancelled, 1, &User{}); !errors.Is(result.Error, context.Canceled) {
+		t.Errorf("Expected a cancelled read to fail, got %v", result.Error)
+	}
+}
+
+func TestUpdateIf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	created := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	if created.Value.Version != 1 {
+		t.Errorf("Expected a new record at version 1, got %d", created.Value.Version)
+	}
+	updated := db.UpdateIf(ctx, created.Value.Id, 1, &User{"Jan", "Kowalski", 26, true})
+	if updated.Error != nil || updated.Value.Version != 2 {
+		t.Fatalf("UpdateIf at the current version = %v, %v", updated.Value, updated.Error)
+	}
+	stale := db.UpdateIf(ctx, created.Value.Id, 1, &User{"Jan", "Kowalski", 99, true})
+	if !errors.Is(stale.Error, ErrConflict) || stale.Value.Version != 2 {
+		t.Errorf("Expected a conflict for a stale version, got %v, %v", stale.Value, stale.Error)
+	}
+	crash(db)
+
+	db = openTestDb(t, path, &Sequence{counter: 1})
+	defer db.Close()
+	user := User{}
+	result := db.Read(ctx, created.Value.Id, &user)
+	if result.Error != nil || result.Value.Version != 2 || user.Age != 26 {
+		t.Errorf("Expected version 2 after recovery, got %v, %v, %v", result.Value, user, result.Error)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 2)
+	db.Close()
+
+	// A generator going down hands out ids that are taken
+	db = openTestDb(t, path, &countdown{3})
+	tests := []struct {
+		name     string
+		result   *Result
+		expected error
+	}{
+		{"Read missing", db.Read(ctx, 99, &User{}), ErrNotFound},
+		{"Update missing", db.Update(ctx, 99, &User{}), ErrNotFound},
+		{"Delete missing", db.Delete(ctx, 99), ErrNotFound},
+		{"Duplicate id", db.Create(ctx, &User{}), ErrDuplicateID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.result.Error, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.result.Error)
+			}
+		})
+	}
+	db.Close()
+
+	if err := os.Truncate(path, fileSize(t, path)-1); err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDb(t, path, &Sequence{counter: 2})
+	defer db.Close()
+	if result := db.Read(ctx, ids[1], &User{}); !errors.Is(result.Error, ErrCorrupted) {
+		t.Errorf("Expected a truncated record to be corrupted, got %v", result.Error)
+	}
+	if result := db.Read(ctx, ids[0], &User{}); result.Error != nil {
+		t.Errorf("Expected other records to stay readable, got %v", result.Error)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{notFound(1), http.StatusNotFound},
+		{duplicateID(1), http.StatusConflict},
+		{fmt.Errorf("record 1: %w", ErrConflict), http.StatusConflict},
+		{fmt.Errorf("%w: data missing", ErrInvalidBackup), http.StatusBadRequest},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{ErrClosed, http.StatusServiceUnavailable},
+		{ErrCorrupted, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if status := errorStatus(tt.err); status != tt.expected {
+			t.Errorf("errorStatus(%v) = %d; want %d", tt.err, status, tt.expected)
+		}
+	}
+}
+
+func TestCloseDrainsPendingCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	results := make(chan *Result, 200)
+	var writers sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			results <- db.Create(ctx, &User{"Jan", "Kowalski", int16(i), true})
+		}()
+	}
+	db.Close()
+	writers.Wait()
+	close(results)
+	db.Close() // Closing twice does nothing
+
+	var created []int64
+	for result := range results {
+		switch {
+		case result.Error == nil:
+			created = append(created, result.Value.Id)
+		case !errors.Is(result.Error, ErrClosed):
+			t.Errorf("Expected a write to succeed or fail with ErrClosed, got %v", result.Error)
+		}
+	}
+	if result := db.Read(ctx, 1, &User{}); !errors.Is(result.Error, ErrClosed) {
+		t.Errorf("Expected a read after Close to fail with ErrClosed, got %v", result.Error)
+	}
+	if result := db.Delete(ctx, 1); !errors.Is(result.Error, ErrClosed) {
+		t.Errorf("Expected a write after Close to fail with ErrClosed, got %v", result.Error)
+	}
+
+	db = openTestDb(t, path, &Sequence{counter: 200})
+	defer db.Close()
+	for _, id := range created {
+		if result := db.Read(ctx, id, &User{}); result.Error != nil {
+			t.Errorf("Expected record %d written before Close to be saved, got %v", id, result.Error)
+		}
+	}
+}
+
+func TestCheckpointCostPerWrite(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.closeFiles()
+
+	tests := []struct {
+		name    string
+		records int
+		logged  int
+		due     bool
+	}{
+		{"Small database", 10, checkpointInterval - 1, false},
+		{"Small database at the interval", 10, checkpointInterval, true},
+		{"Large database at the interval", 5000, checkpointInterval, false},
+		{"Large database logged its size", 5000, 5000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make(map[int64]*Record, tt.records)
+			for id := range int64(tt.records) {
+				records[id] = &Record{Id: id}
+			}
+			db.state.Records = records
+			db.wal.entries = tt.logged
+			if due := db.checkpointDue(); due != tt.due {
+				t.Errorf("checkpointDue() = %v; want %v", due, tt.due)
+			}
+		})
+	}
+}
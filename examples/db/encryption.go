@@ -0,0 +1,101 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// keyCheckText is encrypted into the state, so a wrong key is noticed on open
+// and not only when reading the first record
+const keyCheckText = "training.pl/go/examples/db"
+
+// WithEncryptionKey encrypts every record with AES-GCM, the key must have 16, 24
+// or 32 bytes. A database keeps the key it was created with.
+func WithEncryptionKey(key []byte) Option {
+	return func(d *Database) {
+		d.encryptionKey = key
+	}
+}
+
+// encryptedCodec seals the output of another codec, every record gets its own
+// random nonce stored in front of the ciphertext
+type encryptedCodec struct {
+	inner Codec
+	aead  cipher.AEAD
+}
+
+func newEncryptedCodec(inner Codec, key []byte) (*encryptedCodec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedCodec{inner, aead}, nil
+}
+
+func (c *encryptedCodec) Id() string {
+	return c.inner.Id() + "+aes-gcm"
+}
+
+func (c *encryptedCodec) Encode(value any) ([]byte, error) {
+	plain, err := c.inner.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(plain)
+}
+
+func (c *encryptedCodec) Decode(data []byte, value any) error {
+	plain, err := c.open(data)
+	if err != nil {
+		return err
+	}
+	return c.inner.Decode(plain, value)
+}
+
+func (c *encryptedCodec) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (c *encryptedCodec) open(data []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("failed to decrypt record: data too short")
+	}
+	plain, err := c.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt record: wrong key or corrupted data")
+	}
+	return plain, nil
+}
+
+func (d *Database) setKeyCheck() error {
+	codec, ok := d.codec.(*encryptedCodec)
+	if !ok {
+		return nil
+	}
+	check, err := codec.seal([]byte(keyCheckText))
+	d.state.KeyCheck = check
+	return err
+}
+
+func (d *Database) verifyKey(state *DatabaseState) error {
+	codec, ok := d.codec.(*encryptedCodec)
+	if !ok || len(state.KeyCheck) == 0 {
+		return nil
+	}
+	if plain, err := codec.open(state.KeyCheck); err != nil || string(plain) != keyCheckText {
+		return errors.New("wrong encryption key")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := Open(path, &Sequence{}, WithEncryptionKey(testKey))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go db.run()
+	jan := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	twin := db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	if err := CreateFieldIndex[User](db, "LastName"); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	if found, _ := db.Find("User.LastName", "Kowalski"); !slices.Equal(found, []int64{jan.Value.Id, twin.Value.Id}) {
+		t.Errorf("Find on encrypted records = %v", found)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("Kowalski")) {
+		t.Error("Expected the data file not to contain plaintext")
+	}
+	first := data[jan.Value.Offset : jan.Value.Offset+jan.Value.Length]
+	second := data[twin.Value.Offset : twin.Value.Offset+twin.Value.Length]
+	if bytes.Equal(first, second) {
+		t.Error("Expected equal records to be encrypted with different nonces")
+	}
+
+	db, err = Open(path, &Sequence{counter: 2}, WithEncryptionKey(testKey))
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	go db.run()
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, jan.Value.Id, &user); result.Error != nil || user != (User{"Jan", "Kowalski", 25, true}) {
+		t.Errorf("Read = %v, %v", user, result.Error)
+	}
+}
+
+func TestEncryptionKeyChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := Open(path, &Sequence{}, WithEncryptionKey(testKey))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go db.run()
+	db.Close()
+
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"No key", nil},
+		{"Wrong key", []Option{WithEncryptionKey([]byte("fedcba9876543210fedcba9876543210"))}},
+		{"Invalid key length", []Option{WithEncryptionKey([]byte("short"))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if db, err := Open(path, &Sequence{}, tt.options...); err == nil {
+				db.closeFiles()
+				t.Error("Expected opening to fail")
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsBackupWithAnotherKey(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "users.db"), &Sequence{}, WithEncryptionKey(testKey))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go db.run()
+	defer db.Close()
+	createUsers(t, db, 1)
+	var backup bytes.Buffer
+	if err := db.Backup(&backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	other, err := Open(filepath.Join(dir, "other.db"), &Sequence{}, WithEncryptionKey([]byte("fedcba9876543210")))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go other.run()
+	defer other.Close()
+	if err := other.Restore(&backup); err == nil {
+		t.Error("Expected a backup encrypted with another key to be rejected")
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PersistForever is the TTL of records that never expire
+const PersistForever time.Duration = 0
+
+// StartJanitor removes expired records every interval until Close. Expired
+// records are hidden from reads and scans as soon as they expire.
+func (d *Database) StartJanitor(interval time.Duration) {
+	d.every(interval, func() {
+		if result := d.RemoveExpired(context.Background()); result.Error != nil {
+			log.Printf("Removing expired records failed: %v", result.Error)
+		}
+	})
+}
+
+// RemoveExpired deletes all expired records at once
+func (d *Database) RemoveExpired(ctx context.Context) *Result {
+	return d.execute(ctx, command{action: "expire"})
+}
+
+func (d *Database) removeExpired() *Result {
+	now := time.Now()
+	var entries []walEntry
+	for id, record := range d.state.Records {
+		if record.expired(now) {
+			entries = append(entries, walEntry{"delete", Record{Id: id}})
+		}
+	}
+	if len(entries) == 0 {
+		return &Result{}
+	}
+	if err := d.log(entries...); err != nil {
+		return &Result{Error: err}
+	}
+	return &Result{}
+}
+
+// every calls fn in a background goroutine every interval until Close
+func (d *Database) every(interval time.Duration, fn func()) {
+	d.background.Add(1)
+	go func() {
+		defer d.background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stopBackground:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiringRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	db := openTestDb(t, path, &Sequence{})
+	session := db.CreateWithTTL(ctx, &User{"Jan", "Kowalski", 25, true}, 50*time.Millisecond)
+	forever := db.CreateWithTTL(ctx, &User{"Anna", "Nowak", 30, true}, PersistForever)
+	if session.Error != nil || forever.Error != nil {
+		t.Fatalf("CreateWithTTL failed: %v, %v", session.Error, forever.Error)
+	}
+	if result := db.Read(ctx, session.Value.Id, &User{}); result.Error != nil {
+		t.Errorf("Expected the session before it expires: %v", result.Error)
+	}
+	db.Close()
+
+	// The expiry time survives a restart
+	db = openTestDb(t, path, &Sequence{counter: 2})
+	defer db.Close()
+	time.Sleep(60 * time.Millisecond)
+	if result := db.Read(ctx, session.Value.Id, &User{}); result.Error == nil {
+		t.Error("Expected an expired record to be hidden before the janitor runs")
+	}
+	if result := db.Update(ctx, session.Value.Id, &User{}); result.Error == nil {
+		t.Error("Expected updating an expired record to fail")
+	}
+	if result := db.Read(ctx, forever.Value.Id, &User{}); result.Error != nil {
+		t.Errorf("Expected a record without TTL to stay: %v", result.Error)
+	}
+
+	db.StartJanitor(10 * time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		db.snapshotLock.RLock()
+		stored := db.snapshot.records.len()
+		db.snapshotLock.RUnlock()
+		if stored == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the janitor to remove the expired record")
+}
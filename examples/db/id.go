@@ -1,7 +1,17 @@
 package db
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
 type IdGenerator interface {
 	next() int64
+	// resume is called when a database is opened or restored with the highest id
+	// in use, so generators don't hand out ids that are taken after a restart
+	resume(lastId int64)
 }
 
 type Sequence struct {
@@ -12,3 +22,93 @@ func (s *Sequence) next() int64 {
 	s.counter++
 	return s.counter
 }
+
+func (s *Sequence) resume(lastId int64) {
+	s.counter = max(s.counter, lastId)
+}
+
+// RandomIds hands out random positive ids, the counterpart of version 4 UUIDs.
+// Records are keyed by int64, so an id has 63 random bits instead of 122.
+type RandomIds struct{}
+
+func (RandomIds) next() int64 {
+	return randomBits(63)
+}
+
+func (RandomIds) resume(int64) {}
+
+// TimeOrderedIds hands out ids growing with time like version 7 UUIDs: the
+// milliseconds since 1970 in the upper 42 bits and random lower 21 bits
+type TimeOrderedIds struct {
+	last int64
+}
+
+func (g *TimeOrderedIds) next() int64 {
+	id := time.Now().UnixMilli()<<21 | randomBits(21)
+	if id <= g.last {
+		// Several ids in one millisecond or a clock set back
+		id = g.last + 1
+	}
+	g.last = id
+	return id
+}
+
+func (g *TimeOrderedIds) resume(lastId int64) {
+	g.last = max(g.last, lastId)
+}
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	maxSnowflakeNode      = 1<<snowflakeNodeBits - 1
+	maxSnowflakeSequence  = 1<<snowflakeSequenceBits - 1
+)
+
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake hands out ids unique across up to 1024 nodes writing to copies of a
+// database: milliseconds since 2020 in the upper 41 bits, then the node and a sequence
+// number for ids created in the same millisecond
+type Snowflake struct {
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > maxSnowflakeNode {
+		return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", maxSnowflakeNode, node)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+func (s *Snowflake) next() int64 {
+	// The time never goes back, ids stay unique when the clock is set back
+	now := max(time.Since(snowflakeEpoch).Milliseconds(), s.lastTime)
+	if now == s.lastTime {
+		s.sequence++
+		if s.sequence > maxSnowflakeSequence {
+			now++
+			s.sequence = 0
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastTime = now
+	return now<<(snowflakeNodeBits+snowflakeSequenceBits) | s.node<<snowflakeSequenceBits | s.sequence
+}
+
+// resume continues in the millisecond after lastId, so new ids are greater
+// whichever node created lastId
+func (s *Snowflake) resume(lastId int64) {
+	if lastTime := lastId>>(snowflakeNodeBits+snowflakeSequenceBits) + 1; lastTime > s.lastTime {
+		s.lastTime = lastTime
+		s.sequence = 0
+	}
+}
+
+func randomBits(count int) int64 {
+	var bytes [8]byte
+	rand.Read(bytes[:]) // Never fails, it crashes the program instead
+	return int64(binary.BigEndian.Uint64(bytes[:]) >> (64 - count))
+}
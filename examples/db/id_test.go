@@ -0,0 +1,69 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSequenceResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 3)
+	db.Close()
+
+	db = openTestDb(t, path, &Sequence{})
+	defer db.Close()
+	result := db.Create(ctx, &User{"Anna", "Nowak", 30, true})
+	if result.Error != nil || result.Value.Id != ids[2]+1 {
+		t.Errorf("Expected the sequence to continue after %d, got %v, %v", ids[2], result.Value, result.Error)
+	}
+}
+
+func TestIdGenerators(t *testing.T) {
+	snowflake, err := NewSnowflake(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		generator IdGenerator
+		ordered   bool
+	}{
+		{"Sequence", &Sequence{}, true},
+		{"Random", RandomIds{}, false},
+		{"Time ordered", &TimeOrderedIds{}, true},
+		{"Snowflake", snowflake, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[int64]bool)
+			var last int64
+			for i := 0; i < 10000; i++ {
+				id := tt.generator.next()
+				if id < 0 || seen[id] {
+					t.Fatalf("Expected unique positive ids, got %d", id)
+				}
+				if tt.ordered && id <= last {
+					t.Fatalf("Expected growing ids, got %d after %d", id, last)
+				}
+				seen[id] = true
+				last = id
+			}
+			// Ids already in the database are never handed out again
+			tt.generator.resume(last + 1000)
+			if id := tt.generator.next(); tt.ordered && id <= last+1000 {
+				t.Errorf("Expected an id after %d once resumed, got %d", last+1000, id)
+			}
+		})
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	snowflake, _ := NewSnowflake(5)
+	if node := snowflake.next() >> snowflakeSequenceBits & maxSnowflakeNode; node != 5 {
+		t.Errorf("Expected the node in the id, got %d", node)
+	}
+	if _, err := NewSnowflake(maxSnowflakeNode + 1); err == nil {
+		t.Error("Expected an invalid node to be rejected")
+	}
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// KeyFunc extracts the indexed value from a serialized record, false leaves the
+// record out of the index
+type KeyFunc func(raw []byte) (any, bool)
+
+type indexEntry struct {
+	key any
+	id  int64
+}
+
+// index keeps record ids sorted by key, so exact and range lookups are binary searches
+type index struct {
+	keyFunc KeyFunc
+	entries []indexEntry
+	keys    map[int64]any
+}
+
+type indexDefinition struct {
+	name    string
+	keyFunc KeyFunc
+}
+
+type indexQuery struct {
+	index    string
+	from, to any // Inclusive bounds, nil is unbounded
+}
+
+// CreateIndex indexes all current and future records by the value keyFunc extracts
+func (d *Database) CreateIndex(name string, keyFunc KeyFunc) error {
+	return d.execute(context.Background(), command{action: "createIndex", input: indexDefinition{name, keyFunc}}).Error
+}
+
+// CreateFieldIndex indexes records of type T by a field, the index is named
+// after both, e.g. CreateFieldIndex[User](db, "LastName") creates "User.LastName"
+func CreateFieldIndex[T any](d *Database, field string) error {
+	name := reflect.TypeFor[T]().Name() + "." + field
+	return d.CreateIndex(name, FieldKey[T](d.codec, field))
+}
+
+// FieldKey returns a KeyFunc reading a field of records of type T serialized with codec
+func FieldKey[T any](codec Codec, field string) KeyFunc {
+	return func(raw []byte) (any, bool) {
+		var value T
+		if err := codec.Decode(raw, &value); err != nil {
+			return nil, false
+		}
+		structValue := reflect.Indirect(reflect.ValueOf(value))
+		if structValue.Kind() != reflect.Struct {
+			return nil, false
+		}
+		fieldValue := structValue.FieldByName(field)
+		if !fieldValue.IsValid() {
+			return nil, false
+		}
+		return indexKey(fieldValue.Interface())
+	}
+}
+
+// Find returns the ids of records whose indexed value equals value
+func (d *Database) Find(index string, value any) ([]int64, error) {
+	return d.FindRange(index, value, value)
+}
+
+// FindRange returns the ids of records whose indexed value is between from and
+// to inclusive, ordered by the value; a nil bound leaves that side open
+func (d *Database) FindRange(index string, from, to any) ([]int64, error) {
+	var ids []int64
+	result := d.execute(context.Background(), command{action: "findIndex", input: indexQuery{index, from, to}, output: &ids})
+	return ids, result.Error
+}
+
+func (d *Database) createIndex(definition indexDefinition) *Result {
+	if _, exists := d.indexes[definition.name]; exists {
+		return &Result{Error: fmt.Errorf("index %s already exists", definition.name)}
+	}
+	idx := &index{keyFunc: definition.keyFunc}
+	if err := d.fillIndex(idx); err != nil {
+		return &Result{Error: err}
+	}
+	d.indexes[definition.name] = idx
+	return &Result{}
+}
+
+// fillIndex replaces the content of an index with all current records
+func (d *Database) fillIndex(idx *index) error {
+	idx.entries = nil
+	idx.keys = make(map[int64]any)
+	for _, record := range d.state.Records {
+		raw, err := d.readRaw(record)
+		if err != nil {
+			return err
+		}
+		idx.put(record.Id, raw)
+	}
+	return nil
+}
+
+func (d *Database) rebuildIndexes() error {
+	for _, idx := range d.indexes {
+		if err := d.fillIndex(idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) findIndex(query indexQuery, ids *[]int64) *Result {
+	idx, exists := d.indexes[query.index]
+	if !exists {
+		return &Result{Error: fmt.Errorf("index %s not found", query.index)}
+	}
+	bounds := []*any{&query.from, &query.to}
+	for _, bound := range bounds {
+		if *bound == nil {
+			continue
+		}
+		key, ok := indexKey(*bound)
+		if !ok {
+			return &Result{Error: fmt.Errorf("unsupported index value %v", *bound)}
+		}
+		*bound = key
+	}
+	*ids = idx.find(query.from, query.to)
+	return &Result{}
+}
+
+// updateIndexes keeps the indexes in line with a change applied to the state
+func (d *Database) updateIndexes(entry walEntry) {
+	if len(d.indexes) == 0 {
+		return
+	}
+	var raw []byte
+	if entry.Action != "delete" {
+		var err error
+		if raw, err = d.readRaw(&entry.Record); err != nil {
+			raw = nil
+		}
+	}
+	for _, idx := range d.indexes {
+		idx.remove(entry.Record.Id)
+		if raw != nil {
+			idx.put(entry.Record.Id, raw)
+		}
+	}
+}
+
+func (d *Database) readRaw(record *Record) ([]byte, error) {
+	return readRecord(d.file, record)
+}
+
+func (idx *index) put(id int64, raw []byte) {
+	key, ok := idx.keyFunc(raw)
+	if !ok {
+		return
+	}
+	entry := indexEntry{key, id}
+	position, _ := slices.BinarySearchFunc(idx.entries, entry, compareEntries)
+	idx.entries = slices.Insert(idx.entries, position, entry)
+	idx.keys[id] = key
+}
+
+func (idx *index) remove(id int64) {
+	key, exists := idx.keys[id]
+	if !exists {
+		return
+	}
+	if position, found := slices.BinarySearchFunc(idx.entries, indexEntry{key, id}, compareEntries); found {
+		idx.entries = slices.Delete(idx.entries, position, position+1)
+	}
+	delete(idx.keys, id)
+}
+
+func (idx *index) find(from, to any) []int64 {
+	start := 0
+	if from != nil {
+		start, _ = slices.BinarySearchFunc(idx.entries, from, func(entry indexEntry, key any) int {
+			return cmp.Or(compareKeys(entry.key, key), 1)
+		})
+	}
+	var ids []int64
+	for _, entry := range idx.entries[start:] {
+		if to != nil && compareKeys(entry.key, to) > 0 {
+			break
+		}
+		ids = append(ids, entry.id)
+	}
+	return ids
+}
+
+// indexKey normalizes an indexed value, so numbers of different types compare
+func indexKey(value any) (any, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return nil, false
+}
+
+func compareEntries(a, b indexEntry) int {
+	return cmp.Or(compareKeys(a.key, b.key), cmp.Compare(a.id, b.id))
+}
+
+// compareKeys orders keys of one kind by value, and of different kinds booleans
+// before numbers before strings
+func compareKeys(a, b any) int {
+	switch x := a.(type) {
+	case bool:
+		if y, ok := b.(bool); ok {
+			return cmp.Compare(boolRank(x), boolRank(y))
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	return cmp.Compare(kindRank(a), kindRank(b))
+}
+
+func boolRank(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func kindRank(key any) int {
+	switch key.(type) {
+	case bool:
+		return 0
+	case float64:
+		return 1
+	}
+	return 2
+}
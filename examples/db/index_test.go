@@ -0,0 +1,89 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type Product struct {
+	Name  string
+	Price float64
+}
+
+func TestFindByIndex(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	jan := db.Create(ctx, &User{"Jan", "Kowalski", 25, true}).Value.Id
+	anna := db.Create(ctx, &User{"Anna", "Nowak", 30, true}).Value.Id
+	db.Create(ctx, &Product{"Book", 10})
+	if err := CreateFieldIndex[User](db, "LastName"); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	if err := CreateFieldIndex[User](db, "LastName"); err == nil {
+		t.Error("Expected creating a duplicate index to fail")
+	}
+	piotr := db.Create(ctx, &User{"Piotr", "Kowalski", 40, false}).Value.Id
+
+	tests := []struct {
+		name     string
+		value    string
+		expected []int64
+	}{
+		{"Existing and new records", "Kowalski", []int64{jan, piotr}},
+		{"Single match", "Nowak", []int64{anna}},
+		{"No match", "Wiśniewski", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := db.Find("User.LastName", tt.value)
+			if err != nil || !slices.Equal(ids, tt.expected) {
+				t.Errorf("Find(%q) = %v, %v; want %v", tt.value, ids, err, tt.expected)
+			}
+		})
+	}
+
+	db.Update(ctx, jan, &User{"Jan", "Nowak", 25, true})
+	db.Delete(ctx, piotr)
+	if ids, _ := db.Find("User.LastName", "Kowalski"); len(ids) != 0 {
+		t.Errorf("Expected updated and deleted records to leave the index, got %v", ids)
+	}
+	if ids, _ := db.Find("User.LastName", "Nowak"); !slices.Equal(ids, []int64{jan, anna}) {
+		t.Errorf("Expected the updated record under its new value, got %v", ids)
+	}
+	if _, err := db.Find("User.FirstName", "Jan"); err == nil {
+		t.Error("Expected a query on a missing index to fail")
+	}
+}
+
+func TestFindRange(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	ids := createUsers(t, db, 5) // Ages 20 to 24
+	if err := CreateFieldIndex[User](db, "Age"); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to any
+		expected []int64
+	}{
+		{"Closed range", 21, 23, ids[1:4]},
+		{"Open start", nil, int16(21), ids[:2]},
+		{"Open end", 23.5, nil, ids[4:]},
+		{"Everything", nil, nil, ids},
+		{"Empty", 30, 40, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := db.FindRange("User.Age", tt.from, tt.to)
+			if err != nil || !slices.Equal(found, tt.expected) {
+				t.Errorf("FindRange(%v, %v) = %v, %v; want %v", tt.from, tt.to, found, err, tt.expected)
+			}
+		})
+	}
+	if _, err := db.FindRange("User.Age", []int{1}, nil); err == nil {
+		t.Error("Expected an unsupported bound to fail")
+	}
+}
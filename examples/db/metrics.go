@@ -0,0 +1,82 @@
+package db
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Upper bounds of the latency histogram buckets, slower operations only count in "+Inf"
+var latencyBuckets = []time.Duration{100 * time.Microsecond, time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, time.Second}
+
+// measuredActions maps run loop actions to the operations metrics are kept for
+var measuredActions = map[string]string{"insert": "create", "update": "update", "delete": "delete"}
+
+type operationMetrics struct {
+	count   atomic.Int64
+	errors  atomic.Int64
+	latency atomic.Int64 // Total in nanoseconds
+	buckets []atomic.Int64
+}
+
+// OperationStats is a snapshot of the metrics of one operation. Buckets are
+// cumulative like in Prometheus, "1ms" counts the operations done within 1ms.
+type OperationStats struct {
+	Count        int64
+	Errors       int64
+	TotalLatency time.Duration
+	Buckets      map[string]int64
+}
+
+// WithSlowLog logs every create, read, update and delete taking longer than threshold
+func WithSlowLog(threshold time.Duration) Option {
+	return func(d *Database) {
+		d.slowThreshold = threshold
+	}
+}
+
+func newMetrics() map[string]*operationMetrics {
+	metrics := make(map[string]*operationMetrics)
+	for _, operation := range []string{"create", "read", "update", "delete"} {
+		metrics[operation] = &operationMetrics{buckets: make([]atomic.Int64, len(latencyBuckets)+1)}
+	}
+	return metrics
+}
+
+// observe records an operation started at start, safe to call from any goroutine
+func (d *Database) observe(operation string, id int64, start time.Time, err error) {
+	elapsed := time.Since(start)
+	metrics := d.metrics[operation]
+	metrics.count.Add(1)
+	if err != nil {
+		metrics.errors.Add(1)
+	}
+	metrics.latency.Add(int64(elapsed))
+	bucket := 0
+	for bucket < len(latencyBuckets) && elapsed > latencyBuckets[bucket] {
+		bucket++
+	}
+	metrics.buckets[bucket].Add(1)
+	if d.slowThreshold > 0 && elapsed > d.slowThreshold {
+		log.Printf("Slow %s of record %d took %v (error: %v)", operation, id, elapsed, err)
+	}
+}
+
+// Metrics returns the counters and latency histograms of create, read, update and delete
+func (d *Database) Metrics() map[string]OperationStats {
+	stats := make(map[string]OperationStats, len(d.metrics))
+	for operation, metrics := range d.metrics {
+		buckets := make(map[string]int64, len(metrics.buckets))
+		var total int64
+		for i := range metrics.buckets {
+			total += metrics.buckets[i].Load()
+			if i < len(latencyBuckets) {
+				buckets[latencyBuckets[i].String()] = total
+			} else {
+				buckets["+Inf"] = total
+			}
+		}
+		stats[operation] = OperationStats{metrics.count.Load(), metrics.errors.Load(), time.Duration(metrics.latency.Load()), buckets}
+	}
+	return stats
+}
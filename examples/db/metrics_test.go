@@ -0,0 +1,60 @@
+package db
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	users := NewCollection[User](db, "users")
+	ids := createUsers(t, db, 2)
+	id, _ := users.Insert(ctx, User{"Anna", "Nowak", 30, true})
+	db.Read(ctx, ids[0], &User{})
+	users.Get(ctx, id)
+	db.Read(ctx, 99, &User{})
+	db.Update(ctx, ids[0], &User{"Jan", "Nowak", 25, true})
+	db.Delete(ctx, ids[1])
+
+	tests := []struct {
+		operation     string
+		count, errors int64
+	}{
+		{"create", 3, 0},
+		{"read", 3, 1},
+		{"update", 1, 0},
+		{"delete", 1, 0},
+	}
+	metrics := db.Metrics()
+	for _, tt := range tests {
+		stats := metrics[tt.operation]
+		if stats.Count != tt.count || stats.Errors != tt.errors {
+			t.Errorf("%s: count %d, errors %d; want %d, %d", tt.operation, stats.Count, stats.Errors, tt.count, tt.errors)
+		}
+		if stats.Buckets["+Inf"] != tt.count || stats.Buckets["100µs"] > stats.Buckets["1s"] {
+			t.Errorf("%s: expected cumulative buckets, got %v", tt.operation, stats.Buckets)
+		}
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+	db, err := Open(filepath.Join(t.TempDir(), "users.db"), &Sequence{}, WithSlowLog(time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go db.run()
+	defer db.Close()
+
+	db.Create(ctx, &User{"Jan", "Kowalski", 25, true})
+	if !strings.Contains(output.String(), "Slow create of record 1") {
+		t.Errorf("Expected the create to be logged, got %q", output.String())
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import "fmt"
+
+// Migration upgrades the records written with the previous version of the schema,
+// e.g. when a struct stored in the database changes
+type Migration func(m *Migrator) error
+
+// Migrator gives a migration access to the records, the changes are logged
+// together with the new schema version once the migration returns
+type Migrator struct {
+	db      *Database
+	entries []walEntry
+}
+
+// WithMigrations runs the migrations the database is missing when it is opened,
+// migrations[i] upgrades it from schema version i to i+1. Migrations are only
+// ever added to the end of the list.
+func WithMigrations(migrations ...Migration) Option {
+	return func(d *Database) {
+		d.migrations = append([]Migration{}, migrations...) // Not nil, even without migrations
+	}
+}
+
+// MigrateRecords converts the records of a collection that decode as Old, the
+// empty collection stands for all records. Keep the Old type around under another
+// name, e.g. userV1, for as long as databases with the old schema may exist.
+func MigrateRecords[Old, New any](collection string, convert func(Old) New) Migration {
+	return func(m *Migrator) error {
+		return m.ForEach(collection, func(id int64, raw []byte) error {
+			var old Old
+			if err := m.db.codec.Decode(raw, &old); err != nil {
+				return nil
+			}
+			return m.Update(id, convert(old))
+		})
+	}
+}
+
+// ForEach passes the records of a collection to fn in insertion order, the
+// empty collection stands for all records
+func (m *Migrator) ForEach(collection string, fn func(id int64, raw []byte) error) error {
+	return m.db.forEach(func(record *Record, raw []byte) error {
+		if collection != "" && record.Collection != collection {
+			return nil
+		}
+		return fn(record.Id, raw)
+	}).Error
+}
+
+func (m *Migrator) Update(id int64, value any) error {
+	bytes, err := m.db.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	record, err := m.db.writeRecord(id, bytes)
+	if err != nil {
+		return err
+	}
+	m.entries = append(m.entries, walEntry{"update", record})
+	return nil
+}
+
+func (m *Migrator) Delete(id int64) error {
+	m.entries = append(m.entries, walEntry{"delete", Record{Id: id}})
+	return nil
+}
+
+// migrate runs during Open, before the run loop starts. Each migration is logged
+// in one frame with its version, so a crash never leaves one half applied.
+func (d *Database) migrate() error {
+	if d.migrations == nil {
+		return nil // Opened without WithMigrations
+	}
+	if d.state.SchemaVersion > len(d.migrations) {
+		return fmt.Errorf("database is at schema version %d, only %d migrations are known", d.state.SchemaVersion, len(d.migrations))
+	}
+	for version := d.state.SchemaVersion; version < len(d.migrations); version++ {
+		migrator := &Migrator{db: d}
+		if err := d.migrations[version](migrator); err != nil {
+			return fmt.Errorf("migration to version %d failed: %w", version+1, err)
+		}
+		entries := append(migrator.entries, walEntry{"schema", Record{Version: int64(version + 1)}})
+		if err := d.log(entries...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
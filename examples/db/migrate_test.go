@@ -0,0 +1,65 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// userV1 is how User was stored before the name was split
+type userV1 struct {
+	Name string
+	Age  int16
+}
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	v1 := NewCollection[userV1](db, "users")
+	jan, _ := v1.Insert(ctx, userV1{"Jan Kowalski", 25})
+	book := db.Create(ctx, &Product{"Book", 10}).Value.Id
+	db.Close()
+
+	converted := 0
+	migrations := []Migration{
+		MigrateRecords("users", func(user userV1) User {
+			converted++
+			firstName, lastName, _ := strings.Cut(user.Name, " ")
+			return User{firstName, lastName, user.Age, true}
+		}),
+	}
+	open := func(migrations ...Migration) (*Database, error) {
+		db, err := Open(path, &Sequence{}, WithMigrations(migrations...))
+		if err == nil {
+			go db.run()
+		}
+		return db, err
+	}
+	db, err := open(migrations...)
+	if err != nil {
+		t.Fatalf("Open with migrations failed: %v", err)
+	}
+	user, err := NewCollection[User](db, "users").Get(ctx, jan)
+	if err != nil || user != (User{"Jan", "Kowalski", 25, true}) {
+		t.Errorf("Expected a migrated user, got %v, %v", user, err)
+	}
+	product := Product{}
+	if result := db.Read(ctx, book, &product); result.Error != nil || product.Name != "Book" {
+		t.Errorf("Expected records of other collections untouched, got %v, %v", product, result.Error)
+	}
+	crash(db)
+
+	// The migration survived the crash and doesn't run again
+	db, err = open(migrations...)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if converted != 1 || db.state.SchemaVersion != 1 {
+		t.Errorf("Expected one migration run, got %d runs, version %d", converted, db.state.SchemaVersion)
+	}
+	db.Close()
+
+	if _, err := open(); err == nil {
+		t.Error("Expected a database with a newer schema than the known migrations to fail opening")
+	}
+}
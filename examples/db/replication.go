@@ -0,0 +1,174 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+)
+
+// ErrReadOnly is returned for writes to a replica, they go to the primary
+var ErrReadOnly = errors.New("database is a read-only replica")
+
+// Changes waiting to be sent to a replica, a replica falling further behind is
+// disconnected and gets a new copy of the database when it connects again
+const replicaQueueSize = 1000
+
+// Actions a replica refuses, its records only change by replication
+var writeActions = map[string]bool{"insert": true, "update": true, "delete": true, "commit": true, "expire": true, "restore": true}
+
+// replicatedEntry carries the serialized record along, the records have
+// different offsets in the data files of the primary and the replicas
+type replicatedEntry struct {
+	Entry walEntry
+	Data  []byte
+}
+
+// ServeReplicas sends every replica connecting to listener a copy of the database
+// followed by all later changes. It returns when listener is closed.
+func (d *Database) ServeReplicas(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go d.serveReplica(conn)
+	}
+}
+
+func (d *Database) serveReplica(conn net.Conn) {
+	defer conn.Close()
+	// The copy and the queue are taken together, so no change is missed or sent twice
+	var source backupSource
+	queue := make(chan []replicatedEntry, replicaQueueSize)
+	if result := d.execute(context.Background(), command{action: "subscribe", input: queue, output: &source}); result.Error != nil {
+		log.Printf("Replica %s not served: %v", conn.RemoteAddr(), result.Error)
+		return
+	}
+	defer d.execute(context.Background(), command{action: "unsubscribe", input: queue})
+
+	writer := bufio.NewWriter(conn)
+	err := writeBackup(writer, &source)
+	encoder := gob.NewEncoder(writer)
+	for err == nil {
+		if len(queue) == 0 {
+			if err = writer.Flush(); err != nil {
+				break
+			}
+		}
+		changes, ok := <-queue
+		if !ok {
+			log.Printf("Replica %s disconnected, it fell behind or the database was closed or restored", conn.RemoteAddr())
+			return
+		}
+		err = encoder.Encode(changes)
+	}
+	log.Printf("Replication to %s failed: %v", conn.RemoteAddr(), err)
+}
+
+// Follow turns the database into a read-only replica of the primary serving
+// replicas at address. It loads a copy of the primary and applies its changes
+// until ctx is done or the connection breaks, then it can be called again.
+func (d *Database) Follow(ctx context.Context, address string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	d.readOnly.Store(true)
+	stream := bufio.NewReader(conn)
+	err = d.restoreBackup(stream, "resync")
+	decoder := gob.NewDecoder(stream)
+	for err == nil {
+		var changes []replicatedEntry
+		if err = decoder.Decode(&changes); err == nil {
+			err = d.execute(ctx, command{action: "replicate", input: changes}).Error
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("replication from %s stopped: %w", address, err)
+}
+
+func (d *Database) subscribe(queue chan []replicatedEntry, source *backupSource) *Result {
+	if result := d.backup(source); result.Error != nil {
+		return result
+	}
+	d.replicas = append(d.replicas, queue)
+	return &Result{}
+}
+
+func (d *Database) unsubscribe(queue chan []replicatedEntry) *Result {
+	d.replicas = slices.DeleteFunc(d.replicas, func(replica chan []replicatedEntry) bool {
+		return replica == queue
+	})
+	return &Result{}
+}
+
+// ship queues logged entries for the replicas, called by log
+func (d *Database) ship(entries []walEntry) {
+	if len(d.replicas) == 0 {
+		return
+	}
+	changes := make([]replicatedEntry, len(entries))
+	for i, entry := range entries {
+		changes[i].Entry = entry
+		if !entry.hasData() {
+			continue
+		}
+		raw, err := d.readRaw(&entry.Record)
+		if err != nil {
+			log.Printf("Reading a change for replicas failed: %v", err)
+			d.dropReplicas()
+			return
+		}
+		changes[i].Data = raw
+	}
+	d.replicas = slices.DeleteFunc(d.replicas, func(queue chan []replicatedEntry) bool {
+		select {
+		case queue <- changes:
+			return false
+		default:
+			close(queue)
+			return true
+		}
+	})
+}
+
+func (d *Database) dropReplicas() {
+	for _, queue := range d.replicas {
+		close(queue)
+	}
+	d.replicas = nil
+}
+
+// replicate writes the records received from the primary to the data file and
+// logs the changes in one frame, like the primary did
+func (d *Database) replicate(changes []replicatedEntry) *Result {
+	entries := make([]walEntry, len(changes))
+	for i, change := range changes {
+		entry := change.Entry
+		if entry.hasData() {
+			record, err := d.writeRecord(entry.Record.Id, change.Data)
+			if err != nil {
+				return &Result{Error: err}
+			}
+			record.Collection = entry.Record.Collection
+			record.ExpiresAt = entry.Record.ExpiresAt
+			entry.Record = record
+		}
+		entries[i] = entry
+	}
+	return &Result{Error: d.log(entries...)}
+}
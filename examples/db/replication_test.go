@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// eventually waits for a condition met by a replica in the background
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func follow(db *Database, address string) (context.CancelFunc, chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- db.Follow(ctx, address) }()
+	return cancel, done
+}
+
+func TestReplication(t *testing.T) {
+	dir := t.TempDir()
+	primary := openTestDb(t, filepath.Join(dir, "primary.db"), &Sequence{})
+	defer primary.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go primary.ServeReplicas(listener)
+	users := NewCollection[User](primary, "users")
+	jan, _ := users.Insert(ctx, User{"Jan", "Kowalski", 25, true})
+	anna, _ := users.Insert(ctx, User{"Anna", "Nowak", 30, true})
+
+	replica := openTestDb(t, filepath.Join(dir, "replica.db"), &Sequence{})
+	defer replica.Close()
+	if err := CreateFieldIndex[User](replica, "LastName"); err != nil {
+		t.Fatal(err)
+	}
+	replicatedUsers := NewCollection[User](replica, "users")
+	cancel, done := follow(replica, listener.Addr().String())
+	eventually(t, "the copy", func() bool {
+		_, err := replicatedUsers.Get(ctx, anna)
+		return err == nil
+	})
+
+	users.Update(ctx, jan, User{"Jan", "Nowak", 26, true})
+	users.Delete(ctx, anna)
+	piotr, _ := users.Insert(ctx, User{"Piotr", "Nowak", 40, false})
+	eventually(t, "the changes", func() bool {
+		list, _ := replicatedUsers.List(ctx)
+		return len(list) == 2 && list[0].Value.Age == 26 && list[0].Version == 2 && list[1].Id == piotr
+	})
+	if found, _ := replica.Find("User.LastName", "Nowak"); !slices.Equal(found, []int64{jan, piotr}) {
+		t.Errorf("Expected the replica to keep its indexes, found %v", found)
+	}
+	if _, err := replicatedUsers.Insert(ctx, User{"Ewa", "Lis", 20, true}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected writes to the replica to fail with ErrReadOnly, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Follow to stop when cancelled, got %v", err)
+	}
+	// Changes made while disconnected arrive with the next copy
+	users.Delete(ctx, piotr)
+	cancel, done = follow(replica, listener.Addr().String())
+	defer func() { cancel(); <-done }()
+	eventually(t, "the catch up", func() bool {
+		list, _ := replicatedUsers.List(ctx)
+		return len(list) == 1
+	})
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ForEach passes every record to fn in insertion order and stops at the first
+// error, which it returns. Writes wait until the scan ends, so fn must not use
+// the database.
+func (d *Database) ForEach(fn func(id int64, raw []byte) error) error {
+	return d.scanRecords(context.Background(), func(record *Record, raw []byte) error {
+		return fn(record.Id, raw)
+	})
+}
+
+func (d *Database) scanRecords(ctx context.Context, fn func(*Record, []byte) error) error {
+	return d.execute(ctx, command{action: "forEach", input: fn}).Error
+}
+
+// Scan passes every record that decodes as T to fn in insertion order, records
+// the codec cannot decode as T are skipped
+func Scan[T any](d *Database, fn func(id int64, value T) error) error {
+	return d.ForEach(func(id int64, raw []byte) error {
+		var value T
+		if err := d.codec.Decode(raw, &value); err != nil {
+			return nil
+		}
+		return fn(id, value)
+	})
+}
+
+func (d *Database) forEach(fn func(*Record, []byte) error) *Result {
+	ordered := slices.SortedFunc(maps.Values(d.state.Records), func(a, b *Record) int {
+		return cmp.Or(cmp.Compare(a.Seq, b.Seq), cmp.Compare(a.Id, b.Id))
+	})
+	now := time.Now()
+	for _, record := range ordered {
+		if record.expired(now) {
+			continue
+		}
+		raw, err := d.readRaw(record)
+		if err != nil {
+			return &Result{Value: record, Error: err}
+		}
+		if err := fn(record, raw); err != nil {
+			return &Result{Value: record, Error: err}
+		}
+	}
+	return &Result{}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// countdown hands out decreasing ids, so id order differs from insertion order
+type countdown struct {
+	counter int64
+}
+
+func (c *countdown) next() int64 {
+	c.counter--
+	return c.counter
+}
+
+func (c *countdown) resume(int64) {}
+
+func scannedNames(t *testing.T, db *Database) []string {
+	t.Helper()
+	var names []string
+	err := Scan(db, func(id int64, user User) error {
+		names = append(names, user.FirstName)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	return names
+}
+
+func TestScanInInsertionOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &countdown{100})
+	first := db.Create(ctx, &User{"Jan", "Kowalski", 25, true}).Value.Id
+	db.Create(ctx, &Product{"Book", 10})
+	second := db.Create(ctx, &User{"Anna", "Nowak", 30, true}).Value.Id
+	db.Create(ctx, &User{"Piotr", "Zieliński", 40, true})
+	db.Update(ctx, first, &User{"Janusz", "Kowalski", 25, true})
+	db.Delete(ctx, second)
+
+	expected := []string{"Janusz", "Piotr"}
+	if names := scannedNames(t, db); !slices.Equal(names, expected) {
+		t.Errorf("Scan returned %v; want %v", names, expected)
+	}
+	db.Compact()
+	crash(db)
+
+	db = openTestDb(t, path, &countdown{96})
+	defer db.Close()
+	if names := scannedNames(t, db); !slices.Equal(names, expected) {
+		t.Errorf("Scan after compaction and recovery returned %v; want %v", names, expected)
+	}
+}
+
+func TestForEachStopsOnError(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	createUsers(t, db, 3)
+
+	stop := errors.New("stop")
+	visited := 0
+	err := db.ForEach(func(id int64, raw []byte) error {
+		visited++
+		if visited == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || visited != 2 {
+		t.Errorf("ForEach returned %v after %d records; want stop after 2", err, visited)
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"time"
+
+	"training.pl/go/common"
+)
+
+// snapshot is an immutable view of the records for readers. Records are never
+// changed in place, the run loop replaces them in a persistent trie, so a
+// snapshot shares all but the changed paths with the previous one.
+type snapshot struct {
+	records recordTrie
+	file    File
+}
+
+// WithReadCache keeps up to maxBytes of recently read records in memory. Records
+// are never changed in place, so a cached record can't go stale.
+func WithReadCache(maxBytes int64) Option {
+	return func(d *Database) {
+		d.readCache = common.NewCache[*Record, []byte](0).WithMaxSize(maxBytes, func(raw []byte) int64 {
+			return int64(len(raw))
+		})
+	}
+}
+
+// publish makes the current state visible to readers, called by the run loop after every change
+func (d *Database) publish() {
+	current := &snapshot{d.records, d.file}
+	d.snapshotLock.Lock()
+	d.snapshot = current
+	d.snapshotLock.Unlock()
+}
+
+// readSnapshot reads a record with ReadAt, which is safe next to the appends of
+// the run loop. The lock only keeps compaction and Close from closing the file meanwhile.
+func (d *Database) readSnapshot(id int64) (*Record, []byte, error) {
+	d.snapshotLock.RLock()
+	defer d.snapshotLock.RUnlock()
+	if d.snapshot == nil {
+		return nil, nil, ErrClosed
+	}
+	record, exists := d.snapshot.records.get(id)
+	if !exists || record.expired(time.Now()) {
+		return nil, nil, notFound(id)
+	}
+	if d.readCache != nil {
+		if raw, ok := d.readCache.Get(record); ok {
+			return record, raw, nil
+		}
+	}
+	raw, err := readRecord(d.snapshot.file, record)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.readCache != nil {
+		d.readCache.Set(record, raw)
+	}
+	return record, raw, nil
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadsDuringWrites(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	ids := createUsers(t, db, 10)
+
+	done := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for age := int16(0); ; age++ {
+			select {
+			case <-done:
+				return
+			default:
+				db.Update(ctx, ids[0], &User{"Jan", "Kowalski", age, true})
+			}
+		}
+	}()
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for n := 0; n < 200; n++ {
+				user := User{}
+				if result := db.Read(ctx, ids[n%len(ids)], &user); result.Error != nil || user.LastName != "Kowalski" {
+					t.Errorf("Read during writes = %v, %v", user, result.Error)
+					return
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	if result := db.Compact(); result.Error != nil {
+		t.Errorf("Compact failed: %v", result.Error)
+	}
+	close(done)
+	writer.Wait()
+}
+
+func TestReadCache(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "users.db"), &Sequence{}, WithReadCache(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go db.run()
+	defer db.Close()
+	ids := createUsers(t, db, 2)
+
+	user := User{}
+	for range 2 {
+		if result := db.Read(ctx, ids[0], &user); result.Error != nil || user.Age != 20 {
+			t.Fatalf("Read = %v, %v", user, result.Error)
+		}
+	}
+	if db.readCache.Len() != 1 {
+		t.Errorf("Expected the read record to be cached, got %d entries", db.readCache.Len())
+	}
+	db.Update(ctx, ids[0], &User{"Anna", "Nowak", 30, false})
+	if result := db.Read(ctx, ids[0], &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Expected the updated record, got %v, %v", user, result.Error)
+	}
+	if result := db.Compact(); result.Error != nil {
+		t.Fatalf("Compact failed: %v", result.Error)
+	}
+	if result := db.Read(ctx, ids[0], &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Expected the record after compaction, got %v, %v", user, result.Error)
+	}
+	db.Delete(ctx, ids[0])
+	if result := db.Read(ctx, ids[0], &user); result.Error == nil {
+		t.Error("Expected a deleted record not to be served from the cache")
+	}
+}
+
+// The baseline emulates the former design where a read waited in the run loop
+// behind every write, and a write behind every read: both hold the same lock.
+// Besides the read latency the benchmark reports the writes done meanwhile.
+func benchmarkReads(b *testing.B, serialized bool) {
+	db, err := Open(filepath.Join(b.TempDir(), "users.db"), &Sequence{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	go db.run()
+	defer db.Close()
+	var ids []int64
+	for i := 0; i < 100; i++ {
+		ids = append(ids, db.Create(ctx, &User{"Jan", "Kowalski", int16(i), true}).Value.Id)
+	}
+
+	var runLoop sync.Mutex
+	var stop atomic.Bool
+	var writes atomic.Int64
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for i := 0; !stop.Load(); i++ {
+			if serialized {
+				runLoop.Lock()
+			}
+			db.Update(ctx, ids[i%len(ids)], &User{"Anna", "Nowak", int16(i), false})
+			if serialized {
+				runLoop.Unlock()
+			}
+			writes.Add(1)
+		}
+	}()
+
+	b.ResetTimer()
+	user := User{}
+	for i := 0; i < b.N; i++ {
+		if serialized {
+			runLoop.Lock()
+		}
+		db.Read(ctx, ids[i%len(ids)], &user)
+		if serialized {
+			runLoop.Unlock()
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(writes.Load())/b.Elapsed().Seconds(), "writes/s")
+	stop.Store(true)
+	writer.Wait()
+}
+
+func BenchmarkReadWhileWriting(b *testing.B) {
+	b.Run("snapshot", func(b *testing.B) { benchmarkReads(b, false) })
+	b.Run("serialized", func(b *testing.B) { benchmarkReads(b, true) })
+}
+
+// Every write publishes a snapshot for readers, which must not make writes
+// slower as the database grows
+func BenchmarkWriteAsRecordsGrow(b *testing.B) {
+	for _, records := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("records=%d", records), func(b *testing.B) {
+			db, err := Open("users.db", &Sequence{}, WithBackend(NewMemoryBackend()))
+			if err != nil {
+				b.Fatal(err)
+			}
+			go db.run()
+			defer db.Close()
+			var ids []int64
+			inputs := make([]any, 1000)
+			for len(ids) < records {
+				for i := range inputs {
+					inputs[i] = &User{"Jan", "Kowalski", int16(i), true}
+				}
+				results, err := db.CreateBatch(ctx, inputs)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, result := range results {
+					ids = append(ids, result.Value.Id)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if result := db.Update(ctx, ids[i%len(ids)], &User{"Anna", "Nowak", int16(i), false}); result.Error != nil {
+					b.Fatal(result.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package db
+
+import "math/bits"
+
+// trieBits is the number of id bits every level of a recordTrie consumes
+const trieBits = 5
+
+// recordTrie is a persistent map of records by id, a hash array mapped trie
+// keyed by the bits of the id, the lowest first. Changing it copies only the
+// few nodes on the path to the record and shares the rest with the trie it was
+// changed from, which stays as it was. So readers can keep every version as a
+// snapshot while a write copies a handful of nodes even for millions of records.
+// The zero value is an empty trie.
+type recordTrie struct {
+	root *trieNode
+	size int
+}
+
+// trieNode keeps the children of the used slots out of 32, in slot order
+type trieNode struct {
+	bitmap   uint32
+	children []trieChild
+}
+
+// trieChild is either a record or the node of the next level
+type trieChild struct {
+	record *Record
+	node   *trieNode
+}
+
+func newRecordTrie(records map[int64]*Record) recordTrie {
+	var trie recordTrie
+	for _, record := range records {
+		trie = trie.set(record)
+	}
+	return trie
+}
+
+func (t recordTrie) len() int {
+	return t.size
+}
+
+func (t recordTrie) get(id int64) (*Record, bool) {
+	node, key := t.root, uint64(id)
+	for shift := uint(0); node != nil; shift += trieBits {
+		slot := slotOf(key, shift)
+		if node.bitmap&slot == 0 {
+			return nil, false
+		}
+		child := node.children[node.index(slot)]
+		if child.node == nil {
+			return child.record, child.record.Id == id
+		}
+		node = child.node
+	}
+	return nil, false
+}
+
+// set returns a trie with record added or replacing the one with its id
+func (t recordTrie) set(record *Record) recordTrie {
+	root, added := t.root.with(uint64(record.Id), 0, record)
+	if added {
+		t.size++
+	}
+	return recordTrie{root, t.size}
+}
+
+// delete returns a trie without the record with the id
+func (t recordTrie) delete(id int64) recordTrie {
+	root, removed := t.root.without(uint64(id), 0, id)
+	if !removed {
+		return t
+	}
+	return recordTrie{root, t.size - 1}
+}
+
+// slotOf returns the bit of the slot the key takes at the level of shift
+func slotOf(key uint64, shift uint) uint32 {
+	return 1 << (key >> shift & (1<<trieBits - 1))
+}
+
+// index returns the position of the child of a slot among the children
+func (n *trieNode) index(slot uint32) int {
+	return bits.OnesCount32(n.bitmap & (slot - 1))
+}
+
+// copied returns a copy of the node with room for one more child, a nil node copies as an empty one
+func (n *trieNode) copied() *trieNode {
+	if n == nil {
+		return &trieNode{}
+	}
+	children := make([]trieChild, len(n.children), len(n.children)+1)
+	copy(children, n.children)
+	return &trieNode{n.bitmap, children}
+}
+
+// with returns a copy of the node holding record and whether the id is new
+func (n *trieNode) with(key uint64, shift uint, record *Record) (*trieNode, bool) {
+	slot := slotOf(key, shift)
+	node := n.copied()
+	i := node.index(slot)
+	if node.bitmap&slot == 0 {
+		node.bitmap |= slot
+		node.children = append(node.children, trieChild{})
+		copy(node.children[i+1:], node.children[i:])
+		node.children[i] = trieChild{record: record}
+		return node, true
+	}
+
+	child := node.children[i]
+	switch {
+	case child.node != nil:
+		next, added := child.node.with(key, shift+trieBits, record)
+		node.children[i] = trieChild{node: next}
+		return node, added
+	case child.record.Id == record.Id:
+		node.children[i] = trieChild{record: record}
+		return node, false
+	}
+	// Both ids share the slot, the next level tells them apart
+	next, _ := (*trieNode)(nil).with(uint64(child.record.Id), shift+trieBits, child.record)
+	next, _ = next.with(key, shift+trieBits, record)
+	node.children[i] = trieChild{node: next}
+	return node, true
+}
+
+// without returns a copy of the node without the record with the id, nil when
+// nothing is left, and whether the record was there
+func (n *trieNode) without(key uint64, shift uint, id int64) (*trieNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	slot := slotOf(key, shift)
+	if n.bitmap&slot == 0 {
+		return n, false
+	}
+	i := n.index(slot)
+	child := n.children[i]
+	if child.node == nil {
+		if child.record.Id != id {
+			return n, false
+		}
+		return n.withoutChild(slot, i), true
+	}
+
+	next, removed := child.node.without(key, shift+trieBits, id)
+	switch {
+	case !removed:
+		return n, false
+	case next == nil:
+		return n.withoutChild(slot, i), true
+	}
+	node := n.copied()
+	if len(next.children) == 1 && next.children[0].node == nil {
+		// A record left alone moves up, where it is still told apart from the others
+		node.children[i] = next.children[0]
+	} else {
+		node.children[i] = trieChild{node: next}
+	}
+	return node, true
+}
+
+func (n *trieNode) withoutChild(slot uint32, i int) *trieNode {
+	if n.bitmap == slot {
+		return nil
+	}
+	children := make([]trieChild, 0, len(n.children)-1)
+	children = append(append(children, n.children[:i]...), n.children[i+1:]...)
+	return &trieNode{n.bitmap &^ slot, children}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// checkTrie compares a trie with the map it should hold
+func checkTrie(t *testing.T, trie recordTrie, expected map[int64]*Record) {
+	t.Helper()
+	if trie.len() != len(expected) {
+		t.Fatalf("len() = %d; want %d", trie.len(), len(expected))
+	}
+	for id, want := range expected {
+		if got, ok := trie.get(id); !ok || got != want {
+			t.Fatalf("get(%d) = %v, %v; want %v", id, got, ok, want)
+		}
+	}
+}
+
+func TestRecordTrie(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	tests := []struct {
+		name string
+		id   func() int64
+	}{
+		{"Small ids", func() int64 { return random.Int64N(2000) }},
+		{"High ids", func() int64 { return random.Int64N(2000) << 50 }}, // Told apart only by the last levels
+		{"Negative ids", func() int64 { return -random.Int64N(2000) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trie recordTrie
+			expected := make(map[int64]*Record)
+			for i := 0; i < 20000; i++ {
+				id := tt.id()
+				if random.IntN(3) == 0 {
+					trie = trie.delete(id)
+					delete(expected, id)
+				} else {
+					record := &Record{Id: id}
+					trie = trie.set(record)
+					expected[id] = record
+				}
+			}
+			checkTrie(t, trie, expected)
+			if _, ok := trie.get(1 << 40); ok {
+				t.Error("Found a record never added")
+			}
+		})
+	}
+}
+
+// Changing a trie leaves the versions readers hold as they were
+func TestRecordTrieIsPersistent(t *testing.T) {
+	records := make(map[int64]*Record)
+	for id := range int64(100) {
+		records[id] = &Record{Id: id}
+	}
+	before := newRecordTrie(records)
+	after := before.delete(7).set(&Record{Id: 8, Version: 2}).set(&Record{Id: 1000})
+
+	checkTrie(t, before, records)
+	if _, ok := after.get(7); ok || after.len() != 100 {
+		t.Errorf("Expected 7 deleted and 1000 added, got %d records", after.len())
+	}
+	if record, _ := after.get(8); record.Version != 2 {
+		t.Errorf("Expected 8 replaced, got %+v", record)
+	}
+}
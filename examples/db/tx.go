@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var errTxFinished = errors.New("transaction already committed or rolled back")
+
+type txOperation struct {
+	action string
+	id     int64
+	bytes  []byte
+}
+
+// Tx buffers writes until Commit, which applies all of them or none
+type Tx struct {
+	db         *Database
+	operations []txOperation
+	finished   bool
+}
+
+func (d *Database) Begin() *Tx {
+	return &Tx{db: d}
+}
+
+func (tx *Tx) Create(input any) error {
+	return tx.add("insert", 0, input)
+}
+
+func (tx *Tx) Update(id int64, input any) error {
+	return tx.add("update", id, input)
+}
+
+func (tx *Tx) Delete(id int64) error {
+	return tx.add("delete", id, nil)
+}
+
+func (tx *Tx) add(action string, id int64, input any) error {
+	if tx.finished {
+		return errTxFinished
+	}
+	operation := txOperation{action: action, id: id}
+	if input != nil {
+		bytes, err := tx.db.codec.Encode(input)
+		if err != nil {
+			return err
+		}
+		operation.bytes = bytes
+	}
+	tx.operations = append(tx.operations, operation)
+	return nil
+}
+
+// Commit returns a result per buffered operation, in the order they were made
+func (tx *Tx) Commit() ([]*Result, error) {
+	return tx.commit(context.Background())
+}
+
+func (tx *Tx) commit(ctx context.Context) ([]*Result, error) {
+	if tx.finished {
+		return nil, errTxFinished
+	}
+	tx.finished = true
+	var results []*Result
+	result := tx.db.execute(ctx, command{action: "commit", input: tx.operations, output: &results})
+	return results, result.Error
+}
+
+// CreateBatch creates all records or none, with a single write to the log
+func (d *Database) CreateBatch(ctx context.Context, inputs []any) ([]*Result, error) {
+	tx := d.Begin()
+	for _, input := range inputs {
+		if err := tx.Create(input); err != nil {
+			return nil, err
+		}
+	}
+	return tx.commit(ctx)
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.finished {
+		return errTxFinished
+	}
+	tx.finished = true
+	tx.operations = nil
+	return nil
+}
+
+func (d *Database) commit(operations []txOperation, results *[]*Result) *Result {
+	// Check the whole transaction before writing anything
+	deleted := make(map[int64]bool)
+	for _, operation := range operations {
+		if operation.action == "insert" {
+			continue
+		}
+		if _, err := d.lookup(operation.id, ""); err != nil {
+			return &Result{Error: err}
+		}
+		if deleted[operation.id] {
+			return &Result{Error: notFound(operation.id)}
+		}
+		if operation.action == "delete" {
+			deleted[operation.id] = true
+		}
+	}
+	if len(operations) == 0 {
+		return &Result{}
+	}
+
+	entries := make([]walEntry, 0, len(operations))
+	for _, operation := range operations {
+		id := operation.id
+		if operation.action == "insert" {
+			id = d.idGenerator.next()
+			if _, exists := d.state.Records[id]; exists {
+				return &Result{Error: duplicateID(id)}
+			}
+		}
+		if operation.action == "delete" {
+			entries = append(entries, walEntry{"delete", Record{Id: id}})
+			continue
+		}
+		record, err := d.writeRecord(id, operation.bytes)
+		if err != nil {
+			return &Result{Error: err}
+		}
+		entries = append(entries, walEntry{operation.action, record})
+	}
+	if err := d.log(entries...); err != nil {
+		return &Result{Error: err}
+	}
+
+	for _, entry := range entries {
+		*results = append(*results, &Result{Value: d.state.Records[entry.Record.Id]})
+	}
+	return &Result{}
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db := openTestDb(t, path, &Sequence{})
+	ids := createUsers(t, db, 2)
+
+	tx := db.Begin()
+	tx.Create(&User{"Anna", "Nowak", 30, true})
+	tx.Update(ids[0], &User{"Jan", "Kowalski", 40, false})
+	tx.Delete(ids[1])
+	results, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Value == nil || results[2].Value != nil {
+		t.Fatalf("Unexpected commit results %v", results)
+	}
+	created := results[0].Value.Id
+	crash(db)
+
+	db = openTestDb(t, path, &Sequence{counter: 3})
+	defer db.Close()
+	user := User{}
+	if result := db.Read(ctx, created, &user); result.Error != nil || user.FirstName != "Anna" {
+		t.Errorf("Expected the created user, got %v, %v", user, result.Error)
+	}
+	if result := db.Read(ctx, ids[0], &user); result.Error != nil || user.Age != 40 {
+		t.Errorf("Expected the updated user, got %v, %v", user, result.Error)
+	}
+	if result := db.Read(ctx, ids[1], &user); result.Error == nil {
+		t.Errorf("Expected record %d to be deleted", ids[1])
+	}
+}
+
+func TestTxFailedCommitAppliesNothing(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	ids := createUsers(t, db, 1)
+
+	tx := db.Begin()
+	tx.Delete(ids[0])
+	tx.Update(ids[0], &User{"Jan", "Kowalski", 40, false})
+	if _, err := tx.Commit(); err == nil {
+		t.Fatal("Expected updating a record deleted in the same transaction to fail")
+	}
+	user := User{}
+	if result := db.Read(ctx, ids[0], &user); result.Error != nil || user.Age != 20 {
+		t.Errorf("Expected the record to be untouched, got %v, %v", user, result.Error)
+	}
+	if _, err := tx.Commit(); err == nil {
+		t.Error("Expected a second commit to fail")
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	db := openTestDb(t, filepath.Join(t.TempDir(), "users.db"), &Sequence{})
+	defer db.Close()
+	ids := createUsers(t, db, 1)
+
+	tx := db.Begin()
+	tx.Delete(ids[0])
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if result := db.Read(ctx, ids[0], &User{}); result.Error != nil {
+		t.Errorf("Expected the record to survive the rollback: %v", result.Error)
+	}
+	if err := tx.Create(&User{}); err == nil {
+		t.Error("Expected writes after rollback to fail")
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"training.pl/go/common/codec"
+)
+
+const walFileSuffix = ".wal"
+
+// Minimum number of logged operations after which the state is saved and the
+// log emptied, larger databases wait for as many operations as they have records
+const checkpointInterval = 1000
+
+// Every entry is framed as: payload length, payload CRC-32, gob encoded payload
+const walHeaderSize = 8
+
+// walEntry logs an insert, update or delete of a record, or with the "schema"
+// action the schema version reached by a migration in Record.Version
+type walEntry struct {
+	Action string
+	Record Record
+}
+
+// hasData tells if the entry points at record data in the data file
+func (e walEntry) hasData() bool {
+	return e.Action == "insert" || e.Action == "update"
+}
+
+type writeAheadLog struct {
+	file    File
+	frames  int
+	entries int // Logged operations, a group commit or a batch writes many in a frame
+}
+
+func openWal(backend Backend, filepath string) (*writeAheadLog, error) {
+	file, err := backend.OpenFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return &writeAheadLog{file: file}, nil
+}
+
+// append writes entries as one frame, so they are replayed all or none, and
+// returns only after the frame reached the disk
+func (w *writeAheadLog) append(entries []walEntry) error {
+	payload, err := codec.Encode(entries)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	frame = append(frame, payload...)
+	size, err := w.file.Size()
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.WriteAt(frame, size); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.frames++
+	w.entries += len(entries)
+	return nil
+}
+
+// replay passes the logged entries to apply in order and returns the number of frames. A torn or corrupted
+// entry at the end, left by a crash in the middle of append, ends the replay
+// and is cut off the log.
+func (w *writeAheadLog) replay(apply func(walEntry)) (int, error) {
+	size, err := w.file.Size()
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(w.file, 0, size))
+	var valid int64
+	frames, count := 0, 0
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		if int64(length) > size-valid-walHeaderSize {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+		entries, err := codec.Decode[[]walEntry](payload)
+		if err != nil {
+			break
+		}
+		for _, entry := range entries {
+			apply(entry)
+		}
+		valid += walHeaderSize + int64(length)
+		frames++
+		count += len(entries)
+	}
+	if valid < size {
+		if err := w.file.Truncate(valid); err != nil {
+			return frames, err
+		}
+	}
+	w.frames, w.entries = frames, count
+	return frames, nil
+}
+
+func (w *writeAheadLog) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.frames, w.entries = 0, 0
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) close() error {
+	return w.file.Close()
+}
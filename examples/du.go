@@ -0,0 +1,198 @@
+package examples
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"training.pl/go/common"
+)
+
+type DuOptions struct {
+	Summarize bool // -s, prints only the total of every argument
+	Human     bool // -h, prints sizes like 1.5K instead of bytes
+	Top       int  // -top, reports the largest files instead of directories
+}
+
+type fileSize struct {
+	path string
+	size int64
+}
+
+// smaller orders files by size, and equal ones by path descending, so the
+// report lists them by path
+func smaller(a, b fileSize) bool {
+	return a.size < b.size || a.size == b.size && a.path > b.path
+}
+
+func Du() {
+	os.Exit(RunDu(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// RunDu prints the apparent size, the sum of file lengths, of every directory
+// under the paths in args, children before their parents, as "size<TAB>path"
+// lines other commands can read. The path "-" reads further paths from stdin,
+// one per line. It returns 0 on success, 1 if any path failed and 2 for invalid flags.
+func RunDu(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("du", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: du [-s] [-h] [-top n] [path ...]")
+		flags.PrintDefaults()
+	}
+	options := DuOptions{}
+	flags.BoolVar(&options.Summarize, "s", false, "Print only the total of every path")
+	flags.BoolVar(&options.Human, "h", false, "Print sizes in human readable units")
+	flags.IntVar(&options.Top, "top", 0, "Report the n largest files")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if options.Top < 0 {
+		flags.Usage()
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	output := bufio.NewWriter(stdout)
+	usage := &diskUsage{options: options, output: output, stderr: stderr, largest: newLargestFiles(options.Top)}
+	for _, path := range paths {
+		if path != "-" {
+			usage.measure(path)
+			continue
+		}
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				usage.measure(line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			usage.fail(err)
+		}
+	}
+	if options.Top > 0 {
+		for _, file := range usage.largest.files() {
+			usage.print(file.size, file.path)
+		}
+	}
+	return flushCommand(output, stderr, "du", usage.exitCode)
+}
+
+type diskUsage struct {
+	options  DuOptions
+	output   *bufio.Writer
+	stderr   io.Writer
+	largest  *largestFiles
+	exitCode int
+}
+
+func (d *diskUsage) measure(root string) {
+	size := d.walk(root)
+	if d.options.Summarize && d.options.Top == 0 {
+		d.print(size, root)
+	}
+}
+
+// walk returns the size of a path, printing directories after their children
+func (d *diskUsage) walk(path string) int64 {
+	info, err := os.Lstat(path)
+	if err != nil {
+		d.fail(err)
+		return 0
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			d.largest.add(fileSize{path, info.Size()})
+		}
+		return info.Size()
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		d.fail(err)
+	}
+	size := int64(0)
+	for _, entry := range entries {
+		size += d.walk(filepath.Join(path, entry.Name()))
+	}
+	if !d.options.Summarize && d.options.Top == 0 {
+		d.print(size, path)
+	}
+	return size
+}
+
+func (d *diskUsage) print(size int64, path string) {
+	if d.options.Human {
+		fmt.Fprintf(d.output, "%s\t%s\n", humanSize(size), path)
+	} else {
+		fmt.Fprintf(d.output, "%d\t%s\n", size, path)
+	}
+}
+
+func (d *diskUsage) fail(err error) {
+	d.output.Flush()
+	fmt.Fprintf(d.stderr, "du: %v\n", err)
+	d.exitCode = 1
+}
+
+// humanSize formats a size with a binary unit and one decimal below 10, like du -h
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprintf("%d", size)
+	}
+	value, unit := float64(size)/1024, 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, units[unit])
+	}
+	return fmt.Sprintf("%.0f%c", value, units[unit])
+}
+
+// largestFiles keeps the n largest of the files added to it. The smallest
+// kept file is on top of a priority queue, so a larger one replaces it in
+// O(log n) without keeping all the files in memory.
+type largestFiles struct {
+	limit int
+	queue *common.PriorityQueue[fileSize]
+}
+
+func newLargestFiles(limit int) *largestFiles {
+	return &largestFiles{limit: limit, queue: common.NewPriorityQueue(smaller)}
+}
+
+func (l *largestFiles) add(file fileSize) {
+	if l.limit == 0 {
+		return
+	}
+	if l.queue.Size() < l.limit {
+		l.queue.Push(file)
+		return
+	}
+	if smallest, _ := l.queue.Peek(); smaller(smallest, file) {
+		l.queue.Pop()
+		l.queue.Push(file)
+	}
+}
+
+// files returns the kept files from the largest and empties the report
+func (l *largestFiles) files() []fileSize {
+	files := make([]fileSize, l.queue.Size())
+	for i := len(files) - 1; i >= 0; i-- {
+		files[i], _ = l.queue.Pop()
+	}
+	return files
+}
@@ -0,0 +1,59 @@
+package examples
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDu(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{"a.bin": 3000, "sub/b.bin": 100, "sub/c.bin": 2000, "sub/deep/d.bin": 100, "e.bin": 10}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, make([]byte, size), 0644)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{"Directories after children", []string{dir}, "",
+			"100\t" + path("sub/deep") + "\n2200\t" + path("sub") + "\n5210\t" + dir + "\n"},
+		{"Summarize", []string{"-s", path("sub"), path("a.bin")}, "", "2200\t" + path("sub") + "\n3000\t" + path("a.bin") + "\n"},
+		{"Human", []string{"-s", "-h", dir}, "", "5.1K\t" + dir + "\n"},
+		{"Largest files", []string{"-top", "3", dir}, "",
+			"3000\t" + path("a.bin") + "\n2000\t" + path("sub/c.bin") + "\n100\t" + path("sub/b.bin") + "\n"},
+		{"Paths from stdin", []string{"-s", "-"}, path("e.bin") + "\n\n" + path("sub/deep") + "\n",
+			"10\t" + path("e.bin") + "\n100\t" + path("sub/deep") + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunDu(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != 0 || stdout.String() != tt.expected {
+				t.Errorf("RunDu(%v) = %d, %q; want 0, %q (stderr %q)", tt.args, code, stdout.String(), tt.expected, stderr.String())
+			}
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := RunDu([]string{"-s", path("missing"), dir}, nil, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "missing") {
+		t.Errorf("Expected a missing path to be reported, got %d, %q", code, stderr.String())
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	tests := map[int64]string{0: "0", 1023: "1023", 1024: "1.0K", 1536: "1.5K", 10 * 1024: "10K", 5 << 30: "5.0G", 1 << 62: "4.0E"}
+	for size, expected := range tests {
+		if actual := humanSize(size); actual != expected {
+			t.Errorf("humanSize(%d) = %q; want %q", size, actual, expected)
+		}
+	}
+}
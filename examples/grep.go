@@ -2,59 +2,230 @@ package examples
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strings"
+
+	"training.pl/go/common"
+	"training.pl/go/concurrency"
 )
 
+type GrepOptions struct {
+	Recursive   bool     // -r, searches directories
+	IgnoreCase  bool     // -i
+	LineNumbers bool     // -n
+	Include     []string // --include, globs the base names of searched files must match
+	Workers     int      // -workers, files searched at the same time
+}
+
 func Grep() {
-	if len(os.Args) < 3 {
-		log.Fatalf("Usage: grep pattern path")
-	}
+	os.Exit(RunGrep(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	pattern, err := regexp.Compile(os.Args[1])
+// RunGrep prints the lines of files matching a regexp and returns the exit
+// code: 0 when a line matched, 1 when none did and 2 after an error. Files are
+// searched by a pool of workers, the output keeps the order of the arguments
+// and of walking the directories.
+func RunGrep(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: grep [-r] [-i] [-n] [--include glob] pattern [path ...]")
+		flags.PrintDefaults()
+	}
+	options := GrepOptions{}
+	flags.BoolVar(&options.Recursive, "r", false, "Search directories recursively")
+	flags.BoolVar(&options.IgnoreCase, "i", false, "Ignore case")
+	flags.BoolVar(&options.LineNumbers, "n", false, "Print line numbers")
+	flags.Func("include", "Search only files whose name matches the glob, may be repeated", func(glob string) error {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return err
+		}
+		options.Include = append(options.Include, glob)
+		return nil
+	})
+	flags.IntVar(&options.Workers, "workers", runtime.NumCPU(), "Files searched concurrently")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 || options.Workers < 1 {
+		flags.Usage()
+		return 2
+	}
+	expression := flags.Arg(0)
+	if options.IgnoreCase {
+		expression = "(?i)" + expression
+	}
+	pattern, err := regexp.Compile(expression)
 	if err != nil {
-		log.Fatalf("Invalid regexp")
+		fmt.Fprintf(stderr, "grep: invalid pattern: %v\n", err)
+		return 2
+	}
+	paths := flags.Args()[1:]
+	if len(paths) == 0 && options.Recursive {
+		paths = []string{"."}
+	} else if len(paths) == 0 {
+		paths = []string{"-"}
 	}
 
-	path := os.Args[2]
+	searcher := &grepSearcher{pattern: pattern, options: options, stdin: stdin, prefix: options.Recursive || len(paths) > 1}
+	output := bufio.NewWriter(stdout)
+	matched, failed := false, false
+	for result := range searcher.search(paths) {
+		lines, err := result.Get()
+		if err != nil {
+			output.Flush()
+			fmt.Fprintf(stderr, "grep: %v\n", err)
+			failed = true
+			continue
+		}
+		for _, line := range lines {
+			output.WriteString(line)
+		}
+		matched = matched || len(lines) > 0
+	}
+	if err := output.Flush(); err != nil {
+		fmt.Fprintf(stderr, "grep: write error: %v\n", err)
+		return 2
+	}
+	switch {
+	case failed:
+		return 2
+	case matched:
+		return 0
+	}
+	return 1
+}
 
-	err = filepath.Walk(path, search(*pattern))
+type grepSearcher struct {
+	pattern *regexp.Regexp
+	options GrepOptions
+	stdin   io.Reader
+	prefix  bool // Whether lines start with the name of their file
+}
 
-	if err != nil {
-		log.Fatalf("Error walking on path %s: %v", path, err)
+// search walks the paths and submits every file to a pool. Each file gets a
+// channel for its outcome, the channels are queued in walk order and read
+// one by one, so results are printed in order while later files are searched.
+// The queue is bounded, the walk waits when the printing falls behind.
+func (s *grepSearcher) search(paths []string) <-chan common.Result[[]string] {
+	pool := concurrency.NewPool(s.options.Workers, s.options.Workers)
+	pending := make(chan chan common.Result[[]string], 4*s.options.Workers)
+	submit := func(path string) {
+		results := make(chan common.Result[[]string], 1)
+		pending <- results
+		concurrency.SubmitResult(pool, results, func(ctx context.Context) ([]string, error) {
+			return s.searchFile(ctx, path)
+		})
+	}
+	fail := func(err error) {
+		results := make(chan common.Result[[]string], 1)
+		results <- common.Failed[[]string](err)
+		pending <- results
 	}
-}
 
-func search(pattern regexp.Regexp) filepath.WalkFunc {
-	return func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	go func() {
+		defer close(pending)
+		defer pool.Shutdown(context.Background())
+		for _, root := range paths {
+			if root == "-" {
+				submit(root)
+				continue
+			}
+			info, err := os.Stat(root)
+			switch {
+			case err != nil:
+				fail(err)
+			case !info.IsDir():
+				if s.included(root) {
+					submit(root)
+				}
+			case !s.options.Recursive:
+				fail(fmt.Errorf("%s: is a directory", root))
+			default:
+				filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						fail(err)
+					} else if entry.Type().IsRegular() && s.included(path) {
+						submit(path)
+					}
+					return nil
+				})
+			}
+		}
+	}()
+
+	ordered := make(chan common.Result[[]string])
+	go func() {
+		defer close(ordered)
+		for results := range pending {
+			ordered <- <-results
 		}
-		if !info.Mode().IsRegular() {
-			return nil
+	}()
+	return ordered
+}
+
+func (s *grepSearcher) included(path string) bool {
+	if len(s.options.Include) == 0 {
+		return true
+	}
+	for _, glob := range s.options.Include {
+		if matched, _ := filepath.Match(glob, filepath.Base(path)); matched {
+			return true
 		}
+	}
+	return false
+}
+
+// searchFile returns the matching lines of a file formatted for printing
+func (s *grepSearcher) searchFile(ctx context.Context, path string) ([]string, error) {
+	var input io.Reader = s.stdin
+	name := "(standard input)"
+	if path != "-" {
 		file, err := os.Open(path)
 		if err != nil {
-			log.Fatalf("Failed to open file: %s", path)
-			return err
+			return nil, err
 		}
-		defer func(file *os.File) {
-			if file.Close() != nil {
-				log.Fatalf("Failed to close file: %s", path)
-			}
-		}(file)
-		scanner := bufio.NewScanner(file)
-		lineNumber := 0
-		for scanner.Scan() {
-			line := scanner.Text()
-			lineNumber++
-			if pattern.MatchString(line) {
-				fmt.Printf("%s (line: %d): %s\n", path, lineNumber, line)
-			}
+		defer file.Close()
+		input, name = file, path
+	}
+
+	var matches []string
+	reader := bufio.NewReader(input)
+	for lineNumber := 1; ctx.Err() == nil; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if line != "" && s.pattern.MatchString(strings.TrimSuffix(line, "\n")) {
+			matches = append(matches, s.format(name, lineNumber, line))
 		}
-		return err
+		if err == io.EOF {
+			return matches, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil, ctx.Err()
+}
+
+func (s *grepSearcher) format(name string, lineNumber int, line string) string {
+	var builder strings.Builder
+	if s.prefix {
+		builder.WriteString(name + ":")
+	}
+	if s.options.LineNumbers {
+		fmt.Fprintf(&builder, "%d:", lineNumber)
 	}
+	builder.WriteString(strings.TrimSuffix(line, "\n"))
+	builder.WriteByte('\n')
+	return builder.String()
 }
@@ -0,0 +1,95 @@
+package examples
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func grepTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":        "package a\nfunc Hello() {}\n",
+		"b.txt":       "hello world\nnothing\nHELLO again",
+		"sub/c.go":    "// hello from c\n",
+		"sub/d/e.go":  "no match here\n",
+		"sub/d/f.txt": "Hello\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	return dir
+}
+
+func TestRunGrep(t *testing.T) {
+	dir := grepTree(t)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		code     int
+		expected string
+	}{
+		{"Single file", []string{"hello", path("b.txt")}, "", 0, "hello world\n"},
+		{"Ignore case with numbers", []string{"-i", "-n", "hello", path("b.txt")}, "", 0, "1:hello world\n3:HELLO again\n"},
+		{"Several files in order", []string{"-i", "hello", path("b.txt"), path("a.go")}, "", 0,
+			path("b.txt") + ":hello world\n" + path("b.txt") + ":HELLO again\n" + path("a.go") + ":func Hello() {}\n"},
+		{"Recursive in walk order", []string{"-r", "-i", "-n", "hello", dir}, "", 0,
+			path("a.go") + ":2:func Hello() {}\n" + path("b.txt") + ":1:hello world\n" + path("b.txt") + ":3:HELLO again\n" +
+				path("sub/c.go") + ":1:// hello from c\n" + path("sub/d/f.txt") + ":1:Hello\n"},
+		{"Include", []string{"-r", "-i", "--include", "*.go", "hello", dir}, "", 0,
+			path("a.go") + ":func Hello() {}\n" + path("sub/c.go") + ":// hello from c\n"},
+		{"Several includes", []string{"-r", "--include=c.*", "--include", "*.txt", "world|from", dir}, "", 0,
+			path("b.txt") + ":hello world\n" + path("sub/c.go") + ":// hello from c\n"},
+		{"Stdin", []string{"-n", "b"}, "a\nb\nab", 0, "2:b\n3:ab\n"},
+		{"No match", []string{"missing", path("a.go")}, "", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunGrep(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code || stdout.String() != tt.expected {
+				t.Errorf("RunGrep(%v) = %d, %q; want %d, %q (stderr %q)", tt.args, code, stdout.String(), tt.code, tt.expected, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunGrepKeepsOrderWithManyFiles(t *testing.T) {
+	dir := t.TempDir()
+	var expected strings.Builder
+	for i := range 200 {
+		path := filepath.Join(dir, fmt.Sprintf("%03d.txt", i))
+		os.WriteFile(path, []byte(strings.Repeat("filler\n", i%7)+fmt.Sprintf("match %d\n", i)), 0644)
+		fmt.Fprintf(&expected, "%s:match %d\n", path, i)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := RunGrep([]string{"-r", "-workers", "8", "match", dir}, nil, &stdout, &stderr); code != 0 || stdout.String() != expected.String() {
+		t.Errorf("Expected all matches in walk order, got %d, %q", code, stderr.String())
+	}
+}
+
+func TestRunGrepReportsErrors(t *testing.T) {
+	dir := grepTree(t)
+	var stdout, stderr bytes.Buffer
+	code := RunGrep([]string{"hello", filepath.Join(dir, "missing.txt"), dir, filepath.Join(dir, "b.txt")}, nil, &stdout, &stderr)
+	if code != 2 || !strings.Contains(stdout.String(), "hello world") {
+		t.Errorf("Expected errors reported and other files searched, got %d, %q", code, stdout.String())
+	}
+	if errors := stderr.String(); !strings.Contains(errors, "missing.txt") || !strings.Contains(errors, "is a directory") {
+		t.Errorf("Expected both errors reported, got %q", errors)
+	}
+	for _, args := range [][]string{{"("}, {}, {"-x", "a"}, {"--include", "[", "a"}} {
+		if code := RunGrep(args, nil, &stdout, &stderr); code != 2 {
+			t.Errorf("RunGrep(%v) = %d; want 2", args, code)
+		}
+	}
+}
@@ -1,68 +1,51 @@
 package examples
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
 
-type monetaryAmount struct {
-	value    float64
-	currency string
-}
-
-var currencyMismatch = fmt.Errorf("currnency mismatch")
+	"training.pl/go/examples/money"
+)
 
-func newMonetaryAmount(value float64, currency string) *monetaryAmount {
-	return &monetaryAmount{value, currency}
-}
-
-/*func (ma *monetaryAmount) add(monetaryAmount *monetaryAmount) error {
-	if ma.currency != monetaryAmount.currency {
-		return currencyMismatch
-	}
-	ma.value += monetaryAmount.value
-	return nil
-}
-
-func (ma *monetaryAmount) subtract(monetaryAmount *monetaryAmount) error {
-	if ma.currency != monetaryAmount.currency {
-		return currencyMismatch
+func MonetaryAmount() {
+	value, otherValue := 0.1, 0.2 // Constants would be added exactly by the compiler
+	fmt.Printf("With float64: 0.1 + 0.2 = %v\n", value+otherValue)
+	amount, err := money.New(10, money.PLN).Add(money.New(20, money.PLN))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
 	}
-	ma.value -= monetaryAmount.value
-	return nil
-}*/
+	fmt.Printf("In grosze: 0.10 + 0.20 = %v\n", amount)
 
-func (ma *monetaryAmount) add(amount *monetaryAmount) error {
-	return apply(ma, amount, func(monetaryAmount, otherMonetaryAmount *monetaryAmount) {
-		monetaryAmount.value += otherMonetaryAmount.value
-	})
-}
-
-func (ma *monetaryAmount) subtract(amount *monetaryAmount) error {
-	return apply(ma, amount, func(monetaryAmount, otherMonetaryAmount *monetaryAmount) {
-		monetaryAmount.value -= otherMonetaryAmount.value
-	})
-}
-
-func apply(monetaryAmount, otherMonetaryAmount *monetaryAmount, operator func(monetaryAmount, otherMonetaryAmount *monetaryAmount)) error {
-	if monetaryAmount.currency != otherMonetaryAmount.currency {
-		return currencyMismatch
+	gross, err := money.New(1999, money.PLN).Multiply(big.NewRat(123, 100), money.HalfUp)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
 	}
-	operator(monetaryAmount, otherMonetaryAmount)
-	return nil
-}
-
-/*func (ma monetaryAmount) addImmutable(amount *monetaryAmount) (*monetaryAmount, error) {
-	if ma.currency != amount.currency {
-		return nil, errors.New("incompatible currency")
+	fmt.Printf("19.99 PLN with 23%% VAT: %v\n", gross)
+
+	parts, _ := money.New(10000, money.PLN).Divide(3)
+	fmt.Printf("100.00 PLN split between 3 people: %v\n", parts)
+
+	rates := money.NewStaticRates(24 * time.Hour)
+	rates.Set(money.EUR, money.PLN, big.NewRat(4285, 1000), time.Now())
+	rates.Set(money.GBP, money.PLN, big.NewRat(5, 1), time.Now().Add(-72*time.Hour))
+	for _, currency := range []money.Currency{money.EUR, money.GBP, money.USD} {
+		converted, err := gross.Convert(currency, rates, money.HalfEven)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		fmt.Printf("%v in %v: %v\n", gross, currency, converted)
 	}
-	ma.value += amount.value
-	return &ma, nil
-}*/
 
-func MonetaryAmount() {
-	amount := newMonetaryAmount(100.0, "PLN")
-	otherAmount := newMonetaryAmount(100.0, "PLN")
-	if result := amount.add(otherAmount); result != nil {
-		fmt.Printf("Error: %v\n", result.Error())
+	price, err := money.ParseAmount("1 234,50 zł")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	fmt.Println(amount)
+	data, _ := json.Marshal(price)
+	fmt.Printf("Parsed %v, in English %s, as JSON %s\n", price, price.Format(money.English), data)
 }
@@ -0,0 +1,134 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+var (
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrOverflow          = errors.New("amount out of range")
+	ErrInvalidAllocation = errors.New("invalid allocation")
+)
+
+// Amount is kept in minor units, float64 can't represent most decimal fractions
+// (0.1 + 0.2 != 0.3) and would lose or invent grosze in calculations. Operations
+// return a new amount, an Amount never changes.
+type Amount struct {
+	units    int64
+	currency Currency
+}
+
+// New creates an amount of units in the minor unit of currency, New(1230, PLN) is 12.30 PLN
+func New(units int64, currency Currency) Amount {
+	return Amount{units, currency}
+}
+
+func (a Amount) Units() int64 {
+	return a.units
+}
+
+func (a Amount) Currency() Currency {
+	return a.currency
+}
+
+func (a Amount) IsZero() bool {
+	return a.units == 0
+}
+
+func (a Amount) Add(other Amount) (Amount, error) {
+	if a.currency != other.currency {
+		return Amount{}, ErrCurrencyMismatch
+	}
+	sum := a.units + other.units
+	if (sum > a.units) != (other.units > 0) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{sum, a.currency}, nil
+}
+
+func (a Amount) Subtract(other Amount) (Amount, error) {
+	if a.currency != other.currency {
+		return Amount{}, ErrCurrencyMismatch
+	}
+	difference := a.units - other.units
+	if (difference < a.units) != (other.units > 0) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{difference, a.currency}, nil
+}
+
+// Multiply scales the amount by an exact factor, e.g. big.NewRat(123, 100) for 23% VAT
+func (a Amount) Multiply(factor *big.Rat, mode RoundingMode) (Amount, error) {
+	units, err := round(new(big.Rat).Mul(new(big.Rat).SetInt64(a.units), factor), mode)
+	if err != nil {
+		return Amount{}, err
+	}
+	return Amount{units, a.currency}, nil
+}
+
+// Allocate splits the amount in proportion to ratios without losing a grosz, the
+// minor units left over after rounding down go one by one to the first parts
+func (a Amount) Allocate(ratios ...int64) ([]Amount, error) {
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("%w: negative ratio %d", ErrInvalidAllocation, ratio)
+		}
+		total += ratio
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("%w: ratios sum up to %d", ErrInvalidAllocation, total)
+	}
+	parts := make([]Amount, len(ratios))
+	remainder := a.units
+	for i, ratio := range ratios {
+		share := new(big.Rat).SetFrac(big.NewInt(a.units), big.NewInt(total))
+		units, _ := round(share.Mul(share, new(big.Rat).SetInt64(ratio)), Down)
+		parts[i] = Amount{units, a.currency}
+		remainder -= units
+	}
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i++ {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].units += step
+		remainder -= step
+	}
+	return parts, nil
+}
+
+// Divide splits the amount into equal parts, the first ones get the remaining minor units
+func (a Amount) Divide(parts int) ([]Amount, error) {
+	ratios := make([]int64, parts)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return a.Allocate(ratios...)
+}
+
+// rat is the amount in major units
+func (a Amount) rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(a.units), a.currency.minorUnits())
+}
+
+// decimal writes the amount in major units, e.g. 1230 PLN grosze as 12.30
+func (a Amount) decimal() string {
+	if a.currency.Exponent == 0 {
+		return fmt.Sprint(a.units)
+	}
+	sign := ""
+	magnitude := uint64(a.units)
+	if a.units < 0 {
+		sign = "-"
+		magnitude = -magnitude
+	}
+	divisor := uint64(math.Pow10(a.currency.Exponent))
+	return fmt.Sprintf("%s%d.%0*d", sign, magnitude/divisor, a.currency.Exponent, magnitude%divisor)
+}
@@ -0,0 +1,120 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestAddAndSubtract(t *testing.T) {
+	sum, err := New(10, PLN).Add(New(20, PLN))
+	if err != nil || sum != New(30, PLN) {
+		t.Errorf("Expected exactly 30 grosze, got %v, %v", sum, err)
+	}
+	if difference, err := sum.Subtract(New(45, PLN)); err != nil || difference.decimal() != "-0.15" {
+		t.Errorf("Expected -0.15, got %s, %v", difference.decimal(), err)
+	}
+	if _, err := sum.Add(New(1, EUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected a currency mismatch, got %v", err)
+	}
+	if _, err := New(math.MaxInt64, PLN).Add(New(1, PLN)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected an overflow, got %v", err)
+	}
+	if _, err := New(math.MinInt64, PLN).Subtract(New(1, PLN)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected an overflow, got %v", err)
+	}
+	if _, err := CurrencyOf("XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Expected an unknown currency, got %v", err)
+	}
+}
+
+func TestMultiply(t *testing.T) {
+	tests := []struct {
+		name     string
+		units    int64
+		factor   *big.Rat
+		mode     RoundingMode
+		expected int64
+	}{
+		{"Exact", 1000, big.NewRat(123, 100), HalfUp, 1230},
+		{"Half up", 25, big.NewRat(1, 10), HalfUp, 3},
+		{"Half up negative", -25, big.NewRat(1, 10), HalfUp, -3},
+		{"Half even to even", 25, big.NewRat(1, 10), HalfEven, 2},
+		{"Half even to odd neighbour", 35, big.NewRat(1, 10), HalfEven, 4},
+		{"Half even above half", 26, big.NewRat(1, 10), HalfEven, 3},
+		{"Down", 29, big.NewRat(1, 10), Down, 2},
+		{"Down negative", -29, big.NewRat(1, 10), Down, -2},
+		{"Up", 21, big.NewRat(1, 10), Up, 3},
+		{"Up negative", -21, big.NewRat(1, 10), Up, -3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, err := New(tt.units, PLN).Multiply(tt.factor, tt.mode)
+			if err != nil || product.Units() != tt.expected {
+				t.Errorf("%d * %v = %d, %v; want %d", tt.units, tt.factor, product.Units(), err, tt.expected)
+			}
+		})
+	}
+	if _, err := New(math.MaxInt64, PLN).Multiply(big.NewRat(2, 1), HalfUp); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected an overflow, got %v", err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	units := func(parts []Amount) []int64 {
+		var result []int64
+		for _, part := range parts {
+			result = append(result, part.Units())
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		units    int64
+		ratios   []int64
+		expected []int64
+	}{
+		{"Even", 900, []int64{1, 1, 1}, []int64{300, 300, 300}},
+		{"Remainder to the first parts", 1000, []int64{1, 1, 1}, []int64{334, 333, 333}},
+		{"Proportional", 5, []int64{70, 30}, []int64{4, 1}},
+		{"Zero ratio gets nothing", 10, []int64{0, 1, 2}, []int64{0, 4, 6}},
+		{"Negative amount", -1000, []int64{1, 1, 1}, []int64{-334, -333, -333}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := New(tt.units, PLN).Allocate(tt.ratios...)
+			if err != nil || !slices.Equal(units(parts), tt.expected) {
+				t.Errorf("Allocate(%v) = %v, %v; want %v", tt.ratios, units(parts), err, tt.expected)
+			}
+		})
+	}
+	if parts, err := New(100, PLN).Divide(3); err != nil || !slices.Equal(units(parts), []int64{34, 33, 33}) {
+		t.Errorf("Divide(3) = %v, %v", units(parts), err)
+	}
+	for _, ratios := range [][]int64{{}, {0, 0}, {1, -1}} {
+		if _, err := New(100, PLN).Allocate(ratios...); !errors.Is(err, ErrInvalidAllocation) {
+			t.Errorf("Expected ratios %v to be rejected, got %v", ratios, err)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		expected string
+	}{
+		{New(1230, PLN), "12.30"},
+		{New(5, PLN), "0.05"},
+		{New(-5, PLN), "-0.05"},
+		{New(1500, JPY), "1500"},
+		{New(1500, KWD), "1.500"},
+		{New(math.MinInt64, PLN), "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if decimal := tt.amount.decimal(); decimal != tt.expected {
+			t.Errorf("decimal(%d %s) = %s; want %s", tt.amount.Units(), tt.amount.Currency(), decimal, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+type Currency struct {
+	Code     string
+	Exponent int // Decimal digits of the minor unit, e.g. 2 for grosze in PLN
+	Symbol   string
+}
+
+var (
+	PLN = Currency{"PLN", 2, "zł"}
+	EUR = Currency{"EUR", 2, "€"}
+	USD = Currency{"USD", 2, "$"}
+	GBP = Currency{"GBP", 2, "£"}
+	CHF = Currency{"CHF", 2, "CHF"}
+	JPY = Currency{"JPY", 0, "¥"}
+	KWD = Currency{"KWD", 3, "KD"}
+)
+
+var currencies = map[string]Currency{}
+var currencySymbols = map[string]Currency{}
+
+// currencyLocales are used by String, the way amounts are written where the currency is used
+var currencyLocales = map[string]Locale{
+	"PLN": Polish,
+	"EUR": German,
+	"CHF": Swiss,
+}
+
+func init() {
+	for _, currency := range []Currency{PLN, EUR, USD, GBP, CHF, JPY, KWD} {
+		currencies[currency.Code] = currency
+		currencySymbols[currency.Symbol] = currency
+	}
+}
+
+// CurrencyOf finds a currency by its ISO 4217 code
+func CurrencyOf(code string) (Currency, error) {
+	currency, ok := currencies[code]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	return currency, nil
+}
+
+// currencyOfLabel finds a currency by its code or symbol
+func currencyOfLabel(label string) (Currency, error) {
+	if currency, ok := currencySymbols[label]; ok {
+		return currency, nil
+	}
+	return CurrencyOf(strings.ToUpper(label))
+}
+
+func (c Currency) locale() Locale {
+	if locale, ok := currencyLocales[c.Code]; ok {
+		return locale
+	}
+	return English
+}
+
+func (c Currency) String() string {
+	return c.Code
+}
+
+// minorUnits is the number of minor units in a major one
+func (c Currency) minorUnits() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Exponent)), nil)
+}
@@ -0,0 +1,192 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Locale tells how amounts are written
+type Locale struct {
+	DecimalSeparator string
+	GroupSeparator   string // Between groups of three digits of the major units
+	SymbolFirst      bool
+}
+
+var (
+	English = Locale{".", ",", true}  // $1,234.56
+	Polish  = Locale{",", " ", false} // 1 234,56 zł
+	German  = Locale{",", ".", false} // 1.234,56 €
+	Swiss   = Locale{".", "'", true}  // CHF 1'234.56
+)
+
+// Format writes the amount with the currency symbol the way the locale does
+func (a Amount) Format(locale Locale) string {
+	number, negative := strings.CutPrefix(a.decimal(), "-")
+	whole, fraction, hasFraction := strings.Cut(number, ".")
+	var text strings.Builder
+	if negative {
+		text.WriteString("-")
+	}
+	if locale.SymbolFirst {
+		text.WriteString(a.currency.Symbol)
+		// A sign like $ sticks to the number, a symbol of letters doesn't
+		if utf8.RuneCountInString(a.currency.Symbol) > 1 {
+			text.WriteString(" ")
+		}
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			text.WriteString(locale.GroupSeparator)
+		}
+		text.WriteRune(digit)
+	}
+	if hasFraction {
+		text.WriteString(locale.DecimalSeparator)
+		text.WriteString(fraction)
+	}
+	if !locale.SymbolFirst {
+		text.WriteString(" " + a.currency.Symbol)
+	}
+	return text.String()
+}
+
+// String formats the amount the way it is written where its currency is used
+func (a Amount) String() string {
+	return a.Format(a.currency.locale())
+}
+
+// ParseAmount reads an amount with a currency code or symbol before or after the
+// number, e.g. "12,30 PLN", "1 234,56 zł" or "-$1,234.56". The decimal separator is
+// a comma or a dot. A single one followed by three digits separates groups, unless
+// the currency has three decimal digits.
+func ParseAmount(text string) (Amount, error) {
+	text = strings.TrimSpace(text)
+	unsigned, negative := strings.CutPrefix(text, "-")
+	start := strings.IndexFunc(unsigned, unicode.IsDigit)
+	end := strings.LastIndexFunc(unsigned, unicode.IsDigit)
+	if start < 0 {
+		return Amount{}, fmt.Errorf("%w: no digits in %q", ErrInvalidAmount, text)
+	}
+	prefix, suffix := strings.TrimSpace(unsigned[:start]), strings.TrimSpace(unsigned[end+1:])
+	if prefix != "" && suffix != "" || prefix == suffix {
+		return Amount{}, fmt.Errorf("%w: expected a currency before or after the number in %q", ErrInvalidAmount, text)
+	}
+	currency, err := currencyOfLabel(prefix + suffix)
+	if err != nil {
+		return Amount{}, err
+	}
+	number := normalize(unsigned[start:end+1], currency)
+	if negative {
+		number = "-" + number
+	}
+	return parseDecimal(number, currency)
+}
+
+// normalize removes group separators from a number and makes a dot its decimal separator
+func normalize(number string, currency Currency) string {
+	number = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(number)
+	decimal := strings.LastIndexAny(number, ".,")
+	if decimal < 0 {
+		return number
+	}
+	separator := number[decimal : decimal+1]
+	if strings.Count(number, separator) > 1 ||
+		!strings.ContainsAny(number[:decimal], ".,") && len(number)-decimal-1 == 3 && currency.Exponent != 3 {
+		// The only separators group the digits
+		return strings.ReplaceAll(number, separator, "")
+	}
+	whole := strings.NewReplacer(".", "", ",", "").Replace(number[:decimal])
+	return whole + "." + number[decimal+1:]
+}
+
+// parseDecimal reads a number in major units with a dot as the decimal separator
+func parseDecimal(number string, currency Currency) (Amount, error) {
+	unsigned, negative := strings.CutPrefix(number, "-")
+	whole, fraction, _ := strings.Cut(unsigned, ".")
+	if whole == "" || strings.IndexFunc(whole+fraction, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Amount{}, fmt.Errorf("%w: %q is not a number", ErrInvalidAmount, number)
+	}
+	if len(fraction) > currency.Exponent {
+		return Amount{}, fmt.Errorf("%w: %s has %d decimal digits, %q has more", ErrInvalidAmount, currency, currency.Exponent, number)
+	}
+	units, _ := new(big.Int).SetString(whole+fraction+strings.Repeat("0", currency.Exponent-len(fraction)), 10)
+	if negative {
+		units.Neg(units)
+	}
+	if !units.IsInt64() {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{units.Int64(), currency}, nil
+}
+
+// MarshalText writes the amount as "1234.56 PLN", whatever the locale
+func (a Amount) MarshalText() ([]byte, error) {
+	if a.currency == (Currency{}) {
+		return []byte{}, nil
+	}
+	return []byte(a.decimal() + " " + a.currency.Code), nil
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*a = Amount{}
+		return nil
+	}
+	amount, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// MarshalBinary is the text form too, gob and msgpack encode amounts with it
+func (a Amount) MarshalBinary() ([]byte, error) {
+	return a.MarshalText()
+}
+
+func (a *Amount) UnmarshalBinary(data []byte) error {
+	return a.UnmarshalText(data)
+}
+
+// amountJSON keeps the number in a string, a JSON number is a float64 to most decoders
+type amountJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes the amount as {"amount": "1234.56", "currency": "PLN"}, a zero Amount as null
+func (a Amount) MarshalJSON() ([]byte, error) {
+	if a.currency == (Currency{}) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(amountJSON{a.decimal(), a.currency.Code})
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*a = Amount{}
+		return nil
+	}
+	var value amountJSON
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	currency, err := CurrencyOf(value.Currency)
+	if err != nil {
+		return err
+	}
+	amount, err := parseDecimal(value.Amount, currency)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,102 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		locale   Locale
+		expected string
+	}{
+		{New(123456, PLN), Polish, "1 234,56 zł"},
+		{New(-123456789, EUR), German, "-1.234.567,89 €"},
+		{New(123456, USD), English, "$1,234.56"},
+		{New(-5, USD), English, "-$0.05"},
+		{New(123456, CHF), Swiss, "CHF 1'234.56"},
+		{New(1500, JPY), English, "¥1,500"},
+		{New(100, PLN), English, "zł 1.00"},
+	}
+	for _, tt := range tests {
+		if text := tt.amount.Format(tt.locale); text != tt.expected {
+			t.Errorf("Format(%d %s) = %q; want %q", tt.amount.Units(), tt.amount.Currency(), text, tt.expected)
+		}
+	}
+	if text := New(1230, PLN).String(); text != "12,30 zł" {
+		t.Errorf("Expected String in the locale of the currency, got %q", text)
+	}
+	if text := New(1230, GBP).String(); text != "£12.30" {
+		t.Errorf("Expected String in English by default, got %q", text)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Amount
+	}{
+		{"12,30 PLN", New(1230, PLN)},
+		{"12.30 PLN", New(1230, PLN)},
+		{"1 234,56 zł", New(123456, PLN)},
+		{"1 234,5 zł", New(123450, PLN)},
+		{"-1.234.567,89 €", New(-123456789, EUR)},
+		{"$1,234.56", New(123456, USD)},
+		{"-$0.05", New(-5, USD)},
+		{"CHF 1'234.56", New(123456, CHF)},
+		{"1,234 PLN", New(123400, PLN)},
+		{"1,234 KWD", New(1234, KWD)},
+		{"1500 jpy", New(1500, JPY)},
+		{"  7 EUR ", New(700, EUR)},
+	}
+	for _, tt := range tests {
+		if amount, err := ParseAmount(tt.text); err != nil || amount != tt.expected {
+			t.Errorf("ParseAmount(%q) = %v, %v; want %v", tt.text, amount, err, tt.expected)
+		}
+	}
+	for _, text := range []string{"", "PLN", "12,30", "12 PLN EUR", "12,3456 PLN", "1.5 JPY", "1x2 PLN", "99999999999999999999 PLN"} {
+		if amount, err := ParseAmount(text); err == nil {
+			t.Errorf("Expected ParseAmount(%q) to fail, got %v", text, amount)
+		}
+	}
+	if _, err := ParseAmount("12 XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Expected an unknown currency, got %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type payment struct {
+		Title  string `json:"title"`
+		Amount Amount `json:"amount"`
+		Fee    Amount `json:"fee"`
+	}
+	original := payment{"Rent", New(-123456, PLN), Amount{}}
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"title":"Rent","amount":{"amount":"-1234.56","currency":"PLN"},"fee":null}`; string(data) != expected {
+		t.Errorf("Marshal = %s; want %s", data, expected)
+	}
+	var decoded payment
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != original {
+		t.Errorf("Unmarshal = %v, %v; want %v", decoded, err, original)
+	}
+	for _, data := range []string{`{"amount":"1.234","currency":"PLN"}`, `{"amount":"1","currency":"XYZ"}`, `{"amount":1,"currency":"PLN"}`} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(data), &amount); err == nil {
+			t.Errorf("Expected %s to be rejected, got %v", data, amount)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	amount := New(150025, JPY)
+	text, _ := amount.MarshalText()
+	var decoded Amount
+	if err := decoded.UnmarshalText(text); err != nil || decoded != amount || string(text) != "150025 JPY" {
+		t.Errorf("Text round trip = %s, %v, %v", text, decoded, err)
+	}
+}
@@ -0,0 +1,88 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownRate = errors.New("unknown exchange rate")
+	ErrStaleRate   = errors.New("stale exchange rate")
+)
+
+// Rate is the price of one major unit of From in major units of To
+type Rate struct {
+	From  Currency
+	To    Currency
+	Value *big.Rat
+	Time  time.Time // When the rate was published
+}
+
+type RateProvider interface {
+	// Rate fails with ErrUnknownRate or ErrStaleRate when it has no usable rate
+	Rate(from, to Currency) (Rate, error)
+}
+
+type currencyPair struct {
+	from, to Currency
+}
+
+// StaticRates is a table of rates set by hand, a rate of a pair serves the reverse
+// pair as well. Rates older than maxAge are stale.
+type StaticRates struct {
+	lock   sync.RWMutex
+	rates  map[currencyPair]Rate
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewStaticRates creates an empty table, a maxAge of 0 keeps rates valid forever
+func NewStaticRates(maxAge time.Duration) *StaticRates {
+	return &StaticRates{rates: map[currencyPair]Rate{}, maxAge: maxAge, now: time.Now}
+}
+
+func (r *StaticRates) Set(from, to Currency, value *big.Rat, published time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rates[currencyPair{from, to}] = Rate{from, to, value, published}
+}
+
+func (r *StaticRates) Rate(from, to Currency) (Rate, error) {
+	if from == to {
+		return Rate{from, to, big.NewRat(1, 1), r.now()}, nil
+	}
+	r.lock.RLock()
+	rate, ok := r.rates[currencyPair{from, to}]
+	if !ok {
+		if reverse, found := r.rates[currencyPair{to, from}]; found && reverse.Value.Sign() != 0 {
+			rate, ok = Rate{from, to, new(big.Rat).Inv(reverse.Value), reverse.Time}, true
+		}
+	}
+	r.lock.RUnlock()
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrUnknownRate, from, to)
+	}
+	if age := r.now().Sub(rate.Time); r.maxAge > 0 && age > r.maxAge {
+		return Rate{}, fmt.Errorf("%w: %s/%s is %v old", ErrStaleRate, from, to, age.Round(time.Second))
+	}
+	return rate, nil
+}
+
+// Convert exchanges the amount into currency to at the rate of the provider
+func (a Amount) Convert(to Currency, rates RateProvider, mode RoundingMode) (Amount, error) {
+	rate, err := rates.Rate(a.currency, to)
+	if err != nil {
+		return Amount{}, err
+	}
+	value := a.rat()
+	value.Mul(value, rate.Value)
+	value.Mul(value, new(big.Rat).SetInt(to.minorUnits()))
+	units, err := round(value, mode)
+	if err != nil {
+		return Amount{}, err
+	}
+	return Amount{units, to}, nil
+}
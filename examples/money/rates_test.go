@@ -0,0 +1,49 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rates := NewStaticRates(24 * time.Hour)
+	rates.now = func() time.Time { return now }
+	rates.Set(EUR, PLN, big.NewRat(4285, 1000), now.Add(-time.Hour))
+	rates.Set(USD, JPY, big.NewRat(15025, 100), now.Add(-time.Hour))
+	rates.Set(GBP, PLN, big.NewRat(5, 1), now.Add(-48*time.Hour))
+
+	tests := []struct {
+		name     string
+		amount   Amount
+		to       Currency
+		mode     RoundingMode
+		expected Amount
+	}{
+		{"Direct", New(1000, EUR), PLN, HalfUp, New(4285, PLN)},
+		{"Rounded", New(1, EUR), PLN, HalfUp, New(4, PLN)},
+		{"Reverse pair", New(4285, PLN), EUR, HalfUp, New(1000, EUR)},
+		{"Different exponents", New(1000, USD), JPY, HalfEven, New(1502, JPY)},
+		{"Same currency", New(123, PLN), PLN, HalfUp, New(123, PLN)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := tt.amount.Convert(tt.to, rates, tt.mode)
+			if err != nil || converted != tt.expected {
+				t.Errorf("Convert = %v, %v; want %v", converted, err, tt.expected)
+			}
+		})
+	}
+
+	if _, err := New(100, CHF).Convert(PLN, rates, HalfUp); !errors.Is(err, ErrUnknownRate) {
+		t.Errorf("Expected an unknown pair to fail, got %v", err)
+	}
+	if _, err := New(100, GBP).Convert(PLN, rates, HalfUp); !errors.Is(err, ErrStaleRate) {
+		t.Errorf("Expected a stale rate to fail, got %v", err)
+	}
+	if _, err := New(100, PLN).Convert(GBP, rates, HalfUp); !errors.Is(err, ErrStaleRate) {
+		t.Errorf("Expected the reverse of a stale rate to be stale, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package money
+
+import "math/big"
+
+// RoundingMode decides what happens to a fraction of the minor unit
+type RoundingMode int
+
+const (
+	HalfUp   RoundingMode = iota // Half a grosz and more away from zero, as taught in school
+	HalfEven                     // Half a grosz to the even neighbour, "banker's rounding"
+	Down                         // Towards zero
+	Up                           // Away from zero
+)
+
+// round turns a value in minor units into a whole number of them
+func round(value *big.Rat, mode RoundingMode) (int64, error) {
+	numerator, denominator := value.Num(), value.Denom() // The denominator is always positive
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() != 0 {
+		// Compares twice the remainder with the denominator to tell less, exactly and more than a half
+		doubled := new(big.Int).Abs(remainder)
+		half := doubled.Lsh(doubled, 1).Cmp(denominator)
+		awayFromZero := false
+		switch mode {
+		case HalfUp:
+			awayFromZero = half >= 0
+		case HalfEven:
+			awayFromZero = half > 0 || half == 0 && quotient.Bit(0) == 1
+		case Up:
+			awayFromZero = true
+		}
+		if awayFromZero {
+			quotient.Add(quotient, big.NewInt(int64(numerator.Sign())))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return quotient.Int64(), nil
+}
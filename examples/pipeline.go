@@ -0,0 +1,74 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Command is the shape shared by the command line examples, so they can run
+// in a pipeline where one reads what the previous one wrote
+type Command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var Commands = map[string]Command{
+	"cat":  RunCat,
+	"grep": RunGrep,
+	"wc":   RunWc,
+	"du":   RunDu,
+}
+
+// RunPipeline runs commands separated by |, e.g. "du -top 5 . | cat -n",
+// connected with io.Pipe. Arguments are split on whitespace, there is no
+// quoting. Like a shell it returns the exit code of the last command, or 127
+// when a command is unknown.
+func RunPipeline(line string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var stages [][]string
+	for _, stage := range strings.Split(line, "|") {
+		args := strings.Fields(stage)
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, "pipeline: empty command")
+			return 2
+		}
+		if _, ok := Commands[args[0]]; !ok {
+			fmt.Fprintf(stderr, "pipeline: %s: command not found\n", args[0])
+			return 127
+		}
+		stages = append(stages, args)
+	}
+
+	var running sync.WaitGroup
+	input := stdin
+	for _, args := range stages[:len(stages)-1] {
+		reader, writer := io.Pipe()
+		running.Add(1)
+		go func(input io.Reader) {
+			defer running.Done()
+			runStage(args, input, writer, stderr)
+			writer.Close()
+		}(input)
+		input = reader
+	}
+	exitCode := runStage(stages[len(stages)-1], input, stdout, stderr)
+	running.Wait()
+	return exitCode
+}
+
+// runStage closes the input of a command when it ends, so when it didn't read
+// everything the writes of the previous command fail instead of blocking
+func runStage(args []string, input io.Reader, output, stderr io.Writer) int {
+	exitCode := Commands[args[0]](args[1:], input, output, stderr)
+	if reader, ok := input.(*io.PipeReader); ok {
+		reader.Close()
+	}
+	return exitCode
+}
+
+func Pipeline() {
+	line := strings.Join(os.Args[1:], " ")
+	if line == "" {
+		line = "du -top 5 . | cat -n"
+	}
+	os.Exit(RunPipeline(line, os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,55 @@
+package examples
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPipeline(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("apple\nbanana\navocado\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n"), 0644)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name     string
+		line     string
+		stdin    string
+		code     int
+		expected string
+	}{
+		{"Single command", "cat -n -", "x\n", 0, "     1: x\n"},
+		{"Cat into wc", fmt.Sprintf("cat %s | wc -l", path("a.txt")), "", 0, "       3\n"},
+		{"Three stages", fmt.Sprintf("cat %s | grep ^a | cat -n -E", path("a.txt")), "", 0, "     1: apple$\n     2: avocado$\n"},
+		{"Du into grep", fmt.Sprintf("du -top 5 %s | grep -n go$", dir), "", 0, "2:10\t" + path("b.go") + "\n"},
+		{"Stdin of the first command", "grep an | wc -w", "banana split\nanother\nnone\n", 0, "       3\n"},
+		{"Exit code of the last command", "cat | grep missing", "text\n", 1, ""},
+		{"Unknown command", "cat | sort", "", 127, ""},
+		{"Empty command", "cat | ", "", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunPipeline(tt.line, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code || stdout.String() != tt.expected {
+				t.Errorf("RunPipeline(%q) = %d, %q; want %d, %q (stderr %q)", tt.line, code, stdout.String(), tt.code, tt.expected, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunPipelineStopsWritersOfFinishedCommands(t *testing.T) {
+	// grep with an invalid pattern ends at once, cat must not block writing to it
+	input := strings.Repeat("line\n", 100000)
+	var stdout, stderr bytes.Buffer
+	if code := RunPipeline("cat | cat | grep (", strings.NewReader(input), &stdout, &stderr); code != 2 {
+		t.Errorf("Expected the exit code of grep, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "closed pipe") {
+		t.Errorf("Expected cat to report the closed pipe, got %q", stderr.String())
+	}
+}
@@ -1,15 +1,28 @@
 package examples
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+
+	"training.pl/go/common/validate"
 )
 
 type Person struct {
-	Name string `mymeta:"required" training:"required"`
-	Age  int    `mymeta:"range=0..150"`
+	Name    string   `mymeta:"required,len=..40" training:"required"`
+	Age     int      `mymeta:"range=0..150"`
+	Email   string   `mymeta:"regexp=^[^@ ]+@[^@ ]+$"`
+	Address *Address `mymeta:"required"`
+	Tags    []string `mymeta:"len=..3,each,len=1..10"`
+}
+
+type Address struct {
+	City   string `mymeta:"required"`
+	Postal string `mymeta:"regexp=^[0-9]{2}-[0-9]{3}$"`
 }
 
+var personValidator = validate.New("mymeta")
+
 func Reflect() {
 	t := reflect.TypeOf(Person{})
 
@@ -19,4 +32,23 @@ func Reflect() {
 			fmt.Printf("%s -> %q\n", f.Name, tagVal)
 		}
 	}
+
+	people := []Person{
+		{Name: "Jan", Age: 42, Email: "jan@training.pl", Address: &Address{"Warszawa", "00-001"}, Tags: []string{"go"}},
+		{Age: 200, Email: "jan", Address: &Address{Postal: "0001"}, Tags: []string{"go", "", "java", "rust"}},
+		{Name: "Anna", Age: -1},
+	}
+	for i, person := range people {
+		var errs validate.Errors
+		if err := personValidator.Validate(person); errors.As(err, &errs) {
+			fmt.Printf("Person %d is invalid:\n", i+1)
+			for _, fieldErr := range errs {
+				fmt.Printf("  %v (%s)\n", fieldErr, fieldErr.Rule)
+			}
+		} else if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		} else {
+			fmt.Printf("Person %d is valid\n", i+1)
+		}
+	}
 }
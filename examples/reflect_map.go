@@ -0,0 +1,362 @@
+package examples
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+type mapField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// mapFields lists the exported fields of a struct kept in maps. Fields are
+// renamed with the map tag, e.g. `map:"first_name"`, `map:",omitempty"`
+// leaves out zero values and `map:"-"` skips the field.
+func mapFields(structType reflect.Type) []mapField {
+	var fields []mapField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("map")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, mapField{i, name, options == "omitempty"})
+	}
+	return fields
+}
+
+// convertible tells whether a struct is turned into a map, structs without
+// exported fields such as time.Time are kept as values
+func convertible(structType reflect.Type) bool {
+	return len(mapFields(structType)) > 0
+}
+
+// ToMap turns a struct, or a pointer to one, into a map of its fields. Nested
+// structs become maps as well, and so do the structs in slices and maps,
+// which are then held in []any and map[string]any. Other values are shared.
+// Unlike DeepCopy it does not follow cycles, the value must not have any.
+func ToMap(value any) map[string]any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return structToMap(v)
+}
+
+func structToMap(v reflect.Value) map[string]any {
+	result := make(map[string]any)
+	for _, field := range mapFields(v.Type()) {
+		fieldValue := v.Field(field.index)
+		if field.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		result[field.name] = toMapValue(fieldValue)
+	}
+	return result
+}
+
+func toMapValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if holdsStructs(v.Type()) {
+			return toMapValue(v.Elem())
+		}
+	case reflect.Struct:
+		if convertible(v.Type()) {
+			return structToMap(v)
+		}
+	case reflect.Slice, reflect.Array:
+		if holdsStructs(v.Type().Elem()) && !(v.Kind() == reflect.Slice && v.IsNil()) {
+			items := make([]any, v.Len())
+			for i := range items {
+				items[i] = toMapValue(v.Index(i))
+			}
+			return items
+		}
+	case reflect.Map:
+		if holdsStructs(v.Type().Elem()) && v.Type().Key().Kind() == reflect.String && !v.IsNil() {
+			entries := make(map[string]any, v.Len())
+			for iter := v.MapRange(); iter.Next(); {
+				entries[iter.Key().String()] = toMapValue(iter.Value())
+			}
+			return entries
+		}
+	}
+	return v.Interface()
+}
+
+// holdsStructs tells whether values of a type are, or may contain, structs turned into maps
+func holdsStructs(valueType reflect.Type) bool {
+	switch valueType.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return holdsStructs(valueType.Elem())
+	case reflect.Struct:
+		return convertible(valueType)
+	case reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// FromMap fills the struct target points to with the entries of a map,
+// reversing ToMap. Nested maps fill nested structs, []any fills slices and
+// numbers are converted between types as long as no value is lost, so maps
+// decoded from JSON work as well. Entries without a field are ignored.
+func FromMap(values map[string]any, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("FromMap needs a pointer to a struct, got %T", target)
+	}
+	return mapToStruct(values, v.Elem(), "")
+}
+
+func mapToStruct(values map[string]any, v reflect.Value, path string) error {
+	for _, field := range mapFields(v.Type()) {
+		value, ok := values[field.name]
+		if !ok {
+			continue
+		}
+		if err := assign(v.Field(field.index), value, joinPath(path, field.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assign(target reflect.Value, value any, path string) error {
+	if value == nil {
+		target.SetZero()
+		return nil
+	}
+	source := reflect.ValueOf(value)
+	if source.Type().AssignableTo(target.Type()) {
+		target.Set(source)
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		element := reflect.New(target.Type().Elem())
+		if err := assign(element.Elem(), value, path); err != nil {
+			return err
+		}
+		target.Set(element)
+		return nil
+	case reflect.Struct:
+		if values, ok := value.(map[string]any); ok {
+			return mapToStruct(values, target, path)
+		}
+	case reflect.Slice, reflect.Array:
+		if source.Kind() != reflect.Slice && source.Kind() != reflect.Array {
+			break
+		}
+		items := target
+		if target.Kind() == reflect.Slice {
+			items = reflect.MakeSlice(target.Type(), source.Len(), source.Len())
+		} else if source.Len() != target.Len() {
+			return fmt.Errorf("field %s: cannot use %d items as %s", path, source.Len(), target.Type())
+		}
+		for i := 0; i < source.Len(); i++ {
+			if err := assign(items.Index(i), source.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		target.Set(items)
+		return nil
+	case reflect.Map:
+		if source.Kind() != reflect.Map {
+			break
+		}
+		entries := reflect.MakeMapWithSize(target.Type(), source.Len())
+		for iter := source.MapRange(); iter.Next(); {
+			key := reflect.New(target.Type().Key()).Elem()
+			if err := assign(key, iter.Key().Interface(), path); err != nil {
+				return err
+			}
+			entry := reflect.New(target.Type().Elem()).Elem()
+			if err := assign(entry, iter.Value().Interface(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			entries.SetMapIndex(key, entry)
+		}
+		target.Set(entries)
+		return nil
+	default:
+		if convertNumber(target, source) {
+			return nil
+		}
+	}
+	return fmt.Errorf("field %s: cannot use %T as %s", path, value, target.Type())
+}
+
+// convertNumber sets a number of another type, e.g. a float64 decoded from
+// JSON into an int field, unless the value does not fit or has a fraction
+func convertNumber(target, source reflect.Value) bool {
+	if !isNumber(target.Kind()) || !isNumber(source.Kind()) {
+		return false
+	}
+	number := numberOf(source)
+	switch {
+	case target.CanInt():
+		if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 || target.OverflowInt(int64(number)) {
+			return false
+		}
+	case target.CanUint():
+		if number != math.Trunc(number) || number < 0 || number >= math.MaxUint64 || target.OverflowUint(uint64(number)) {
+			return false
+		}
+	case target.OverflowFloat(number):
+		return false
+	}
+	target.Set(source.Convert(target.Type()))
+	return true
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+// DeepCopy returns a copy of value that shares no memory reachable through
+// pointers, slices, maps and interfaces with the original. Pointers shared
+// in the original stay shared in the copy, so cycles are copied as well.
+// Unexported fields can't be set through reflection and are copied shallowly,
+// channels and functions are shared.
+func DeepCopy[T any](value T) T {
+	var result T
+	copyValue(reflect.ValueOf(&result).Elem(), reflect.ValueOf(&value).Elem(), make(map[uintptr]reflect.Value))
+	return result
+}
+
+func copyValue(target, source reflect.Value, copied map[uintptr]reflect.Value) {
+	switch source.Kind() {
+	case reflect.Pointer:
+		if source.IsNil() {
+			return
+		}
+		if pointer, ok := copied[source.Pointer()]; ok {
+			target.Set(pointer)
+			return
+		}
+		pointer := reflect.New(source.Type().Elem())
+		copied[source.Pointer()] = pointer
+		copyValue(pointer.Elem(), source.Elem(), copied)
+		target.Set(pointer)
+	case reflect.Interface:
+		if source.IsNil() {
+			return
+		}
+		element := reflect.New(source.Elem().Type()).Elem()
+		copyValue(element, source.Elem(), copied)
+		target.Set(element)
+	case reflect.Struct:
+		target.Set(source)
+		for i := 0; i < source.NumField(); i++ {
+			if source.Type().Field(i).IsExported() {
+				copyValue(target.Field(i), source.Field(i), copied)
+			}
+		}
+	case reflect.Slice:
+		if source.IsNil() {
+			return
+		}
+		items := reflect.MakeSlice(source.Type(), source.Len(), source.Cap())
+		for i := 0; i < source.Len(); i++ {
+			copyValue(items.Index(i), source.Index(i), copied)
+		}
+		target.Set(items)
+	case reflect.Array:
+		for i := 0; i < source.Len(); i++ {
+			copyValue(target.Index(i), source.Index(i), copied)
+		}
+	case reflect.Map:
+		if source.IsNil() {
+			return
+		}
+		entries := reflect.MakeMapWithSize(source.Type(), source.Len())
+		for iter := source.MapRange(); iter.Next(); {
+			key := reflect.New(source.Type().Key()).Elem()
+			copyValue(key, iter.Key(), copied)
+			entry := reflect.New(source.Type().Elem()).Elem()
+			copyValue(entry, iter.Value(), copied)
+			entries.SetMapIndex(key, entry)
+		}
+		target.Set(entries)
+	default:
+		target.Set(source)
+	}
+}
+
+type Employee struct {
+	Person   `map:"person"`
+	Position string            `map:"position"`
+	Salary   int               `map:"salary,omitempty"`
+	Manager  *Employee         `map:"manager"`
+	Skills   map[string]int    `map:"skills"`
+	Password string            `map:"-"`
+	Labels   map[string]string `map:"labels,omitempty"`
+}
+
+func ReflectMaps() {
+	boss := &Employee{Person: Person{Name: "Anna", Age: 50}, Position: "CTO", Salary: 30000}
+	employee := Employee{
+		Person:   Person{Name: "Jan", Age: 30, Address: &Address{"Kraków", "30-001"}, Tags: []string{"go"}},
+		Position: "Developer",
+		Manager:  boss,
+		Skills:   map[string]int{"go": 5, "sql": 3},
+		Password: "secret",
+	}
+
+	values := ToMap(employee)
+	fmt.Printf("As a map: %v\n", values)
+
+	restored := Employee{}
+	if err := FromMap(values, &restored); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Restored %s living in %s, reporting to %s\n", restored.Name, restored.Address.City, restored.Manager.Name)
+	if err := FromMap(map[string]any{"salary": 1.5}, &restored); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	clone := DeepCopy(employee)
+	clone.Skills["go"] = 1
+	clone.Manager.Name = "Piotr"
+	clone.Address.City = "Gdańsk"
+	fmt.Printf("After changing the copy the original still has go=%d, manager %s, city %s\n",
+		employee.Skills["go"], employee.Manager.Name, employee.Address.City)
+}
+
+func numberOf(value reflect.Value) float64 {
+	switch {
+	case value.CanInt():
+		return float64(value.Int())
+	case value.CanUint():
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,131 @@
+package examples
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Team struct {
+	Name    string              `map:"name"`
+	Lead    *Employee           `map:"lead"`
+	Members []Employee          `map:"members"`
+	Rooms   map[string]*Address `map:"rooms"`
+	Founded time.Time           `map:"founded"`
+	Scores  [2]int              `map:"scores"`
+}
+
+func testTeam() Team {
+	lead := &Employee{Person: Person{Name: "Anna", Age: 50}, Position: "Lead", Salary: 100}
+	return Team{
+		Name: "Backend",
+		Lead: lead,
+		Members: []Employee{
+			{Person: Person{Name: "Jan", Tags: []string{"go"}}, Manager: lead, Skills: map[string]int{"go": 5}},
+			{Person: Person{Name: "Ewa"}, Manager: lead, Password: "secret"},
+		},
+		Rooms:   map[string]*Address{"main": {"Kraków", "30-001"}},
+		Founded: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		Scores:  [2]int{3, 4},
+	}
+}
+
+func TestToMap(t *testing.T) {
+	values := ToMap(testTeam())
+	members := values["members"].([]any)
+	jan := members[0].(map[string]any)
+	if jan["person"].(map[string]any)["Name"] != "Jan" || jan["manager"].(map[string]any)["position"] != "Lead" {
+		t.Errorf("Expected nested structs as maps, got %v", jan)
+	}
+	if _, ok := members[1].(map[string]any)["Password"]; ok {
+		t.Error("Expected the skipped field to be left out")
+	}
+	if _, ok := jan["salary"]; ok {
+		t.Error("Expected the empty salary to be omitted")
+	}
+	if values["rooms"].(map[string]any)["main"].(map[string]any)["City"] != "Kraków" {
+		t.Errorf("Expected structs in maps as maps, got %v", values["rooms"])
+	}
+	if _, ok := values["founded"].(time.Time); !ok {
+		t.Errorf("Expected a struct without exported fields to be kept, got %T", values["founded"])
+	}
+	if ToMap(42) != nil || ToMap((*Team)(nil)) != nil {
+		t.Error("Expected no map for a value that is not a struct")
+	}
+}
+
+func TestFromMapRestoresToMap(t *testing.T) {
+	team := testTeam()
+	team.Members[1].Password = ""
+	restored := Team{}
+	if err := FromMap(ToMap(team), &restored); err != nil {
+		t.Fatalf("FromMap failed: %v", err)
+	}
+	if !reflect.DeepEqual(restored, team) {
+		t.Errorf("FromMap(ToMap()) = %+v; want %+v", restored, team)
+	}
+}
+
+func TestFromMapConvertsJson(t *testing.T) {
+	var values map[string]any
+	data := `{"name":"Backend","lead":{"person":{"Name":"Anna","Age":50},"salary":100},"scores":[1,2],"members":[{"skills":{"go":4}}]}`
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		t.Fatal(err)
+	}
+	team := Team{}
+	if err := FromMap(values, &team); err != nil {
+		t.Fatalf("FromMap failed: %v", err)
+	}
+	if team.Lead.Age != 50 || team.Lead.Salary != 100 || team.Scores != [2]int{1, 2} || team.Members[0].Skills["go"] != 4 {
+		t.Errorf("Unexpected team %+v", team)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]any
+	}{
+		{"Fraction", map[string]any{"scores": []any{1.5, 2}}},
+		{"Overflow", map[string]any{"lead": map[string]any{"person": map[string]any{"Age": 1e20}}}},
+		{"Wrong type", map[string]any{"name": 42}},
+		{"Wrong length", map[string]any{"scores": []any{1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := FromMap(tt.values, &Team{}); err == nil {
+				t.Errorf("Expected FromMap(%v) to fail", tt.values)
+			}
+		})
+	}
+	if err := FromMap(values, team); err == nil {
+		t.Error("Expected FromMap into a value to fail")
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	team := testTeam()
+	clone := DeepCopy(team)
+	if !reflect.DeepEqual(clone, team) {
+		t.Fatalf("DeepCopy() = %+v; want %+v", clone, team)
+	}
+	clone.Lead.Name = "Piotr"
+	clone.Members[0].Tags[0] = "rust"
+	clone.Members[0].Skills["go"] = 1
+	clone.Rooms["main"].City = "Gdańsk"
+	if team.Lead.Name != "Anna" || team.Members[0].Tags[0] != "go" || team.Members[0].Skills["go"] != 5 || team.Rooms["main"].City != "Kraków" {
+		t.Errorf("Expected the original to be untouched, got %+v", team)
+	}
+	if clone.Members[0].Manager != clone.Lead {
+		t.Error("Expected a pointer shared in the original to be shared in the copy")
+	}
+
+	cyclic := &Employee{Person: Person{Name: "Anna"}}
+	cyclic.Manager = cyclic
+	if copied := DeepCopy(cyclic); copied == cyclic || copied.Manager != copied {
+		t.Error("Expected the cycle to be copied")
+	}
+	var slice any = []int{1, 2}
+	if copied := DeepCopy(slice).([]int); &copied[0] == &slice.([]int)[0] {
+		t.Error("Expected the slice in an interface to be copied")
+	}
+}
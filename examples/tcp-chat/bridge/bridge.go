@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"tcp-chat/chatclient"
+	"tcp-chat/common"
+)
+
+// Bridge relays messages between chat rooms and IRC channels
+type Bridge struct {
+	chat     *chatclient.Connection
+	messages <-chan *common.Message // Subscription to the chat connection
+	irc      *IRCClient
+	rooms    map[string]string // Chat room ID -> IRC channel
+	channels map[string]string // Lower-cased IRC channel -> chat room ID
+}
+
+// NewBridge creates a bridge for the given room to channel mapping; create
+// it before connecting so the first user list is not missed
+func NewBridge(chat *chatclient.Connection, irc *IRCClient, rooms map[string]string) *Bridge {
+	b := &Bridge{
+		chat:     chat,
+		messages: chat.Subscribe(),
+		irc:      irc,
+		rooms:    rooms,
+		channels: make(map[string]string),
+	}
+	for roomID, channel := range rooms {
+		b.channels[strings.ToLower(channel)] = roomID
+	}
+	return b
+}
+
+// ParseMapping parses "room=#channel,..." into a room ID to channel map
+func ParseMapping(spec string) (map[string]string, error) {
+	rooms := make(map[string]string)
+	channels := make(map[string]bool)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		roomID, channel, ok := strings.Cut(pair, "=")
+		roomID, channel = strings.TrimSpace(roomID), strings.TrimSpace(channel)
+		if !ok || roomID == "" || !strings.HasPrefix(channel, "#") || strings.ContainsAny(channel, " ,") {
+			return nil, fmt.Errorf("invalid mapping %q, want room=#channel", pair)
+		}
+		if _, exists := rooms[roomID]; exists {
+			return nil, fmt.Errorf("room %s is mapped twice", roomID)
+		}
+		if channels[strings.ToLower(channel)] {
+			return nil, fmt.Errorf("channel %s is mapped twice", channel)
+		}
+		rooms[roomID] = channel
+		channels[strings.ToLower(channel)] = true
+	}
+	if len(rooms) == 0 {
+		return nil, fmt.Errorf("no rooms mapped")
+	}
+	return rooms, nil
+}
+
+// Run relays messages until ctx is done
+func (b *Bridge) Run(ctx context.Context) {
+	defer b.chat.Unsubscribe(b.messages)
+
+	go b.irc.Run(ctx, b.handleIRC)
+
+	for {
+		select {
+		case msg := <-b.messages:
+			b.handleChat(msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleChat relays room messages to IRC and rejoins the rooms after
+// every (re)registration
+func (b *Bridge) handleChat(msg *common.Message) {
+	switch msg.Type {
+	case common.TypeUserList:
+		// The full user list follows a successful registration
+		for roomID := range b.rooms {
+			if err := b.chat.JoinRoom(roomID); err != nil {
+				log.Printf("Joining room %s: %v", roomID, err)
+			}
+		}
+
+	case common.TypeRoom:
+		if msg.Action == common.RoomJoin {
+			if channel, ok := b.rooms[msg.Room]; ok {
+				log.Printf("Relaying room %s to %s", msg.Room, channel)
+			}
+		}
+
+	case common.TypeError:
+		log.Printf("Chat server error: %s", msg.Error)
+
+	case common.TypeText:
+		channel, ok := b.rooms[msg.Room]
+		if !ok || msg.Sender == b.chat.Nickname() || msg.Sender == "Server" {
+			return
+		}
+		content := msg.Content
+		if msg.Format == common.FormatMarkdown {
+			content = common.PlainText(content)
+		}
+		b.irc.Privmsg(channel, fmt.Sprintf("<%s> %s", msg.Sender, content))
+	}
+}
+
+// handleIRC relays channel messages to the mapped rooms
+func (b *Bridge) handleIRC(msg *IRCMessage) {
+	if msg.Command != "PRIVMSG" && msg.Command != "NOTICE" {
+		return
+	}
+	roomID, ok := b.channels[strings.ToLower(msg.Param(0))]
+	if !ok || msg.Nick() == b.irc.CurrentNick() {
+		return
+	}
+
+	content := formatIRCText(msg.Nick(), msg.Param(1))
+	if content == "" {
+		return
+	}
+	if err := b.chat.SendRoomMessage(roomID, content); err != nil && !errors.Is(err, chatclient.ErrQueued) {
+		log.Printf("Relaying to room %s: %v", roomID, err)
+	}
+}
+
+// formatIRCText renders an IRC message for the chat, turning CTCP ACTION
+// (/me) into an emote and dropping other CTCP requests
+func formatIRCText(nick, text string) string {
+	if strings.HasPrefix(text, "\x01") {
+		command, rest, _ := strings.Cut(strings.Trim(text, "\x01"), " ")
+		if command != "ACTION" {
+			return ""
+		}
+		return fmt.Sprintf("* %s %s", nick, StripFormatting(rest))
+	}
+	return fmt.Sprintf("<%s> %s", nick, StripFormatting(text))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tcp-chat/common/retry"
+)
+
+// IRC protocol limits and timings
+const (
+	ircMaxLine      = 512                    // Bytes per line including CRLF (RFC 1459)
+	ircLineInterval = 500 * time.Millisecond // Pause between sent lines to stay clear of flood limits
+	ircQueueSize    = 100                    // Lines waiting to be sent before new ones are dropped
+	ircReadTimeout  = 5 * time.Minute        // Servers ping idle clients well within this
+	ircMaxBackoff   = 5 * time.Minute
+)
+
+// IRCMessage is a parsed IRC protocol line
+type IRCMessage struct {
+	Prefix  string   // Origin, e.g. nick!user@host
+	Command string   // Command or numeric reply
+	Params  []string // Parameters, the trailing one included as the last element
+}
+
+// Nick returns the nickname of the origin
+func (m *IRCMessage) Nick() string {
+	nick, _, _ := strings.Cut(m.Prefix, "!")
+	return nick
+}
+
+// Param returns the i-th parameter or an empty string
+func (m *IRCMessage) Param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// ParseIRCLine parses one line without the trailing CRLF; IRCv3 message
+// tags are skipped
+func ParseIRCLine(raw string) (*IRCMessage, error) {
+	line := strings.TrimRight(raw, "\r\n")
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+
+	msg := &IRCMessage{}
+	if strings.HasPrefix(line, ":") {
+		msg.Prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, ":") {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if param == "" {
+			continue
+		}
+		if msg.Command == "" {
+			msg.Command = strings.ToUpper(param)
+		} else {
+			msg.Params = append(msg.Params, param)
+		}
+	}
+
+	if msg.Command == "" {
+		return nil, fmt.Errorf("no command in IRC line %q", raw)
+	}
+	return msg, nil
+}
+
+// formattingCodes matches mIRC bold, colour, italic, underline and reset codes
+var formattingCodes = regexp.MustCompile(`\x03(\d{1,2}(,\d{1,2})?)?|[\x02\x0f\x16\x1d\x1e\x1f]`)
+
+// StripFormatting removes mIRC formatting codes from text
+func StripFormatting(text string) string {
+	return formattingCodes.ReplaceAllString(text, "")
+}
+
+// IRCClient is a minimal IRC client that stays in a set of channels and
+// reconnects when the connection drops
+type IRCClient struct {
+	Address  string
+	Nick     string
+	Password string // Server password, sent with PASS when set
+	UseTLS   bool
+	Channels []string
+
+	nick     string // Current nickname, changes when Nick is taken
+	outgoing chan string
+	conn     net.Conn
+	mutex    sync.Mutex // Guards conn and nick
+}
+
+// NewIRCClient creates a client joining channels on the server at address
+func NewIRCClient(address, nick string, channels []string) *IRCClient {
+	return &IRCClient{
+		Address:  address,
+		Nick:     nick,
+		Channels: channels,
+		outgoing: make(chan string, ircQueueSize),
+	}
+}
+
+// CurrentNick returns the nickname the client is registered with
+func (ic *IRCClient) CurrentNick() string {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	return ic.nick
+}
+
+// Run connects and passes every received message to handle, reconnecting
+// with exponential backoff until ctx is done
+func (ic *IRCClient) Run(ctx context.Context, handle func(*IRCMessage)) {
+	go ic.writeLoop(ctx)
+
+	policy := retry.Policy{
+		InitialDelay: time.Second,
+		MaxDelay:     ircMaxBackoff,
+		RetryIf:      func(error) bool { return ctx.Err() == nil },
+		OnRetry: func(_ int, err error, delay time.Duration) {
+			log.Printf("IRC connection lost: %v. Reconnecting in %v...", err, delay)
+		},
+	}
+	for ctx.Err() == nil {
+		retry.Do(ctx, policy, func(ctx context.Context) error {
+			start := time.Now()
+			err := ic.session(ctx, handle)
+			// A session that lasted a while was healthy, start backing off anew
+			if ctx.Err() == nil && time.Since(start) > ircMaxBackoff {
+				log.Printf("IRC connection lost: %v. Reconnecting...", err)
+				return nil
+			}
+			return err
+		})
+	}
+}
+
+// session runs one connection until it fails
+func (ic *IRCClient) session(ctx context.Context, handle func(*IRCMessage)) error {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if ic.UseTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", ic.Address, &tls.Config{})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", ic.Address)
+	}
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	ic.mutex.Lock()
+	ic.conn = conn
+	ic.nick = ic.Nick
+	ic.mutex.Unlock()
+	defer func() {
+		ic.mutex.Lock()
+		ic.conn = nil
+		ic.mutex.Unlock()
+	}()
+
+	// Registration bypasses the throttled queue
+	if ic.Password != "" {
+		ic.writeNow("PASS " + ic.Password)
+	}
+	ic.writeNow("NICK " + ic.Nick)
+	ic.writeNow(fmt.Sprintf("USER %s 0 * :tcp-chat bridge", ic.Nick))
+
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(ircReadTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return errors.New("connection closed by server")
+		}
+
+		msg, err := ParseIRCLine(scanner.Text())
+		if err != nil {
+			continue
+		}
+
+		switch msg.Command {
+		case "PING":
+			ic.writeNow("PONG :" + msg.Param(0))
+		case "001":
+			// Registered, the welcome names the nickname the server accepted
+			ic.mutex.Lock()
+			ic.nick = msg.Param(0)
+			ic.mutex.Unlock()
+			log.Printf("Registered on IRC as %s", msg.Param(0))
+			for _, channel := range ic.Channels {
+				ic.writeNow("JOIN " + channel)
+			}
+		case "433":
+			// Nickname in use, try again with an underscore appended
+			ic.mutex.Lock()
+			ic.nick += "_"
+			nick := ic.nick
+			ic.mutex.Unlock()
+			ic.writeNow("NICK " + nick)
+		case "ERROR":
+			return fmt.Errorf("server error: %s", msg.Param(0))
+		default:
+			handle(msg)
+		}
+	}
+}
+
+// writeNow writes a line to the current connection immediately
+func (ic *IRCClient) writeNow(line string) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if ic.conn == nil {
+		return errors.New("not connected to IRC")
+	}
+	ic.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := ic.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// writeLoop sends queued lines at a pace IRC servers accept
+func (ic *IRCClient) writeLoop(ctx context.Context) {
+	for {
+		select {
+		case line := <-ic.outgoing:
+			if err := ic.writeNow(line); err != nil {
+				log.Printf("Dropping IRC line: %v", err)
+			}
+			time.Sleep(ircLineInterval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lineBreaks matches every line ending an IRC server may honour, many end a
+// line at a bare CR
+var lineBreaks = regexp.MustCompile(`\r\n|\r|\n`)
+
+// dropControl removes NUL and other control characters but tabs from a line,
+// so remote text can't end it early or smuggle in a command
+func dropControl(r rune) rune {
+	if r != '\t' && unicode.IsControl(r) {
+		return -1
+	}
+	return r
+}
+
+// Privmsg queues text for target, splitting it into lines that fit the
+// protocol limit
+func (ic *IRCClient) Privmsg(target, text string) {
+	prefix := "PRIVMSG " + target + " :"
+	// Leave room for the origin prefix servers add when relaying the line
+	limit := ircMaxLine - len(prefix) - 2 - 100
+
+	for _, line := range lineBreaks.Split(text, -1) {
+		line = strings.Map(dropControl, line)
+		for _, part := range splitUTF8(line, limit) {
+			select {
+			case ic.outgoing <- prefix + part:
+			default:
+				log.Printf("IRC send queue full, dropping message to %s", target)
+				return
+			}
+		}
+	}
+}
+
+// splitUTF8 splits text into parts of at most limit bytes without cutting
+// multi-byte characters
+func splitUTF8(text string, limit int) []string {
+	if text == "" {
+		return nil
+	}
+	var parts []string
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	return append(parts, text)
+}
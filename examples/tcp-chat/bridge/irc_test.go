@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseIRCLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want IRCMessage
+	}{
+		{"PING :irc.example.net", IRCMessage{Command: "PING", Params: []string{"irc.example.net"}}},
+		{":alice!a@host PRIVMSG #lobby :hello there\r\n", IRCMessage{Prefix: "alice!a@host", Command: "PRIVMSG", Params: []string{"#lobby", "hello there"}}},
+		{":irc.example.net 001 bridge :Welcome", IRCMessage{Prefix: "irc.example.net", Command: "001", Params: []string{"bridge", "Welcome"}}},
+		{"@time=2024-01-01T00:00:00Z :bob!b@h JOIN #dev", IRCMessage{Prefix: "bob!b@h", Command: "JOIN", Params: []string{"#dev"}}},
+		{":s 353 me = #dev :a b  c", IRCMessage{Prefix: "s", Command: "353", Params: []string{"me", "=", "#dev", "a b  c"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseIRCLine(tt.line)
+		if err != nil {
+			t.Errorf("ParseIRCLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseIRCLine(%q) = %+v, want %+v", tt.line, *got, tt.want)
+		}
+	}
+
+	if _, err := ParseIRCLine(":prefix-only"); err == nil {
+		t.Error("a line without command was accepted")
+	}
+	if nick := (&IRCMessage{Prefix: "alice!a@host"}).Nick(); nick != "alice" {
+		t.Errorf("Nick() = %q, want alice", nick)
+	}
+}
+
+func TestFormatIRCText(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"hello", "<alice> hello"},
+		{"\x02bold\x02 and \x0304,01red\x03", "<alice> bold and red"},
+		{"\x01ACTION waves\x01", "* alice waves"},
+		{"\x01VERSION\x01", ""},
+	}
+	for _, tt := range tests {
+		if got := formatIRCText("alice", tt.text); got != tt.want {
+			t.Errorf("formatIRCText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitUTF8(t *testing.T) {
+	text := strings.Repeat("zażółć ", 50)
+	parts := splitUTF8(text, 64)
+	if strings.Join(parts, "") != text {
+		t.Fatal("parts do not add up to the text")
+	}
+	for _, part := range parts {
+		if len(part) > 64 || !utf8.ValidString(part) {
+			t.Errorf("bad part %q", part)
+		}
+	}
+}
+
+// Chat text can't end a line early and inject IRC commands
+func TestPrivmsgSplitsLines(t *testing.T) {
+	ic := NewIRCClient("irc.example.net:6667", "bridge", nil)
+	ic.Privmsg("#lobby", "hi\rQUIT :x\r\nbye\nnul\x00\x7fl\tok")
+	close(ic.outgoing)
+	var lines []string
+	for line := range ic.outgoing {
+		lines = append(lines, line)
+	}
+	want := []string{"PRIVMSG #lobby :hi", "PRIVMSG #lobby :QUIT :x", "PRIVMSG #lobby :bye", "PRIVMSG #lobby :null\tok"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Privmsg queued %q, want %q", lines, want)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	rooms, err := ParseMapping("room_1=#lobby, room_2=#Dev")
+	if err != nil {
+		t.Fatalf("ParseMapping: %v", err)
+	}
+	if want := map[string]string{"room_1": "#lobby", "room_2": "#Dev"}; !reflect.DeepEqual(rooms, want) {
+		t.Errorf("got %v, want %v", rooms, want)
+	}
+
+	for _, spec := range []string{"", "room_1", "room_1=lobby", "room_1=#a,room_1=#b", "room_1=#a,room_2=#A"} {
+		if _, err := ParseMapping(spec); err == nil {
+			t.Errorf("ParseMapping(%q) accepted an invalid mapping", spec)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tcp-chat/chatclient"
+)
+
+func main() {
+	server := flag.String("server", "localhost:8080", "Chat server address")
+	nickname := flag.String("nick", "irc-bridge", "Nickname of the bridge on the chat server")
+	ircServer := flag.String("irc", "localhost:6667", "IRC server address")
+	ircNick := flag.String("irc-nick", "chatbridge", "Nickname of the bridge on IRC")
+	ircTLS := flag.Bool("irc-tls", false, "Connect to the IRC server with TLS")
+	ircPassword := flag.String("irc-password", os.Getenv("IRC_PASSWORD"), "IRC server password, defaults to $IRC_PASSWORD")
+	mapping := flag.String("map", "", "Comma-separated room to channel mapping, e.g. room_123=#lobby,room_456=#dev")
+	flag.Parse()
+
+	rooms, err := ParseMapping(*mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var channels []string
+	for _, channel := range rooms {
+		channels = append(channels, channel)
+	}
+
+	irc := NewIRCClient(*ircServer, *ircNick, channels)
+	irc.UseTLS = *ircTLS
+	irc.Password = *ircPassword
+
+	chat := chatclient.NewConnection(*nickname)
+	// Messages from IRC arriving during a chat outage are relayed afterwards
+	chat.AutoFlush = true
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	bridge := NewBridge(chat, irc, rooms)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bridge.Run(ctx)
+	}()
+
+	go chat.ConnectWithRetry(*server)
+	<-done
+	chat.Disconnect()
+}
@@ -0,0 +1,202 @@
+package chatclient
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// TransferBatch tracks a group of files sent together by SendFiles
+type TransferBatch struct {
+	ID         string
+	Recipient  string
+	Files      []string
+	TotalBytes int64
+	SentBytes  int64
+	Completed  int
+	Failed     int
+	StartTime  time.Time
+}
+
+// batchItem is a single file queued in a batch
+type batchItem struct {
+	path      string
+	name      string
+	size      int64
+	temporary bool // Archive created on the fly, removed after sending
+}
+
+// SendFiles sends several files and directories to a recipient one after
+// another. Directories are zipped on the fly with relative paths preserved.
+func (ft *FileTransfer) SendFiles(recipient string, paths []string) (*TransferBatch, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files to send")
+	}
+
+	items := make([]batchItem, 0, len(paths))
+	cleanup := func() {
+		for _, item := range items {
+			if item.temporary {
+				os.Remove(item.path)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		item, err := prepareBatchItem(path)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	batch := &TransferBatch{
+		ID:        generateFileID(),
+		Recipient: recipient,
+		StartTime: time.Now(),
+	}
+	for _, item := range items {
+		batch.Files = append(batch.Files, item.name)
+		batch.TotalBytes += item.size
+	}
+
+	ft.conn.mutex.Lock()
+	ft.batches[batch.ID] = batch
+	ft.conn.mutex.Unlock()
+
+	go ft.sendBatch(batch, items)
+
+	return batch, nil
+}
+
+// sendBatch sends batch items sequentially so per-user transfer limits hold
+func (ft *FileTransfer) sendBatch(batch *TransferBatch, items []batchItem) {
+	for _, item := range items {
+		sent := false
+		file, transfer, err := ft.startFile(batch.Recipient, item.path, item.name, batch.ID, nil)
+		if err != nil {
+			ft.conn.notify("File transfer error: %s - %v", item.name, err)
+		} else {
+			sent = ft.sendFileChunks(file, transfer.FileID, batch.Recipient, transfer.TotalChunks)
+		}
+
+		if item.temporary {
+			os.Remove(item.path)
+		}
+
+		ft.conn.mutex.Lock()
+		if sent {
+			batch.Completed++
+		} else {
+			batch.Failed++
+		}
+		ft.conn.mutex.Unlock()
+	}
+
+	ft.conn.mutex.Lock()
+	delete(ft.batches, batch.ID)
+	ft.conn.mutex.Unlock()
+
+	ft.conn.notify("Batch to %s finished: %d of %d files sent (%s in %v)",
+		batch.Recipient, batch.Completed, len(items),
+		FormatFileSize(batch.TotalBytes), time.Since(batch.StartTime).Round(time.Millisecond))
+}
+
+// prepareBatchItem validates a path, archiving directories into a zip file
+func prepareBatchItem(path string) (batchItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return batchItem{}, fmt.Errorf("failed to get file info: %v", err)
+	}
+
+	item := batchItem{path: path, name: filepath.Base(path), size: info.Size()}
+	if info.IsDir() {
+		archive, err := zipDirectory(path)
+		if err != nil {
+			return batchItem{}, fmt.Errorf("failed to archive %s: %v", path, err)
+		}
+		archiveInfo, err := os.Stat(archive)
+		if err != nil {
+			os.Remove(archive)
+			return batchItem{}, err
+		}
+		item = batchItem{path: archive, name: item.name + ".zip", size: archiveInfo.Size(), temporary: true}
+	} else if !info.Mode().IsRegular() {
+		return batchItem{}, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if item.size > common.MaxFileSize {
+		if item.temporary {
+			os.Remove(item.path)
+		}
+		return batchItem{}, fmt.Errorf("%s exceeds maximum allowed size of %d bytes", item.name, common.MaxFileSize)
+	}
+	return item, nil
+}
+
+// zipDirectory archives a directory tree into a temporary zip file
+func zipDirectory(dir string) (string, error) {
+	archive, err := os.CreateTemp("", "tcp-chat-*.zip")
+	if err != nil {
+		return "", err
+	}
+
+	writer := zip.NewWriter(archive)
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Symlinks and devices are skipped, only files and directories are kept
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err = writer.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(entry, file)
+		return err
+	})
+
+	closeErr := writer.Close()
+	if err := archive.Close(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	if walkErr == nil {
+		walkErr = closeErr
+	}
+	if walkErr != nil {
+		os.Remove(archive.Name())
+		return "", walkErr
+	}
+	return archive.Name(), nil
+}
@@ -0,0 +1,807 @@
+// Package chatclient implements the tcp-chat client protocol without any
+// user interface, so other programs and tests can drive a chat session.
+package chatclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+	"tcp-chat/common/retry"
+)
+
+// TypeLocal marks notices generated by the client itself; they are delivered
+// to subscribers but never sent to the server
+const TypeLocal common.MessageType = "LOCAL"
+
+// ErrClosed is returned when sending on a connection that was disconnected
+var ErrClosed = errors.New("connection closed")
+
+// Connection manages the server connection
+type Connection struct {
+	conn          net.Conn
+	nickname      string
+	status        common.UserStatus
+	format        string // Format of sent text messages
+	sendChan      chan *common.Message
+	subscribers   []chan *common.Message
+	subMutex      sync.RWMutex
+	fileTransfers map[string]*FileTransferProgress
+	users         *UserDirectory
+	pings         *pingTracker
+	connected     bool
+	closed        bool
+	address       string
+	pending       []*common.Message
+	queueMutex    sync.Mutex
+	ignored       []string  // Sent to the server again after reconnecting
+	retryAt       time.Time // Earliest reconnect attempt the server allows
+	mutex         sync.RWMutex
+	reconnectChan chan bool
+	connectedChan chan bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	// AutoFlush sends messages queued while offline as soon as the
+	// connection is back instead of waiting for FlushPending
+	AutoFlush bool
+}
+
+// FileTransferProgress tracks file transfer progress
+type FileTransferProgress struct {
+	FileID      string
+	Filename    string
+	Filesize    int64
+	Sender      string            // Nickname of the sender of an incoming file
+	ContentType string            // MIME type announced by the sender
+	Metadata    map[string]string // Details announced by the sender
+	IsIncoming  bool
+	Progress    float64
+	StartTime   time.Time
+	Chunks      *common.ChunkAssembler // Received data, set for incoming transfers
+	TotalChunks int
+	BatchID     string // Set when the file is part of a SendFiles batch
+	Paused      bool
+	cancelled   bool
+	resumed     *sync.Cond // Wakes a paused sender, created on first use
+	mutex       sync.Mutex
+}
+
+// NewConnection creates a new connection manager
+func NewConnection(nickname string) *Connection {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Connection{
+		nickname:      nickname,
+		status:        common.StatusActive,
+		sendChan:      make(chan *common.Message, 100),
+		fileTransfers: make(map[string]*FileTransferProgress),
+		users:         NewUserDirectory(),
+		pings:         newPingTracker(),
+		reconnectChan: make(chan bool, 1),
+		connectedChan: make(chan bool, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Connect establishes connection to the server
+func (c *Connection) Connect(address string) error {
+	// Cancel any existing goroutines
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	// Set connection timeout
+	conn, err := net.DialTimeout("tcp", address, common.ConnectionTimeout)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.conn = conn
+	c.address = address
+	c.connected = true
+	c.mutex.Unlock()
+	c.pings.reset()
+
+	// Set read/write deadlines
+	conn.SetReadDeadline(time.Now().Add(common.ReadTimeout))
+	conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
+
+	// Signal that we're connected
+	select {
+	case c.connectedChan <- true:
+	default:
+	}
+
+	// Send connection message with nickname
+	connectMsg := &common.Message{
+		Type:    common.TypeConnect,
+		Content: c.nickname,
+	}
+
+	if err := c.sendMessage(connectMsg); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Start read and write pumps with context
+	go c.readPump(c.ctx)
+	go c.writePump(c.ctx)
+
+	return nil
+}
+
+// ConnectWithRetry connects with automatic retry, backing off exponentially
+// until it succeeds or Disconnect is called
+func (c *Connection) ConnectWithRetry(address string) {
+	// Disconnect signals reconnectChan, stop waiting for the next attempt then
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		select {
+		case <-c.reconnectChan:
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+
+	policy := retry.Policy{
+		InitialDelay: time.Second,
+		MaxDelay:     time.Minute,
+		Jitter:       0.2,
+		RetryIf:      func(error) bool { return !c.isClosed() },
+		OnRetry: func(_ int, err error, delay time.Duration) {
+			log.Printf("Connection failed: %v. Retrying in %v...", err, delay.Round(time.Millisecond))
+		},
+	}
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		if c.isClosed() {
+			return ErrClosed
+		}
+
+		// Respect the retry time the server asked for when it rejected us
+		c.mutex.RLock()
+		wait := time.Until(c.retryAt)
+		c.mutex.RUnlock()
+		if wait > 0 {
+			log.Printf("Server asked to retry in %v", wait.Round(time.Second))
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		log.Printf("Connecting to %s...", address)
+		return c.Connect(address)
+	})
+	if err != nil {
+		return
+	}
+
+	log.Println("Connected successfully!")
+	c.SetConnected(true)
+	c.afterConnect()
+}
+
+// Nickname returns the nickname used to register with the server
+func (c *Connection) Nickname() string {
+	return c.nickname
+}
+
+// Status returns the last status set by this client
+func (c *Connection) Status() common.UserStatus {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.status
+}
+
+// Format returns the format text messages are sent in
+func (c *Connection) Format() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.format
+}
+
+// SetFormat sets the format of text messages sent from now on,
+// common.FormatPlain or common.FormatMarkdown
+func (c *Connection) SetFormat(format string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.format = format
+}
+
+// IsConnected returns connection status
+func (c *Connection) IsConnected() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.connected
+}
+
+// Users returns the online users as sorted "nickname:status" entries, see
+// UserDirectory.List
+func (c *Connection) Users() []string {
+	return c.users.List()
+}
+
+// UserStatus returns the status of an online user
+func (c *Connection) UserStatus(nickname string) (common.UserStatus, bool) {
+	return c.users.Status(nickname)
+}
+
+// WaitForConnection blocks until connected
+func (c *Connection) WaitForConnection() {
+	<-c.connectedChan
+}
+
+// SetConnected sets connection status
+func (c *Connection) SetConnected(connected bool) {
+	c.mutex.Lock()
+	c.connected = connected
+	c.mutex.Unlock()
+}
+
+// isClosed reports whether Disconnect was called
+func (c *Connection) isClosed() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.closed
+}
+
+// connectionLost queues unsent messages and starts reconnecting
+func (c *Connection) connectionLost() {
+	c.mutex.Lock()
+	c.connected = false
+	if c.cancel != nil {
+		c.cancel()
+	}
+	address := c.address
+	c.mutex.Unlock()
+
+	c.drainSendChan()
+	c.notify("Connection lost, reconnecting... (%d message(s) queued)", c.PendingCount())
+	go c.ConnectWithRetry(address)
+}
+
+// Disconnect closes the connection
+func (c *Connection) Disconnect() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	c.cancelTransfers()
+
+	// Cancel context to stop goroutines
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			log.Printf("Error closing connection: %v", err)
+		}
+		c.connected = false
+	}
+
+	// Signal to stop reconnection attempts
+	select {
+	case c.reconnectChan <- true:
+	default:
+	}
+}
+
+// Send queues a message for delivery to the server. While offline the
+// message is kept in the offline queue and ErrQueued is returned.
+func (c *Connection) Send(msg *common.Message) error {
+	c.mutex.RLock()
+	closed := c.closed
+	connected := c.connected
+	c.mutex.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	// The ID stays the same when a queued message is sent again, so the
+	// server can drop it if the first attempt got through
+	if msg.ID == "" && msg.Type != common.TypeFileChunk {
+		msg.ID = common.NewMessageID()
+	}
+	if !connected {
+		return c.enqueue(msg)
+	}
+	c.sendChan <- msg
+	return nil
+}
+
+// Subscribe returns a channel receiving every incoming message. Subscribers
+// must keep draining the channel, otherwise delivery to others blocks.
+func (c *Connection) Subscribe() <-chan *common.Message {
+	ch := make(chan *common.Message, 100)
+	c.subMutex.Lock()
+	c.subscribers = append(c.subscribers, ch)
+	c.subMutex.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it
+func (c *Connection) Unsubscribe(ch <-chan *common.Message) {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+	for i, sub := range c.subscribers {
+		if sub == ch {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			close(sub)
+			return
+		}
+	}
+}
+
+// deliver passes a message to all subscribers
+func (c *Connection) deliver(msg *common.Message) {
+	c.subMutex.RLock()
+	defer c.subMutex.RUnlock()
+	for _, sub := range c.subscribers {
+		sub <- msg
+	}
+}
+
+// notify delivers a translated client-generated notice to subscribers
+func (c *Connection) notify(format string, args ...interface{}) {
+	c.deliver(&common.Message{
+		Type:      TypeLocal,
+		Sender:    "Client",
+		Content:   common.T(format, args...),
+		Timestamp: time.Now(),
+	})
+}
+
+// SendTextMessage sends a text message
+func (c *Connection) SendTextMessage(recipient, content string) error {
+	msg := common.NewTextMessage(c.nickname, recipient, content)
+	msg.Format = c.Format()
+	return c.Send(msg)
+}
+
+// SendBroadcastMessage sends a broadcast message
+func (c *Connection) SendBroadcastMessage(content string) error {
+	msg := common.NewBroadcastMessage(c.nickname, content)
+	msg.Format = c.Format()
+	return c.Send(msg)
+}
+
+// SendAutoReply answers a text message in the conversation it came from,
+// marked as automatic so the triggers of its recipients ignore it
+func (c *Connection) SendAutoReply(to *common.Message, content string) error {
+	msg := common.NewTextMessage(c.nickname, "", content)
+	switch {
+	case to.Room != "":
+		msg.Room = to.Room
+	case to.Recipient == c.Nickname():
+		msg.Recipient = to.Sender
+	}
+	msg.Format = c.Format()
+	msg.Metadata = map[string]string{common.MetaAutoReply: "true"}
+	return c.Send(msg)
+}
+
+// SendRoomMessage sends a message to a room
+func (c *Connection) SendRoomMessage(roomID, content string) error {
+	msg := &common.Message{
+		Type:      common.TypeText,
+		Room:      roomID,
+		Content:   content,
+		Format:    c.Format(),
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// ChangeStatus updates user status
+func (c *Connection) ChangeStatus(status common.UserStatus) error {
+	c.mutex.Lock()
+	c.status = status
+	c.mutex.Unlock()
+	return c.Send(common.NewStatusMessage(c.nickname, status))
+}
+
+// SetAway marks the user away with an optional reason the server sends to
+// anyone messaging them privately
+func (c *Connection) SetAway(message string) error {
+	c.mutex.Lock()
+	c.status = common.StatusAway
+	c.mutex.Unlock()
+	msg := common.NewStatusMessage(c.nickname, common.StatusAway)
+	msg.Content = message
+	return c.Send(msg)
+}
+
+// QueryStatus asks the server whether a user is available; the answer is
+// a TypeStatus message with the user as sender
+func (c *Connection) QueryStatus(nickname string) error {
+	return c.Send(&common.Message{
+		Type:      common.TypeStatus,
+		Recipient: nickname,
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateRoom creates a new room
+func (c *Connection) CreateRoom(name string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomCreate,
+		Content:   name,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// CreateEphemeralRoom creates a room the server deletes when its last
+// member leaves or, with a positive ttl, once the ttl passes
+func (c *Connection) CreateEphemeralRoom(name string, ttl time.Duration) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomCreate,
+		Content:   name,
+		Ephemeral: true,
+		TTL:       int64(ttl / time.Second),
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// InviteToRoom invites a user to a room
+func (c *Connection) InviteToRoom(roomID, userNickname string) error {
+	msg := &common.Message{
+		Type:      common.TypeInvite,
+		Room:      roomID,
+		Recipient: userNickname,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// RespondToInvite responds to a room invitation
+func (c *Connection) RespondToInvite(roomID string, accept bool) error {
+	response := "decline"
+	if accept {
+		response = "accept"
+	}
+
+	msg := &common.Message{
+		Type:      common.TypeInviteResp,
+		Room:      roomID,
+		Content:   response,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// SetIgnoreList asks the server to drop messages, invites and files from
+// the given users; the list is restored automatically after reconnecting
+func (c *Connection) SetIgnoreList(nicknames []string) error {
+	c.mutex.Lock()
+	c.ignored = append([]string{}, nicknames...)
+	c.mutex.Unlock()
+
+	if !c.IsConnected() {
+		return nil
+	}
+	return c.Send(&common.Message{Type: common.TypeIgnore, Users: nicknames})
+}
+
+// AnswerChallenge replies to the question the server asks before it
+// registers the nickname
+func (c *Connection) AnswerChallenge(answer string) error {
+	return c.Send(&common.Message{Type: common.TypeChallenge, Content: answer})
+}
+
+// RequestStats asks the server for its statistics, answered with a TypeStats message
+func (c *Connection) RequestStats() error {
+	return c.Send(&common.Message{Type: common.TypeStats})
+}
+
+// JoinRoom joins an existing room by ID
+func (c *Connection) JoinRoom(roomID string) error {
+	return c.JoinProtectedRoom(roomID, "")
+}
+
+// JoinProtectedRoom joins a room that requires a password
+func (c *Connection) JoinProtectedRoom(roomID, password string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomJoin,
+		Room:      roomID,
+		Content:   password,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// SetRoomSettings changes the join policy and member limit of a room
+// (creator only), e.g. "policy=password password=secret max=10"
+func (c *Connection) SetRoomSettings(roomID, settings string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomSettings,
+		Room:      roomID,
+		Content:   settings,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// LeaveRoom sends a leave room message
+func (c *Connection) LeaveRoom(roomID string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomLeave,
+		Room:      roomID,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// GetRoomMembers requests the member list for a room
+func (c *Connection) GetRoomMembers(roomID string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomMembers,
+		Room:      roomID,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// KickFromRoom kicks a user from a room (creator only)
+func (c *Connection) KickFromRoom(roomID, nickname string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomKick,
+		Room:      roomID,
+		Recipient: nickname,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// DeleteRoom deletes a room (creator only)
+func (c *Connection) DeleteRoom(roomID string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomDelete,
+		Room:      roomID,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// SetRoomTopic sets the topic/description for a room
+func (c *Connection) SetRoomTopic(roomID, description string) error {
+	msg := &common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomSetTopic,
+		Room:      roomID,
+		Content:   description,
+		Timestamp: time.Now(),
+	}
+	return c.Send(msg)
+}
+
+// readPump reads messages from the server
+func (c *Connection) readPump(ctx context.Context) {
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+
+	defer func() {
+		conn.Close()
+		if ctx.Err() == nil && !c.isClosed() {
+			c.connectionLost()
+		} else {
+			c.SetConnected(false)
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Reset read deadline on successful read
+		conn.SetReadDeadline(time.Now().Add(common.ReadTimeout))
+
+		data := scanner.Bytes()
+		msg, err := common.DecodeMessage(data)
+		if err != nil {
+			log.Printf("Error decoding message: %v", err)
+			continue
+		}
+
+		// Handle file chunks separately
+		switch msg.Type {
+		case common.TypeFileChunk:
+			c.handleFileChunk(msg)
+		case common.TypeFile:
+			c.registerIncomingFile(msg)
+			c.deliver(msg)
+		case common.TypeFileControl:
+			c.handleFileControl(msg)
+		case common.TypeError:
+			c.handleError(msg)
+			c.deliver(msg)
+		case common.TypePong:
+			c.handlePong(msg)
+		case common.TypeUserList, common.TypeUserJoined, common.TypeUserLeft, common.TypeUserStatus:
+			c.users.Apply(msg)
+			c.deliver(msg)
+		default:
+			c.deliver(msg)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("Read error: %v", err)
+	}
+}
+
+// handleError remembers when the server allows the next attempt after
+// rejecting a connection or throttling messages
+func (c *Connection) handleError(msg *common.Message) {
+	chatErr := msg.ChatError()
+	if chatErr.Type != common.ErrRateLimit && chatErr.Type != common.ErrUnavailable {
+		return
+	}
+	if wait, ok := chatErr.RetryAfter(); ok {
+		c.mutex.Lock()
+		c.retryAt = time.Now().Add(wait)
+		c.mutex.Unlock()
+	}
+}
+
+// RetryAfter returns how long the server asked the client to wait before
+// trying again; it is zero when no limit is in effect
+func (c *Connection) RetryAfter() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if wait := time.Until(c.retryAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// writePump writes messages to the server
+func (c *Connection) writePump(ctx context.Context) {
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+
+	ticker := time.NewTicker(common.PingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-c.sendChan:
+			if err := c.sendMessage(msg); err != nil {
+				log.Printf("Write error: %v", err)
+				// Keep the message for resending after reconnection
+				c.enqueue(msg)
+				return
+			}
+
+		case <-ticker.C:
+			if !c.keepAlive() {
+				return
+			}
+		}
+	}
+}
+
+// sendMessage sends a message to the server
+func (c *Connection) sendMessage(msg *common.Message) error {
+	data, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	// Set write deadline
+	conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
+
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+// registerIncomingFile records an announced file so chunks keep its name
+func (c *Connection) registerIncomingFile(msg *common.Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, exists := c.fileTransfers[msg.FileID]; exists {
+		return
+	}
+	c.fileTransfers[msg.FileID] = &FileTransferProgress{
+		FileID:      msg.FileID,
+		Filename:    msg.Filename,
+		Filesize:    msg.Filesize,
+		Sender:      msg.Sender,
+		ContentType: msg.ContentType,
+		Metadata:    msg.Metadata,
+		IsIncoming:  true,
+		StartTime:   time.Now(),
+		Chunks:      common.NewChunkAssembler(msg.TotalChunks, common.DefaultSpillThreshold),
+		TotalChunks: msg.TotalChunks,
+	}
+}
+
+// handleFileChunk processes incoming file chunks
+func (c *Connection) handleFileChunk(msg *common.Message) {
+	c.mutex.Lock()
+	transfer, exists := c.fileTransfers[msg.FileID]
+	if !exists {
+		// Chunk of an unannounced or cancelled transfer
+		c.mutex.Unlock()
+		return
+	}
+	c.mutex.Unlock()
+
+	// Duplicates are ignored, they must not report completion twice
+	if transfer.Chunks.Has(msg.ChunkNum) {
+		return
+	}
+	if err := transfer.Chunks.Add(msg.ChunkNum, msg.Data); err != nil {
+		log.Printf("Dropping chunk %d of %s: %v", msg.ChunkNum, transfer.Filename, err)
+		return
+	}
+
+	transfer.mutex.Lock()
+	transfer.Progress = transfer.Chunks.Progress()
+	transfer.mutex.Unlock()
+
+	// Forward to UI for progress display
+	progressMsg := &common.Message{
+		Type:     common.TypeFileChunk,
+		FileID:   msg.FileID,
+		Filename: transfer.Filename,
+		Content:  fmt.Sprintf("%.1f%%", transfer.Progress),
+	}
+	c.deliver(progressMsg)
+
+	// Check if complete
+	if transfer.Chunks.IsComplete() {
+		completeMsg := &common.Message{
+			Type:     common.TypeFileComplete,
+			FileID:   msg.FileID,
+			Filename: transfer.Filename,
+		}
+		c.deliver(completeMsg)
+	}
+}
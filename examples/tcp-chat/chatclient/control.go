@@ -0,0 +1,160 @@
+package chatclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"tcp-chat/common"
+)
+
+// FindTransfer resolves a full or abbreviated file ID to a transfer ID
+func (ft *FileTransfer) FindTransfer(prefix string) (string, error) {
+	if prefix == "" {
+		return "", fmt.Errorf("file ID is required")
+	}
+
+	ft.conn.mutex.RLock()
+	defer ft.conn.mutex.RUnlock()
+
+	var matches []string
+	for fileID := range ft.conn.fileTransfers {
+		if strings.HasPrefix(fileID, prefix) {
+			matches = append(matches, fileID)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("file transfer %s not found", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("file ID %s is ambiguous", prefix)
+	}
+}
+
+// Pause suspends a transfer; the sender stops sending chunks until resumed
+func (ft *FileTransfer) Pause(fileID string) error {
+	return ft.control(fileID, common.FilePause)
+}
+
+// Resume continues a paused transfer
+func (ft *FileTransfer) Resume(fileID string) error {
+	return ft.control(fileID, common.FileResume)
+}
+
+// Cancel aborts a transfer on both sides
+func (ft *FileTransfer) Cancel(fileID string) error {
+	return ft.control(fileID, common.FileCancel)
+}
+
+// control applies an action locally and forwards it to the other side
+func (ft *FileTransfer) control(fileID, action string) error {
+	transfer, ok := ft.conn.applyFileControl(fileID, action)
+	if !ok {
+		return fmt.Errorf("file transfer %s not found", fileID)
+	}
+
+	msg := &common.Message{
+		Type:     common.TypeFileControl,
+		Sender:   ft.conn.Nickname(),
+		FileID:   fileID,
+		Filename: transfer.Filename,
+		Content:  action,
+	}
+	if err := ft.conn.Send(msg); err != nil && action != common.FileCancel {
+		return err
+	}
+	return nil
+}
+
+// applyFileControl changes the state of a transfer
+func (c *Connection) applyFileControl(fileID, action string) (*FileTransferProgress, bool) {
+	c.mutex.Lock()
+	transfer, exists := c.fileTransfers[fileID]
+	if exists && action == common.FileCancel {
+		delete(c.fileTransfers, fileID)
+	}
+	c.mutex.Unlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	transfer.mutex.Lock()
+	defer transfer.mutex.Unlock()
+	switch action {
+	case common.FilePause:
+		transfer.Paused = true
+	case common.FileResume:
+		transfer.Paused = false
+	case common.FileCancel:
+		transfer.cancelled = true
+		transfer.releaseChunks()
+	default:
+		return nil, false
+	}
+	transfer.wake()
+	return transfer, true
+}
+
+// handleFileControl applies a control message sent by the other side
+func (c *Connection) handleFileControl(msg *common.Message) {
+	transfer, ok := c.applyFileControl(msg.FileID, msg.Content)
+	if !ok {
+		return
+	}
+
+	switch msg.Content {
+	case common.FilePause:
+		c.notify("%s paused the transfer of %s", msg.Sender, transfer.Filename)
+	case common.FileResume:
+		c.notify("%s resumed the transfer of %s", msg.Sender, transfer.Filename)
+	case common.FileCancel:
+		c.notify("%s cancelled the transfer of %s", msg.Sender, transfer.Filename)
+	}
+}
+
+// cancelTransfers stops all transfers; the caller must hold c.mutex
+func (c *Connection) cancelTransfers() {
+	for fileID, transfer := range c.fileTransfers {
+		transfer.mutex.Lock()
+		transfer.cancelled = true
+		transfer.releaseChunks()
+		transfer.wake()
+		transfer.mutex.Unlock()
+		delete(c.fileTransfers, fileID)
+	}
+}
+
+// releaseChunks drops received data of a cancelled incoming transfer
+func (t *FileTransferProgress) releaseChunks() {
+	if t.Chunks != nil {
+		t.Chunks.Close()
+	}
+}
+
+// waitWhilePaused blocks while the transfer is paused and reports whether
+// sending may continue
+func (t *FileTransferProgress) waitWhilePaused() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for t.Paused && !t.cancelled {
+		t.cond().Wait()
+	}
+	return !t.cancelled
+}
+
+// wake releases a sender waiting in waitWhilePaused; t.mutex must be held
+func (t *FileTransferProgress) wake() {
+	t.cond().Broadcast()
+}
+
+// cond returns the condition used to wait for resumption; t.mutex must be held
+func (t *FileTransferProgress) cond() *sync.Cond {
+	if t.resumed == nil {
+		t.resumed = sync.NewCond(&t.mutex)
+	}
+	return t.resumed
+}
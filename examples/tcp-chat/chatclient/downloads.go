@@ -0,0 +1,133 @@
+package chatclient
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tcp-chat/common"
+)
+
+// CollisionPolicy decides what happens when a received file has the name
+// of a file already in the download directory
+type CollisionPolicy string
+
+const (
+	CollisionRename    CollisionPolicy = "rename"    // Save as "name (1).ext", the default
+	CollisionOverwrite CollisionPolicy = "overwrite" // Replace the existing file
+	CollisionAsk       CollisionPolicy = "ask"       // Keep the file until the user decides
+)
+
+// ErrFileExists is returned by ReceiveFile when the policy is CollisionAsk
+// and the target exists; the transfer is kept for ReceiveFileAs or Discard
+var ErrFileExists = errors.New("file already exists")
+
+// ParseCollisionPolicy parses a policy from the configuration, empty
+// meaning CollisionRename
+func ParseCollisionPolicy(text string) (CollisionPolicy, error) {
+	switch policy := CollisionPolicy(strings.ToLower(text)); policy {
+	case "":
+		return CollisionRename, nil
+	case CollisionRename, CollisionOverwrite, CollisionAsk:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid collision policy %q, use rename, overwrite or ask", text)
+	}
+}
+
+// downloadDir returns the directory a received file is saved in
+func (ft *FileTransfer) downloadDir(transfer *FileTransferProgress) string {
+	dir := ft.DownloadDir
+	if ft.PerSender && transfer.Sender != "" {
+		dir = filepath.Join(dir, filepath.Base(transfer.Sender))
+	}
+	if ft.SortDownloads {
+		contentType := transfer.ContentType
+		if contentType == "" {
+			// Senders predating content types, go by the name
+			contentType = common.DetectContentType(transfer.Filename, nil)
+		}
+		dir = filepath.Join(dir, common.FileKind(contentType))
+	}
+	return dir
+}
+
+// DiskUsage returns the bytes taken by the download directory
+func (ft *FileTransfer) DiskUsage() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(ft.DownloadDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// AcceptOffer checks an announced incoming file against the quota; saved
+// files and other incoming transfers count towards it
+func (ft *FileTransfer) AcceptOffer(fileID string) error {
+	if ft.Quota <= 0 {
+		return nil
+	}
+	used, err := ft.DiskUsage()
+	if err != nil {
+		return fmt.Errorf("failed to check disk usage: %v", err)
+	}
+
+	ft.conn.mutex.RLock()
+	var offered int64
+	for id, transfer := range ft.conn.fileTransfers {
+		if transfer.IsIncoming && id != fileID {
+			used += transfer.Filesize
+		} else if id == fileID {
+			offered = transfer.Filesize
+		}
+	}
+	ft.conn.mutex.RUnlock()
+
+	if used+offered > ft.Quota {
+		return fmt.Errorf("download quota of %s exceeded, %s in use",
+			FormatFileSize(ft.Quota), FormatFileSize(used))
+	}
+	return nil
+}
+
+// Discard drops a received file that is waiting for a collision decision
+func (ft *FileTransfer) Discard(fileID string) error {
+	ft.conn.mutex.Lock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	delete(ft.conn.fileTransfers, fileID)
+	ft.conn.mutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("file transfer %s not found", fileID)
+	}
+	transfer.releaseChunks()
+	return nil
+}
+
+// uniquePath appends " (n)" to the name in path until no file has it
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package chatclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// ChunkSize is defined in common/constants.go as FileChunkSize
+
+// DefaultDownloadDir is where received files are saved by default
+const DefaultDownloadDir = "downloads"
+
+// FileTransfer manages file transfers
+type FileTransfer struct {
+	conn        *Connection
+	batches     map[string]*TransferBatch // Guarded by conn.mutex
+	DownloadDir string
+
+	// SortDownloads saves received files in a subfolder of DownloadDir
+	// named after their kind, e.g. downloads/image
+	SortDownloads bool
+	// PerSender saves received files in a subfolder named after the
+	// sender, above the kind subfolder
+	PerSender bool
+	// OnCollision decides what happens to files with a taken name
+	OnCollision CollisionPolicy
+	// Quota bounds the bytes in DownloadDir, offers exceeding it are
+	// declined; zero means no limit
+	Quota int64
+}
+
+// NewFileTransfer creates a new file transfer manager
+func NewFileTransfer(conn *Connection) *FileTransfer {
+	return &FileTransfer{
+		conn:        conn,
+		batches:     make(map[string]*TransferBatch),
+		DownloadDir: DefaultDownloadDir,
+		OnCollision: CollisionRename,
+	}
+}
+
+// SendFile sends a file to a recipient
+func (ft *FileTransfer) SendFile(recipient, filePath string) error {
+	return ft.SendFileWithMetadata(recipient, filePath, nil)
+}
+
+// SendVoiceMemo sends a recorded audio file marked as a voice memo
+func (ft *FileTransfer) SendVoiceMemo(recipient, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	contentType, err := sniffContentType(file, filepath.Base(filePath))
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	if common.FileKind(contentType) != common.KindAudio {
+		return fmt.Errorf("a voice memo must be an audio file, not %s", contentType)
+	}
+	return ft.SendFileWithMetadata(recipient, filePath, map[string]string{common.MetaVoiceMemo: "true"})
+}
+
+// SendFileWithMetadata sends a file to a recipient along with metadata
+// describing it; the content type is detected from the file
+func (ft *FileTransfer) SendFileWithMetadata(recipient, filePath string, metadata map[string]string) error {
+	file, transfer, err := ft.startFile(recipient, filePath, filepath.Base(filePath), "", metadata)
+	if err != nil {
+		return err
+	}
+
+	// Start sending chunks
+	go ft.sendFileChunks(file, transfer.FileID, recipient, transfer.TotalChunks)
+
+	return nil
+}
+
+// startFile opens a file, registers the transfer and announces it to the
+// recipient; the returned file is owned by the caller
+func (ft *FileTransfer) startFile(recipient, filePath, filename, batchID string, metadata map[string]string) (*os.File, *FileTransferProgress, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %v", err)
+	}
+
+	// The file is handed over to the caller once the transfer is announced
+	started := false
+	defer func() {
+		if !started {
+			file.Close()
+		}
+	}()
+
+	// Get file info
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get file info: %v", err)
+	}
+
+	// Check if it's a directory
+	if fileInfo.IsDir() {
+		return nil, nil, fmt.Errorf("cannot send directory as file")
+	}
+
+	// Generate file ID
+	fileID := generateFileID()
+	filesize := fileInfo.Size()
+
+	// Validate file size
+	if filesize > common.MaxFileSize {
+		return nil, nil, fmt.Errorf("file size exceeds maximum allowed size of %d bytes", common.MaxFileSize)
+	}
+
+	contentType, err := sniffContentType(file, filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	totalChunks := int(filesize / common.FileChunkSize)
+	if filesize%common.FileChunkSize != 0 {
+		totalChunks++
+	}
+
+	// Create file transfer record
+	transfer := &FileTransferProgress{
+		FileID:      fileID,
+		Filename:    filename,
+		Filesize:    filesize,
+		ContentType: contentType,
+		Metadata:    metadata,
+		IsIncoming:  false,
+		StartTime:   time.Now(),
+		TotalChunks: totalChunks,
+		BatchID:     batchID,
+	}
+
+	ft.conn.mutex.Lock()
+	ft.conn.fileTransfers[fileID] = transfer
+	ft.conn.mutex.Unlock()
+
+	// Send file init message
+	initMsg := &common.Message{
+		Type:        common.TypeFile,
+		Recipient:   recipient,
+		FileID:      fileID,
+		Filename:    filename,
+		Filesize:    filesize,
+		ContentType: contentType,
+		Metadata:    metadata,
+		TotalChunks: totalChunks,
+		Timestamp:   time.Now(),
+	}
+
+	if err := ft.conn.Send(initMsg); err != nil {
+		ft.conn.mutex.Lock()
+		delete(ft.conn.fileTransfers, fileID)
+		ft.conn.mutex.Unlock()
+		return nil, nil, err
+	}
+
+	started = true
+	return file, transfer, nil
+}
+
+// sendFileChunks sends file chunks and reports whether the file was sent
+func (ft *FileTransfer) sendFileChunks(file *os.File, fileID, recipient string, totalChunks int) bool {
+	defer file.Close() // Ensure file is always closed
+
+	ft.conn.mutex.RLock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	ft.conn.mutex.RUnlock()
+	if !exists {
+		return false
+	}
+
+	buffer := make([]byte, common.FileChunkSize)
+	chunkNum := 0
+
+	for {
+		// Block while paused, stop when cancelled
+		if !transfer.waitWhilePaused() {
+			ft.conn.notify("File transfer cancelled: %s", transfer.Filename)
+			return false
+		}
+
+		n, err := file.Read(buffer)
+		if err != nil && err != io.EOF {
+			ft.notifyError(fileID, fmt.Sprintf("Read error: %v", err))
+			return false
+		}
+
+		if n == 0 {
+			break
+		}
+
+		// Copy the chunk since the buffer is reused for the next read
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+
+		// Send chunk
+		chunkMsg := &common.Message{
+			Type:        common.TypeFileChunk,
+			Recipient:   recipient,
+			FileID:      fileID,
+			ChunkNum:    chunkNum,
+			TotalChunks: totalChunks,
+			Data:        data,
+			Timestamp:   time.Now(),
+		}
+
+		if err := ft.conn.Send(chunkMsg); err != nil {
+			ft.notifyError(fileID, err.Error())
+			return false
+		}
+
+		// Update progress
+		ft.updateProgress(fileID, chunkNum, totalChunks, n)
+
+		chunkNum++
+
+		// Small delay to avoid overwhelming the connection
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// File transfer complete
+	ft.notifyComplete(fileID)
+	return true
+}
+
+// ReceiveFile saves a received file and returns its path, a taken name is
+// handled according to OnCollision
+func (ft *FileTransfer) ReceiveFile(fileID string) (string, error) {
+	return ft.ReceiveFileAs(fileID, ft.OnCollision)
+}
+
+// ReceiveFileAs saves a received file, handling a taken name according to
+// policy; with CollisionAsk it returns the taken path and ErrFileExists
+func (ft *FileTransfer) ReceiveFileAs(fileID string, policy CollisionPolicy) (string, error) {
+	ft.conn.mutex.RLock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	ft.conn.mutex.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("file transfer not found")
+	}
+
+	// Create downloads directory
+	downloadDir := ft.downloadDir(transfer)
+	if err := os.MkdirAll(downloadDir, common.GetDirMode()); err != nil {
+		return "", fmt.Errorf("failed to create download directory: %v", err)
+	}
+
+	// Sanitize filename to prevent path traversal attacks
+	filename := filepath.Base(transfer.Filename)
+	if filename == "." || filename == ".." || filename == "/" || filename == "" {
+		return "", fmt.Errorf("invalid filename: %s", transfer.Filename)
+	}
+
+	// Resolve a name collision
+	filePath := filepath.Join(downloadDir, filename)
+	if _, err := os.Lstat(filePath); err == nil {
+		switch policy {
+		case CollisionAsk:
+			return filePath, ErrFileExists
+		case CollisionOverwrite:
+		default:
+			filePath = uniquePath(filePath)
+		}
+	}
+
+	// Create file
+	file, err := os.Create(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	// Write chunks in order
+	if _, err := transfer.Chunks.WriteTo(file); err != nil {
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+
+	// Clean up
+	ft.conn.mutex.Lock()
+	delete(ft.conn.fileTransfers, fileID)
+	ft.conn.mutex.Unlock()
+	transfer.Chunks.Close()
+
+	return filePath, nil
+}
+
+// sniffContentType detects the content type of a file from its name and
+// first bytes and rewinds it for sending
+func sniffContentType(file *os.File, filename string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return common.DetectContentType(filename, head[:n]), nil
+}
+
+// Describe returns the content type and metadata of a transfer, they stay
+// available until the file is received
+func (ft *FileTransfer) Describe(fileID string) (contentType string, metadata map[string]string) {
+	ft.conn.mutex.RLock()
+	defer ft.conn.mutex.RUnlock()
+	if transfer, exists := ft.conn.fileTransfers[fileID]; exists {
+		return transfer.ContentType, transfer.Metadata
+	}
+	return "", nil
+}
+
+// updateProgress updates transfer progress
+func (ft *FileTransfer) updateProgress(fileID string, chunkNum, totalChunks, size int) {
+	ft.conn.mutex.Lock()
+	defer ft.conn.mutex.Unlock()
+
+	if transfer, exists := ft.conn.fileTransfers[fileID]; exists {
+		transfer.Progress = float64(chunkNum+1) / float64(totalChunks) * 100
+		if batch, ok := ft.batches[transfer.BatchID]; ok {
+			batch.SentBytes += int64(size)
+		}
+	}
+}
+
+// IsIncoming reports whether a transfer is being received by this client
+func (ft *FileTransfer) IsIncoming(fileID string) bool {
+	ft.conn.mutex.RLock()
+	defer ft.conn.mutex.RUnlock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	return exists && transfer.IsIncoming
+}
+
+// notifyComplete notifies completion
+func (ft *FileTransfer) notifyComplete(fileID string) {
+	ft.conn.mutex.Lock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	delete(ft.conn.fileTransfers, fileID)
+	ft.conn.mutex.Unlock()
+
+	if exists {
+		duration := time.Since(transfer.StartTime)
+		speed := float64(transfer.Filesize) / duration.Seconds() / 1024 / 1024 // MB/s
+
+		ft.conn.notify("File transfer complete: %s (%.2f MB/s)", transfer.Filename, speed)
+	}
+}
+
+// notifyError notifies transfer error
+func (ft *FileTransfer) notifyError(fileID, error string) {
+	ft.conn.mutex.Lock()
+	transfer, exists := ft.conn.fileTransfers[fileID]
+	delete(ft.conn.fileTransfers, fileID)
+	ft.conn.mutex.Unlock()
+
+	if exists {
+		ft.conn.notify("File transfer error: %s - %s", transfer.Filename, error)
+	}
+}
+
+// GetTransferProgress returns current transfer progress
+func (ft *FileTransfer) GetTransferProgress() []string {
+	ft.conn.mutex.RLock()
+	defer ft.conn.mutex.RUnlock()
+
+	var progress []string
+	for _, batch := range ft.batches {
+		percent := 0.0
+		if batch.TotalBytes > 0 {
+			percent = float64(batch.SentBytes) / float64(batch.TotalBytes) * 100
+		}
+		progress = append(progress, fmt.Sprintf("⇑ batch to %s: %d/%d files, %.1f%% (%s)",
+			batch.Recipient,
+			batch.Completed+batch.Failed,
+			len(batch.Files),
+			percent,
+			FormatFileSize(batch.TotalBytes)))
+	}
+
+	for _, transfer := range ft.conn.fileTransfers {
+		direction := "↓"
+		if !transfer.IsIncoming {
+			direction = "↑"
+		}
+
+		status := fmt.Sprintf("%s [%s] %s: %.1f%% (%s)",
+			direction,
+			transfer.FileID[:min(len(transfer.FileID), 8)],
+			transfer.Filename,
+			transfer.Progress,
+			FormatFileSize(transfer.Filesize))
+		if transfer.Paused {
+			status += " paused"
+		}
+
+		progress = append(progress, status)
+	}
+
+	return progress
+}
+
+// generateFileID generates a unique file ID
+func generateFileID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// Fallback to timestamp-based ID if random generation fails
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// FormatFileSize formats file size in human readable format
+func FormatFileSize(size int64) string {
+	return common.FormatFileSize(size)
+}
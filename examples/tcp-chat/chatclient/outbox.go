@@ -0,0 +1,112 @@
+package chatclient
+
+import (
+	"errors"
+
+	"tcp-chat/common"
+)
+
+// MaxPendingMessages limits how many messages are kept while offline
+const MaxPendingMessages = 500
+
+var (
+	// ErrQueued is returned when a message was queued because the client is offline
+	ErrQueued = errors.New("not connected, message queued")
+	// ErrQueueFull is returned when the offline queue cannot take more messages
+	ErrQueueFull = errors.New("not connected and offline queue is full")
+	// ErrNotConnected is returned for messages that cannot be queued while offline
+	ErrNotConnected = errors.New("not connected")
+)
+
+// enqueue stores a message for sending after reconnection
+func (c *Connection) enqueue(msg *common.Message) error {
+	switch msg.Type {
+	case common.TypeFile, common.TypeFileChunk, common.TypeDisconnect, common.TypeConnect, common.TypePing:
+		// File data is too large to hold and the rest is connection-specific
+		return ErrNotConnected
+	}
+
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	if len(c.pending) >= MaxPendingMessages {
+		return ErrQueueFull
+	}
+	c.pending = append(c.pending, msg)
+	return ErrQueued
+}
+
+// drainSendChan moves messages that were not written yet into the offline queue
+func (c *Connection) drainSendChan() {
+	for {
+		select {
+		case msg := <-c.sendChan:
+			c.enqueue(msg)
+		default:
+			return
+		}
+	}
+}
+
+// PendingMessages returns a copy of the messages queued while offline
+func (c *Connection) PendingMessages() []*common.Message {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	return append([]*common.Message(nil), c.pending...)
+}
+
+// PendingCount returns the number of messages queued while offline
+func (c *Connection) PendingCount() int {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	return len(c.pending)
+}
+
+// FlushPending sends all queued messages and returns how many were sent
+func (c *Connection) FlushPending() (int, error) {
+	if !c.IsConnected() {
+		return 0, ErrNotConnected
+	}
+
+	c.queueMutex.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.queueMutex.Unlock()
+
+	for _, msg := range pending {
+		c.sendChan <- msg
+	}
+	return len(pending), nil
+}
+
+// DiscardPending drops all queued messages and returns how many were dropped
+func (c *Connection) DiscardPending() int {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	count := len(c.pending)
+	c.pending = nil
+	return count
+}
+
+// afterConnect restores the ignore list and flushes or announces queued
+// messages once a connection is up
+func (c *Connection) afterConnect() {
+	c.mutex.RLock()
+	ignored := c.ignored
+	c.mutex.RUnlock()
+	if ignored != nil {
+		c.Send(&common.Message{Type: common.TypeIgnore, Users: ignored})
+	}
+
+	count := c.PendingCount()
+	if count == 0 {
+		return
+	}
+
+	if c.AutoFlush {
+		if sent, err := c.FlushPending(); err == nil {
+			c.notify("Sent %d queued message(s)", sent)
+		}
+		return
+	}
+	c.notify("%d message(s) were queued while offline: /flush to send them or /discard to drop them", count)
+}
@@ -0,0 +1,111 @@
+package chatclient
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// pendingPing is a ping waiting for its pong
+type pendingPing struct {
+	sent   time.Time
+	manual bool // Requested by the user, the result is reported
+}
+
+// pingTracker measures round trips of pings to the server
+type pingTracker struct {
+	pending map[string]pendingPing // Ping ID -> ping
+	rtt     time.Duration          // Last measured round trip
+	mutex   sync.Mutex
+}
+
+// newPingTracker creates a tracker without pings in flight
+func newPingTracker() *pingTracker {
+	return &pingTracker{pending: make(map[string]pendingPing)}
+}
+
+// start registers a new ping and returns the message to send
+func (pt *pingTracker) start(manual bool) *common.Message {
+	msg := &common.Message{Type: common.TypePing, ID: common.NewMessageID(), Timestamp: time.Now()}
+
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	pt.pending[msg.ID] = pendingPing{sent: msg.Timestamp, manual: manual}
+	return msg
+}
+
+// finish records the pong answering ping id
+func (pt *pingTracker) finish(id string) (pendingPing, time.Duration, bool) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	ping, ok := pt.pending[id]
+	if !ok {
+		return pendingPing{}, 0, false
+	}
+	delete(pt.pending, id)
+	pt.rtt = time.Since(ping.sent)
+	return ping, pt.rtt, true
+}
+
+// overdue reports whether a ping has waited longer than timeout
+func (pt *pingTracker) overdue(timeout time.Duration) bool {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	for _, ping := range pt.pending {
+		if time.Since(ping.sent) > timeout {
+			return true
+		}
+	}
+	return false
+}
+
+// reset forgets pings sent over a previous connection
+func (pt *pingTracker) reset() {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	pt.pending = make(map[string]pendingPing)
+}
+
+// latency returns the last measured round trip
+func (pt *pingTracker) latency() (time.Duration, bool) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	return pt.rtt, pt.rtt > 0
+}
+
+// Ping asks the server for a pong and reports the round trip time as a
+// local notice once it arrives
+func (c *Connection) Ping() error {
+	return c.Send(c.pings.start(true))
+}
+
+// Latency returns the round trip time of the last answered ping
+func (c *Connection) Latency() (time.Duration, bool) {
+	return c.pings.latency()
+}
+
+// handlePong measures the round trip of the answered ping
+func (c *Connection) handlePong(msg *common.Message) {
+	ping, rtt, ok := c.pings.finish(msg.ID)
+	if ok && ping.manual {
+		c.notify("Pong from server: %v", rtt.Round(100*time.Microsecond))
+	}
+}
+
+// keepAlive pings the server from writePump and reports false once a ping
+// went unanswered for too long, meaning the connection is dead even if TCP
+// has not noticed yet
+func (c *Connection) keepAlive() bool {
+	if c.pings.overdue(common.PongTimeout) {
+		c.notify("Server did not answer pings for %v, reconnecting...", common.PongTimeout)
+		return false
+	}
+	if err := c.sendMessage(c.pings.start(false)); err != nil {
+		log.Printf("Ping failed: %v", err)
+		return false
+	}
+	return true
+}
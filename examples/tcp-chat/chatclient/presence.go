@@ -0,0 +1,104 @@
+package chatclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"tcp-chat/common"
+)
+
+// UserDirectory keeps the list of online users in sync with the server; it
+// starts from the full list sent on connect and applies presence events
+type UserDirectory struct {
+	users map[string]common.UserStatus
+	away  map[string]string // Reasons of away users
+	mutex sync.RWMutex
+}
+
+// NewUserDirectory creates an empty directory
+func NewUserDirectory() *UserDirectory {
+	return &UserDirectory{
+		users: make(map[string]common.UserStatus),
+		away:  make(map[string]string),
+	}
+}
+
+// Apply updates the directory from a user list or presence event and
+// reports whether the message was one of them
+func (ud *UserDirectory) Apply(msg *common.Message) bool {
+	ud.mutex.Lock()
+	defer ud.mutex.Unlock()
+
+	switch msg.Type {
+	case common.TypeUserList:
+		ud.users = make(map[string]common.UserStatus, len(msg.Users))
+		ud.away = make(map[string]string)
+		for _, entry := range msg.Users {
+			// Entries are "nickname:status" or "nickname:AWAY:reason"
+			nickname, rest, _ := strings.Cut(entry, ":")
+			status, away, _ := strings.Cut(rest, ":")
+			ud.users[nickname] = common.UserStatus(status)
+			ud.setAway(nickname, away)
+		}
+	case common.TypeUserJoined, common.TypeUserStatus:
+		ud.users[msg.Sender] = msg.Status
+		ud.setAway(msg.Sender, msg.Content)
+	case common.TypeUserLeft:
+		delete(ud.users, msg.Sender)
+		delete(ud.away, msg.Sender)
+	default:
+		return false
+	}
+	return true
+}
+
+// setAway records or clears the away reason of a user
+func (ud *UserDirectory) setAway(nickname, away string) {
+	if away == "" {
+		delete(ud.away, nickname)
+	} else {
+		ud.away[nickname] = away
+	}
+}
+
+// AwayMessage returns the reason an away user gave, if any
+func (ud *UserDirectory) AwayMessage(nickname string) string {
+	ud.mutex.RLock()
+	defer ud.mutex.RUnlock()
+	return ud.away[nickname]
+}
+
+// Status returns the status of an online user
+func (ud *UserDirectory) Status(nickname string) (common.UserStatus, bool) {
+	ud.mutex.RLock()
+	defer ud.mutex.RUnlock()
+	status, ok := ud.users[nickname]
+	return status, ok
+}
+
+// List returns the online users as sorted "nickname:status" entries, away
+// users with a reason as "nickname:AWAY:reason"
+func (ud *UserDirectory) List() []string {
+	ud.mutex.RLock()
+	defer ud.mutex.RUnlock()
+
+	list := make([]string, 0, len(ud.users))
+	for nickname, status := range ud.users {
+		entry := fmt.Sprintf("%s:%s", nickname, status)
+		if away := ud.away[nickname]; away != "" {
+			entry += ":" + away
+		}
+		list = append(list, entry)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Len returns the number of online users
+func (ud *UserDirectory) Len() int {
+	ud.mutex.RLock()
+	defer ud.mutex.RUnlock()
+	return len(ud.users)
+}
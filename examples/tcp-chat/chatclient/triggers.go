@@ -0,0 +1,183 @@
+package chatclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// TriggerAction represents what a trigger does when it matches
+type TriggerAction string
+
+const (
+	TriggerCommand   TriggerAction = "command"   // Run a client slash command
+	TriggerReply     TriggerAction = "reply"     // Reply in the same conversation
+	TriggerHighlight TriggerAction = "highlight" // Highlight the message
+)
+
+// TriggerCooldown is how long triggers ignore a sender after running a
+// command or a reply for it, so two clients replying to each other stop
+const TriggerCooldown = 10 * time.Second
+
+// TriggerConfig describes a trigger in the client configuration file.
+// Command and Reply may reference the variables $sender and $room, Reply also
+// regexp groups ($1, ${name}). Commands can't use groups, they would let the
+// sender of a message choose the command.
+type TriggerConfig struct {
+	Pattern string        `json:"pattern"`
+	Action  TriggerAction `json:"action"`
+	Command string        `json:"command,omitempty"`
+	Reply   string        `json:"reply,omitempty"`
+}
+
+// Trigger is a compiled trigger
+type Trigger struct {
+	TriggerConfig
+	regex *regexp.Regexp
+}
+
+// TriggerResult collects the effects of all triggers matching a message
+type TriggerResult struct {
+	Highlight bool
+	Commands  []string
+	Replies   []string
+}
+
+// HookFunc is a callback invoked for every incoming message
+type HookFunc func(msg *common.Message)
+
+// TriggerEngine matches incoming messages against triggers and runs hooks
+type TriggerEngine struct {
+	triggers []*Trigger
+	hooks    []HookFunc
+	mutex    sync.RWMutex
+
+	// Last command or reply run for every sender, see TriggerCooldown
+	fired      map[string]time.Time
+	firedMutex sync.Mutex
+	now        func() time.Time
+}
+
+// NewTriggerEngine compiles the configured triggers
+func NewTriggerEngine(configs []TriggerConfig) (*TriggerEngine, error) {
+	te := &TriggerEngine{fired: make(map[string]time.Time), now: time.Now}
+	for _, config := range configs {
+		if err := te.AddTrigger(config); err != nil {
+			return nil, err
+		}
+	}
+	return te, nil
+}
+
+// AddTrigger compiles and registers a trigger
+func (te *TriggerEngine) AddTrigger(config TriggerConfig) error {
+	regex, err := regexp.Compile(config.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid trigger pattern %q: %v", config.Pattern, err)
+	}
+
+	switch config.Action {
+	case TriggerCommand:
+		if !strings.HasPrefix(config.Command, "/") {
+			return fmt.Errorf("trigger %q: command must start with '/'", config.Pattern)
+		}
+		if strings.Contains(variables.Replace(config.Command), "$") {
+			return fmt.Errorf("trigger %q: command cannot use regexp groups", config.Pattern)
+		}
+	case TriggerReply:
+		if config.Reply == "" {
+			return fmt.Errorf("trigger %q: reply cannot be empty", config.Pattern)
+		}
+	case TriggerHighlight:
+	default:
+		return fmt.Errorf("trigger %q: unknown action %q", config.Pattern, config.Action)
+	}
+
+	te.mutex.Lock()
+	defer te.mutex.Unlock()
+	te.triggers = append(te.triggers, &Trigger{TriggerConfig: config, regex: regex})
+	return nil
+}
+
+// RegisterHook registers a callback invoked for every incoming message
+func (te *TriggerEngine) RegisterHook(hook HookFunc) {
+	te.mutex.Lock()
+	defer te.mutex.Unlock()
+	te.hooks = append(te.hooks, hook)
+}
+
+// Process runs hooks and evaluates triggers against a message. Messages
+// sent by the user itself and automatic replies never fire triggers, and
+// commands and replies run for a sender at most once per TriggerCooldown.
+func (te *TriggerEngine) Process(msg *common.Message, self string) TriggerResult {
+	te.mutex.RLock()
+	hooks := te.hooks
+	triggers := te.triggers
+	te.mutex.RUnlock()
+
+	for _, hook := range hooks {
+		hook(msg)
+	}
+
+	var result TriggerResult
+	if msg.Type != common.TypeText || msg.Sender == self || msg.Metadata[common.MetaAutoReply] == "true" {
+		return result
+	}
+
+	for _, trigger := range triggers {
+		match := trigger.regex.FindStringSubmatchIndex(msg.Content)
+		if match == nil {
+			continue
+		}
+
+		switch trigger.Action {
+		case TriggerHighlight:
+			result.Highlight = true
+		case TriggerCommand:
+			result.Commands = append(result.Commands, expandVariables(trigger.Command, msg))
+		case TriggerReply:
+			result.Replies = append(result.Replies, trigger.expand(trigger.Reply, msg, match))
+		}
+	}
+	if (len(result.Commands) > 0 || len(result.Replies) > 0) && !te.allow(msg.Sender) {
+		result.Commands, result.Replies = nil, nil
+	}
+	return result
+}
+
+// allow reports whether commands and replies may run for a sender, and if so
+// starts its cooldown
+func (te *TriggerEngine) allow(sender string) bool {
+	te.firedMutex.Lock()
+	defer te.firedMutex.Unlock()
+	now := te.now()
+	if last, ok := te.fired[sender]; ok && now.Sub(last) < TriggerCooldown {
+		return false
+	}
+	// Forget the senders whose cooldown ended, the map stays as small as the
+	// number of recent senders
+	for other, last := range te.fired {
+		if now.Sub(last) >= TriggerCooldown {
+			delete(te.fired, other)
+		}
+	}
+	te.fired[sender] = now
+	return true
+}
+
+// variables matches the message variables of templates
+var variables = strings.NewReplacer("$sender", "", "$room", "")
+
+// expandVariables substitutes the message variables into a template
+func expandVariables(template string, msg *common.Message) string {
+	return strings.NewReplacer("$sender", msg.Sender, "$room", msg.Room).Replace(template)
+}
+
+// expand substitutes message variables and regexp groups into a template
+func (t *Trigger) expand(template string, msg *common.Message, match []int) string {
+	return string(t.regex.ExpandString(nil, expandVariables(template, msg), msg.Content, match))
+}
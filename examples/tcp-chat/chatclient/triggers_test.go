@@ -0,0 +1,63 @@
+package chatclient
+
+import (
+	"testing"
+	"time"
+
+	"tcp-chat/common"
+)
+
+func TestTriggerCommandsCannotUseGroups(t *testing.T) {
+	tests := []struct {
+		command string
+		valid   bool
+	}{
+		{"/msg $sender pong", true},
+		{"/join $room", true},
+		{"/msg $1 pong", false},
+		{"/msg ${name} pong", false},
+	}
+	for _, test := range tests {
+		_, err := NewTriggerEngine([]TriggerConfig{{Pattern: "(?P<name>ping)", Action: TriggerCommand, Command: test.command}})
+		if (err == nil) != test.valid {
+			t.Errorf("Command %q: error %v, valid %v", test.command, err, test.valid)
+		}
+	}
+}
+
+func TestTriggerRepliesStopLoops(t *testing.T) {
+	te, err := NewTriggerEngine([]TriggerConfig{
+		{Pattern: `ping (\w+)`, Action: TriggerReply, Reply: "pong $1 to $sender"},
+		{Pattern: "ping", Action: TriggerHighlight},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	te.now = func() time.Time { return now }
+
+	msg := common.NewTextMessage("alice", "bob", "ping you")
+	if result := te.Process(msg, "bob"); len(result.Replies) != 1 || result.Replies[0] != "pong you to alice" || !result.Highlight {
+		t.Fatalf("Process = %+v", result)
+	}
+
+	// Within the cooldown the sender is highlighted but not answered
+	if result := te.Process(msg, "bob"); len(result.Replies) != 0 || !result.Highlight {
+		t.Errorf("Process during the cooldown = %+v", result)
+	}
+	other := common.NewTextMessage("carol", "bob", "ping you")
+	if result := te.Process(other, "bob"); len(result.Replies) != 1 {
+		t.Errorf("Expected other senders to be answered, got %+v", result)
+	}
+	now = now.Add(TriggerCooldown)
+	if result := te.Process(msg, "bob"); len(result.Replies) != 1 {
+		t.Errorf("Expected an answer after the cooldown, got %+v", result)
+	}
+
+	// Automatic replies never fire triggers, or two clients answer each other forever
+	now = now.Add(TriggerCooldown)
+	msg.Metadata = map[string]string{common.MetaAutoReply: "true"}
+	if result := te.Process(msg, "bob"); len(result.Replies) != 0 || result.Highlight {
+		t.Errorf("Process of an automatic reply = %+v", result)
+	}
+}
@@ -0,0 +1,100 @@
+// Package chatgrpc defines the Chat gRPC service, a bidirectional stream
+// carrying the same messages as the newline-delimited JSON protocol on the
+// TCP port. The first message must be CONNECT with the nickname in content,
+// after that both sides stream messages freely.
+//
+// The service has no protobuf schema. Messages are JSON encoded
+// common.Message values sent with the "json" content subtype
+// (application/grpc+json), so the service shares the protocol types with the
+// TCP server. Clients generated from a .proto file can't talk to it; clients
+// need a gRPC stack with a JSON codec, such as this package's NewStream.
+package chatgrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+
+	"tcp-chat/common"
+)
+
+// ChatMethod is the full name of the bidirectional Chat RPC
+const ChatMethod = "/chat.Chat/Chat"
+
+// Codec encodes gRPC messages as JSON
+type Codec struct{}
+
+// Marshal encodes v as JSON
+func (Codec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (Codec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name returns the content subtype of the codec
+func (Codec) Name() string {
+	return "json"
+}
+
+// ChatServer is implemented by servers of the Chat service
+type ChatServer interface {
+	// Chat serves one chat session until the stream ends
+	Chat(stream grpc.ServerStream) error
+}
+
+// ServiceDesc describes the Chat service for grpc.Server.RegisterService
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.Chat",
+	HandlerType: (*ChatServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chat",
+			Handler:       chatHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+// chatHandler dispatches a Chat stream to the registered ChatServer
+func chatHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(ChatServer).Chat(stream)
+}
+
+// RegisterChatServer registers srv on a gRPC server. The server must be
+// created with grpc.ForceServerCodec(Codec{}).
+func RegisterChatServer(s grpc.ServiceRegistrar, srv ChatServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
+
+// Stream is the client side of a Chat session
+type Stream struct {
+	grpc.ClientStream
+}
+
+// NewStream opens a Chat session on cc
+func NewStream(ctx context.Context, cc grpc.ClientConnInterface) (*Stream, error) {
+	stream, err := cc.NewStream(ctx, &ServiceDesc.Streams[0], ChatMethod, grpc.ForceCodec(Codec{}))
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{stream}, nil
+}
+
+// Send sends a message to the server
+func (s *Stream) Send(msg *common.Message) error {
+	return s.SendMsg(msg)
+}
+
+// Recv waits for the next message from the server
+func (s *Stream) Recv() (*common.Message, error) {
+	msg := &common.Message{}
+	if err := s.RecvMsg(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
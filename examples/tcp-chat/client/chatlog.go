@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// MaxHistoryPerConversation limits the in-memory history kept for export
+const MaxHistoryPerConversation = 1000
+
+// BroadcastConversation is the conversation key used for broadcast messages
+const BroadcastConversation = "broadcast"
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+// ChatEntry represents a single recorded chat line
+type ChatEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Conversation string    `json:"conversation"`
+	Sender       string    `json:"sender"`
+	Content      string    `json:"content"`
+}
+
+// ChatLog records conversations per room or peer
+type ChatLog struct {
+	dir     string             // Empty disables writing dated log files
+	cipher  *common.FileCipher // Encrypts every written line when set
+	history map[string][]ChatEntry
+	files   map[string]*os.File // "conversation/date" -> open log file
+	mutex   sync.Mutex
+}
+
+// NewChatLog creates a chat log; pass an empty dir to keep history in memory only
+func NewChatLog(dir string) *ChatLog {
+	return &ChatLog{
+		dir:     dir,
+		history: make(map[string][]ChatEntry),
+		files:   make(map[string]*os.File),
+	}
+}
+
+// NewEncryptedChatLog creates a chat log whose files are encrypted line by
+// line with cipher; read them back with DecryptLog
+func NewEncryptedChatLog(dir string, cipher *common.FileCipher) *ChatLog {
+	cl := NewChatLog(dir)
+	cl.cipher = cipher
+	return cl
+}
+
+// ConversationKey returns the conversation a message belongs to
+func ConversationKey(msg *common.Message, self string) string {
+	switch {
+	case msg.Room != "":
+		return msg.Room
+	case msg.Recipient == "*" || msg.Recipient == "":
+		return BroadcastConversation
+	case msg.Sender == self:
+		return msg.Recipient
+	default:
+		return msg.Sender
+	}
+}
+
+// Record stores a message in the history and the dated log file if enabled
+func (cl *ChatLog) Record(conversation, sender, content string, timestamp time.Time) {
+	entry := ChatEntry{
+		Timestamp:    timestamp,
+		Conversation: conversation,
+		Sender:       sender,
+		Content:      content,
+	}
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	entries := append(cl.history[conversation], entry)
+	if len(entries) > MaxHistoryPerConversation {
+		entries = entries[len(entries)-MaxHistoryPerConversation:]
+	}
+	cl.history[conversation] = entries
+
+	if cl.dir == "" {
+		return
+	}
+
+	file, err := cl.fileFor(conversation, timestamp)
+	if err != nil {
+		log.Printf("Failed to open chat log for %s: %v", conversation, err)
+		return
+	}
+	line := fmt.Sprintf("[%s] %s: %s", timestamp.Format("15:04:05"), sender, content)
+	if cl.cipher != nil {
+		if line, err = cl.cipher.SealLine(line); err != nil {
+			log.Printf("Failed to encrypt chat log line for %s: %v", conversation, err)
+			return
+		}
+	}
+	fmt.Fprintln(file, line)
+}
+
+// DecryptLog writes the decrypted lines of an encrypted chat log file to w,
+// stopping at the first line that isn't encrypted or fails to decrypt
+func DecryptLog(path string, cipher *common.FileCipher, w io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), common.MaxScannerBuffer)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, err := cipher.OpenLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		fmt.Fprintln(w, line)
+	}
+	return scanner.Err()
+}
+
+// fileFor returns the log file for a conversation and day, rotating daily
+func (cl *ChatLog) fileFor(conversation string, timestamp time.Time) (*os.File, error) {
+	date := timestamp.Format(time.DateOnly)
+	segments := strings.Split(conversation, "/")
+	for i, segment := range segments {
+		segments[i] = sanitizeFileName(segment)
+	}
+	name := strings.Join(segments, "/")
+	key := name + "/" + date
+	if file, ok := cl.files[key]; ok {
+		return file, nil
+	}
+
+	// Close files from previous days for this conversation
+	for k, file := range cl.files {
+		if strings.HasPrefix(k, name+"/") {
+			file.Close()
+			delete(cl.files, k)
+		}
+	}
+
+	dir := filepath.Join(append([]string{cl.dir}, segments...)...)
+	if err := os.MkdirAll(dir, common.GetDirMode()); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, date+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, common.GetFileMode())
+	if err != nil {
+		return nil, err
+	}
+	cl.files[key] = file
+	return file, nil
+}
+
+// History returns a copy of the recorded history of a conversation
+func (cl *ChatLog) History(conversation string) []ChatEntry {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	return append([]ChatEntry(nil), cl.history[conversation]...)
+}
+
+// Export writes the history of a conversation to a file in the given format
+// (txt, json or html) and returns the path of the created file
+func (cl *ChatLog) Export(conversation, format, dir string) (string, error) {
+	entries := cl.History(conversation)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no history for %s", conversation)
+	}
+
+	var data []byte
+	format = strings.ToLower(format)
+	switch format {
+	case "", "txt", "text":
+		format = "txt"
+		var sb strings.Builder
+		for _, entry := range entries {
+			fmt.Fprintf(&sb, "[%s] %s: %s\n", entry.Timestamp.Format(time.DateTime), entry.Sender, entry.Content)
+		}
+		data = []byte(sb.String())
+	case "json":
+		var err error
+		if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+			return "", err
+		}
+	case "html":
+		var sb strings.Builder
+		sb.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>")
+		sb.WriteString(html.EscapeString(conversation))
+		sb.WriteString("</title></head>\n<body>\n<ul>\n")
+		for _, entry := range entries {
+			fmt.Fprintf(&sb, "<li><time>%s</time> <b>%s</b>: %s</li>\n",
+				entry.Timestamp.Format(time.DateTime),
+				html.EscapeString(entry.Sender),
+				html.EscapeString(entry.Content))
+		}
+		sb.WriteString("</ul>\n</body>\n</html>\n")
+		data = []byte(sb.String())
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	if err := os.MkdirAll(dir, common.GetDirMode()); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s", sanitizeFileName(conversation), time.Now().Format("20060102-150405"), format))
+	if err := os.WriteFile(path, data, common.GetFileMode()); err != nil {
+		return "", fmt.Errorf("failed to write export: %v", err)
+	}
+	return path, nil
+}
+
+// Close closes all open log files
+func (cl *ChatLog) Close() error {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	var firstErr error
+	for key, file := range cl.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(cl.files, key)
+	}
+	return firstErr
+}
+
+// sanitizeFileName makes a conversation key safe to use as a file name
+func sanitizeFileName(name string) string {
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"tcp-chat/chatclient"
+	"tcp-chat/common"
+)
+
+// DefaultConfigFile is the client configuration file used when none is given
+const DefaultConfigFile = "client.json"
+
+// ClientConfig holds user settings loaded from the client configuration file
+type ClientConfig struct {
+	Triggers []chatclient.TriggerConfig `json:"triggers,omitempty"`
+
+	// Ignore hides users entirely, Mute only in broadcasts and rooms.
+	// ServerIgnore also asks the server to drop traffic from ignored users.
+	Ignore       []string `json:"ignore,omitempty"`
+	Mute         []string `json:"mute,omitempty"`
+	ServerIgnore bool     `json:"server_ignore,omitempty"`
+
+	// RoomNotify maps room IDs to notification levels, rooms without an
+	// entry notify on mentions
+	RoomNotify map[string]NotifyLevel `json:"room_notify,omitempty"`
+
+	// PlainText sends messages as typed instead of as markdown
+	PlainText bool `json:"plain_text,omitempty"`
+
+	// DownloadDir is where received files are saved, DownloadPerSender and
+	// SortDownloads add subfolders per sender and by kind. DownloadCollision
+	// is rename, overwrite or ask. Offers are declined once DownloadDir
+	// would exceed DownloadQuota bytes, zero means no limit.
+	DownloadDir       string `json:"download_dir,omitempty"`
+	DownloadPerSender bool   `json:"download_per_sender,omitempty"`
+	SortDownloads     bool   `json:"sort_downloads,omitempty"`
+	DownloadCollision string `json:"download_collision,omitempty"`
+	DownloadQuota     int64  `json:"download_quota,omitempty"`
+
+	// ImagePreview is auto, iterm2, sixel, text or off
+	ImagePreview   ImageProtocol `json:"image_preview,omitempty"`
+	PreviewMaxSize int64         `json:"preview_max_size,omitempty"`
+
+	path string
+}
+
+// LoadConfig reads the configuration file; a missing file yields defaults
+func LoadConfig(path string) (*ClientConfig, error) {
+	config := &ClientConfig{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Save writes the configuration back to the file it was loaded from
+func (c *ClientConfig) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, common.GetFileMode())
+}
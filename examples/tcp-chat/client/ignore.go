@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"tcp-chat/common"
+)
+
+// IgnoreList holds ignored and muted nicknames, persisted in the client config.
+// Ignored users are hidden entirely; muted users are hidden in broadcasts and
+// rooms but can still send private messages and files.
+type IgnoreList struct {
+	config *ClientConfig
+	mutex  sync.RWMutex
+}
+
+// NewIgnoreList creates an ignore list backed by the client configuration
+func NewIgnoreList(config *ClientConfig) *IgnoreList {
+	return &IgnoreList{config: config}
+}
+
+// ServerEnforced reports whether ignored users are also blocked by the server
+func (l *IgnoreList) ServerEnforced() bool {
+	return l.config.ServerIgnore
+}
+
+// Ignore adds a nickname to the ignore list
+func (l *IgnoreList) Ignore(nickname string) error {
+	return l.update(&l.config.Ignore, nickname, true)
+}
+
+// Unignore removes a nickname from the ignore list
+func (l *IgnoreList) Unignore(nickname string) error {
+	return l.update(&l.config.Ignore, nickname, false)
+}
+
+// Mute adds a nickname to the mute list
+func (l *IgnoreList) Mute(nickname string) error {
+	return l.update(&l.config.Mute, nickname, true)
+}
+
+// Unmute removes a nickname from the mute list
+func (l *IgnoreList) Unmute(nickname string) error {
+	return l.update(&l.config.Mute, nickname, false)
+}
+
+// update adds or removes a nickname and saves the configuration
+func (l *IgnoreList) update(list *[]string, nickname string, add bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	index := slices.Index(*list, nickname)
+	switch {
+	case add && index >= 0:
+		return fmt.Errorf("%s is already on the list", nickname)
+	case add:
+		*list = append(*list, nickname)
+	case index < 0:
+		return fmt.Errorf("%s is not on the list", nickname)
+	default:
+		*list = slices.Delete(*list, index, index+1)
+	}
+
+	if err := l.config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// Ignored returns the ignored nicknames
+func (l *IgnoreList) Ignored() []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return slices.Clone(l.config.Ignore)
+}
+
+// Muted returns the muted nicknames
+func (l *IgnoreList) Muted() []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return slices.Clone(l.config.Mute)
+}
+
+// Suppress reports whether a message should be hidden from the user
+func (l *IgnoreList) Suppress(msg *common.Message) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	switch msg.Type {
+	case common.TypeText:
+		if slices.Contains(l.config.Ignore, msg.Sender) {
+			return true
+		}
+		public := msg.Room != "" || msg.Recipient == "" || msg.Recipient == "*"
+		return public && slices.Contains(l.config.Mute, msg.Sender)
+	case common.TypeInvite, common.TypeFile:
+		return slices.Contains(l.config.Ignore, msg.Sender)
+	}
+	return false
+}
@@ -7,33 +7,96 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"tcp-chat/chatclient"
 	"tcp-chat/common"
-	"time"
 )
 
-var logFile *os.File
+var logFile *common.RotatingFile
 
 func main() {
 	// Parse command line arguments
 	serverAddr := flag.String("server", "localhost:8080", "Server address")
 	nickname := flag.String("nick", "", "Your nickname")
+	chatLogDir := flag.String("chatlog", "", "Directory for per-conversation chat logs (disabled if empty)")
+	configFile := flag.String("config", DefaultConfigFile, "Client configuration file")
+	lang := flag.String("lang", common.DefaultLanguage, "Language of client messages (en, pl)")
+	logKeyFile := flag.String("chatlog-passphrase-file", "", "File holding the passphrase encrypting chat logs, defaults to $CHAT_LOG_PASSPHRASE (unencrypted if neither is set)")
+	readLog := flag.String("read-log", "", "Print a chat log file, decrypting encrypted lines, and exit")
+	images := flag.String("images", "", "Inline image previews: auto, iterm2, sixel, text or off (overrides config)")
 	flag.Parse()
 
+	if err := common.SetLanguage(*lang); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	passphrase, err := common.LoadPassphrase(*logKeyFile, "CHAT_LOG_PASSPHRASE")
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		os.Exit(1)
+	}
+	var logCipher *common.FileCipher
+	if passphrase != "" {
+		if logCipher, err = common.NewFileCipher(passphrase); err != nil {
+			fmt.Print(common.T("Error: %v\n", err))
+			os.Exit(1)
+		}
+	}
+
+	if *readLog != "" {
+		if logCipher == nil {
+			fmt.Println(common.T("Error: reading a chat log needs its passphrase"))
+			os.Exit(1)
+		}
+		if err := DecryptLog(*readLog, logCipher, os.Stdout); err != nil {
+			fmt.Print(common.T("Error: %v\n", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Validate nickname
 	if *nickname == "" {
-		fmt.Println("Error: Nickname is required")
-		fmt.Println("Usage: ./client -nick <your_nickname> [-server <address>]")
+		fmt.Println(common.T("Error: Nickname is required"))
+		fmt.Println(common.T("Usage: ./client -nick <your_nickname> [-server <address>]"))
+		os.Exit(1)
+	}
+
+	// Load configuration
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		os.Exit(1)
+	}
+
+	if _, err := chatclient.ParseCollisionPolicy(config.DownloadCollision); err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		os.Exit(1)
+	}
+
+	triggers, err := chatclient.NewTriggerEngine(config.Triggers)
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
 		os.Exit(1)
 	}
 
-	// Create connection
-	conn := NewConnection(*nickname)
+	if *images != "" {
+		config.ImagePreview = ImageProtocol(*images)
+	}
+	previewer, err := NewImagePreviewer(config.ImagePreview, config.PreviewMaxSize)
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		os.Exit(1)
+	}
 
-	// Create file transfer manager
-	ft := NewFileTransfer(conn)
+	// Create chat log
+	chatLog := NewChatLog(*chatLogDir)
+	if logCipher != nil {
+		chatLog = NewEncryptedChatLog(*chatLogDir, logCipher)
+	}
 
 	// Create UI
-	ui := NewUI(conn, ft)
+	ui := NewUI(chatLog, triggers, previewer, NewIgnoreList(config), NewRoomNotifications(config), config)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -41,38 +104,29 @@ func main() {
 
 	go func() {
 		<-sigChan
-		fmt.Println("\nShutting down...")
-
-		// Send disconnect message if connected
-		if conn.IsConnected() {
-			disconnectMsg := &common.Message{
-				Type:    common.TypeDisconnect,
-				Sender:  *nickname,
-				Content: "Client shutting down",
-			}
-			conn.sendChan <- disconnectMsg
-
-			// Give message time to send
-			time.Sleep(100 * time.Millisecond)
-		}
+		fmt.Println(common.T("\nShutting down..."))
 
-		conn.Disconnect()
+		ui.Shutdown()
 
 		// Close log file
 		if logFile != nil {
 			logFile.Close()
 		}
 
-		fmt.Println("Goodbye!")
+		fmt.Println(common.T("Goodbye!"))
 		os.Exit(0)
 	}()
 
 	// Connect to server with retry
-	go conn.ConnectWithRetry(*serverAddr)
+	session, err := ui.Connect(*serverAddr, *nickname)
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		os.Exit(1)
+	}
 
 	// Wait for connection
-	fmt.Printf("Connecting to %s...\n", *serverAddr)
-	conn.WaitForConnection()
+	fmt.Print(common.T("Connecting to %s...\n", *serverAddr))
+	session.conn.WaitForConnection()
 
 	// Start UI
 	ui.Start()
@@ -82,7 +136,7 @@ func main() {
 func init() {
 	// Set up logging to file
 	var err error
-	logFile, err = os.OpenFile("client.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, common.GetFileMode())
+	logFile, err = common.NewRotatingFile("client.log", common.DefaultLogMaxSize, 0, common.DefaultLogMaxBackups)
 	if err == nil {
 		log.SetOutput(logFile)
 	} else {
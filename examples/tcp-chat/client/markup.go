@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"tcp-chat/common"
+)
+
+// ANSI styles of formatted text
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiItalic    = "\033[3m"
+	ansiUnderline = "\033[4m"
+	ansiCode      = "\033[36m"   // Cyan
+	ansiHighlight = "\033[1;33m" // Bold yellow
+)
+
+// renderMarkup styles markdown content for the terminal; base is the style
+// of the surrounding text and is restored after every styled span
+func renderMarkup(content, base string) string {
+	var out strings.Builder
+	out.WriteString(base)
+	for _, span := range common.ParseMarkup(content) {
+		var style string
+		if span.Bold {
+			style += ansiBold
+		}
+		if span.Italic {
+			style += ansiItalic
+		}
+		if span.Code {
+			style += ansiCode
+		}
+		if span.URL != "" {
+			style += ansiUnderline
+		}
+
+		if style == "" {
+			out.WriteString(span.Text)
+		} else {
+			out.WriteString(style + span.Text + ansiReset + base)
+		}
+		if span.URL != "" && span.URL != span.Text {
+			out.WriteString(" <" + span.URL + ">")
+		}
+	}
+	if base != "" {
+		out.WriteString(ansiReset)
+	}
+	return out.String()
+}
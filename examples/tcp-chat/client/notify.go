@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// NotifyLevel controls how messages in a room draw attention
+type NotifyLevel string
+
+const (
+	NotifyAll      NotifyLevel = "all"      // Ring the bell for every message, highlight mentions
+	NotifyMentions NotifyLevel = "mentions" // Highlight and ring only when mentioned, the default
+	NotifyNone     NotifyLevel = "none"     // Never highlight or ring
+)
+
+// ParseNotifyLevel parses a level given on the command line
+func ParseNotifyLevel(text string) (NotifyLevel, error) {
+	switch level := NotifyLevel(strings.ToLower(text)); level {
+	case NotifyAll, NotifyMentions, NotifyNone:
+		return level, nil
+	default:
+		return "", fmt.Errorf("invalid notification level %q, use all, mentions or none", text)
+	}
+}
+
+// RoomNotifications holds per-room notification levels, persisted in the
+// client config
+type RoomNotifications struct {
+	config *ClientConfig
+	mutex  sync.RWMutex
+}
+
+// NewRoomNotifications creates notification settings backed by the client configuration
+func NewRoomNotifications(config *ClientConfig) *RoomNotifications {
+	return &RoomNotifications{config: config}
+}
+
+// Level returns the notification level of a room
+func (n *RoomNotifications) Level(roomID string) NotifyLevel {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if level, ok := n.config.RoomNotify[roomID]; ok {
+		return level
+	}
+	return NotifyMentions
+}
+
+// SetLevel changes the notification level of a room and saves the configuration
+func (n *RoomNotifications) SetLevel(roomID string, level NotifyLevel) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if level == NotifyMentions {
+		// The default needs no entry
+		delete(n.config.RoomNotify, roomID)
+	} else {
+		if n.config.RoomNotify == nil {
+			n.config.RoomNotify = make(map[string]NotifyLevel)
+		}
+		n.config.RoomNotify[roomID] = level
+	}
+
+	if err := n.config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// Alert decides whether a room message is highlighted and rings the bell;
+// triggered tells whether a highlight trigger matched it
+func (n *RoomNotifications) Alert(roomID, sender, content, nickname string, triggered bool) (highlight, bell bool) {
+	if sender == nickname {
+		return triggered, false
+	}
+
+	mentioned := Mentions(content, nickname)
+	switch n.Level(roomID) {
+	case NotifyNone:
+		return false, false
+	case NotifyAll:
+		return triggered || mentioned, true
+	default:
+		return triggered || mentioned, mentioned
+	}
+}
+
+// Mentions reports whether content names nickname as a whole word,
+// with or without a leading @
+func Mentions(content, nickname string) bool {
+	if nickname == "" {
+		return false
+	}
+	pattern := `(?i)(^|[^\w-])@?` + regexp.QuoteMeta(nickname) + `($|[^\w-])`
+	matched, _ := regexp.MatchString(pattern, content)
+	return matched
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tcp-chat/chatclient"
+	"tcp-chat/common"
+)
+
+// ImageProtocol selects how images are drawn in the terminal
+type ImageProtocol string
+
+const (
+	ImageAuto   ImageProtocol = "auto"
+	ImageITerm2 ImageProtocol = "iterm2"
+	ImageSixel  ImageProtocol = "sixel"
+	ImageText   ImageProtocol = "text" // Placeholder line only
+	ImageOff    ImageProtocol = "off"
+)
+
+// Preview limits
+const (
+	DefaultPreviewMaxSize = 2 * 1024 * 1024 // 2MB
+	MaxSixelWidth         = 480
+	MaxSixelHeight        = 360
+)
+
+// ImagePreviewer renders received images inline when the terminal allows it
+type ImagePreviewer struct {
+	Protocol ImageProtocol
+	MaxSize  int64
+}
+
+// NewImagePreviewer creates a previewer; ImageAuto picks a protocol from the
+// terminal environment and falls back to a textual placeholder
+func NewImagePreviewer(protocol ImageProtocol, maxSize int64) (*ImagePreviewer, error) {
+	switch protocol {
+	case "", ImageAuto:
+		protocol = detectImageProtocol()
+	case ImageITerm2, ImageSixel, ImageText, ImageOff:
+	default:
+		return nil, fmt.Errorf("unknown image preview mode: %s", protocol)
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultPreviewMaxSize
+	}
+	return &ImagePreviewer{Protocol: protocol, MaxSize: maxSize}, nil
+}
+
+// detectImageProtocol guesses the inline image support of the terminal
+func detectImageProtocol() ImageProtocol {
+	switch {
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app",
+		os.Getenv("LC_TERMINAL") == "iTerm2",
+		os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return ImageITerm2
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case strings.Contains(term, "sixel"),
+		strings.HasPrefix(term, "mlterm"),
+		strings.HasPrefix(term, "foot"),
+		strings.HasPrefix(term, "yaft"):
+		return ImageSixel
+	}
+	return ImageText
+}
+
+// IsImage reports whether a file is a previewable image, judged by its
+// content type or, when the sender gave none, its extension
+func IsImage(filename, contentType string) bool {
+	if contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		switch mediaType {
+		case "image/png", "image/jpeg", "image/gif":
+			return true
+		}
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// Render writes a preview of a received file to w. Images are drawn, audio
+// gets a summary line and other files are ignored; images over MaxSize get
+// the textual placeholder only.
+func (p *ImagePreviewer) Render(w io.Writer, path, contentType string, metadata map[string]string) error {
+	if p.Protocol == ImageOff {
+		return nil
+	}
+	if contentType != "" && common.FileKind(contentType) == common.KindAudio {
+		_, err := fmt.Fprintf(w, "[%s]\n", describeAudio(filepath.Base(path), metadata))
+		return err
+	}
+	if !IsImage(path, contentType) {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read image: %v", err)
+	}
+	placeholder := fmt.Sprintf("[image: %s, %dx%d %s, %s]", filepath.Base(path),
+		config.Width, config.Height, strings.ToUpper(format), chatclient.FormatFileSize(int64(len(data))))
+
+	if int64(len(data)) > p.MaxSize {
+		_, err = fmt.Fprintln(w, placeholder)
+		return err
+	}
+
+	switch p.Protocol {
+	case ImageITerm2:
+		_, err = fmt.Fprintf(w, "\033]1337;File=name=%s;size=%d;inline=1;preserveAspectRatio=1:%s\a\n",
+			base64.StdEncoding.EncodeToString([]byte(filepath.Base(path))), len(data),
+			base64.StdEncoding.EncodeToString(data))
+	case ImageSixel:
+		img, _, decodeErr := image.Decode(bytes.NewReader(data))
+		if decodeErr != nil {
+			return fmt.Errorf("failed to decode image: %v", decodeErr)
+		}
+		err = writeSixel(w, img)
+	default:
+		_, err = fmt.Fprintln(w, placeholder)
+	}
+	return err
+}
+
+// describeAudio names an audio file, calling voice memos such and adding
+// the playing time when the sender gave it
+func describeAudio(name string, metadata map[string]string) string {
+	label := "audio"
+	if metadata[common.MetaVoiceMemo] == "true" {
+		label = "voice memo"
+	}
+	if duration := metadata[common.MetaDuration]; duration != "" {
+		return fmt.Sprintf("%s: %s, %s", label, name, duration)
+	}
+	return fmt.Sprintf("%s: %s", label, name)
+}
+
+// describeFile summarizes an announced file: size, content type and the
+// metadata worth showing
+func describeFile(msg *common.Message) string {
+	details := []string{chatclient.FormatFileSize(msg.Filesize)}
+	if msg.ContentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(msg.ContentType)
+		details = append(details, mediaType)
+	}
+	if msg.Metadata[common.MetaVoiceMemo] == "true" {
+		details = append(details, "voice memo")
+	}
+	if duration := msg.Metadata[common.MetaDuration]; duration != "" {
+		details = append(details, duration)
+	}
+	if caption := msg.Metadata[common.MetaCaption]; caption != "" {
+		details = append(details, fmt.Sprintf("%q", caption))
+	}
+	return strings.Join(details, ", ")
+}
+
+// writeSixel encodes an image as DEC sixel graphics, scaled down to fit
+func writeSixel(w io.Writer, img image.Image) error {
+	img = scaleToFit(img, MaxSixelWidth, MaxSixelHeight)
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Reduce to a 216 color web-safe palette, sixel needs indexed colors
+	paletted := image.NewPaletted(image.Rect(0, 0, width, height), palette.WebSafe)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "\033Pq\"1;1;%d;%d", width, height)
+	for i, c := range paletted.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	row := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		// Collect the colors used in this band of six pixel rows
+		used := make(map[uint8]bool)
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+
+		first := true
+		for index := range paletted.Palette {
+			if !used[uint8(index)] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for bit := 0; bit < 6 && top+bit < height; bit++ {
+					if paletted.ColorIndexAt(x, top+bit) == uint8(index) {
+						bits |= 1 << bit
+					}
+				}
+				row[x] = '?' + bits
+			}
+			if !first {
+				out.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&out, "#%d", index)
+			writeSixelRow(&out, row)
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\033\\\n")
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// writeSixelRow writes sixel characters with run-length compression
+func writeSixelRow(out *bytes.Buffer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if count := j - i; count > 3 {
+			fmt.Fprintf(out, "!%d%c", count, row[i])
+		} else {
+			out.Write(row[i:j])
+		}
+		i = j
+	}
+}
+
+// scaleToFit shrinks an image with nearest-neighbour sampling so it fits
+// within the given size; smaller images are returned unchanged
+func scaleToFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	newWidth := max(1, int(float64(width)*scale))
+	newHeight := max(1, int(float64(height)*scale))
+
+	scaled := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		for x := 0; x < newWidth; x++ {
+			srcX := bounds.Min.X + x*width/newWidth
+			srcY := bounds.Min.Y + y*height/newHeight
+			scaled.Set(x, y, color.RGBAModel.Convert(img.At(srcX, srcY)))
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync"
+
+	"tcp-chat/chatclient"
+	"tcp-chat/common"
+)
+
+// Session holds the state of a single server connection
+type Session struct {
+	Name         string
+	conn         *chatclient.Connection
+	fileTransfer *chatclient.FileTransfer
+	messages     <-chan *common.Message
+	rooms        map[string]string // roomID -> roomName
+	mutex        sync.RWMutex
+}
+
+// NewSession creates a session for a server address and nickname
+func NewSession(address, nickname string) *Session {
+	conn := chatclient.NewConnection(nickname)
+	return &Session{
+		Name:         address,
+		conn:         conn,
+		fileTransfer: chatclient.NewFileTransfer(conn),
+		messages:     conn.Subscribe(),
+		rooms:        make(map[string]string),
+	}
+}
+
+// ConversationKey returns the chat log key of a message, namespaced by session
+func (s *Session) ConversationKey(msg *common.Message) string {
+	return s.Name + "/" + ConversationKey(msg, s.conn.Nickname())
+}
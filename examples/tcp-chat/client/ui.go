@@ -2,31 +2,43 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"tcp-chat/chatclient"
 	"tcp-chat/common"
 )
 
 // UI handles terminal user interface
 type UI struct {
-	conn         *Connection
-	fileTransfer *FileTransfer
-	rooms        map[string]string // roomID -> roomName
-	users        []string
-	running      bool
-	mutex        sync.RWMutex
+	sessions []*Session
+	active   *Session
+	chatLog  *ChatLog
+	triggers *chatclient.TriggerEngine
+	preview  *ImagePreviewer
+	ignores  *IgnoreList
+	notify   *RoomNotifications
+	config   *ClientConfig
+	running  bool
+	mutex    sync.RWMutex
 }
 
 // NewUI creates a new UI instance
-func NewUI(conn *Connection, ft *FileTransfer) *UI {
+func NewUI(chatLog *ChatLog, triggers *chatclient.TriggerEngine, preview *ImagePreviewer, ignores *IgnoreList, notify *RoomNotifications, config *ClientConfig) *UI {
 	return &UI{
-		conn:         conn,
-		fileTransfer: ft,
-		rooms:        make(map[string]string),
-		running:      true,
+		chatLog:  chatLog,
+		triggers: triggers,
+		preview:  preview,
+		ignores:  ignores,
+		notify:   notify,
+		config:   config,
+		running:  true,
 	}
 }
 
@@ -36,38 +48,197 @@ func (ui *UI) Start() {
 	clearScreen()
 	ui.showWelcome()
 
-	// Start message receiver
-	go ui.receiveMessages()
-
 	// Start input handler
 	ui.handleInput()
 }
 
+// Connect opens a new session to a server and makes it the active one
+func (ui *UI) Connect(address, nickname string) (*Session, error) {
+	ui.mutex.Lock()
+	for _, s := range ui.sessions {
+		if s.Name == address {
+			ui.mutex.Unlock()
+			return nil, fmt.Errorf("already connected to %s", address)
+		}
+	}
+	session := NewSession(address, nickname)
+	ui.sessions = append(ui.sessions, session)
+	ui.active = session
+	ui.mutex.Unlock()
+
+	if ui.ignores.ServerEnforced() {
+		session.conn.SetIgnoreList(ui.ignores.Ignored())
+	}
+	if !ui.config.PlainText {
+		session.conn.SetFormat(common.FormatMarkdown)
+	}
+	ui.configureDownloads(session.fileTransfer)
+
+	// Start message receiver
+	go ui.receiveMessages(session)
+
+	// Connect to server with retry
+	go session.conn.ConnectWithRetry(address)
+
+	return session, nil
+}
+
+// Disconnect closes a session and removes it from the UI
+func (ui *UI) Disconnect(session *Session) {
+	ui.mutex.Lock()
+	for i, s := range ui.sessions {
+		if s == session {
+			ui.sessions = append(ui.sessions[:i], ui.sessions[i+1:]...)
+			break
+		}
+	}
+	if ui.active == session {
+		ui.active = nil
+		if len(ui.sessions) > 0 {
+			ui.active = ui.sessions[0]
+		}
+	}
+	ui.mutex.Unlock()
+
+	sendDisconnect(session.conn)
+	session.conn.Disconnect()
+	session.conn.Unsubscribe(session.messages)
+}
+
+// Shutdown disconnects all sessions and closes the chat log
+func (ui *UI) Shutdown() {
+	ui.mutex.Lock()
+	ui.running = false
+	sessions := ui.sessions
+	ui.sessions = nil
+	ui.active = nil
+	ui.mutex.Unlock()
+
+	for _, session := range sessions {
+		sendDisconnect(session.conn)
+	}
+	if len(sessions) > 0 {
+		// Give messages time to send
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, session := range sessions {
+		session.conn.Disconnect()
+	}
+	ui.chatLog.Close()
+}
+
+// sendDisconnect notifies the server that the client is leaving
+func sendDisconnect(conn *chatclient.Connection) {
+	if conn.IsConnected() {
+		conn.Send(&common.Message{
+			Type:    common.TypeDisconnect,
+			Sender:  conn.Nickname(),
+			Content: "Client shutting down",
+		})
+	}
+}
+
+// activeSession returns the session commands currently apply to
+func (ui *UI) activeSession() *Session {
+	ui.mutex.RLock()
+	defer ui.mutex.RUnlock()
+	return ui.active
+}
+
+// findSession looks up a session by name or 1-based index
+func (ui *UI) findSession(nameOrIndex string) *Session {
+	ui.mutex.RLock()
+	defer ui.mutex.RUnlock()
+	if index, err := strconv.Atoi(nameOrIndex); err == nil && index >= 1 && index <= len(ui.sessions) {
+		return ui.sessions[index-1]
+	}
+	for _, s := range ui.sessions {
+		if s.Name == nameOrIndex {
+			return s
+		}
+	}
+	return nil
+}
+
+// printf prints a line, tagging it with the session name when several
+// sessions are open so output from background servers stays attributable
+func (ui *UI) printf(s *Session, format string, args ...interface{}) {
+	ui.mutex.RLock()
+	multiple := len(ui.sessions) > 1
+	ui.mutex.RUnlock()
+
+	format = common.T(format)
+	if multiple {
+		prefix := strings.TrimLeft(format, "\r\n")
+		leading := format[:len(format)-len(prefix)]
+		format = leading + "{" + s.Name + "} " + prefix
+	}
+	fmt.Printf(format, args...)
+}
+
 // Use platform-specific clearScreen function defined in platform_*.go files
 
+// helpCommands lists the commands shown by /help
+var helpCommands = []struct {
+	usage       string
+	description string
+}{
+	{"/help", "Show help"},
+	{"/users", "List online users"},
+	{"/msg <nick> <message>", "Send private message"},
+	{"/file <nick> <path...>", "Send files or directories (quote paths with spaces)"},
+	{"/memo <nick> <audio file>", "Send a recorded audio file as a voice memo"},
+	{"/status <active|busy|away|invisible>", "Change status"},
+	{"/away [message]", "Set yourself away, without a message come back"},
+	{"/check <nick>", "Check if a user is available"},
+	{"/room create <name> [ephemeral] [ttl=<duration>]", "Create private room, ephemeral rooms vanish when empty or after ttl"},
+	{"/room invite <id> <nick>", "Invite to room"},
+	{"/room accept <id>", "Accept room invitation"},
+	{"/room decline <id>", "Decline room invitation"},
+	{"/room join <id> [password]", "Join a room by ID"},
+	{"/room set <id> <key=value...>", "Set room policy (open, invite, password), password and max members"},
+	{"/room msg <id> <message>", "Message to room"},
+	{"/room list", "List your rooms"},
+	{"/room notify <id> [all|mentions|none]", "Show or set when a room highlights messages and rings the bell"},
+	{"/room leave <id>", "Leave a room"},
+	{"/transfers", "Show file transfers"},
+	{"/stats", "Show server statistics"},
+	{"/ping", "Measure the round trip time to the server"},
+	{"/format [plain|markdown]", "Show or set the format of your messages: **bold**, *italic*, `code`, [text](url)"},
+	{"/ignore [nick]", "Ignore a user or list ignored users"},
+	{"/unignore <nick>", "Stop ignoring a user"},
+	{"/mute [nick]", "Hide a user in broadcasts and rooms or list muted users"},
+	{"/unmute <nick>", "Unmute a user"},
+	{"/transfer <pause|resume|cancel> <id>", "Manage a file transfer"},
+	{"/transfer <rename|overwrite|discard> <id>", "Save or drop a received file whose name is taken"},
+	{"/downloads", "Show the download directory and disk usage"},
+	{"/export <room_id|nick|broadcast> [txt|json|html]", "Export history"},
+	{"/connect <address> [nick]", "Connect to another server"},
+	{"/switch <name|number>", "Switch active server"},
+	{"/connections", "List server connections"},
+	{"/disconnect [name|number]", "Close a server connection"},
+	{"/queue", "Show messages queued while offline"},
+	{"/flush", "Send messages queued while offline"},
+	{"/discard", "Drop messages queued while offline"},
+	{"/answer <text>", "Answer the server's connect challenge"},
+	{"/quit", "Exit"},
+}
+
 // showWelcome displays welcome message
 func (ui *UI) showWelcome() {
 	fmt.Println("=================================")
-	fmt.Println("   TCP Chat Client")
+	fmt.Println(common.T("   TCP Chat Client"))
 	fmt.Println("=================================")
-	fmt.Printf("Connected as: %s\n", ui.conn.nickname)
-	fmt.Println("\nCommands:")
-	fmt.Println("  /help                    - Show help")
-	fmt.Println("  /users                   - List online users")
-	fmt.Println("  /msg <nick> <message>    - Send private message")
-	fmt.Println("  /file <nick> <filepath>  - Send file")
-	fmt.Println("  /status <active|busy|invisible> - Change status")
-	fmt.Println("  /room create <name>      - Create private room")
-	fmt.Println("  /room invite <id> <nick> - Invite to room")
-	fmt.Println("  /room accept <id>        - Accept room invitation")
-	fmt.Println("  /room decline <id>       - Decline room invitation")
-	fmt.Println("  /room msg <id> <message> - Message to room")
-	fmt.Println("  /room list               - List your rooms")
-	fmt.Println("  /room leave <id>         - Leave a room")
-	fmt.Println("  /transfers               - Show file transfers")
-	fmt.Println("  /quit                    - Exit")
-	fmt.Println("\nType messages without '/' to broadcast to all users")
-	fmt.Println("=================================\n")
+	if s := ui.activeSession(); s != nil {
+		fmt.Print(common.T("Connected to %s as: %s\n", s.Name, s.conn.Nickname()))
+	}
+	fmt.Println(common.T("\nCommands:"))
+	for _, command := range helpCommands {
+		fmt.Printf("  %-24s - %s\n", command.usage, common.T(command.description))
+	}
+	fmt.Println(common.T("\nType messages without '/' to broadcast to all users"))
+	fmt.Println("=================================")
+	fmt.Println()
 }
 
 // handleInput handles user input
@@ -80,17 +251,20 @@ func (ui *UI) handleInput() {
 			continue
 		}
 
+		session := ui.activeSession()
 		if strings.HasPrefix(input, "/") {
-			ui.handleCommand(input)
+			ui.handleCommand(session, input)
+		} else if session == nil {
+			fmt.Println(common.T("Not connected. Use /connect <address> [nick]"))
 		} else {
 			// Send broadcast message
-			ui.conn.SendBroadcastMessage(input)
+			reportSendError(session.conn.SendBroadcastMessage(input))
 		}
 	}
 }
 
 // handleCommand handles slash commands
-func (ui *UI) handleCommand(input string) {
+func (ui *UI) handleCommand(s *Session, input string) {
 	parts := strings.Fields(input)
 	if len(parts) == 0 {
 		return
@@ -98,39 +272,170 @@ func (ui *UI) handleCommand(input string) {
 
 	command := strings.ToLower(parts[0])
 
+	// Commands available without an active session
 	switch command {
 	case "/help":
 		ui.showWelcome()
+		return
+
+	case "/connect":
+		if len(parts) < 2 {
+			fmt.Println(common.T("Usage: /connect <address> [nickname]"))
+			return
+		}
+		nickname := ""
+		if len(parts) > 2 {
+			nickname = parts[2]
+		} else if s != nil {
+			nickname = s.conn.Nickname()
+		}
+		if nickname == "" {
+			fmt.Println(common.T("Usage: /connect <address> <nickname>"))
+			return
+		}
+		if _, err := ui.Connect(parts[1], nickname); err != nil {
+			fmt.Print(common.T("Error: %v\n", err))
+		} else {
+			fmt.Print(common.T("Connecting to %s as %s...\n", parts[1], nickname))
+		}
+		return
+
+	case "/switch":
+		if len(parts) < 2 {
+			fmt.Println(common.T("Usage: /switch <name|number>"))
+			return
+		}
+		target := ui.findSession(parts[1])
+		if target == nil {
+			fmt.Print(common.T("No connection named %s\n", parts[1]))
+			return
+		}
+		ui.mutex.Lock()
+		ui.active = target
+		ui.mutex.Unlock()
+		fmt.Print(common.T("Switched to %s\n", target.Name))
+		return
+
+	case "/connections":
+		ui.showConnections()
+		return
+
+	case "/disconnect":
+		target := s
+		if len(parts) > 1 {
+			target = ui.findSession(parts[1])
+		}
+		if target == nil {
+			fmt.Println(common.T("No such connection"))
+			return
+		}
+		ui.Disconnect(target)
+		fmt.Print(common.T("Disconnected from %s\n", target.Name))
+		return
+
+	case "/quit":
+		ui.Shutdown()
+		fmt.Println(common.T("Goodbye!"))
+		os.Exit(0)
+	}
+
+	if s == nil {
+		fmt.Println(common.T("Not connected. Use /connect <address> [nick]"))
+		return
+	}
+
+	switch command {
 
 	case "/users":
-		ui.showUsers()
+		ui.showUsers(s)
+
+	case "/stats":
+		reportSendError(s.conn.RequestStats())
+
+	case "/ping":
+		reportSendError(s.conn.Ping())
+
+	case "/format":
+		if len(parts) < 2 {
+			format := s.conn.Format()
+			if format == common.FormatPlain {
+				format = "plain"
+			}
+			fmt.Print(common.T("Messages are sent as %s\n", format))
+			return
+		}
+		switch strings.ToLower(parts[1]) {
+		case "plain":
+			s.conn.SetFormat(common.FormatPlain)
+		case common.FormatMarkdown:
+			s.conn.SetFormat(common.FormatMarkdown)
+		default:
+			fmt.Println(common.T("Usage: /format [plain|markdown]"))
+			return
+		}
+		fmt.Print(common.T("Messages are sent as %s\n", strings.ToLower(parts[1])))
+
+	case "/answer":
+		if len(parts) < 2 {
+			fmt.Println(common.T("Usage: /answer <text>"))
+			return
+		}
+		reportSendError(s.conn.AnswerChallenge(strings.Join(parts[1:], " ")))
 
 	case "/msg":
 		if len(parts) < 3 {
-			fmt.Println("Usage: /msg <nickname> <message>")
+			fmt.Println(common.T("Usage: /msg <nickname> <message>"))
 			return
 		}
 		recipient := parts[1]
 		message := strings.Join(parts[2:], " ")
-		ui.conn.SendTextMessage(recipient, message)
+		reportSendError(s.conn.SendTextMessage(recipient, message))
+
+	case "/memo":
+		if len(parts) < 3 {
+			fmt.Println(common.T("Usage: /memo <nickname> <audio file>"))
+			return
+		}
+		recipient := parts[1]
+		paths := fileArguments(strings.Join(parts[2:], " "))
+		if len(paths) != 1 {
+			fmt.Println(common.T("Usage: /memo <nickname> <audio file>"))
+			return
+		}
+		if err := s.fileTransfer.SendVoiceMemo(recipient, paths[0]); err != nil {
+			fmt.Print(common.T("Error sending file: %v\n", err))
+		} else {
+			fmt.Print(common.T("Sending voice memo to %s...\n", recipient))
+		}
 
 	case "/file":
 		if len(parts) < 3 {
-			fmt.Println("Usage: /file <nickname> <filepath>")
+			fmt.Println(common.T("Usage: /file <nickname> <path> [path...]"))
 			return
 		}
 		recipient := parts[1]
-		filepath := strings.Join(parts[2:], " ")
+		paths := fileArguments(strings.Join(parts[2:], " "))
 
-		if err := ui.fileTransfer.SendFile(recipient, filepath); err != nil {
-			fmt.Printf("Error sending file: %v\n", err)
-		} else {
-			fmt.Printf("Sending file to %s...\n", recipient)
+		if len(paths) == 1 && !isDirectory(paths[0]) {
+			if err := s.fileTransfer.SendFile(recipient, paths[0]); err != nil {
+				fmt.Print(common.T("Error sending file: %v\n", err))
+			} else {
+				fmt.Print(common.T("Sending file to %s...\n", recipient))
+			}
+			return
 		}
 
+		batch, err := s.fileTransfer.SendFiles(recipient, paths)
+		if err != nil {
+			fmt.Print(common.T("Error sending files: %v\n", err))
+			return
+		}
+		fmt.Print(common.T("Sending %d file(s) to %s (%s)...\n",
+			len(batch.Files), recipient, chatclient.FormatFileSize(batch.TotalBytes)))
+
 	case "/status":
 		if len(parts) < 2 {
-			fmt.Println("Usage: /status <active|busy|invisible>")
+			fmt.Println(common.T("Usage: /status <active|busy|away|invisible>"))
 			return
 		}
 
@@ -140,37 +445,136 @@ func (ui *UI) handleCommand(input string) {
 			status = common.StatusActive
 		case "busy":
 			status = common.StatusBusy
+		case "away":
+			status = common.StatusAway
 		case "invisible":
 			status = common.StatusInvisible
 		default:
-			fmt.Println("Invalid status. Use: active, busy, or invisible")
+			fmt.Println(common.T("Invalid status. Use: active, busy, away or invisible"))
+			return
+		}
+
+		s.conn.ChangeStatus(status)
+		fmt.Print(common.T("Status changed to: %s\n", status))
+
+	case "/away":
+		message := strings.TrimSpace(strings.TrimPrefix(input, parts[0]))
+		if message == "" {
+			s.conn.ChangeStatus(common.StatusActive)
+			fmt.Println(common.T("You are no longer away"))
+			return
+		}
+		if len(message) > common.MaxAwayLength {
+			fmt.Print(common.T("Away message cannot exceed %d characters\n", common.MaxAwayLength))
 			return
 		}
+		s.conn.SetAway(message)
+		fmt.Print(common.T("You are away: %s\n", message))
 
-		ui.conn.ChangeStatus(status)
-		fmt.Printf("Status changed to: %s\n", status)
+	case "/check":
+		if len(parts) < 2 {
+			fmt.Println(common.T("Usage: /check <nick>"))
+			return
+		}
+		s.conn.QueryStatus(parts[1])
 
 	case "/room":
-		ui.handleRoomCommand(parts[1:])
+		ui.handleRoomCommand(s, parts[1:])
 
 	case "/transfers":
-		ui.showTransfers()
+		ui.showTransfers(s)
 
-	case "/quit":
-		ui.running = false
-		ui.conn.Disconnect()
-		fmt.Println("Goodbye!")
-		os.Exit(0)
+	case "/ignore", "/unignore", "/mute", "/unmute":
+		ui.handleIgnoreCommand(parts)
+
+	case "/downloads":
+		ui.showDownloads(s)
+
+	case "/transfer":
+		if len(parts) < 3 {
+			fmt.Println(common.T("Usage: /transfer <pause|resume|cancel|rename|overwrite|discard> <fileID>"))
+			return
+		}
+		fileID, err := s.fileTransfer.FindTransfer(parts[2])
+		if err != nil {
+			fmt.Print(common.T("Error: %v\n", err))
+			return
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "pause":
+			err = s.fileTransfer.Pause(fileID)
+		case "resume":
+			err = s.fileTransfer.Resume(fileID)
+		case "cancel":
+			err = s.fileTransfer.Cancel(fileID)
+		case "rename", "overwrite":
+			// Settle a name collision of a received file
+			policy := chatclient.CollisionPolicy(strings.ToLower(parts[1]))
+			ui.saveFile(s, fileID, func(fileID string) (string, error) {
+				return s.fileTransfer.ReceiveFileAs(fileID, policy)
+			})
+			return
+		case "discard":
+			err = s.fileTransfer.Discard(fileID)
+		default:
+			fmt.Println(common.T("Usage: /transfer <pause|resume|cancel|rename|overwrite|discard> <fileID>"))
+			return
+		}
+		if err != nil {
+			fmt.Print(common.T("Error: %v\n", err))
+		} else {
+			fmt.Print(common.T("Transfer %s: %s\n", parts[2], strings.ToLower(parts[1])))
+		}
+
+	case "/queue":
+		pending := s.conn.PendingMessages()
+		fmt.Println(common.T("\n=== Offline Queue ==="))
+		if len(pending) == 0 {
+			fmt.Println(common.T("  No queued messages"))
+		}
+		for i, msg := range pending {
+			fmt.Printf("  %d. [%s] %s %s\n", i+1, msg.Timestamp.Format("15:04:05"), msg.Type, msg.Content)
+		}
+		fmt.Println("=====================")
+		fmt.Println()
+
+	case "/flush":
+		sent, err := s.conn.FlushPending()
+		if err != nil {
+			fmt.Print(common.T("Cannot flush queue: %v\n", err))
+		} else {
+			fmt.Print(common.T("Sent %d queued message(s)\n", sent))
+		}
+
+	case "/discard":
+		fmt.Print(common.T("Discarded %d queued message(s)\n", s.conn.DiscardPending()))
+
+	case "/export":
+		if len(parts) < 2 {
+			fmt.Println(common.T("Usage: /export <room_id|nick|broadcast> [txt|json|html]"))
+			return
+		}
+		format := "txt"
+		if len(parts) > 2 {
+			format = parts[2]
+		}
+		path, err := ui.chatLog.Export(s.Name+"/"+parts[1], format, "exports")
+		if err != nil {
+			fmt.Print(common.T("Error exporting history: %v\n", err))
+		} else {
+			fmt.Print(common.T("History exported to %s\n", path))
+		}
 
 	default:
-		fmt.Printf("Unknown command: %s\n", command)
+		fmt.Print(common.T("Unknown command: %s\n", command))
 	}
 }
 
 // handleRoomCommand handles room-related commands
-func (ui *UI) handleRoomCommand(args []string) {
+func (ui *UI) handleRoomCommand(s *Session, args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: /room <create|invite|accept|decline|msg|list|leave|members|kick|delete|topic> ...")
+		fmt.Println(common.T("Usage: /room <create|invite|accept|decline|msg|list|notify|leave|members|kick|delete|topic> ..."))
 		return
 	}
 
@@ -179,229 +583,577 @@ func (ui *UI) handleRoomCommand(args []string) {
 	switch subcommand {
 	case "create":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room create <name>")
+			fmt.Println(common.T("Usage: /room create <name> [ephemeral] [ttl=<duration>]"))
 			return
 		}
-		roomName := strings.Join(args[1:], " ")
-		ui.conn.CreateRoom(roomName)
+
+		// Trailing options make the room ephemeral
+		ephemeral := false
+		var ttl time.Duration
+		nameArgs := args[1:]
+		for len(nameArgs) > 1 {
+			last := nameArgs[len(nameArgs)-1]
+			if last == "ephemeral" {
+				ephemeral = true
+			} else if value, ok := strings.CutPrefix(last, "ttl="); ok {
+				parsed, err := time.ParseDuration(value)
+				if err != nil {
+					fmt.Print(common.T("Invalid TTL: %v\n", err))
+					return
+				}
+				ephemeral = true
+				ttl = parsed
+			} else {
+				break
+			}
+			nameArgs = nameArgs[:len(nameArgs)-1]
+		}
+
+		roomName := strings.Join(nameArgs, " ")
+		if ephemeral {
+			s.conn.CreateEphemeralRoom(roomName, ttl)
+		} else {
+			s.conn.CreateRoom(roomName)
+		}
 
 	case "invite":
 		if len(args) < 3 {
-			fmt.Println("Usage: /room invite <room_id> <nickname>")
+			fmt.Println(common.T("Usage: /room invite <room_id> <nickname>"))
 			return
 		}
 		roomID := args[1]
 		nickname := args[2]
-		ui.conn.InviteToRoom(roomID, nickname)
+		s.conn.InviteToRoom(roomID, nickname)
 
 	case "accept":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room accept <room_id>")
+			fmt.Println(common.T("Usage: /room accept <room_id>"))
 			return
 		}
 		roomID := args[1]
-		ui.conn.RespondToInvite(roomID, true)
-		fmt.Printf("Accepted invitation to room %s\n", roomID)
+		s.conn.RespondToInvite(roomID, true)
+		fmt.Print(common.T("Accepted invitation to room %s\n", roomID))
 
 	case "decline":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room decline <room_id>")
+			fmt.Println(common.T("Usage: /room decline <room_id>"))
 			return
 		}
 		roomID := args[1]
-		ui.conn.RespondToInvite(roomID, false)
-		fmt.Printf("Declined invitation to room %s\n", roomID)
+		s.conn.RespondToInvite(roomID, false)
+		fmt.Print(common.T("Declined invitation to room %s\n", roomID))
 
 	case "msg":
 		if len(args) < 3 {
-			fmt.Println("Usage: /room msg <room_id> <message>")
+			fmt.Println(common.T("Usage: /room msg <room_id> <message>"))
 			return
 		}
 		roomID := args[1]
 		message := strings.Join(args[2:], " ")
-		ui.conn.SendRoomMessage(roomID, message)
+		reportSendError(s.conn.SendRoomMessage(roomID, message))
+
+	case "join":
+		if len(args) < 2 {
+			fmt.Println(common.T("Usage: /room join <room_id> [password]"))
+			return
+		}
+		password := ""
+		if len(args) > 2 {
+			password = args[2]
+		}
+		s.conn.JoinProtectedRoom(args[1], password)
+
+	case "set":
+		if len(args) < 3 {
+			fmt.Println(common.T("Usage: /room set <room_id> policy=<open|invite|password> [password=<secret>] [max=<n>]"))
+			return
+		}
+		s.conn.SetRoomSettings(args[1], strings.Join(args[2:], " "))
 
 	case "list":
-		ui.showRooms()
+		ui.showRooms(s)
+
+	case "notify":
+		if len(args) < 2 {
+			fmt.Println(common.T("Usage: /room notify <room_id> [all|mentions|none]"))
+			return
+		}
+		roomID := args[1]
+		if len(args) == 2 {
+			fmt.Print(common.T("Notifications for %s: %s\n", roomID, ui.notify.Level(roomID)))
+			return
+		}
+		level, err := ParseNotifyLevel(args[2])
+		if err == nil {
+			err = ui.notify.SetLevel(roomID, level)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Print(common.T("Notifications for %s set to %s\n", roomID, level))
 
 	case "leave":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room leave <room_id>")
+			fmt.Println(common.T("Usage: /room leave <room_id>"))
 			return
 		}
 		roomID := args[1]
-		ui.conn.LeaveRoom(roomID)
+		s.conn.LeaveRoom(roomID)
 		// Don't delete here - wait for server confirmation
 
 	case "members":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room members <room_id>")
+			fmt.Println(common.T("Usage: /room members <room_id>"))
 			return
 		}
 		roomID := args[1]
-		ui.conn.GetRoomMembers(roomID)
+		s.conn.GetRoomMembers(roomID)
 
 	case "kick":
 		if len(args) < 3 {
-			fmt.Println("Usage: /room kick <room_id> <nickname>")
+			fmt.Println(common.T("Usage: /room kick <room_id> <nickname>"))
 			return
 		}
 		roomID := args[1]
 		nickname := args[2]
-		ui.conn.KickFromRoom(roomID, nickname)
+		s.conn.KickFromRoom(roomID, nickname)
 
 	case "delete":
 		if len(args) < 2 {
-			fmt.Println("Usage: /room delete <room_id>")
+			fmt.Println(common.T("Usage: /room delete <room_id>"))
 			return
 		}
 		roomID := args[1]
-		ui.conn.DeleteRoom(roomID)
+		s.conn.DeleteRoom(roomID)
 
 	case "topic":
 		if len(args) < 3 {
-			fmt.Println("Usage: /room topic <room_id> <description>")
+			fmt.Println(common.T("Usage: /room topic <room_id> <description>"))
 			return
 		}
 		roomID := args[1]
 		description := strings.Join(args[2:], " ")
-		ui.conn.SetRoomTopic(roomID, description)
+		s.conn.SetRoomTopic(roomID, description)
 
 	default:
-		fmt.Printf("Unknown room command: %s\n", subcommand)
+		fmt.Print(common.T("Unknown room command: %s\n", subcommand))
 	}
 }
 
-// receiveMessages handles incoming messages
-func (ui *UI) receiveMessages() {
-	for msg := range ui.conn.GetMessages() {
-		ui.handleMessage(msg)
+// receiveMessages handles incoming messages of a session
+func (ui *UI) receiveMessages(s *Session) {
+	for msg := range s.messages {
+		ui.handleMessage(s, msg)
 	}
 }
 
 // handleMessage processes incoming messages
-func (ui *UI) handleMessage(msg *common.Message) {
+func (ui *UI) handleMessage(s *Session, msg *common.Message) {
+	if ui.ignores.Suppress(msg) {
+		if msg.Type == common.TypeFile {
+			// Refuse the file instead of silently storing it
+			s.fileTransfer.Cancel(msg.FileID)
+		}
+		return
+	}
+
 	timestamp := msg.Timestamp.Format("15:04:05")
+	triggered := ui.triggers.Process(msg, s.conn.Nickname())
 
 	switch msg.Type {
 	case common.TypeText:
+		ui.chatLog.Record(s.ConversationKey(msg), msg.Sender, msg.Content, msg.Timestamp)
+
+		content := msg.Content
+		marked, bell := triggered.Highlight, false
+		if msg.Room != "" {
+			marked, bell = ui.notify.Alert(msg.Room, msg.Sender, msg.Content, s.conn.Nickname(), triggered.Highlight)
+		}
+		switch {
+		case msg.Format == common.FormatMarkdown && marked:
+			content = renderMarkup(content, ansiHighlight)
+		case msg.Format == common.FormatMarkdown:
+			content = renderMarkup(content, "")
+		case marked:
+			content = highlight(content)
+		}
+		if bell {
+			fmt.Print("\a")
+		}
+
 		if msg.Room != "" {
 			// Room message
-			ui.mutex.RLock()
-			roomName := ui.rooms[msg.Room]
-			ui.mutex.RUnlock()
+			s.mutex.RLock()
+			roomName := s.rooms[msg.Room]
+			s.mutex.RUnlock()
 			if roomName == "" {
 				roomName = msg.Room
 			}
-			fmt.Printf("[%s] [Room: %s] %s: %s\n", timestamp, roomName, msg.Sender, msg.Content)
-		} else if msg.Recipient == ui.conn.nickname {
+			ui.printf(s, "[%s] [Room: %s] %s: %s\n", timestamp, roomName, msg.Sender, content)
+		} else if msg.Recipient == s.conn.Nickname() {
 			// Private message
-			fmt.Printf("[%s] [Private] %s: %s\n", timestamp, msg.Sender, msg.Content)
+			ui.printf(s, "[%s] [Private] %s: %s\n", timestamp, msg.Sender, content)
 		} else if msg.Recipient == "*" || msg.Recipient == "" {
 			// Broadcast message
-			fmt.Printf("[%s] %s: %s\n", timestamp, msg.Sender, msg.Content)
+			ui.printf(s, "[%s] %s: %s\n", timestamp, msg.Sender, content)
 		}
 
-	case common.TypeUserList:
-		ui.users = msg.Users
+		ui.runTriggers(s, msg, triggered)
+
+	case common.TypeUserList, common.TypeUserJoined, common.TypeUserLeft, common.TypeUserStatus:
+		// Applied to the user directory by the connection, joins, leaves
+		// and status changes are also announced as server text
 
 	case common.TypeStatus:
-		fmt.Printf("[%s] %s changed status to %s\n", timestamp, msg.Sender, msg.Status)
+		// Answer to /check
+		if msg.Status == common.StatusOffline {
+			ui.printf(s, "[%s] %s is offline\n", timestamp, msg.Sender)
+		} else if msg.Content != "" {
+			ui.printf(s, "[%s] %s is away: %s\n", timestamp, msg.Sender, msg.Content)
+		} else {
+			ui.printf(s, "[%s] %s is %s\n", timestamp, msg.Sender, msg.Status)
+		}
 
 	case common.TypeRoom:
 		if msg.Action == common.RoomCreate {
-			ui.mutex.Lock()
-			ui.rooms[msg.Room] = msg.Content
-			ui.mutex.Unlock()
-			fmt.Printf("[%s] %s (ID: %s)\n", timestamp, msg.Content, msg.Room)
+			s.mutex.Lock()
+			s.rooms[msg.Room] = msg.Content
+			s.mutex.Unlock()
+			ui.printf(s, "[%s] %s (ID: %s)\n", timestamp, msg.Content, msg.Room)
 		} else if msg.Action == common.RoomJoin {
 			// Add room to our list when we join
-			ui.mutex.Lock()
-			ui.rooms[msg.Room] = msg.Content
-			ui.mutex.Unlock()
-			fmt.Printf("[%s] Joined room '%s' (ID: %s)\n", timestamp, msg.Content, msg.Room)
+			s.mutex.Lock()
+			s.rooms[msg.Room] = msg.Content
+			s.mutex.Unlock()
+			ui.printf(s, "[%s] Joined room '%s' (ID: %s)\n", timestamp, msg.Content, msg.Room)
 		} else if msg.Action == common.RoomMembers {
 			// Display room members
-			fmt.Printf("[%s] %s\n", timestamp, msg.Content)
+			ui.printf(s, "[%s] %s\n", timestamp, msg.Content)
 		} else if msg.Action == common.RoomLeaveConfirm {
 			// Remove room from local state after confirmation
-			ui.mutex.Lock()
-			delete(ui.rooms, msg.Room)
-			ui.mutex.Unlock()
-			fmt.Printf("[%s] Left room '%s'\n", timestamp, msg.Content)
+			s.mutex.Lock()
+			delete(s.rooms, msg.Room)
+			s.mutex.Unlock()
+			ui.printf(s, "[%s] Left room '%s'\n", timestamp, msg.Content)
 		}
 
 	case common.TypeInvite:
-		fmt.Printf("\n[%s] %s\n", timestamp, msg.Content)
-		fmt.Printf("Type '/room accept %s' to accept or '/room decline %s' to decline\n", msg.Room, msg.Room)
+		ui.printf(s, "\n[%s] %s\n", timestamp, msg.Content)
+		ui.printf(s, "Type '/room accept %s' to accept or '/room decline %s' to decline\n", msg.Room, msg.Room)
 
 	case common.TypeFile:
-		fmt.Printf("[%s] %s is sending you file: %s (%s)\n",
-			timestamp, msg.Sender, msg.Filename, formatFileSize(msg.Filesize))
+		if err := s.fileTransfer.AcceptOffer(msg.FileID); err != nil {
+			s.fileTransfer.Cancel(msg.FileID)
+			ui.printf(s, "[%s] Declined file %s from %s: %v\n", timestamp, msg.Filename, msg.Sender, err)
+			return
+		}
+		ui.printf(s, "[%s] %s is sending you file: %s (%s)\n",
+			timestamp, msg.Sender, msg.Filename, describeFile(msg))
 
 	case common.TypeFileChunk:
 		// Progress update
-		fmt.Printf("\rFile transfer: %s - %s", msg.Filename, msg.Content)
+		ui.printf(s, "\rFile transfer: %s - %s", msg.Filename, msg.Content)
 
 	case common.TypeFileComplete:
-		fmt.Printf("\n[%s] File received: %s\n", timestamp, msg.Filename)
-		if err := ui.fileTransfer.ReceiveFile(msg.FileID); err != nil {
-			fmt.Printf("Error saving file: %v\n", err)
-		} else {
-			fmt.Printf("File saved to downloads/%s\n", msg.Filename)
+		if !s.fileTransfer.IsIncoming(msg.FileID) {
+			// Completion of one of our own uploads, already reported
+			return
 		}
+		ui.printf(s, "\n[%s] File received: %s\n", timestamp, msg.Filename)
+		ui.saveFile(s, msg.FileID, s.fileTransfer.ReceiveFile)
+
+	case common.TypeStats:
+		ui.printf(s, "[%s] Server statistics:\n", timestamp)
+		fmt.Println(msg.Content)
+
+	case common.TypeChallenge:
+		ui.printf(s, "[%s] Server asks: %s\n", timestamp, msg.Content)
+		ui.printf(s, "Reply with /answer <text> to finish connecting\n")
 
 	case common.TypeError:
-		fmt.Printf("[%s] Error: %s\n", timestamp, msg.Error)
+		ui.showError(s, timestamp, msg)
+
+	case chatclient.TypeLocal:
+		ui.printf(s, "\n[%s] %s\n", timestamp, msg.Content)
 
 	default:
 		// System messages
 		if msg.Sender == "Server" {
-			fmt.Printf("[%s] %s\n", timestamp, msg.Content)
+			ui.printf(s, "[%s] %s\n", timestamp, msg.Content)
+		}
+	}
+}
+
+// showError prints a server error with a hint chosen by its code
+func (ui *UI) showError(s *Session, timestamp string, msg *common.Message) {
+	chatErr := msg.ChatError()
+	ui.printf(s, "[%s] Error: %s\n", timestamp, chatErr.Message)
+
+	switch chatErr.Type {
+	case common.ErrRateLimit, common.ErrUnavailable:
+		if wait, ok := chatErr.RetryAfter(); ok {
+			ui.printf(s, "You can try again in %v\n", wait)
+		}
+	case common.ErrNotFound:
+		ui.printf(s, "Use /users or /room list to see who and what is available\n")
+	case common.ErrDuplicate:
+		ui.printf(s, "Reconnect with a different nickname\n")
+	}
+}
+
+// runTriggers executes commands and replies produced by matching triggers
+func (ui *UI) runTriggers(s *Session, msg *common.Message, triggered chatclient.TriggerResult) {
+	for _, command := range triggered.Commands {
+		ui.handleCommand(s, command)
+	}
+
+	for _, reply := range triggered.Replies {
+		s.conn.SendAutoReply(msg, reply)
+	}
+}
+
+// configureDownloads applies the download settings to a session; the
+// collision policy was validated on startup
+func (ui *UI) configureDownloads(ft *chatclient.FileTransfer) {
+	if ui.config.DownloadDir != "" {
+		ft.DownloadDir = ui.config.DownloadDir
+	}
+	ft.PerSender = ui.config.DownloadPerSender
+	ft.SortDownloads = ui.config.SortDownloads
+	ft.OnCollision, _ = chatclient.ParseCollisionPolicy(ui.config.DownloadCollision)
+	ft.Quota = ui.config.DownloadQuota
+}
+
+// saveFile stores a received file using receive and previews it; a taken
+// name leaves the file waiting for the user to decide
+func (ui *UI) saveFile(s *Session, fileID string, receive func(fileID string) (string, error)) {
+	contentType, metadata := s.fileTransfer.Describe(fileID)
+	path, err := receive(fileID)
+	switch {
+	case errors.Is(err, chatclient.ErrFileExists):
+		ui.printf(s, "%s already exists, use /transfer <rename|overwrite|discard> %s\n", path, fileID[:min(len(fileID), 8)])
+	case err != nil:
+		ui.printf(s, "Error saving file: %v\n", err)
+	default:
+		ui.printf(s, "File saved to %s\n", path)
+		if err := ui.preview.Render(os.Stdout, path, contentType, metadata); err != nil {
+			log.Printf("Image preview failed for %s: %v", path, err)
+		}
+	}
+}
+
+// showDownloads displays the download settings and disk usage
+func (ui *UI) showDownloads(s *Session) {
+	ft := s.fileTransfer
+	fmt.Println(common.T("\n=== Downloads ==="))
+	fmt.Print(common.T("  Directory: %s\n", ft.DownloadDir))
+	fmt.Print(common.T("  On name collision: %s\n", ft.OnCollision))
+	used, err := ft.DiskUsage()
+	switch {
+	case err != nil:
+		fmt.Print(common.T("  Disk usage unknown: %v\n", err))
+	case ft.Quota > 0:
+		fmt.Print(common.T("  Used: %s of %s\n", chatclient.FormatFileSize(used), chatclient.FormatFileSize(ft.Quota)))
+	default:
+		fmt.Print(common.T("  Used: %s, no quota\n", chatclient.FormatFileSize(used)))
+	}
+	fmt.Println("=================")
+	fmt.Println()
+}
+
+// reportSendError tells the user when a message was not sent right away
+func reportSendError(err error) {
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// highlight marks text with ANSI bold yellow
+func highlight(text string) string {
+	return ansiHighlight + text + ansiReset
+}
+
+// showConnections lists open server connections
+func (ui *UI) showConnections() {
+	ui.mutex.RLock()
+	defer ui.mutex.RUnlock()
+
+	fmt.Println(common.T("\n=== Connections ==="))
+	if len(ui.sessions) == 0 {
+		fmt.Println(common.T("  No connections"))
+	}
+	for i, s := range ui.sessions {
+		marker := " "
+		if s == ui.active {
+			marker = "*"
+		}
+		state := "connecting"
+		if s.conn.IsConnected() {
+			state = "connected"
+			if rtt, ok := s.conn.Latency(); ok {
+				state += fmt.Sprintf(", %v", rtt.Round(100*time.Microsecond))
+			}
 		}
+		fmt.Print(common.T(" %s%d. %s as %s (%s)\n", marker, i+1, s.Name, s.conn.Nickname(), state))
 	}
+	fmt.Println("===================")
+	fmt.Println()
 }
 
 // showUsers displays online users
-func (ui *UI) showUsers() {
-	fmt.Println("\n=== Online Users ===")
-	for _, user := range ui.users {
-		parts := strings.Split(user, ":")
-		if len(parts) == 2 {
+func (ui *UI) showUsers(s *Session) {
+	fmt.Println(common.T("\n=== Online Users ==="))
+	for _, user := range s.conn.Users() {
+		parts := strings.SplitN(user, ":", 3)
+		if len(parts) == 3 {
+			fmt.Printf("  %s (%s: %s)\n", parts[0], parts[1], parts[2])
+		} else if len(parts) == 2 {
 			fmt.Printf("  %s (%s)\n", parts[0], parts[1])
 		} else {
 			fmt.Printf("  %s\n", user)
 		}
 	}
-	fmt.Println("==================\n")
+	fmt.Println("==================")
+	fmt.Println()
 }
 
 // showRooms displays user's rooms
-func (ui *UI) showRooms() {
-	fmt.Println("\n=== Your Rooms ===")
-	ui.mutex.RLock()
-	defer ui.mutex.RUnlock()
-	if len(ui.rooms) == 0 {
-		fmt.Println("  No rooms joined")
+func (ui *UI) showRooms(s *Session) {
+	fmt.Println(common.T("\n=== Your Rooms ==="))
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if len(s.rooms) == 0 {
+		fmt.Println(common.T("  No rooms joined"))
 	} else {
-		for id, info := range ui.rooms {
+		for id, info := range s.rooms {
+			if level := ui.notify.Level(id); level != NotifyMentions {
+				info += fmt.Sprintf(" [notify: %s]", level)
+			}
 			fmt.Printf("  %s: %s\n", id, info)
 		}
 	}
-	fmt.Println("==================\n")
+	fmt.Println("==================")
+	fmt.Println()
 }
 
 // showTransfers displays active file transfers
-func (ui *UI) showTransfers() {
-	transfers := ui.fileTransfer.GetTransferProgress()
+func (ui *UI) showTransfers(s *Session) {
+	transfers := s.fileTransfer.GetTransferProgress()
 
-	fmt.Println("\n=== File Transfers ===")
+	fmt.Println(common.T("\n=== File Transfers ==="))
 	if len(transfers) == 0 {
-		fmt.Println("  No active transfers")
+		fmt.Println(common.T("  No active transfers"))
 	} else {
 		for _, transfer := range transfers {
 			fmt.Printf("  %s\n", transfer)
 		}
 	}
-	fmt.Println("===================\n")
+	fmt.Println("===================")
+	fmt.Println()
+}
+
+// fileArguments splits /file arguments into paths. Pasted or dropped paths
+// may be quoted or use backslash escapes; an unquoted path with spaces is
+// accepted when it names an existing file.
+func fileArguments(input string) []string {
+	if _, err := os.Stat(input); err == nil {
+		return []string{input}
+	}
+
+	var paths []string
+	var current strings.Builder
+	var quote rune
+	inPath, escaped := false, false
+	for _, r := range input {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote == 0:
+			escaped, inPath = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inPath = r, true
+		case r == ' ' || r == '\t':
+			if inPath {
+				paths = append(paths, current.String())
+				current.Reset()
+				inPath = false
+			}
+		default:
+			current.WriteRune(r)
+			inPath = true
+		}
+	}
+	if inPath {
+		paths = append(paths, current.String())
+	}
+	return paths
+}
+
+// isDirectory reports whether a path names an existing directory
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// handleIgnoreCommand manages the ignore and mute lists
+func (ui *UI) handleIgnoreCommand(parts []string) {
+	command := parts[0]
+	if len(parts) < 2 {
+		switch command {
+		case "/ignore":
+			showList("Ignored users", ui.ignores.Ignored())
+		case "/mute":
+			showList("Muted users", ui.ignores.Muted())
+		default:
+			fmt.Print(common.T("Usage: %s <nickname>\n", command))
+		}
+		return
+	}
+
+	nickname := parts[1]
+	var err error
+	switch command {
+	case "/ignore":
+		err = ui.ignores.Ignore(nickname)
+	case "/unignore":
+		err = ui.ignores.Unignore(nickname)
+	case "/mute":
+		err = ui.ignores.Mute(nickname)
+	case "/unmute":
+		err = ui.ignores.Unmute(nickname)
+	}
+	if err != nil {
+		fmt.Print(common.T("Error: %v\n", err))
+		return
+	}
+	fmt.Printf("%s: %s\n", strings.TrimPrefix(command, "/"), nickname)
+
+	if ui.ignores.ServerEnforced() && (command == "/ignore" || command == "/unignore") {
+		ignored := ui.ignores.Ignored()
+		ui.mutex.RLock()
+		for _, s := range ui.sessions {
+			reportSendError(s.conn.SetIgnoreList(ignored))
+		}
+		ui.mutex.RUnlock()
+	}
+}
+
+// showList prints a titled list of names
+func showList(title string, names []string) {
+	fmt.Printf("\n=== %s ===\n", common.T(title))
+	if len(names) == 0 {
+		fmt.Println(common.T("  (none)"))
+	}
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Println()
 }
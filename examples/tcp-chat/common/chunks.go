@@ -0,0 +1,218 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultSpillThreshold is the amount of chunk data kept in memory before
+// an assembler moves it to a temporary file
+const DefaultSpillThreshold = 8 * 1024 * 1024 // 8MB
+
+// span locates a chunk inside the spill file
+type span struct {
+	offset int64
+	length int
+}
+
+// ChunkAssembler collects the chunks of a file in any order, tracks progress
+// and completeness and writes the file out in order. Chunk data is kept in
+// memory until it exceeds the spill threshold, after which it is stored in a
+// temporary file that is removed by Close.
+type ChunkAssembler struct {
+	totalChunks    int
+	spillThreshold int64
+
+	memory    map[int][]byte
+	spilled   map[int]span
+	memBytes  int64
+	size      int64
+	spillFile *os.File
+	spillEnd  int64
+	closed    bool
+	mutex     sync.RWMutex
+}
+
+// NewChunkAssembler creates an assembler for totalChunks chunks. A
+// spillThreshold of zero or less keeps everything in memory.
+func NewChunkAssembler(totalChunks int, spillThreshold int64) *ChunkAssembler {
+	return &ChunkAssembler{
+		totalChunks:    totalChunks,
+		spillThreshold: spillThreshold,
+		memory:         make(map[int][]byte),
+		spilled:        make(map[int]span),
+	}
+}
+
+// TotalChunks returns the number of chunks the file consists of
+func (ca *ChunkAssembler) TotalChunks() int {
+	return ca.totalChunks
+}
+
+// Add stores a chunk; duplicates are ignored so retransmissions do not
+// distort progress
+func (ca *ChunkAssembler) Add(chunkNum int, data []byte) error {
+	if chunkNum < 0 || chunkNum >= ca.totalChunks {
+		return fmt.Errorf("chunk %d out of range (0-%d)", chunkNum, ca.totalChunks-1)
+	}
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if ca.closed {
+		return os.ErrClosed
+	}
+	if ca.has(chunkNum) {
+		return nil
+	}
+
+	ca.size += int64(len(data))
+	if ca.spillFile != nil {
+		return ca.appendToSpill(chunkNum, data)
+	}
+
+	ca.memory[chunkNum] = append([]byte(nil), data...)
+	ca.memBytes += int64(len(data))
+	if ca.spillThreshold > 0 && ca.memBytes > ca.spillThreshold {
+		return ca.spill()
+	}
+	return nil
+}
+
+// has checks if a chunk was received; the caller holds the mutex
+func (ca *ChunkAssembler) has(chunkNum int) bool {
+	if _, ok := ca.memory[chunkNum]; ok {
+		return true
+	}
+	_, ok := ca.spilled[chunkNum]
+	return ok
+}
+
+// spill moves the chunks held in memory to the spill file; the caller holds the mutex
+func (ca *ChunkAssembler) spill() error {
+	file, err := os.CreateTemp("", "chunks-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %v", err)
+	}
+	ca.spillFile = file
+
+	for chunkNum, data := range ca.memory {
+		if err := ca.appendToSpill(chunkNum, data); err != nil {
+			return err
+		}
+	}
+	ca.memory = make(map[int][]byte)
+	ca.memBytes = 0
+	return nil
+}
+
+// appendToSpill writes a chunk to the end of the spill file; the caller holds the mutex
+func (ca *ChunkAssembler) appendToSpill(chunkNum int, data []byte) error {
+	if _, err := ca.spillFile.WriteAt(data, ca.spillEnd); err != nil {
+		return fmt.Errorf("failed to spill chunk %d: %v", chunkNum, err)
+	}
+	ca.spilled[chunkNum] = span{offset: ca.spillEnd, length: len(data)}
+	ca.spillEnd += int64(len(data))
+	return nil
+}
+
+// Has checks if a chunk was received
+func (ca *ChunkAssembler) Has(chunkNum int) bool {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return ca.has(chunkNum)
+}
+
+// Chunk returns the data of a received chunk
+func (ca *ChunkAssembler) Chunk(chunkNum int) ([]byte, bool) {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+
+	if data, ok := ca.memory[chunkNum]; ok {
+		return data, true
+	}
+	location, ok := ca.spilled[chunkNum]
+	if !ok {
+		return nil, false
+	}
+	data := make([]byte, location.length)
+	if _, err := ca.spillFile.ReadAt(data, location.offset); err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Received returns the number of distinct chunks received
+func (ca *ChunkAssembler) Received() int {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return len(ca.memory) + len(ca.spilled)
+}
+
+// Size returns the number of bytes received
+func (ca *ChunkAssembler) Size() int64 {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return ca.size
+}
+
+// Progress returns the percentage of chunks received
+func (ca *ChunkAssembler) Progress() float64 {
+	if ca.totalChunks == 0 {
+		return 0
+	}
+	return float64(ca.Received()) / float64(ca.totalChunks) * 100
+}
+
+// IsComplete checks if all chunks have been received
+func (ca *ChunkAssembler) IsComplete() bool {
+	return ca.Received() == ca.totalChunks
+}
+
+// IsSpilled reports whether chunk data has been moved to disk
+func (ca *ChunkAssembler) IsSpilled() bool {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return ca.spillFile != nil
+}
+
+// WriteTo writes the chunks to w in order; it fails on the first missing chunk
+func (ca *ChunkAssembler) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for i := 0; i < ca.totalChunks; i++ {
+		data, ok := ca.Chunk(i)
+		if !ok {
+			return written, fmt.Errorf("missing chunk %d", i)
+		}
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Close releases the chunk data and removes the spill file
+func (ca *ChunkAssembler) Close() error {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	ca.closed = true
+	ca.memory = make(map[int][]byte)
+	ca.spilled = make(map[int]span)
+	ca.memBytes = 0
+	if ca.spillFile == nil {
+		return nil
+	}
+
+	name := ca.spillFile.Name()
+	err := ca.spillFile.Close()
+	ca.spillFile = nil
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestChunkAssemblerOutOfOrder(t *testing.T) {
+	ca := NewChunkAssembler(3, 0)
+	defer ca.Close()
+
+	for _, n := range []int{2, 0, 1} {
+		if ca.IsComplete() {
+			t.Fatalf("complete before chunk %d was added", n)
+		}
+		if err := ca.Add(n, []byte{byte('a' + n)}); err != nil {
+			t.Fatalf("Add(%d): %v", n, err)
+		}
+	}
+
+	if !ca.IsComplete() {
+		t.Fatal("expected assembler to be complete")
+	}
+	var out bytes.Buffer
+	if _, err := ca.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if out.String() != "abc" {
+		t.Errorf("assembled %q, want %q", out.String(), "abc")
+	}
+}
+
+func TestChunkAssemblerProgressAndDuplicates(t *testing.T) {
+	ca := NewChunkAssembler(4, 0)
+	defer ca.Close()
+
+	ca.Add(0, []byte("xx"))
+	ca.Add(0, []byte("xx"))
+	ca.Add(3, []byte("y"))
+
+	if got := ca.Received(); got != 2 {
+		t.Errorf("Received() = %d, want 2", got)
+	}
+	if got := ca.Progress(); got != 50 {
+		t.Errorf("Progress() = %v, want 50", got)
+	}
+	if got := ca.Size(); got != 3 {
+		t.Errorf("Size() = %d, want 3", got)
+	}
+	if !ca.Has(3) || ca.Has(1) {
+		t.Error("Has reports wrong chunks")
+	}
+}
+
+func TestChunkAssemblerRejectsOutOfRange(t *testing.T) {
+	ca := NewChunkAssembler(2, 0)
+	defer ca.Close()
+
+	for _, n := range []int{-1, 2} {
+		if err := ca.Add(n, []byte("x")); err == nil {
+			t.Errorf("Add(%d) succeeded, want error", n)
+		}
+	}
+}
+
+func TestChunkAssemblerMissingChunk(t *testing.T) {
+	ca := NewChunkAssembler(3, 0)
+	defer ca.Close()
+
+	ca.Add(0, []byte("a"))
+	ca.Add(2, []byte("c"))
+	if _, err := ca.WriteTo(&bytes.Buffer{}); err == nil {
+		t.Fatal("WriteTo succeeded with a missing chunk")
+	}
+}
+
+func TestChunkAssemblerSpillToDisk(t *testing.T) {
+	ca := NewChunkAssembler(4, 5)
+
+	chunks := [][]byte{[]byte("one"), []byte("two"), []byte("three"), []byte("four")}
+	for _, n := range []int{1, 0, 3, 2} {
+		if err := ca.Add(n, chunks[n]); err != nil {
+			t.Fatalf("Add(%d): %v", n, err)
+		}
+	}
+
+	if !ca.IsSpilled() {
+		t.Fatal("expected chunks to be spilled to disk")
+	}
+	name := ca.spillFile.Name()
+
+	if data, ok := ca.Chunk(2); !ok || string(data) != "three" {
+		t.Errorf("Chunk(2) = %q, %v", data, ok)
+	}
+	var out bytes.Buffer
+	if _, err := ca.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if out.String() != "onetwothreefour" {
+		t.Errorf("assembled %q", out.String())
+	}
+
+	if err := ca.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spill file %s not removed", name)
+	}
+	if err := ca.Add(0, []byte("x")); err == nil {
+		t.Error("Add after Close succeeded")
+	}
+}
+
+func TestChunkAssemblerCopiesData(t *testing.T) {
+	ca := NewChunkAssembler(1, 0)
+	defer ca.Close()
+
+	data := []byte("abc")
+	ca.Add(0, data)
+	data[0] = 'x'
+
+	if got, _ := ca.Chunk(0); string(got) != "abc" {
+		t.Errorf("chunk changed with the caller's buffer: %q", got)
+	}
+}
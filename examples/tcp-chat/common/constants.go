@@ -12,6 +12,13 @@ const (
 	KeepAliveInterval   = 30 * time.Second
 )
 
+// Write batching, a client's messages queued within WriteFlushInterval of
+// each other are written at once, up to about WriteBatchSize bytes
+const (
+	WriteBatchSize     = 32 * 1024
+	WriteFlushInterval = time.Millisecond
+)
+
 // Message limits
 const (
 	MaxMessageSize    = 4096
@@ -19,12 +26,24 @@ const (
 	MinNicknameLength = 3
 	MaxRoomNameLength = 30
 	MinRoomNameLength = 3
+	MaxRoomMembers    = 100
+	MaxAwayLength     = 160
 	MaxFileSize       = 100 * 1024 * 1024 // 100MB
 	MaxFileNameLength = 255
 	FileChunkSize     = 8192
 	MaxScannerBuffer  = 1024 * 1024 // 1MB
 )
 
+// Decoding limits, messages exceeding them are rejected before any
+// handler sees them
+const (
+	MaxFieldLength     = 256       // Names, IDs and other short fields
+	MaxContentLength   = 64 * 1024 // Content and error text
+	MaxListLength      = 1000      // Entries in Users and error details
+	MaxMetadataEntries = 32        // Entries in file metadata
+	MaxTotalChunks     = (MaxFileSize + FileChunkSize - 1) / FileChunkSize
+)
+
 // Rate limits
 const (
 	MessagesPerSecond    = 10
@@ -32,15 +51,61 @@ const (
 	FileTransfersPerUser = 3
 )
 
+// File transfer memory, bytes of in-flight transfers the server buffers at
+// most in total and for a single user
+const (
+	TransferMemoryBudget  = 256 * 1024 * 1024 // 256MB
+	TransferMemoryPerUser = MaxFileSize
+)
+
+// Reconnect throttling, an IP connecting more than ReconnectsPerWindow times
+// within ReconnectWindow is banned for ReconnectBanBase, doubling with every
+// repeat offence up to ReconnectBanMax
+const (
+	ReconnectWindow      = 10 * time.Second
+	ReconnectsPerWindow  = 5
+	ReconnectBanBase     = 10 * time.Second
+	ReconnectBanMax      = time.Hour
+	ReconnectStrikeReset = 15 * time.Minute // Quiet period after which offences are forgotten
+)
+
+// Message deduplication, the server remembers up to DedupCacheSize message
+// IDs for DedupWindow so retransmitted messages are delivered only once
+const (
+	DedupWindow        = 10 * time.Minute
+	DedupCacheSize     = 10000
+	MaxMessageIDLength = 64
+)
+
+// Connect challenge
+const (
+	ChallengeAttempts = 3 // Wrong answers allowed before the connection is closed
+)
+
 // Timeouts
 const (
 	FileTransferTimeout = 5 * time.Minute
 	EmptyRoomTimeout    = 30 * time.Minute
+	MinRoomTTL          = time.Minute
+	MaxRoomTTL          = 24 * time.Hour
 	ShutdownTimeout     = 30 * time.Second
+	RequestTimeout      = 10 * time.Second // Deadline for handling one client message
+	PingInterval        = 30 * time.Second // How often clients ping the server
+	PongTimeout         = 75 * time.Second // Unanswered ping age after which a client reconnects
+)
+
+// Webhooks
+const (
+	WebhookQueueSize  = 1000            // Events waiting for delivery before new ones are dropped
+	WebhookWorkers    = 2               // Concurrent deliveries
+	WebhookTimeout    = 5 * time.Second // Deadline for one delivery attempt
+	WebhookAttempts   = 4               // Attempts per event before it is dropped
+	WebhookRetryDelay = time.Second     // Delay before the first retry, doubled for each further one
 )
 
 // Validation patterns
 const (
-	NicknamePattern = "^[a-zA-Z0-9_-]+$"
-	RoomNamePattern = "^[a-zA-Z0-9_\\- ]+$"
+	NicknamePattern    = "^[a-zA-Z0-9_-]+$"
+	RoomNamePattern    = "^[a-zA-Z0-9_\\- ]+$"
+	MetadataKeyPattern = "^[a-z0-9_]{1,32}$"
 )
@@ -0,0 +1,182 @@
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// At-rest encryption parameters. A sealed blob is the magic, the salt the
+// key was derived with, the nonce and the AES-256-GCM ciphertext.
+const (
+	cryptMagic      = "TCENC1"
+	cryptSaltSize   = 16
+	cryptKeySize    = 32
+	cryptIterations = 600000 // PBKDF2-SHA256 rounds
+	cryptCachedKeys = 8      // Keys of other salts kept for opening, a file is usually sealed with one
+)
+
+// EncryptedLinePrefix starts encrypted lines in append-only logs
+const EncryptedLinePrefix = "enc:"
+
+// ErrDecrypt is returned when data cannot be decrypted, usually because
+// the passphrase is wrong
+var ErrDecrypt = errors.New("decryption failed, wrong passphrase or corrupted data")
+
+// ErrNotEncrypted is returned by OpenLine for a line that isn't encrypted,
+// which in an encrypted log could have been added by anyone
+var ErrNotEncrypted = errors.New("line is not encrypted")
+
+// FileCipher encrypts files with a key derived from a passphrase. Keys are
+// derived once per salt, so sealing many records stays cheap.
+type FileCipher struct {
+	passphrase string
+	salt       []byte            // Salt of the key used for sealing
+	keys       map[string][]byte // Salt -> derived key, at most cryptCachedKeys+1 of them
+	mutex      sync.Mutex
+}
+
+// NewFileCipher creates a cipher for passphrase with a fresh salt
+func NewFileCipher(passphrase string) (*FileCipher, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	salt := make([]byte, cryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	return &FileCipher{passphrase: passphrase, salt: salt, keys: make(map[string][]byte)}, nil
+}
+
+// LoadPassphrase returns the passphrase stored in file, or the value of the
+// environment variable env when no file is given; empty means encryption is
+// off. Files are preferred as arguments are visible to other users.
+func LoadPassphrase(file, env string) (string, error) {
+	if file == "" {
+		return os.Getenv(env), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", file)
+	}
+	return passphrase, nil
+}
+
+// aead returns the cipher for the key derived with salt
+func (fc *FileCipher) aead(salt []byte) (cipher.AEAD, error) {
+	fc.mutex.Lock()
+	key, ok := fc.keys[string(salt)]
+	if !ok {
+		var err error
+		key, err = pbkdf2.Key(sha256.New, fc.passphrase, salt, cryptIterations, cryptKeySize)
+		if err != nil {
+			fc.mutex.Unlock()
+			return nil, fmt.Errorf("failed to derive key: %v", err)
+		}
+		if len(fc.keys) > cryptCachedKeys {
+			for cached := range fc.keys {
+				if cached != string(fc.salt) {
+					delete(fc.keys, cached)
+					break
+				}
+			}
+		}
+		fc.keys[string(salt)] = key
+	}
+	fc.mutex.Unlock()
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext into a self-contained blob
+func (fc *FileCipher) Seal(plaintext []byte) ([]byte, error) {
+	gcm, err := fc.aead(fc.salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	out := make([]byte, 0, len(cryptMagic)+len(fc.salt)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, cryptMagic...)
+	out = append(out, fc.salt...)
+	out = append(out, nonce...)
+	// The header is authenticated along with the content
+	return gcm.Seal(out, nonce, plaintext, out), nil
+}
+
+// Open decrypts a blob created by Seal
+func (fc *FileCipher) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) || len(data) < len(cryptMagic)+cryptSaltSize {
+		return nil, ErrDecrypt
+	}
+	salt := data[len(cryptMagic) : len(cryptMagic)+cryptSaltSize]
+	gcm, err := fc.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(cryptMagic) + cryptSaltSize + gcm.NonceSize()
+	if len(data) < headerSize+gcm.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce := data[headerSize-gcm.NonceSize() : headerSize]
+	plaintext, err := gcm.Open(nil, nonce, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// SealLine encrypts one line of an append-only log; the result is a single
+// text line without the trailing newline
+func (fc *FileCipher) SealLine(line string) (string, error) {
+	sealed, err := fc.Seal([]byte(line))
+	if err != nil {
+		return "", err
+	}
+	return EncryptedLinePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenLine decrypts a line created by SealLine. Other lines are rejected
+// with ErrNotEncrypted, accepting them would let anyone able to append to the
+// log add lines nobody can tell from the authenticated ones.
+func (fc *FileCipher) OpenLine(line string) (string, error) {
+	encoded, ok := strings.CutPrefix(line, EncryptedLinePrefix)
+	if !ok {
+		return "", ErrNotEncrypted
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	plaintext, err := fc.Open(sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether data starts like a blob created by Seal
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(cryptMagic))
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFileCipherRoundTrip(t *testing.T) {
+	fc, err := NewFileCipher("correct horse")
+	if err != nil {
+		t.Fatalf("NewFileCipher: %v", err)
+	}
+	plaintext := []byte(`{"rooms":[]}`)
+
+	sealed, err := fc.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsEncrypted(sealed) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("sealed data is not encrypted: %q", sealed)
+	}
+
+	// A cipher created later with the same passphrase reads it
+	other, _ := NewFileCipher("correct horse")
+	opened, err := other.Open(sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, %v, want %q", opened, err, plaintext)
+	}
+
+	wrong, _ := NewFileCipher("battery staple")
+	if _, err := wrong.Open(sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Open with the wrong passphrase = %v, want ErrDecrypt", err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := fc.Open(sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Open of tampered data = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestFileCipherLines(t *testing.T) {
+	fc, _ := NewFileCipher("secret")
+	line, err := fc.SealLine("[12:00:00] alice: hi")
+	if err != nil {
+		t.Fatalf("SealLine: %v", err)
+	}
+	if !strings.HasPrefix(line, EncryptedLinePrefix) || strings.ContainsAny(line, "\n ") {
+		t.Errorf("sealed line %q is not a single encoded line", line)
+	}
+	if got, err := fc.OpenLine(line); err != nil || got != "[12:00:00] alice: hi" {
+		t.Errorf("OpenLine = %q, %v", got, err)
+	}
+
+	// A plain line in an encrypted log could have been added by anyone
+	if got, err := fc.OpenLine("[11:00:00] bob: plain"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("OpenLine of a plain line = %q, %v", got, err)
+	}
+}
+
+func TestFileCipherBoundsKeys(t *testing.T) {
+	fc, _ := NewFileCipher("secret")
+	own, _ := fc.SealLine("own")
+	// Every line of a crafted log may name another salt
+	for i := range cryptCachedKeys + 3 {
+		if _, err := fc.aead(bytes.Repeat([]byte{byte(i)}, cryptSaltSize)); err != nil {
+			t.Fatalf("aead: %v", err)
+		}
+	}
+	if len(fc.keys) > cryptCachedKeys+1 {
+		t.Errorf("%d keys cached", len(fc.keys))
+	}
+	if _, ok := fc.keys[string(fc.salt)]; !ok {
+		t.Error("Expected the sealing key to stay cached")
+	}
+	if got, err := fc.OpenLine(own); err != nil || got != "own" {
+		t.Errorf("OpenLine = %q, %v", got, err)
+	}
+}
@@ -1,6 +1,10 @@
 package common
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrorType represents the type of error
 type ErrorType string
@@ -13,13 +17,14 @@ const (
 	ErrInternal     ErrorType = "INTERNAL"
 	ErrTimeout      ErrorType = "TIMEOUT"
 	ErrDuplicate    ErrorType = "DUPLICATE"
+	ErrUnavailable  ErrorType = "UNAVAILABLE"
 )
 
 // ChatError represents a custom error with context
 type ChatError struct {
-	Type    ErrorType
-	Message string
-	Details map[string]interface{}
+	Type    ErrorType              `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 // Error implements the error interface
@@ -36,6 +41,11 @@ func NewChatError(errType ErrorType, message string) *ChatError {
 	}
 }
 
+// ChatErrorf creates a chat error with a translated message
+func ChatErrorf(errType ErrorType, format string, args ...interface{}) *ChatError {
+	return NewChatError(errType, T(format, args...))
+}
+
 // WithDetail adds a detail to the error
 func (e *ChatError) WithDetail(key string, value interface{}) *ChatError {
 	e.Details[key] = value
@@ -44,8 +54,19 @@ func (e *ChatError) WithDetail(key string, value interface{}) *ChatError {
 
 // IsType checks if error is of specific type
 func IsType(err error, errType ErrorType) bool {
-	if chatErr, ok := err.(*ChatError); ok {
+	var chatErr *ChatError
+	if errors.As(err, &chatErr) {
 		return chatErr.Type == errType
 	}
 	return false
 }
+
+// RetryAfter returns how long to wait before retrying a rate limited request
+func (e *ChatError) RetryAfter() (time.Duration, bool) {
+	value, ok := e.Details["retry_after"].(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	return d, err == nil
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// File kinds, the broad category of a file derived from its content type
+const (
+	KindImage    = "image"
+	KindAudio    = "audio"
+	KindVideo    = "video"
+	KindDocument = "document"
+	KindOther    = "other"
+)
+
+// Well-known file metadata keys
+const (
+	MetaVoiceMemo = "voice_memo" // "true" for recorded voice messages
+	MetaDuration  = "duration"   // Playing time of audio and video, e.g. "1m30s"
+	MetaCaption   = "caption"    // Text shown with the file
+)
+
+// extensionTypes covers media formats missing from the built-in table of
+// the mime package on systems without a mime.types file
+var extensionTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/opus",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// documentTypes are content types outside text/ treated as documents
+var documentTypes = map[string]bool{
+	"application/pdf":                                true,
+	"application/msword":                             true,
+	"application/rtf":                                true,
+	"application/vnd.oasis.opendocument.text":        true,
+	"application/vnd.oasis.opendocument.spreadsheet": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       true,
+	"application/vnd.ms-excel": true,
+	"application/json":         true,
+}
+
+// DetectContentType guesses the MIME type of a file from its name and the
+// first bytes of its content, the extension taking precedence
+func DetectContentType(filename string, head []byte) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if byExt, ok := extensionTypes[ext]; ok {
+		return byExt
+	}
+	if byExt := mime.TypeByExtension(ext); byExt != "" {
+		return byExt
+	}
+	if len(head) == 0 {
+		return "application/octet-stream"
+	}
+	return http.DetectContentType(head)
+}
+
+// FileKind returns the kind of a file with the given content type
+func FileKind(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return KindOther
+	}
+	major, _, ok := strings.Cut(mediaType, "/")
+	switch {
+	case !ok:
+		return KindOther
+	case major == "image":
+		return KindImage
+	case major == "audio":
+		return KindAudio
+	case major == "video":
+		return KindVideo
+	case major == "text" || documentTypes[mediaType]:
+		return KindDocument
+	}
+	return KindOther
+}
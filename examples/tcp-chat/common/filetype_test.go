@@ -0,0 +1,35 @@
+package common
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		filename string
+		head     []byte
+		kind     string
+	}{
+		{"memo.ogg", nil, KindAudio},
+		{"photo.JPG", nil, KindImage},
+		{"clip.mp4", nil, KindVideo},
+		{"report.pdf", nil, KindDocument},
+		{"notes.txt", nil, KindDocument},
+		{"archive.xyz", nil, KindOther},
+		{"noext", []byte("\x89PNG\r\n\x1a\n"), KindImage},
+		{"noext", []byte("plain words"), KindDocument},
+	}
+
+	for _, tt := range tests {
+		contentType := DetectContentType(tt.filename, tt.head)
+		if kind := FileKind(contentType); kind != tt.kind {
+			t.Errorf("%s: kind of %q is %s, want %s", tt.filename, contentType, kind, tt.kind)
+		}
+	}
+}
+
+func TestFileKindRejectsMalformedTypes(t *testing.T) {
+	for _, contentType := range []string{"", "audio", "image/png; ===="} {
+		if kind := FileKind(contentType); kind != KindOther {
+			t.Errorf("FileKind(%q) = %s, want %s", contentType, kind, KindOther)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Supported languages
+const (
+	LangEnglish     = "en"
+	LangPolish      = "pl"
+	DefaultLanguage = LangEnglish
+)
+
+// catalogs maps a language to translations keyed by the English format
+// string, so English needs no catalog and missing entries fall back to it
+var catalogs = map[string]map[string]string{
+	LangEnglish: {},
+	LangPolish:  polishCatalog,
+}
+
+var (
+	language     = DefaultLanguage
+	languageLock sync.RWMutex
+)
+
+// SetLanguage selects the language of user-facing strings
+func SetLanguage(lang string) error {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if _, ok := catalogs[lang]; !ok {
+		return fmt.Errorf("unsupported language %q (available: %s)", lang, strings.Join(Languages(), ", "))
+	}
+
+	languageLock.Lock()
+	language = lang
+	languageLock.Unlock()
+	return nil
+}
+
+// Language returns the selected language
+func Language() string {
+	languageLock.RLock()
+	defer languageLock.RUnlock()
+	return language
+}
+
+// Languages returns the supported language codes
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// T translates an English format string into the selected language and
+// formats it with args
+func T(format string, args ...interface{}) string {
+	languageLock.RLock()
+	if translated, ok := catalogs[language][format]; ok {
+		format = translated
+	}
+	languageLock.RUnlock()
+
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Errorf returns an error with a translated message
+func Errorf(format string, args ...interface{}) error {
+	return errors.New(T(format, args...))
+}
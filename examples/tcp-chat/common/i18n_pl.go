@@ -0,0 +1,301 @@
+package common
+
+// polishCatalog translates user-facing strings into Polish
+var polishCatalog = map[string]string{
+	"server has reached maximum connection limit (%d)":                     "serwer osiągnął limit połączeń (%d)",
+	"invalid address format":                                               "nieprawidłowy format adresu",
+	"IP %s has reached maximum connection limit (%d)":                      "adres IP %s osiągnął limit połączeń (%d)",
+	"message rate limit exceeded (%d messages per second)":                 "przekroczono limit wiadomości (%d na sekundę)",
+	"room creation limit exceeded (%d rooms per user)":                     "przekroczono limit tworzenia pokoi (%d na użytkownika)",
+	"file transfer limit exceeded (%d concurrent transfers per user)":      "przekroczono limit przesyłania plików (%d jednocześnie na użytkownika)",
+	"File transfer timed out: %s":                                          "Przekroczono czas przesyłania pliku: %s",
+	"nickname '%s' is already taken":                                       "pseudonim '%s' jest już zajęty",
+	"Welcome to the chat, %s!":                                             "Witaj na czacie, %s!",
+	"%s has joined the chat":                                               "%s dołączył(a) do czatu",
+	"%s has disconnected from the room":                                    "%s rozłączył(a) się z pokojem",
+	"%s has left the chat":                                                 "%s opuścił(a) czat",
+	"Connected successfully":                                               "Połączono pomyślnie",
+	"You are not a member of this room":                                    "Nie jesteś członkiem tego pokoju",
+	"Room not found":                                                       "Nie znaleziono pokoju",
+	"User %s not found":                                                    "Nie znaleziono użytkownika %s",
+	"%s is now %s":                                                         "%s ma teraz status %s",
+	"Room '%s' created successfully":                                       "Pokój '%s' został utworzony",
+	"%s has joined the room":                                               "%s dołączył(a) do pokoju",
+	"Joined room '%s'":                                                     "Dołączono do pokoju '%s'",
+	"%s has left the room":                                                 "%s opuścił(a) pokój",
+	"%s members: %s":                                                       "%s - członkowie: %s",
+	"Only the room creator can kick members":                               "Tylko twórca pokoju może wyrzucać członków",
+	"%s is not a member of this room":                                      "%s nie jest członkiem tego pokoju",
+	"You cannot kick yourself":                                             "Nie możesz wyrzucić samego siebie",
+	"You have been kicked from room '%s'":                                  "Zostałeś(-aś) wyrzucony(-a) z pokoju '%s'",
+	"%s has been kicked from the room by %s":                               "%s został(a) wyrzucony(-a) z pokoju przez %s",
+	"%s has been kicked from the room":                                     "%s został(a) wyrzucony(-a) z pokoju",
+	"Only the room creator can delete the room":                            "Tylko twórca pokoju może go usunąć",
+	"Room '%s' has been deleted by the creator":                            "Pokój '%s' został usunięty przez twórcę",
+	"Room '%s' has been deleted":                                           "Pokój '%s' został usunięty",
+	"You must be a member to set the room topic":                           "Musisz być członkiem pokoju, aby ustawić temat",
+	"%s set the room topic to: %s":                                         "%s ustawił(a) temat pokoju: %s",
+	"Room topic updated":                                                   "Temat pokoju zaktualizowany",
+	"%s invited you to join room '%s'":                                     "%s zaprasza cię do pokoju '%s'",
+	"Invitation sent to %s":                                                "Wysłano zaproszenie do %s",
+	"Room no longer exists":                                                "Pokój już nie istnieje",
+	"Invitation declined":                                                  "Zaproszenie odrzucone",
+	"User %s is not accepting files from you":                              "Użytkownik %s nie przyjmuje od ciebie plików",
+	"File transfer not found":                                              "Nie znaleziono transferu pliku",
+	"You are not part of this file transfer":                               "Nie uczestniczysz w tym transferze pliku",
+	"Invalid file transfer action: %s":                                     "Nieprawidłowa operacja na transferze pliku: %s",
+	"Server is shutting down":                                              "Serwer jest wyłączany",
+	"nickname must be at least %d characters long":                         "pseudonim musi mieć co najmniej %d znaki",
+	"nickname cannot exceed %d characters":                                 "pseudonim nie może przekraczać %d znaków",
+	"nickname can only contain letters, numbers, underscores, and hyphens": "pseudonim może zawierać tylko litery, cyfry, podkreślenia i myślniki",
+	"room name must be at least %d characters long":                        "nazwa pokoju musi mieć co najmniej %d znaki",
+	"room name cannot exceed %d characters":                                "nazwa pokoju nie może przekraczać %d znaków",
+	"room name can only contain letters, numbers, underscores, hyphens, and spaces": "nazwa pokoju może zawierać tylko litery, cyfry, podkreślenia, myślniki i spacje",
+	"message cannot be empty":                                                "wiadomość nie może być pusta",
+	"message cannot exceed %d characters":                                    "wiadomość nie może przekraczać %d znaków",
+	"filename cannot be empty":                                               "nazwa pliku nie może być pusta",
+	"filename cannot exceed %d characters":                                   "nazwa pliku nie może przekraczać %d znaków",
+	"filename cannot contain path separators or parent directory references": "nazwa pliku nie może zawierać separatorów ścieżki ani odwołań do katalogu nadrzędnego",
+	"hidden files are not allowed":                                           "pliki ukryte są niedozwolone",
+	"file size must be positive":                                             "rozmiar pliku musi być dodatni",
+	"file size cannot exceed %d bytes":                                       "rozmiar pliku nie może przekraczać %d bajtów",
+	"   TCP Chat Client":                                                     "   Klient czatu TCP",
+	"Connected to %s as: %s\n":                                               "Połączono z %s jako: %s\n",
+	"\nCommands:":                                                            "\nPolecenia:",
+	"\nType messages without '/' to broadcast to all users":                  "\nWpisz wiadomość bez '/', aby wysłać ją do wszystkich",
+	"Not connected. Use /connect <address> [nick]":                           "Brak połączenia. Użyj /connect <adres> [nick]",
+	"Usage: /connect <address> [nickname]":                                   "Użycie: /connect <adres> [pseudonim]",
+	"Usage: /connect <address> <nickname>":                                   "Użycie: /connect <adres> <pseudonim>",
+	"Error: %v\n":                                                            "Błąd: %v\n",
+	"Connecting to %s as %s...\n":                                            "Łączenie z %s jako %s...\n",
+	"Usage: /switch <name|number>":                                           "Użycie: /switch <nazwa|numer>",
+	"No connection named %s\n":                                               "Brak połączenia o nazwie %s\n",
+	"Switched to %s\n":                                                       "Przełączono na %s\n",
+	"No such connection":                                                     "Nie ma takiego połączenia",
+	"Disconnected from %s\n":                                                 "Rozłączono z %s\n",
+	"Goodbye!":                                                               "Do widzenia!",
+	"Usage: /msg <nickname> <message>":                                       "Użycie: /msg <pseudonim> <wiadomość>",
+	"Usage: /file <nickname> <path> [path...]":                               "Użycie: /file <pseudonim> <ścieżka> [ścieżka...]",
+	"Error sending file: %v\n":                                               "Błąd wysyłania pliku: %v\n",
+	"Sending file to %s...\n":                                                "Wysyłanie pliku do %s...\n",
+	"Error sending files: %v\n":                                              "Błąd wysyłania plików: %v\n",
+	"Sending %d file(s) to %s (%s)...\n":                                     "Wysyłanie plików (%d) do %s (%s)...\n",
+	"Usage: /status <active|busy|away|invisible>":                            "Użycie: /status <active|busy|away|invisible>",
+	"Invalid status. Use: active, busy, away or invisible":                   "Nieprawidłowy status. Dostępne: active, busy, away, invisible",
+	"Status changed to: %s\n":                                                "Zmieniono status na: %s\n",
+	"Usage: /transfer <pause|resume|cancel|rename|overwrite|discard> <fileID>": "Użycie: /transfer <pause|resume|cancel|rename|overwrite|discard> <id_pliku>",
+	"Transfer %s: %s\n":                                                   "Transfer %s: %s\n",
+	"\n=== Offline Queue ===":                                             "\n=== Kolejka offline ===",
+	"  No queued messages":                                                "  Brak wiadomości w kolejce",
+	"Cannot flush queue: %v\n":                                            "Nie można wysłać kolejki: %v\n",
+	"Sent %d queued message(s)\n":                                         "Wysłano wiadomości z kolejki: %d\n",
+	"Discarded %d queued message(s)\n":                                    "Odrzucono wiadomości z kolejki: %d\n",
+	"Usage: /export <room_id|nick|broadcast> [txt|json|html]":             "Użycie: /export <id_pokoju|nick|broadcast> [txt|json|html]",
+	"Error exporting history: %v\n":                                       "Błąd eksportu historii: %v\n",
+	"History exported to %s\n":                                            "Historię wyeksportowano do %s\n",
+	"Unknown command: %s\n":                                               "Nieznane polecenie: %s\n",
+	"Usage: /room invite <room_id> <nickname>":                            "Użycie: /room invite <id_pokoju> <pseudonim>",
+	"Usage: /room accept <room_id>":                                       "Użycie: /room accept <id_pokoju>",
+	"Accepted invitation to room %s\n":                                    "Przyjęto zaproszenie do pokoju %s\n",
+	"Usage: /room decline <room_id>":                                      "Użycie: /room decline <id_pokoju>",
+	"Declined invitation to room %s\n":                                    "Odrzucono zaproszenie do pokoju %s\n",
+	"Usage: /room msg <room_id> <message>":                                "Użycie: /room msg <id_pokoju> <wiadomość>",
+	"Usage: /room leave <room_id>":                                        "Użycie: /room leave <id_pokoju>",
+	"Usage: /room members <room_id>":                                      "Użycie: /room members <id_pokoju>",
+	"Usage: /room kick <room_id> <nickname>":                              "Użycie: /room kick <id_pokoju> <pseudonim>",
+	"Usage: /room delete <room_id>":                                       "Użycie: /room delete <id_pokoju>",
+	"Usage: /room topic <room_id> <description>":                          "Użycie: /room topic <id_pokoju> <opis>",
+	"Unknown room command: %s\n":                                          "Nieznane polecenie pokoju: %s\n",
+	"[%s] [Room: %s] %s: %s\n":                                            "[%s] [Pokój: %s] %s: %s\n",
+	"[%s] [Private] %s: %s\n":                                             "[%s] [Prywatnie] %s: %s\n",
+	"[%s] Joined room '%s' (ID: %s)\n":                                    "[%s] Dołączono do pokoju '%s' (ID: %s)\n",
+	"[%s] Left room '%s'\n":                                               "[%s] Opuszczono pokój '%s'\n",
+	"Type '/room accept %s' to accept or '/room decline %s' to decline\n": "Wpisz '/room accept %s', aby przyjąć, lub '/room decline %s', aby odrzucić\n",
+	"[%s] %s is sending you file: %s (%s)\n":                              "[%s] %s wysyła ci plik: %s (%s)\n",
+	"\rFile transfer: %s - %s":                                            "\rPrzesyłanie pliku: %s - %s",
+	"\n[%s] File received: %s\n":                                          "\n[%s] Odebrano plik: %s\n",
+	"Error saving file: %v\n":                                             "Błąd zapisu pliku: %v\n",
+	"File saved to %s\n":                                                  "Plik zapisano w %s\n",
+	"[%s] Error: %s\n":                                                    "[%s] Błąd: %s\n",
+	"\n=== Connections ===":                                               "\n=== Połączenia ===",
+	"  No connections":                                                    "  Brak połączeń",
+	"\n=== Online Users ===":                                              "\n=== Użytkownicy online ===",
+	"\n=== Your Rooms ===":                                                "\n=== Twoje pokoje ===",
+	"  No rooms joined":                                                   "  Nie należysz do żadnego pokoju",
+	"\n=== File Transfers ===":                                            "\n=== Transfery plików ===",
+	"  No active transfers":                                               "  Brak aktywnych transferów",
+	"Usage: %s <nickname>\n":                                              "Użycie: %s <pseudonim>\n",
+	"  (none)":                                                            "  (brak)",
+	"Show help":                                                           "Pokaż pomoc",
+	"List online users":                                                   "Lista użytkowników online",
+	"Send private message":                                                "Wyślij prywatną wiadomość",
+	"Send files or directories (quote paths with spaces)":                 "Wyślij pliki lub katalogi (ścieżki ze spacjami w cudzysłowie)",
+	"Change status":                                                       "Zmień status",
+	"Invite to room":                                                      "Zaproś do pokoju",
+	"Accept room invitation":                                              "Przyjmij zaproszenie do pokoju",
+	"Decline room invitation":                                             "Odrzuć zaproszenie do pokoju",
+	"Message to room":                                                     "Wiadomość do pokoju",
+	"List your rooms":                                                     "Lista twoich pokoi",
+	"Leave a room":                                                        "Opuść pokój",
+	"Show file transfers":                                                 "Pokaż transfery plików",
+	"Ignore a user or list ignored users":                                 "Ignoruj użytkownika lub pokaż ignorowanych",
+	"Stop ignoring a user":                                                "Przestań ignorować użytkownika",
+	"Hide a user in broadcasts and rooms or list muted users":             "Ukryj użytkownika w kanale ogólnym i pokojach lub pokaż wyciszonych",
+	"Unmute a user":                                                       "Wyłącz wyciszenie użytkownika",
+	"Manage a file transfer":                                              "Zarządzaj transferem pliku",
+	"Export history":                                                      "Eksportuj historię",
+	"Connect to another server":                                           "Połącz z kolejnym serwerem",
+	"Switch active server":                                                "Przełącz aktywny serwer",
+	"List server connections":                                             "Lista połączeń z serwerami",
+	"Close a server connection":                                           "Zamknij połączenie z serwerem",
+	"Show messages queued while offline":                                  "Pokaż wiadomości zakolejkowane offline",
+	"Send messages queued while offline":                                  "Wyślij wiadomości zakolejkowane offline",
+	"Drop messages queued while offline":                                  "Odrzuć wiadomości zakolejkowane offline",
+	"Exit":                                                                "Wyjście",
+	"Error: Nickname is required":                                         "Błąd: pseudonim jest wymagany",
+	"Usage: ./client -nick <your_nickname> [-server <address>]":           "Użycie: ./client -nick <pseudonim> [-server <adres>]",
+	"\nShutting down...":                                                  "\nZamykanie...",
+	"Connecting to %s...\n":                                               "Łączenie z %s...\n",
+	"%s paused the transfer of %s":                                        "%s wstrzymał(a) przesyłanie %s",
+	"%s resumed the transfer of %s":                                       "%s wznowił(a) przesyłanie %s",
+	"%s cancelled the transfer of %s":                                     "%s anulował(a) przesyłanie %s",
+	"File transfer error: %s - %v":                                        "Błąd przesyłania pliku: %s - %v",
+	"Batch to %s finished: %d of %d files sent (%s in %v)":                "Paczka do %s zakończona: wysłano %d z %d plików (%s w %v)",
+	"Sent %d queued message(s)":                                           "Wysłano wiadomości z kolejki: %d",
+	"%d message(s) were queued while offline: /flush to send them or /discard to drop them": "Wiadomości zakolejkowane offline: %d. /flush wysyła je, /discard odrzuca",
+	"File transfer cancelled: %s":                             "Anulowano przesyłanie pliku: %s",
+	"File transfer complete: %s (%.2f MB/s)":                  "Zakończono przesyłanie pliku: %s (%.2f MB/s)",
+	"File transfer error: %s - %s":                            "Błąd przesyłania pliku: %s - %s",
+	"Connection lost, reconnecting... (%d message(s) queued)": "Utracono połączenie, ponowne łączenie... (w kolejce: %d)",
+	"Ignored users": "Ignorowani użytkownicy",
+	"Muted users":   "Wyciszeni użytkownicy",
+	"The server is going down for maintenance at %s (in %v). New connections are refused.": "Serwer zostanie wyłączony w celu konserwacji o %s (za %v). Nowe połączenia są odrzucane.",
+	"The server shuts down in %v":                                        "Serwer zostanie wyłączony za %v",
+	"The server is in maintenance mode until %s, please try again later": "Serwer jest w trybie konserwacji do %s, spróbuj ponownie później",
+	"too many connection attempts, try again in %v":                      "zbyt wiele prób połączenia, spróbuj ponownie za %v",
+	"Enter the access token published by the server operator":            "Podaj token dostępu udostępniony przez operatora serwera",
+	"What is %d + %d?":                                 "Ile to jest %d + %d?",
+	"What is %d times %d?":                             "Ile to jest %d razy %d?",
+	"No challenge is pending":                          "Brak oczekującego pytania weryfikacyjnego",
+	"Too many wrong answers":                           "Zbyt wiele błędnych odpowiedzi",
+	"Wrong answer, %d attempt(s) left":                 "Błędna odpowiedź, pozostało prób: %d",
+	"answer the challenge before sending messages":     "odpowiedz na pytanie weryfikacyjne przed wysyłaniem wiadomości",
+	"Answer the server's connect challenge":            "Odpowiedz na pytanie weryfikacyjne serwera",
+	"Usage: /answer <text>":                            "Użycie: /answer <tekst>",
+	"[%s] Server asks: %s\n":                           "[%s] Serwer pyta: %s\n",
+	"Reply with /answer <text> to finish connecting\n": "Odpowiedz poleceniem /answer <tekst>, aby dokończyć łączenie\n",
+	"%s is back in the room":                           "%s wrócił(a) do pokoju",
+	"room TTL must be between %v and %v":               "czas życia pokoju musi wynosić od %v do %v",
+	"[ephemeral, expires in %v]":                       "[tymczasowy, wygasa za %v]",
+	"[ephemeral]":                                      "[tymczasowy]",
+	"Ephemeral room '%s' created, it is deleted when the last member leaves":          "Utworzono tymczasowy pokój '%s', zostanie usunięty, gdy wyjdzie ostatni członek",
+	"Ephemeral room '%s' created, it is deleted in %v or when the last member leaves": "Utworzono tymczasowy pokój '%s', zostanie usunięty za %v lub gdy wyjdzie ostatni członek",
+	"Room '%s' has expired":                                                                  "Pokój '%s' wygasł",
+	"Usage: /room create <name> [ephemeral] [ttl=<duration>]":                                "Użycie: /room create <nazwa> [ephemeral] [ttl=<czas>]",
+	"Invalid TTL: %v\n":                                                                      "Nieprawidłowy czas życia: %v\n",
+	"Create private room, ephemeral rooms vanish when empty or after ttl":                    "Utwórz prywatny pokój, pokoje tymczasowe znikają, gdy są puste lub po czasie ttl",
+	"room '%s' is full (%d members)":                                                         "pokój '%s' jest pełny (członków: %d)",
+	"room '%s' is invite-only":                                                               "do pokoju '%s' można dołączyć tylko z zaproszeniem",
+	"wrong password for room '%s'":                                                           "błędne hasło do pokoju '%s'",
+	"no room settings given":                                                                 "nie podano ustawień pokoju",
+	"invalid room setting %q, use key=value":                                                 "nieprawidłowe ustawienie pokoju %q, użyj klucz=wartość",
+	"unknown join policy: %s (use open, invite or password)":                                 "nieznana zasada dołączania: %s (użyj open, invite lub password)",
+	"max must be a number between 0 (no limit) and %d":                                       "max musi być liczbą od 0 (bez limitu) do %d",
+	"unknown room setting: %s (use policy, password or max)":                                 "nieznane ustawienie pokoju: %s (użyj policy, password lub max)",
+	"the password policy requires password=<secret>":                                         "zasada password wymaga ustawienia password=<hasło>",
+	"Only the room creator can change room settings":                                         "Tylko twórca pokoju może zmieniać jego ustawienia",
+	"%s changed the room settings: %s":                                                       "%s zmienił(a) ustawienia pokoju: %s",
+	"Room '%s' is full":                                                                      "Pokój '%s' jest pełny",
+	"Usage: /room join <room_id> [password]":                                                 "Użycie: /room join <id_pokoju> [hasło]",
+	"Usage: /room set <room_id> policy=<open|invite|password> [password=<secret>] [max=<n>]": "Użycie: /room set <id_pokoju> policy=<open|invite|password> [password=<hasło>] [max=<n>]",
+	"Join a room by ID":                                                                      "Dołącz do pokoju po ID",
+	"Set room policy (open, invite, password), password and max members":                     "Ustaw zasadę dołączania (open, invite, password), hasło i limit członków",
+	"Uptime: %v":          "Czas działania: %v",
+	"Connected users: %d": "Połączeni użytkownicy: %d",
+	"Rooms: %d":           "Pokoje: %d",
+	"Messages routed: %d": "Przekazane wiadomości: %d",
+	"Bytes transferred: %s received, %s sent":                     "Przesłane dane: odebrano %s, wysłano %s",
+	"Files transferred: %d":                                       "Przesłane pliki: %d",
+	"Memory: %s in use, %s reserved":                              "Pamięć: w użyciu %s, zarezerwowano %s",
+	"Show server statistics":                                      "Pokaż statystyki serwera",
+	"[%s] Server statistics:\n":                                   "[%s] Statystyki serwera:\n",
+	"Goroutines: %d":                                              "Gorutyny: %d",
+	"You can try again in %v\n":                                   "Możesz spróbować ponownie za %v\n",
+	"Use /users or /room list to see who and what is available\n": "Użyj /users lub /room list, aby zobaczyć dostępnych użytkowników i pokoje\n",
+	"Reconnect with a different nickname\n":                       "Połącz się ponownie z innym pseudonimem\n",
+	"Duplicates dropped: %d":                                      "Odrzucone duplikaty: %d",
+	"message ID cannot exceed %d characters":                      "identyfikator wiadomości nie może przekraczać %d znaków",
+	"unknown status: %s (use ACTIVE, BUSY, AWAY or INVISIBLE)":    "nieznany status: %s (użyj ACTIVE, BUSY, AWAY lub INVISIBLE)",
+	"[%s] %s is offline\n":                                        "[%s] %s jest niedostępny\n",
+	"[%s] %s is %s\n":                                             "[%s] %s ma status %s\n",
+	"Check if a user is available":                                "Sprawdź, czy użytkownik jest dostępny",
+	"Usage: /check <nick>":                                        "Użycie: /check <nick>",
+	"message exceeds %d bytes":                                    "wiadomość przekracza %d bajtów",
+	"malformed message: %v":                                       "nieprawidłowa wiadomość: %v",
+	"field %s is required":                                        "pole %s jest wymagane",
+	"field %s must be between %v and %v":                          "pole %s musi wynosić od %v do %v",
+	"field %s exceeds %v characters":                              "pole %s przekracza %v znaków",
+	"field %s exceeds %v bytes":                                   "pole %s przekracza %v bajtów",
+	"field %s exceeds %v entries":                                 "pole %s przekracza %v pozycji",
+	"field %s needs at least %v characters":                       "pole %s wymaga co najmniej %v znaków",
+	"field %s needs at least %v bytes":                            "pole %s wymaga co najmniej %v bajtów",
+	"field %s needs at least %v entries":                          "pole %s wymaga co najmniej %v pozycji",
+	"field %s does not match %s":                                  "pole %s nie pasuje do %s",
+	"chunk number %d out of range":                                "numer fragmentu %d poza zakresem",
+	"a file of %d bytes must be sent in %d chunks, not %d":        "plik o rozmiarze %d bajtów musi być wysłany w %d fragmentach, a nie %d",
+	"your file transfers in progress cannot exceed %s, wait for them to finish": "twoje trwające transfery plików nie mogą przekraczać %s, poczekaj na ich zakończenie",
+	"the server is busy with other file transfers, try again later":             "serwer jest zajęty innymi transferami plików, spróbuj ponownie później",
+	"file chunks exceed the announced size of %s":                               "fragmenty pliku przekraczają zapowiedziany rozmiar %s",
+	"Transfer memory: %s of %s reserved":                                        "Pamięć transferów: zarezerwowano %s z %s",
+	"invalid API token":                                                         "nieprawidłowy token API",
+	"invalid request body: %v":                                                  "nieprawidłowa treść żądania: %v",
+	"Nickname %s is used by a connected user":                                   "Pseudonim %s jest używany przez połączonego użytkownika",
+	"Pong from server: %v":                                                      "Odpowiedź serwera: %v",
+	"Server did not answer pings for %v, reconnecting...":                       "Serwer nie odpowiada na pingi od %v, ponowne łączenie...",
+	"Measure the round trip time to the server":                                 "Zmierz czas odpowiedzi serwera",
+	"Show or set when a room highlights messages and rings the bell":            "Pokaż lub ustaw, kiedy pokój wyróżnia wiadomości i wydaje dźwięk",
+	"Usage: /room notify <room_id> [all|mentions|none]":                         "Użycie: /room notify <room_id> [all|mentions|none]",
+	"Notifications for %s: %s\n":                                                "Powiadomienia dla %s: %s\n",
+	"Notifications for %s set to %s\n":                                          "Powiadomienia dla %s ustawione na %s\n",
+
+	// Away messages
+	"%s is away":            "%s jest nieobecny",
+	"%s is away: %s":        "%s jest nieobecny: %s",
+	"[%s] %s is away: %s\n": "[%s] %s jest nieobecny: %s\n",
+	"away message cannot exceed %d characters":       "wiadomość o nieobecności nie może przekraczać %d znaków",
+	"away message must be a single line":             "wiadomość o nieobecności musi mieścić się w jednej linii",
+	"Away message cannot exceed %d characters\n":     "Wiadomość o nieobecności nie może przekraczać %d znaków\n",
+	"You are no longer away":                         "Nie jesteś już nieobecny",
+	"You are away: %s\n":                             "Jesteś nieobecny: %s\n",
+	"Set yourself away, without a message come back": "Ustaw nieobecność, bez wiadomości wróć",
+
+	// Message formatting
+	"Messages are sent as %s\n":       "Wiadomości są wysyłane jako %s\n",
+	"Usage: /format [plain|markdown]": "Użycie: /format [plain|markdown]",
+	"Show or set the format of your messages: **bold**, *italic*, `code`, [text](url)": "Pokaż lub ustaw format wiadomości: **pogrubienie**, *kursywa*, `kod`, [tekst](url)",
+	"unknown message format: %s (use markdown or leave empty)":                         "nieznany format wiadomości: %s (użyj markdown lub pozostaw puste)",
+
+	// File types
+	"Usage: /memo <nickname> <audio file>":       "Użycie: /memo <nick> <plik audio>",
+	"Sending voice memo to %s...\n":              "Wysyłanie notatki głosowej do %s...\n",
+	"Send a recorded audio file as a voice memo": "Wyślij nagranie jako notatkę głosową",
+	"invalid content type: %s":                   "nieprawidłowy typ zawartości: %s",
+	"invalid metadata key: %q":                   "nieprawidłowy klucz metadanych: %q",
+	"metadata %s must be a single line":          "metadane %s muszą mieścić się w jednej linii",
+
+	// Downloads
+	"%s already exists, use /transfer <rename|overwrite|discard> %s\n": "%s już istnieje, użyj /transfer <rename|overwrite|discard> %s\n",
+	"[%s] Declined file %s from %s: %v\n":                              "[%s] Odrzucono plik %s od %s: %v\n",
+	"\n=== Downloads ===":                                              "\n=== Pobrane pliki ===",
+	"  Directory: %s\n":                                                "  Katalog: %s\n",
+	"  On name collision: %s\n":                                        "  Przy konflikcie nazw: %s\n",
+	"  Disk usage unknown: %v\n":                                       "  Nieznane zajęcie dysku: %v\n",
+	"  Used: %s of %s\n":                                               "  Zajęte: %s z %s\n",
+	"  Used: %s, no quota\n":                                           "  Zajęte: %s, bez limitu\n",
+	"Save or drop a received file whose name is taken":                 "Zapisz lub odrzuć odebrany plik o zajętej nazwie",
+	"Show the download directory and disk usage":                       "Pokaż katalog pobierania i zajęcie dysku",
+
+	// At-rest encryption
+	"Error: reading a chat log needs its passphrase": "Błąd: odczyt dziennika rozmów wymaga hasła",
+	"passphrase cannot be empty":                     "hasło nie może być puste",
+}
@@ -1,9 +1,12 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,11 +30,54 @@ var logLevelNames = map[LogLevel]string{
 	LogFatal: "FATAL",
 }
 
-// Logger provides structured logging
+// LogFormat selects how log lines are written
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json"
+)
+
+// LogConfig configures the global logger
+type LogConfig struct {
+	Level      LogLevel
+	Format     LogFormat
+	Filename   string        // Log file, rotated according to the limits below
+	MaxSize    int64         // Rotate when the file exceeds this many bytes, 0 disables
+	MaxAge     time.Duration // Rotate when the file is older than this, 0 disables
+	MaxBackups int           // Rotated files to keep, 0 keeps all
+	Output     io.Writer     // Custom sink used instead of Filename when set
+
+	// ComponentLevels overrides Level for loggers created with Component
+	ComponentLevels map[string]LogLevel
+}
+
+// Field is a key/value pair attached to log messages
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates a log field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger provides structured logging. Loggers derived with With or
+// Component share the output, levels and metrics of their parent.
 type Logger struct {
+	sink      *logSink
+	component string
+	fields    []Field
+}
+
+// logSink is the output shared by a logger and all loggers derived from it
+type logSink struct {
 	level   LogLevel
-	file    *os.File
-	logger  *log.Logger
+	levels  map[string]LogLevel // Per-component overrides
+	format  LogFormat
+	out     io.Writer
+	closer  io.Closer
 	mu      sync.Mutex
 	metrics *LogMetrics
 }
@@ -46,60 +92,200 @@ type LogMetrics struct {
 // GlobalLogger is the default logger instance
 var GlobalLogger *Logger
 
-// InitLogger initializes the global logger
+// InitLogger initializes the global logger writing text to a file
 func InitLogger(filename string, level LogLevel) error {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, GetFileMode())
+	return InitLoggerWithConfig(LogConfig{
+		Level:      level,
+		Format:     LogFormatText,
+		Filename:   filename,
+		MaxSize:    DefaultLogMaxSize,
+		MaxBackups: DefaultLogMaxBackups,
+	})
+}
+
+// InitLoggerWithConfig initializes the global logger from a configuration
+func InitLoggerWithConfig(config LogConfig) error {
+	logger, err := NewLogger(config)
 	if err != nil {
 		return err
 	}
+	GlobalLogger = logger
+	return nil
+}
+
+// NewLogger creates a logger writing to config.Output or a rotating file
+func NewLogger(config LogConfig) (*Logger, error) {
+	switch config.Format {
+	case "":
+		config.Format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", config.Format)
+	}
 
-	GlobalLogger = &Logger{
-		level:  level,
-		file:   file,
-		logger: log.New(file, "", 0),
+	sink := &logSink{
+		level:  config.Level,
+		levels: make(map[string]LogLevel),
+		format: config.Format,
+		out:    config.Output,
 		metrics: &LogMetrics{
 			counts: make(map[LogLevel]int64),
 		},
 	}
+	for component, level := range config.ComponentLevels {
+		sink.levels[component] = level
+	}
 
-	return nil
+	if sink.out == nil {
+		file, err := NewRotatingFile(config.Filename, config.MaxSize, config.MaxAge, config.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sink.out = file
+		sink.closer = file
+	}
+
+	return &Logger{sink: sink}, nil
 }
 
 // Close closes the log file
 func (l *Logger) Close() error {
-	if l.file != nil {
-		return l.file.Close()
+	if l != nil && l.sink.closer != nil {
+		return l.sink.closer.Close()
 	}
 	return nil
 }
 
+// With returns a logger that attaches fields to every message
+func (l *Logger) With(fields ...Field) *Logger {
+	if l == nil {
+		return nil
+	}
+	return &Logger{
+		sink:      l.sink,
+		component: l.component,
+		fields:    append(append([]Field(nil), l.fields...), fields...),
+	}
+}
+
+// Component returns a logger for a named component; its level can be set
+// separately with SetComponentLevel
+func (l *Logger) Component(name string) *Logger {
+	if l == nil {
+		return nil
+	}
+	return &Logger{sink: l.sink, component: name, fields: l.fields}
+}
+
+// SetComponentLevel sets the minimum level logged by a component
+func (l *Logger) SetComponentLevel(component string, level LogLevel) {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	l.sink.levels[component] = level
+}
+
+// enabled reports whether a message of the given level would be written
+func (l *Logger) enabled(level LogLevel) bool {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	if componentLevel, ok := l.sink.levels[l.component]; ok {
+		return level >= componentLevel
+	}
+	return level >= l.sink.level
+}
+
+// GetLogger returns a component logger of the global logger, or nil when
+// logging is not initialized; nil loggers discard messages
+func GetLogger(component string) *Logger {
+	return GlobalLogger.Component(component)
+}
+
+// ParseLogLevel converts a level name such as "debug" into a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return LogInfo, fmt.Errorf("unknown log level: %s", name)
+}
+
+// ParseComponentLevels parses a list like "server=debug,ratelimit=warn"
+func ParseComponentLevels(spec string) (map[string]LogLevel, error) {
+	levels := make(map[string]LogLevel)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		component, levelName, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid component level %q, expected component=level", entry)
+		}
+		level, err := ParseLogLevel(strings.TrimSpace(levelName))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(component)] = level
+	}
+	return levels, nil
+}
+
 // log writes a log message
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
-	if level < l.level {
+	if l == nil || !l.enabled(level) {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	sink := l.sink
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
 
 	// Update metrics
-	l.metrics.mu.Lock()
-	l.metrics.counts[level]++
-	l.metrics.lastLog = time.Now()
-	l.metrics.mu.Unlock()
+	sink.metrics.mu.Lock()
+	sink.metrics.counts[level]++
+	sink.metrics.lastLog = time.Now()
+	sink.metrics.mu.Unlock()
 
 	// Format message
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
+	now := time.Now()
 	levelStr := logLevelNames[level]
 	message := fmt.Sprintf(format, args...)
 
+	var text strings.Builder
+	fmt.Fprintf(&text, "[%s] [%s] ", now.Format("2006-01-02 15:04:05.000"), levelStr)
+	if l.component != "" {
+		fmt.Fprintf(&text, "[%s] ", l.component)
+	}
+	text.WriteString(message)
+	for _, field := range l.fields {
+		fmt.Fprintf(&text, " %s=%v", field.Key, field.Value)
+	}
+	textLine := text.String()
+
 	// Write log
-	logLine := fmt.Sprintf("[%s] [%s] %s", timestamp, levelStr, message)
-	l.logger.Println(logLine)
+	line := []byte(textLine + "\n")
+	if sink.format == LogFormatJSON {
+		entry := map[string]interface{}{
+			"time":  now,
+			"level": levelStr,
+			"msg":   message,
+		}
+		if l.component != "" {
+			entry["component"] = l.component
+		}
+		for _, field := range l.fields {
+			entry[field.Key] = field.Value
+		}
+		if data, err := json.Marshal(entry); err == nil {
+			line = append(data, '\n')
+		}
+	}
+	sink.out.Write(line)
 
-	// Also print to console for errors and above
-	if level >= LogError {
-		log.Println(logLine)
+	// Also print to console for errors and above when logging to a file
+	if level >= LogError && sink.closer != nil {
+		log.Println(textLine)
 	}
 
 	// Fatal exits the program
@@ -108,6 +294,31 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	}
 }
 
+// Debug logs a debug message
+func (l *Logger) Debug(format string, args ...interface{}) {
+	l.log(LogDebug, format, args...)
+}
+
+// Info logs an info message
+func (l *Logger) Info(format string, args ...interface{}) {
+	l.log(LogInfo, format, args...)
+}
+
+// Warn logs a warning message
+func (l *Logger) Warn(format string, args ...interface{}) {
+	l.log(LogWarn, format, args...)
+}
+
+// Error logs an error message
+func (l *Logger) Error(format string, args ...interface{}) {
+	l.log(LogError, format, args...)
+}
+
+// Fatal logs a fatal message and exits
+func (l *Logger) Fatal(format string, args ...interface{}) {
+	l.log(LogFatal, format, args...)
+}
+
 // Debug logs a debug message
 func Debug(format string, args ...interface{}) {
 	if GlobalLogger != nil {
@@ -145,18 +356,19 @@ func Fatal(format string, args ...interface{}) {
 
 // GetMetrics returns logging metrics
 func GetMetrics() map[string]interface{} {
-	if GlobalLogger == nil || GlobalLogger.metrics == nil {
+	if GlobalLogger == nil {
 		return nil
 	}
 
-	GlobalLogger.metrics.mu.RLock()
-	defer GlobalLogger.metrics.mu.RUnlock()
+	logMetrics := GlobalLogger.sink.metrics
+	logMetrics.mu.RLock()
+	defer logMetrics.mu.RUnlock()
 
 	metrics := make(map[string]interface{})
-	for level, count := range GlobalLogger.metrics.counts {
+	for level, count := range logMetrics.counts {
 		metrics[logLevelNames[level]] = count
 	}
-	metrics["last_log"] = GlobalLogger.metrics.lastLog
+	metrics["last_log"] = logMetrics.lastLog
 
 	return metrics
 }
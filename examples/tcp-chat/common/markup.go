@@ -0,0 +1,292 @@
+package common
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Message formats, carried in the Format field of text messages
+const (
+	FormatPlain    = ""         // Content is shown as is
+	FormatMarkdown = "markdown" // Content uses the markup subset parsed by ParseMarkup
+)
+
+// linkSchemes are the URL schemes allowed in links
+var linkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Span is a run of text sharing one style
+type Span struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Code   bool
+	URL    string // Link target, empty when the span is not a link
+}
+
+// sameStyle reports whether two spans can be merged
+func (s Span) sameStyle(other Span) bool {
+	return s.Bold == other.Bold && s.Italic == other.Italic && s.Code == other.Code && s.URL == other.URL
+}
+
+// markupParser splits markup into spans
+type markupParser struct {
+	text   []rune
+	spans  []Span
+	bold   bool
+	italic rune      // Marker that opened italics, '*' or '_', zero when off
+	unsafe []linkRef // Links dropped for their scheme
+}
+
+// linkRef locates a link in the parsed text
+type linkRef struct {
+	start, end int // Rune offsets of the opening bracket and closing parenthesis
+	label      string
+}
+
+// ParseMarkup parses the markdown subset of chat messages: **bold**,
+// *italic* or _italic_, `code` and [text](url) links. Markers without a
+// closing counterpart are kept as text, a backslash escapes the next
+// marker and links to schemes other than http, https and mailto are
+// reduced to their text.
+func ParseMarkup(text string) []Span {
+	p := &markupParser{text: []rune(stripControl(text))}
+	p.parse()
+	return p.spans
+}
+
+// parse walks the text once, toggling styles at markers
+func (p *markupParser) parse() {
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			p.add(Span{Text: plain.String(), Bold: p.bold, Italic: p.italic != 0})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(p.text); i++ {
+		r := p.text[i]
+		switch {
+		case r == '\\' && i+1 < len(p.text) && isMarker(p.text[i+1]):
+			plain.WriteRune(p.text[i+1])
+			i++
+
+		case r == '`':
+			end := p.find(i+1, "`")
+			if end <= i+1 {
+				plain.WriteRune(r)
+				continue
+			}
+			flush()
+			p.add(Span{Text: string(p.text[i+1 : end]), Bold: p.bold, Italic: p.italic != 0, Code: true})
+			i = end
+
+		case r == '*' && i+1 < len(p.text) && p.text[i+1] == '*':
+			if !p.bold && p.find(i+2, "**") < 0 {
+				plain.WriteString("**")
+				i++
+				continue
+			}
+			flush()
+			p.bold = !p.bold
+			i++
+
+		case r == '*' || r == '_':
+			if p.italic == 0 {
+				if !p.opensItalic(i) {
+					plain.WriteRune(r)
+					continue
+				}
+				flush()
+				p.italic = r
+			} else if p.italic == r {
+				flush()
+				p.italic = 0
+			} else {
+				plain.WriteRune(r)
+			}
+
+		case r == '[':
+			label, target, end, ok := p.link(i)
+			if !ok {
+				plain.WriteRune(r)
+				continue
+			}
+			flush()
+			span := Span{Text: label, Bold: p.bold, Italic: p.italic != 0}
+			if safeLink(target) {
+				span.URL = target
+			} else {
+				p.unsafe = append(p.unsafe, linkRef{start: i, end: end, label: label})
+			}
+			p.add(span)
+			i = end
+
+		default:
+			plain.WriteRune(r)
+		}
+	}
+	flush()
+}
+
+// opensItalic reports whether the marker at i starts italics: it must be
+// followed by text and closed later, and underscores inside words such as
+// snake_case are left alone
+func (p *markupParser) opensItalic(i int) bool {
+	marker := p.text[i]
+	if i+1 >= len(p.text) || unicode.IsSpace(p.text[i+1]) {
+		return false
+	}
+	if marker == '_' && i > 0 && isWordRune(p.text[i-1]) {
+		return false
+	}
+	for j := i + 1; j < len(p.text); j++ {
+		if p.text[j] == '\\' {
+			j++
+			continue
+		}
+		if p.text[j] != marker {
+			continue
+		}
+		if marker == '*' && j+1 < len(p.text) && p.text[j+1] == '*' {
+			j++
+			continue
+		}
+		if marker == '_' && j+1 < len(p.text) && isWordRune(p.text[j+1]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// link parses [label](target) starting at i and returns the index of the
+// closing parenthesis
+func (p *markupParser) link(i int) (label, target string, end int, ok bool) {
+	close := p.find(i+1, "](")
+	if close <= i+1 {
+		return "", "", 0, false
+	}
+	end = p.find(close+2, ")")
+	if end <= close+2 {
+		return "", "", 0, false
+	}
+	target = string(p.text[close+2 : end])
+	if strings.ContainsFunc(target, unicode.IsSpace) {
+		return "", "", 0, false
+	}
+	label = unescapeMarkup(string(p.text[i+1 : close]))
+	return label, target, end, true
+}
+
+// find returns the index of the next unescaped occurrence of marker at or
+// after start, or -1
+func (p *markupParser) find(start int, marker string) int {
+	m := []rune(marker)
+	for j := start; j+len(m) <= len(p.text); j++ {
+		if p.text[j] == '\\' && m[0] != '`' {
+			j++
+			continue
+		}
+		if string(p.text[j:j+len(m)]) == marker {
+			return j
+		}
+	}
+	return -1
+}
+
+// add appends a span, merging it into the previous one when the styles match
+func (p *markupParser) add(span Span) {
+	if span.Text == "" {
+		return
+	}
+	if n := len(p.spans); n > 0 && p.spans[n-1].sameStyle(span) && span.URL == "" && !span.Code {
+		p.spans[n-1].Text += span.Text
+		return
+	}
+	p.spans = append(p.spans, span)
+}
+
+// SanitizeMarkup removes control characters from markup and replaces
+// links to unsafe schemes with their text; it is applied by the server to
+// every markdown message
+func SanitizeMarkup(text string) string {
+	p := &markupParser{text: []rune(stripControl(text))}
+	p.parse()
+
+	var out strings.Builder
+	last := 0
+	for _, link := range p.unsafe {
+		out.WriteString(string(p.text[last:link.start]))
+		out.WriteString(escapeMarkup(link.label))
+		last = link.end + 1
+	}
+	out.WriteString(string(p.text[last:]))
+	return out.String()
+}
+
+// PlainText returns the text of markup without any styling; links are
+// followed by their target
+func PlainText(text string) string {
+	var out strings.Builder
+	for _, span := range ParseMarkup(text) {
+		out.WriteString(span.Text)
+		if span.URL != "" && span.URL != span.Text {
+			out.WriteString(" <" + span.URL + ">")
+		}
+	}
+	return out.String()
+}
+
+// safeLink reports whether target is an absolute URL with an allowed scheme
+func safeLink(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && linkSchemes[strings.ToLower(u.Scheme)] && (u.Host != "" || u.Opaque != "")
+}
+
+// stripControl removes control characters other than newlines and tabs,
+// terminal escape sequences in particular
+func stripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// isMarker reports whether r has a meaning in markup
+func isMarker(r rune) bool {
+	return strings.ContainsRune("\\*_`[]", r)
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeMarkup escapes every marker in text
+func escapeMarkup(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		if isMarker(r) {
+			out.WriteRune('\\')
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// unescapeMarkup removes the backslashes escaping markers
+func unescapeMarkup(text string) string {
+	var out strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) && isMarker(runes[i+1]) {
+			i++
+		}
+		out.WriteRune(runes[i])
+	}
+	return out.String()
+}
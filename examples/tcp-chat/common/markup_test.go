@@ -0,0 +1,62 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Span
+	}{
+		{"plain text", []Span{{Text: "plain text"}}},
+		{"a **bold** move", []Span{{Text: "a "}, {Text: "bold", Bold: true}, {Text: " move"}}},
+		{"*one* and _two_", []Span{{Text: "one", Italic: true}, {Text: " and "}, {Text: "two", Italic: true}}},
+		{"***both***", []Span{{Text: "both", Bold: true, Italic: true}}},
+		{"run `go *test*`", []Span{{Text: "run "}, {Text: "go *test*", Code: true}}},
+		{"see [docs](https://go.dev)", []Span{{Text: "see "}, {Text: "docs", URL: "https://go.dev"}}},
+		{"snake_case_name", []Span{{Text: "snake_case_name"}}},
+		{"2 * 3 * 4", []Span{{Text: "2 * 3 * 4"}}},
+		{"unclosed **bold", []Span{{Text: "unclosed **bold"}}},
+		{`\*not italic\*`, []Span{{Text: "*not italic*"}}},
+		{"[click](javascript:alert(1))", []Span{{Text: "click)"}}},
+		{"bell\a and \x1b[31mred", []Span{{Text: "bell and [31mred"}}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseMarkup(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMarkup(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"**keep** _this_ [link](https://example.com)", "**keep** _this_ [link](https://example.com)"},
+		{"[x](javascript:void)", "x"},
+		{"[**a**](data:text/html)", `\*\*a\*\*`},
+		{"\x1b[2Jclear", "[2Jclear"},
+		{"line\nbreak", "line\nbreak"},
+	}
+
+	for _, tt := range tests {
+		got := SanitizeMarkup(tt.text)
+		if got != tt.want {
+			t.Errorf("SanitizeMarkup(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if again := SanitizeMarkup(got); again != got {
+			t.Errorf("SanitizeMarkup is not idempotent: %q became %q", got, again)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText("**hi** see [docs](https://go.dev) or https://go.dev")
+	if want := "hi see docs <https://go.dev> or https://go.dev"; got != want {
+		t.Errorf("PlainText = %q, want %q", got, want)
+	}
+}
@@ -2,8 +2,10 @@ package common
 
 import (
 	"encoding/json"
-	"sync"
+	"errors"
 	"time"
+
+	"training.pl/go/common/validate"
 )
 
 // MessageType represents the type of message being sent
@@ -15,6 +17,7 @@ const (
 	TypeFile         MessageType = "FILE"
 	TypeFileChunk    MessageType = "FILE_CHUNK"
 	TypeFileComplete MessageType = "FILE_COMPLETE"
+	TypeFileControl  MessageType = "FILE_CONTROL"
 	TypeStatus       MessageType = "STATUS"
 	TypeRoom         MessageType = "ROOM"
 	TypeInvite       MessageType = "INVITE"
@@ -24,6 +27,14 @@ const (
 	TypeConnect      MessageType = "CONNECT"
 	TypeDisconnect   MessageType = "DISCONNECT"
 	TypeAck          MessageType = "ACK"
+	TypeIgnore       MessageType = "IGNORE"
+	TypeChallenge    MessageType = "CHALLENGE"
+	TypeStats        MessageType = "STATS"
+	TypeUserJoined   MessageType = "USER_JOINED" // A user became visible, Sender and Status describe it
+	TypeUserLeft     MessageType = "USER_LEFT"   // A user disconnected or went invisible
+	TypeUserStatus   MessageType = "USER_STATUS" // A visible user changed status
+	TypePing         MessageType = "PING"        // Client keep-alive, answered with a PONG carrying the same ID
+	TypePong         MessageType = "PONG"
 )
 
 // UserStatus represents the status of a user
@@ -33,6 +44,8 @@ const (
 	StatusActive    UserStatus = "ACTIVE"
 	StatusBusy      UserStatus = "BUSY"
 	StatusInvisible UserStatus = "INVISIBLE"
+	StatusAway      UserStatus = "AWAY"    // Content of status messages and presence events carries the reason
+	StatusOffline   UserStatus = "OFFLINE" // Answer to status queries about absent or invisible users
 )
 
 // RoomAction represents actions related to rooms
@@ -48,26 +61,53 @@ const (
 	RoomKick         RoomAction = "KICK"
 	RoomDelete       RoomAction = "DELETE"
 	RoomSetTopic     RoomAction = "TOPIC"
+	RoomSettings     RoomAction = "SETTINGS"
+)
+
+// Room join policies
+const (
+	PolicyOpen     = "open"     // Anyone knowing the room ID can join
+	PolicyInvite   = "invite"   // Only invited users can join
+	PolicyPassword = "password" // Joining requires the room password
+)
+
+// MetaAutoReply is set to "true" in the Metadata of text sent automatically,
+// such as trigger replies, which never fire triggers themselves
+const MetaAutoReply = "auto_reply"
+
+// File transfer control actions, carried in the Content of TypeFileControl messages
+const (
+	FilePause  = "pause"
+	FileResume = "resume"
+	FileCancel = "cancel"
 )
 
 // Message represents a message in the chat protocol
 type Message struct {
-	Type        MessageType `json:"type"`
-	Sender      string      `json:"sender"`
-	Recipient   string      `json:"recipient,omitempty"` // Empty for broadcast, "*" for all
-	Room        string      `json:"room,omitempty"`
-	Content     string      `json:"content,omitempty"`
-	Status      UserStatus  `json:"status,omitempty"`
-	Action      RoomAction  `json:"action,omitempty"`
-	Filename    string      `json:"filename,omitempty"`
-	Filesize    int64       `json:"filesize,omitempty"`
-	FileID      string      `json:"file_id,omitempty"`
-	ChunkNum    int         `json:"chunk_num,omitempty"`
-	TotalChunks int         `json:"total_chunks,omitempty"`
-	Data        []byte      `json:"data,omitempty"`
-	Users       []string    `json:"users,omitempty"`
-	Timestamp   time.Time   `json:"timestamp"`
-	Error       string      `json:"error,omitempty"`
+	ID           string                 `json:"id,omitempty" validate:"len=..MaxFieldLength"` // Client-generated UUID used to drop retransmissions
+	Type         MessageType            `json:"type" validate:"len=..MaxFieldLength"`
+	Sender       string                 `json:"sender" validate:"len=..MaxFieldLength"`
+	Recipient    string                 `json:"recipient,omitempty" validate:"len=..MaxFieldLength"` // Empty for broadcast, "*" for all
+	Room         string                 `json:"room,omitempty" validate:"len=..MaxFieldLength"`
+	Content      string                 `json:"content,omitempty" validate:"len=..MaxContentLength"`
+	Format       string                 `json:"format,omitempty" validate:"len=..MaxFieldLength"` // Markup of Content, FormatPlain or FormatMarkdown
+	Status       UserStatus             `json:"status,omitempty" validate:"len=..MaxFieldLength"`
+	Action       RoomAction             `json:"action,omitempty" validate:"len=..MaxFieldLength"`
+	Filename     string                 `json:"filename,omitempty" validate:"len=..MaxFieldLength"`
+	Filesize     int64                  `json:"filesize,omitempty" validate:"range=0..MaxFileSize"`
+	FileID       string                 `json:"file_id,omitempty" validate:"len=..MaxFieldLength"`
+	ContentType  string                 `json:"content_type,omitempty" validate:"len=..MaxFieldLength"`                           // MIME type of a transferred file
+	Metadata     map[string]string      `json:"metadata,omitempty" validate:"len=..MaxMetadataEntries,each,len=..MaxFieldLength"` // Free-form file details, see the Meta constants
+	ChunkNum     int                    `json:"chunk_num,omitempty" validate:"range=0.."`
+	TotalChunks  int                    `json:"total_chunks,omitempty" validate:"range=0..MaxTotalChunks"`
+	Data         []byte                 `json:"data,omitempty" validate:"len=..FileChunkSize"`
+	Users        []string               `json:"users,omitempty" validate:"len=..MaxListLength,each,len=..MaxFieldLength"`
+	Ephemeral    bool                   `json:"ephemeral,omitempty"`                          // Room is deleted when the last member leaves
+	TTL          int64                  `json:"ttl,omitempty" validate:"range=0..MaxRoomTTL"` // Lifetime of an ephemeral room in seconds
+	Timestamp    time.Time              `json:"timestamp"`
+	Error        string                 `json:"error,omitempty" validate:"len=..MaxContentLength"`
+	ErrorCode    ErrorType              `json:"error_code,omitempty" validate:"len=..MaxFieldLength"`   // Machine-readable error type
+	ErrorDetails map[string]interface{} `json:"error_details,omitempty" validate:"len=..MaxListLength"` // Extra error context such as retry_after
 }
 
 // NewTextMessage creates a new text message
@@ -113,59 +153,121 @@ func NewErrorMessage(sender, recipient, error string) *Message {
 	}
 }
 
+// NewErrorMessageFromError creates an error message from err; a ChatError
+// also carries its type and details so clients can handle it by code
+func NewErrorMessageFromError(sender, recipient string, err error) *Message {
+	var chatErr *ChatError
+	if !errors.As(err, &chatErr) {
+		return NewErrorMessage(sender, recipient, err.Error())
+	}
+	msg := NewErrorMessage(sender, recipient, chatErr.Message)
+	msg.ErrorCode = chatErr.Type
+	if len(chatErr.Details) > 0 {
+		msg.ErrorDetails = chatErr.Details
+	}
+	return msg
+}
+
+// ChatError returns the error carried by a TypeError message; messages
+// without a code are reported as ErrInternal
+func (m *Message) ChatError() *ChatError {
+	chatErr := NewChatError(m.ErrorCode, m.Error)
+	if chatErr.Type == "" {
+		chatErr.Type = ErrInternal
+	}
+	for key, value := range m.ErrorDetails {
+		chatErr.Details[key] = value
+	}
+	return chatErr
+}
+
 // Encode serializes the message to JSON
 func (m *Message) Encode() ([]byte, error) {
 	return json.Marshal(m)
 }
 
-// DecodeMessage deserializes a JSON message
+// DecodeMessage deserializes a JSON message and rejects messages whose
+// fields exceed the protocol limits with a validation error
 func DecodeMessage(data []byte) (*Message, error) {
 	var msg Message
-	err := json.Unmarshal(data, &msg)
-	return &msg, err
+	if len(data) > MaxScannerBuffer {
+		return &msg, ChatErrorf(ErrValidation, "message exceeds %d bytes", MaxScannerBuffer)
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return &msg, ChatErrorf(ErrValidation, "malformed message: %v", err)
+	}
+	return &msg, msg.Validate()
+}
+
+// messageValidator enforces the limits in the validate tags of Message; the
+// tags name the limits, so the constants stay the only place to change them
+var messageValidator = validate.New("validate").NameBy("json").
+	WithLimit("MaxFieldLength", MaxFieldLength).
+	WithLimit("MaxContentLength", MaxContentLength).
+	WithLimit("MaxListLength", MaxListLength).
+	WithLimit("MaxMetadataEntries", MaxMetadataEntries).
+	WithLimit("MaxFileSize", MaxFileSize).
+	WithLimit("MaxTotalChunks", MaxTotalChunks).
+	WithLimit("FileChunkSize", FileChunkSize).
+	WithLimit("MaxRoomTTL", float64(MaxRoomTTL/time.Second))
+
+// Validate checks the message fields against the protocol limits; the
+// meaning of the fields is left to the handlers. The first broken limit is
+// reported, all the offending fields are listed in the "fields" detail.
+func (m *Message) Validate() error {
+	var invalid validate.Errors
+	if err := messageValidator.Validate(m); errors.As(err, &invalid) {
+		fields := make([]string, len(invalid))
+		for i, fieldErr := range invalid {
+			fields[i] = fieldErr.Field
+		}
+		return ChatErrorf(ErrValidation, invalid[0].Format, invalid[0].Args...).WithDetail("fields", fields)
+	} else if err != nil {
+		return NewChatError(ErrInternal, err.Error())
+	}
+
+	// Rules across fields are beyond the tags
+	if m.TotalChunks > 0 && m.ChunkNum >= m.TotalChunks {
+		return ChatErrorf(ErrValidation, "chunk number %d out of range", m.ChunkNum)
+	}
+	return nil
 }
 
 // FileTransfer represents an ongoing file transfer
 type FileTransfer struct {
-	FileID         string
-	Filename       string
-	Filesize       int64
-	Sender         string
-	Recipient      string
-	TotalChunks    int
-	ReceivedChunks map[int][]byte
-	StartTime      time.Time
-	mutex          sync.RWMutex
+	FileID      string
+	Filename    string
+	Filesize    int64
+	ContentType string
+	Metadata    map[string]string
+	Sender      string
+	Recipient   string
+	TotalChunks int
+	Chunks      *ChunkAssembler
+	StartTime   time.Time
 }
 
 // IsComplete checks if all chunks have been received
 func (ft *FileTransfer) IsComplete() bool {
-	ft.mutex.RLock()
-	defer ft.mutex.RUnlock()
-	return len(ft.ReceivedChunks) == ft.TotalChunks
+	return ft.Chunks.IsComplete()
 }
 
 // GetProgress returns the progress percentage
 func (ft *FileTransfer) GetProgress() float64 {
-	ft.mutex.RLock()
-	defer ft.mutex.RUnlock()
-	if ft.TotalChunks == 0 {
-		return 0
-	}
-	return float64(len(ft.ReceivedChunks)) / float64(ft.TotalChunks) * 100
+	return ft.Chunks.Progress()
 }
 
 // AddChunk adds a chunk to the file transfer
-func (ft *FileTransfer) AddChunk(chunkNum int, data []byte) {
-	ft.mutex.Lock()
-	defer ft.mutex.Unlock()
-	ft.ReceivedChunks[chunkNum] = data
+func (ft *FileTransfer) AddChunk(chunkNum int, data []byte) error {
+	return ft.Chunks.Add(chunkNum, data)
 }
 
 // GetChunk retrieves a specific chunk
 func (ft *FileTransfer) GetChunk(chunkNum int) ([]byte, bool) {
-	ft.mutex.RLock()
-	defer ft.mutex.RUnlock()
-	data, exists := ft.ReceivedChunks[chunkNum]
-	return data, exists
+	return ft.Chunks.Chunk(chunkNum)
+}
+
+// Close releases the chunks of the transfer
+func (ft *FileTransfer) Close() error {
+	return ft.Chunks.Close()
 }
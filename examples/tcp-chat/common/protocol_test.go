@@ -0,0 +1,118 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeMessageRejectsHostileInput(t *testing.T) {
+	long := strings.Repeat("x", MaxFieldLength+1)
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"malformed JSON", `{"type":`},
+		{"huge sender", fmt.Sprintf(`{"type":"TEXT","sender":%q}`, long)},
+		{"huge content", fmt.Sprintf(`{"type":"TEXT","content":%q}`, strings.Repeat("x", MaxContentLength+1))},
+		{"huge total chunks", `{"type":"FILE","total_chunks":1000000000}`},
+		{"negative total chunks", `{"type":"FILE","total_chunks":-1}`},
+		{"negative chunk number", `{"type":"FILE_CHUNK","chunk_num":-5,"total_chunks":2}`},
+		{"chunk number past the end", `{"type":"FILE_CHUNK","chunk_num":2,"total_chunks":2}`},
+		{"negative file size", `{"type":"FILE","filesize":-1}`},
+		{"oversized file", fmt.Sprintf(`{"type":"FILE","filesize":%d}`, MaxFileSize+1)},
+		{"oversized chunk", fmt.Sprintf(`{"type":"FILE_CHUNK","data":%q}`, bytes.Repeat([]byte("A"), (FileChunkSize+3)/3*4+4))},
+		{"too many users", fmt.Sprintf(`{"type":"IGNORE","users":[%s"x"]}`, strings.Repeat(`"x",`, MaxListLength))},
+		{"huge user name", fmt.Sprintf(`{"type":"IGNORE","users":["x",%q]}`, long)},
+		{"huge metadata value", fmt.Sprintf(`{"type":"FILE","metadata":{"title":%q}}`, long)},
+		{"negative TTL", `{"type":"ROOM","ttl":-1}`},
+		{"huge TTL", fmt.Sprintf(`{"type":"ROOM","ttl":%d}`, int64(MaxRoomTTL/time.Second)+1)},
+		{"message too large", `{"type":"TEXT","content":"` + strings.Repeat("x", MaxScannerBuffer) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeMessage([]byte(tt.data))
+			if !IsType(err, ErrValidation) {
+				t.Errorf("DecodeMessage returned %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllFields(t *testing.T) {
+	msg := &Message{Type: TypeFile, Sender: strings.Repeat("x", MaxFieldLength+1), Filesize: MaxFileSize + 1}
+	var chatErr *ChatError
+	if err := msg.Validate(); !errors.As(err, &chatErr) || chatErr.Type != ErrValidation {
+		t.Fatalf("Validate returned %v, want a validation error", err)
+	}
+	if expected := fmt.Sprintf("field sender exceeds %d characters", MaxFieldLength); chatErr.Message != expected {
+		t.Errorf("Expected %q, got %q", expected, chatErr.Message)
+	}
+	if fields := chatErr.Details["fields"]; !slices.Equal(fields.([]string), []string{"sender", "filesize"}) {
+		t.Errorf("Expected both fields in the details, got %v", fields)
+	}
+}
+
+func TestDecodeMessageAcceptsProtocolMessages(t *testing.T) {
+	msgs := []*Message{
+		NewTextMessage("alice", "bob", "hello"),
+		NewBroadcastMessage("alice", "hello all"),
+		NewStatusMessage("alice", StatusBusy),
+		NewErrorMessageFromError("Server", "alice", ChatErrorf(ErrRateLimit, "slow down").WithDetail("retry_after", "1s")),
+		{Type: TypeFileChunk, FileID: "f", ChunkNum: 3, TotalChunks: 4, Data: make([]byte, FileChunkSize)},
+		{Type: TypeFile, Filename: "a.txt", Filesize: MaxFileSize, TotalChunks: MaxTotalChunks},
+	}
+
+	for _, msg := range msgs {
+		data, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if _, err := DecodeMessage(data); err != nil {
+			t.Errorf("DecodeMessage(%s): %v", data, err)
+		}
+	}
+}
+
+func FuzzDecodeMessage(f *testing.F) {
+	seeds := []string{
+		`{"type":"TEXT","sender":"alice","recipient":"*","content":"hi"}`,
+		`{"type":"FILE","file_id":"f1","filename":"a.bin","filesize":10,"total_chunks":1}`,
+		`{"type":"FILE_CHUNK","file_id":"f1","chunk_num":0,"total_chunks":1,"data":"aGVsbG8="}`,
+		`{"type":"ROOM","action":"CREATE","content":"lounge","ephemeral":true,"ttl":60}`,
+		`{"type":"ERROR","error":"x","error_code":"RATE_LIMIT","error_details":{"retry_after":"1s"}}`,
+		`{"type":"IGNORE","users":["a","b"]}`,
+		`{"type":"FILE","total_chunks":99999999999}`,
+		`{"chunk_num":-1}`,
+		`[]`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			if !IsType(err, ErrValidation) {
+				t.Fatalf("DecodeMessage returned a non-validation error: %v", err)
+			}
+			return
+		}
+
+		// Anything accepted must encode again; invalid UTF-8 grows when it
+		// is replaced, so re-decoding may only fail validation
+		encoded, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Encode of a decoded message: %v", err)
+		}
+		if _, err := DecodeMessage(encoded); err != nil && !IsType(err, ErrValidation) {
+			t.Fatalf("re-decoding %s: %v", encoded, err)
+		}
+	})
+}
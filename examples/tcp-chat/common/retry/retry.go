@@ -0,0 +1,74 @@
+// Package retry runs operations again after failures, waiting longer after
+// every failed attempt
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy describes how often and how soon an operation is retried
+type Policy struct {
+	MaxAttempts  int           // Attempts including the first one, 0 retries until the context is done
+	InitialDelay time.Duration // Delay after the first failure, one second if not set
+	MaxDelay     time.Duration // Upper bound of the delay, no bound if not set
+	Multiplier   float64       // Growth of the delay after every failure, 2 if not set
+	Jitter       float64       // Fraction of the delay chosen at random, so clients don't retry in lockstep
+
+	// RetryIf decides whether an error is worth another attempt, all errors are when not set
+	RetryIf func(err error) bool
+	// OnRetry is called before waiting for the next attempt
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Do calls fn until it succeeds, the policy gives up or ctx is done. It returns
+// the last error of fn, wrapped together with the context error when ctx ended the retries.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return err
+		}
+		if policy.RetryIf != nil && !policy.RetryIf(err) {
+			return err
+		}
+		delay := policy.Delay(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+}
+
+// Delay returns how long to wait after the given failed attempt, counted from 1
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	scaled := float64(delay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && scaled > float64(p.MaxDelay) {
+		scaled = float64(p.MaxDelay)
+	}
+	delay = time.Duration(min(scaled, 1<<62)) // An unbounded delay must not overflow
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
@@ -0,0 +1,112 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTemporary = errors.New("temporary")
+
+// failing returns an operation failing the given number of times before it succeeds
+func failing(failures int, calls *int) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= failures {
+			return errTemporary
+		}
+		return nil
+	}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	var delays []time.Duration
+	policy := Policy{
+		InitialDelay: time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			if attempt != len(delays)+1 || !errors.Is(err, errTemporary) {
+				t.Errorf("Unexpected retry %d after %v", attempt, err)
+			}
+			delays = append(delays, delay)
+		},
+	}
+	if err := Do(context.Background(), policy, failing(3, &calls)); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+	expected := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}
+	for i := range expected {
+		if i >= len(delays) || delays[i] != expected[i] {
+			t.Fatalf("Expected delays %v, got %v", expected, delays)
+		}
+	}
+}
+
+func TestDoGivesUp(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        Policy
+		expectedCalls int
+	}{
+		{"Max attempts", Policy{MaxAttempts: 3, InitialDelay: time.Millisecond}, 3},
+		{"Not retryable", Policy{RetryIf: func(err error) bool { return !errors.Is(err, errTemporary) }}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			if err := Do(context.Background(), tt.policy, failing(10, &calls)); !errors.Is(err, errTemporary) {
+				t.Errorf("Expected the last error, got %v", err)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{InitialDelay: time.Hour}, failing(10, &calls))
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errTemporary) {
+		t.Errorf("Expected the context and the last error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second || calls != 1 {
+		t.Errorf("Expected the wait to be cut short after 1 call, took %v and %d calls", elapsed, calls)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		attempt  int
+		expected time.Duration
+	}{
+		{"Defaults", Policy{}, 1, time.Second},
+		{"Doubling", Policy{}, 4, 8 * time.Second},
+		{"Multiplier", Policy{InitialDelay: 100 * time.Millisecond, Multiplier: 3}, 3, 900 * time.Millisecond},
+		{"Capped", Policy{MaxDelay: 5 * time.Second}, 10, 5 * time.Second},
+		{"No overflow", Policy{}, 1000, 1 << 62},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if delay := tt.policy.Delay(tt.attempt); delay != tt.expected {
+				t.Errorf("Delay(%d) = %v, want %v", tt.attempt, delay, tt.expected)
+			}
+		})
+	}
+
+	policy := Policy{InitialDelay: time.Second, Jitter: 0.5}
+	for range 100 {
+		if delay := policy.Delay(1); delay <= 500*time.Millisecond || delay > time.Second {
+			t.Fatalf("Expected a jittered delay between 0.5s and 1s, got %v", delay)
+		}
+	}
+}
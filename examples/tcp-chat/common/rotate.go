@@ -0,0 +1,212 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotation defaults
+const (
+	DefaultLogMaxSize    = 10 * 1024 * 1024 // 10MB
+	DefaultLogMaxBackups = 5
+)
+
+// backupTimeFormat is appended to rotated file names
+const backupTimeFormat = "20060102-150405.000"
+
+// rotateRetryDelay is how long writes go to the current file after a failed
+// rotation before rotating is tried again
+const rotateRetryDelay = time.Minute
+
+// RotatingFile is an io.WriteCloser that rotates the underlying file once it
+// grows over MaxSize or gets older than MaxAge, keeping MaxBackups old files
+type RotatingFile struct {
+	filename   string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file      *os.File
+	size      int64
+	startedAt time.Time // When the current file was started, see open
+	retryAt   time.Time // No rotation before, set when one failed
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+// NewRotatingFile opens filename for appending. A zero maxSize or maxAge
+// disables that trigger; maxBackups of zero keeps all rotated files.
+func NewRotatingFile(filename string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens the current log file, continuing an existing one. The age of
+// a continued file counts from the last rotation, or from its modification
+// time when there was none, so restarts don't postpone rotating it.
+func (rf *RotatingFile) open() error {
+	if dir := filepath.Dir(rf.filename); dir != "." {
+		if err := os.MkdirAll(dir, GetDirMode()); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(rf.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, GetFileMode())
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	rf.startedAt = rf.now()
+	if rf.size > 0 {
+		rf.startedAt = info.ModTime()
+		if rotated, ok := rf.lastRotation(); ok && rotated.Before(rf.startedAt) {
+			rf.startedAt = rotated
+		}
+	}
+	return nil
+}
+
+// Write writes p to the file, rotating first when a limit would be exceeded
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := rf.now()
+	tooBig := rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize
+	tooOld := rf.maxAge > 0 && now.Sub(rf.startedAt) > rf.maxAge
+	var rotateErr error
+	if (tooBig || tooOld) && !now.Before(rf.retryAt) {
+		// The entry still goes to the current file, the error only reports
+		// that rotating failed
+		if rotateErr = rf.rotate(); rotateErr != nil {
+			rf.retryAt = now.Add(rotateRetryDelay)
+			rotateErr = fmt.Errorf("failed to rotate log: %v", rotateErr)
+		}
+		if rf.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate forces a rotation of the current file
+func (rf *RotatingFile) Rotate() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	return rf.rotate()
+}
+
+// rotate renames the current file and opens a fresh one; caller holds the
+// mutex. When renaming fails the current file is opened again, so writing can
+// go on.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+
+	backup := rf.filename + "." + rf.now().Format(backupTimeFormat)
+	if err := os.Rename(rf.filename, backup); err != nil && !os.IsNotExist(err) {
+		if openErr := rf.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	return rf.prune()
+}
+
+// backups returns the files produced by rotate, oldest first
+func (rf *RotatingFile) backups() ([]string, error) {
+	backups, err := filepath.Glob(rf.filename + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	// Their suffix sorts chronologically
+	prefix := rf.filename + "."
+	var rotated []string
+	for _, backup := range backups {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(backup, prefix)); err == nil {
+			rotated = append(rotated, backup)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+// lastRotation returns the time of the newest backup, when the current file was started
+func (rf *RotatingFile) lastRotation() (time.Time, bool) {
+	rotated, err := rf.backups()
+	if err != nil || len(rotated) == 0 {
+		return time.Time{}, false
+	}
+	suffix := strings.TrimPrefix(rotated[len(rotated)-1], rf.filename+".")
+	rotatedAt, err := time.ParseInLocation(backupTimeFormat, suffix, time.Local)
+	return rotatedAt, err == nil
+}
+
+// prune removes the oldest backups over the retention limit
+func (rf *RotatingFile) prune() error {
+	if rf.maxBackups <= 0 {
+		return nil
+	}
+
+	rotated, err := rf.backups()
+	if err != nil {
+		return err
+	}
+	for len(rotated) > rf.maxBackups {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Close closes the current file
+func (rf *RotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRotatingFile opens a log in a temporary directory with a clock the
+// test moves forward
+func newTestRotatingFile(t *testing.T, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, *time.Time) {
+	t.Helper()
+	rf, err := NewRotatingFile(filepath.Join(t.TempDir(), "chat.log"), maxSize, maxAge, maxBackups)
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	t.Cleanup(func() { rf.Close() })
+	now := time.Now()
+	rf.now = func() time.Time { return now }
+	return rf, &now
+}
+
+func write(t *testing.T, rf *RotatingFile, text string) {
+	t.Helper()
+	if _, err := rf.Write([]byte(text)); err != nil {
+		t.Fatalf("Write(%q): %v", text, err)
+	}
+}
+
+// contents returns the backups oldest first followed by the current file
+func contents(t *testing.T, rf *RotatingFile) []string {
+	t.Helper()
+	backups, err := rf.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, name := range append(backups, rf.filename) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, string(data))
+	}
+	return texts
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	rf, now := newTestRotatingFile(t, 10, 0, 0)
+	for _, text := range []string{"12345", "67890", "abc", "defghijk"} {
+		write(t, rf, text)
+		*now = now.Add(time.Second) // Backups are named after the time
+	}
+	if got := strings.Join(contents(t, rf), "|"); got != "1234567890|abc|defghijk" {
+		t.Errorf("Files %q", got)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	rf, now := newTestRotatingFile(t, 0, time.Hour, 0)
+	write(t, rf, "old")
+	*now = now.Add(time.Hour + time.Second)
+	write(t, rf, "new")
+	if got := strings.Join(contents(t, rf), "|"); got != "old|new" {
+		t.Errorf("Files %q", got)
+	}
+}
+
+// A restart continues the current file without resetting its age
+func TestRotatingFileAgeSurvivesReopening(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "chat.log")
+	if err := os.WriteFile(filename, []byte("before the restart"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := NewRotatingFile(filename, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	defer rf.Close()
+	write(t, rf, "after")
+	if got := strings.Join(contents(t, rf), "|"); got != "before the restart|after" {
+		t.Errorf("Files %q", got)
+	}
+}
+
+func TestRotatingFilePrunesBackups(t *testing.T) {
+	rf, now := newTestRotatingFile(t, 0, 0, 2)
+	for _, text := range []string{"a", "b", "c", "d"} {
+		write(t, rf, text)
+		if err := rf.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		*now = now.Add(time.Second)
+	}
+	write(t, rf, "e")
+	if got := strings.Join(contents(t, rf), "|"); got != "c|d|e" {
+		t.Errorf("Files %q, want the two newest backups", got)
+	}
+}
+
+// Writes go on to the current file when it can't be renamed
+func TestRotatingFileFailedRotation(t *testing.T) {
+	rf, now := newTestRotatingFile(t, 4, 0, 0)
+	write(t, rf, "1234")
+	// A directory takes the name of the backup, renaming onto it fails
+	blocked := rf.filename + "." + now.Format(backupTimeFormat)
+	if err := os.MkdirAll(filepath.Join(blocked, "file"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := rf.Write([]byte("5678")); n != 4 || err == nil {
+		t.Fatalf("Write = %d, %v; want 4 and the rotation error", n, err)
+	}
+	write(t, rf, "9") // Not rotated again before rotateRetryDelay
+	*now = now.Add(rotateRetryDelay)
+	write(t, rf, "0")
+	os.RemoveAll(blocked)
+	if got := strings.Join(contents(t, rf), "|"); got != "123456789|0" {
+		t.Errorf("Files %q", got)
+	}
+}
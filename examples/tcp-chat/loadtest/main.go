@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// roomTimeout bounds how long room setup and the statistics request may take
+const roomTimeout = 5 * time.Second
+
+// Config describes a load test run
+type Config struct {
+	Server     string
+	Clients    int
+	Duration   time.Duration
+	Rate       float64 // Actions per second per client
+	Mix        *Mix
+	FileChunks int
+	Ramp       time.Duration // Delay between connecting clients
+	LocalAddrs []string      // Source addresses to spread clients over
+	Prefix     string
+}
+
+func main() {
+	server := flag.String("server", "localhost:8080", "Server address")
+	clients := flag.Int("clients", 10, "Number of synthetic clients")
+	duration := flag.Duration("duration", 30*time.Second, "How long to generate load")
+	rate := flag.Float64("rate", 2, "Actions per second per client")
+	mixSpec := flag.String("mix", "broadcast=50,private=30,room=20,file=0", "Weights of the generated actions (broadcast, private, room, file)")
+	fileChunks := flag.Int("file-chunks", 4, "Chunks per file for the file action")
+	ramp := flag.Duration("ramp", 10*time.Millisecond, "Delay between connecting clients")
+	localAddrs := flag.String("local-addrs", "", "Comma-separated source IPs to spread clients over the per-IP limits, e.g. 127.0.0.2,127.0.0.3")
+	prefix := flag.String("prefix", "lt", "Nickname prefix of the synthetic clients")
+	flag.Parse()
+
+	mix, err := ParseMix(*mixSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *clients < 1 || *rate <= 0 || *fileChunks < 1 {
+		log.Fatal("clients, rate and file-chunks must be positive")
+	}
+
+	config := &Config{
+		Server:     *server,
+		Clients:    *clients,
+		Duration:   *duration,
+		Rate:       *rate,
+		Mix:        mix,
+		FileChunks: *fileChunks,
+		Ramp:       *ramp,
+		Prefix:     *prefix,
+	}
+	for _, addr := range strings.Split(*localAddrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			config.LocalAddrs = append(config.LocalAddrs, addr)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	recorder := NewRecorder()
+	elapsed := run(ctx, config, recorder)
+	recorder.Report(os.Stdout, elapsed)
+}
+
+// run connects the clients, generates load for the configured duration and
+// returns how long the load phase lasted
+func run(ctx context.Context, config *Config, recorder *Recorder) time.Duration {
+	fmt.Printf("Connecting %d clients to %s...\n", config.Clients, config.Server)
+
+	var clients []*SyntheticClient
+	var nicknames []string
+	for i := 0; i < config.Clients && ctx.Err() == nil; i++ {
+		nickname := fmt.Sprintf("%s%d", config.Prefix, i)
+		localAddr := ""
+		if len(config.LocalAddrs) > 0 {
+			localAddr = config.LocalAddrs[i%len(config.LocalAddrs)]
+		}
+
+		client, err := Dial(config, recorder, nickname, localAddr)
+		if err != nil {
+			log.Printf("%s: %v", nickname, err)
+			recorder.connectFailed.Add(1)
+			continue
+		}
+		recorder.connected.Add(1)
+		clients = append(clients, client)
+		nicknames = append(nicknames, nickname)
+		time.Sleep(config.Ramp)
+	}
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+
+	if len(clients) == 0 {
+		return 0
+	}
+	for _, client := range clients {
+		client.SetPeers(nicknames)
+	}
+	if config.Mix.Has(ActionRoom) {
+		setupRoom(clients, config.Prefix)
+	}
+
+	fmt.Printf("Generating load for %v (%s)...\n", config.Duration, config.Mix)
+	loadCtx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *SyntheticClient) {
+			defer wg.Done()
+			client.Run(loadCtx)
+		}(client)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Give messages still in flight a moment to arrive
+	time.Sleep(time.Second)
+
+	// The server's own counters show its throughput from its side
+	if stats, err := clients[0].ServerStats(roomTimeout); err == nil {
+		fmt.Printf("\n=== Server statistics ===\n%s\n", stats)
+	} else {
+		log.Printf("Server statistics unavailable: %v", err)
+	}
+	return elapsed
+}
+
+// setupRoom has the first client create a room that all others join;
+// clients that fail to join skip room messages
+func setupRoom(clients []*SyntheticClient, prefix string) {
+	roomID, err := clients[0].CreateRoom(prefix+"-room", roomTimeout)
+	if err != nil {
+		log.Printf("Room setup failed, skipping room messages: %v", err)
+		return
+	}
+	for _, client := range clients[1:] {
+		if err := client.JoinRoom(roomID, roomTimeout); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Action is a kind of traffic a synthetic client generates
+type Action string
+
+const (
+	ActionBroadcast Action = "broadcast"
+	ActionPrivate   Action = "private"
+	ActionRoom      Action = "room"
+	ActionFile      Action = "file"
+)
+
+// Mix picks actions at random according to their weights
+type Mix struct {
+	actions []Action
+	weights []int
+	total   int
+}
+
+// ParseMix parses a message mix such as "broadcast=60,private=30,room=10,file=0"
+func ParseMix(spec string) (*Mix, error) {
+	mix := &Mix{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q, use action=weight", pair)
+		}
+
+		action := Action(strings.TrimSpace(key))
+		switch action {
+		case ActionBroadcast, ActionPrivate, ActionRoom, ActionFile:
+		default:
+			return nil, fmt.Errorf("unknown action: %s (use broadcast, private, room or file)", action)
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for %s: %s", action, value)
+		}
+		if weight == 0 {
+			continue
+		}
+		mix.actions = append(mix.actions, action)
+		mix.weights = append(mix.weights, weight)
+		mix.total += weight
+	}
+
+	if mix.total == 0 {
+		return nil, fmt.Errorf("message mix has no actions")
+	}
+	return mix, nil
+}
+
+// Pick returns a random action
+func (m *Mix) Pick(rng *rand.Rand) Action {
+	n := rng.Intn(m.total)
+	for i, weight := range m.weights {
+		if n < weight {
+			return m.actions[i]
+		}
+		n -= weight
+	}
+	return m.actions[len(m.actions)-1]
+}
+
+// Has reports whether the mix contains an action
+func (m *Mix) Has(action Action) bool {
+	for _, a := range m.actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the mix as percentages
+func (m *Mix) String() string {
+	parts := make([]string, len(m.actions))
+	for i, action := range m.actions {
+		parts[i] = fmt.Sprintf("%s %.0f%%", action, float64(m.weights[i])/float64(m.total)*100)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// Recorder tracks sent messages and collects delivery latencies and
+// errors from all synthetic clients
+type Recorder struct {
+	inFlight  map[string]time.Time // Message ID -> send time
+	latencies []time.Duration
+	errors    map[common.ErrorType]int
+	mutex     sync.Mutex
+
+	connected     atomic.Int64
+	registered    atomic.Int64
+	connectFailed atomic.Int64
+	sent          atomic.Int64
+	delivered     atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		inFlight: make(map[string]time.Time),
+		errors:   make(map[common.ErrorType]int),
+	}
+}
+
+// Sent records a message about to be written
+func (r *Recorder) Sent(id string, size int) {
+	r.mutex.Lock()
+	r.inFlight[id] = time.Now()
+	r.mutex.Unlock()
+	r.sent.Add(1)
+	r.bytesSent.Add(int64(size))
+}
+
+// Received records a delivered message; every delivery of a broadcast or
+// room message counts as a latency sample
+func (r *Recorder) Received(msg *common.Message, size int) {
+	r.bytesReceived.Add(int64(size))
+	if msg.ID == "" {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if sentAt, ok := r.inFlight[msg.ID]; ok {
+		r.latencies = append(r.latencies, time.Since(sentAt))
+		r.delivered.Add(1)
+	}
+}
+
+// Error records an error returned by the server
+func (r *Recorder) Error(code common.ErrorType) {
+	if code == "" {
+		code = common.ErrInternal
+	}
+	r.mutex.Lock()
+	r.errors[code]++
+	r.mutex.Unlock()
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p / 100)
+	return sorted[index]
+}
+
+// Report writes the results of a run that lasted elapsed
+func (r *Recorder) Report(w io.Writer, elapsed time.Duration) {
+	r.mutex.Lock()
+	latencies := append([]time.Duration(nil), r.latencies...)
+	errors := make(map[common.ErrorType]int, len(r.errors))
+	for code, count := range r.errors {
+		errors[code] = count
+	}
+	r.mutex.Unlock()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	seconds := elapsed.Seconds()
+	fmt.Fprintf(w, "\n=== Load test results (%v) ===\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Clients:     %d connected, %d registered, %d failed\n",
+		r.connected.Load(), r.registered.Load(), r.connectFailed.Load())
+	fmt.Fprintf(w, "Sent:        %d messages (%.1f/s), %s\n",
+		r.sent.Load(), float64(r.sent.Load())/seconds, common.FormatFileSize(r.bytesSent.Load()))
+	fmt.Fprintf(w, "Delivered:   %d messages (%.1f/s), %s received\n",
+		r.delivered.Load(), float64(r.delivered.Load())/seconds, common.FormatFileSize(r.bytesReceived.Load()))
+
+	if len(latencies) > 0 {
+		fmt.Fprintf(w, "Latency:     p50 %v, p90 %v, p99 %v, max %v\n",
+			percentile(latencies, 50).Round(time.Microsecond),
+			percentile(latencies, 90).Round(time.Microsecond),
+			percentile(latencies, 99).Round(time.Microsecond),
+			latencies[len(latencies)-1].Round(time.Microsecond))
+	}
+
+	if len(errors) > 0 {
+		codes := make([]string, 0, len(errors))
+		for code := range errors {
+			codes = append(codes, string(code))
+		}
+		sort.Strings(codes)
+		fmt.Fprintln(w, "Errors:")
+		for _, code := range codes {
+			fmt.Fprintf(w, "  %-12s %d\n", code, errors[common.ErrorType(code)])
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// SyntheticClient is a scripted chat client generating load
+type SyntheticClient struct {
+	Nickname string
+	conn     net.Conn
+	config   *Config
+	recorder *Recorder
+	rng      *rand.Rand
+	peers    []string
+	roomID   string
+	joined   chan string // Receives the room ID once the client is in the room
+	stats    chan string // Receives the server statistics text
+	mutex    sync.Mutex  // Guards writes to conn
+}
+
+// Dial connects a synthetic client and registers its nickname
+func Dial(config *Config, recorder *Recorder, nickname string, localAddr string) (*SyntheticClient, error) {
+	dialer := net.Dialer{Timeout: common.ConnectionTimeout}
+	if localAddr != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(localAddr)}
+	}
+	conn, err := dialer.Dial("tcp", config.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &SyntheticClient{
+		Nickname: nickname,
+		conn:     conn,
+		config:   config,
+		recorder: recorder,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		joined:   make(chan string, 1),
+		stats:    make(chan string, 1),
+	}
+	if err := sc.send(&common.Message{Type: common.TypeConnect, Content: nickname}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go sc.readLoop()
+	return sc, nil
+}
+
+// SetPeers sets the nicknames used for private messages and files
+func (sc *SyntheticClient) SetPeers(peers []string) {
+	for _, peer := range peers {
+		if peer != sc.Nickname {
+			sc.peers = append(sc.peers, peer)
+		}
+	}
+}
+
+// send encodes and writes a message
+func (sc *SyntheticClient) send(msg *common.Message) error {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	data, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
+	if msg.ID != "" {
+		sc.recorder.Sent(msg.ID, len(data)+1)
+	}
+	_, err = sc.conn.Write(append(data, '\n'))
+	return err
+}
+
+// readLoop records deliveries and errors until the connection closes
+func (sc *SyntheticClient) readLoop() {
+	scanner := bufio.NewScanner(sc.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), common.MaxScannerBuffer)
+
+	for scanner.Scan() {
+		data := scanner.Bytes()
+		msg, err := common.DecodeMessage(data)
+		if err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case common.TypeUserList:
+			// The full list is only sent once the nickname is registered
+			sc.recorder.registered.Add(1)
+		case common.TypeError:
+			sc.recorder.Error(msg.ErrorCode)
+		case common.TypeStats:
+			select {
+			case sc.stats <- msg.Content:
+			default:
+			}
+		case common.TypeRoom:
+			if msg.Action == common.RoomCreate || msg.Action == common.RoomJoin {
+				select {
+				case sc.joined <- msg.Room:
+				default:
+				}
+			}
+		default:
+			sc.recorder.Received(msg, len(data)+1)
+		}
+	}
+}
+
+// CreateRoom creates the shared load test room and returns its ID
+func (sc *SyntheticClient) CreateRoom(name string, timeout time.Duration) (string, error) {
+	if err := sc.send(&common.Message{Type: common.TypeRoom, Action: common.RoomCreate, Content: name}); err != nil {
+		return "", err
+	}
+	return sc.waitForRoom(timeout)
+}
+
+// JoinRoom joins the shared load test room
+func (sc *SyntheticClient) JoinRoom(roomID string, timeout time.Duration) error {
+	if err := sc.send(&common.Message{Type: common.TypeRoom, Action: common.RoomJoin, Room: roomID}); err != nil {
+		return err
+	}
+	_, err := sc.waitForRoom(timeout)
+	return err
+}
+
+// waitForRoom waits for the server to confirm a room creation or join
+func (sc *SyntheticClient) waitForRoom(timeout time.Duration) (string, error) {
+	select {
+	case roomID := <-sc.joined:
+		sc.roomID = roomID
+		return roomID, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("%s: no room confirmation within %v", sc.Nickname, timeout)
+	}
+}
+
+// ServerStats asks the server for its statistics
+func (sc *SyntheticClient) ServerStats(timeout time.Duration) (string, error) {
+	if err := sc.send(&common.Message{Type: common.TypeStats}); err != nil {
+		return "", err
+	}
+	select {
+	case stats := <-sc.stats:
+		return stats, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("no statistics within %v", timeout)
+	}
+}
+
+// Run sends messages at the configured rate until ctx is done
+func (sc *SyntheticClient) Run(ctx context.Context) {
+	interval := time.Duration(float64(time.Second) / sc.config.Rate)
+
+	// Spread the clients so they do not all send at the same instant
+	select {
+	case <-time.After(time.Duration(sc.rng.Int63n(int64(interval)))):
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sc.act(sc.config.Mix.Pick(sc.rng)); err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// act sends the traffic of one action
+func (sc *SyntheticClient) act(action Action) error {
+	content := fmt.Sprintf("load test message from %s", sc.Nickname)
+
+	switch action {
+	case ActionPrivate:
+		if len(sc.peers) == 0 {
+			return nil
+		}
+		peer := sc.peers[sc.rng.Intn(len(sc.peers))]
+		return sc.send(&common.Message{ID: common.NewMessageID(), Type: common.TypeText, Recipient: peer, Content: content})
+
+	case ActionRoom:
+		if sc.roomID == "" {
+			return nil
+		}
+		return sc.send(&common.Message{ID: common.NewMessageID(), Type: common.TypeText, Room: sc.roomID, Content: content})
+
+	case ActionFile:
+		if len(sc.peers) == 0 {
+			return nil
+		}
+		return sc.sendFile(sc.peers[sc.rng.Intn(len(sc.peers))])
+
+	default:
+		return sc.send(&common.Message{ID: common.NewMessageID(), Type: common.TypeText, Recipient: "*", Content: content})
+	}
+}
+
+// sendFile sends a file of random bytes in chunks
+func (sc *SyntheticClient) sendFile(peer string) error {
+	fileID := common.GenerateID("loadtest")
+	chunks := sc.config.FileChunks
+	init := &common.Message{
+		Type:        common.TypeFile,
+		Recipient:   peer,
+		FileID:      fileID,
+		Filename:    "loadtest.bin",
+		Filesize:    int64(chunks * common.FileChunkSize),
+		TotalChunks: chunks,
+	}
+	if err := sc.send(init); err != nil {
+		return err
+	}
+
+	data := make([]byte, common.FileChunkSize)
+	sc.rng.Read(data)
+	for i := 0; i < chunks; i++ {
+		chunk := &common.Message{
+			ID:          common.NewMessageID(),
+			Type:        common.TypeFileChunk,
+			Recipient:   peer,
+			FileID:      fileID,
+			ChunkNum:    i,
+			TotalChunks: chunks,
+			Data:        data,
+		}
+		if err := sc.send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection
+func (sc *SyntheticClient) Close() error {
+	sc.send(&common.Message{Type: common.TypeDisconnect})
+	return sc.conn.Close()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// apiSender is the sender of API messages that do not name one
+const apiSender = "API"
+
+// maxAPIBody bounds the size of an API request body
+const maxAPIBody = 64 * 1024
+
+// APIMessage is the body of POST /api/messages
+type APIMessage struct {
+	Sender    string `json:"sender,omitempty"`    // Shown as the sender, defaults to API
+	Recipient string `json:"recipient,omitempty"` // Nickname for a private message, empty or "*" for everyone
+	Room      string `json:"room,omitempty"`      // Room ID for a room message
+	Content   string `json:"content"`
+	Format    string `json:"format,omitempty"` // "markdown" for markup, empty for plain text
+}
+
+// APIRoom describes a room in GET /api/rooms
+type APIRoom struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Creator     string     `json:"creator"`
+	Members     []string   `json:"members"`
+	Ephemeral   bool       `json:"ephemeral,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIUser describes a connected user in GET /api/users
+type APIUser struct {
+	Nickname string            `json:"nickname"`
+	Status   common.UserStatus `json:"status"`
+	Rooms    []string          `json:"rooms"`
+}
+
+// apiError is the body of failed API requests
+type apiError struct {
+	Error *common.ChatError `json:"error"`
+}
+
+// APIHandler returns the HTTP API of the server; every request must carry
+// token as a bearer token
+func (s *Server) APIHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/messages", s.handleAPIMessage)
+	mux.HandleFunc("GET /api/rooms", s.handleAPIRooms)
+	mux.HandleFunc("GET /api/users", s.handleAPIUsers)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, common.ChatErrorf(common.ErrUnauthorized, "invalid API token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// StartAPI serves the HTTP API on the specified port in the background
+func (s *Server) StartAPI(port, token string) error {
+	if token == "" {
+		return fmt.Errorf("the HTTP API requires a token")
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %v", port, err)
+	}
+
+	s.apiServer = &http.Server{
+		Handler:           s.APIHandler(token),
+		ReadHeaderTimeout: common.RequestTimeout,
+		ReadTimeout:       common.RequestTimeout,
+		WriteTimeout:      common.RequestTimeout,
+	}
+	s.logger.Info("HTTP API started on port %s", port)
+	go func() {
+		if err := s.apiServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("HTTP API error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopAPI shuts the HTTP API down, letting running requests finish until ctx is done
+func (s *Server) stopAPI(ctx context.Context) {
+	if s.apiServer != nil {
+		s.apiServer.Shutdown(ctx)
+	}
+}
+
+// handleAPIMessage delivers a message from an external system
+func (s *Server) handleAPIMessage(w http.ResponseWriter, r *http.Request) {
+	var req APIMessage
+	body := http.MaxBytesReader(w, r.Body, maxAPIBody)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		writeAPIError(w, common.ChatErrorf(common.ErrValidation, "invalid request body: %v", err))
+		return
+	}
+
+	msg, err := s.SendAPIMessage(r.Context(), &req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusAccepted, msg)
+}
+
+// SendAPIMessage validates and delivers an API message like a text message
+// of a connected client and returns what was delivered
+func (s *Server) SendAPIMessage(ctx context.Context, req *APIMessage) (*common.Message, error) {
+	sender := req.Sender
+	if sender == "" {
+		sender = apiSender
+	}
+	if err := ValidateNickname(sender); err != nil {
+		return nil, err
+	}
+	// Messages must not impersonate connected users
+	if _, taken := s.GetClient(sender); taken {
+		return nil, common.ChatErrorf(common.ErrDuplicate, "Nickname %s is used by a connected user", sender)
+	}
+	if err := ValidateFormat(req.Format); err != nil {
+		return nil, err
+	}
+	content := req.Content
+	if req.Format == common.FormatMarkdown {
+		content = common.SanitizeMarkup(content)
+	}
+	if err := ValidateMessage(content); err != nil {
+		return nil, err
+	}
+	if err := s.rateLimiter.CanSendMessage("api/" + sender); err != nil {
+		return nil, err
+	}
+
+	msg := common.NewTextMessage(sender, req.Recipient, content)
+	msg.Format = req.Format
+	msg.ID = common.NewMessageID()
+	s.stats.RecordMessage()
+
+	switch {
+	case req.Room != "":
+		if _, exists := s.roomManager.GetRoom(req.Room); !exists {
+			return nil, common.ChatErrorf(common.ErrNotFound, "Room not found")
+		}
+		msg.Room = req.Room
+		msg.Recipient = ""
+		s.roomManager.BroadcastToRoom(ctx, s, req.Room, msg)
+	case req.Recipient == "" || req.Recipient == "*":
+		s.BroadcastMessage(ctx, msg, "")
+	default:
+		recipient, ok := s.GetClient(req.Recipient)
+		if !ok {
+			return nil, common.ChatErrorf(common.ErrNotFound, "User %s not found", req.Recipient)
+		}
+		recipient.SendMessage(msg)
+	}
+
+	s.logger.With(common.F("sender", sender)).Info("API message delivered")
+	return msg, nil
+}
+
+// handleAPIRooms lists all rooms
+func (s *Server) handleAPIRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := []APIRoom{}
+	for _, room := range s.roomManager.ListRooms() {
+		members := room.GetMembers()
+		sort.Strings(members)
+		info := APIRoom{
+			ID:          room.ID,
+			Name:        room.Name,
+			Description: room.GetDescription(),
+			Creator:     room.Creator,
+			Members:     members,
+			Ephemeral:   room.Ephemeral,
+			CreatedAt:   room.CreatedAt,
+		}
+		if !room.ExpiresAt.IsZero() {
+			expiresAt := room.ExpiresAt
+			info.ExpiresAt = &expiresAt
+		}
+		rooms = append(rooms, info)
+	}
+	writeAPIJSON(w, http.StatusOK, rooms)
+}
+
+// handleAPIUsers lists all registered users with their real status
+func (s *Server) handleAPIUsers(w http.ResponseWriter, r *http.Request) {
+	users := []APIUser{}
+	s.clients.Range(func(_ string, client *Client) bool {
+		rooms := []string{}
+		for _, room := range s.roomManager.GetUserRooms(client.Nickname) {
+			rooms = append(rooms, room.ID)
+		}
+		sort.Strings(rooms)
+		users = append(users, APIUser{Nickname: client.Nickname, Status: client.GetStatus(), Rooms: rooms})
+		return true
+	})
+	sort.Slice(users, func(i, j int) bool { return users[i].Nickname < users[j].Nickname })
+	writeAPIJSON(w, http.StatusOK, users)
+}
+
+// writeAPIJSON writes v as a JSON response
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes err with the HTTP status matching its error type
+func writeAPIError(w http.ResponseWriter, err error) {
+	var chatErr *common.ChatError
+	if !errors.As(err, &chatErr) {
+		chatErr = common.NewChatError(common.ErrInternal, err.Error())
+	}
+	if retryAfter, ok := chatErr.RetryAfter(); ok {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds()+0.999)))
+	}
+	writeAPIJSON(w, apiStatus(chatErr.Type), apiError{Error: chatErr})
+}
+
+// apiStatus maps an error type to an HTTP status code
+func apiStatus(errType common.ErrorType) int {
+	switch errType {
+	case common.ErrValidation:
+		return http.StatusBadRequest
+	case common.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case common.ErrNotFound:
+		return http.StatusNotFound
+	case common.ErrDuplicate:
+		return http.StatusConflict
+	case common.ErrRateLimit:
+		return http.StatusTooManyRequests
+	case common.ErrTimeout:
+		return http.StatusGatewayTimeout
+	case common.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
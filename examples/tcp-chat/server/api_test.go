@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+const testAPIToken = "secret"
+
+// apiRequest sends a request to the API handler of s
+func apiRequest(t *testing.T, s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.APIHandler(testAPIToken).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, token := range []string{"", "wrong"} {
+		rec := apiRequest(t, s, http.MethodGet, "/api/users", token, "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want %d", token, rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if rec := apiRequest(t, s, http.MethodGet, "/api/users", testAPIToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAPIMessages(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+	drain(alice)
+
+	rec := apiRequest(t, s, http.MethodPost, "/api/messages", testAPIToken, `{"sender":"ci-bot","content":"build passed"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("broadcast: status %d: %s", rec.Code, rec.Body)
+	}
+	for _, client := range []*Client{alice, bob} {
+		if got := findMessage(drain(client), common.TypeText); got == nil || got.Sender != "ci-bot" || got.Content != "build passed" {
+			t.Errorf("%s did not receive the announcement, got %v", client.Nickname, got)
+		}
+	}
+
+	rec = apiRequest(t, s, http.MethodPost, "/api/messages", testAPIToken, `{"recipient":"bob","content":"hi bob"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("private: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := findMessage(drain(bob), common.TypeText); got == nil || got.Sender != apiSender {
+		t.Errorf("bob did not receive the private message, got %v", got)
+	}
+	if got := findMessage(drain(alice), common.TypeText); got != nil {
+		t.Errorf("alice received a private message for bob: %v", got)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"malformed body", `{"content":`, http.StatusBadRequest},
+		{"empty content", `{"content":""}`, http.StatusBadRequest},
+		{"impersonation", `{"sender":"alice","content":"hi"}`, http.StatusConflict},
+		{"unknown user", `{"recipient":"carol","content":"hi"}`, http.StatusNotFound},
+		{"unknown room", `{"room":"nope","content":"hi"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := apiRequest(t, s, http.MethodPost, "/api/messages", testAPIToken, tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+}
+
+func TestAPIState(t *testing.T) {
+	s := newTestServer(t)
+	newTestClient(t, s, "bob")
+	alice := newTestClient(t, s, "alice")
+	setStatus(t, s, alice, common.StatusInvisible)
+
+	room := s.roomManager.CreateRoom("lounge", "alice")
+	room.AddMember("alice")
+
+	rec := apiRequest(t, s, http.MethodGet, "/api/users", testAPIToken, "")
+	var users []APIUser
+	if err := json.NewDecoder(rec.Body).Decode(&users); err != nil {
+		t.Fatalf("decode users: %v", err)
+	}
+	if len(users) != 2 || users[0].Nickname != "alice" || users[1].Nickname != "bob" {
+		t.Fatalf("users %+v, want alice and bob", users)
+	}
+	if users[0].Status != common.StatusInvisible || len(users[0].Rooms) != 1 || users[0].Rooms[0] != room.ID {
+		t.Errorf("alice is %+v, want invisible in %s", users[0], room.ID)
+	}
+
+	rec = apiRequest(t, s, http.MethodGet, "/api/rooms", testAPIToken, "")
+	var rooms []APIRoom
+	if err := json.NewDecoder(rec.Body).Decode(&rooms); err != nil {
+		t.Fatalf("decode rooms: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].Name != "lounge" || len(rooms[0].Members) != 1 {
+		t.Errorf("rooms %+v, want lounge with alice", rooms)
+	}
+}
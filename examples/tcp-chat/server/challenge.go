@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"tcp-chat/common"
+)
+
+// Challenge modes
+const (
+	ChallengeOff   = "off"
+	ChallengeMath  = "math"
+	ChallengeToken = "token"
+)
+
+// ChallengeConfig configures the anti-bot check a connection has to pass
+// before its nickname is registered
+type ChallengeConfig struct {
+	Mode  string // off, math or token
+	Token string // Shared out of band, required in token mode
+}
+
+// Validate checks that the configuration is usable
+func (cc ChallengeConfig) Validate() error {
+	switch cc.Mode {
+	case "", ChallengeOff, ChallengeMath:
+		return nil
+	case ChallengeToken:
+		if cc.Token == "" {
+			return fmt.Errorf("challenge mode %q requires a token", cc.Mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown challenge mode: %s (use off, math or token)", cc.Mode)
+	}
+}
+
+// Enabled reports whether connections have to answer a challenge
+func (cc ChallengeConfig) Enabled() bool {
+	return cc.Mode == ChallengeMath || cc.Mode == ChallengeToken
+}
+
+// challenge is a question pending for one connection
+type challenge struct {
+	question string
+	answer   string
+	attempts int
+}
+
+// newChallenge creates a question according to the configured mode
+func (cc ChallengeConfig) newChallenge() *challenge {
+	if cc.Mode == ChallengeToken {
+		return &challenge{
+			question: common.T("Enter the access token published by the server operator"),
+			answer:   cc.Token,
+		}
+	}
+
+	a, b := rand.Intn(20)+1, rand.Intn(20)+1
+	if rand.Intn(2) == 0 {
+		return &challenge{
+			question: common.T("What is %d + %d?", a, b),
+			answer:   strconv.Itoa(a + b),
+		}
+	}
+	return &challenge{
+		question: common.T("What is %d times %d?", a, b),
+		answer:   strconv.Itoa(a * b),
+	}
+}
+
+// check compares an answer in constant time so tokens cannot be guessed byte by byte
+func (ch *challenge) check(answer string) bool {
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(answer)), []byte(ch.answer)) == 1
+}
+
+// SetChallenge configures the challenge required from new connections
+func (s *Server) SetChallenge(config ChallengeConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	s.challengeConfig = config
+	return nil
+}
+
+// requireChallenge asks an unverified client to answer a challenge before
+// nickname is registered and reports whether registration has to wait
+func (s *Server) requireChallenge(client *Client, nickname string) bool {
+	if !s.challengeConfig.Enabled() || client.IsVerified() {
+		return false
+	}
+
+	ch := s.challengeConfig.newChallenge()
+	client.StartChallenge(ch, nickname)
+	client.Logger().Debug("Challenge sent (%s)", s.challengeConfig.Mode)
+	s.sendChallenge(client, ch)
+	return true
+}
+
+// sendChallenge sends the pending question to the client
+func (s *Server) sendChallenge(client *Client, ch *challenge) {
+	client.SendMessage(&common.Message{
+		Type:    common.TypeChallenge,
+		Sender:  "Server",
+		Content: ch.question,
+	})
+}
+
+// handleChallengeAnswer verifies an answer and registers the nickname the
+// client connected with once it is correct
+func (s *Server) handleChallengeAnswer(ctx context.Context, client *Client, msg *common.Message) {
+	ch, nickname := client.PendingChallenge()
+	if ch == nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrValidation, "No challenge is pending"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	if ch.check(msg.Content) {
+		client.PassChallenge()
+		client.Logger().Info("Challenge passed")
+		s.completeConnect(ctx, client, nickname)
+		return
+	}
+
+	ch.attempts++
+	if ch.attempts >= common.ChallengeAttempts {
+		client.Logger().Warn("Challenge failed %d times, closing connection", ch.attempts)
+		errMsg := common.NewErrorMessageFromError("Server", "", common.ChatErrorf(common.ErrUnauthorized, "Too many wrong answers"))
+		client.SendMessage(errMsg)
+		client.closeAfterError()
+		return
+	}
+
+	errMsg := common.NewErrorMessageFromError("Server", "", common.ChatErrorf(common.ErrUnauthorized, "Wrong answer, %d attempt(s) left", common.ChallengeAttempts-ch.attempts))
+	client.SendMessage(errMsg)
+	s.sendChallenge(client, ch)
+}
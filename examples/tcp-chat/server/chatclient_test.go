@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"tcp-chat/chatclient"
+	"tcp-chat/common"
+)
+
+// libraryClient is a client driven through the chatclient package, the way
+// programs other than the terminal client talk to the server
+type libraryClient struct {
+	t        *testing.T
+	conn     *chatclient.Connection
+	messages <-chan *common.Message
+}
+
+// connectLibraryClient connects and registers with chatclient, waiting for
+// the server to confirm the registration
+func connectLibraryClient(t *testing.T, addr, nickname string) *libraryClient {
+	t.Helper()
+	conn := chatclient.NewConnection(nickname)
+	lc := &libraryClient{t: t, conn: conn, messages: conn.Subscribe()}
+	if err := conn.Connect(addr); err != nil {
+		t.Fatalf("Connect(%s): %v", nickname, err)
+	}
+	t.Cleanup(conn.Disconnect)
+	lc.expect("registration", func(msg *common.Message) bool {
+		return msg.Type == common.TypeText && msg.Content == common.T("Connected successfully")
+	})
+	return lc
+}
+
+// expect skips messages until one matches, failing the test on timeout
+func (lc *libraryClient) expect(what string, match func(*common.Message) bool) *common.Message {
+	lc.t.Helper()
+	timeout := time.After(expectTimeout)
+	for {
+		select {
+		case msg := <-lc.messages:
+			if match(msg) {
+				return msg
+			}
+		case <-timeout:
+			lc.t.Fatalf("%s: no %s within %v", lc.conn.Nickname(), what, expectTimeout)
+		}
+	}
+}
+
+func (lc *libraryClient) expectText(sender, content string) *common.Message {
+	lc.t.Helper()
+	return lc.expect("text "+content, func(msg *common.Message) bool {
+		return msg.Type == common.TypeText && msg.Sender == sender && msg.Content == content
+	})
+}
+
+func (lc *libraryClient) expectRoom(action common.RoomAction) *common.Message {
+	lc.t.Helper()
+	return lc.expect("room "+string(action), func(msg *common.Message) bool {
+		return msg.Type == common.TypeRoom && msg.Action == action
+	})
+}
+
+func TestChatClientMessaging(t *testing.T) {
+	addr := startServer(t)
+	alice := connectLibraryClient(t, addr, "alice")
+	bob := connectLibraryClient(t, addr, "bob")
+	alice.expect("bob joining", func(msg *common.Message) bool {
+		return msg.Type == common.TypeUserJoined && msg.Sender == "bob"
+	})
+	if status, ok := alice.conn.UserStatus("bob"); !ok || status != common.StatusActive {
+		t.Errorf("Alice sees bob as %q, %v", status, ok)
+	}
+
+	if err := alice.conn.SendBroadcastMessage("hello all"); err != nil {
+		t.Fatalf("SendBroadcastMessage: %v", err)
+	}
+	bob.expectText("alice", "hello all")
+
+	if err := bob.conn.Send(common.NewTextMessage("", "alice", "psst")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if msg := alice.expectText("bob", "psst"); msg.Recipient != "alice" {
+		t.Errorf("Private message addressed to %q", msg.Recipient)
+	}
+}
+
+func TestChatClientRooms(t *testing.T) {
+	addr := startServer(t)
+	alice := connectLibraryClient(t, addr, "alice")
+	bob := connectLibraryClient(t, addr, "bob")
+
+	if err := alice.conn.CreateRoom("lobby"); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	roomID := alice.expectRoom(common.RoomCreate).Room
+	if err := bob.conn.JoinRoom(roomID); err != nil {
+		t.Fatalf("JoinRoom: %v", err)
+	}
+	if joined := bob.expectRoom(common.RoomJoin); joined.Room != roomID {
+		t.Fatalf("Bob joined %q, want %q", joined.Room, roomID)
+	}
+
+	if err := bob.conn.SendRoomMessage(roomID, "hi room"); err != nil {
+		t.Fatalf("SendRoomMessage: %v", err)
+	}
+	if msg := alice.expectText("bob", "hi room"); msg.Room != roomID {
+		t.Errorf("Room message in %q, want %q", msg.Room, roomID)
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"log"
 	"tcp-chat/common"
+	"time"
 )
 
 // CleanupManager handles periodic cleanup of resources
@@ -10,6 +10,7 @@ type CleanupManager struct {
 	server   *Server
 	ticker   *time.Ticker
 	stopChan chan bool
+	logger   *common.Logger
 }
 
 // NewCleanupManager creates a new cleanup manager
@@ -18,6 +19,7 @@ func NewCleanupManager(server *Server) *CleanupManager {
 		server:   server,
 		ticker:   time.NewTicker(1 * time.Minute),
 		stopChan: make(chan bool),
+		logger:   common.GetLogger("cleanup"),
 	}
 }
 
@@ -48,42 +50,44 @@ func (cm *CleanupManager) run() {
 // cleanupFileTransfers removes stale file transfers
 func (cm *CleanupManager) cleanupFileTransfers() {
 	now := time.Now()
-	var toDelete []string
 
 	// Find stale transfers
-	cm.server.fileTransfers.Range(func(key, value interface{}) bool {
-		fileID := key.(string)
-		ft := value.(*common.FileTransfer)
-
+	cm.server.fileTransfers.Range(func(fileID string, ft *common.FileTransfer) bool {
 		// Check if transfer is older than timeout
 		if now.Sub(ft.StartTime) > common.FileTransferTimeout {
-			toDelete = append(toDelete, fileID)
-			log.Printf("Cleaning up stale file transfer: %s", fileID)
+			cm.logger.With(common.F("file_id", fileID)).Info("Cleaning up stale file transfer")
+
+			// Cancel the transfer on both sides, a paused sender would wait forever
+			cancelMsg := &common.Message{
+				Type:      common.TypeFileControl,
+				Sender:    "Server",
+				FileID:    fileID,
+				Filename:  ft.Filename,
+				Content:   common.FileCancel,
+				Timestamp: now,
+			}
 
 			// Notify sender about timeout
 			if sender, ok := cm.server.GetClient(ft.Sender); ok {
-				errMsg := common.NewErrorMessage("Server", ft.Sender,
-					"File transfer timed out: "+ft.Filename)
+				errMsg := common.NewErrorMessageFromError("Server", ft.Sender,
+					common.ChatErrorf(common.ErrTimeout, "File transfer timed out: %s", ft.Filename))
 				sender.SendMessage(errMsg)
+				sender.SendMessage(cancelMsg)
 			}
 
 			// Notify recipient about timeout
 			if recipient, ok := cm.server.GetClient(ft.Recipient); ok {
-				errMsg := common.NewErrorMessage("Server", ft.Recipient,
-					"File transfer timed out: "+ft.Filename)
+				errMsg := common.NewErrorMessageFromError("Server", ft.Recipient,
+					common.ChatErrorf(common.ErrTimeout, "File transfer timed out: %s", ft.Filename))
 				recipient.SendMessage(errMsg)
+				recipient.SendMessage(cancelMsg)
 			}
 
-			// Clean up rate limiter
-			cm.server.rateLimiter.RemoveFileTransfer(ft.Sender)
+			// Clean up rate limiter and stored chunks
+			cm.server.finishFileTransfer(ft)
 		}
 		return true
 	})
-
-	// Delete stale transfers
-	for _, fileID := range toDelete {
-		cm.server.fileTransfers.Delete(fileID)
-	}
 }
 
 // cleanupEmptyRooms removes rooms that have been empty for too long
@@ -101,7 +105,7 @@ func (cm *CleanupManager) cleanupEmptyRooms() {
 		// Remove rooms that are empty and older than timeout
 		if memberCount == 0 && now.Sub(createdAt) > common.EmptyRoomTimeout {
 			toDelete = append(toDelete, roomID)
-			log.Printf("Cleaning up empty room: %s (%s)", room.Name, roomID)
+			cm.logger.With(common.F("room_id", roomID), common.F("room", room.Name)).Info("Cleaning up empty room")
 		}
 	}
 	cm.server.roomManager.mutex.RUnlock()
@@ -2,37 +2,102 @@ package main
 
 import (
 	"bufio"
-	"log"
+	"context"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"tcp-chat/common"
+	"training.pl/go/common/bus"
 )
 
-// Client represents a connected client
+// closeFlushTimeout bounds how long a rejected client gets to receive the
+// error explaining why it is disconnected
+const closeFlushTimeout = time.Second
+
+// sendBuffer is the number of messages queued for a client before new ones
+// are dropped
+const sendBuffer = 256
+
+// Client represents a connected client. SendChan receives from the client's
+// subscription to the bus of the server; closing a client unsubscribes it, so
+// messages sent after closing are dropped and SendChan is closed once the
+// queued ones were read.
 type Client struct {
-	ID         string
-	Nickname   string
-	Conn       net.Conn
-	RemoteAddr string
-	Status     common.UserStatus
-	Rooms      map[string]bool
-	SendChan   chan *common.Message
-	Server     *Server
-	mutex      sync.RWMutex
+	ID          string
+	Nickname    string
+	Conn        net.Conn
+	RemoteAddr  string
+	Status      common.UserStatus
+	Away        string // Reason given with the away status
+	Rooms       map[string]bool
+	Ignored     map[string]bool
+	awayReplied map[string]bool // Senders already auto-replied to while away
+	SendChan    <-chan *common.Message
+	Server      *Server
+	logger      *common.Logger
+	mutex       sync.RWMutex
+
+	// Lifecycle; ctx is derived from the server context and cancelled when
+	// the client is closed, aborting whatever the client is doing
+	ctx          context.Context
+	cancel       context.CancelFunc
+	closed       atomic.Bool
+	closeOnce    sync.Once
+	subscription *bus.Subscription[*common.Message] // Joins the topic of all clients once registered
+	writerDone   chan struct{}                      // Closed when WritePump has exited
+	writeBatch   int                                // Bytes WritePump collects before writing them at once
+
+	// Connect challenge, see challenge.go
+	challenge       *challenge
+	pendingNickname string
+	verified        bool
 }
 
 // NewClient creates a new client instance
 func NewClient(conn net.Conn, server *Server) *Client {
-	return &Client{
-		ID:       common.GenerateID("client"),
-		Conn:     conn,
-		Status:   common.StatusActive,
-		Rooms:    make(map[string]bool),
-		SendChan: make(chan *common.Message, 256),
-		Server:   server,
+	ctx, cancel := context.WithCancel(server.ctx)
+	c := &Client{
+		ID:         common.GenerateID("client"),
+		Conn:       conn,
+		Status:     common.StatusActive,
+		Rooms:      make(map[string]bool),
+		Ignored:    make(map[string]bool),
+		Server:     server,
+		writerDone: make(chan struct{}),
+		writeBatch: common.WriteBatchSize,
+		logger:     server.logger.Component("client").With(common.F("remote_addr", conn.RemoteAddr().String())),
+		ctx:        ctx,
+		cancel:     cancel,
 	}
+	c.subscription = server.bus.Subscribe(bus.Options[*common.Message]{Buffer: sendBuffer, Policy: bus.DropNewest, Filter: c.accepts})
+	c.SendChan = c.subscription.C()
+
+	// Unblock the read pump as soon as the server or the client is cancelled
+	context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	return c
+}
+
+// Context returns the client's context, cancelled when the client is closed
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// Logger returns the client's logger carrying its address and nickname
+func (c *Client) Logger() *common.Logger {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.logger
+}
+
+// SetLogger replaces the client's logger
+func (c *Client) SetLogger(logger *common.Logger) {
+	c.mutex.Lock()
+	c.logger = logger
+	c.mutex.Unlock()
 }
 
 // GetStatus returns the client's current status
@@ -49,6 +114,36 @@ func (c *Client) SetStatus(status common.UserStatus) {
 	c.mutex.Unlock()
 }
 
+// GetAway returns the away message, empty unless the client is away
+func (c *Client) GetAway() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Away
+}
+
+// SetAway sets the away message and forgets who was told about it
+func (c *Client) SetAway(message string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Away = message
+	c.awayReplied = nil
+}
+
+// ShouldAutoReply reports whether sender has not yet been told that the
+// client is away; every sender gets the away message once
+func (c *Client) ShouldAutoReply(sender string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Status != common.StatusAway || c.awayReplied[sender] {
+		return false
+	}
+	if c.awayReplied == nil {
+		c.awayReplied = make(map[string]bool)
+	}
+	c.awayReplied[sender] = true
+	return true
+}
+
 // AddRoom adds a room to the client's room list
 func (c *Client) AddRoom(roomID string) {
 	c.mutex.Lock()
@@ -70,19 +165,76 @@ func (c *Client) IsInRoom(roomID string) bool {
 	return c.Rooms[roomID]
 }
 
+// SetIgnored replaces the list of users the client does not want to hear from
+func (c *Client) SetIgnored(nicknames []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Ignored = make(map[string]bool, len(nicknames))
+	for _, nickname := range nicknames {
+		c.Ignored[nickname] = true
+	}
+}
+
+// IsIgnoring checks if the client ignores a user
+func (c *Client) IsIgnoring(nickname string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Ignored[nickname]
+}
+
+// StartChallenge records a challenge the client has to answer before
+// nickname is registered
+func (c *Client) StartChallenge(ch *challenge, nickname string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.challenge = ch
+	c.pendingNickname = nickname
+}
+
+// PendingChallenge returns the unanswered challenge and the nickname waiting for it
+func (c *Client) PendingChallenge() (*challenge, string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.challenge, c.pendingNickname
+}
+
+// PassChallenge marks the client as verified
+func (c *Client) PassChallenge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.challenge = nil
+	c.verified = true
+}
+
+// IsVerified checks if the client has answered its challenge
+func (c *Client) IsVerified() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.verified
+}
+
+// accepts filters the messages of the client's subscription, dropping user
+// content from ignored senders; server notices always pass
+func (c *Client) accepts(msg *common.Message) bool {
+	switch msg.Type {
+	case common.TypeText, common.TypeInvite, common.TypeFile, common.TypeFileChunk:
+		return !c.IsIgnoring(msg.Sender)
+	}
+	return true
+}
+
 // SendMessage sends a message to the client
 func (c *Client) SendMessage(msg *common.Message) {
-	select {
-	case c.SendChan <- msg:
-	default:
-		log.Printf("Client %s send channel full, dropping message", c.Nickname)
+	dropped := c.subscription.Dropped()
+	if !c.subscription.Deliver(context.Background(), msg) && c.subscription.Dropped() > dropped {
+		c.Logger().Warn("Send channel full, dropping message")
 	}
 }
 
 // ReadPump reads messages from the client connection
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Server.UnregisterClient(c)
+		c.Server.UnregisterClient(c.Server.ctx, c)
 		c.Close()
 	}()
 
@@ -93,9 +245,11 @@ func (c *Client) ReadPump() {
 		// Reset read deadline on successful read
 		c.Conn.SetReadDeadline(time.Now().Add(common.ReadTimeout))
 		data := scanner.Bytes()
+		c.Server.stats.RecordReceived(len(data) + 1)
 		msg, err := common.DecodeMessage(data)
 		if err != nil {
-			log.Printf("Error decoding message from %s: %v", c.Nickname, err)
+			c.Logger().Warn("Error decoding message: %v", err)
+			c.SendMessage(common.NewErrorMessageFromError("Server", c.Nickname, err))
 			continue
 		}
 
@@ -103,46 +257,69 @@ func (c *Client) ReadPump() {
 		msg.Sender = c.Nickname
 		msg.Timestamp = time.Now()
 
-		// Handle the message
-		if err := c.Server.HandleMessage(c, msg); err != nil {
-			log.Printf("Error handling message from %s: %v", c.Nickname, err)
+		// Handle the message within the per-request deadline
+		ctx, cancel := context.WithTimeout(c.ctx, common.RequestTimeout)
+		err = c.Server.HandleMessage(ctx, c, msg)
+		cancel()
+		if err != nil {
+			c.Logger().Warn("Error handling message: %v", err)
 			// Send error message back to client
-			errMsg := common.NewErrorMessage("Server", c.Nickname, err.Error())
+			errMsg := common.NewErrorMessageFromError("Server", c.Nickname, err)
 			c.SendMessage(errMsg)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading from %s: %v", c.Nickname, err)
+		c.Logger().Info("Read error: %v", err)
 	}
 }
 
-// WritePump writes messages to the client connection
+// WritePump writes messages to the client connection. Messages queued close
+// together are collected and written at once, common.WriteFlushInterval after
+// the first of them or as soon as writeBatch bytes were collected, so a burst
+// of broadcasts costs one write instead of one per message.
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(30 * time.Second)
+	flushTimer := time.NewTimer(common.WriteFlushInterval)
+	flushTimer.Stop()
 	defer func() {
 		ticker.Stop()
+		flushTimer.Stop()
 		c.Conn.Close()
+		close(c.writerDone)
 	}()
 
+	var batch []byte
+	flush := func() bool {
+		flushTimer.Stop()
+		ok := c.write(batch)
+		batch = batch[:0]
+		return ok
+	}
+
 	for {
 		select {
+		case <-c.ctx.Done():
+			return
+
 		case msg, ok := <-c.SendChan:
+			// Closed after the messages queued before the client was closed
 			if !ok {
+				flush()
 				return
 			}
-
-			data, err := msg.Encode()
-			if err != nil {
-				log.Printf("Error encoding message: %v", err)
-				continue
+			pending := len(batch) > 0
+			batch = c.appendMessage(batch, msg)
+			if len(batch) >= c.writeBatch {
+				if !flush() {
+					return
+				}
+			} else if !pending {
+				flushTimer.Reset(common.WriteFlushInterval)
 			}
 
-			// Set write deadline
-			c.Conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
-
-			if _, err := c.Conn.Write(append(data, '\n')); err != nil {
-				log.Printf("Error writing to %s: %v", c.Nickname, err)
+		case <-flushTimer.C:
+			if !flush() {
 				return
 			}
 
@@ -152,38 +329,87 @@ func (c *Client) WritePump() {
 				Type:      common.TypeAck,
 				Timestamp: time.Now(),
 			}
-
-			data, _ := ping.Encode()
-
-			// Set write deadline for ping
-			c.Conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
-
-			if _, err := c.Conn.Write(append(data, '\n')); err != nil {
+			batch = c.appendMessage(batch, ping)
+			if !flush() {
 				return
 			}
 		}
 	}
 }
 
+// appendMessage appends an encoded message line to a batch, a message that
+// can't be encoded is left out
+func (c *Client) appendMessage(batch []byte, msg *common.Message) []byte {
+	data, err := msg.Encode()
+	if err != nil {
+		c.Logger().Error("Error encoding message: %v", err)
+		return batch
+	}
+	return append(append(batch, data...), '\n')
+}
+
+// write sends a batch of messages and reports whether the connection is
+// still usable
+func (c *Client) write(batch []byte) bool {
+	if len(batch) == 0 {
+		return true
+	}
+
+	// Set write deadline
+	c.Conn.SetWriteDeadline(time.Now().Add(common.WriteTimeout))
+
+	n, err := c.Conn.Write(batch)
+	c.Server.stats.RecordSent(n)
+	if err != nil {
+		c.Logger().Info("Write error: %v", err)
+		return false
+	}
+	return true
+}
+
 // Start begins the client's read and write pumps
 func (c *Client) Start() {
 	go c.WritePump()
 	go c.ReadPump()
 }
 
-// Close properly closes the client connection and channels
+// IsClosed reports whether Close or Shutdown was called
+func (c *Client) IsClosed() bool {
+	return c.closed.Load()
+}
+
+// beginClose stops accepting new messages and tells WritePump to finish
+func (c *Client) beginClose() {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		c.subscription.Unsubscribe()
+	})
+}
+
+// Close closes the connection immediately, queued messages are dropped
 func (c *Client) Close() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.beginClose()
+	c.cancel()
+}
 
-	// Close send channel to signal WritePump to exit
-	if c.SendChan != nil {
-		close(c.SendChan)
-		c.SendChan = nil
-	}
+// Shutdown stops accepting messages, lets WritePump deliver the ones already
+// queued and closes the connection. The connection is closed when ctx ends
+// even if the queue was not flushed, in which case ctx.Err() is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.beginClose()
 
-	// Close connection
-	if c.Conn != nil {
-		c.Conn.Close()
+	defer c.cancel()
+	select {
+	case <-c.writerDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
+
+// closeAfterError closes the connection once a pending error reached the client
+func (c *Client) closeAfterError() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeFlushTimeout)
+	defer cancel()
+	c.Shutdown(ctx)
+}
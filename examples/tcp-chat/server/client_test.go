@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+// broadcastRound is the number of messages broadcast before waiting for every
+// client to read them, below sendBuffer so none is dropped
+const broadcastRound = 100
+
+// connectClients connects clients to a server over loopback TCP, subscribed
+// to broadcasts with their write pumps running. Each one calls round.Done
+// after reading broadcastRound messages.
+func connectClients(b *testing.B, s *Server, clients, writeBatch int, round *sync.WaitGroup) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	for range clients {
+		peer, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			b.Fatalf("dial: %v", err)
+		}
+		b.Cleanup(func() { peer.Close() })
+		conn, err := listener.Accept()
+		if err != nil {
+			b.Fatalf("accept: %v", err)
+		}
+
+		client := NewClient(conn, s)
+		client.writeBatch = writeBatch
+		client.subscription.Join(topicAll)
+		go client.WritePump()
+		go func() {
+			lines := bufio.NewScanner(peer)
+			for read := 1; lines.Scan(); read++ {
+				if read%broadcastRound == 0 {
+					round.Done()
+				}
+			}
+		}()
+	}
+}
+
+// benchmarkBroadcast broadcasts to clients reading over TCP, writeBatch 0
+// writes every message on its own
+func benchmarkBroadcast(b *testing.B, clients, writeBatch int) {
+	s := NewServer()
+	b.Cleanup(s.cancel)
+	var round sync.WaitGroup
+	connectClients(b, s, clients, writeBatch, &round)
+	msg := common.NewBroadcastMessage("alice", "hello everyone")
+
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += broadcastRound {
+		round.Add(clients)
+		for range broadcastRound {
+			s.BroadcastMessage(context.Background(), msg, "")
+		}
+		round.Wait()
+	}
+	b.StopTimer()
+	rounded := (b.N + broadcastRound - 1) / broadcastRound * broadcastRound
+	b.ReportMetric(float64(rounded*clients)/b.Elapsed().Seconds(), "deliveries/s")
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{10, 1000} {
+		b.Run(fmt.Sprintf("%d/unbatched", clients), func(b *testing.B) {
+			benchmarkBroadcast(b, clients, 0)
+		})
+		b.Run(fmt.Sprintf("%d/batched", clients), func(b *testing.B) {
+			benchmarkBroadcast(b, clients, common.WriteBatchSize)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+	shared "training.pl/go/common"
+)
+
+// DedupCache remembers recently handled message IDs so messages that a
+// client sends again after reconnecting are not delivered twice. Entries
+// expire after the window and the least recently seen are evicted once the
+// cache is full.
+type DedupCache struct {
+	seen  *shared.Cache[string, struct{}]
+	mutex sync.Mutex // Makes checking and recording an ID one step
+}
+
+// NewDedupCache creates a cache remembering up to maxSize IDs for window
+func NewDedupCache(window time.Duration, maxSize int) *DedupCache {
+	return &DedupCache{seen: shared.NewCache[string, struct{}](maxSize).WithTTL(window)}
+}
+
+// Seen records a message ID from sender and reports whether it was already
+// handled within the window
+func (dc *DedupCache) Seen(sender, id string) bool {
+	key := sender + "\x00" + id
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if _, ok := dc.seen.Get(key); ok {
+		return true
+	}
+	dc.seen.Set(key, struct{}{})
+	return false
+}
+
+// Len returns the number of remembered message IDs
+func (dc *DedupCache) Len() int {
+	dc.seen.Prune()
+	return dc.seen.Len()
+}
+
+// isDuplicate checks a message ID against the server's dedup cache; chunks
+// are deduplicated by the chunk assembler instead and pings are harmless to repeat
+func (s *Server) isDuplicate(client *Client, msg *common.Message) bool {
+	if msg.ID == "" || msg.Type == common.TypeFileChunk || msg.Type == common.TypePing {
+		return false
+	}
+	if !s.dedup.Seen(client.Nickname, msg.ID) {
+		return false
+	}
+	client.Logger().With(common.F("message_id", msg.ID)).Debug("Dropping duplicate %s message", msg.Type)
+	s.stats.RecordDuplicate()
+	return true
+}
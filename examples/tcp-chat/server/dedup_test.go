@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	dc := NewDedupCache(time.Hour, 2)
+	if dc.Seen("alice", "1") || !dc.Seen("alice", "1") {
+		t.Fatal("Expected the second sighting of an ID to be a duplicate")
+	}
+	if dc.Seen("bob", "1") {
+		t.Error("IDs of different senders collided")
+	}
+	dc.Seen("alice", "2") // Evicts alice's first ID, the least recently seen
+	if dc.Len() != 2 || !dc.Seen("bob", "1") || dc.Seen("alice", "1") {
+		t.Errorf("Expected the least recently seen ID to be evicted, %d IDs left", dc.Len())
+	}
+}
+
+func TestDedupCacheExpiry(t *testing.T) {
+	dc := NewDedupCache(10*time.Millisecond, 10)
+	dc.Seen("alice", "1")
+	time.Sleep(20 * time.Millisecond)
+	if dc.Seen("alice", "1") {
+		t.Error("Expected the ID to be forgotten after the window")
+	}
+}
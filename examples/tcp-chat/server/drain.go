@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// DefaultDrainTimeout is the drain deadline used when none is given
+const DefaultDrainTimeout = 5 * time.Minute
+
+// drainReminder is how long before the deadline clients are reminded
+const drainReminder = time.Minute
+
+// Drain puts the server into maintenance mode: new connections are refused,
+// connected clients are told when the server goes down, and the server shuts
+// down once everybody has left or the deadline passes
+func (s *Server) Drain(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("drain timeout must be positive")
+	}
+	if !s.draining.CompareAndSwap(false, true) {
+		return fmt.Errorf("server is already draining")
+	}
+
+	deadline := time.Now().Add(timeout)
+	s.regMutex.Lock()
+	s.drainDeadline = deadline
+	s.regMutex.Unlock()
+
+	s.logger.Info("Draining connections, shutdown at %s", deadline.Format("15:04:05"))
+	notice := common.NewBroadcastMessage("Server", common.T("The server is going down for maintenance at %s (in %v). New connections are refused.",
+		deadline.Format("15:04:05"), timeout.Round(time.Second)))
+	s.BroadcastMessage(s.ctx, notice, "")
+
+	go s.waitForDrain(deadline)
+	return nil
+}
+
+// IsDraining reports whether the server is in drain mode
+func (s *Server) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// waitForDrain stops the server when no clients remain or the deadline passes
+func (s *Server) waitForDrain(deadline time.Time) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	reminded := time.Until(deadline) <= drainReminder
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case now := <-ticker.C:
+			if s.ClientCount() == 0 {
+				s.logger.Info("All clients disconnected, finishing drain")
+				s.requestStop()
+				return
+			}
+			if now.After(deadline) {
+				s.logger.Warn("Drain deadline passed with %d client(s) connected", s.ClientCount())
+				s.requestStop()
+				return
+			}
+			if !reminded && deadline.Sub(now) <= drainReminder {
+				reminded = true
+				notice := common.NewBroadcastMessage("Server", common.T("The server shuts down in %v", deadline.Sub(now).Round(time.Second)))
+				s.BroadcastMessage(s.ctx, notice, "")
+			}
+		}
+	}
+}
+
+// requestStop triggers the graceful shutdown handled by handleShutdown
+func (s *Server) requestStop() {
+	s.stopOnce.Do(func() {
+		close(s.stopRequest)
+	})
+}
+
+// rejectDraining tells a new connection that the server is in maintenance
+func (s *Server) rejectDraining(conn net.Conn) {
+	s.regMutex.Lock()
+	deadline := s.drainDeadline
+	s.regMutex.Unlock()
+
+	err := common.ChatErrorf(common.ErrUnavailable, "The server is in maintenance mode until %s, please try again later",
+		deadline.Format("15:04:05"))
+	rejectConnection(conn, err.WithDetail("retry_after", time.Until(deadline).Round(time.Second).String()))
+}
+
+// ClientCount returns the number of registered clients
+func (s *Server) ClientCount() int {
+	count := 0
+	s.clients.Range(func(string, *Client) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// runConsole executes admin commands typed on the server terminal
+func (s *Server) runConsole(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "drain":
+			timeout := DefaultDrainTimeout
+			if len(fields) > 1 {
+				parsed, err := time.ParseDuration(fields[1])
+				if err != nil {
+					fmt.Printf("Invalid duration: %v\n", err)
+					continue
+				}
+				timeout = parsed
+			}
+			if err := s.Drain(timeout); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else {
+				fmt.Printf("Draining, shutdown in %v at the latest\n", timeout)
+			}
+
+		case "status":
+			state := "running"
+			if s.IsDraining() {
+				state = "draining"
+			}
+			fmt.Printf("%s, %d client(s) connected\n", state, s.ClientCount())
+
+		case "stats":
+			fmt.Println(s.Snapshot())
+
+		case "help":
+			fmt.Println("Commands:")
+			fmt.Println("  drain [duration]  - Refuse new connections and shut down when empty or after duration (default 5m)")
+			fmt.Println("  status            - Show server state and connected clients")
+			fmt.Println("  stats             - Show uptime, traffic and memory statistics")
+
+		default:
+			fmt.Printf("Unknown command: %s (type 'help')\n", fields[0])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// createEphemeralRoom creates a room that disappears once it is empty or
+// its TTL passes
+func (s *Server) createEphemeralRoom(ctx context.Context, client *Client, msg *common.Message) {
+	ttl := time.Duration(msg.TTL) * time.Second
+	if err := ValidateRoomTTL(ttl); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+		client.SendMessage(errMsg)
+		return
+	}
+
+	room := s.roomManager.CreateEphemeralRoom(strings.TrimSpace(msg.Content), client.Nickname, ttl)
+	client.AddRoom(room.ID)
+	s.rateLimiter.AddRoom(client.Nickname)
+	s.notifyRoomCreated(room)
+
+	content := common.T("Ephemeral room '%s' created, it is deleted when the last member leaves", room.Name)
+	if ttl > 0 {
+		content = common.T("Ephemeral room '%s' created, it is deleted in %v or when the last member leaves", room.Name, ttl)
+		time.AfterFunc(ttl, func() {
+			s.expireRoom(room.ID)
+		})
+	}
+
+	client.SendMessage(&common.Message{
+		Type:      common.TypeRoom,
+		Action:    common.RoomCreate,
+		Room:      room.ID,
+		Content:   content,
+		Ephemeral: true,
+		TTL:       msg.TTL,
+	})
+}
+
+// expireRoom deletes an ephemeral room whose TTL has passed
+func (s *Server) expireRoom(roomID string) {
+	room, exists := s.roomManager.GetRoom(roomID)
+	if !exists {
+		return
+	}
+	room.logger.Info("Ephemeral room expired")
+	s.deleteRoom(s.ctx, room, common.T("Room '%s' has expired", room.Name))
+}
+
+// removeIfAbandoned deletes an ephemeral room right after its last member
+// left instead of waiting for the empty room cleanup
+func (s *Server) removeIfAbandoned(room *Room) {
+	if !room.Ephemeral || !room.IsEmpty() {
+		return
+	}
+	room.logger.Info("Last member left ephemeral room")
+	s.roomManager.RemoveRoom(room.ID)
+	s.rateLimiter.RemoveRoom(room.Creator)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	"tcp-chat/chatgrpc"
+	"tcp-chat/common"
+)
+
+// newGRPCServer creates the gRPC server exposing the Chat service of s
+func newGRPCServer(s *Server) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ForceServerCodec(chatgrpc.Codec{}),
+		grpc.MaxRecvMsgSize(common.MaxScannerBuffer),
+	)
+	chatgrpc.RegisterChatServer(grpcServer, &grpcService{server: s})
+	return grpcServer
+}
+
+// StartGRPC serves the Chat gRPC service on the specified port in the background
+func (s *Server) StartGRPC(port string) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %v", port, err)
+	}
+
+	s.logger.Info("gRPC service started on port %s", port)
+	go s.ServeGRPC(listener)
+	return nil
+}
+
+// ServeGRPC serves the Chat gRPC service on listener until the server shuts down
+func (s *Server) ServeGRPC(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+// grpcService serves Chat streams with the same clients as the TCP protocol
+type grpcService struct {
+	server *Server
+}
+
+// Chat admits a stream like an accepted TCP connection and blocks until the
+// client is closed
+func (g *grpcService) Chat(stream grpc.ServerStream) error {
+	s := g.server
+	conn := newStreamConn(stream)
+
+	if s.IsDraining() {
+		s.rejectDraining(conn)
+	} else if err := s.rateLimiter.CanConnect(conn.RemoteAddr()); err != nil {
+		s.logger.Warn("gRPC stream rejected from %s: %v", conn.RemoteAddr(), err)
+		rejectConnection(conn, err)
+	} else {
+		s.handleNewConnection(conn)
+	}
+
+	conn.wait()
+	return nil
+}
+
+// streamConn presents a Chat stream as a net.Conn carrying newline-delimited
+// JSON, so Client can serve it with its usual read and write pumps
+type streamConn struct {
+	stream   grpc.ServerStream
+	remote   net.Addr
+	received chan []byte // Compacted messages followed by a newline
+	recvErr  error       // Why receiving stopped, set before received is closed
+	pending  []byte      // Unread rest of the current message
+	partial  []byte      // Written bytes not yet ending in a newline
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	writing   sync.Mutex // Held while sending, so wait can let the last send finish
+
+	mutex        sync.Mutex
+	readDeadline time.Time
+}
+
+// newStreamConn wraps stream and starts receiving from it
+func newStreamConn(stream grpc.ServerStream) *streamConn {
+	c := &streamConn{
+		stream:   stream,
+		remote:   &net.TCPAddr{},
+		received: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		c.remote = p.Addr
+	}
+	go c.receive()
+	return c
+}
+
+// receive forwards messages from the stream to Read until the stream ends
+func (c *streamConn) receive() {
+	defer close(c.received)
+	for {
+		var raw json.RawMessage
+		if err := c.stream.RecvMsg(&raw); err != nil {
+			c.recvErr = err
+			return
+		}
+
+		// Clients may send indented JSON, but a message must stay on one line
+		var line bytes.Buffer
+		if err := json.Compact(&line, raw); err != nil {
+			c.recvErr = err
+			return
+		}
+		line.WriteByte('\n')
+
+		select {
+		case c.received <- line.Bytes():
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// Read returns the received messages as newline-delimited JSON
+func (c *streamConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		c.mutex.Lock()
+		deadline := c.readDeadline
+		c.mutex.Unlock()
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case line, ok := <-c.received:
+			if !ok {
+				if c.recvErr == io.EOF {
+					return 0, io.EOF
+				}
+				return 0, fmt.Errorf("gRPC stream: %v", c.recvErr)
+			}
+			c.pending = line
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		case <-c.closed:
+			return 0, net.ErrClosed
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write sends every complete line written as one message on the stream
+func (c *streamConn) Write(p []byte) (int, error) {
+	c.writing.Lock()
+	defer c.writing.Unlock()
+
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	c.partial = append(c.partial, p...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := json.RawMessage(c.partial[:i])
+		if err := c.stream.SendMsg(&line); err != nil {
+			return 0, err
+		}
+		c.partial = c.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close ends the stream once the Chat handler returns
+func (c *streamConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// wait blocks until the connection is closed or the client goes away, then
+// waits for a send in progress, since the stream must not be used after the
+// handler returns
+func (c *streamConn) wait() {
+	select {
+	case <-c.closed:
+	case <-c.stream.Context().Done():
+		c.Close()
+	}
+	c.writing.Lock()
+	c.writing.Unlock()
+}
+
+// LocalAddr returns a placeholder, the stream has no local address of its own
+func (c *streamConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// RemoteAddr returns the address of the gRPC peer
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read deadline; see SetWriteDeadline
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline bounds how long Read waits for the next message
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline is a no-op: a send cannot be interrupted, gRPC flow
+// control and the stream context bound it instead
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"tcp-chat/chatgrpc"
+	"tcp-chat/common"
+)
+
+// expectTimeout bounds how long a test client waits for a message
+const expectTimeout = 2 * time.Second
+
+// startServer runs a server on an ephemeral port until the test ends and
+// returns its address
+func startServer(t *testing.T) string {
+	t.Helper()
+	addr, _ := startServerWithGRPC(t)
+	return addr
+}
+
+// startServerWithGRPC is startServer also serving the Chat gRPC service, and
+// returns the addresses of both protocols
+func startServerWithGRPC(t *testing.T) (string, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := s.Serve(ctx, listener); err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	}()
+	go s.ServeGRPC(grpcListener)
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-served:
+		case <-time.After(common.ShutdownTimeout):
+			t.Error("server did not shut down")
+		}
+	})
+	return listener.Addr().String(), grpcListener.Addr().String()
+}
+
+// testClient is a programmable protocol client
+type testClient struct {
+	t        *testing.T
+	nickname string
+	conn     net.Conn
+	messages chan *common.Message
+}
+
+// dialClient opens a connection without registering
+func dialClient(t *testing.T, addr string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	tc := &testClient{t: t, conn: conn, messages: make(chan *common.Message, 256)}
+	go func() {
+		defer close(tc.messages)
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 0, 64*1024), common.MaxScannerBuffer)
+		for scanner.Scan() {
+			if msg, err := common.DecodeMessage(scanner.Bytes()); err == nil {
+				tc.messages <- msg
+			}
+		}
+	}()
+	return tc
+}
+
+// connectClient dials and registers a nickname, waiting for the user list
+func connectClient(t *testing.T, addr, nickname string) *testClient {
+	t.Helper()
+	tc := dialClient(t, addr)
+	tc.nickname = nickname
+	tc.send(&common.Message{Type: common.TypeConnect, Content: nickname})
+	tc.expectType(common.TypeUserList)
+	return tc
+}
+
+// send writes a message to the server
+func (tc *testClient) send(msg *common.Message) {
+	tc.t.Helper()
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	data, err := msg.Encode()
+	if err != nil {
+		tc.t.Fatalf("encode: %v", err)
+	}
+	if _, err := tc.conn.Write(append(data, '\n')); err != nil {
+		tc.t.Fatalf("%s: write: %v", tc.nickname, err)
+	}
+}
+
+// expect skips messages until one matches, failing the test on timeout
+func (tc *testClient) expect(what string, match func(*common.Message) bool) *common.Message {
+	tc.t.Helper()
+	timeout := time.After(expectTimeout)
+	for {
+		select {
+		case msg, ok := <-tc.messages:
+			if !ok {
+				tc.t.Fatalf("%s: connection closed while waiting for %s", tc.nickname, what)
+			}
+			if match(msg) {
+				return msg
+			}
+		case <-timeout:
+			tc.t.Fatalf("%s: no %s within %v", tc.nickname, what, expectTimeout)
+		}
+	}
+}
+
+// expectType waits for a message of the given type
+func (tc *testClient) expectType(msgType common.MessageType) *common.Message {
+	tc.t.Helper()
+	return tc.expect(string(msgType), func(msg *common.Message) bool {
+		return msg.Type == msgType
+	})
+}
+
+// expectError waits for an error message with the given code
+func (tc *testClient) expectError(code common.ErrorType) *common.Message {
+	tc.t.Helper()
+	return tc.expect(string(code)+" error", func(msg *common.Message) bool {
+		return msg.Type == common.TypeError && msg.ErrorCode == code
+	})
+}
+
+// expectRoom waits for a room message with the given action
+func (tc *testClient) expectRoom(action common.RoomAction) *common.Message {
+	tc.t.Helper()
+	return tc.expect("room "+string(action), func(msg *common.Message) bool {
+		return msg.Type == common.TypeRoom && msg.Action == action
+	})
+}
+
+// expectText waits for a text message with the given content
+func (tc *testClient) expectText(content string) *common.Message {
+	tc.t.Helper()
+	return tc.expect("text "+content, func(msg *common.Message) bool {
+		return msg.Type == common.TypeText && msg.Content == content
+	})
+}
+
+// expectNoText fails if a text message with the given content arrives
+// within a short period
+func (tc *testClient) expectNoText(content string) {
+	tc.t.Helper()
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg, ok := <-tc.messages:
+			if !ok {
+				return
+			}
+			if msg.Type == common.TypeText && msg.Content == content {
+				tc.t.Fatalf("%s: unexpected text %q", tc.nickname, content)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// createRoom creates a room and returns its ID
+func (tc *testClient) createRoom(name string) string {
+	tc.t.Helper()
+	tc.send(&common.Message{Type: common.TypeRoom, Action: common.RoomCreate, Content: name})
+	return tc.expectRoom(common.RoomCreate).Room
+}
+
+// joinRoom joins a room and waits for the confirmation
+func (tc *testClient) joinRoom(roomID string) {
+	tc.t.Helper()
+	tc.send(&common.Message{Type: common.TypeRoom, Action: common.RoomJoin, Room: roomID})
+	tc.expectRoom(common.RoomJoin)
+}
+
+func TestIntegrationRegistration(t *testing.T) {
+	tests := []struct {
+		name     string
+		taken    string
+		nickname string
+		wantCode common.ErrorType
+	}{
+		{"valid nickname", "", "alice", ""},
+		{"too short", "", "al", common.ErrValidation},
+		{"invalid characters", "", "al ice!", common.ErrValidation},
+		{"already taken", "alice", "alice", common.ErrDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startServer(t)
+			if tt.taken != "" {
+				connectClient(t, addr, tt.taken)
+			}
+
+			tc := dialClient(t, addr)
+			tc.nickname = tt.nickname
+			tc.send(&common.Message{Type: common.TypeConnect, Content: tt.nickname})
+
+			if tt.wantCode == "" {
+				list := tc.expectType(common.TypeUserList)
+				found := false
+				for _, user := range list.Users {
+					found = found || user == tt.nickname+":"+string(common.StatusActive)
+				}
+				if !found {
+					t.Errorf("user list %v does not contain %s", list.Users, tt.nickname)
+				}
+				tc.expectText(common.T("Welcome to the chat, %s!", tt.nickname))
+				return
+			}
+			tc.expectError(tt.wantCode)
+		})
+	}
+}
+
+func TestIntegrationMessaging(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	bob := connectClient(t, addr, "bob")
+	carol := connectClient(t, addr, "carol")
+
+	tests := []struct {
+		name      string
+		msg       *common.Message
+		receivers []*testClient
+		excluded  []*testClient
+	}{
+		{"broadcast", common.NewBroadcastMessage("", "hello all"), []*testClient{bob, carol}, nil},
+		{"private", common.NewTextMessage("", "bob", "hello bob"), []*testClient{bob, alice}, []*testClient{carol}},
+	}
+
+	// The clients report failures on the parent test, so the cases run
+	// sequentially without subtests
+	for _, tt := range tests {
+		alice.send(tt.msg)
+		for _, receiver := range tt.receivers {
+			got := receiver.expectText(tt.msg.Content)
+			if got.Sender != "alice" {
+				t.Errorf("%s: sender %q, want alice", tt.name, got.Sender)
+			}
+		}
+		for _, excluded := range tt.excluded {
+			excluded.expectNoText(tt.msg.Content)
+		}
+	}
+
+	alice.send(common.NewTextMessage("", "nobody", "anyone?"))
+	alice.expectError(common.ErrNotFound)
+}
+
+func TestIntegrationFormatting(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	bob := connectClient(t, addr, "bob")
+
+	msg := common.NewBroadcastMessage("", "**hi** [me](javascript:alert(1)) \x1b[2J")
+	msg.Format = common.FormatMarkdown
+	alice.send(msg)
+	if got := bob.expectText("**hi** me) [2J"); got.Format != common.FormatMarkdown {
+		t.Errorf("format %q, want %q", got.Format, common.FormatMarkdown)
+	}
+
+	msg = common.NewBroadcastMessage("", "<b>hi</b>")
+	msg.Format = "html"
+	alice.send(msg)
+	alice.expectError(common.ErrValidation)
+}
+
+func TestIntegrationRooms(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	bob := connectClient(t, addr, "bob")
+	carol := connectClient(t, addr, "carol")
+
+	roomID := alice.createRoom("lounge")
+	bob.joinRoom(roomID)
+
+	// Room messages reach members only
+	msg := common.NewTextMessage("", "", "room hello")
+	msg.Room = roomID
+	alice.send(msg)
+	bob.expectText("room hello")
+	carol.expectNoText("room hello")
+
+	// Outsiders cannot post
+	outsider := common.NewTextMessage("", "", "let me in")
+	outsider.Room = roomID
+	carol.send(outsider)
+	carol.expectError(common.ErrUnauthorized)
+
+	// Leaving stops delivery
+	bob.send(&common.Message{Type: common.TypeRoom, Action: common.RoomLeave, Room: roomID})
+	bob.expectRoom(common.RoomLeaveConfirm)
+	after := common.NewTextMessage("", "", "after leave")
+	after.Room = roomID
+	alice.send(after)
+	bob.expectNoText("after leave")
+
+	// Unknown rooms are reported as not found
+	carol.send(&common.Message{Type: common.TypeRoom, Action: common.RoomJoin, Room: "missing"})
+	carol.expectError(common.ErrNotFound)
+}
+
+func TestIntegrationInvites(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantMember bool
+	}{
+		{"accept", "accept", true},
+		{"decline", "decline", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startServer(t)
+			alice := connectClient(t, addr, "alice")
+			bob := connectClient(t, addr, "bob")
+
+			roomID := alice.createRoom("private")
+			alice.send(&common.Message{Type: common.TypeRoom, Action: common.RoomSettings, Room: roomID, Content: "policy=invite"})
+			alice.expect("settings notice", func(msg *common.Message) bool {
+				return msg.Type == common.TypeText && msg.Room == roomID && msg.Sender == "Server"
+			})
+
+			// Invite-only rooms refuse uninvited users
+			bob.send(&common.Message{Type: common.TypeRoom, Action: common.RoomJoin, Room: roomID})
+			bob.expectError(common.ErrUnauthorized)
+
+			alice.send(&common.Message{Type: common.TypeInvite, Room: roomID, Recipient: "bob"})
+			invite := bob.expectType(common.TypeInvite)
+			if invite.Room != roomID {
+				t.Fatalf("invite for room %s, want %s", invite.Room, roomID)
+			}
+
+			bob.send(&common.Message{Type: common.TypeInviteResp, Room: roomID, Content: tt.response})
+			msg := common.NewTextMessage("", "", "welcome")
+			msg.Room = roomID
+			if tt.wantMember {
+				bob.expectRoom(common.RoomJoin)
+				alice.send(msg)
+				bob.expectText("welcome")
+			} else {
+				alice.send(msg)
+				bob.expectNoText("welcome")
+			}
+		})
+	}
+}
+
+func TestIntegrationKick(t *testing.T) {
+	tests := []struct {
+		name     string
+		kicker   string
+		target   string
+		wantCode common.ErrorType
+	}{
+		{"creator kicks member", "alice", "bob", ""},
+		{"member cannot kick", "bob", "alice", common.ErrUnauthorized},
+		{"creator cannot kick self", "alice", "alice", common.ErrValidation},
+		{"target not in room", "alice", "carol", common.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startServer(t)
+			clients := map[string]*testClient{
+				"alice": connectClient(t, addr, "alice"),
+				"bob":   connectClient(t, addr, "bob"),
+				"carol": connectClient(t, addr, "carol"),
+			}
+			roomID := clients["alice"].createRoom("lounge")
+			clients["bob"].joinRoom(roomID)
+
+			kicker := clients[tt.kicker]
+			kicker.send(&common.Message{Type: common.TypeRoom, Action: common.RoomKick, Room: roomID, Recipient: tt.target})
+			if tt.wantCode != "" {
+				kicker.expectError(tt.wantCode)
+				return
+			}
+
+			clients[tt.target].expectRoom(common.RoomLeaveConfirm)
+			msg := common.NewTextMessage("", "", "after kick")
+			msg.Room = roomID
+			kicker.send(msg)
+			clients[tt.target].expectNoText("after kick")
+		})
+	}
+}
+
+func TestIntegrationFileTransfer(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	bob := connectClient(t, addr, "bob")
+
+	content := bytes.Repeat([]byte("0123456789"), common.FileChunkSize/4)
+	totalChunks := (len(content) + common.FileChunkSize - 1) / common.FileChunkSize
+	alice.send(&common.Message{
+		Type:        common.TypeFile,
+		Recipient:   "bob",
+		FileID:      "file-1",
+		Filename:    "data.bin",
+		Filesize:    int64(len(content)),
+		ContentType: "application/octet-stream",
+		Metadata:    map[string]string{common.MetaCaption: "numbers"},
+		TotalChunks: totalChunks,
+	})
+	offer := bob.expectType(common.TypeFile)
+	if offer.Filename != "data.bin" || offer.Sender != "alice" {
+		t.Fatalf("unexpected offer %+v", offer)
+	}
+	if offer.ContentType != "application/octet-stream" || offer.Metadata[common.MetaCaption] != "numbers" {
+		t.Errorf("content type and metadata not forwarded: %q %v", offer.ContentType, offer.Metadata)
+	}
+
+	for i := 0; i < totalChunks; i++ {
+		end := min((i+1)*common.FileChunkSize, len(content))
+		alice.send(&common.Message{
+			Type:        common.TypeFileChunk,
+			Recipient:   "bob",
+			FileID:      "file-1",
+			ChunkNum:    i,
+			TotalChunks: totalChunks,
+			Data:        content[i*common.FileChunkSize : end],
+		})
+	}
+
+	assembler := common.NewChunkAssembler(totalChunks, 0)
+	defer assembler.Close()
+	for !assembler.IsComplete() {
+		chunk := bob.expectType(common.TypeFileChunk)
+		if err := assembler.Add(chunk.ChunkNum, chunk.Data); err != nil {
+			t.Fatalf("chunk %d: %v", chunk.ChunkNum, err)
+		}
+	}
+	bob.expectType(common.TypeFileComplete)
+	alice.expectType(common.TypeFileComplete)
+
+	var received bytes.Buffer
+	if _, err := assembler.WriteTo(&received); err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if !bytes.Equal(received.Bytes(), content) {
+		t.Error("received file differs from the sent file")
+	}
+
+	// Invalid file names are refused before anything is forwarded
+	alice.send(&common.Message{Type: common.TypeFile, Recipient: "bob", FileID: "file-2", Filename: "../etc/passwd", Filesize: 10, TotalChunks: 1})
+	alice.expectError(common.ErrValidation)
+	alice.send(&common.Message{Type: common.TypeFile, Recipient: "bob", FileID: "file-3", Filename: "a.bin", Filesize: 10, TotalChunks: 1, ContentType: "not a type"})
+	alice.expectError(common.ErrValidation)
+	alice.send(&common.Message{Type: common.TypeFile, Recipient: "bob", FileID: "file-4", Filename: "a.bin", Filesize: 10, TotalChunks: 1, Metadata: map[string]string{"Bad Key": "x"}})
+	alice.expectError(common.ErrValidation)
+}
+
+// Only the sender of a transfer may send its chunks, and its ID can't be reused
+func TestIntegrationFileTransferOwnership(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	bob := connectClient(t, addr, "bob")
+	carol := connectClient(t, addr, "carol")
+
+	offer := &common.Message{Type: common.TypeFile, Recipient: "bob", FileID: "file-1", Filename: "a.bin", Filesize: 5, TotalChunks: 1}
+	alice.send(offer)
+	bob.expectType(common.TypeFile)
+	carol.send(offer)
+	carol.expectError(common.ErrDuplicate)
+
+	chunk := func(data string) *common.Message {
+		return &common.Message{Type: common.TypeFileChunk, Recipient: "bob", FileID: "file-1", TotalChunks: 1, Data: []byte(data)}
+	}
+	carol.send(chunk("evil!"))
+	carol.expectError(common.ErrUnauthorized)
+	alice.send(chunk("hello"))
+	if received := bob.expectType(common.TypeFileChunk); string(received.Data) != "hello" {
+		t.Errorf("Bob received %q, want the sender's chunk", received.Data)
+	}
+	bob.expectType(common.TypeFileComplete)
+}
+
+func TestIntegrationHostileInput(t *testing.T) {
+	addr := startServer(t)
+	alice := connectClient(t, addr, "alice")
+	connectClient(t, addr, "bob")
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"negative chunk number", `{"type":"FILE_CHUNK","file_id":"x","chunk_num":-1,"total_chunks":1}`},
+		{"absurd total chunks", `{"type":"FILE","recipient":"bob","file_id":"x","filename":"a.bin","filesize":10,"total_chunks":2000000000}`},
+		{"chunks not matching the size", `{"type":"FILE","recipient":"bob","file_id":"x","filename":"a.bin","filesize":10,"total_chunks":500}`},
+		{"malformed JSON", `{"type":"TEXT",`},
+	}
+
+	for _, tt := range tests {
+		if _, err := alice.conn.Write([]byte(tt.line + "\n")); err != nil {
+			t.Fatalf("%s: write: %v", tt.name, err)
+		}
+		alice.expectError(common.ErrValidation)
+	}
+
+	// The connection survives rejected input
+	alice.send(common.NewBroadcastMessage("", "still here"))
+	alice.expectText("still here")
+}
+
+func TestGRPCClient(t *testing.T) {
+	addr, grpcAddr := startServerWithGRPC(t)
+	alice := connectClient(t, addr, "alice")
+
+	cc, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := chatgrpc.NewStream(ctx, cc)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+
+	// recv skips messages until one matches
+	recv := func(what string, match func(*common.Message) bool) *common.Message {
+		t.Helper()
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("waiting for %s: %v", what, err)
+			}
+			if match(msg) {
+				return msg
+			}
+		}
+	}
+
+	if err := stream.Send(&common.Message{Type: common.TypeConnect, Content: "bob", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	users := recv("user list", func(msg *common.Message) bool { return msg.Type == common.TypeUserList })
+	if len(users.Users) != 2 {
+		t.Errorf("gRPC client sees users %v, want alice and bob", users.Users)
+	}
+	alice.expect("bob joining", func(msg *common.Message) bool {
+		return msg.Type == common.TypeUserJoined && msg.Sender == "bob"
+	})
+
+	// Both protocols reach each other through the same server
+	alice.send(common.NewTextMessage("alice", "bob", "hello over tcp"))
+	recv("message from alice", func(msg *common.Message) bool {
+		return msg.Type == common.TypeText && msg.Sender == "alice" && msg.Content == "hello over tcp"
+	})
+	if err := stream.Send(common.NewTextMessage("", "alice", "hello over grpc")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if msg := alice.expectText("hello over grpc"); msg.Sender != "bob" {
+		t.Errorf("message from %q, want bob", msg.Sender)
+	}
+
+	// Errors carry the same codes
+	if err := stream.Send(common.NewTextMessage("", "nobody", "hi")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	recv("NOT_FOUND error", func(msg *common.Message) bool {
+		return msg.Type == common.TypeError && msg.ErrorCode == common.ErrNotFound
+	})
+
+	// Ending the stream unregisters the client
+	stream.CloseSend()
+	alice.expect("bob leaving", func(msg *common.Message) bool {
+		return msg.Type == common.TypeUserLeft && msg.Sender == "bob"
+	})
+}
+
+func TestPing(t *testing.T) {
+	addr := startServer(t)
+
+	// Pings are answered even before registering
+	tc := dialClient(t, addr)
+	tc.send(&common.Message{Type: common.TypePing, ID: "ping-1"})
+	if pong := tc.expectType(common.TypePong); pong.ID != "ping-1" {
+		t.Errorf("pong for %q, want ping-1", pong.ID)
+	}
+
+	// Repeating an ID is fine, pings skip the dedup cache
+	alice := connectClient(t, addr, "alice")
+	for i := 0; i < 2; i++ {
+		alice.send(&common.Message{Type: common.TypePing, ID: "ping-2"})
+		if pong := alice.expectType(common.TypePong); pong.ID != "ping-2" {
+			t.Errorf("pong for %q, want ping-2", pong.ID)
+		}
+	}
+}
@@ -2,39 +2,80 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
 	"tcp-chat/common"
+	shared "training.pl/go/common"
+	"training.pl/go/common/bus"
 )
 
+// topicAll is the topic of the bus every registered client subscribes to
+const topicAll = "all"
+
 // Server represents the chat server
 type Server struct {
 	listener       net.Listener
-	clients        sync.Map // map[string]*Client (nickname -> client)
+	grpcServer     *grpc.Server                    // Chat gRPC service sharing this server, see grpc.go
+	apiServer      *http.Server                    // Optional HTTP API, see api.go
+	clients        shared.SyncMap[string, *Client] // nickname -> client
+	bus            *bus.Bus[*common.Message]       // Registered clients subscribe to topicAll
 	roomManager    *RoomManager
-	fileTransfers  sync.Map // map[string]*common.FileTransfer
+	fileTransfers  shared.SyncMap[string, *common.FileTransfer]
 	rateLimiter    *RateLimiter
 	cleanupManager *CleanupManager
 	shutdown       chan bool
 	regMutex       sync.Mutex // Mutex for client registration
+	stats          *Stats
+	dedup          *DedupCache
+	webhooks       *WebhookDispatcher
+	logger         *common.Logger
+
+	// Root of all client and request contexts, cancelled at the end of shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Anti-bot check before registration, see challenge.go
+	challengeConfig ChallengeConfig
+
+	// Drain mode, see drain.go
+	draining      atomic.Bool
+	drainDeadline time.Time
+	stopRequest   chan struct{}
+	stopOnce      sync.Once
 }
 
 // NewServer creates a new server instance
 func NewServer() *Server {
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &Server{
+		ctx:         ctx,
+		cancel:      cancel,
+		bus:         bus.New[*common.Message](),
 		roomManager: NewRoomManager(),
 		rateLimiter: NewRateLimiter(),
 		shutdown:    make(chan bool),
+		stopRequest: make(chan struct{}),
+		stats:       NewStats(),
+		dedup:       NewDedupCache(common.DedupWindow, common.DedupCacheSize),
+		webhooks:    NewWebhookDispatcher(),
+		logger:      common.GetLogger("server"),
 	}
 	s.cleanupManager = NewCleanupManager(s)
+	s.grpcServer = newGRPCServer(s)
 	return s
 }
 
@@ -45,32 +86,50 @@ func (s *Server) Start(port string) error {
 		return fmt.Errorf("failed to listen on port %s: %v", port, err)
 	}
 
+	s.logger.Info("Server started on port %s", port)
+	return s.Serve(context.Background(), listener)
+}
+
+// Serve accepts connections on listener until the server shuts down.
+// Cancelling ctx shuts the server down like a termination signal.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
 	s.listener = listener
-	common.Info("Server started on port %s", port)
+	stop := context.AfterFunc(ctx, s.requestStop)
+	defer stop()
 
 	// Start cleanup manager
 	s.cleanupManager.Start()
+	s.webhooks.Start()
 
 	// Handle graceful shutdown
 	go s.handleShutdown()
 
+	// Read admin commands from the terminal
+	go s.runConsole(os.Stdin)
+
 	// Accept connections
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			select {
-			case <-s.shutdown:
+			if errors.Is(err, net.ErrClosed) {
+				// Listener closed by handleShutdown, wait for it to finish
+				<-s.shutdown
 				return nil
-			default:
-				common.Error("Error accepting connection: %v", err)
-				continue
 			}
+			s.logger.Error("Error accepting connection: %v", err)
+			continue
+		}
+
+		// Refuse new clients while draining for maintenance
+		if s.IsDraining() {
+			s.rejectDraining(conn)
+			continue
 		}
 
 		// Check rate limits before accepting
 		if err := s.rateLimiter.CanConnect(conn.RemoteAddr()); err != nil {
-			common.Warn("Connection rejected from %s: %v", conn.RemoteAddr(), err)
-			conn.Close()
+			s.logger.Warn("Connection rejected from %s: %v", conn.RemoteAddr(), err)
+			rejectConnection(conn, err)
 			continue
 		}
 
@@ -89,14 +148,24 @@ func (s *Server) handleNewConnection(conn net.Conn) {
 
 	client := NewClient(conn, s)
 	client.RemoteAddr = conn.RemoteAddr().String()
-	common.Info("New connection from %s", conn.RemoteAddr())
+	client.Logger().Info("New connection")
 
 	// Start client goroutines
 	client.Start()
 }
 
+// rejectConnection sends an error to a connection that is not accepted and closes it
+func rejectConnection(conn net.Conn, reason error) {
+	errMsg := common.NewErrorMessageFromError("Server", "", reason)
+	if data, err := errMsg.Encode(); err == nil {
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write(append(data, '\n'))
+	}
+	conn.Close()
+}
+
 // RegisterClient registers a new client with a nickname
-func (s *Server) RegisterClient(client *Client, nickname string) (bool, error) {
+func (s *Server) RegisterClient(ctx context.Context, client *Client, nickname string) (bool, error) {
 	// Validate nickname
 	if err := ValidateNickname(nickname); err != nil {
 		return false, err
@@ -108,51 +177,91 @@ func (s *Server) RegisterClient(client *Client, nickname string) (bool, error) {
 
 	// Double-check if nickname is already taken
 	if _, exists := s.clients.Load(nickname); exists {
-		return false, fmt.Errorf("nickname '%s' is already taken", nickname)
+		return false, common.ChatErrorf(common.ErrDuplicate, "nickname '%s' is already taken", nickname)
 	}
 
 	client.Nickname = nickname
+	client.SetLogger(client.Logger().With(common.F("nickname", nickname)))
 	s.clients.Store(nickname, client)
+	client.subscription.Join(topicAll)
 
-	// Notify all users about new connection
-	s.BroadcastUserList()
+	// Send the initial user list and announce the user to everyone else
+	s.sendUserList(client)
+	s.announceJoined(ctx, client)
 
 	// Send welcome message
-	welcomeMsg := common.NewTextMessage("Server", nickname, fmt.Sprintf("Welcome to the chat, %s!", nickname))
+	welcomeMsg := common.NewTextMessage("Server", nickname, common.T("Welcome to the chat, %s!", nickname))
 	client.SendMessage(welcomeMsg)
 
 	// Announce to others
-	announceMsg := common.NewBroadcastMessage("Server", fmt.Sprintf("%s has joined the chat", nickname))
-	s.BroadcastMessage(announceMsg, nickname)
+	announceMsg := common.NewBroadcastMessage("Server", common.T("%s has joined the chat", nickname))
+	s.BroadcastMessage(ctx, announceMsg, nickname)
+
+	s.rejoinRooms(ctx, client)
 
-	common.Info("Client registered: %s from %s", nickname, client.RemoteAddr)
+	event := NewWebhookEvent(EventUserJoined)
+	event.User = nickname
+	s.webhooks.Notify(event)
+
+	client.Logger().Info("Client registered")
 	return true, nil
 }
 
+// rejoinRooms puts a reconnecting user back into the persisted rooms they
+// belong to and pushes the topic and member list of each
+func (s *Server) rejoinRooms(ctx context.Context, client *Client) {
+	for _, room := range s.roomManager.GetUserRooms(client.Nickname) {
+		client.AddRoom(room.ID)
+		client.SendMessage(&common.Message{
+			Type:    common.TypeRoom,
+			Action:  common.RoomJoin,
+			Room:    room.ID,
+			Content: room.Name,
+		})
+		client.SendMessage(s.roomMembersMessage(room))
+
+		backMsg := common.NewTextMessage("Server", "", common.T("%s is back in the room", client.Nickname))
+		backMsg.Room = room.ID
+		s.roomManager.BroadcastToRoom(ctx, s, room.ID, backMsg)
+		client.Logger().Debug("Rejoined room %s", room.ID)
+	}
+}
+
 // UnregisterClient removes a client from the server
-func (s *Server) UnregisterClient(client *Client) {
+func (s *Server) UnregisterClient(ctx context.Context, client *Client) {
 	if client.Nickname == "" {
 		return
 	}
 
 	s.clients.Delete(client.Nickname)
+	client.subscription.Leave(topicAll)
 
-	// Remove from all rooms and notify room members
+	// Remove from all rooms and notify room members; persistent rooms keep
+	// the membership so the user is rejoined after reconnecting
+	persistent := s.roomManager.IsPersistent()
 	rooms := s.roomManager.GetUserRooms(client.Nickname)
 	for _, room := range rooms {
-		room.RemoveMember(client.Nickname)
+		if !persistent || room.Ephemeral {
+			room.RemoveMember(client.Nickname)
+		}
 
 		// Notify room members about the disconnection
-		leaveMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s has disconnected from the room", client.Nickname))
+		leaveMsg := common.NewTextMessage("Server", "", common.T("%s has disconnected from the room", client.Nickname))
 		leaveMsg.Room = room.ID
-		s.roomManager.BroadcastToRoom(s, room.ID, leaveMsg)
+		s.roomManager.BroadcastToRoom(ctx, s, room.ID, leaveMsg)
+		s.removeIfAbandoned(room)
 	}
 
-	// Notify all users
-	disconnectMsg := common.NewBroadcastMessage("Server", fmt.Sprintf("%s has left the chat", client.Nickname))
-	s.BroadcastMessage(disconnectMsg, "")
+	// Notify all users, invisible users leave without a trace
+	if client.GetStatus() != common.StatusInvisible {
+		disconnectMsg := common.NewBroadcastMessage("Server", common.T("%s has left the chat", client.Nickname))
+		s.BroadcastMessage(ctx, disconnectMsg, "")
+	}
+	s.announceLeft(ctx, client)
 
-	s.BroadcastUserList()
+	event := NewWebhookEvent(EventUserLeft)
+	event.User = client.Nickname
+	s.webhooks.Notify(event)
 
 	// Clean up rate limiter
 	s.rateLimiter.RemoveUser(client.Nickname)
@@ -165,108 +274,107 @@ func (s *Server) UnregisterClient(client *Client) {
 		}
 	}
 
-	common.Info("Client unregistered: %s", client.Nickname)
+	client.Logger().Info("Client unregistered")
 }
 
 // GetClient retrieves a client by nickname
 func (s *Server) GetClient(nickname string) (*Client, bool) {
-	value, exists := s.clients.Load(nickname)
-	if !exists {
-		return nil, false
-	}
-	return value.(*Client), true
+	return s.clients.Load(nickname)
 }
 
-// BroadcastMessage sends a message to all connected clients
-func (s *Server) BroadcastMessage(msg *common.Message, exclude string) {
-	s.clients.Range(func(key, value interface{}) bool {
-		client := value.(*Client)
-
-		// Skip excluded client
-		if client.Nickname == exclude {
-			return true
-		}
-
-		// Skip invisible clients
-		if client.GetStatus() == common.StatusInvisible && msg.Sender != client.Nickname {
-			return true
-		}
-
-		client.SendMessage(msg)
-		return true
-	})
+// BroadcastMessage sends a message to all connected clients, stopping
+// early when ctx is cancelled
+func (s *Server) BroadcastMessage(ctx context.Context, msg *common.Message, exclude string) {
+	var excluded *bus.Subscription[*common.Message]
+	if client, ok := s.clients.Load(exclude); ok {
+		excluded = client.subscription
+	}
+	s.bus.PublishExcept(ctx, topicAll, msg, excluded)
 }
 
-// BroadcastUserList sends the list of online users to all clients
-func (s *Server) BroadcastUserList() {
-	var users []string
-
-	s.clients.Range(func(key, value interface{}) bool {
-		client := value.(*Client)
-		// Don't include invisible users in the list
-		if client.GetStatus() != common.StatusInvisible {
-			users = append(users, fmt.Sprintf("%s:%s", client.Nickname, client.GetStatus()))
+// HandleMessage processes incoming messages from clients
+func (s *Server) HandleMessage(ctx context.Context, client *Client, msg *common.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client.Logger().Debug("Handling %s message", msg.Type)
+	s.stats.RecordMessage()
+
+	// Nothing but the answer is accepted while a challenge is pending
+	if ch, _ := client.PendingChallenge(); ch != nil {
+		switch msg.Type {
+		case common.TypeConnect, common.TypeChallenge, common.TypeIgnore, common.TypePing:
+		default:
+			return common.ChatErrorf(common.ErrUnauthorized, "answer the challenge before sending messages")
 		}
-		return true
-	})
-
-	msg := &common.Message{
-		Type:  common.TypeUserList,
-		Users: users,
 	}
 
-	s.BroadcastMessage(msg, "")
-}
-
-// HandleMessage processes incoming messages from clients
-func (s *Server) HandleMessage(client *Client, msg *common.Message) error {
-	common.Debug("Handling %s message from %s", msg.Type, client.Nickname)
+	// Retransmissions of already handled messages are dropped silently
+	if err := ValidateMessageID(msg.ID); err != nil {
+		return err
+	}
+	if s.isDuplicate(client, msg) {
+		return nil
+	}
 
 	switch msg.Type {
 	case common.TypeConnect:
-		// Handle client connection with nickname
-		if success, err := s.RegisterClient(client, msg.Content); success {
-			ackMsg := common.NewTextMessage("Server", msg.Sender, "Connected successfully")
-			client.SendMessage(ackMsg)
-		} else {
-			errMsg := common.NewErrorMessage("Server", msg.Sender, err.Error())
-			client.SendMessage(errMsg)
-			if err := client.Conn.Close(); err != nil {
-				log.Printf("Error closing connection: %v", err)
-			}
+		// Unverified clients answer the challenge first
+		if s.requireChallenge(client, msg.Content) {
+			return nil
 		}
+		s.completeConnect(ctx, client, msg.Content)
+
+	case common.TypeChallenge:
+		s.handleChallengeAnswer(ctx, client, msg)
+
+	case common.TypeStats:
+		s.handleStatsRequest(client)
+
+	case common.TypePing:
+		client.SendMessage(&common.Message{Type: common.TypePong, ID: msg.ID, Timestamp: time.Now()})
 
 	case common.TypeText:
 		// Check rate limit
 		if err := s.rateLimiter.CanSendMessage(client.Nickname); err != nil {
-			common.Warn("Rate limit exceeded for %s: %v", client.Nickname, err)
-			errMsg := common.NewErrorMessage("Server", msg.Sender, err.Error())
+			client.Logger().Warn("Rate limit exceeded: %v", err)
+			errMsg := common.NewErrorMessageFromError("Server", msg.Sender, err)
 			client.SendMessage(errMsg)
 			return nil
 		}
 
-		// Validate message content
+		// Validate message content, markup is sanitized before it is relayed
+		if err := ValidateFormat(msg.Format); err != nil {
+			errMsg := common.NewErrorMessageFromError("Server", msg.Sender, err)
+			client.SendMessage(errMsg)
+			return nil
+		}
+		if msg.Format == common.FormatMarkdown {
+			msg.Content = common.SanitizeMarkup(msg.Content)
+		}
 		if err := ValidateMessage(msg.Content); err != nil {
-			errMsg := common.NewErrorMessage("Server", msg.Sender, err.Error())
+			errMsg := common.NewErrorMessageFromError("Server", msg.Sender, err)
 			client.SendMessage(errMsg)
 			return nil
 		}
 
-		// Handle text messages
-		if msg.Recipient == "*" || msg.Recipient == "" {
+		// Handle text messages, room messages carry no recipient
+		if msg.Room == "" && (msg.Recipient == "*" || msg.Recipient == "") {
 			// Broadcast message
-			s.BroadcastMessage(msg, "")
+			s.BroadcastMessage(ctx, msg, "")
+			s.webhooks.NotifyMessage(msg, "")
 		} else if msg.Room != "" {
 			// Room message - validate sender is a member
 			if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 				if !room.IsMember(client.Nickname) {
-					errMsg := common.NewErrorMessage("Server", client.Nickname, "You are not a member of this room")
+					errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You are not a member of this room"))
 					client.SendMessage(errMsg)
 					return nil
 				}
-				s.roomManager.BroadcastToRoom(s, msg.Room, msg)
+				s.roomManager.BroadcastToRoom(ctx, s, msg.Room, msg)
+				s.webhooks.NotifyMessage(msg, room.Name)
 			} else {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 				client.SendMessage(errMsg)
 			}
 		} else {
@@ -275,35 +383,55 @@ func (s *Server) HandleMessage(client *Client, msg *common.Message) error {
 				recipient.SendMessage(msg)
 				// Send copy to sender
 				client.SendMessage(msg)
+				s.autoReplyAway(client, recipient)
 			} else {
-				errMsg := common.NewErrorMessage("Server", msg.Sender, fmt.Sprintf("User %s not found", msg.Recipient))
+				errMsg := common.NewErrorMessageFromError("Server", msg.Sender, common.ChatErrorf(common.ErrNotFound, "User %s not found", msg.Recipient))
 				client.SendMessage(errMsg)
 			}
 		}
 
 	case common.TypeStatus:
-		// Handle status update
+		// A status message naming a recipient asks for that user's status
+		if msg.Recipient != "" {
+			s.handleStatusQuery(client, msg.Recipient)
+			return nil
+		}
+		if err := ValidateStatus(msg.Status); err != nil {
+			return err
+		}
+		away := ""
+		if msg.Status == common.StatusAway {
+			away = strings.TrimSpace(msg.Content)
+			if err := ValidateAwayMessage(away); err != nil {
+				return err
+			}
+		}
+		oldStatus, oldAway := client.GetStatus(), client.GetAway()
 		client.SetStatus(msg.Status)
-		s.BroadcastUserList()
-
-		// Notify about status change
-		statusMsg := common.NewBroadcastMessage("Server", fmt.Sprintf("%s is now %s", client.Nickname, msg.Status))
-		s.BroadcastMessage(statusMsg, client.Nickname)
+		client.SetAway(away)
+		s.announceStatus(ctx, client, oldStatus, oldAway)
 
 	case common.TypeRoom:
-		s.handleRoomMessage(client, msg)
+		s.handleRoomMessage(ctx, client, msg)
 
 	case common.TypeInvite:
-		s.handleInviteMessage(client, msg)
+		s.handleInviteMessage(ctx, client, msg)
 
 	case common.TypeInviteResp:
-		s.handleInviteResponse(client, msg)
+		s.handleInviteResponse(ctx, client, msg)
 
 	case common.TypeFile:
-		s.handleFileTransferInit(client, msg)
+		s.handleFileTransferInit(ctx, client, msg)
 
 	case common.TypeFileChunk:
-		s.handleFileChunk(client, msg)
+		s.handleFileChunk(ctx, client, msg)
+
+	case common.TypeFileControl:
+		s.handleFileControl(ctx, client, msg)
+
+	case common.TypeIgnore:
+		client.SetIgnored(msg.Users)
+		client.Logger().Debug("Ignoring %d user(s)", len(msg.Users))
 
 	default:
 		return common.NewChatError(common.ErrValidation, fmt.Sprintf("unknown message type: %s", msg.Type))
@@ -311,58 +439,84 @@ func (s *Server) HandleMessage(client *Client, msg *common.Message) error {
 	return nil
 }
 
+// completeConnect registers the client and reports the result to it
+func (s *Server) completeConnect(ctx context.Context, client *Client, nickname string) {
+	if success, err := s.RegisterClient(ctx, client, nickname); success {
+		ackMsg := common.NewTextMessage("Server", nickname, common.T("Connected successfully"))
+		client.SendMessage(ackMsg)
+	} else {
+		errMsg := common.NewErrorMessageFromError("Server", nickname, err)
+		client.SendMessage(errMsg)
+		client.closeAfterError()
+	}
+}
+
 // handleRoomMessage handles room-related messages
-func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
+func (s *Server) handleRoomMessage(ctx context.Context, client *Client, msg *common.Message) {
 	switch msg.Action {
 	case common.RoomCreate:
 		// Check rate limit for room creation
 		if err := s.rateLimiter.CanCreateRoom(client.Nickname); err != nil {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, err.Error())
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
 			client.SendMessage(errMsg)
 			return
 		}
 
 		// Validate room name
 		if err := ValidateRoomName(msg.Content); err != nil {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, err.Error())
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
 			client.SendMessage(errMsg)
 			return
 		}
 
+		if msg.Ephemeral || msg.TTL != 0 {
+			s.createEphemeralRoom(ctx, client, msg)
+			return
+		}
+
 		room := s.roomManager.CreateRoom(strings.TrimSpace(msg.Content), client.Nickname)
 		client.AddRoom(room.ID)
 		s.rateLimiter.AddRoom(client.Nickname)
+		s.notifyRoomCreated(room)
 
 		response := &common.Message{
 			Type:    common.TypeRoom,
 			Action:  common.RoomCreate,
 			Room:    room.ID,
-			Content: fmt.Sprintf("Room '%s' created successfully", room.Name),
+			Content: common.T("Room '%s' created successfully", room.Name),
 		}
 		client.SendMessage(response)
 
 	case common.RoomJoin:
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			if !room.IsMember(client.Nickname) {
+				// Enforce the join policy, the password travels in Content
+				if err := room.CanJoin(client.Nickname, msg.Content); err != nil {
+					errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+					client.SendMessage(errMsg)
+					return
+				}
+
 				room.AddMember(client.Nickname)
 				client.AddRoom(room.ID)
+				s.roomManager.Save()
 
 				// Notify room members
-				joinMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s has joined the room", client.Nickname))
+				joinMsg := common.NewTextMessage("Server", "", common.T("%s has joined the room", client.Nickname))
 				joinMsg.Room = msg.Room
-				s.roomManager.BroadcastToRoom(s, msg.Room, joinMsg)
+				s.roomManager.BroadcastToRoom(ctx, s, msg.Room, joinMsg)
 
 				// Send success message to joiner
 				response := &common.Message{
 					Type:    common.TypeRoom,
 					Action:  common.RoomJoin,
 					Room:    room.ID,
-					Content: fmt.Sprintf("Joined room '%s'", room.Name),
+					Content: common.T("Joined room '%s'", room.Name),
 				}
 				client.SendMessage(response)
 			}
 		} else {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 			client.SendMessage(errMsg)
 		}
 
@@ -370,6 +524,7 @@ func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			room.RemoveMember(client.Nickname)
 			client.RemoveRoom(msg.Room)
+			s.roomManager.Save()
 
 			// Send confirmation to the leaving user
 			confirmMsg := &common.Message{
@@ -381,45 +536,24 @@ func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
 			client.SendMessage(confirmMsg)
 
 			// Notify room members
-			leaveMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s has left the room", client.Nickname))
+			leaveMsg := common.NewTextMessage("Server", "", common.T("%s has left the room", client.Nickname))
 			leaveMsg.Room = msg.Room
-			s.roomManager.BroadcastToRoom(s, msg.Room, leaveMsg)
+			s.roomManager.BroadcastToRoom(ctx, s, msg.Room, leaveMsg)
+			s.removeIfAbandoned(room)
 		}
 
 	case common.RoomMembers:
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			// Check if user is a member
 			if !room.IsMember(client.Nickname) {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "You are not a member of this room")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You are not a member of this room"))
 				client.SendMessage(errMsg)
 				return
 			}
 
-			// Get members and their status
-			members := room.GetMembers()
-			var memberList []string
-			for _, member := range members {
-				status := "offline"
-				if memberClient, ok := s.GetClient(member); ok {
-					status = string(memberClient.GetStatus())
-				}
-				memberList = append(memberList, fmt.Sprintf("%s (%s)", member, status))
-			}
-
-			// Send member list
-			roomInfo := fmt.Sprintf("Room '%s'", room.Name)
-			if desc := room.GetDescription(); desc != "" {
-				roomInfo = fmt.Sprintf("%s (Topic: %s)", roomInfo, desc)
-			}
-			response := &common.Message{
-				Type:    common.TypeRoom,
-				Action:  common.RoomMembers,
-				Room:    room.ID,
-				Content: fmt.Sprintf("%s members: %s", roomInfo, strings.Join(memberList, ", ")),
-			}
-			client.SendMessage(response)
+			client.SendMessage(s.roomMembersMessage(room))
 		} else {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 			client.SendMessage(errMsg)
 		}
 
@@ -427,27 +561,28 @@ func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			// Check if user is the room creator
 			if room.Creator != client.Nickname {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "Only the room creator can kick members")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "Only the room creator can kick members"))
 				client.SendMessage(errMsg)
 				return
 			}
 
 			// Check if target is a member
 			if !room.IsMember(msg.Recipient) {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, fmt.Sprintf("%s is not a member of this room", msg.Recipient))
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "%s is not a member of this room", msg.Recipient))
 				client.SendMessage(errMsg)
 				return
 			}
 
 			// Can't kick yourself
 			if msg.Recipient == client.Nickname {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "You cannot kick yourself")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrValidation, "You cannot kick yourself"))
 				client.SendMessage(errMsg)
 				return
 			}
 
 			// Remove the member
 			room.RemoveMember(msg.Recipient)
+			s.roomManager.Save()
 
 			// Remove room from kicked user's list
 			if kickedClient, ok := s.GetClient(msg.Recipient); ok {
@@ -458,21 +593,22 @@ func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
 					Type:    common.TypeRoom,
 					Action:  common.RoomLeaveConfirm,
 					Room:    room.ID,
-					Content: fmt.Sprintf("You have been kicked from room '%s'", room.Name),
+					Content: common.T("You have been kicked from room '%s'", room.Name),
 				}
 				kickedClient.SendMessage(kickMsg)
 			}
 
 			// Notify room members
-			kickNotifyMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s has been kicked from the room by %s", msg.Recipient, client.Nickname))
+			kickNotifyMsg := common.NewTextMessage("Server", "", common.T("%s has been kicked from the room by %s", msg.Recipient, client.Nickname))
 			kickNotifyMsg.Room = msg.Room
-			s.roomManager.BroadcastToRoom(s, msg.Room, kickNotifyMsg)
+			s.roomManager.BroadcastToRoom(ctx, s, msg.Room, kickNotifyMsg)
 
 			// Confirm to the kicker
-			confirmMsg := common.NewTextMessage("Server", client.Nickname, fmt.Sprintf("%s has been kicked from the room", msg.Recipient))
+			confirmMsg := common.NewTextMessage("Server", client.Nickname, common.T("%s has been kicked from the room", msg.Recipient))
 			client.SendMessage(confirmMsg)
+			s.removeIfAbandoned(room)
 		} else {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 			client.SendMessage(errMsg)
 		}
 
@@ -480,81 +616,129 @@ func (s *Server) handleRoomMessage(client *Client, msg *common.Message) {
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			// Check if user is the room creator
 			if room.Creator != client.Nickname {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "Only the room creator can delete the room")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "Only the room creator can delete the room"))
 				client.SendMessage(errMsg)
 				return
 			}
 
-			// Notify all members about room deletion
-			deleteMsg := common.NewTextMessage("Server", "", fmt.Sprintf("Room '%s' has been deleted by the creator", room.Name))
-			deleteMsg.Room = msg.Room
-			s.roomManager.BroadcastToRoom(s, msg.Room, deleteMsg)
-
-			// Send leave confirmation to all members
-			members := room.GetMembers()
-			for _, member := range members {
-				if memberClient, ok := s.GetClient(member); ok {
-					memberClient.RemoveRoom(msg.Room)
-					leaveMsg := &common.Message{
-						Type:    common.TypeRoom,
-						Action:  common.RoomLeaveConfirm,
-						Room:    room.ID,
-						Content: fmt.Sprintf("Room '%s' has been deleted", room.Name),
-					}
-					memberClient.SendMessage(leaveMsg)
-				}
-			}
-
-			// Remove the room
-			s.roomManager.RemoveRoom(msg.Room)
+			s.deleteRoom(ctx, room, common.T("Room '%s' has been deleted by the creator", room.Name))
 
 			// Confirm to the creator
-			confirmMsg := common.NewTextMessage("Server", client.Nickname, fmt.Sprintf("Room '%s' has been deleted", room.Name))
+			confirmMsg := common.NewTextMessage("Server", client.Nickname, common.T("Room '%s' has been deleted", room.Name))
 			client.SendMessage(confirmMsg)
 		} else {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 			client.SendMessage(errMsg)
 		}
 
+	case common.RoomSettings:
+		s.handleRoomSettings(ctx, client, msg)
+
 	case common.RoomSetTopic:
 		if room, exists := s.roomManager.GetRoom(msg.Room); exists {
 			// Check if user is a member
 			if !room.IsMember(client.Nickname) {
-				errMsg := common.NewErrorMessage("Server", client.Nickname, "You must be a member to set the room topic")
+				errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You must be a member to set the room topic"))
 				client.SendMessage(errMsg)
 				return
 			}
 
 			// Set the topic
 			room.SetDescription(msg.Content)
+			s.roomManager.Save()
 
 			// Notify all room members
-			topicMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s set the room topic to: %s", client.Nickname, msg.Content))
+			topicMsg := common.NewTextMessage("Server", "", common.T("%s set the room topic to: %s", client.Nickname, msg.Content))
 			topicMsg.Room = msg.Room
-			s.roomManager.BroadcastToRoom(s, msg.Room, topicMsg)
+			s.roomManager.BroadcastToRoom(ctx, s, msg.Room, topicMsg)
 
 			// Confirm to the setter
-			confirmMsg := common.NewTextMessage("Server", client.Nickname, "Room topic updated")
+			confirmMsg := common.NewTextMessage("Server", client.Nickname, common.T("Room topic updated"))
 			client.SendMessage(confirmMsg)
 		} else {
-			errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 			client.SendMessage(errMsg)
 		}
 	}
 }
 
+// deleteRoom notifies the members of a room, makes them leave and removes it
+func (s *Server) deleteRoom(ctx context.Context, room *Room, notice string) {
+	// Notify all members about room deletion
+	deleteMsg := common.NewTextMessage("Server", "", notice)
+	deleteMsg.Room = room.ID
+	s.roomManager.BroadcastToRoom(ctx, s, room.ID, deleteMsg)
+
+	// Send leave confirmation to all members
+	members := room.GetMembers()
+	for _, member := range members {
+		if memberClient, ok := s.GetClient(member); ok {
+			memberClient.RemoveRoom(room.ID)
+			leaveMsg := &common.Message{
+				Type:    common.TypeRoom,
+				Action:  common.RoomLeaveConfirm,
+				Room:    room.ID,
+				Content: common.T("Room '%s' has been deleted", room.Name),
+			}
+			memberClient.SendMessage(leaveMsg)
+		}
+	}
+
+	// Remove the room
+	s.roomManager.RemoveRoom(room.ID)
+	s.rateLimiter.RemoveRoom(room.Creator)
+}
+
+// roomMembersMessage describes a room with its topic and members' status
+func (s *Server) roomMembersMessage(room *Room) *common.Message {
+	members := room.GetMembers()
+	var memberList []string
+	for _, member := range members {
+		status := "offline"
+		if memberClient, ok := s.GetClient(member); ok {
+			status = string(memberClient.GetStatus())
+		}
+		memberList = append(memberList, fmt.Sprintf("%s (%s)", member, status))
+	}
+
+	roomInfo := fmt.Sprintf("Room '%s'", room.Name)
+	if desc := room.GetDescription(); desc != "" {
+		roomInfo = fmt.Sprintf("%s (Topic: %s)", roomInfo, desc)
+	}
+	roomInfo += " [" + room.SettingsSummary() + "]"
+	if room.Ephemeral {
+		if left := room.TimeLeft(); left > 0 {
+			roomInfo += " " + common.T("[ephemeral, expires in %v]", left.Round(time.Second))
+		} else {
+			roomInfo += " " + common.T("[ephemeral]")
+		}
+	}
+	return &common.Message{
+		Type:    common.TypeRoom,
+		Action:  common.RoomMembers,
+		Room:    room.ID,
+		Content: common.T("%s members: %s", roomInfo, strings.Join(memberList, ", ")),
+	}
+}
+
 // handleInviteMessage handles room invitations
-func (s *Server) handleInviteMessage(client *Client, msg *common.Message) {
+func (s *Server) handleInviteMessage(ctx context.Context, client *Client, msg *common.Message) {
 	room, exists := s.roomManager.GetRoom(msg.Room)
 	if !exists {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, "Room not found")
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
 		client.SendMessage(errMsg)
 		return
 	}
 
 	// Check if sender is room member
 	if !room.IsMember(client.Nickname) {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, "You are not a member of this room")
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You are not a member of this room"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	if room.IsFull() {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrValidation, "Room '%s' is full", room.Name))
 		client.SendMessage(errMsg)
 		return
 	}
@@ -568,31 +752,38 @@ func (s *Server) handleInviteMessage(client *Client, msg *common.Message) {
 			Sender:    client.Nickname,
 			Recipient: msg.Recipient,
 			Room:      msg.Room,
-			Content:   fmt.Sprintf("%s invited you to join room '%s'", client.Nickname, room.Name),
+			Content:   common.T("%s invited you to join room '%s'", client.Nickname, room.Name),
 		}
 		recipient.SendMessage(inviteMsg)
 
 		// Confirm to sender
-		confirmMsg := common.NewTextMessage("Server", client.Nickname, fmt.Sprintf("Invitation sent to %s", msg.Recipient))
+		confirmMsg := common.NewTextMessage("Server", client.Nickname, common.T("Invitation sent to %s", msg.Recipient))
 		client.SendMessage(confirmMsg)
 	} else {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, fmt.Sprintf("User %s not found", msg.Recipient))
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "User %s not found", msg.Recipient))
 		client.SendMessage(errMsg)
 	}
 }
 
 // handleInviteResponse handles invitation responses
-func (s *Server) handleInviteResponse(client *Client, msg *common.Message) {
+func (s *Server) handleInviteResponse(ctx context.Context, client *Client, msg *common.Message) {
 	room, exists := s.roomManager.GetRoom(msg.Room)
 	if !exists {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, "Room no longer exists")
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room no longer exists"))
 		client.SendMessage(errMsg)
 		return
 	}
 
 	if msg.Content == "accept" && room.IsInvited(client.Nickname) {
+		if err := room.CanJoin(client.Nickname, ""); err != nil {
+			errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+			client.SendMessage(errMsg)
+			return
+		}
+
 		room.AddMember(client.Nickname)
 		client.AddRoom(room.ID)
+		s.roomManager.Save()
 
 		// Send room info to the joining user
 		roomInfo := room.Name
@@ -608,9 +799,9 @@ func (s *Server) handleInviteResponse(client *Client, msg *common.Message) {
 		client.SendMessage(response)
 
 		// Notify room members
-		joinMsg := common.NewTextMessage("Server", "", fmt.Sprintf("%s has joined the room", client.Nickname))
+		joinMsg := common.NewTextMessage("Server", "", common.T("%s has joined the room", client.Nickname))
 		joinMsg.Room = msg.Room
-		s.roomManager.BroadcastToRoom(s, msg.Room, joinMsg)
+		s.roomManager.BroadcastToRoom(ctx, s, msg.Room, joinMsg)
 	} else if msg.Content == "decline" {
 		// Remove invitation
 		room.mutex.Lock()
@@ -618,71 +809,133 @@ func (s *Server) handleInviteResponse(client *Client, msg *common.Message) {
 		room.mutex.Unlock()
 
 		// Confirm decline
-		confirmMsg := common.NewTextMessage("Server", client.Nickname, "Invitation declined")
+		confirmMsg := common.NewTextMessage("Server", client.Nickname, common.T("Invitation declined"))
 		client.SendMessage(confirmMsg)
 	}
 }
 
 // handleFileTransferInit initiates a file transfer
-func (s *Server) handleFileTransferInit(client *Client, msg *common.Message) {
-	// Check rate limit for file transfers
-	if err := s.rateLimiter.CanStartFileTransfer(client.Nickname); err != nil {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, err.Error())
+func (s *Server) handleFileTransferInit(ctx context.Context, client *Client, msg *common.Message) {
+	// Validate file name
+	if err := ValidateFileName(msg.Filename); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
 		client.SendMessage(errMsg)
 		return
 	}
 
-	// Validate file name
-	if err := ValidateFileName(msg.Filename); err != nil {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, err.Error())
+	// Validate what the receiver uses to pick a preview or folder
+	if err := ValidateContentType(msg.ContentType); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+		client.SendMessage(errMsg)
+		return
+	}
+	if err := ValidateMetadata(msg.Metadata); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
 		client.SendMessage(errMsg)
 		return
 	}
 
-	// Validate file size
+	// Validate file size and the chunk count it implies
 	if err := ValidateFileSize(msg.Filesize); err != nil {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, err.Error())
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+		client.SendMessage(errMsg)
+		return
+	}
+	if err := ValidateTotalChunks(msg.Filesize, msg.TotalChunks); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
 		client.SendMessage(errMsg)
 		return
 	}
 
 	recipient, exists := s.GetClient(msg.Recipient)
 	if !exists {
-		errMsg := common.NewErrorMessage("Server", client.Nickname, fmt.Sprintf("User %s not found", msg.Recipient))
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "User %s not found", msg.Recipient))
 		client.SendMessage(errMsg)
 		return
 	}
 
+	// Refuse early so the sender does not wait for a transfer that is dropped
+	if recipient.IsIgnoring(client.Nickname) {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "User %s is not accepting files from you", msg.Recipient))
+		client.SendMessage(errMsg)
+		client.SendMessage(&common.Message{
+			Type:      common.TypeFileControl,
+			Sender:    "Server",
+			FileID:    msg.FileID,
+			Filename:  msg.Filename,
+			Content:   common.FileCancel,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
 	// Create file transfer record
 	ft := &common.FileTransfer{
-		FileID:         msg.FileID,
-		Filename:       msg.Filename,
-		Filesize:       msg.Filesize,
-		Sender:         client.Nickname,
-		Recipient:      msg.Recipient,
-		TotalChunks:    msg.TotalChunks,
-		ReceivedChunks: make(map[int][]byte),
-		StartTime:      msg.Timestamp,
+		FileID:      msg.FileID,
+		Filename:    msg.Filename,
+		Filesize:    msg.Filesize,
+		ContentType: msg.ContentType,
+		Metadata:    msg.Metadata,
+		Sender:      client.Nickname,
+		Recipient:   msg.Recipient,
+		TotalChunks: msg.TotalChunks,
+		Chunks:      common.NewChunkAssembler(msg.TotalChunks, common.DefaultSpillThreshold),
+		StartTime:   msg.Timestamp,
 	}
 
-	s.fileTransfers.Store(msg.FileID, ft)
-	s.rateLimiter.AddFileTransfer(client.Nickname)
+	// Check the rate limit and reserve the size in one step, so concurrent
+	// transfers can't all pass the check and overshoot the budget
+	if err := s.rateLimiter.ReserveFileTransfer(client.Nickname, msg.Filesize); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+		client.SendMessage(errMsg)
+		return
+	}
+	// A reused ID would take over another transfer and leak its reservation
+	if _, loaded := s.fileTransfers.LoadOrStore(msg.FileID, ft); loaded {
+		s.rateLimiter.RemoveFileTransfer(client.Nickname, msg.Filesize)
+		ft.Close()
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrDuplicate, "File transfer %s already exists", msg.FileID))
+		client.SendMessage(errMsg)
+		return
+	}
 
 	// Forward to recipient
 	recipient.SendMessage(msg)
 }
 
 // handleFileChunk handles file chunk transfer
-func (s *Server) handleFileChunk(client *Client, msg *common.Message) {
-	value, exists := s.fileTransfers.Load(msg.FileID)
+func (s *Server) handleFileChunk(ctx context.Context, client *Client, msg *common.Message) {
+	ft, exists := s.fileTransfers.Load(msg.FileID)
 	if !exists {
 		return
 	}
+	if client.Nickname != ft.Sender {
+		client.Logger().With(common.F("file_id", msg.FileID)).Warn("Rejected file chunk from someone other than the sender")
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You are not the sender of this file transfer"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	// Storing may hit the disk, skip it when the request was cancelled
+	if ctx.Err() != nil {
+		return
+	}
 
-	ft := value.(*common.FileTransfer)
+	// Chunks may not add up to more than the announced size, which is what
+	// the transfer reserved from the memory budget
+	if ft.Chunks.Size()+int64(len(msg.Data)) > ft.Filesize {
+		client.Logger().With(common.F("file_id", msg.FileID)).Warn("Rejected file chunk beyond the announced size")
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname,
+			common.ChatErrorf(common.ErrValidation, "file chunks exceed the announced size of %s", common.FormatFileSize(ft.Filesize)))
+		client.SendMessage(errMsg)
+		return
+	}
 
 	// Store chunk using thread-safe method
-	ft.AddChunk(msg.ChunkNum, msg.Data)
+	if err := ft.AddChunk(msg.ChunkNum, msg.Data); err != nil {
+		client.Logger().With(common.F("file_id", msg.FileID)).Warn("Rejected file chunk: %v", err)
+		return
+	}
 
 	// Forward to recipient
 	if recipient, ok := s.GetClient(ft.Recipient); ok {
@@ -698,52 +951,118 @@ func (s *Server) handleFileChunk(client *Client, msg *common.Message) {
 			recipient.SendMessage(completeMsg)
 			client.SendMessage(completeMsg)
 
-			// Clean up
-			s.fileTransfers.Delete(msg.FileID)
+			// Clean up and release the sender's transfer slot
+			s.stats.RecordFileTransfer()
+			s.finishFileTransfer(ft)
 		}
 	}
 }
 
+// finishFileTransfer forgets a transfer, frees its chunks and releases the
+// sender's slot and memory reservation; only the first call has an effect
+func (s *Server) finishFileTransfer(ft *common.FileTransfer) {
+	if _, loaded := s.fileTransfers.LoadAndDelete(ft.FileID); !loaded {
+		return
+	}
+	ft.Close()
+	s.rateLimiter.RemoveFileTransfer(ft.Sender, ft.Filesize)
+}
+
+// handleFileControl relays pause, resume and cancel requests between the
+// sender and recipient of a file transfer
+func (s *Server) handleFileControl(ctx context.Context, client *Client, msg *common.Message) {
+	ft, exists := s.fileTransfers.Load(msg.FileID)
+	if !exists {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "File transfer not found"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	var peer string
+	switch client.Nickname {
+	case ft.Sender:
+		peer = ft.Recipient
+	case ft.Recipient:
+		peer = ft.Sender
+	default:
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "You are not part of this file transfer"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	switch msg.Content {
+	case common.FilePause, common.FileResume:
+	case common.FileCancel:
+		s.finishFileTransfer(ft)
+	default:
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrValidation, "Invalid file transfer action: %s", msg.Content))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	client.Logger().With(common.F("file_id", msg.FileID)).Info("File transfer %s: %s", ft.Filename, msg.Content)
+
+	if other, ok := s.GetClient(peer); ok {
+		other.SendMessage(&common.Message{
+			Type:      common.TypeFileControl,
+			Sender:    client.Nickname,
+			FileID:    msg.FileID,
+			Filename:  ft.Filename,
+			Content:   msg.Content,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
 // handleShutdown handles graceful server shutdown
 func (s *Server) handleShutdown() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	<-sigChan
-	common.Info("Shutting down server...")
+	select {
+	case <-sigChan:
+	case <-s.stopRequest:
+	}
+	s.logger.Info("Shutting down server...")
 
 	// Create shutdown context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), common.ShutdownTimeout)
 	defer cancel()
 
 	// Notify all clients
-	shutdownMsg := common.NewBroadcastMessage("Server", "Server is shutting down")
-	s.BroadcastMessage(shutdownMsg, "")
+	shutdownMsg := common.NewBroadcastMessage("Server", common.T("Server is shutting down"))
+	s.BroadcastMessage(ctx, shutdownMsg, "")
 
-	// Give clients time to receive the message
-	time.Sleep(100 * time.Millisecond)
-
-	// Close all client connections
+	// Close all client connections once the notice was delivered
 	connClosed := make(chan bool)
 	go func() {
-		s.clients.Range(func(key, value interface{}) bool {
-			client := value.(*Client)
-			client.Close()
+		var wg sync.WaitGroup
+		s.clients.Range(func(_ string, client *Client) bool {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.Shutdown(ctx)
+			}()
 			return true
 		})
+		wg.Wait()
 		close(connClosed)
 	}()
 
 	// Wait for connections to close or timeout
 	select {
 	case <-connClosed:
-		common.Info("All client connections closed")
+		s.logger.Info("All client connections closed")
 	case <-ctx.Done():
-		common.Warn("Shutdown timeout exceeded, forcing shutdown")
+		s.logger.Warn("Shutdown timeout exceeded, forcing shutdown")
 	}
 
+	// Abort whatever is still running for clients that did not finish in time
+	s.cancel()
+
 	// Stop cleanup manager
 	s.cleanupManager.Stop()
+	s.webhooks.Stop()
 
 	// Stop rate limiter
 	s.rateLimiter.Stop()
@@ -752,35 +1071,107 @@ func (s *Server) handleShutdown() {
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	s.grpcServer.Stop()
+	s.stopAPI(ctx)
 
 	close(s.shutdown)
-	common.Info("Server shutdown complete")
+	s.logger.Info("Server shutdown complete")
 }
 
 func main() {
 	port := flag.String("port", "8080", "Server port")
+	grpcPort := flag.String("grpc-port", "", "Port of the gRPC Chat service (disabled if empty)")
+	apiPort := flag.String("api-port", "", "Port of the HTTP API (disabled if empty)")
+	apiToken := flag.String("api-token", os.Getenv("CHAT_API_TOKEN"), "Bearer token required by the HTTP API, defaults to $CHAT_API_TOKEN")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	logLevels := flag.String("log-levels", "", "Per-component log levels, e.g. server=debug,ratelimit=warn")
+	logFormat := flag.String("log-format", "text", "Log format (text, json)")
+	logFile := flag.String("log-file", "server.log", "Log file")
+	logMaxSize := flag.Int64("log-max-size", common.DefaultLogMaxSize/(1024*1024), "Rotate the log file after this many megabytes (0 disables)")
+	logMaxAge := flag.Duration("log-max-age", 0, "Rotate the log file after this duration, e.g. 24h (0 disables)")
+	logBackups := flag.Int("log-backups", common.DefaultLogMaxBackups, "Number of rotated log files to keep (0 keeps all)")
+	lang := flag.String("lang", common.DefaultLanguage, "Language of messages sent to clients (en, pl)")
+	transferBudget := flag.Int64("transfer-budget", common.TransferMemoryBudget/(1024*1024), "Megabytes all in-flight file transfers may buffer together")
+	webhookFile := flag.String("webhooks", "", "JSON file listing webhooks notified about server events (disabled if empty)")
+	storeFile := flag.String("store", "", "File persisting rooms and memberships across restarts (disabled if empty)")
+	storeKeyFile := flag.String("store-passphrase-file", "", "File holding the passphrase encrypting the store, defaults to $CHAT_STORE_PASSPHRASE (unencrypted if neither is set)")
+	challengeMode := flag.String("challenge", ChallengeOff, "Challenge new connections must answer before registering (off, math, token)")
+	challengeToken := flag.String("challenge-token", os.Getenv("CHAT_CHALLENGE_TOKEN"), "Access token for -challenge token, defaults to $CHAT_CHALLENGE_TOKEN")
 	flag.Parse()
 
+	if err := common.SetLanguage(*lang); err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize logging
-	level := common.LogInfo
-	switch *logLevel {
-	case "debug":
-		level = common.LogDebug
-	case "warn":
-		level = common.LogWarn
-	case "error":
-		level = common.LogError
+	level, err := common.ParseLogLevel(*logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	componentLevels, err := common.ParseComponentLevels(*logLevels)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if err := common.InitLogger("server.log", level); err != nil {
-		log.Printf("Failed to initialize logger: %v", err)
+	logConfig := common.LogConfig{
+		Level:      level,
+		Format:     common.LogFormat(*logFormat),
+		Filename:   *logFile,
+		MaxSize:    *logMaxSize * 1024 * 1024,
+		MaxAge:     *logMaxAge,
+		MaxBackups: *logBackups,
+
+		ComponentLevels: componentLevels,
+	}
+	if err := common.InitLoggerWithConfig(logConfig); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 	defer common.GlobalLogger.Close()
 
 	common.Info("Starting TCP Chat Server on port %s", *port)
 
 	server := NewServer()
+	if *transferBudget <= 0 {
+		common.Fatal("Transfer budget must be positive")
+	}
+	server.rateLimiter.SetTransferBudget(*transferBudget * 1024 * 1024)
+	if err := server.SetChallenge(ChallengeConfig{Mode: *challengeMode, Token: *challengeToken}); err != nil {
+		common.Fatal("Invalid challenge configuration: %v", err)
+	}
+	if *webhookFile != "" {
+		hooks, err := LoadWebhooks(*webhookFile)
+		if err != nil {
+			common.Fatal("Invalid webhooks: %v", err)
+		}
+		server.webhooks.SetWebhooks(hooks)
+	}
+	if *storeFile != "" {
+		passphrase, err := common.LoadPassphrase(*storeKeyFile, "CHAT_STORE_PASSPHRASE")
+		if err != nil {
+			common.Fatal("Failed to load store passphrase: %v", err)
+		}
+		store := NewFileStore(*storeFile)
+		if passphrase != "" {
+			cipher, err := common.NewFileCipher(passphrase)
+			if err != nil {
+				common.Fatal("Failed to set up store encryption: %v", err)
+			}
+			store = NewEncryptedFileStore(*storeFile, cipher)
+		}
+		if err := server.roomManager.SetStore(store); err != nil {
+			common.Fatal("Failed to load store: %v", err)
+		}
+	}
+	if *grpcPort != "" {
+		if err := server.StartGRPC(*grpcPort); err != nil {
+			common.Fatal("gRPC error: %v", err)
+		}
+	}
+	if *apiPort != "" {
+		if err := server.StartAPI(*apiPort, *apiToken); err != nil {
+			common.Fatal("HTTP API error: %v", err)
+		}
+	}
 	if err := server.Start(*port); err != nil {
 		common.Fatal("Server error: %v", err)
 	}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// sendUserList sends the full list of visible users to one client; it is
+// only used for the initial sync, later changes arrive as presence events
+func (s *Server) sendUserList(client *Client) {
+	var users []string
+
+	s.clients.Range(func(_ string, other *Client) bool {
+		// Don't include invisible users in the list
+		if other.GetStatus() != common.StatusInvisible {
+			entry := fmt.Sprintf("%s:%s", other.Nickname, other.GetStatus())
+			if away := other.GetAway(); away != "" {
+				entry += ":" + away
+			}
+			users = append(users, entry)
+		}
+		return true
+	})
+
+	client.SendMessage(&common.Message{
+		Type:      common.TypeUserList,
+		Users:     users,
+		Timestamp: time.Now(),
+	})
+}
+
+// broadcastPresence tells every other client about a change of a user;
+// away carries the reason of away users
+func (s *Server) broadcastPresence(ctx context.Context, eventType common.MessageType, nickname string, status common.UserStatus, away string) {
+	msg := &common.Message{
+		Type:      eventType,
+		Sender:    nickname,
+		Status:    status,
+		Content:   away,
+		Timestamp: time.Now(),
+	}
+	s.BroadcastMessage(ctx, msg, nickname)
+}
+
+// announceJoined announces a newly registered user unless they are invisible
+func (s *Server) announceJoined(ctx context.Context, client *Client) {
+	if status := client.GetStatus(); status != common.StatusInvisible {
+		s.broadcastPresence(ctx, common.TypeUserJoined, client.Nickname, status, client.GetAway())
+	}
+}
+
+// announceLeft announces a disconnected user unless they were invisible
+func (s *Server) announceLeft(ctx context.Context, client *Client) {
+	if client.GetStatus() != common.StatusInvisible {
+		s.broadcastPresence(ctx, common.TypeUserLeft, client.Nickname, "", "")
+	}
+}
+
+// announceStatus announces a status change. Invisible users are hidden
+// from lists but keep receiving messages, so going invisible looks like
+// leaving to others and coming back like joining. A new away message is
+// announced like a status change.
+func (s *Server) announceStatus(ctx context.Context, client *Client, old common.UserStatus, oldAway string) {
+	status, away := client.GetStatus(), client.GetAway()
+	switch {
+	case status == old && away == oldAway:
+	case status == common.StatusInvisible:
+		s.broadcastPresence(ctx, common.TypeUserLeft, client.Nickname, "", "")
+	case old == common.StatusInvisible:
+		s.broadcastPresence(ctx, common.TypeUserJoined, client.Nickname, status, away)
+	default:
+		s.broadcastPresence(ctx, common.TypeUserStatus, client.Nickname, status, away)
+		text := common.T("%s is now %s", client.Nickname, status)
+		if away != "" {
+			text = common.T("%s is away: %s", client.Nickname, away)
+		}
+		s.BroadcastMessage(ctx, common.NewBroadcastMessage("Server", text), client.Nickname)
+	}
+}
+
+// autoReplyAway tells sender, once per away period, that recipient is away
+func (s *Server) autoReplyAway(sender, recipient *Client) {
+	if sender == recipient || !recipient.ShouldAutoReply(sender.Nickname) {
+		return
+	}
+	text := common.T("%s is away", recipient.Nickname)
+	if away := recipient.GetAway(); away != "" {
+		text = common.T("%s is away: %s", recipient.Nickname, away)
+	}
+	sender.SendMessage(&common.Message{
+		Type:      common.TypeText,
+		Sender:    "Server",
+		Recipient: sender.Nickname,
+		Content:   text,
+		Timestamp: time.Now(),
+	})
+}
+
+// visibleStatus returns the status others may see for a user; invisible
+// and unknown users are reported as offline
+func (s *Server) visibleStatus(nickname string) common.UserStatus {
+	client, ok := s.GetClient(nickname)
+	if !ok || client.GetStatus() == common.StatusInvisible {
+		return common.StatusOffline
+	}
+	return client.GetStatus()
+}
+
+// handleStatusQuery answers a request for the availability of a user
+func (s *Server) handleStatusQuery(client *Client, nickname string) {
+	status := s.visibleStatus(nickname)
+	if nickname == client.Nickname {
+		status = client.GetStatus()
+	}
+	away := ""
+	if other, ok := s.GetClient(nickname); ok && status == common.StatusAway {
+		away = other.GetAway()
+	}
+	client.SendMessage(&common.Message{
+		Type:      common.TypeStatus,
+		Sender:    nickname,
+		Recipient: client.Nickname,
+		Status:    status,
+		Content:   away,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+// newTestServer creates a server that is cancelled when the test ends
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.cancel)
+	return s
+}
+
+// newTestClient registers a client backed by an in-memory connection and
+// discards the messages sent during registration
+func newTestClient(t *testing.T, s *Server, nickname string) *Client {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+
+	client := NewClient(conn, s)
+	if ok, err := s.RegisterClient(context.Background(), client, nickname); !ok {
+		t.Fatalf("RegisterClient(%s): %v", nickname, err)
+	}
+	drain(client)
+	return client
+}
+
+// drain returns the messages queued for a client
+func drain(client *Client) []*common.Message {
+	var msgs []*common.Message
+	for {
+		select {
+		case msg := <-client.SendChan:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+// findMessage returns the first message of the given type
+func findMessage(msgs []*common.Message, msgType common.MessageType) *common.Message {
+	for _, msg := range msgs {
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+	return nil
+}
+
+// setStatus handles a status change sent by a client
+func setStatus(t *testing.T, s *Server, client *Client, status common.UserStatus) {
+	t.Helper()
+	msg := common.NewStatusMessage(client.Nickname, status)
+	if err := s.HandleMessage(context.Background(), client, msg); err != nil {
+		t.Fatalf("status %s: %v", status, err)
+	}
+}
+
+func TestInvisibleUserReceivesBroadcasts(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+	setStatus(t, s, bob, common.StatusInvisible)
+	drain(bob)
+
+	msg := common.NewBroadcastMessage("alice", "hello")
+	if err := s.HandleMessage(context.Background(), alice, msg); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+
+	if got := findMessage(drain(bob), common.TypeText); got == nil || got.Content != "hello" {
+		t.Errorf("invisible user did not receive the broadcast, got %v", got)
+	}
+}
+
+func TestInvisibleUserReceivesRoomMessages(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+
+	room := s.roomManager.CreateRoom("lounge", "alice")
+	room.AddMember("bob")
+	setStatus(t, s, bob, common.StatusInvisible)
+	drain(bob)
+
+	msg := common.NewTextMessage("alice", "", "in the room")
+	msg.Room = room.ID
+	if err := s.HandleMessage(context.Background(), alice, msg); err != nil {
+		t.Fatalf("room message: %v", err)
+	}
+
+	if got := findMessage(drain(bob), common.TypeText); got == nil || got.Content != "in the room" {
+		t.Errorf("invisible member did not receive the room message, got %v", got)
+	}
+}
+
+func TestInvisibleUserHiddenFromLists(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	setStatus(t, s, alice, common.StatusInvisible)
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	carol := NewClient(conn, s)
+	if ok, err := s.RegisterClient(context.Background(), carol, "carol"); !ok {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+
+	list := findMessage(drain(carol), common.TypeUserList)
+	if list == nil {
+		t.Fatal("no user list sent on registration")
+	}
+	for _, user := range list.Users {
+		if user == "alice:"+string(common.StatusInvisible) {
+			t.Errorf("invisible user listed: %v", list.Users)
+		}
+	}
+}
+
+func TestGoingInvisibleLooksLikeLeaving(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+	drain(alice)
+
+	setStatus(t, s, bob, common.StatusInvisible)
+	msgs := drain(alice)
+	if left := findMessage(msgs, common.TypeUserLeft); left == nil || left.Sender != "bob" {
+		t.Errorf("expected USER_LEFT for bob, got %v", msgs)
+	}
+	if text := findMessage(msgs, common.TypeText); text != nil {
+		t.Errorf("going invisible was announced: %q", text.Content)
+	}
+
+	setStatus(t, s, bob, common.StatusBusy)
+	joined := findMessage(drain(alice), common.TypeUserJoined)
+	if joined == nil || joined.Sender != "bob" || joined.Status != common.StatusBusy {
+		t.Errorf("expected USER_JOINED for bob as busy, got %v", joined)
+	}
+}
+
+func TestStatusQuery(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+
+	tests := []struct {
+		name   string
+		status common.UserStatus
+		asker  *Client
+		target string
+		want   common.UserStatus
+	}{
+		{"busy user", common.StatusBusy, alice, "bob", common.StatusBusy},
+		{"invisible user", common.StatusInvisible, alice, "bob", common.StatusOffline},
+		{"invisible self", common.StatusInvisible, bob, "bob", common.StatusInvisible},
+		{"unknown user", common.StatusActive, alice, "nobody", common.StatusOffline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setStatus(t, s, bob, tt.status)
+			drain(tt.asker)
+
+			query := &common.Message{Type: common.TypeStatus, Recipient: tt.target}
+			if err := s.HandleMessage(context.Background(), tt.asker, query); err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			reply := findMessage(drain(tt.asker), common.TypeStatus)
+			if reply == nil {
+				t.Fatal("no reply to the status query")
+			}
+			if reply.Sender != tt.target || reply.Status != tt.want {
+				t.Errorf("reply %s:%s, want %s:%s", reply.Sender, reply.Status, tt.target, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownStatusRejected(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+
+	err := s.HandleMessage(context.Background(), alice, common.NewStatusMessage("alice", "sleeping"))
+	if !common.IsType(err, common.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+	if got := alice.GetStatus(); got != common.StatusActive {
+		t.Errorf("status changed to %s", got)
+	}
+}
+
+func TestAwayAutoReply(t *testing.T) {
+	s := newTestServer(t)
+	alice := newTestClient(t, s, "alice")
+	bob := newTestClient(t, s, "bob")
+
+	away := common.NewStatusMessage("bob", common.StatusAway)
+	away.Content = "  lunch  "
+	if err := s.HandleMessage(context.Background(), bob, away); err != nil {
+		t.Fatalf("away: %v", err)
+	}
+	status := findMessage(drain(alice), common.TypeUserStatus)
+	if status == nil || status.Status != common.StatusAway || status.Content != "lunch" {
+		t.Errorf("expected USER_STATUS with the away message, got %v", status)
+	}
+
+	// The sender is told once, the message is still delivered
+	for i := 0; i < 2; i++ {
+		msg := common.NewTextMessage("alice", "bob", "ping")
+		if err := s.HandleMessage(context.Background(), alice, msg); err != nil {
+			t.Fatalf("private message: %v", err)
+		}
+	}
+	var replies int
+	for _, msg := range drain(alice) {
+		if msg.Sender == "Server" {
+			replies++
+			if msg.Content != "bob is away: lunch" {
+				t.Errorf("auto-reply %q", msg.Content)
+			}
+		}
+	}
+	if replies != 1 {
+		t.Errorf("got %d auto-replies, want 1", replies)
+	}
+	if delivered := len(drain(bob)); delivered != 2 {
+		t.Errorf("bob received %d messages, want 2", delivered)
+	}
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	carol := NewClient(conn, s)
+	if ok, err := s.RegisterClient(context.Background(), carol, "carol"); !ok {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	list := findMessage(drain(carol), common.TypeUserList)
+	if list == nil || !slices.Contains(list.Users, "bob:AWAY:lunch") {
+		t.Errorf("away reason missing from the user list: %v", list)
+	}
+
+	// Coming back clears the message
+	setStatus(t, s, bob, common.StatusActive)
+	if got := bob.GetAway(); got != "" {
+		t.Errorf("away message %q kept after coming back", got)
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"net"
 	"sync"
 	"tcp-chat/common"
@@ -23,12 +22,29 @@ type RateLimiter struct {
 	roomsPerUser map[string]int
 	roomMutex    sync.RWMutex
 
-	// File transfer limiting
+	// File transfer limiting, including the bytes reserved by transfers
 	transfersPerUser map[string]int
+	bytesPerUser     map[string]int64
+	transferBytes    int64
+	transferBudget   int64
 	transferMutex    sync.RWMutex
 
+	// Reconnect throttling
+	throttles     map[string]*ipThrottle
+	throttleMutex sync.Mutex
+
 	// Cleanup ticker
 	cleanupTicker *time.Ticker
+
+	logger *common.Logger
+}
+
+// ipThrottle tracks recent connection attempts and bans of one IP
+type ipThrottle struct {
+	attempts    []time.Time
+	strikes     int
+	bannedUntil time.Time
+	lastStrike  time.Time
 }
 
 type userRateLimit struct {
@@ -44,7 +60,11 @@ func NewRateLimiter() *RateLimiter {
 		messageRates:     make(map[string]*userRateLimit),
 		roomsPerUser:     make(map[string]int),
 		transfersPerUser: make(map[string]int),
+		bytesPerUser:     make(map[string]int64),
+		transferBudget:   common.TransferMemoryBudget,
+		throttles:        make(map[string]*ipThrottle),
 		cleanupTicker:    time.NewTicker(1 * time.Minute),
+		logger:           common.GetLogger("ratelimit"),
 	}
 
 	// Start cleanup routine
@@ -60,23 +80,80 @@ func (rl *RateLimiter) CanConnect(addr net.Addr) error {
 
 	// Check total connections
 	if rl.totalConnections >= common.MaxConnections {
-		return fmt.Errorf("server has reached maximum connection limit (%d)", common.MaxConnections)
+		rl.logger.With(common.F("remote_addr", addr.String())).Warn("Connection limit reached")
+		return common.ChatErrorf(common.ErrRateLimit, "server has reached maximum connection limit (%d)", common.MaxConnections)
 	}
 
 	// Extract IP from address
 	ip, _, err := net.SplitHostPort(addr.String())
 	if err != nil {
-		return fmt.Errorf("invalid address format")
+		return common.ChatErrorf(common.ErrValidation, "invalid address format")
+	}
+
+	// Check reconnect storms
+	if err := rl.throttleConnect(ip, time.Now()); err != nil {
+		return err
 	}
 
 	// Check per-IP limit
 	if rl.connectionsByIP[ip] >= common.MaxConnectionsPerIP {
-		return fmt.Errorf("IP %s has reached maximum connection limit (%d)", ip, common.MaxConnectionsPerIP)
+		rl.logger.With(common.F("ip", ip)).Warn("Per-IP connection limit reached")
+		return common.ChatErrorf(common.ErrRateLimit, "IP %s has reached maximum connection limit (%d)", ip, common.MaxConnectionsPerIP)
 	}
 
 	return nil
 }
 
+// throttleConnect records a connection attempt and bans IPs that reconnect
+// too quickly, doubling the ban for every repeated offence
+func (rl *RateLimiter) throttleConnect(ip string, now time.Time) error {
+	rl.throttleMutex.Lock()
+	defer rl.throttleMutex.Unlock()
+
+	t, exists := rl.throttles[ip]
+	if !exists {
+		t = &ipThrottle{}
+		rl.throttles[ip] = t
+	}
+
+	if now.Before(t.bannedUntil) {
+		wait := t.bannedUntil.Sub(now).Round(time.Second)
+		return common.ChatErrorf(common.ErrRateLimit, "too many connection attempts, try again in %v", wait).
+			WithDetail("retry_after", wait.String())
+	}
+
+	// Forget old offences after a quiet period
+	if t.strikes > 0 && now.Sub(t.lastStrike) > common.ReconnectStrikeReset {
+		t.strikes = 0
+	}
+
+	// Keep only attempts inside the window
+	recent := t.attempts[:0]
+	for _, attempt := range t.attempts {
+		if now.Sub(attempt) < common.ReconnectWindow {
+			recent = append(recent, attempt)
+		}
+	}
+	t.attempts = append(recent, now)
+
+	if len(t.attempts) <= common.ReconnectsPerWindow {
+		return nil
+	}
+
+	t.strikes++
+	t.lastStrike = now
+	t.attempts = nil
+	ban := common.ReconnectBanBase << (t.strikes - 1)
+	if ban > common.ReconnectBanMax || ban <= 0 {
+		ban = common.ReconnectBanMax
+	}
+	t.bannedUntil = now.Add(ban)
+
+	rl.logger.With(common.F("ip", ip), common.F("strikes", t.strikes)).Warn("Reconnect storm, banned for %v", ban)
+	return common.ChatErrorf(common.ErrRateLimit, "too many connection attempts, try again in %v", ban).
+		WithDetail("retry_after", ban.String())
+}
+
 // AddConnection registers a new connection
 func (rl *RateLimiter) AddConnection(addr net.Addr) {
 	rl.connMutex.Lock()
@@ -130,7 +207,9 @@ func (rl *RateLimiter) CanSendMessage(nickname string) error {
 
 	// Check rate limit
 	if userLimit.messages >= common.MessagesPerSecond {
-		return fmt.Errorf("message rate limit exceeded (%d messages per second)", common.MessagesPerSecond)
+		rl.logger.With(common.F("nickname", nickname)).Debug("Message rate limit exceeded")
+		return common.ChatErrorf(common.ErrRateLimit, "message rate limit exceeded (%d messages per second)", common.MessagesPerSecond).
+			WithDetail("retry_after", (time.Second - time.Since(userLimit.lastReset)).Round(time.Millisecond).String())
 	}
 
 	userLimit.messages++
@@ -143,7 +222,8 @@ func (rl *RateLimiter) CanCreateRoom(nickname string) error {
 	defer rl.roomMutex.Unlock()
 
 	if rl.roomsPerUser[nickname] >= common.RoomsPerUser {
-		return fmt.Errorf("room creation limit exceeded (%d rooms per user)", common.RoomsPerUser)
+		rl.logger.With(common.F("nickname", nickname)).Info("Room creation limit reached")
+		return common.ChatErrorf(common.ErrRateLimit, "room creation limit exceeded (%d rooms per user)", common.RoomsPerUser)
 	}
 
 	return nil
@@ -171,28 +251,49 @@ func (rl *RateLimiter) RemoveRoom(nickname string) {
 	}
 }
 
-// CanStartFileTransfer checks if a user can start a file transfer
-func (rl *RateLimiter) CanStartFileTransfer(nickname string) error {
+// SetTransferBudget sets how many bytes all in-flight file transfers may
+// buffer together
+func (rl *RateLimiter) SetTransferBudget(bytes int64) {
 	rl.transferMutex.Lock()
 	defer rl.transferMutex.Unlock()
+	rl.transferBudget = bytes
+}
 
-	if rl.transfersPerUser[nickname] >= common.FileTransfersPerUser {
-		return fmt.Errorf("file transfer limit exceeded (%d concurrent transfers per user)", common.FileTransfersPerUser)
-	}
-
-	return nil
+// TransferMemory returns the bytes reserved by in-flight transfers and the budget
+func (rl *RateLimiter) TransferMemory() (reserved, budget int64) {
+	rl.transferMutex.RLock()
+	defer rl.transferMutex.RUnlock()
+	return rl.transferBytes, rl.transferBudget
 }
 
-// AddFileTransfer registers a file transfer
-func (rl *RateLimiter) AddFileTransfer(nickname string) {
+// ReserveFileTransfer registers a file transfer of size bytes and reserves
+// its size, unless that exceeds the transfer limits or the memory budget
+func (rl *RateLimiter) ReserveFileTransfer(nickname string, size int64) error {
 	rl.transferMutex.Lock()
 	defer rl.transferMutex.Unlock()
 
+	if rl.transfersPerUser[nickname] >= common.FileTransfersPerUser {
+		rl.logger.With(common.F("nickname", nickname)).Info("File transfer limit reached")
+		return common.ChatErrorf(common.ErrRateLimit, "file transfer limit exceeded (%d concurrent transfers per user)", common.FileTransfersPerUser)
+	}
+	if rl.bytesPerUser[nickname]+size > common.TransferMemoryPerUser {
+		rl.logger.With(common.F("nickname", nickname)).Info("Per-user transfer memory limit reached")
+		return common.ChatErrorf(common.ErrRateLimit, "your file transfers in progress cannot exceed %s, wait for them to finish",
+			common.FormatFileSize(common.TransferMemoryPerUser))
+	}
+	if rl.transferBytes+size > rl.transferBudget {
+		rl.logger.With(common.F("nickname", nickname), common.F("reserved", rl.transferBytes)).Warn("Transfer memory budget exhausted")
+		return common.ChatErrorf(common.ErrUnavailable, "the server is busy with other file transfers, try again later")
+	}
+
 	rl.transfersPerUser[nickname]++
+	rl.bytesPerUser[nickname] += size
+	rl.transferBytes += size
+	return nil
 }
 
-// RemoveFileTransfer removes a file transfer
-func (rl *RateLimiter) RemoveFileTransfer(nickname string) {
+// RemoveFileTransfer removes a file transfer and releases its reservation
+func (rl *RateLimiter) RemoveFileTransfer(nickname string, size int64) {
 	rl.transferMutex.Lock()
 	defer rl.transferMutex.Unlock()
 
@@ -203,6 +304,12 @@ func (rl *RateLimiter) RemoveFileTransfer(nickname string) {
 			rl.transfersPerUser[nickname]--
 		}
 	}
+	if rl.bytesPerUser[nickname] -= size; rl.bytesPerUser[nickname] <= 0 {
+		delete(rl.bytesPerUser, nickname)
+	}
+	if rl.transferBytes -= size; rl.transferBytes < 0 {
+		rl.transferBytes = 0
+	}
 }
 
 // RemoveUser cleans up all rate limit data for a user
@@ -215,9 +322,8 @@ func (rl *RateLimiter) RemoveUser(nickname string) {
 	delete(rl.roomsPerUser, nickname)
 	rl.roomMutex.Unlock()
 
-	rl.transferMutex.Lock()
-	delete(rl.transfersPerUser, nickname)
-	rl.transferMutex.Unlock()
+	// File transfers outlive the connection, their reservations are released
+	// when they finish or time out
 }
 
 // cleanup periodically cleans up old rate limit data
@@ -232,6 +338,16 @@ func (rl *RateLimiter) cleanup() {
 			userLimit.mutex.Unlock()
 		}
 		rl.rateMutex.Unlock()
+
+		now := time.Now()
+		rl.throttleMutex.Lock()
+		for ip, t := range rl.throttles {
+			idle := len(t.attempts) == 0 || now.Sub(t.attempts[len(t.attempts)-1]) > common.ReconnectWindow
+			if idle && now.After(t.bannedUntil) && now.Sub(t.lastStrike) > common.ReconnectStrikeReset {
+				delete(rl.throttles, ip)
+			}
+		}
+		rl.throttleMutex.Unlock()
 	}
 }
 
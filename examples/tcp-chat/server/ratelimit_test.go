@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+func TestTransferMemoryBudget(t *testing.T) {
+	const mb = 1024 * 1024
+	rl := NewRateLimiter()
+	rl.SetTransferBudget(150 * mb)
+
+	tests := []struct {
+		name     string
+		nickname string
+		size     int64
+		wantCode common.ErrorType
+	}{
+		{"first transfer", "alice", 60 * mb, ""},
+		{"second user", "bob", 60 * mb, ""},
+		{"per-user limit", "alice", 50 * mb, common.ErrRateLimit},
+		{"global budget", "carol", 40 * mb, common.ErrUnavailable},
+		{"fits the remaining budget", "carol", 30 * mb, ""},
+	}
+
+	for _, tt := range tests {
+		err := rl.ReserveFileTransfer(tt.nickname, tt.size)
+		if tt.wantCode == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if !common.IsType(err, tt.wantCode) {
+			t.Fatalf("%s: got %v, want a %s error", tt.name, err, tt.wantCode)
+		}
+	}
+
+	if reserved, _ := rl.TransferMemory(); reserved != 150*mb {
+		t.Errorf("reserved %d bytes, want %d", reserved, 150*mb)
+	}
+
+	// Finishing a transfer frees its share of the budget
+	rl.RemoveFileTransfer("bob", 60*mb)
+	if reserved, _ := rl.TransferMemory(); reserved != 90*mb {
+		t.Errorf("reserved %d bytes after release, want %d", reserved, 90*mb)
+	}
+	if err := rl.ReserveFileTransfer("carol", 40*mb); err != nil {
+		t.Errorf("budget not released: %v", err)
+	}
+}
+
+func TestConcurrentTransferReservations(t *testing.T) {
+	const mb = 1024 * 1024
+	rl := NewRateLimiter()
+	rl.SetTransferBudget(100 * mb)
+
+	var reserved atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rl.ReserveFileTransfer(fmt.Sprintf("user%d", i), 30*mb) == nil {
+				reserved.Add(30 * mb)
+			}
+		}()
+	}
+	wg.Wait()
+	if total, budget := rl.TransferMemory(); total != reserved.Load() || total > budget {
+		t.Errorf("Reserved %d bytes, %d granted, budget %d", total, reserved.Load(), budget)
+	}
+}
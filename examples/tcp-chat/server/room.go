@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,26 +11,80 @@ import (
 
 // Room represents a private chat room
 type Room struct {
-	ID          string
-	Name        string
-	Description string
-	Creator     string
-	Members     map[string]bool
-	Invitations map[string]bool
-	CreatedAt   time.Time
-	mutex       sync.RWMutex
+	ID           string
+	Name         string
+	Description  string
+	Creator      string
+	Members      map[string]bool
+	Invitations  map[string]bool
+	CreatedAt    time.Time
+	Policy       string    // Join policy, see common.PolicyOpen
+	PasswordHash string    // Required by the password policy
+	MaxMembers   int       // Zero means no limit
+	Ephemeral    bool      // Deleted when the last member leaves, never persisted
+	ExpiresAt    time.Time // Deletion time of an ephemeral room, zero without TTL
+	logger       *common.Logger
+	mutex        sync.RWMutex
 }
 
 // NewRoom creates a new room
 func NewRoom(name, creator string) *Room {
+	id := common.GenerateID("room")
 	return &Room{
-		ID:          common.GenerateID("room"),
+		ID:          id,
 		Name:        name,
 		Description: "",
 		Creator:     creator,
 		Members:     map[string]bool{creator: true},
 		Invitations: make(map[string]bool),
+		Policy:      common.PolicyOpen,
 		CreatedAt:   time.Now(),
+		logger:      common.GetLogger("room").With(common.F("room_id", id), common.F("room", name)),
+	}
+}
+
+// newRoomFromRecord restores a persisted room
+func newRoomFromRecord(record RoomRecord) *Room {
+	room := &Room{
+		ID:           record.ID,
+		Name:         record.Name,
+		Description:  record.Description,
+		Creator:      record.Creator,
+		Members:      make(map[string]bool, len(record.Members)),
+		Invitations:  make(map[string]bool),
+		Policy:       record.Policy,
+		PasswordHash: record.PasswordHash,
+		MaxMembers:   record.MaxMembers,
+		CreatedAt:    record.CreatedAt,
+		logger:       common.GetLogger("room").With(common.F("room_id", record.ID), common.F("room", record.Name)),
+	}
+	for _, member := range record.Members {
+		room.Members[member] = true
+	}
+	return room
+}
+
+// Record returns the persisted form of the room
+func (r *Room) Record() RoomRecord {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	members := make([]string, 0, len(r.Members))
+	for member := range r.Members {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	return RoomRecord{
+		ID:           r.ID,
+		Name:         r.Name,
+		Description:  r.Description,
+		Creator:      r.Creator,
+		Members:      members,
+		Policy:       r.Policy,
+		PasswordHash: r.PasswordHash,
+		MaxMembers:   r.MaxMembers,
+		CreatedAt:    r.CreatedAt,
 	}
 }
 
@@ -38,6 +94,7 @@ func (r *Room) AddMember(nickname string) {
 	defer r.mutex.Unlock()
 	r.Members[nickname] = true
 	delete(r.Invitations, nickname)
+	r.logger.Debug("Member added: %s", nickname)
 }
 
 // RemoveMember removes a member from the room
@@ -45,6 +102,22 @@ func (r *Room) RemoveMember(nickname string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	delete(r.Members, nickname)
+	r.logger.Debug("Member removed: %s", nickname)
+}
+
+// TimeLeft returns how long an ephemeral room with a TTL still exists
+func (r *Room) TimeLeft() time.Duration {
+	if r.ExpiresAt.IsZero() {
+		return 0
+	}
+	return time.Until(r.ExpiresAt)
+}
+
+// IsEmpty checks if the room has no members
+func (r *Room) IsEmpty() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.Members) == 0
 }
 
 // IsMember checks if a user is a member of the room
@@ -96,24 +169,93 @@ func (r *Room) GetDescription() string {
 
 // RoomManager manages all rooms
 type RoomManager struct {
-	rooms map[string]*Room
-	mutex sync.RWMutex
+	rooms  map[string]*Room
+	store  Store // Optional, keeps rooms and memberships across restarts
+	logger *common.Logger
+	mutex  sync.RWMutex
 }
 
 // NewRoomManager creates a new room manager
 func NewRoomManager() *RoomManager {
 	return &RoomManager{
-		rooms: make(map[string]*Room),
+		rooms:  make(map[string]*Room),
+		logger: common.GetLogger("room"),
+	}
+}
+
+// SetStore loads the rooms saved in store and persists every later change
+func (rm *RoomManager) SetStore(store Store) error {
+	records, err := store.LoadRooms()
+	if err != nil {
+		return err
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.store = store
+	for _, record := range records {
+		rm.rooms[record.ID] = newRoomFromRecord(record)
+	}
+	rm.logger.Info("Loaded %d room(s) from store", len(records))
+	return nil
+}
+
+// IsPersistent reports whether rooms survive restarts and disconnects
+func (rm *RoomManager) IsPersistent() bool {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.store != nil
+}
+
+// Save writes all rooms to the store, if one is configured
+func (rm *RoomManager) Save() {
+	rm.mutex.RLock()
+	store := rm.store
+	records := make([]RoomRecord, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		if room.Ephemeral {
+			continue
+		}
+		records = append(records, room.Record())
+	}
+	rm.mutex.RUnlock()
+
+	if store == nil {
+		return
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	if err := store.SaveRooms(records); err != nil {
+		rm.logger.Error("Failed to save rooms: %v", err)
 	}
 }
 
 // CreateRoom creates a new room
 func (rm *RoomManager) CreateRoom(name, creator string) *Room {
+	rm.mutex.Lock()
+	room := NewRoom(name, creator)
+	rm.rooms[room.ID] = room
+	room.logger.Info("Room created by %s", creator)
+	rm.mutex.Unlock()
+
+	rm.Save()
+	return room
+}
+
+// CreateEphemeralRoom creates a room that is deleted when its last member
+// leaves or, with a positive ttl, when the ttl passes
+func (rm *RoomManager) CreateEphemeralRoom(name, creator string, ttl time.Duration) *Room {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 
 	room := NewRoom(name, creator)
+	room.Ephemeral = true
+	if ttl > 0 {
+		room.ExpiresAt = room.CreatedAt.Add(ttl)
+	}
 	rm.rooms[room.ID] = room
+	room.logger.Info("Ephemeral room created by %s (ttl %v)", creator, ttl)
 	return room
 }
 
@@ -126,6 +268,31 @@ func (rm *RoomManager) GetRoom(roomID string) (*Room, bool) {
 	return room, exists
 }
 
+// Count returns the number of rooms
+func (rm *RoomManager) Count() int {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return len(rm.rooms)
+}
+
+// ListRooms returns all rooms sorted by name
+func (rm *RoomManager) ListRooms() []*Room {
+	rm.mutex.RLock()
+	rooms := make([]*Room, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		rooms = append(rooms, room)
+	}
+	rm.mutex.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Name != rooms[j].Name {
+			return rooms[i].Name < rooms[j].Name
+		}
+		return rooms[i].ID < rooms[j].ID
+	})
+	return rooms
+}
+
 // GetUserRooms returns all rooms a user is member of
 func (rm *RoomManager) GetUserRooms(nickname string) []*Room {
 	rm.mutex.RLock()
@@ -143,12 +310,18 @@ func (rm *RoomManager) GetUserRooms(nickname string) []*Room {
 // RemoveRoom removes a room
 func (rm *RoomManager) RemoveRoom(roomID string) {
 	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
+	if room, exists := rm.rooms[roomID]; exists {
+		room.logger.Info("Room removed")
+	}
 	delete(rm.rooms, roomID)
+	rm.mutex.Unlock()
+
+	rm.Save()
 }
 
-// BroadcastToRoom sends a message to all room members
-func (rm *RoomManager) BroadcastToRoom(server *Server, roomID string, msg *common.Message) {
+// BroadcastToRoom sends a message to all room members, stopping early when
+// ctx is cancelled
+func (rm *RoomManager) BroadcastToRoom(ctx context.Context, server *Server, roomID string, msg *common.Message) {
 	room, exists := rm.GetRoom(roomID)
 	if !exists {
 		return
@@ -156,11 +329,10 @@ func (rm *RoomManager) BroadcastToRoom(server *Server, roomID string, msg *commo
 
 	members := room.GetMembers()
 	for _, member := range members {
+		if ctx.Err() != nil {
+			return
+		}
 		if client, ok := server.GetClient(member); ok {
-			// Don't send to invisible users unless they're the sender
-			if client.GetStatus() == common.StatusInvisible && member != msg.Sender {
-				continue
-			}
 			client.SendMessage(msg)
 		}
 	}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tcp-chat/common"
+)
+
+// hashRoomPassword hashes a room password so it is not kept in plain text
+func hashRoomPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsFull checks if the room reached its member limit
+func (r *Room) IsFull() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.MaxMembers > 0 && len(r.Members) >= r.MaxMembers
+}
+
+// CanJoin checks the join policy and member limit for a user
+func (r *Room) CanJoin(nickname, password string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.Members[nickname] {
+		return nil
+	}
+	if r.MaxMembers > 0 && len(r.Members) >= r.MaxMembers {
+		return common.ChatErrorf(common.ErrValidation, "room '%s' is full (%d members)", r.Name, r.MaxMembers)
+	}
+
+	switch r.Policy {
+	case common.PolicyInvite:
+		if !r.Invitations[nickname] {
+			return common.ChatErrorf(common.ErrUnauthorized, "room '%s' is invite-only", r.Name)
+		}
+	case common.PolicyPassword:
+		// Invited users do not need the password
+		if r.Invitations[nickname] {
+			return nil
+		}
+		given := hashRoomPassword(password)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(r.PasswordHash)) != 1 {
+			return common.ChatErrorf(common.ErrUnauthorized, "wrong password for room '%s'", r.Name)
+		}
+	}
+	return nil
+}
+
+// ApplySettings changes the room settings from key=value pairs: policy,
+// password and max. Setting a password switches the policy to password.
+func (r *Room) ApplySettings(settings string) error {
+	pairs := strings.Fields(settings)
+	if len(pairs) == 0 {
+		return common.ChatErrorf(common.ErrValidation, "no room settings given")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	policy, passwordHash, maxMembers := r.Policy, r.PasswordHash, r.MaxMembers
+	policySet := false
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return common.ChatErrorf(common.ErrValidation, "invalid room setting %q, use key=value", pair)
+		}
+
+		switch strings.ToLower(key) {
+		case "policy":
+			switch value {
+			case common.PolicyOpen, common.PolicyInvite, common.PolicyPassword:
+				policy = value
+				policySet = true
+			default:
+				return common.ChatErrorf(common.ErrValidation, "unknown join policy: %s (use open, invite or password)", value)
+			}
+		case "password":
+			if value == "" {
+				passwordHash = ""
+				continue
+			}
+			passwordHash = hashRoomPassword(value)
+			if !policySet {
+				policy = common.PolicyPassword
+			}
+		case "max":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 || limit > common.MaxRoomMembers {
+				return common.ChatErrorf(common.ErrValidation, "max must be a number between 0 (no limit) and %d", common.MaxRoomMembers)
+			}
+			maxMembers = limit
+		default:
+			return common.ChatErrorf(common.ErrValidation, "unknown room setting: %s (use policy, password or max)", key)
+		}
+	}
+
+	if policy == common.PolicyPassword && passwordHash == "" {
+		return common.ChatErrorf(common.ErrValidation, "the password policy requires password=<secret>")
+	}
+
+	r.Policy, r.PasswordHash, r.MaxMembers = policy, passwordHash, maxMembers
+	r.logger.Info("Settings changed: %s", r.describeSettings())
+	return nil
+}
+
+// SettingsSummary describes the join policy and member limit
+func (r *Room) SettingsSummary() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.describeSettings()
+}
+
+// describeSettings formats the settings; the caller holds r.mutex
+func (r *Room) describeSettings() string {
+	policy := r.Policy
+	if policy == "" {
+		policy = common.PolicyOpen
+	}
+	if r.MaxMembers > 0 {
+		return fmt.Sprintf("policy=%s, %d/%d members", policy, len(r.Members), r.MaxMembers)
+	}
+	return fmt.Sprintf("policy=%s, %d members", policy, len(r.Members))
+}
+
+// handleRoomSettings lets the creator change the join policy and member limit
+func (s *Server) handleRoomSettings(ctx context.Context, client *Client, msg *common.Message) {
+	room, exists := s.roomManager.GetRoom(msg.Room)
+	if !exists {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrNotFound, "Room not found"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	if room.Creator != client.Nickname {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, common.ChatErrorf(common.ErrUnauthorized, "Only the room creator can change room settings"))
+		client.SendMessage(errMsg)
+		return
+	}
+
+	if err := room.ApplySettings(msg.Content); err != nil {
+		errMsg := common.NewErrorMessageFromError("Server", client.Nickname, err)
+		client.SendMessage(errMsg)
+		return
+	}
+	s.roomManager.Save()
+
+	// Notify room members, the password itself is never echoed
+	notice := common.NewTextMessage("Server", "", common.T("%s changed the room settings: %s", client.Nickname, room.SettingsSummary()))
+	notice.Room = room.ID
+	s.roomManager.BroadcastToRoom(ctx, s, room.ID, notice)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// Stats collects server-wide counters
+type Stats struct {
+	startTime        time.Time
+	messagesRouted   atomic.Int64
+	bytesReceived    atomic.Int64
+	bytesSent        atomic.Int64
+	filesTransferred atomic.Int64
+	duplicates       atomic.Int64
+}
+
+// StatsSnapshot is a point-in-time view of the server statistics
+type StatsSnapshot struct {
+	Uptime           time.Duration
+	Users            int
+	Rooms            int
+	MessagesRouted   int64
+	BytesReceived    int64
+	BytesSent        int64
+	FilesTransferred int64
+	Duplicates       int64
+	TransferReserved int64
+	TransferBudget   int64
+	MemoryAlloc      uint64
+	MemorySys        uint64
+	Goroutines       int
+}
+
+// NewStats creates a collector counting from now
+func NewStats() *Stats {
+	return &Stats{startTime: time.Now()}
+}
+
+// RecordMessage counts a message handled by the server
+func (st *Stats) RecordMessage() {
+	st.messagesRouted.Add(1)
+}
+
+// RecordReceived counts bytes read from clients
+func (st *Stats) RecordReceived(n int) {
+	st.bytesReceived.Add(int64(n))
+}
+
+// RecordSent counts bytes written to clients
+func (st *Stats) RecordSent(n int) {
+	st.bytesSent.Add(int64(n))
+}
+
+// RecordFileTransfer counts a completed file transfer
+func (st *Stats) RecordFileTransfer() {
+	st.filesTransferred.Add(1)
+}
+
+// RecordDuplicate counts a retransmitted message that was dropped
+func (st *Stats) RecordDuplicate() {
+	st.duplicates.Add(1)
+}
+
+// Snapshot gathers the counters together with the server state
+func (s *Server) Snapshot() StatsSnapshot {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	reserved, budget := s.rateLimiter.TransferMemory()
+
+	return StatsSnapshot{
+		Uptime:           time.Since(s.stats.startTime),
+		Users:            s.ClientCount(),
+		Rooms:            s.roomManager.Count(),
+		MessagesRouted:   s.stats.messagesRouted.Load(),
+		BytesReceived:    s.stats.bytesReceived.Load(),
+		BytesSent:        s.stats.bytesSent.Load(),
+		FilesTransferred: s.stats.filesTransferred.Load(),
+		Duplicates:       s.stats.duplicates.Load(),
+		TransferReserved: reserved,
+		TransferBudget:   budget,
+		MemoryAlloc:      mem.Alloc,
+		MemorySys:        mem.Sys,
+		Goroutines:       runtime.NumGoroutine(),
+	}
+}
+
+// String formats the snapshot as translated multi-line text
+func (ss StatsSnapshot) String() string {
+	lines := []string{
+		common.T("Uptime: %v", ss.Uptime.Round(time.Second)),
+		common.T("Connected users: %d", ss.Users),
+		common.T("Rooms: %d", ss.Rooms),
+		common.T("Messages routed: %d", ss.MessagesRouted),
+		common.T("Duplicates dropped: %d", ss.Duplicates),
+		common.T("Bytes transferred: %s received, %s sent", common.FormatFileSize(ss.BytesReceived), common.FormatFileSize(ss.BytesSent)),
+		common.T("Files transferred: %d", ss.FilesTransferred),
+		common.T("Transfer memory: %s of %s reserved", common.FormatFileSize(ss.TransferReserved), common.FormatFileSize(ss.TransferBudget)),
+		common.T("Memory: %s in use, %s reserved", common.FormatFileSize(int64(ss.MemoryAlloc)), common.FormatFileSize(int64(ss.MemorySys))),
+		common.T("Goroutines: %d", ss.Goroutines),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleStatsRequest answers a /stats request
+func (s *Server) handleStatsRequest(client *Client) {
+	client.SendMessage(&common.Message{
+		Type:      common.TypeStats,
+		Sender:    "Server",
+		Recipient: client.Nickname,
+		Content:   s.Snapshot().String(),
+		Timestamp: time.Now(),
+	})
+}
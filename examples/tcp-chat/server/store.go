@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// RoomRecord is the persisted form of a room
+type RoomRecord struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	Creator      string    `json:"creator"`
+	Members      []string  `json:"members"`
+	Policy       string    `json:"policy,omitempty"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	MaxMembers   int       `json:"max_members,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Store persists server state between restarts
+type Store interface {
+	LoadRooms() ([]RoomRecord, error)
+	SaveRooms(rooms []RoomRecord) error
+}
+
+// FileStore keeps server state in a JSON file, encrypted when a cipher is set
+type FileStore struct {
+	filename string
+	cipher   *common.FileCipher
+	mutex    sync.Mutex
+}
+
+// fileState is the layout of the store file
+type fileState struct {
+	Rooms []RoomRecord `json:"rooms"`
+}
+
+// NewFileStore creates a store backed by filename; the file is created on
+// the first save
+func NewFileStore(filename string) *FileStore {
+	return &FileStore{filename: filename}
+}
+
+// NewEncryptedFileStore creates a store encrypting the file with cipher; a
+// plain file left from before is read and encrypted on the next save
+func NewEncryptedFileStore(filename string, cipher *common.FileCipher) *FileStore {
+	return &FileStore{filename: filename, cipher: cipher}
+}
+
+// LoadRooms reads the saved rooms, a missing file means no rooms
+func (fs *FileStore) LoadRooms() ([]RoomRecord, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, err := os.ReadFile(fs.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %v", err)
+	}
+	if common.IsEncrypted(data) {
+		if fs.cipher == nil {
+			return nil, fmt.Errorf("store %s is encrypted, a passphrase is required", fs.filename)
+		}
+		if data, err = fs.cipher.Open(data); err != nil {
+			return nil, fmt.Errorf("failed to decrypt store %s: %v", fs.filename, err)
+		}
+	}
+
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse store %s: %v", fs.filename, err)
+	}
+	return state.Rooms, nil
+}
+
+// SaveRooms replaces the saved rooms; the file is written atomically so a
+// crash never leaves a truncated store behind
+func (fs *FileStore) SaveRooms(rooms []RoomRecord) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, err := json.MarshalIndent(fileState{Rooms: rooms}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %v", err)
+	}
+	if fs.cipher != nil {
+		if data, err = fs.cipher.Seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt store: %v", err)
+		}
+	}
+
+	if dir := filepath.Dir(fs.filename); dir != "." {
+		if err := os.MkdirAll(dir, common.GetDirMode()); err != nil {
+			return fmt.Errorf("failed to create store directory: %v", err)
+		}
+	}
+
+	tmp := fs.filename + ".tmp"
+	if err := os.WriteFile(tmp, data, common.GetFileMode()); err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if err := os.Rename(tmp, fs.filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace store: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+func TestEncryptedFileStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+	rooms := []RoomRecord{{ID: "r1", Name: "general", Creator: "alice", Members: []string{"alice"}}}
+
+	// A plain store written before encryption was enabled is still read
+	if err := NewFileStore(filename).SaveRooms(rooms); err != nil {
+		t.Fatalf("SaveRooms: %v", err)
+	}
+	cipher, err := common.NewFileCipher("store secret")
+	if err != nil {
+		t.Fatalf("NewFileCipher: %v", err)
+	}
+	store := NewEncryptedFileStore(filename, cipher)
+	loaded, err := store.LoadRooms()
+	if err != nil || len(loaded) != 1 || loaded[0].Name != "general" {
+		t.Fatalf("LoadRooms of the plain store = %v, %v", loaded, err)
+	}
+
+	if err := store.SaveRooms(loaded); err != nil {
+		t.Fatalf("SaveRooms: %v", err)
+	}
+	data, _ := os.ReadFile(filename)
+	if !common.IsEncrypted(data) || strings.Contains(string(data), "general") {
+		t.Fatal("store file is not encrypted")
+	}
+
+	restarted, _ := common.NewFileCipher("store secret")
+	if loaded, err = NewEncryptedFileStore(filename, restarted).LoadRooms(); err != nil || len(loaded) != 1 {
+		t.Errorf("LoadRooms after restart = %v, %v", loaded, err)
+	}
+	if _, err := NewFileStore(filename).LoadRooms(); err == nil {
+		t.Error("expected an error loading an encrypted store without a passphrase")
+	}
+	wrong, _ := common.NewFileCipher("guess")
+	if _, err := NewEncryptedFileStore(filename, wrong).LoadRooms(); err == nil {
+		t.Error("expected an error loading with the wrong passphrase")
+	}
+}
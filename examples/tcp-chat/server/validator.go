@@ -1,29 +1,30 @@
 package main
 
 import (
-	"errors"
-	"fmt"
+	"mime"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"tcp-chat/common"
+	"time"
 )
 
 var (
 	nicknameRegex = regexp.MustCompile(common.NicknamePattern)
 	roomNameRegex = regexp.MustCompile(common.RoomNamePattern)
+	metaKeyRegex  = regexp.MustCompile(common.MetadataKeyPattern)
 )
 
 // ValidateNickname validates a nickname according to the rules
 func ValidateNickname(nickname string) error {
 	if len(nickname) < common.MinNicknameLength {
-		return fmt.Errorf("nickname must be at least %d characters long", common.MinNicknameLength)
+		return common.ChatErrorf(common.ErrValidation, "nickname must be at least %d characters long", common.MinNicknameLength)
 	}
 	if len(nickname) > common.MaxNicknameLength {
-		return fmt.Errorf("nickname cannot exceed %d characters", common.MaxNicknameLength)
+		return common.ChatErrorf(common.ErrValidation, "nickname cannot exceed %d characters", common.MaxNicknameLength)
 	}
 	if !nicknameRegex.MatchString(nickname) {
-		return errors.New("nickname can only contain letters, numbers, underscores, and hyphens")
+		return common.ChatErrorf(common.ErrValidation, "nickname can only contain letters, numbers, underscores, and hyphens")
 	}
 	return nil
 }
@@ -34,13 +35,24 @@ func ValidateRoomName(roomName string) error {
 	roomName = strings.TrimSpace(roomName)
 
 	if len(roomName) < common.MinRoomNameLength {
-		return fmt.Errorf("room name must be at least %d characters long", common.MinRoomNameLength)
+		return common.ChatErrorf(common.ErrValidation, "room name must be at least %d characters long", common.MinRoomNameLength)
 	}
 	if len(roomName) > common.MaxRoomNameLength {
-		return fmt.Errorf("room name cannot exceed %d characters", common.MaxRoomNameLength)
+		return common.ChatErrorf(common.ErrValidation, "room name cannot exceed %d characters", common.MaxRoomNameLength)
 	}
 	if !roomNameRegex.MatchString(roomName) {
-		return errors.New("room name can only contain letters, numbers, underscores, hyphens, and spaces")
+		return common.ChatErrorf(common.ErrValidation, "room name can only contain letters, numbers, underscores, hyphens, and spaces")
+	}
+	return nil
+}
+
+// ValidateRoomTTL validates the lifetime of an ephemeral room, zero means no limit
+func ValidateRoomTTL(ttl time.Duration) error {
+	if ttl == 0 {
+		return nil
+	}
+	if ttl < common.MinRoomTTL || ttl > common.MaxRoomTTL {
+		return common.ChatErrorf(common.ErrValidation, "room TTL must be between %v and %v", common.MinRoomTTL, common.MaxRoomTTL)
 	}
 	return nil
 }
@@ -48,44 +60,116 @@ func ValidateRoomName(roomName string) error {
 // ValidateMessage validates a message content
 func ValidateMessage(content string) error {
 	if len(content) == 0 {
-		return errors.New("message cannot be empty")
+		return common.ChatErrorf(common.ErrValidation, "message cannot be empty")
 	}
 	if len(content) > common.MaxMessageSize {
-		return fmt.Errorf("message cannot exceed %d characters", common.MaxMessageSize)
+		return common.ChatErrorf(common.ErrValidation, "message cannot exceed %d characters", common.MaxMessageSize)
 	}
 	return nil
 }
 
+// ValidateFormat validates the format of a text message
+func ValidateFormat(format string) error {
+	switch format {
+	case common.FormatPlain, common.FormatMarkdown:
+		return nil
+	}
+	return common.ChatErrorf(common.ErrValidation, "unknown message format: %s (use markdown or leave empty)", format)
+}
+
 // ValidateFileName validates a file name for security
 func ValidateFileName(filename string) error {
 	if len(filename) == 0 {
-		return errors.New("filename cannot be empty")
+		return common.ChatErrorf(common.ErrValidation, "filename cannot be empty")
 	}
 	if len(filename) > common.MaxFileNameLength {
-		return fmt.Errorf("filename cannot exceed %d characters", common.MaxFileNameLength)
+		return common.ChatErrorf(common.ErrValidation, "filename cannot exceed %d characters", common.MaxFileNameLength)
 	}
 
 	// Check for path traversal attempts
 	cleanPath := filepath.Clean(filename)
 	if strings.Contains(cleanPath, "..") || strings.ContainsAny(cleanPath, `/\`) {
-		return errors.New("filename cannot contain path separators or parent directory references")
+		return common.ChatErrorf(common.ErrValidation, "filename cannot contain path separators or parent directory references")
 	}
 
 	// Check for hidden files
 	if strings.HasPrefix(filename, ".") {
-		return errors.New("hidden files are not allowed")
+		return common.ChatErrorf(common.ErrValidation, "hidden files are not allowed")
 	}
 
 	return nil
 }
 
+// ValidateContentType validates the optional MIME type of a file
+func ValidateContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil || !strings.Contains(contentType, "/") {
+		return common.ChatErrorf(common.ErrValidation, "invalid content type: %s", contentType)
+	}
+	return nil
+}
+
+// ValidateMetadata validates the metadata of a file, keys are lower-case
+// identifiers and values single lines
+func ValidateMetadata(metadata map[string]string) error {
+	for key, value := range metadata {
+		if !metaKeyRegex.MatchString(key) {
+			return common.ChatErrorf(common.ErrValidation, "invalid metadata key: %q", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return common.ChatErrorf(common.ErrValidation, "metadata %s must be a single line", key)
+		}
+	}
+	return nil
+}
+
 // ValidateFileSize validates file size is within limits
 func ValidateFileSize(size int64) error {
 	if size <= 0 {
-		return errors.New("file size must be positive")
+		return common.ChatErrorf(common.ErrValidation, "file size must be positive")
 	}
 	if size > common.MaxFileSize {
-		return fmt.Errorf("file size cannot exceed %d bytes", common.MaxFileSize)
+		return common.ChatErrorf(common.ErrValidation, "file size cannot exceed %d bytes", common.MaxFileSize)
+	}
+	return nil
+}
+
+// ValidateTotalChunks checks that a file is split into exactly the number
+// of chunks its size requires
+func ValidateTotalChunks(size int64, totalChunks int) error {
+	expected := (size + common.FileChunkSize - 1) / common.FileChunkSize
+	if int64(totalChunks) != expected {
+		return common.ChatErrorf(common.ErrValidation, "a file of %d bytes must be sent in %d chunks, not %d", size, expected, totalChunks)
+	}
+	return nil
+}
+
+// ValidateMessageID validates an optional client-generated message ID
+func ValidateMessageID(id string) error {
+	if len(id) > common.MaxMessageIDLength {
+		return common.ChatErrorf(common.ErrValidation, "message ID cannot exceed %d characters", common.MaxMessageIDLength)
+	}
+	return nil
+}
+
+// ValidateStatus validates a status a user sets for themselves
+func ValidateStatus(status common.UserStatus) error {
+	switch status {
+	case common.StatusActive, common.StatusBusy, common.StatusInvisible, common.StatusAway:
+		return nil
+	}
+	return common.ChatErrorf(common.ErrValidation, "unknown status: %s (use ACTIVE, BUSY, AWAY or INVISIBLE)", status)
+}
+
+// ValidateAwayMessage validates the reason given when going away
+func ValidateAwayMessage(message string) error {
+	if len(message) > common.MaxAwayLength {
+		return common.ChatErrorf(common.ErrValidation, "away message cannot exceed %d characters", common.MaxAwayLength)
+	}
+	if strings.ContainsAny(message, "\r\n") {
+		return common.ChatErrorf(common.ErrValidation, "away message must be a single line")
 	}
 	return nil
 }
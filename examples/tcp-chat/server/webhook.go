@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tcp-chat/common"
+	"tcp-chat/common/retry"
+)
+
+// Webhook events
+const (
+	EventUserJoined  = "user.joined"
+	EventUserLeft    = "user.left"
+	EventRoomCreated = "room.created"
+	EventKeyword     = "message.keyword"
+)
+
+// webhookEvents lists the events a webhook may subscribe to
+var webhookEvents = []string{EventUserJoined, EventUserLeft, EventRoomCreated, EventKeyword}
+
+// Webhook is an HTTP endpoint notified about server events
+type Webhook struct {
+	URL      string   `json:"url"`
+	Events   []string `json:"events,omitempty"`   // Subscribed events, all when empty
+	Keywords []string `json:"keywords,omitempty"` // Words triggering message.keyword, case-insensitive
+	Secret   string   `json:"secret,omitempty"`   // Signs payloads with HMAC-SHA256 when set
+}
+
+// Wants reports whether the webhook subscribed to event
+func (w *Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// matchKeyword returns the first keyword contained in content
+func (w *Webhook) matchKeyword(content string) (string, bool) {
+	content = strings.ToLower(content)
+	for _, keyword := range w.Keywords {
+		if strings.Contains(content, strings.ToLower(keyword)) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// Sign returns the signature header value of body
+func (w *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Validate checks the URL and the subscribed events
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", w.URL)
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return fmt.Errorf("webhook %s: unknown event %q", w.URL, event)
+		}
+	}
+	if slices.Contains(w.Events, EventKeyword) && len(w.Keywords) == 0 {
+		return fmt.Errorf("webhook %s: %s needs keywords", w.URL, EventKeyword)
+	}
+	return nil
+}
+
+// LoadWebhooks reads a JSON array of webhooks from filename
+func LoadWebhooks(filename string) ([]Webhook, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %v", err)
+	}
+	var hooks []Webhook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks: %v", err)
+	}
+	for i := range hooks {
+		if err := hooks[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return hooks, nil
+}
+
+// WebhookEvent is the JSON payload posted to webhooks
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user,omitempty"`
+	Room      string    `json:"room,omitempty"`
+	RoomName  string    `json:"room_name,omitempty"`
+	Keyword   string    `json:"keyword,omitempty"`
+	Content   string    `json:"content,omitempty"`
+}
+
+// NewWebhookEvent creates an event of the given type
+func NewWebhookEvent(event string) *WebhookEvent {
+	return &WebhookEvent{
+		ID:        common.NewMessageID(),
+		Event:     event,
+		Timestamp: time.Now(),
+	}
+}
+
+// webhookDelivery is an event queued for one webhook
+type webhookDelivery struct {
+	hook  *Webhook
+	event *WebhookEvent
+}
+
+// WebhookDispatcher posts server events to the configured webhooks in the
+// background, retrying failed deliveries with exponential backoff
+type WebhookDispatcher struct {
+	hooks      []Webhook
+	queue      chan webhookDelivery
+	client     *http.Client
+	retryDelay time.Duration
+	logger     *common.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewWebhookDispatcher creates a dispatcher without webhooks
+func NewWebhookDispatcher() *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		queue:      make(chan webhookDelivery, common.WebhookQueueSize),
+		client:     &http.Client{Timeout: common.WebhookTimeout},
+		retryDelay: common.WebhookRetryDelay,
+		logger:     common.GetLogger("webhook"),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetWebhooks sets the webhooks to notify; call it before Start
+func (wd *WebhookDispatcher) SetWebhooks(hooks []Webhook) {
+	wd.hooks = hooks
+}
+
+// Start starts the delivery workers
+func (wd *WebhookDispatcher) Start() {
+	for i := 0; i < common.WebhookWorkers; i++ {
+		wd.wg.Add(1)
+		go wd.run()
+	}
+}
+
+// Stop aborts running deliveries and waits for the workers to exit; queued
+// events are dropped
+func (wd *WebhookDispatcher) Stop() {
+	wd.cancel()
+	wd.wg.Wait()
+	if dropped := len(wd.queue); dropped > 0 {
+		wd.logger.Warn("Dropped %d undelivered webhook events", dropped)
+	}
+}
+
+// Notify queues event for every webhook subscribed to it
+func (wd *WebhookDispatcher) Notify(event *WebhookEvent) {
+	for i := range wd.hooks {
+		if wd.hooks[i].Wants(event.Event) {
+			wd.enqueue(&wd.hooks[i], event)
+		}
+	}
+}
+
+// NotifyMessage queues a keyword event for every webhook with a keyword
+// contained in the message
+func (wd *WebhookDispatcher) NotifyMessage(msg *common.Message, roomName string) {
+	for i := range wd.hooks {
+		hook := &wd.hooks[i]
+		if !hook.Wants(EventKeyword) {
+			continue
+		}
+		keyword, ok := hook.matchKeyword(msg.Content)
+		if !ok {
+			continue
+		}
+		event := NewWebhookEvent(EventKeyword)
+		event.User = msg.Sender
+		event.Room = msg.Room
+		event.RoomName = roomName
+		event.Keyword = keyword
+		event.Content = msg.Content
+		wd.enqueue(hook, event)
+	}
+}
+
+// enqueue queues a delivery, dropping it when the queue is full so slow
+// webhooks never hold up the chat
+func (wd *WebhookDispatcher) enqueue(hook *Webhook, event *WebhookEvent) {
+	select {
+	case wd.queue <- webhookDelivery{hook: hook, event: event}:
+	default:
+		wd.logger.With(common.F("url", hook.URL)).Warn("Webhook queue full, dropping %s event", event.Event)
+	}
+}
+
+// run delivers queued events until the dispatcher stops
+func (wd *WebhookDispatcher) run() {
+	defer wd.wg.Done()
+	for {
+		select {
+		case d := <-wd.queue:
+			wd.deliver(d)
+		case <-wd.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts an event, retrying temporary failures
+func (wd *WebhookDispatcher) deliver(d webhookDelivery) {
+	logger := wd.logger.With(common.F("url", d.hook.URL), common.F("event", d.event.Event))
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		logger.Error("Failed to encode webhook event: %v", err)
+		return
+	}
+
+	attempts, retryable := 0, false
+	policy := retry.Policy{
+		MaxAttempts:  common.WebhookAttempts,
+		InitialDelay: wd.retryDelay,
+		RetryIf:      func(error) bool { return retryable },
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			logger.Debug("Webhook attempt %d failed, retrying in %v: %v", attempt, delay, err)
+		},
+	}
+	err = retry.Do(wd.ctx, policy, func(context.Context) error {
+		attempts++
+		var err error
+		retryable, err = wd.post(d.hook, d.event, body)
+		return err
+	})
+	switch {
+	case err == nil:
+		logger.Debug("Webhook delivered")
+	case wd.ctx.Err() == nil:
+		logger.Warn("Webhook delivery failed after %d attempts: %v", attempts, err)
+	}
+}
+
+// post sends one delivery attempt and reports whether a failure is worth retrying
+func (wd *WebhookDispatcher) post(hook *Webhook, event *WebhookEvent, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(wd.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chat-Event", event.Event)
+	req.Header.Set("X-Chat-Delivery", event.ID)
+	if hook.Secret != "" {
+		req.Header.Set("X-Chat-Signature", hook.Sign(body))
+	}
+
+	resp, err := wd.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		// Other client errors will not go away by sending the same request again
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// notifyRoomCreated sends the room.created event
+func (s *Server) notifyRoomCreated(room *Room) {
+	event := NewWebhookEvent(EventRoomCreated)
+	event.User = room.Creator
+	event.Room = room.ID
+	event.RoomName = room.Name
+	s.webhooks.Notify(event)
+}
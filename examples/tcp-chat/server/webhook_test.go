@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// webhookReceiver records the events posted to a test endpoint
+type webhookReceiver struct {
+	server   *httptest.Server
+	failures atomic.Int32 // Requests still answered with an error
+	events   chan *WebhookEvent
+}
+
+// newWebhookReceiver starts an endpoint checking signatures made with secret,
+// if one is given
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	t.Helper()
+	wr := &webhookReceiver{events: make(chan *WebhookEvent, 16)}
+	wr.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wr.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		hook := Webhook{Secret: secret}
+		if got := r.Header.Get("X-Chat-Signature"); secret != "" && got != hook.Sign(body) {
+			t.Errorf("signature %q, want %q", got, hook.Sign(body))
+		}
+		var event WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		if got := r.Header.Get("X-Chat-Event"); got != event.Event {
+			t.Errorf("X-Chat-Event %q, want %q", got, event.Event)
+		}
+		wr.events <- &event
+	}))
+	t.Cleanup(wr.server.Close)
+	return wr
+}
+
+// expect waits for the next event
+func (wr *webhookReceiver) expect(t *testing.T) *WebhookEvent {
+	t.Helper()
+	select {
+	case event := <-wr.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook event received")
+		return nil
+	}
+}
+
+// startWebhooks configures and starts the webhooks of s
+func startWebhooks(t *testing.T, s *Server, hooks ...Webhook) {
+	t.Helper()
+	s.webhooks.retryDelay = 10 * time.Millisecond
+	s.webhooks.SetWebhooks(hooks)
+	s.webhooks.Start()
+	t.Cleanup(s.webhooks.Stop)
+}
+
+func TestWebhookEventsAreSignedAndRetried(t *testing.T) {
+	s := newTestServer(t)
+	receiver := newWebhookReceiver(t, "key")
+	receiver.failures.Store(2)
+	startWebhooks(t, s, Webhook{URL: receiver.server.URL, Events: []string{EventUserJoined}, Secret: "key"})
+
+	alice := newTestClient(t, s, "alice")
+	if event := receiver.expect(t); event.Event != EventUserJoined || event.User != "alice" {
+		t.Errorf("got %+v, want alice joining", event)
+	}
+
+	// Events the webhook did not subscribe to are not sent
+	s.UnregisterClient(context.Background(), alice)
+	select {
+	case event := <-receiver.events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookKeywords(t *testing.T) {
+	s := newTestServer(t)
+	receiver := newWebhookReceiver(t, "")
+	startWebhooks(t, s, Webhook{URL: receiver.server.URL, Events: []string{EventKeyword, EventRoomCreated}, Keywords: []string{"urgent"}})
+
+	alice := newTestClient(t, s, "alice")
+	for _, content := range []string{"nothing to see", "this is URGENT"} {
+		if err := s.HandleMessage(context.Background(), alice, common.NewBroadcastMessage("alice", content)); err != nil {
+			t.Fatalf("broadcast: %v", err)
+		}
+	}
+	event := receiver.expect(t)
+	if event.Event != EventKeyword || event.Keyword != "urgent" || event.Content != "this is URGENT" {
+		t.Errorf("got %+v, want the urgent message", event)
+	}
+
+	msg := &common.Message{Type: common.TypeRoom, Action: common.RoomCreate, Content: "ops"}
+	if err := s.HandleMessage(context.Background(), alice, msg); err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	if event := receiver.expect(t); event.Event != EventRoomCreated || event.RoomName != "ops" || event.User != "alice" {
+		t.Errorf("got %+v, want room ops created by alice", event)
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	tests := []struct {
+		hook  Webhook
+		valid bool
+	}{
+		{Webhook{URL: "https://example.com/hook"}, true},
+		{Webhook{URL: "ftp://example.com"}, false},
+		{Webhook{URL: "https://example.com", Events: []string{"user.renamed"}}, false},
+		{Webhook{URL: "https://example.com", Events: []string{EventKeyword}}, false},
+		{Webhook{URL: "https://example.com", Events: []string{EventKeyword}, Keywords: []string{"help"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.hook.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.hook, err, tt.valid)
+		}
+	}
+}
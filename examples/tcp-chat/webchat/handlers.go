@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tcp-chat/common"
+)
+
+const (
+	defaultPollTimeout = 25 * time.Second // Below the idle timeouts of common proxies
+	maxPollTimeout     = time.Minute
+	heartbeatInterval  = 15 * time.Second            // Comment lines keeping an idle stream open
+	maxBody            = 2 * common.MaxContentLength // Room for JSON escaping
+)
+
+// PostedMessage is the body of POST /messages
+type PostedMessage struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
+}
+
+func newRouter(hub *Hub) *gin.Engine {
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("hub", hub)
+	})
+
+	router.GET("/", index)
+	router.POST("/messages", postMessage)
+	router.GET("/messages", pollMessages)
+	router.GET("/events", streamEvents)
+	return router
+}
+
+func getHub(c *gin.Context) *Hub {
+	hub, _ := c.Get("hub")
+	return hub.(*Hub)
+}
+
+// writeError answers with the error in the shape of the HTTP API of the server
+func writeError(c *gin.Context, err error) {
+	var chatErr *common.ChatError
+	if !errors.As(err, &chatErr) {
+		chatErr = common.NewChatError(common.ErrInternal, err.Error())
+	}
+	status := http.StatusInternalServerError
+	if chatErr.Type == common.ErrValidation {
+		status = http.StatusBadRequest
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": chatErr})
+}
+
+// postMessage broadcasts a text message to all the clients, however they listen
+func postMessage(c *gin.Context) {
+	var posted PostedMessage
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+	if err := c.ShouldBindJSON(&posted); err != nil {
+		writeError(c, common.ChatErrorf(common.ErrValidation, "invalid request body: %v", err))
+		return
+	}
+	posted.Sender = strings.TrimSpace(posted.Sender)
+	if posted.Sender == "" || strings.TrimSpace(posted.Content) == "" {
+		writeError(c, common.NewChatError(common.ErrValidation, "sender and content are required"))
+		return
+	}
+	msg := common.NewBroadcastMessage(posted.Sender, posted.Content)
+	if err := msg.Validate(); err != nil {
+		writeError(c, err)
+		return
+	}
+	getHub(c).Post(msg)
+	c.JSON(http.StatusCreated, msg)
+}
+
+// pollMessages answers with the messages posted after ?after=, the ID of the
+// last message the client got, waiting up to ?timeout= seconds for one when
+// there are none yet. The client polls again right away, so a message waits
+// for it at most for a round trip, but every batch costs a request.
+func pollMessages(c *gin.Context) {
+	after, err := queryNumber(c, "after", 0)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	seconds, err := queryNumber(c, "timeout", uint64(defaultPollTimeout/time.Second))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	timeout := min(time.Duration(seconds)*time.Second, maxPollTimeout)
+
+	hub := getHub(c)
+	messages, _, posted := hub.Since(after)
+	if len(messages) == 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-posted:
+			messages, _, _ = hub.Since(after)
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// streamEvents keeps the response open and writes every message as a
+// Server-Sent Event with the message ID as the event ID. A browser's
+// EventSource reconnects on its own, sending the last ID it got in the
+// Last-Event-ID header, so nothing posted in the meantime is missed.
+func streamEvents(c *gin.Context) {
+	after, err := queryNumber(c, "after", 0)
+	if id := c.GetHeader("Last-Event-ID"); id != "" && err == nil {
+		after, err = strconv.ParseUint(id, 10, 64)
+	}
+	if err != nil {
+		writeError(c, common.ChatErrorf(common.ErrValidation, "invalid last event ID: %v", err))
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	hub := getHub(c)
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		messages, last, posted := hub.Since(after)
+		for _, msg := range messages {
+			if err := writeEvent(c.Writer, msg); err != nil {
+				return
+			}
+		}
+		after = last
+		c.Writer.Flush()
+
+		select {
+		case <-posted:
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a message as an event of the default type, so it reaches
+// the onmessage handler of an EventSource. Encoded JSON has no line breaks,
+// so the data fits on a single line.
+func writeEvent(w io.Writer, msg *common.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", msg.ID, data)
+	return err
+}
+
+func queryNumber(c *gin.Context, name string, defaultValue uint64) (uint64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, common.ChatErrorf(common.ErrValidation, "invalid %s: %s", name, value)
+	}
+	return number, nil
+}
+
+// index serves a page listening with Server-Sent Events, or with long
+// polling when opened as /?poll
+func index(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(indexPage))
+}
+
+const indexPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Web chat</title></head>
+<body>
+<ul id="messages"></ul>
+<form id="form">
+  <input id="sender" placeholder="Nickname" required>
+  <input id="content" placeholder="Message" required autofocus>
+  <button>Send</button>
+</form>
+<script>
+const list = document.getElementById("messages");
+const show = msg => {
+  const item = document.createElement("li");
+  item.textContent = msg.sender + ": " + msg.content;
+  list.appendChild(item);
+};
+
+if (location.search.includes("poll")) {
+  (async () => {
+    let after = 0;
+    for (;;) {
+      try {
+        const response = await fetch("/messages?after=" + after);
+        for (const msg of await response.json()) {
+          show(msg);
+          after = msg.id;
+        }
+      } catch (e) {
+        await new Promise(resolve => setTimeout(resolve, 1000));
+      }
+    }
+  })();
+} else {
+  new EventSource("/events").onmessage = event => show(JSON.parse(event.data));
+}
+
+document.getElementById("form").onsubmit = event => {
+  event.preventDefault();
+  const content = document.getElementById("content");
+  fetch("/messages", {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({sender: document.getElementById("sender").value, content: content.value}),
+  });
+  content.value = "";
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tcp-chat/common"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(newRouter(NewHub(10)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func post(t *testing.T, server *httptest.Server, body string) *http.Response {
+	t.Helper()
+	response, err := http.Post(server.URL+"/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func poll(t *testing.T, server *httptest.Server, query string) []common.Message {
+	t.Helper()
+	response, err := http.Get(server.URL + "/messages?" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var messages []common.Message
+	if err := json.NewDecoder(response.Body).Decode(&messages); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Poll answered %d, %v", response.StatusCode, err)
+	}
+	return messages
+}
+
+func TestPostMessageValidation(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"sender":"anna","content":"hello"}`, http.StatusCreated},
+		{"malformed", `{"sender":`, http.StatusBadRequest},
+		{"no sender", `{"sender":" ","content":"hello"}`, http.StatusBadRequest},
+		{"too long", `{"sender":"` + strings.Repeat("a", common.MaxFieldLength+1) + `","content":"hello"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if response := post(t, server, test.body); response.StatusCode != test.status {
+				t.Errorf("Status %d, want %d", response.StatusCode, test.status)
+			}
+		})
+	}
+}
+
+func TestLongPolling(t *testing.T) {
+	server := newTestServer(t)
+	post(t, server, `{"sender":"anna","content":"first"}`)
+	messages := poll(t, server, "after=0")
+	if len(messages) != 1 || messages[0].Content != "first" || messages[0].ID != "1" || messages[0].Type != common.TypeText {
+		t.Fatalf("Polled %+v", messages)
+	}
+	if messages := poll(t, server, "after=1&timeout=0"); len(messages) != 0 {
+		t.Errorf("Expected nothing new, polled %+v", messages)
+	}
+
+	// A poll with nothing to answer waits for the next message
+	polled := make(chan []common.Message)
+	go func() { polled <- poll(t, server, "after=1") }()
+	time.Sleep(50 * time.Millisecond)
+	post(t, server, `{"sender":"jan","content":"second"}`)
+	select {
+	case messages := <-polled:
+		if len(messages) != 1 || messages[0].Content != "second" {
+			t.Errorf("Polled %+v", messages)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The waiting poll didn't answer")
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	server := newTestServer(t)
+	post(t, server, `{"sender":"anna","content":"missed"}`)
+	post(t, server, `{"sender":"anna","content":"before"}`)
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+	request.Header.Set("Last-Event-ID", "1") // Reconnecting after the first message
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Content-Type %q", contentType)
+	}
+
+	lines := bufio.NewReader(response.Body)
+	expect := func(id, content string) {
+		t.Helper()
+		var event []string
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Stream ended: %v", err)
+			}
+			if line == "\n" {
+				break
+			}
+			event = append(event, strings.TrimSuffix(line, "\n"))
+		}
+		var msg common.Message
+		if len(event) != 2 || event[0] != "id: "+id || json.Unmarshal([]byte(strings.TrimPrefix(event[1], "data: ")), &msg) != nil || msg.Content != content {
+			t.Fatalf("Received event %q; want %s with %q", event, id, content)
+		}
+	}
+	expect("2", "before")
+	post(t, server, `{"sender":"jan","content":"after"}`)
+	expect("3", "after")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	"tcp-chat/common"
+)
+
+// Hub keeps the recent messages, numbered in the order they were posted, and
+// wakes up the requests waiting for new ones. Long polling and Server-Sent
+// Events differ only in what a woken up request does: a poll answers and the
+// client asks again, a stream writes the messages and waits for more.
+//
+// It doesn't use the bus of the chat server: requests read the numbered
+// history rather than a buffer of their own, so a slow client catches up
+// instead of losing messages to a drop policy, and a wake-up costs one closed
+// channel however many requests wait.
+type Hub struct {
+	mutex   sync.Mutex
+	history []*common.Message // At most limit, the oldest first
+	last    uint64            // Number of the last message posted
+	limit   int
+	posted  chan struct{} // Closed and replaced when a message is posted
+}
+
+// NewHub creates a hub keeping the last limit messages for clients catching up
+func NewHub(limit int) *Hub {
+	return &Hub{limit: max(limit, 1), posted: make(chan struct{})}
+}
+
+// Post numbers a message, the number becomes its ID, and wakes up the waiting requests
+func (h *Hub) Post(msg *common.Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.last++
+	msg.ID = strconv.FormatUint(h.last, 10)
+	h.history = append(h.history, msg)
+	if len(h.history) > h.limit {
+		h.history = slices.Delete(h.history, 0, len(h.history)-h.limit)
+	}
+	close(h.posted)
+	h.posted = make(chan struct{})
+}
+
+// Since returns the messages posted after the one numbered after, all the
+// kept ones when it is unknown, the number of the last one and a channel
+// closed when the next message is posted. A client missing more than the
+// history misses the oldest messages.
+func (h *Hub) Since(after uint64) ([]*common.Message, uint64, <-chan struct{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	first := h.last - uint64(len(h.history)) + 1
+	start := 0
+	switch {
+	case after > h.last:
+		// Numbered by a previous run of the server
+	case after >= first:
+		start = int(after - first + 1)
+	}
+	messages := make([]*common.Message, len(h.history)-start)
+	copy(messages, h.history[start:])
+	return messages, h.last, h.posted
+}
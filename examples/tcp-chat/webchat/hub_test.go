@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"tcp-chat/common"
+)
+
+func contents(messages []*common.Message) []string {
+	var texts []string
+	for _, msg := range messages {
+		texts = append(texts, msg.ID+":"+msg.Content)
+	}
+	return texts
+}
+
+func TestHubSince(t *testing.T) {
+	hub := NewHub(3)
+	messages, last, posted := hub.Since(0)
+	if len(messages) != 0 || last != 0 {
+		t.Fatalf("Since(0) of an empty hub = %v, %d", contents(messages), last)
+	}
+	hub.Post(common.NewBroadcastMessage("anna", "a"))
+	select {
+	case <-posted:
+	default:
+		t.Fatal("Expected posting to close the channel returned before")
+	}
+	for _, content := range []string{"b", "c", "d"} {
+		hub.Post(common.NewBroadcastMessage("anna", content))
+	}
+
+	tests := []struct {
+		after    uint64
+		expected []string
+	}{
+		{0, []string{"2:b", "3:c", "4:d"}}, // The first message was dropped
+		{2, []string{"3:c", "4:d"}},
+		{4, nil},
+		{10, []string{"2:b", "3:c", "4:d"}}, // Numbered by a previous run
+	}
+	for _, test := range tests {
+		messages, last, _ := hub.Since(test.after)
+		if got := contents(messages); last != 4 || !slices.Equal(got, test.expected) {
+			t.Errorf("Since(%d) = %v, %d; want %v, 4", test.after, got, last, test.expected)
+		}
+	}
+}
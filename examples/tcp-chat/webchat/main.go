@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"tcp-chat/common"
+)
+
+// A chat over HTTP, to compare the ways a server can push messages to
+// browsers: long polling (GET /messages) and Server-Sent Events (GET /events).
+// Messages are posted with POST /messages and use the model of the TCP chat.
+func main() {
+	address := flag.String("addr", "localhost:8090", "HTTP address")
+	history := flag.Int("history", 100, "Messages kept for clients catching up")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           newRouter(NewHub(*history)),
+		ReadHeaderTimeout: common.RequestTimeout,
+		// Shutdown doesn't interrupt requests, waiting polls and streams end with ctx
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	failed := make(chan error, 1)
+	go func() {
+		log.Printf("Web chat on http://%s, long polling on http://%s/?poll", *address, *address)
+		failed <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-failed:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("Shutdown failed: " + err.Error())
+	}
+}
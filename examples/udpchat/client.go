@@ -0,0 +1,85 @@
+package udpchat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Client talks to the UDP chat server at address, Ctrl-C leaves the chat
+func Client(address string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := RunClient(ctx, address, os.Stdin, os.Stdout); err != nil {
+		log.Println("Chat client failed: " + err.Error())
+	}
+}
+
+// RunClient sends the lines of input to the server, the first one being the
+// nickname, and writes the received messages to output. There is no
+// connection to establish, nothing tells the server is not there until a
+// text sent to it is not acknowledged. It returns when ctx is done, input
+// ends or the server says bye or is lost.
+func RunClient(ctx context.Context, address string, input io.Reader, output io.Writer) error {
+	server, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+	connection, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return err
+	}
+	transport := newTransport(connection, retransmitTimeout)
+	defer transport.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	fmt.Fprint(output, "Nickname: ")
+
+	for {
+		select {
+		case text, ok := <-lines:
+			if !ok {
+				transport.Leave(server)
+				return nil
+			}
+			err := transport.Send(server, text)
+			if errors.Is(err, ErrMessageTooLarge) {
+				log.Println("Message too long")
+				continue
+			}
+			if err != nil {
+				log.Println("Error sending message: " + err.Error())
+			}
+		case delivery := <-transport.deliveries:
+			if delivery.err != nil {
+				// The server refuses a nickname by saying bye
+				fmt.Fprintln(output, "Disconnected")
+				return nil
+			}
+			fmt.Fprintln(output, delivery.text)
+		case <-ctx.Done():
+			// Without a bye the server would only notice when sending to the client fails
+			transport.Leave(server)
+			fmt.Fprintln(output, "Bye")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package udpchat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	maxMessageSize    = 1024 // Text in a single datagram, UDP doesn't split messages
+	headerSize        = 5    // Kind and sequence number
+	maxPacketSize     = headerSize + maxMessageSize
+	maxNicknameLength = 20
+	retransmitTimeout = 200 * time.Millisecond // Fixed, TCP adapts it to the measured round trip time
+	maxAttempts       = 10                     // Sends of a packet before the peer is considered lost
+	receiveWindow     = 64                     // Packets received ahead of a missing one that are kept
+)
+
+var (
+	ErrMessageTooLarge = errors.New("message too large")
+	ErrPeerLeft        = errors.New("peer left")
+	ErrPeerLost        = errors.New("peer lost")
+	errInvalidPacket   = errors.New("invalid packet")
+)
+
+type packetKind byte
+
+const (
+	dataPacket packetKind = iota + 1 // Carries a text, acknowledged
+	ackPacket                        // Acknowledges the packet with the same sequence number
+	byePacket                        // Ends a conversation, acknowledged and ordered like data
+)
+
+// packet is a datagram of the protocol: its kind, a sequence number as 4
+// bytes in big endian order and a text taking the rest of the datagram. UDP
+// keeps the boundaries of datagrams, so unlike over TCP no length prefix is
+// needed, but datagrams may be lost, duplicated or arrive out of order.
+type packet struct {
+	kind     packetKind
+	sequence uint32
+	payload  []byte
+}
+
+func (p packet) encode() []byte {
+	datagram := make([]byte, headerSize+len(p.payload))
+	datagram[0] = byte(p.kind)
+	binary.BigEndian.PutUint32(datagram[1:], p.sequence)
+	copy(datagram[headerSize:], p.payload)
+	return datagram
+}
+
+// decodePacket copies the payload, the datagram is usually a reused buffer
+func decodePacket(datagram []byte) (packet, error) {
+	if len(datagram) < headerSize {
+		return packet{}, fmt.Errorf("%w: %d bytes", errInvalidPacket, len(datagram))
+	}
+	if len(datagram) > maxPacketSize {
+		return packet{}, fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, len(datagram), maxPacketSize)
+	}
+	kind := packetKind(datagram[0])
+	if kind < dataPacket || kind > byePacket {
+		return packet{}, fmt.Errorf("%w: unknown kind %d", errInvalidPacket, kind)
+	}
+	return packet{
+		kind:     kind,
+		sequence: binary.BigEndian.Uint32(datagram[1:]),
+		payload:  bytes.Clone(datagram[headerSize:]),
+	}, nil
+}
@@ -0,0 +1,39 @@
+package udpchat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPacketEncoding(t *testing.T) {
+	sent := packet{kind: dataPacket, sequence: 1 << 30, payload: []byte("zażółć gęślą jaźń")}
+	datagram := sent.encode()
+	received, err := decodePacket(datagram)
+	if err != nil || received.kind != sent.kind || received.sequence != sent.sequence || !bytes.Equal(received.payload, sent.payload) {
+		t.Fatalf("decodePacket() = %+v, %v; want %+v", received, err, sent)
+	}
+	datagram[headerSize] = 'x'
+	if received.payload[0] == 'x' {
+		t.Error("Expected the payload to be copied from the datagram")
+	}
+}
+
+func TestInvalidPackets(t *testing.T) {
+	tests := []struct {
+		name     string
+		datagram []byte
+		err      error
+	}{
+		{"too short", []byte{byte(ackPacket), 0, 0}, errInvalidPacket},
+		{"unknown kind", packet{kind: byePacket + 1}.encode(), errInvalidPacket},
+		{"too large", packet{kind: dataPacket, payload: make([]byte, maxMessageSize+1)}.encode(), ErrMessageTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := decodePacket(test.datagram); !errors.Is(err, test.err) {
+				t.Errorf("Expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
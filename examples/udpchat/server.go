@@ -0,0 +1,149 @@
+package udpchat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode"
+)
+
+// Server runs a chat server on the UDP address until Ctrl-C
+func Server(address string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := ListenAndServe(ctx, address); err != nil {
+		log.Println("Chat server failed: " + err.Error())
+	}
+}
+
+func ListenAndServe(ctx context.Context, address string) error {
+	connection, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	log.Println("Listening on: " + address)
+	return Serve(ctx, connection)
+}
+
+type member struct {
+	address  net.Addr
+	nickname string
+}
+
+type chatServer struct {
+	transport *transport
+	members   map[string]member // Clients that joined, by address
+}
+
+// Serve runs the chat on connection until ctx is done, speaking the protocol
+// of the TCP chat: the first text of a client is its nickname and the others
+// are broadcast to everyone else. There are no connections to give to
+// handlers, the datagrams of all the clients arrive at the same socket, so a
+// single goroutine keeps the whole state. A client is gone when it says bye,
+// or when it stops acknowledging what it is sent.
+func Serve(ctx context.Context, connection net.PacketConn) error {
+	s := &chatServer{
+		transport: newTransport(connection, retransmitTimeout),
+		members:   make(map[string]member),
+	}
+	for {
+		select {
+		case delivery := <-s.transport.deliveries:
+			s.handle(delivery)
+		case <-ctx.Done():
+			s.shutdown()
+			return nil
+		}
+	}
+}
+
+func (s *chatServer) handle(delivery delivery) {
+	member, joined := s.members[delivery.from.String()]
+	switch {
+	case !joined && delivery.err == nil:
+		s.join(delivery.from, delivery.text)
+	case !joined:
+	case delivery.err != nil:
+		delete(s.members, delivery.from.String())
+		s.broadcast(delivery.from, fmt.Sprintf("* %s left", member.nickname))
+		log.Printf("%s left: %v", member.nickname, delivery.err)
+	default:
+		s.broadcast(delivery.from, fmt.Sprintf("%s: %s", member.nickname, delivery.text))
+	}
+}
+
+// join takes the first text of a client as its nickname, a taken or invalid
+// one is refused with a message and a bye
+func (s *chatServer) join(address net.Addr, nickname string) {
+	nickname = strings.TrimSpace(nickname)
+	err := validateNickname(nickname)
+	for _, other := range s.members {
+		if err == nil && strings.EqualFold(other.nickname, nickname) {
+			err = fmt.Errorf("nickname %s is taken", nickname)
+		}
+	}
+	if err != nil {
+		log.Println("Handshake failed: " + err.Error())
+		s.send(address, "Refused: "+err.Error())
+		// Waits for the acknowledgements, the messages of others don't have to
+		go s.transport.Leave(address)
+		return
+	}
+
+	s.members[address.String()] = member{address, nickname}
+	s.send(address, fmt.Sprintf("Welcome %s, %d online", nickname, len(s.members)))
+	s.broadcast(address, fmt.Sprintf("* %s joined", nickname))
+	log.Printf("%s joined as %s", address, nickname)
+}
+
+func validateNickname(nickname string) error {
+	switch {
+	case nickname == "":
+		return errors.New("nickname is empty")
+	case len([]rune(nickname)) > maxNicknameLength:
+		return fmt.Errorf("nickname is longer than %d characters", maxNicknameLength)
+	case strings.ContainsFunc(nickname, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }):
+		return errors.New("nickname may not contain spaces")
+	}
+	return nil
+}
+
+// broadcast sends text to all the members but the sender
+func (s *chatServer) broadcast(sender net.Addr, text string) {
+	for key, member := range s.members {
+		if key != sender.String() {
+			s.send(member.address, text)
+		}
+	}
+}
+
+func (s *chatServer) send(address net.Addr, text string) {
+	if err := s.transport.Send(address, text); err != nil {
+		log.Println("Error sending message: " + err.Error())
+	}
+}
+
+// shutdown tells the members and says bye to all of them at once, so a lost
+// one delays the others no more than by the time it takes to give up on it
+func (s *chatServer) shutdown() {
+	log.Println("Shutting down")
+	var leaving sync.WaitGroup
+	for _, member := range s.members {
+		s.send(member.address, "* Server is shutting down")
+		leaving.Add(1)
+		go func() {
+			defer leaving.Done()
+			s.transport.Leave(member.address)
+		}()
+	}
+	leaving.Wait()
+	s.transport.Close()
+	log.Println("Server stopped")
+}
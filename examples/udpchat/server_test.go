@@ -0,0 +1,114 @@
+package udpchat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClient struct {
+	t         *testing.T
+	transport *transport
+	server    net.Addr
+}
+
+func connect(t *testing.T, server net.Addr, nickname string) *testClient {
+	t.Helper()
+	client := &testClient{t, newTestTransport(t, 0, 0), server}
+	client.send(nickname)
+	return client
+}
+
+func (c *testClient) send(text string) {
+	c.t.Helper()
+	if err := c.transport.Send(c.server, text); err != nil {
+		c.t.Fatalf("Send failed: %v", err)
+	}
+}
+
+func (c *testClient) expect(expected string) {
+	c.t.Helper()
+	if delivery := receive(c.t, c.transport); delivery.err != nil || delivery.text != expected {
+		c.t.Fatalf("Received %q, %v; want %q", delivery.text, delivery.err, expected)
+	}
+}
+
+func (c *testClient) expectBye() {
+	c.t.Helper()
+	if delivery := receive(c.t, c.transport); !errors.Is(delivery.err, ErrPeerLeft) {
+		c.t.Fatalf("Received %q, %v; want a bye", delivery.text, delivery.err)
+	}
+}
+
+// startServer returns the address of a server and a function stopping it,
+// which returns once Serve does
+func startServer(t *testing.T) (net.Addr, func() error) {
+	t.Helper()
+	connection := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, connection) }()
+	stop := sync.OnceValue(func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			return errors.New("server didn't stop in time")
+		}
+	})
+	t.Cleanup(func() { stop() })
+	return connection.LocalAddr(), stop
+}
+
+func TestServerNicknamesAndNotifications(t *testing.T) {
+	address, stop := startServer(t)
+	defer stop() // While the clients are there to acknowledge the bye
+
+	anna := connect(t, address, "anna")
+	anna.expect("Welcome anna, 1 online")
+
+	refused := connect(t, address, "Anna")
+	refused.expect("Refused: nickname Anna is taken")
+	refused.expectBye()
+	invalid := connect(t, address, "jan kowalski")
+	invalid.expect("Refused: nickname may not contain spaces")
+
+	jan := connect(t, address, " jan ")
+	jan.expect("Welcome jan, 2 online")
+	anna.expect("* jan joined")
+
+	anna.send("hello")
+	jan.expect("anna: hello")
+	jan.send("hi")
+	anna.expect("jan: hi") // Anna's own message was not sent back to her
+
+	if err := jan.transport.Leave(address); err != nil {
+		t.Fatalf("Leave failed: %v", err)
+	}
+	anna.expect("* jan left")
+	ola := connect(t, address, "jan")
+	ola.expect("Welcome jan, 2 online") // The nickname is free again
+}
+
+func TestServerShutdown(t *testing.T) {
+	address, stop := startServer(t)
+	anna := connect(t, address, "anna")
+	anna.expect("Welcome anna, 1 online")
+	jan := connect(t, address, "jan")
+	jan.expect("Welcome jan, 2 online")
+	anna.expect("* jan joined")
+
+	jan.send("bye")
+	anna.expect("jan: bye")
+	if err := stop(); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	for _, client := range []*testClient{anna, jan} {
+		client.expect("* Server is shutting down")
+		client.expectBye()
+	}
+}
@@ -0,0 +1,287 @@
+package udpchat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// delivery is a text received from a peer or, with err set, the news that the
+// peer left or was lost
+type delivery struct {
+	from net.Addr
+	text string
+	err  error
+}
+
+type outgoing struct {
+	datagram []byte
+	sentAt   time.Time
+	attempts int
+}
+
+// peer is the state of a conversation with a single address, sequence
+// numbers are counted separately in each direction
+type peer struct {
+	address  net.Addr
+	next     uint32               // Sequence number of the next packet sent
+	pending  map[uint32]*outgoing // Sent and not acknowledged yet
+	expected uint32               // Sequence number of the next packet delivered
+	early    map[uint32]packet    // Received while an earlier one is missing
+	closedAt time.Time            // When the conversation ended, zero while it goes on
+}
+
+// transport is the reliability layer UDP lacks and TCP provides. Every text
+// gets a sequence number and is sent again until the peer acknowledges it,
+// the receiver acknowledges and drops duplicates and delivers texts in the
+// order they were sent. A peer that doesn't acknowledge a packet sent
+// maxAttempts times is considered lost, there is no other way to tell.
+type transport struct {
+	connection   net.PacketConn
+	timeout      time.Duration // Of an acknowledgement, before the packet is sent again
+	mutex        sync.Mutex
+	acknowledged *sync.Cond // Signalled when packets are acknowledged or peers end
+	peers        map[string]*peer
+	closed       bool
+	deliveries   chan delivery
+	done         chan struct{}
+	running      sync.WaitGroup
+}
+
+func newTransport(connection net.PacketConn, timeout time.Duration) *transport {
+	t := &transport{
+		connection: connection,
+		timeout:    timeout,
+		peers:      make(map[string]*peer),
+		deliveries: make(chan delivery, 100),
+		done:       make(chan struct{}),
+	}
+	t.acknowledged = sync.NewCond(&t.mutex)
+	t.running.Add(2)
+	go t.readLoop()
+	go t.retransmitLoop()
+	return t
+}
+
+// Send sends text to a peer and keeps sending it until it is acknowledged,
+// without waiting for that
+func (t *transport) Send(to net.Addr, text string) error {
+	if len(text) > maxMessageSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, len(text), maxMessageSize)
+	}
+	_, err := t.sendPacket(to, dataPacket, []byte(text))
+	return err
+}
+
+// Leave ends the conversation with a peer. The bye is delivered after the
+// texts sent before it and Leave waits until all of them are acknowledged,
+// or the peer is lost. The peer is then kept for a while, to acknowledge the
+// packets it sends again when an acknowledgement got lost, like TCP does in
+// the TIME_WAIT state.
+func (t *transport) Leave(to net.Addr) error {
+	p, err := t.sendPacket(to, byePacket, nil)
+	if err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for len(p.pending) > 0 && t.peers[to.String()] == p && !t.closed {
+		t.acknowledged.Wait()
+	}
+	switch {
+	case t.closed:
+		return net.ErrClosed
+	case t.peers[to.String()] != p:
+		return ErrPeerLost
+	}
+	if p.closedAt.IsZero() {
+		p.closedAt = time.Now()
+	}
+	return nil
+}
+
+// Close stops the transport, the texts not acknowledged yet are not sent again
+func (t *transport) Close() error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.acknowledged.Broadcast()
+	t.mutex.Unlock()
+
+	close(t.done)
+	err := t.connection.Close()
+	t.running.Wait()
+	close(t.deliveries)
+	return err
+}
+
+func (t *transport) sendPacket(to net.Addr, kind packetKind, payload []byte) (*peer, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	p := t.peer(to)
+	if !p.closedAt.IsZero() {
+		return nil, ErrPeerLeft
+	}
+	datagram := packet{kind: kind, sequence: p.next, payload: payload}.encode()
+	p.pending[p.next] = &outgoing{datagram: datagram, sentAt: time.Now(), attempts: 1}
+	p.next++
+	// A failed write is no different from a lost datagram, it is sent again
+	t.write(datagram, to)
+	return p, nil
+}
+
+// peer returns the conversation with an address, starting one if needed.
+// The mutex must be held.
+func (t *transport) peer(address net.Addr) *peer {
+	key := address.String()
+	p, ok := t.peers[key]
+	if !ok {
+		p = &peer{address: address, pending: make(map[uint32]*outgoing), early: make(map[uint32]packet)}
+		t.peers[key] = p
+	}
+	return p
+}
+
+// write sends a datagram, UDP writes don't wait for the peer so it is done
+// while holding the mutex
+func (t *transport) write(datagram []byte, to net.Addr) {
+	if _, err := t.connection.WriteTo(datagram, to); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Println("Error sending packet: " + err.Error())
+	}
+}
+
+func (t *transport) readLoop() {
+	defer t.running.Done()
+	buffer := make([]byte, maxPacketSize+1) // One more byte reveals oversized datagrams
+	for {
+		size, from, err := t.connection.ReadFrom(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Println("Error receiving packet: " + err.Error())
+			continue
+		}
+		received, err := decodePacket(buffer[:size])
+		if err != nil {
+			log.Println("Skipping packet: " + err.Error())
+			continue
+		}
+		for _, delivery := range t.receive(from, received) {
+			select {
+			case t.deliveries <- delivery:
+			case <-t.done:
+				return
+			}
+		}
+	}
+}
+
+// receive updates the conversation with a peer and returns what can be
+// delivered: nothing while an earlier packet is missing, or the packet and
+// the ones received early that follow it
+func (t *transport) receive(from net.Addr, received packet) []delivery {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if received.kind == ackPacket {
+		if p, ok := t.peers[from.String()]; ok {
+			delete(p.pending, received.sequence)
+			t.acknowledged.Broadcast()
+		}
+		return nil
+	}
+
+	p := t.peer(from)
+	ack := packet{kind: ackPacket, sequence: received.sequence}.encode()
+	switch {
+	case received.sequence < p.expected:
+		// Delivered already, sent again because the acknowledgement was lost
+		t.write(ack, from)
+		return nil
+	case !p.closedAt.IsZero() || received.sequence >= p.expected+receiveWindow:
+		// Not acknowledged, after the conversation or with no room to keep it
+		return nil
+	}
+	t.write(ack, from)
+	p.early[received.sequence] = received
+
+	var deliveries []delivery
+	for {
+		next, ok := p.early[p.expected]
+		if !ok {
+			return deliveries
+		}
+		delete(p.early, p.expected)
+		p.expected++
+		if next.kind == byePacket {
+			// What wasn't acknowledged yet never will be
+			p.closedAt = time.Now()
+			clear(p.pending)
+			clear(p.early)
+			t.acknowledged.Broadcast()
+			return append(deliveries, delivery{from: from, err: ErrPeerLeft})
+		}
+		deliveries = append(deliveries, delivery{from: from, text: string(next.payload)})
+	}
+}
+
+func (t *transport) retransmitLoop() {
+	defer t.running.Done()
+	ticker := time.NewTicker(t.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, delivery := range t.retransmit(now) {
+				select {
+				case t.deliveries <- delivery:
+				case <-t.done:
+					return
+				}
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// retransmit sends again the packets not acknowledged in time, gives up on
+// peers that stopped acknowledging and forgets the conversations that ended
+// long enough ago
+func (t *transport) retransmit(now time.Time) []delivery {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var lost []delivery
+	for key, p := range t.peers {
+		if !p.closedAt.IsZero() {
+			if now.Sub(p.closedAt) > t.timeout*maxAttempts {
+				delete(t.peers, key)
+			}
+			continue
+		}
+		for _, sent := range p.pending {
+			if now.Sub(sent.sentAt) < t.timeout {
+				continue
+			}
+			if sent.attempts == maxAttempts {
+				delete(t.peers, key)
+				t.acknowledged.Broadcast()
+				lost = append(lost, delivery{from: p.address, err: ErrPeerLost})
+				break
+			}
+			sent.attempts++
+			sent.sentAt = now
+			t.write(sent.datagram, p.address)
+		}
+	}
+	return lost
+}
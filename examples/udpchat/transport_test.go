@@ -0,0 +1,112 @@
+package udpchat
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lossyConn drops some of the datagrams written and duplicates others, as a
+// congested network might, so the reliability layer has work to do
+type lossyConn struct {
+	net.PacketConn
+	mutex  sync.Mutex
+	random *rand.Rand
+	loss   float64
+}
+
+func (c *lossyConn) WriteTo(datagram []byte, address net.Addr) (int, error) {
+	c.mutex.Lock()
+	draw := c.random.Float64()
+	c.mutex.Unlock()
+	switch {
+	case draw < c.loss:
+		return len(datagram), nil
+	case draw < 2*c.loss:
+		c.PacketConn.WriteTo(datagram, address)
+	}
+	return c.PacketConn.WriteTo(datagram, address)
+}
+
+func listen(t *testing.T) net.PacketConn {
+	t.Helper()
+	connection, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return connection
+}
+
+func newTestTransport(t *testing.T, loss float64, seed uint64) *transport {
+	t.Helper()
+	connection := &lossyConn{PacketConn: listen(t), random: rand.New(rand.NewPCG(seed, seed)), loss: loss}
+	transport := newTransport(connection, 10*time.Millisecond)
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+func receive(t *testing.T, transport *transport) delivery {
+	t.Helper()
+	select {
+	case delivery := <-transport.deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("Nothing was delivered")
+		return delivery{}
+	}
+}
+
+func TestTransportDeliversInOrderDespiteLoss(t *testing.T) {
+	anna := newTestTransport(t, 0.1, 1)
+	jan := newTestTransport(t, 0.1, 2)
+	annaAddress, janAddress := anna.connection.LocalAddr(), jan.connection.LocalAddr()
+
+	const count = 200
+	for i := range count {
+		if err := anna.Send(janAddress, fmt.Sprintf("message %d", i)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	jan.Send(annaAddress, "reply")
+	for i := range count {
+		if delivery := receive(t, jan); delivery.err != nil || delivery.text != fmt.Sprintf("message %d", i) {
+			t.Fatalf("Delivered %+v; want message %d", delivery, i)
+		}
+	}
+	if delivery := receive(t, anna); delivery.text != "reply" {
+		t.Errorf("Delivered %+v; want the reply", delivery)
+	}
+
+	if err := anna.Leave(janAddress); err != nil {
+		t.Fatalf("Leave failed: %v", err)
+	}
+	if delivery := receive(t, jan); !errors.Is(delivery.err, ErrPeerLeft) {
+		t.Errorf("Delivered %+v; want ErrPeerLeft", delivery)
+	}
+	if err := anna.Send(janAddress, "after bye"); !errors.Is(err, ErrPeerLeft) {
+		t.Errorf("Expected sending after bye to fail, got %v", err)
+	}
+}
+
+func TestTransportLosesSilentPeer(t *testing.T) {
+	anna := newTestTransport(t, 0, 1)
+	silent := listen(t)
+	address := silent.LocalAddr()
+	silent.Close()
+
+	anna.Send(address, "anyone there?")
+	started := time.Now()
+	if delivery := receive(t, anna); !errors.Is(delivery.err, ErrPeerLost) || delivery.from.String() != address.String() {
+		t.Fatalf("Delivered %+v; want ErrPeerLost", delivery)
+	}
+	if elapsed := time.Since(started); elapsed < anna.timeout*(maxAttempts-1) {
+		t.Errorf("Peer lost after %v, before all the attempts", elapsed)
+	}
+	if err := anna.Leave(address); !errors.Is(err, ErrPeerLost) {
+		t.Errorf("Expected leaving a silent peer to fail, got %v", err)
+	}
+}
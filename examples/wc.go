@@ -0,0 +1,163 @@
+package examples
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type Counts struct {
+	Lines, Words, Bytes int64
+}
+
+func (c *Counts) add(other Counts) {
+	c.Lines += other.Lines
+	c.Words += other.Words
+	c.Bytes += other.Bytes
+}
+
+// WordCounter is a pipeline stage, it passes the data of a reader through
+// unchanged and counts it on the way. Words are separated by ASCII whitespace.
+type WordCounter struct {
+	input  io.Reader
+	counts Counts
+	inWord bool // Whether the last read ended inside a word
+}
+
+func NewWordCounter(input io.Reader) *WordCounter {
+	return &WordCounter{input: input}
+}
+
+func (c *WordCounter) Read(p []byte) (int, error) {
+	n, err := c.input.Read(p)
+	for _, b := range p[:n] {
+		switch b {
+		case '\n':
+			c.counts.Lines++
+			c.inWord = false
+		case ' ', '\t', '\v', '\f', '\r':
+			c.inWord = false
+		default:
+			if !c.inWord {
+				c.counts.Words++
+				c.inWord = true
+			}
+		}
+	}
+	c.counts.Bytes += int64(n)
+	return n, err
+}
+
+// Counts returns what passed through so far
+func (c *WordCounter) Counts() Counts {
+	return c.counts
+}
+
+// Count reads input to the end and counts it
+func Count(input io.Reader) (Counts, error) {
+	counter := NewWordCounter(input)
+	_, err := io.Copy(io.Discard, counter)
+	return counter.Counts(), err
+}
+
+func Wc() {
+	os.Exit(RunWc(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// RunWc prints the lines, words and bytes of the files named in args, or of
+// stdin when there are none or the name is "-", followed by a total for
+// several files. It returns 0 on success, 1 if any file failed and 2 for
+// invalid flags.
+func RunWc(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: wc [-l] [-w] [-c] [path ...]")
+		flags.PrintDefaults()
+	}
+	showLines := flags.Bool("l", false, "Print the line count")
+	showWords := flags.Bool("w", false, "Print the word count")
+	showBytes := flags.Bool("c", false, "Print the byte count")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if !*showLines && !*showWords && !*showBytes {
+		*showLines, *showWords, *showBytes = true, true, true
+	}
+	paths := flags.Args()
+
+	output := bufio.NewWriter(stdout)
+	report := func(counts Counts, name string) {
+		for _, column := range []struct {
+			shown bool
+			value int64
+		}{{*showLines, counts.Lines}, {*showWords, counts.Words}, {*showBytes, counts.Bytes}} {
+			if column.shown {
+				fmt.Fprintf(output, "%8d", column.value)
+			}
+		}
+		if name != "" {
+			fmt.Fprintf(output, " %s", name)
+		}
+		output.WriteByte('\n')
+	}
+
+	if len(paths) == 0 {
+		counts, err := Count(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "wc: %v\n", err)
+			return 1
+		}
+		report(counts, "")
+		return flushCommand(output, stderr, "wc", 0)
+	}
+	exitCode := 0
+	total := Counts{}
+	for _, path := range paths {
+		counts, err := countPath(path, stdin)
+		if err != nil {
+			output.Flush()
+			fmt.Fprintf(stderr, "wc: %v\n", err)
+			exitCode = 1
+			continue
+		}
+		total.add(counts)
+		report(counts, path)
+	}
+	if len(paths) > 1 {
+		report(total, "total")
+	}
+	return flushCommand(output, stderr, "wc", exitCode)
+}
+
+func countPath(path string, stdin io.Reader) (Counts, error) {
+	if path == "-" {
+		return Count(stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer file.Close()
+	counts, err := Count(file)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	return counts, err
+}
+
+// flushCommand writes out the buffered output of a command and returns its
+// exit code, 1 when the output could not be written
+func flushCommand(output *bufio.Writer, stderr io.Writer, command string, exitCode int) int {
+	if err := output.Flush(); err != nil {
+		fmt.Fprintf(stderr, "%s: write error: %v\n", command, err)
+		return 1
+	}
+	return exitCode
+}
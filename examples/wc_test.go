@@ -0,0 +1,68 @@
+package examples
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWordCounter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Counts
+	}{
+		{"Empty", "", Counts{}},
+		{"Words and lines", "one two\nthree\n", Counts{2, 3, 14}},
+		{"No final newline", "one  two", Counts{0, 2, 8}},
+		{"Whitespace only", " \t\r\n\n", Counts{2, 0, 5}},
+		{"Unicode", "zażółć gęślą\n", Counts{1, 2, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time, so words span reads
+			counter := NewWordCounter(iotest.OneByteReader(strings.NewReader(tt.input)))
+			passed, err := io.ReadAll(counter)
+			if err != nil || string(passed) != tt.input {
+				t.Fatalf("Expected the input passed through, got %q, %v", passed, err)
+			}
+			if counts := counter.Counts(); counts != tt.expected {
+				t.Errorf("Counts() = %+v; want %+v", counts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunWc(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	os.WriteFile(first, []byte("a b\nc\n"), 0644)
+	os.WriteFile(second, []byte("hello"), 0644)
+
+	tests := []struct {
+		name     string
+		args     []string
+		code     int
+		expected string
+	}{
+		{"Stdin", nil, 0, "       1       2      11\n"},
+		{"Selected counts", []string{"-l", "-c"}, 0, "       1      11\n"},
+		{"Files with a total", []string{"-w", first, second}, 0, "       3 " + first + "\n       1 " + second + "\n       4 total\n"},
+		{"Stdin among files", []string{"-l", "-", first}, 0, "       1 -\n       2 " + first + "\n       3 total\n"},
+		{"Missing file", []string{"-l", filepath.Join(dir, "missing"), first}, 1, "       2 " + first + "\n       2 total\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := RunWc(tt.args, strings.NewReader("from stdin\n"), &stdout, &stderr)
+			if code != tt.code || stdout.String() != tt.expected {
+				t.Errorf("RunWc(%v) = %d, %q; want %d, %q (stderr %q)", tt.args, code, stdout.String(), tt.code, tt.expected, stderr.String())
+			}
+		})
+	}
+}
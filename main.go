@@ -24,6 +24,9 @@ func main() {
 	// chat.Server("localhost:8000")
 	// chat.Client("localhost:8000")
 
+	// udpchat.Server("localhost:8001")
+	// udpchat.Client("localhost:8001")
+
 	// fmt.Printf("Hello World\n")
 
 	//budget := &b.Budget{}